@@ -1,3 +1,8 @@
+// Package collision detects overlaps between 2D shapes.
+//
+// The Check functions treat shapes that only touch, sharing an edge or a
+// single point, as colliding. The Strict variants require the shapes to
+// overlap by a positive area, so touching shapes don't collide.
 package collision
 
 import (
@@ -53,23 +58,45 @@ func NewPolygon(points []Point) *Polygon {
 	return &Polygon{Points: points}
 }
 
-// CheckAABBCollision checks if two AABBs collide
+// CheckAABBCollision checks if two AABBs collide. AABBs that touch along
+// an edge or at a corner collide.
 func CheckAABBCollision(a, b *AABB) bool {
+	return a.X <= b.X+b.Width &&
+		a.X+a.Width >= b.X &&
+		a.Y <= b.Y+b.Height &&
+		a.Y+a.Height >= b.Y
+}
+
+// CheckAABBCollisionStrict checks if two AABBs overlap. AABBs that only
+// touch along an edge or at a corner don't collide.
+func CheckAABBCollisionStrict(a, b *AABB) bool {
 	return a.X < b.X+b.Width &&
 		a.X+a.Width > b.X &&
 		a.Y < b.Y+b.Height &&
 		a.Y+a.Height > b.Y
 }
 
-// CheckCircleCollision checks if two circles collide
+// CheckCircleCollision checks if two circles collide. Circles whose
+// centers are exactly the sum of their radii apart touch, and collide.
 func CheckCircleCollision(a, b *Circle) bool {
+	// Squared distances avoid the rounding of a square root at the boundary
+	return centerDistanceSquared(a, b) <= (a.Radius+b.Radius)*(a.Radius+b.Radius)
+}
+
+// CheckCircleCollisionStrict checks if two circles overlap. Circles that
+// only touch don't collide.
+func CheckCircleCollisionStrict(a, b *Circle) bool {
+	return centerDistanceSquared(a, b) < (a.Radius+b.Radius)*(a.Radius+b.Radius)
+}
+
+func centerDistanceSquared(a, b *Circle) float64 {
 	dx := a.X - b.X
 	dy := a.Y - b.Y
-	distance := math.Sqrt(dx*dx + dy*dy)
-	return distance < (a.Radius + b.Radius)
+	return dx*dx + dy*dy
 }
 
-// CheckAABBCircleCollision checks if an AABB and circle collide
+// CheckAABBCircleCollision checks if an AABB and circle collide. A circle
+// touching the AABB's edge collides.
 func CheckAABBCircleCollision(aabb *AABB, circle *Circle) bool {
 	// Find the closest point on the AABB to the circle center
 	closestX := circle.X
@@ -90,9 +117,7 @@ func CheckAABBCircleCollision(aabb *AABB, circle *Circle) bool {
 	// Calculate distance between circle center and closest point
 	dx := circle.X - closestX
 	dy := circle.Y - closestY
-	distance := math.Sqrt(dx*dx + dy*dy)
-
-	return distance < circle.Radius
+	return dx*dx+dy*dy <= circle.Radius*circle.Radius
 }
 
 // CheckPointInAABB checks if a point is inside an AABB
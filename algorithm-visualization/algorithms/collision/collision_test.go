@@ -0,0 +1,63 @@
+package collision_test
+
+import (
+	"testing"
+
+	"algorithm-visualization/algorithms/collision"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollisionBoundaries(t *testing.T) {
+	const nudge = 1e-9
+
+	aabbTests := []struct {
+		name      string
+		aabb1     *collision.AABB
+		aabb2     *collision.AABB
+		inclusive bool
+		strict    bool
+	}{
+		{"exactly touching edge", collision.NewAABB(0, 0, 5, 5), collision.NewAABB(5, 0, 5, 5), true, false},
+		{"exactly touching corner", collision.NewAABB(0, 0, 5, 5), collision.NewAABB(5, 5, 5, 5), true, false},
+		{"just overlapping", collision.NewAABB(0, 0, 5, 5), collision.NewAABB(5-nudge, 0, 5, 5), true, true},
+		{"just separated", collision.NewAABB(0, 0, 5, 5), collision.NewAABB(5+nudge, 0, 5, 5), false, false},
+	}
+	for _, tt := range aabbTests {
+		t.Run("AABB "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.inclusive, collision.CheckAABBCollision(tt.aabb1, tt.aabb2))
+			assert.Equal(t, tt.inclusive, collision.CheckAABBCollision(tt.aabb2, tt.aabb1))
+			assert.Equal(t, tt.strict, collision.CheckAABBCollisionStrict(tt.aabb1, tt.aabb2))
+			assert.Equal(t, tt.strict, collision.CheckAABBCollisionStrict(tt.aabb2, tt.aabb1))
+		})
+	}
+
+	circleTests := []struct {
+		name      string
+		circle1   *collision.Circle
+		circle2   *collision.Circle
+		inclusive bool
+		strict    bool
+	}{
+		{"exactly touching", collision.NewCircle(0, 0, 3), collision.NewCircle(6, 0, 3), true, false},
+		{"exactly touching diagonally", collision.NewCircle(0, 0, 2), collision.NewCircle(3, 4, 3), true, false},
+		{"just overlapping", collision.NewCircle(0, 0, 3), collision.NewCircle(6-nudge, 0, 3), true, true},
+		{"just separated", collision.NewCircle(0, 0, 3), collision.NewCircle(6+nudge, 0, 3), false, false},
+	}
+	for _, tt := range circleTests {
+		t.Run("circle "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.inclusive, collision.CheckCircleCollision(tt.circle1, tt.circle2))
+			assert.Equal(t, tt.inclusive, collision.CheckCircleCollision(tt.circle2, tt.circle1))
+			assert.Equal(t, tt.strict, collision.CheckCircleCollisionStrict(tt.circle1, tt.circle2))
+			assert.Equal(t, tt.strict, collision.CheckCircleCollisionStrict(tt.circle2, tt.circle1))
+		})
+	}
+
+	t.Run("zero size shapes at the same point", func(t *testing.T) {
+		box := collision.NewAABB(0, 0, 0, 0)
+		dot := collision.NewCircle(0, 0, 0)
+		assert.True(t, collision.CheckAABBCollision(box, box))
+		assert.False(t, collision.CheckAABBCollisionStrict(box, box))
+		assert.True(t, collision.CheckCircleCollision(dot, dot))
+		assert.False(t, collision.CheckCircleCollisionStrict(dot, dot))
+	})
+}
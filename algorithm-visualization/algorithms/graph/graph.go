@@ -0,0 +1,175 @@
+package graph
+
+import (
+	"math"
+
+	"algorithm-visualization/algorithms/heap"
+	"algorithm-visualization/algorithms/unionfind"
+)
+
+// Edge is a weighted edge to vertex To
+type Edge struct {
+	To     int
+	Weight float64
+}
+
+// Graph is an adjacency-list graph over vertices 0..n-1
+type Graph struct {
+	adj      [][]Edge
+	directed bool
+}
+
+// NewGraph creates an undirected graph with n vertices
+func NewGraph(n int) *Graph {
+	return &Graph{adj: make([][]Edge, n)}
+}
+
+// NewDirectedGraph creates a directed graph with n vertices
+func NewDirectedGraph(n int) *Graph {
+	return &Graph{adj: make([][]Edge, n), directed: true}
+}
+
+// V returns the number of vertices
+func (g *Graph) V() int {
+	return len(g.adj)
+}
+
+// IsDirected reports whether edges only go from u to v
+func (g *Graph) IsDirected() bool {
+	return g.directed
+}
+
+// IsValidVertex checks if the given vertex is in the graph
+func (g *Graph) IsValidVertex(v int) bool {
+	return v >= 0 && v < len(g.adj)
+}
+
+// AddEdge adds an edge from u to v, and from v to u for undirected graphs.
+// Weights must be non-negative for Dijkstra.
+func (g *Graph) AddEdge(u, v int, weight float64) {
+	g.adj[u] = append(g.adj[u], Edge{To: v, Weight: weight})
+	if !g.directed && u != v {
+		g.adj[v] = append(g.adj[v], Edge{To: u, Weight: weight})
+	}
+}
+
+// Neighbors returns the edges leaving v in insertion order
+func (g *Graph) Neighbors(v int) []Edge {
+	return g.adj[v]
+}
+
+// BFS returns the vertices reachable from start in breadth-first order
+func (g *Graph) BFS(start int) []int {
+	visited := make([]bool, len(g.adj))
+	order := []int{start}
+	visited[start] = true
+
+	for i := 0; i < len(order); i++ {
+		for _, e := range g.adj[order[i]] {
+			if !visited[e.To] {
+				visited[e.To] = true
+				order = append(order, e.To)
+			}
+		}
+	}
+	return order
+}
+
+// DFS returns the vertices reachable from start in depth-first preorder,
+// visiting neighbors in insertion order
+func (g *Graph) DFS(start int) []int {
+	visited := make([]bool, len(g.adj))
+	order := make([]int, 0)
+	g.dfs(start, visited, &order)
+	return order
+}
+
+func (g *Graph) dfs(v int, visited []bool, order *[]int) {
+	visited[v] = true
+	*order = append(*order, v)
+	for _, e := range g.adj[v] {
+		if !visited[e.To] {
+			g.dfs(e.To, visited, order)
+		}
+	}
+}
+
+// Dijkstra computes shortest distances from src. dist[v] is +Inf and
+// prev[v] is -1 for vertices src can't reach; prev[src] is also -1.
+func (g *Graph) Dijkstra(src int) (dist []float64, prev []int) {
+	n := len(g.adj)
+	dist = make([]float64, n)
+	prev = make([]int, n)
+	for i := range dist {
+		dist[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	dist[src] = 0
+
+	pq := heap.New(func(a, b queued) bool { return a.dist < b.dist })
+	pq.Push(queued{vertex: src, dist: 0})
+	for pq.Len() > 0 {
+		cur, _ := pq.Pop()
+		if cur.dist > dist[cur.vertex] {
+			continue // stale entry, a shorter path was already found
+		}
+		for _, e := range g.adj[cur.vertex] {
+			if alt := cur.dist + e.Weight; alt < dist[e.To] {
+				dist[e.To] = alt
+				prev[e.To] = cur.vertex
+				pq.Push(queued{vertex: e.To, dist: alt})
+			}
+		}
+	}
+	return dist, prev
+}
+
+// ShortestPath returns the vertices on a shortest path from src to dst and
+// its length, or false if dst is unreachable
+func (g *Graph) ShortestPath(src, dst int) ([]int, float64, bool) {
+	dist, prev := g.Dijkstra(src)
+	if math.IsInf(dist[dst], 1) {
+		return nil, dist[dst], false
+	}
+
+	path := make([]int, 0)
+	for v := dst; v != -1; v = prev[v] {
+		path = append(path, v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, dist[dst], true
+}
+
+// ConnectedComponents groups the vertices using union-find, ignoring edge
+// direction. Components are ordered by their smallest vertex and each lists
+// its vertices in ascending order.
+func (g *Graph) ConnectedComponents() [][]int {
+	uf := unionfind.NewWeightedQuickUnionWithPathCompression(len(g.adj))
+	for u, edges := range g.adj {
+		for _, e := range edges {
+			uf.Union(u, e.To)
+		}
+	}
+
+	components := make([][]int, 0, uf.Count())
+	index := make(map[int]int)
+	for v := range g.adj {
+		root := uf.Find(v)
+		i, ok := index[root]
+		if !ok {
+			i = len(components)
+			index[root] = i
+			components = append(components, nil)
+		}
+		components[i] = append(components[i], v)
+	}
+	return components
+}
+
+// queued is a vertex waiting in Dijkstra's priority queue
+type queued struct {
+	vertex int
+	dist   float64
+}
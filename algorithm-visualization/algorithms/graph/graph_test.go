@@ -0,0 +1,158 @@
+package graph_test
+
+import (
+	"math"
+	"testing"
+
+	"algorithm-visualization/algorithms/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type weightedEdge struct {
+	u, v   int
+	weight float64
+}
+
+func buildGraph(n int, directed bool, edges []weightedEdge) *graph.Graph {
+	g := graph.NewGraph(n)
+	if directed {
+		g = graph.NewDirectedGraph(n)
+	}
+	for _, e := range edges {
+		g.AddEdge(e.u, e.v, e.weight)
+	}
+	return g
+}
+
+// The classic six vertex example: 0 -> 4 is cheapest via 2 and 5
+var knownWeighted = []weightedEdge{
+	{0, 1, 7}, {0, 2, 9}, {0, 5, 14},
+	{1, 2, 10}, {1, 3, 15},
+	{2, 3, 11}, {2, 5, 2},
+	{3, 4, 6}, {4, 5, 9},
+}
+
+func TestGraph_ShortestPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		directed  bool
+		edges     []weightedEdge
+		src, dst  int
+		reachable bool
+		distance  float64
+		path      []int
+	}{
+		{"known weighted graph", 6, false, knownWeighted, 0, 4, true, 20, []int{0, 2, 5, 4}},
+		{"known weighted graph neighbor", 6, false, knownWeighted, 0, 3, true, 20, []int{0, 2, 3}},
+		{"source to itself", 6, false, knownWeighted, 0, 0, true, 0, []int{0}},
+		{"disconnected", 4, false, []weightedEdge{{0, 1, 1}, {2, 3, 1}}, 0, 3, false, math.Inf(1), nil},
+		{"isolated vertex", 3, false, []weightedEdge{{0, 1, 1}}, 0, 2, false, math.Inf(1), nil},
+		{"cycle takes shorter direction", 4, false, []weightedEdge{{0, 1, 1}, {1, 2, 1}, {2, 3, 1}, {3, 0, 1}}, 0, 3, true, 1, []int{0, 3}},
+		{"directed cycle goes the long way", 4, true, []weightedEdge{{0, 1, 1}, {1, 2, 1}, {2, 3, 1}, {3, 0, 1}}, 0, 3, true, 3, []int{0, 1, 2, 3}},
+		{"directed edge against direction", 2, true, []weightedEdge{{1, 0, 1}}, 0, 1, false, math.Inf(1), nil},
+		{"cheaper multi hop beats direct edge", 3, false, []weightedEdge{{0, 2, 10}, {0, 1, 2}, {1, 2, 3}}, 0, 2, true, 5, []int{0, 1, 2}},
+		{"self loop ignored", 2, false, []weightedEdge{{0, 0, 1}, {0, 1, 4}}, 0, 1, true, 4, []int{0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := buildGraph(tt.n, tt.directed, tt.edges)
+
+			path, distance, ok := g.ShortestPath(tt.src, tt.dst)
+			assert.Equal(t, tt.reachable, ok)
+			assert.Equal(t, tt.distance, distance)
+			assert.Equal(t, tt.path, path)
+		})
+	}
+}
+
+func TestGraph_Dijkstra(t *testing.T) {
+	g := buildGraph(6, false, knownWeighted)
+
+	dist, prev := g.Dijkstra(0)
+	assert.Equal(t, []float64{0, 7, 9, 20, 20, 11}, dist)
+	assert.Equal(t, -1, prev[0], "source has no predecessor")
+	assert.Equal(t, 2, prev[5])
+	assert.Equal(t, 5, prev[4])
+
+	disconnected := buildGraph(3, false, []weightedEdge{{0, 1, 1}})
+	dist, prev = disconnected.Dijkstra(0)
+	assert.True(t, math.IsInf(dist[2], 1))
+	assert.Equal(t, -1, prev[2])
+}
+
+func TestGraph_Traversal(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		edges []weightedEdge
+		start int
+		bfs   []int
+		dfs   []int
+	}{
+		{
+			name:  "tree",
+			n:     7,
+			edges: []weightedEdge{{0, 1, 1}, {0, 2, 1}, {1, 3, 1}, {1, 4, 1}, {2, 5, 1}, {2, 6, 1}},
+			start: 0,
+			bfs:   []int{0, 1, 2, 3, 4, 5, 6},
+			dfs:   []int{0, 1, 3, 4, 2, 5, 6},
+		},
+		{
+			name:  "cycle visits each vertex once",
+			n:     4,
+			edges: []weightedEdge{{0, 1, 1}, {1, 2, 1}, {2, 3, 1}, {3, 0, 1}},
+			start: 0,
+			bfs:   []int{0, 1, 3, 2},
+			dfs:   []int{0, 1, 2, 3},
+		},
+		{
+			name:  "disconnected stays in its component",
+			n:     5,
+			edges: []weightedEdge{{0, 1, 1}, {2, 3, 1}, {3, 4, 1}},
+			start: 2,
+			bfs:   []int{2, 3, 4},
+			dfs:   []int{2, 3, 4},
+		},
+		{
+			name:  "single vertex",
+			n:     1,
+			start: 0,
+			bfs:   []int{0},
+			dfs:   []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := buildGraph(tt.n, false, tt.edges)
+			assert.Equal(t, tt.bfs, g.BFS(tt.start))
+			assert.Equal(t, tt.dfs, g.DFS(tt.start))
+		})
+	}
+}
+
+func TestGraph_ConnectedComponents(t *testing.T) {
+	tests := []struct {
+		name       string
+		n          int
+		directed   bool
+		edges      []weightedEdge
+		components [][]int
+	}{
+		{"no edges", 3, false, nil, [][]int{{0}, {1}, {2}}},
+		{"connected", 6, false, knownWeighted, [][]int{{0, 1, 2, 3, 4, 5}}},
+		{"disconnected", 6, false, []weightedEdge{{0, 3, 1}, {1, 4, 1}, {4, 5, 1}}, [][]int{{0, 3}, {1, 4, 5}, {2}}},
+		{"cycle", 4, false, []weightedEdge{{0, 1, 1}, {1, 2, 1}, {2, 0, 1}}, [][]int{{0, 1, 2}, {3}}},
+		{"directed edges count as weak links", 3, true, []weightedEdge{{2, 0, 1}}, [][]int{{0, 2}, {1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := buildGraph(tt.n, tt.directed, tt.edges)
+			require.Equal(t, tt.components, g.ConnectedComponents())
+		})
+	}
+}
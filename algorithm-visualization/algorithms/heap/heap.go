@@ -0,0 +1,105 @@
+package heap
+
+import "cmp"
+
+// Heap is a binary heap ordered by less: the item for which less reports
+// true against every other item is at the top. It is not safe for
+// concurrent use.
+type Heap[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+// New creates an empty heap ordered by less
+func New[T any](less func(a, b T) bool) *Heap[T] {
+	return &Heap[T]{less: less}
+}
+
+// NewMin creates an empty heap that pops the smallest item first
+func NewMin[T cmp.Ordered]() *Heap[T] {
+	return New(func(a, b T) bool { return a < b })
+}
+
+// NewMax creates an empty heap that pops the largest item first
+func NewMax[T cmp.Ordered]() *Heap[T] {
+	return New(func(a, b T) bool { return a > b })
+}
+
+// From heapifies items in place in O(n) and returns a heap using them as
+// its storage. Popping every item leaves items ordered with the first
+// popped item last.
+func From[T any](items []T, less func(a, b T) bool) *Heap[T] {
+	h := &Heap[T]{items: items, less: less}
+	for i := len(items)/2 - 1; i >= 0; i-- {
+		h.down(i, len(items))
+	}
+	return h
+}
+
+// Len returns the number of items in the heap
+func (h *Heap[T]) Len() int {
+	return len(h.items)
+}
+
+// Push adds an item in O(log n)
+func (h *Heap[T]) Push(item T) {
+	h.items = append(h.items, item)
+	h.up(len(h.items) - 1)
+}
+
+// Peek returns the top item without removing it
+func (h *Heap[T]) Peek() (T, bool) {
+	if len(h.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return h.items[0], true
+}
+
+// Pop removes and returns the top item in O(log n)
+func (h *Heap[T]) Pop() (T, bool) {
+	n := len(h.items)
+	if n == 0 {
+		var zero T
+		return zero, false
+	}
+
+	last := n - 1
+	h.items[0], h.items[last] = h.items[last], h.items[0]
+	h.down(0, last)
+
+	item := h.items[last]
+	h.items = h.items[:last]
+	return item, true
+}
+
+func (h *Heap[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(h.items[i], h.items[parent]) {
+			return
+		}
+		h.items[i], h.items[parent] = h.items[parent], h.items[i]
+		i = parent
+	}
+}
+
+// down sifts the item at i towards the leaves among the first n items
+func (h *Heap[T]) down(i, n int) {
+	for {
+		top := i
+		left, right := 2*i+1, 2*i+2
+
+		if left < n && h.less(h.items[left], h.items[top]) {
+			top = left
+		}
+		if right < n && h.less(h.items[right], h.items[top]) {
+			top = right
+		}
+		if top == i {
+			return
+		}
+		h.items[i], h.items[top] = h.items[top], h.items[i]
+		i = top
+	}
+}
@@ -0,0 +1,115 @@
+package heap_test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"algorithm-visualization/algorithms/heap"
+	"algorithm-visualization/algorithms/sorting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeap_EmptyPeekAndPop(t *testing.T) {
+	h := heap.NewMin[int]()
+
+	_, ok := h.Peek()
+	assert.False(t, ok)
+	_, ok = h.Pop()
+	assert.False(t, ok)
+	assert.Equal(t, 0, h.Len())
+}
+
+func TestHeap_RandomPushPopKeepsOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		heap  func() *heap.Heap[int]
+		first func(values []int) int
+	}{
+		{"min heap", heap.NewMin[int], func(values []int) int { return values[0] }},
+		{"max heap", heap.NewMax[int], func(values []int) int { return values[len(values)-1] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(42))
+			h := tt.heap()
+			var reference []int // kept sorted ascending
+
+			for op := 0; op < 5000; op++ {
+				if len(reference) == 0 || rng.Intn(3) > 0 {
+					v := rng.Intn(200) - 100
+					h.Push(v)
+					reference = append(reference, v)
+					sort.Ints(reference)
+				} else {
+					want := tt.first(reference)
+					got, ok := h.Pop()
+					require.True(t, ok)
+					require.Equal(t, want, got, "pop %d", op)
+
+					i := sort.SearchInts(reference, want)
+					reference = append(reference[:i], reference[i+1:]...)
+				}
+
+				require.Equal(t, len(reference), h.Len())
+				if len(reference) > 0 {
+					top, ok := h.Peek()
+					require.True(t, ok)
+					require.Equal(t, tt.first(reference), top, "peek after op %d", op)
+				}
+			}
+		})
+	}
+}
+
+func TestHeap_FromHeapifiesInPlace(t *testing.T) {
+	items := []int{9, 4, 7, 1, 8, 2, 2, 6}
+	h := heap.From(items, func(a, b int) bool { return a < b })
+
+	var popped []int
+	for h.Len() > 0 {
+		v, _ := h.Pop()
+		popped = append(popped, v)
+	}
+
+	assert.Equal(t, []int{1, 2, 2, 4, 6, 7, 8, 9}, popped)
+	assert.Equal(t, []int{9, 8, 7, 6, 4, 2, 2, 1}, items, "popped items fill the slice from the end")
+}
+
+func TestHeap_CustomComparator(t *testing.T) {
+	type task struct {
+		name     string
+		priority int
+	}
+
+	h := heap.New(func(a, b task) bool { return a.priority > b.priority })
+	h.Push(task{"low", 1})
+	h.Push(task{"high", 10})
+	h.Push(task{"mid", 5})
+
+	for _, want := range []string{"high", "mid", "low"} {
+		got, _ := h.Pop()
+		assert.Equal(t, want, got.name)
+	}
+}
+
+func TestHeapSort_MatchesStdlib(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	for _, size := range []int{0, 1, 2, 3, 10, 100, 1000} {
+		for trial := 0; trial < 5; trial++ {
+			arr := make([]int, size)
+			for i := range arr {
+				arr[i] = rng.Intn(50) - 25
+			}
+			expected := make([]int, size)
+			copy(expected, arr)
+			sort.Ints(expected)
+
+			sorting.HeapSort(arr)
+			require.Equal(t, expected, arr, "size %d trial %d", size, trial)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package sorting
 import (
 	"math/rand"
 	"time"
+
+	"algorithm-visualization/algorithms/heap"
 )
 
 // BubbleSort implements bubble sort algorithm
@@ -134,36 +136,10 @@ func partition(arr []int, low, high int) int {
 
 // HeapSort implements heap sort algorithm
 func HeapSort(arr []int) {
-	n := len(arr)
-
-	// Build heap
-	for i := n/2 - 1; i >= 0; i-- {
-		heapify(arr, n, i)
-	}
-
-	// Extract elements from heap one by one
-	for i := n - 1; i > 0; i-- {
-		arr[0], arr[i] = arr[i], arr[0]
-		heapify(arr, i, 0)
-	}
-}
-
-func heapify(arr []int, n, i int) {
-	largest := i
-	left := 2*i + 1
-	right := 2*i + 2
-
-	if left < n && arr[left] > arr[largest] {
-		largest = left
-	}
-
-	if right < n && arr[right] > arr[largest] {
-		largest = right
-	}
-
-	if largest != i {
-		arr[i], arr[largest] = arr[largest], arr[i]
-		heapify(arr, n, largest)
+	// Each pop moves the largest remaining item to the end of arr
+	h := heap.From(arr, func(a, b int) bool { return a > b })
+	for h.Len() > 0 {
+		h.Pop()
 	}
 }
 
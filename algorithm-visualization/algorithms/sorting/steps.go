@@ -0,0 +1,169 @@
+package sorting
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StepType identifies the operation a recorded step performed
+type StepType string
+
+const (
+	// StepCompare compares the elements at I and J
+	StepCompare StepType = "compare"
+	// StepSwap swaps the elements at I and J
+	StepSwap StepType = "swap"
+	// StepSet writes Value to index I
+	StepSet StepType = "set"
+)
+
+// Step is one operation of a sort, in the order it happened. Replaying the
+// swap and set steps over the input yields the sorted array. J is unused
+// by set steps and Value by the others.
+type Step struct {
+	Type  StepType `json:"type"`
+	I     int      `json:"i"`
+	J     int      `json:"j"`
+	Value int      `json:"value"`
+}
+
+// recorder collects steps while sorting arr in place
+type recorder struct {
+	arr   []int
+	steps []Step
+}
+
+// less records a comparison and reports whether arr[i] < arr[j]
+func (r *recorder) less(i, j int) bool {
+	r.steps = append(r.steps, Step{Type: StepCompare, I: i, J: j})
+	return r.arr[i] < r.arr[j]
+}
+
+func (r *recorder) swap(i, j int) {
+	if i == j {
+		return
+	}
+	r.steps = append(r.steps, Step{Type: StepSwap, I: i, J: j})
+	r.arr[i], r.arr[j] = r.arr[j], r.arr[i]
+}
+
+func (r *recorder) set(i, value int) {
+	r.steps = append(r.steps, Step{Type: StepSet, I: i, Value: value})
+	r.arr[i] = value
+}
+
+var stepSorters = map[string]func(*recorder){
+	"bubble":    bubbleSortSteps,
+	"insertion": insertionSortSteps,
+	"merge":     mergeSortSteps,
+	"quick":     quickSortSteps,
+}
+
+// StepAlgorithms lists the algorithms SortWithSteps supports
+func StepAlgorithms() []string {
+	names := make([]string, 0, len(stepSorters))
+	for name := range stepSorters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SortWithSteps sorts arr in place with the named algorithm and returns
+// every compare, swap and write it performed
+func SortWithSteps(algo string, arr []int) ([]Step, error) {
+	sorter, ok := stepSorters[algo]
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %q, expected one of %v", algo, StepAlgorithms())
+	}
+
+	r := &recorder{arr: arr, steps: make([]Step, 0)}
+	sorter(r)
+	return r.steps, nil
+}
+
+func bubbleSortSteps(r *recorder) {
+	n := len(r.arr)
+	for i := 0; i < n-1; i++ {
+		swapped := false
+		for j := 0; j < n-i-1; j++ {
+			if r.less(j+1, j) {
+				r.swap(j, j+1)
+				swapped = true
+			}
+		}
+		if !swapped {
+			break
+		}
+	}
+}
+
+func insertionSortSteps(r *recorder) {
+	for i := 1; i < len(r.arr); i++ {
+		for j := i; j > 0 && r.less(j, j-1); j-- {
+			r.swap(j, j-1)
+		}
+	}
+}
+
+func mergeSortSteps(r *recorder) {
+	if len(r.arr) > 1 {
+		mergeSortStepsHelper(r, 0, len(r.arr)-1)
+	}
+}
+
+func mergeSortStepsHelper(r *recorder, left, right int) {
+	if left >= right {
+		return
+	}
+	mid := left + (right-left)/2
+	mergeSortStepsHelper(r, left, mid)
+	mergeSortStepsHelper(r, mid+1, right)
+
+	// Compare against the original positions of both halves, then write
+	// the merged run back
+	merged := make([]int, 0, right-left+1)
+	i, j := left, mid+1
+	for i <= mid && j <= right {
+		if r.less(j, i) {
+			merged = append(merged, r.arr[j])
+			j++
+		} else {
+			merged = append(merged, r.arr[i])
+			i++
+		}
+	}
+	merged = append(merged, r.arr[i:mid+1]...)
+	merged = append(merged, r.arr[j:right+1]...)
+
+	for k, v := range merged {
+		if r.arr[left+k] != v {
+			r.set(left+k, v)
+		}
+	}
+}
+
+func quickSortSteps(r *recorder) {
+	if len(r.arr) > 1 {
+		quickSortStepsHelper(r, 0, len(r.arr)-1)
+	}
+}
+
+func quickSortStepsHelper(r *recorder, low, high int) {
+	if low >= high {
+		return
+	}
+
+	// Lomuto partition around arr[high], as in partition
+	i := low - 1
+	for j := low; j < high; j++ {
+		if r.less(j, high) {
+			i++
+			r.swap(i, j)
+		}
+	}
+	r.swap(i+1, high)
+
+	quickSortStepsHelper(r, low, i)
+	quickSortStepsHelper(r, i+2, high)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"algorithm-visualization/algorithms/search"
+	"algorithm-visualization/algorithms/sorting"
+)
+
+// defaultBenchmarkSizes are the array sizes --benchmark uses unless
+// --sizes is given
+const defaultBenchmarkSizes = "100,1000,5000"
+
+var sortBenchmarks = map[string]func([]int){
+	"bubble":    sorting.BubbleSort,
+	"selection": sorting.SelectionSort,
+	"insertion": sorting.InsertionSort,
+	"merge":     sorting.MergeSort,
+	"quick":     sorting.QuickSort,
+	"heap":      sorting.HeapSort,
+	"radix":     sorting.RadixSort,
+	"counting":  sorting.CountingSort,
+	"bucket":    sorting.BucketSort,
+	"shell":     sorting.ShellSort,
+	"tim":       sorting.TimSort,
+}
+
+var searchBenchmarks = map[string]func([]int, int) int{
+	"linear":        search.LinearSearch,
+	"binary":        search.BinarySearch,
+	"ternary":       search.TernarySearch,
+	"jump":          search.JumpSearch,
+	"interpolation": search.InterpolationSearch,
+	"exponential":   search.ExponentialSearch,
+	"fibonacci":     search.FibonacciSearch,
+}
+
+// Sorts run on every generator. Searches need sorted input without
+// duplicates (InterpolationSearch divides by arr[right]-arr[left]), so they
+// only run on the sorted one.
+var sortInputs = map[string]func(int) []int{
+	"random":   sorting.GenerateRandomArray,
+	"sorted":   sorting.GenerateSortedArray,
+	"reversed": sorting.GenerateReverseSortedArray,
+}
+
+var searchInputs = map[string]func(int) []int{
+	"sorted": sorting.GenerateSortedArray,
+}
+
+// benchmarkResult is one algorithm's time on one input. Rank orders the
+// algorithms sharing its kind, input and size, fastest first.
+type benchmarkResult struct {
+	Kind      string        `json:"kind"`
+	Algorithm string        `json:"algorithm"`
+	Input     string        `json:"input"`
+	Size      int           `json:"size"`
+	Duration  time.Duration `json:"-"`
+	Nanos     int64         `json:"ns"`
+	Rank      int           `json:"rank"`
+}
+
+type benchmarkReport struct {
+	Sizes   []int             `json:"sizes"`
+	Results []benchmarkResult `json:"results"`
+}
+
+// runBenchmarks times every sorting algorithm on every generator, and every
+// search algorithm looking up each element of a sorted array, at each size.
+// An algorithm producing a wrong answer fails the run.
+func runBenchmarks(sizes []int) (*benchmarkReport, error) {
+	report := &benchmarkReport{Sizes: sizes}
+
+	for _, size := range sizes {
+		for _, input := range sortedKeys(sortInputs) {
+			data := sortInputs[input](size)
+			for _, algo := range sortedKeys(sortBenchmarks) {
+				arr := make([]int, len(data))
+				copy(arr, data)
+
+				start := time.Now()
+				sortBenchmarks[algo](arr)
+				elapsed := time.Since(start)
+
+				if !sorting.IsSorted(arr) {
+					return nil, fmt.Errorf("%s sort left %s input of size %d unsorted", algo, input, size)
+				}
+				report.add("sort", algo, input, size, elapsed)
+			}
+		}
+
+		for _, input := range sortedKeys(searchInputs) {
+			data := searchInputs[input](size)
+			for _, algo := range sortedKeys(searchBenchmarks) {
+				start := time.Now()
+				for _, target := range data {
+					if i := searchBenchmarks[algo](data, target); i < 0 || data[i] != target {
+						return nil, fmt.Errorf("%s search missed %d in %s input of size %d", algo, target, input, size)
+					}
+				}
+				elapsed := time.Since(start)
+
+				report.add("search", algo, input, size, elapsed)
+			}
+		}
+	}
+
+	report.rank()
+	return report, nil
+}
+
+// benchmark runs the comparison for --benchmark, prints the table to out
+// and writes the report to opts.output if set
+func benchmark(opts options, out io.Writer) error {
+	report, err := runBenchmarks(opts.sizes)
+	if err != nil {
+		return err
+	}
+	if err := report.writeTable(out); err != nil {
+		return err
+	}
+	if opts.output == "" {
+		return nil
+	}
+
+	write, err := reportFormat(opts.output)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return err
+	}
+	if err := write(report, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *benchmarkReport) add(kind, algo, input string, size int, elapsed time.Duration) {
+	r.Results = append(r.Results, benchmarkResult{
+		Kind:      kind,
+		Algorithm: algo,
+		Input:     input,
+		Size:      size,
+		Duration:  elapsed,
+		Nanos:     elapsed.Nanoseconds(),
+	})
+}
+
+// rank sorts the results into groups by kind, size and input, fastest first
+// within each group, and numbers them
+func (r *benchmarkReport) rank() {
+	sort.SliceStable(r.Results, func(i, j int) bool {
+		a, b := r.Results[i], r.Results[j]
+		if a.Kind != b.Kind {
+			return a.Kind > b.Kind // sort before search
+		}
+		if a.Size != b.Size {
+			return a.Size < b.Size
+		}
+		if a.Input != b.Input {
+			return a.Input < b.Input
+		}
+		return a.Duration < b.Duration
+	})
+
+	for i := range r.Results {
+		r.Results[i].Rank = 1
+		if i > 0 {
+			prev := r.Results[i-1]
+			cur := r.Results[i]
+			if prev.Kind == cur.Kind && prev.Size == cur.Size && prev.Input == cur.Input {
+				r.Results[i].Rank = prev.Rank + 1
+			}
+		}
+	}
+}
+
+// writeTable prints the report as an aligned table
+func (r *benchmarkReport) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tSIZE\tINPUT\tRANK\tALGORITHM\tTIME")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\n", res.Kind, res.Size, res.Input, res.Rank, res.Algorithm, res.Duration)
+	}
+	return tw.Flush()
+}
+
+func (r *benchmarkReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *benchmarkReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "size", "input", "rank", "algorithm", "ns"})
+	for _, res := range r.Results {
+		cw.Write([]string{
+			res.Kind,
+			strconv.Itoa(res.Size),
+			res.Input,
+			strconv.Itoa(res.Rank),
+			res.Algorithm,
+			strconv.FormatInt(res.Nanos, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// reportFormat picks the report writer from the output file's extension
+func reportFormat(path string) (func(*benchmarkReport, io.Writer) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return (*benchmarkReport).writeJSON, nil
+	case ".csv":
+		return (*benchmarkReport).writeCSV, nil
+	}
+	return nil, fmt.Errorf("report %q must end in .json or .csv", path)
+}
+
+// parseSizes parses a comma separated list of positive array sizes
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, part := range strings.Split(s, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid size %q", part)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
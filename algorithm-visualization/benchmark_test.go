@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBenchmarks_CoversEveryAlgorithm(t *testing.T) {
+	sizes := []int{10, 50}
+	report, err := runBenchmarks(sizes)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	seen := make(map[string]int)
+	for _, res := range report.Results {
+		if res.Duration <= 0 || res.Nanos <= 0 {
+			t.Errorf("Expected a positive time for %+v", res)
+		}
+		seen[res.Kind+"/"+res.Algorithm]++
+	}
+
+	for algo := range sortBenchmarks {
+		if want := len(sizes) * len(sortInputs); seen["sort/"+algo] != want {
+			t.Errorf("Expected %d results for sort %s, got %d", want, algo, seen["sort/"+algo])
+		}
+	}
+	for algo := range searchBenchmarks {
+		if want := len(sizes) * len(searchInputs); seen["search/"+algo] != want {
+			t.Errorf("Expected %d results for search %s, got %d", want, algo, seen["search/"+algo])
+		}
+	}
+}
+
+func TestBenchmarkReport_Ranking(t *testing.T) {
+	report, err := runBenchmarks([]int{20})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i := 1; i < len(report.Results); i++ {
+		prev, cur := report.Results[i-1], report.Results[i]
+		if prev.Kind != cur.Kind || prev.Input != cur.Input {
+			if cur.Rank != 1 {
+				t.Errorf("Expected a new group to start at rank 1, got %+v", cur)
+			}
+			continue
+		}
+		if cur.Rank != prev.Rank+1 || cur.Duration < prev.Duration {
+			t.Errorf("Expected %+v to rank right after %+v", cur, prev)
+		}
+	}
+}
+
+func TestBenchmark_WritesReports(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(dir, "report.json")
+		var table bytes.Buffer
+		if err := benchmark(options{sizes: []int{10}, output: path}, &table); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(table.String(), "ALGORITHM") || !strings.Contains(table.String(), "quick") {
+			t.Errorf("Expected a table on stdout, got %q", table.String())
+		}
+
+		data, _ := os.ReadFile(path)
+		var report benchmarkReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatalf("Expected valid JSON, got %v", err)
+		}
+		if want := len(sortBenchmarks)*len(sortInputs) + len(searchBenchmarks)*len(searchInputs); len(report.Results) != want {
+			t.Errorf("Expected %d results, got %d", want, len(report.Results))
+		}
+		for _, res := range report.Results {
+			if res.Nanos <= 0 {
+				t.Errorf("Expected a positive time for %+v", res)
+			}
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		path := filepath.Join(dir, "report.csv")
+		if err := benchmark(options{sizes: []int{10}, output: path}, &bytes.Buffer{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		f, _ := os.Open(path)
+		defer f.Close()
+		rows, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			t.Fatalf("Expected valid CSV, got %v", err)
+		}
+		if rows[0][0] != "kind" || len(rows) != 1+len(sortBenchmarks)*len(sortInputs)+len(searchBenchmarks)*len(searchInputs) {
+			t.Errorf("Unexpected CSV rows %v", rows)
+		}
+	})
+}
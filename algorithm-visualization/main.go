@@ -1,10 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
 
 	"algorithm-visualization/algorithms/collision"
+	"algorithm-visualization/algorithms/graph"
 	"algorithm-visualization/algorithms/unionfind"
 	"algorithm-visualization/algorithms/sorting"
 	"algorithm-visualization/algorithms/search"
@@ -14,11 +19,31 @@ import (
 const Version = "0.1.0"
 
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "--version" {
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
+	if opts.version {
 		fmt.Printf("Algorithm Visualization v%s\n", Version)
 		return
 	}
 
+	if opts.benchmark {
+		if err := benchmark(opts, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if opts.serve {
+		log.Printf("Algorithm Visualization v%s serving on %s", Version, opts.addr)
+		log.Fatal(http.ListenAndServe(opts.addr, newServer()))
+	}
+
 	fmt.Println("🚀 Algorithm Visualization Project")
 	fmt.Printf("Version: %s\n", Version)
 	fmt.Println("=====================================")
@@ -28,10 +53,53 @@ func main() {
 	demonstrateUnionFind()
 	demonstrateSorting()
 	demonstrateSearch()
+	demonstrateGraph()
 
 	fmt.Println("\n✅ All algorithms demonstrated successfully!")
 }
 
+// options holds the command line flags
+type options struct {
+	version   bool
+	serve     bool
+	addr      string
+	benchmark bool
+	sizes     []int
+	output    string
+}
+
+// parseFlags parses args (without the program name), reporting errors and
+// usage to output
+func parseFlags(args []string, output io.Writer) (options, error) {
+	var opts options
+
+	fs := flag.NewFlagSet("algorithm-visualization", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.version, "version", false, "print the version and exit")
+	fs.BoolVar(&opts.serve, "serve", false, "start the visualization HTTP server")
+	fs.StringVar(&opts.addr, "addr", ":8080", "address the server listens on")
+	fs.BoolVar(&opts.benchmark, "benchmark", false, "time every sorting and search algorithm and print a ranked comparison")
+	sizes := fs.String("sizes", defaultBenchmarkSizes, "comma separated array sizes for --benchmark")
+	fs.StringVar(&opts.output, "output", "", "also write the --benchmark report to this .json or .csv file")
+
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+
+	var err error
+	if fs.NArg() > 0 {
+		err = fmt.Errorf("unexpected arguments: %v", fs.Args())
+	} else if opts.sizes, err = parseSizes(*sizes); err == nil && opts.output != "" {
+		_, err = reportFormat(opts.output)
+	}
+	if err != nil {
+		fmt.Fprintln(output, err)
+		fs.Usage()
+		return options{}, err
+	}
+	return opts, nil
+}
+
 func demonstrateCollisionDetection() {
 	fmt.Println("\n📦 Collision Detection Algorithms:")
 	
@@ -111,4 +179,29 @@ func demonstrateSearch() {
 	// Binary Search
 	binaryIndex := search.BinarySearch(arr, target)
 	fmt.Printf("  Binary Search found %d at index: %d\n", target, binaryIndex)
-}
\ No newline at end of file
+}
+
+func demonstrateGraph() {
+	fmt.Println("\n🗺️  Graph Algorithms:")
+
+	g := graph.NewGraph(6)
+	g.AddEdge(0, 1, 7)
+	g.AddEdge(0, 2, 9)
+	g.AddEdge(0, 5, 14)
+	g.AddEdge(1, 2, 10)
+	g.AddEdge(2, 5, 2)
+	g.AddEdge(3, 4, 6)
+
+	fmt.Printf("  BFS from 0: %v\n", g.BFS(0))
+	fmt.Printf("  DFS from 0: %v\n", g.DFS(0))
+
+	// Dijkstra shortest path
+	if path, dist, ok := g.ShortestPath(0, 5); ok {
+		fmt.Printf("  Shortest path 0 -> 5: %v (distance %g)\n", path, dist)
+	}
+	if _, _, ok := g.ShortestPath(0, 4); !ok {
+		fmt.Println("  ❌ No path from 0 to 4")
+	}
+
+	fmt.Printf("  Connected components: %v\n", g.ConnectedComponents())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		opts, err := parseFlags(nil, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if opts.serve || opts.version || opts.benchmark || opts.addr != ":8080" {
+			t.Errorf("Unexpected defaults: %+v", opts)
+		}
+	})
+
+	t.Run("serve", func(t *testing.T) {
+		opts, err := parseFlags([]string{"--serve", "--addr", ":9090"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !opts.serve || opts.addr != ":9090" {
+			t.Errorf("Expected serve on :9090, got %+v", opts)
+		}
+	})
+
+	t.Run("benchmark", func(t *testing.T) {
+		opts, err := parseFlags([]string{"--benchmark", "--sizes", "10, 20", "--output", "report.json"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !opts.benchmark || !reflect.DeepEqual(opts.sizes, []int{10, 20}) || opts.output != "report.json" {
+			t.Errorf("Unexpected benchmark options %+v", opts)
+		}
+
+		opts, _ = parseFlags([]string{"--benchmark"}, io.Discard)
+		if !reflect.DeepEqual(opts.sizes, []int{100, 1000, 5000}) {
+			t.Errorf("Expected the default sizes, got %v", opts.sizes)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		opts, err := parseFlags([]string{"--version"}, io.Discard)
+		if err != nil || !opts.version {
+			t.Errorf("Expected version flag, got %+v, %v", opts, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := parseFlags([]string{"--bogus"}, io.Discard); err == nil {
+			t.Error("Expected an error for an unknown flag")
+		}
+		if _, err := parseFlags([]string{"serve"}, io.Discard); err == nil {
+			t.Error("Expected an error for a positional argument")
+		}
+		if _, err := parseFlags([]string{"--benchmark", "--sizes", "10,0"}, io.Discard); err == nil {
+			t.Error("Expected an error for a zero size")
+		}
+		if _, err := parseFlags([]string{"--benchmark", "--output", "report.txt"}, io.Discard); err == nil {
+			t.Error("Expected an error for an unsupported report format")
+		}
+	})
+}
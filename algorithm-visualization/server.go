@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"algorithm-visualization/algorithms/search"
+	"algorithm-visualization/algorithms/sorting"
+	"algorithm-visualization/algorithms/unionfind"
+)
+
+// maxDataLen bounds the arrays and union-find sizes the server accepts
+const maxDataLen = 1000
+
+// newServer returns the handler behind --serve
+func newServer() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sort", handleSort)
+	mux.HandleFunc("/search", handleSearch)
+	mux.HandleFunc("/unionfind", handleUnionFind)
+	return mux
+}
+
+type sortResponse struct {
+	Algorithm string         `json:"algorithm"`
+	Input     []int          `json:"input"`
+	Result    []int          `json:"result"`
+	Steps     []sorting.Step `json:"steps"`
+}
+
+// handleSort handles GET /sort?algo=quick&data=3,1,2
+func handleSort(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	algo := r.URL.Query().Get("algo")
+	if algo == "" {
+		algo = "quick"
+	}
+	data, err := parseInts(r.URL.Query().Get("data"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := make([]int, len(data))
+	copy(result, data)
+	steps, err := sorting.SortWithSteps(algo, result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, sortResponse{Algorithm: algo, Input: data, Result: result, Steps: steps})
+}
+
+type searchResponse struct {
+	Algorithm string `json:"algorithm"`
+	Data      []int  `json:"data"`
+	Target    int    `json:"target"`
+	Index     int    `json:"index"`
+	Found     bool   `json:"found"`
+}
+
+var searchers = map[string]func([]int, int) int{
+	"linear": search.LinearSearch,
+	"binary": search.BinarySearch,
+}
+
+// handleSearch handles GET /search?algo=binary&data=1,3,5&target=3
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	algo := r.URL.Query().Get("algo")
+	if algo == "" {
+		algo = "binary"
+	}
+	searcher, ok := searchers[algo]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown algorithm %q, expected linear or binary", algo), http.StatusBadRequest)
+		return
+	}
+
+	data, err := parseInts(r.URL.Query().Get("data"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if algo == "binary" && !sorting.IsSorted(data) {
+		http.Error(w, "binary search needs sorted data", http.StatusBadRequest)
+		return
+	}
+	target, err := strconv.Atoi(r.URL.Query().Get("target"))
+	if err != nil {
+		http.Error(w, "target must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	index := searcher(data, target)
+	writeJSON(w, searchResponse{Algorithm: algo, Data: data, Target: target, Index: index, Found: index >= 0})
+}
+
+type connection struct {
+	P         int  `json:"p"`
+	Q         int  `json:"q"`
+	Connected bool `json:"connected"`
+}
+
+type unionFindResponse struct {
+	N           int          `json:"n"`
+	Count       int          `json:"count"`
+	Components  [][]int      `json:"components"`
+	Connections []connection `json:"connections"`
+}
+
+// handleUnionFind handles GET /unionfind?n=10&unions=0-1,2-3&check=0-3,1-4.
+// It applies the unions in order and reports the resulting components and
+// whether each checked pair is connected.
+func handleUnionFind(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n < 1 || n > maxDataLen {
+		http.Error(w, fmt.Sprintf("n must be between 1 and %d", maxDataLen), http.StatusBadRequest)
+		return
+	}
+	unions, err := parsePairs(r.URL.Query().Get("unions"), n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checks, err := parsePairs(r.URL.Query().Get("check"), n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uf := unionfind.NewWeightedQuickUnionWithPathCompression(n)
+	for _, pair := range unions {
+		uf.Union(pair[0], pair[1])
+	}
+
+	// Components in order of their smallest element, so output is stable
+	components := make([][]int, 0, uf.Count())
+	seen := make(map[int]bool)
+	all := uf.GetAllComponents()
+	for i := 0; i < n; i++ {
+		root := uf.Find(i)
+		if !seen[root] {
+			seen[root] = true
+			components = append(components, all[root])
+		}
+	}
+
+	connections := make([]connection, 0, len(checks))
+	for _, pair := range checks {
+		connections = append(connections, connection{P: pair[0], Q: pair[1], Connected: uf.Connected(pair[0], pair[1])})
+	}
+
+	writeJSON(w, unionFindResponse{N: n, Count: uf.Count(), Components: components, Connections: connections})
+}
+
+// parseInts parses a comma separated list of integers
+func parseInts(s string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("data is required")
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) > maxDataLen {
+		return nil, fmt.Errorf("data has %d values, the limit is %d", len(parts), maxDataLen)
+	}
+
+	values := make([]int, 0, len(parts))
+	for _, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q in data", part)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// parsePairs parses "p-q,p-q" with every element in [0, n). An empty string
+// is no pairs.
+func parsePairs(s string, n int) ([][2]int, error) {
+	var pairs [][2]int
+	if strings.TrimSpace(s) == "" {
+		return pairs, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		ps, qs, ok := strings.Cut(strings.TrimSpace(part), "-")
+		p, errP := strconv.Atoi(ps)
+		q, errQ := strconv.Atoi(qs)
+		if !ok || errP != nil || errQ != nil {
+			return nil, fmt.Errorf("invalid pair %q, expected p-q", part)
+		}
+		if p < 0 || p >= n || q < 0 || q >= n {
+			return nil, fmt.Errorf("pair %q is out of range for n=%d", part, n)
+		}
+		pairs = append(pairs, [2]int{p, q})
+	}
+	return pairs, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
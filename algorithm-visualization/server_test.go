@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"algorithm-visualization/algorithms/sorting"
+)
+
+func get(t *testing.T, target string, v interface{}) int {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	newServer().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code == http.StatusOK && v != nil {
+		if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+			t.Fatalf("Expected a JSON body, got %v", err)
+		}
+	}
+	return w.Code
+}
+
+func TestHandleSort_QuickSortSteps(t *testing.T) {
+	var resp sortResponse
+	if code := get(t, "/sort?algo=quick&data=3,1,2", &resp); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+
+	if !reflect.DeepEqual(resp.Input, []int{3, 1, 2}) {
+		t.Errorf("Expected the input echoed back, got %v", resp.Input)
+	}
+	if !reflect.DeepEqual(resp.Result, []int{1, 2, 3}) {
+		t.Errorf("Expected [1 2 3], got %v", resp.Result)
+	}
+
+	expected := []sorting.Step{
+		{Type: sorting.StepCompare, I: 0, J: 2},
+		{Type: sorting.StepCompare, I: 1, J: 2},
+		{Type: sorting.StepSwap, I: 0, J: 1},
+		{Type: sorting.StepSwap, I: 1, J: 2},
+	}
+	if !reflect.DeepEqual(resp.Steps, expected) {
+		t.Errorf("Expected steps %+v, got %+v", expected, resp.Steps)
+	}
+}
+
+func TestHandleSort_StepsReplayToResult(t *testing.T) {
+	data := []int{64, 34, 25, 12, 22, 11, 90, 25}
+
+	for _, algo := range sorting.StepAlgorithms() {
+		t.Run(algo, func(t *testing.T) {
+			var resp sortResponse
+			if code := get(t, "/sort?algo="+algo+"&data=64,34,25,12,22,11,90,25", &resp); code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", code)
+			}
+
+			replay := append([]int(nil), data...)
+			for _, step := range resp.Steps {
+				switch step.Type {
+				case sorting.StepSwap:
+					replay[step.I], replay[step.J] = replay[step.J], replay[step.I]
+				case sorting.StepSet:
+					replay[step.I] = step.Value
+				}
+			}
+
+			if !sorting.IsSorted(resp.Result) {
+				t.Errorf("Expected a sorted result, got %v", resp.Result)
+			}
+			if !reflect.DeepEqual(replay, resp.Result) {
+				t.Errorf("Expected replaying the steps to give %v, got %v", resp.Result, replay)
+			}
+		})
+	}
+}
+
+func TestHandleSort_BadRequests(t *testing.T) {
+	for _, target := range []string{
+		"/sort?algo=quick",
+		"/sort?algo=quick&data=1,x",
+		"/sort?algo=bogo&data=1,2",
+	} {
+		if code := get(t, target, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, code)
+		}
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	var resp searchResponse
+	if code := get(t, "/search?algo=binary&data=1,3,5,7,9&target=7", &resp); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if resp.Index != 3 || !resp.Found {
+		t.Errorf("Expected 7 at index 3, got %+v", resp)
+	}
+
+	if code := get(t, "/search?algo=linear&data=4,2,9&target=5", &resp); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if resp.Index != -1 || resp.Found {
+		t.Errorf("Expected 5 not to be found, got %+v", resp)
+	}
+
+	if code := get(t, "/search?algo=binary&data=4,2,9&target=2", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unsorted binary search data, got %d", code)
+	}
+}
+
+func TestHandleUnionFind(t *testing.T) {
+	var resp unionFindResponse
+	if code := get(t, "/unionfind?n=6&unions=0-1,2-3,0-2&check=1-3,1-4", &resp); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+
+	if resp.Count != 3 {
+		t.Errorf("Expected 3 components, got %d", resp.Count)
+	}
+	if !reflect.DeepEqual(resp.Components, [][]int{{0, 1, 2, 3}, {4}, {5}}) {
+		t.Errorf("Unexpected components %v", resp.Components)
+	}
+	expected := []connection{{P: 1, Q: 3, Connected: true}, {P: 1, Q: 4, Connected: false}}
+	if !reflect.DeepEqual(resp.Connections, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, resp.Connections)
+	}
+
+	if code := get(t, "/unionfind?n=3&unions=0-5", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an out of range pair, got %d", code)
+	}
+}
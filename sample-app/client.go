@@ -0,0 +1,208 @@
+/**
+# Copyright 2015 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the backend while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("backend circuit open")
+
+// ClientConfig configures a BackendClient
+type ClientConfig struct {
+	Timeout          time.Duration // per attempt
+	Retries          int           // extra attempts after a failure
+	RetryDelay       time.Duration // wait between attempts
+	FailureThreshold int           // failed calls in a row that open the circuit
+	OpenTimeout      time.Duration // how long the circuit stays open before a trial call
+}
+
+// DefaultClientConfig returns the configuration used by frontend mode
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:          2 * time.Second,
+		Retries:          2,
+		RetryDelay:       100 * time.Millisecond,
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+	}
+}
+
+// circuitState is the state of a BackendClient's circuit breaker
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// BackendClient calls the backend with a timeout on every attempt, retries
+// failed attempts and stops calling a backend that keeps failing. After
+// FailureThreshold failed calls in a row (connection errors, timeouts or 5xx
+// responses) the circuit opens and calls fail fast with ErrCircuitOpen. Once
+// OpenTimeout has passed a single trial call is let through: if it succeeds
+// the circuit closes again.
+type BackendClient struct {
+	client *http.Client
+	config ClientConfig
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// NewBackendClient creates a client with the given configuration
+func NewBackendClient(config ClientConfig) *BackendClient {
+	return &BackendClient{
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Get fetches url and returns the response body. Connection errors,
+// timeouts and 5xx responses are retried; a 4xx response is returned as an
+// error without retrying and, as the backend did answer, doesn't count as a
+// failure toward opening the circuit.
+func (c *BackendClient) Get(url string) ([]byte, error) {
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext is like Get, but stops retrying once ctx is done and passes
+// the trace in ctx, if any, to the backend
+func (c *BackendClient) GetContext(ctx context.Context, url string) ([]byte, error) {
+	if err := c.allow(); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	var err error
+	var retry bool
+	for attempt := 0; attempt <= c.config.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(c.config.RetryDelay):
+			case <-ctx.Done():
+				c.record(false)
+				return nil, ctx.Err()
+			}
+		}
+
+		body, retry, err = c.get(ctx, url)
+		if err == nil || !retry {
+			break
+		}
+	}
+
+	// Only failures worth retrying mean the backend is in trouble
+	c.record(err == nil || !retry)
+	return body, err
+}
+
+// get makes a single attempt and reports whether a failure is worth
+// retrying
+func (c *BackendClient) get(ctx context.Context, url string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if span, ok := SpanFromContext(ctx); ok {
+		req.Header.Set(TraceparentHeader, span.Traceparent())
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, true, fmt.Errorf("backend returned %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, false, fmt.Errorf("backend returned %d", resp.StatusCode)
+	}
+	return body, false, nil
+}
+
+// allow reports whether a call may go to the backend, moving an open
+// circuit to half-open once its timeout has passed
+func (c *BackendClient) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case circuitOpen:
+		if c.now().Sub(c.openedAt) < c.config.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		// Let one trial call through
+		c.state = circuitHalfOpen
+	case circuitHalfOpen:
+		// A trial call is already running
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record updates the circuit with the outcome of a call
+func (c *BackendClient) record(success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if success {
+		c.state = circuitClosed
+		c.failures = 0
+		return
+	}
+
+	c.failures++
+	if c.state == circuitHalfOpen || c.failures >= c.config.FailureThreshold {
+		c.state = circuitOpen
+		c.openedAt = c.now()
+	}
+}
+
+// RetryAfter returns how long until an open circuit lets a trial call
+// through, or zero if the circuit isn't open
+func (c *BackendClient) RetryAfter() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != circuitOpen {
+		return 0
+	}
+	if wait := c.config.OpenTimeout - c.now().Sub(c.openedAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+//go:build unit
+// +build unit
+
+/**
+# Copyright 2015 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:          50 * time.Millisecond,
+		Retries:          2,
+		RetryDelay:       time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	}
+}
+
+func instanceBackend(failures int64) (*httptest.Server, *int64) {
+	var calls int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"Name":"backend-1","Version":"1.0.0"}`))
+	}))
+	return backend, &calls
+}
+
+func TestBackendClient_TimeoutFailsFast(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	mux := newFrontendMux(backend.URL, NewBackendClient(testClientConfig()))
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the timeout to cut the request short, took %v", elapsed)
+	}
+}
+
+func TestBackendClient_RetrySucceeds(t *testing.T) {
+	backend, calls := instanceBackend(1)
+	defer backend.Close()
+
+	mux := newFrontendMux(backend.URL, NewBackendClient(testClientConfig()))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "backend-1") {
+		t.Errorf("Expected the retried call to render the backend, got %d %s", w.Code, w.Body.String())
+	}
+	if *calls != 2 {
+		t.Errorf("Expected one failed call and one retry, got %d calls", *calls)
+	}
+}
+
+func TestBackendClient_CircuitOpensAndRecovers(t *testing.T) {
+	backend, calls := instanceBackend(6) // two calls of three attempts each
+	defer backend.Close()
+
+	client := NewBackendClient(testClientConfig())
+	now := time.Now()
+	client.now = func() time.Time { return now }
+	mux := newFrontendMux(backend.URL, client)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Call %d: expected status 503, got %d", i+1, w.Code)
+		}
+	}
+
+	// The circuit is open: no call reaches the backend
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "not retrying") {
+		t.Errorf("Expected a fast 503 from the open circuit, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After while the circuit is open")
+	}
+	if *calls != 6 {
+		t.Errorf("Expected no backend calls while open, got %d", *calls)
+	}
+
+	// After the timeout a trial call closes the circuit again
+	now = now.Add(time.Minute)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the trial call to succeed, got %d", w.Code)
+	}
+	if client.RetryAfter() != 0 {
+		t.Error("Expected the circuit closed after a successful trial")
+	}
+}
+
+func TestBackendClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	if _, err := NewBackendClient(testClientConfig()).Get(backend.URL); err == nil {
+		t.Error("Expected an error for a 404")
+	}
+	if calls != 1 {
+		t.Errorf("Expected a 404 not to be retried, got %d calls", calls)
+	}
+}
+
+func TestBackendClient_ClientErrorsKeepCircuitClosed(t *testing.T) {
+	var calls int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	client := NewBackendClient(testClientConfig())
+	const requests = 5 // well past the threshold of 2
+	for i := 0; i < requests; i++ {
+		if _, err := client.Get(backend.URL); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Call %d: expected the 404 as an error, got %v", i+1, err)
+		}
+	}
+	if atomic.LoadInt64(&calls) != requests {
+		t.Errorf("Expected every call to reach the backend, got %d", calls)
+	}
+	if client.RetryAfter() != 0 {
+		t.Error("Expected 404s to leave the circuit closed")
+	}
+}
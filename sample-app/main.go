@@ -18,13 +18,18 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
-	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/compute/metadata"
 )
@@ -45,6 +50,9 @@ type Instance struct {
 
 const version string = "1.0.0"
 
+// logger writes the structured request log, tagged with trace IDs
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
 func main() {
 	showversion := flag.Bool("version", false, "display version")
 	frontend := flag.Bool("frontend", false, "run in frontend mode")
@@ -71,51 +79,106 @@ func main() {
 
 func backendMode(port int) {
 	log.Println("Operating in backend mode...")
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", port), Trace(logger, Instrument(globalMetrics, newBackendMux()))))
+}
+
+// newBackendMux returns the backend's handlers. The root handler describes
+// this instance as JSON, or as the frontend's HTML page when the Accept
+// header prefers HTML.
+func newBackendMux() *http.ServeMux {
+	tpl := template.Must(template.New("out").Parse(html))
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		i := newInstance()
 		raw, _ := httputil.DumpRequest(r, true)
 		i.LBRequest = string(raw)
+
+		w.Header().Add("Vary", "Accept")
+		if prefersHTML(r.Header.Get("Accept")) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			tpl.Execute(w, i)
+			return
+		}
+
 		resp, _ := json.Marshal(i)
+		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, "%s", resp)
 	})
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", port), mux))
+	mux.HandleFunc("/metrics", PrometheusHandler)
+	return mux
+}
 
+// prefersHTML reports whether an Accept header ranks text/html above
+// application/json. Ties, an empty header and headers naming neither
+// default to JSON.
+func prefersHTML(accept string) bool {
+	htmlQ := acceptQuality(accept, "text/html")
+	return htmlQ > 0 && htmlQ > acceptQuality(accept, "application/json")
+}
+
+// acceptQuality returns the q-value an Accept header gives mediaType,
+// taken from its most specific matching range, or 0 if no range matches
+func acceptQuality(accept, mediaType string) float64 {
+	typ := strings.SplitN(mediaType, "/", 2)[0]
+
+	quality, specificity := 0.0, 0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+
+		var rangeSpecificity int
+		switch mediaRange {
+		case mediaType:
+			rangeSpecificity = 3
+		case typ + "/*":
+			rangeSpecificity = 2
+		case "*/*":
+			rangeSpecificity = 1
+		default:
+			continue
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		quality, specificity = q, rangeSpecificity
+	}
+	return quality
 }
 
 func frontendMode(port int, backendURL string) {
 	log.Println("Operating in frontend mode...")
-	tpl := template.Must(template.New("out").Parse(html))
+	client := NewBackendClient(DefaultClientConfig())
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", port), Trace(logger, Instrument(globalMetrics, newFrontendMux(backendURL, client)))))
+}
 
-	transport := http.Transport{DisableKeepAlives: false}
-	client := &http.Client{Transport: &transport}
-	req, _ := http.NewRequest(
-		"GET",
-		backendURL,
-		nil,
-	)
-	req.Close = false
+// newFrontendMux returns the frontend's handlers, which render the instance
+// reported by the backend at backendURL
+func newFrontendMux(backendURL string, client *BackendClient) *http.ServeMux {
+	tpl := template.Must(template.New("out").Parse(html))
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		i := &Instance{}
-		resp, err := client.Do(req)
+		body, err := client.GetContext(r.Context(), backendURL)
 		if err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			fmt.Fprintf(w, "Error: %s\n", err.Error())
+			backendUnavailable(w, client, "Error", err)
 			return
 		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(w, "Error: %s\n", err.Error())
-			return
-		}
-		err = json.Unmarshal([]byte(body), i)
+		err = json.Unmarshal(body, i)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "Error: %s\n", err.Error())
@@ -125,17 +188,28 @@ func frontendMode(port int, backendURL string) {
 	})
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		resp, err := client.Do(req)
-		if err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			fmt.Fprintf(w, "Backend could not be connected to: %s", err.Error())
+		if _, err := client.GetContext(r.Context(), backendURL); err != nil {
+			backendUnavailable(w, client, "Backend could not be connected to", err)
 			return
 		}
-		defer resp.Body.Close()
-		ioutil.ReadAll(resp.Body)
 		w.WriteHeader(http.StatusOK)
 	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", port), mux))
+	mux.HandleFunc("/metrics", PrometheusHandler)
+	return mux
+}
+
+// backendUnavailable answers 503 for a failed backend call. While the
+// circuit is open it says so and sets Retry-After.
+func backendUnavailable(w http.ResponseWriter, client *BackendClient, prefix string, err error) {
+	if errors.Is(err, ErrCircuitOpen) {
+		wait := client.RetryAfter()
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "%s: backend is failing, not retrying for %s\n", prefix, wait.Round(time.Second))
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, "%s: %s\n", prefix, err.Error())
 }
 
 type assigner struct {
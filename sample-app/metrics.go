@@ -1,6 +1,3 @@
-//go:build !test
-// +build !test
-
 /**
 # Copyright 2015 Google Inc. All rights reserved.
 #
@@ -21,13 +18,28 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// DurationBuckets are the upper bounds, in seconds, of the request
+// duration histogram
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestLabels identifies a series of http_requests_total
+type requestLabels struct {
+	path string
+	code int
+}
+
 // Metrics represents application metrics
 type Metrics struct {
 	mu                sync.RWMutex
@@ -40,6 +52,9 @@ type Metrics struct {
 	StatusCodes       map[int]int64    `json:"status_codes"`
 	Endpoints         map[string]int64 `json:"endpoints"`
 	Errors            map[string]int64 `json:"errors"`
+
+	requests        map[requestLabels]int64
+	durationBuckets []int64 // cumulative counts per DurationBuckets bound
 }
 
 // NewMetrics creates a new metrics instance
@@ -49,6 +64,9 @@ func NewMetrics() *Metrics {
 		StatusCodes: make(map[int]int64),
 		Endpoints:   make(map[string]int64),
 		Errors:      make(map[string]int64),
+
+		requests:        make(map[requestLabels]int64),
+		durationBuckets: make([]int64, len(DurationBuckets)),
 	}
 }
 
@@ -67,6 +85,14 @@ func (m *Metrics) RecordRequest(endpoint string, statusCode int, responseTime ti
 
 	// Record endpoint
 	m.Endpoints[endpoint]++
+	m.requests[requestLabels{path: endpoint, code: statusCode}]++
+
+	// Record duration in every bucket it fits
+	for i, bound := range DurationBuckets {
+		if responseTime.Seconds() <= bound {
+			m.durationBuckets[i]++
+		}
+	}
 
 	// Record error if any
 	if err != nil {
@@ -75,7 +101,8 @@ func (m *Metrics) RecordRequest(endpoint string, statusCode int, responseTime ti
 	}
 }
 
-// GetMetrics returns current metrics
+// GetMetrics returns current metrics. The per status, endpoint and error
+// counts are copies, so callers can read them while requests are recorded.
 func (m *Metrics) GetMetrics() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -95,9 +122,9 @@ func (m *Metrics) GetMetrics() map[string]interface{} {
 		"uptime_seconds":       uptime.Seconds(),
 		"start_time":           m.StartTime.Format(time.RFC3339),
 		"last_request_time":    m.LastRequestTime.Format(time.RFC3339),
-		"status_codes":         m.StatusCodes,
-		"endpoints":            m.Endpoints,
-		"errors":               m.Errors,
+		"status_codes":         maps.Clone(m.StatusCodes),
+		"endpoints":            maps.Clone(m.Endpoints),
+		"errors":               maps.Clone(m.Errors),
 	}
 }
 
@@ -154,6 +181,77 @@ func (m *Metrics) Reset() {
 	m.StatusCodes = make(map[int]int64)
 	m.Endpoints = make(map[string]int64)
 	m.Errors = make(map[string]int64)
+	m.requests = make(map[requestLabels]int64)
+	m.durationBuckets = make([]int64, len(DurationBuckets))
+}
+
+// WritePrometheus writes the per path and status request counters and the
+// request duration histogram in the Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	labels := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].path != labels[j].path {
+			return labels[i].path < labels[j].path
+		}
+		return labels[i].code < labels[j].code
+	})
+
+	fmt.Fprintf(w, "# HELP http_requests_total Total number of requests by path and status code\n")
+	fmt.Fprintf(w, "# TYPE http_requests_total counter\n")
+	for _, l := range labels {
+		fmt.Fprintf(w, "http_requests_total{path=\"%s\",code=\"%d\"} %d\n", escapeLabel(l.path), l.code, m.requests[l])
+	}
+
+	fmt.Fprintf(w, "# HELP http_request_duration_seconds Request duration in seconds\n")
+	fmt.Fprintf(w, "# TYPE http_request_duration_seconds histogram\n")
+	for i, bound := range DurationBuckets {
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{le=\"%g\"} %d\n", bound, m.durationBuckets[i])
+	}
+	fmt.Fprintf(w, "http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.ResponseTimeCount)
+	fmt.Fprintf(w, "http_request_duration_seconds_sum %g\n", m.ResponseTimeSum.Seconds())
+	fmt.Fprintf(w, "http_request_duration_seconds_count %d\n", m.ResponseTimeCount)
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// statusRecorder captures the status code a handler writes
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Instrument records every request served by mux in m. Requests are
+// labelled with the mux pattern that handled them rather than the raw path
+// so unknown URLs don't create new series. Responses of 500 and above count
+// as errors.
+func Instrument(m *Metrics, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		start := time.Now()
+		mux.ServeHTTP(rec, r)
+
+		var err error
+		if rec.status >= http.StatusInternalServerError {
+			err = errors.New(http.StatusText(rec.status))
+		}
+		m.RecordRequest(pattern, rec.status, time.Since(start), err)
+	})
 }
 
 // Global metrics instance
@@ -255,4 +353,6 @@ func PrometheusHandler(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "endpoint_total{endpoint=\"%s\"} %d\n", endpoint, count)
 		}
 	}
+
+	globalMetrics.WritePrometheus(w)
 }
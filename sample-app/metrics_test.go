@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -307,3 +308,100 @@ func TestConcurrentAccess(t *testing.T) {
 		t.Errorf("Expected request count %d, got %d", concurrency, metrics.RequestCount)
 	}
 }
+
+// TestMetrics_ScrapeWhileRecording tests that scraping the metrics while
+// requests are recorded doesn't race; run it with -race
+func TestMetrics_ScrapeWhileRecording(t *testing.T) {
+	ResetMetrics()
+
+	const rounds = 200
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			// New keys grow the maps, the write a scrape must not see
+			RecordRequest(fmt.Sprintf("/path/%d", i), 200+i%5, time.Millisecond, fmt.Errorf("error %d", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			PrometheusHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			MetricsHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics.json", nil))
+		}
+	}()
+	wg.Wait()
+
+	if endpoints := GetMetrics()["endpoints"].(map[string]int64); len(endpoints) != rounds {
+		t.Errorf("Expected %d endpoints, got %d", rounds, len(endpoints))
+	}
+}
+
+// TestInstrument_ExposesRequestMetrics tests that instrumented requests show
+// up at /metrics labelled by path and status
+func TestInstrument_ExposesRequestMetrics(t *testing.T) {
+	ResetMetrics()
+	handler := Instrument(globalMetrics, newBackendMux())
+
+	requests := []string{"/", "/", "/healthz", "/unknown/path"}
+	for _, path := range requests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	// Unknown paths fall through to the "/" pattern
+	expected := []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{path="/",code="200"} 3`,
+		`http_requests_total{path="/healthz",code="200"} 1`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{le="0.005"} `,
+		`http_request_duration_seconds_bucket{le="10"} 4`,
+		`http_request_duration_seconds_bucket{le="+Inf"} 4`,
+		"http_request_duration_seconds_count 4",
+		"http_request_duration_seconds_sum ",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics output missing %q:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "/unknown/path") {
+		t.Errorf("metrics should not label requests by raw path:\n%s", body)
+	}
+}
+
+// TestInstrument_RecordsServerErrors tests that 5xx responses count as errors
+func TestInstrument_RecordsServerErrors(t *testing.T) {
+	metrics := NewMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	w := httptest.NewRecorder()
+	Instrument(metrics, mux).ServeHTTP(w, httptest.NewRequest("GET", "/fail", nil))
+
+	if metrics.ErrorCount != 1 {
+		t.Errorf("Expected 1 error, got %d", metrics.ErrorCount)
+	}
+
+	var out strings.Builder
+	metrics.WritePrometheus(&out)
+	if !strings.Contains(out.String(), `http_requests_total{path="/fail",code="503"} 1`) {
+		t.Errorf("Expected a 503 series for /fail:\n%s", out.String())
+	}
+}
@@ -0,0 +1,80 @@
+//go:build unit
+// +build unit
+
+/**
+# Copyright 2015 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBackendMux_ContentNegotiation(t *testing.T) {
+	mux := newBackendMux()
+
+	tests := []struct {
+		name     string
+		accept   string
+		wantHTML bool
+	}{
+		{"json", "application/json", false},
+		{"html", "text/html", true},
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"json preferred by q", "text/html;q=0.5, application/json", false},
+		{"no accept header", "", false},
+		{"unknown type", "application/xml", false},
+		{"wildcard", "*/*", false},
+		{"html refused", "text/html;q=0, */*", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Header().Get("Vary") != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", w.Header().Get("Vary"))
+			}
+
+			contentType := w.Header().Get("Content-Type")
+			if tt.wantHTML {
+				if !strings.HasPrefix(contentType, "text/html") {
+					t.Errorf("Expected HTML, got %q", contentType)
+				}
+				if body := w.Body.String(); !strings.Contains(body, "<!doctype html>") || !strings.Contains(body, version) {
+					t.Error("Expected the rendered instance template")
+				}
+				return
+			}
+
+			if contentType != "application/json" {
+				t.Errorf("Expected JSON, got %q", contentType)
+			}
+			var i Instance
+			if err := json.Unmarshal(w.Body.Bytes(), &i); err != nil || i.Version != version {
+				t.Errorf("Expected the JSON instance, got %v %+v", err, i)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/**
+# Copyright 2015 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TraceparentHeader carries the W3C trace context between services
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies one span of a W3C trace. IDs are lowercase hex:
+// 32 characters for the trace, 16 for spans.
+type SpanContext struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string // empty for the root span
+	Flags        string // two hex digits; "01" means sampled
+}
+
+// Traceparent formats the span as a traceparent header value, so the span
+// becomes the parent of whatever receives it
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a version 00 traceparent header. Malformed values
+// and the all-zero IDs the spec reserves as invalid are rejected.
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return SpanContext{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHexID(traceID, 32) || !isHexID(spanID, 16) || !isHex(flags, 2) {
+		return SpanContext{}, false
+	}
+	return SpanContext{TraceID: traceID, SpanID: spanID, Flags: flags}, true
+}
+
+// isHexID reports whether s is n lowercase hex digits and not all zero
+func isHexID(s string, n int) bool {
+	return isHex(s, n) && strings.Trim(s, "0") != ""
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newSpan starts a span that continues the trace in header, or a new
+// sampled trace when header is missing or invalid
+func newSpan(header string) SpanContext {
+	span := SpanContext{SpanID: randomHex(8)}
+	if parent, ok := ParseTraceparent(header); ok {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+		span.Flags = parent.Flags
+		return span
+	}
+	span.TraceID = randomHex(16)
+	span.Flags = "01"
+	return span
+}
+
+type spanContextKey struct{}
+
+// SpanFromContext returns the span stored by Trace, if any
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	span, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return span, ok
+}
+
+// Trace starts a span for every request, continuing the caller's trace if
+// the request carries a traceparent header. The span is stored in the
+// request context, so outgoing calls made with it propagate the trace, and
+// returned in the response's traceparent header. Each request is logged to
+// logger with its trace and span IDs.
+func Trace(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := newSpan(r.Header.Get(TraceparentHeader))
+		w.Header().Set(TraceparentHeader, span.Traceparent())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), spanContextKey{}, span)))
+
+		logger.Info("request",
+			"trace_id", span.TraceID,
+			"span_id", span.SpanID,
+			"parent_span_id", span.ParentSpanID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(start),
+		)
+	})
+}
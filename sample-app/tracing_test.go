@@ -0,0 +1,142 @@
+//go:build unit
+// +build unit
+
+/**
+# Copyright 2015 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer is a log sink shared by two test servers
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// entries decodes the JSON log lines written so far
+func (b *lockedBuffer) entries(t *testing.T) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestTrace_PropagatesFromFrontendToBackend(t *testing.T) {
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+
+	var received string
+	backend := httptest.NewServer(Trace(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TraceparentHeader)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Name":"backend"}`))
+	})))
+	defer backend.Close()
+
+	frontend := Trace(logger, newFrontendMux(backend.URL, NewBackendClient(DefaultClientConfig())))
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(TraceparentHeader, incoming)
+	w := httptest.NewRecorder()
+	frontend.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	frontendSpan, ok := ParseTraceparent(w.Header().Get(TraceparentHeader))
+	if !ok {
+		t.Fatalf("Expected a traceparent on the response, got %q", w.Header().Get(TraceparentHeader))
+	}
+	sent, ok := ParseTraceparent(received)
+	if !ok {
+		t.Fatalf("Expected the backend to receive a traceparent, got %q", received)
+	}
+	if sent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the backend to continue the trace, got %s", sent.TraceID)
+	}
+	if sent.SpanID != frontendSpan.SpanID {
+		t.Errorf("Expected the frontend's span %s as the backend's parent, got %s", frontendSpan.SpanID, sent.SpanID)
+	}
+
+	entries := logs.entries(t)
+	if len(entries) != 2 {
+		t.Fatalf("Expected a log line from each side, got %d", len(entries))
+	}
+	backendLog, frontendLog := entries[0], entries[1]
+	for _, entry := range entries {
+		if entry["trace_id"] != sent.TraceID {
+			t.Errorf("Expected every log line tagged with trace %s, got %v", sent.TraceID, entry)
+		}
+	}
+	if frontendLog["parent_span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("Expected the frontend span to be a child of the caller's, got %v", frontendLog)
+	}
+	if backendLog["parent_span_id"] != frontendSpan.SpanID {
+		t.Errorf("Expected the backend span to be a child of the frontend's, got %v", backendLog)
+	}
+	if backendLog["span_id"] == frontendSpan.SpanID || backendLog["span_id"] == "" {
+		t.Errorf("Expected the backend to start its own span, got %v", backendLog)
+	}
+}
+
+func TestTrace_StartsFreshTraceWithoutHeader(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(&lockedBuffer{}, nil))
+	var span SpanContext
+	handler := Trace(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span, _ = SpanFromContext(r.Context())
+	}))
+
+	for _, header := range []string{"", "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set(TraceparentHeader, header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !isHexID(span.TraceID, 32) || !isHexID(span.SpanID, 16) {
+			t.Errorf("header %q: expected fresh IDs, got %+v", header, span)
+		}
+		if span.ParentSpanID != "" || span.Flags != "01" {
+			t.Errorf("header %q: expected a sampled root span, got %+v", header, span)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"common/apierror"
+	"common/openapi"
+)
+
+// StatePath is where services mount the state handler
+const StatePath = "/admin/state"
+
+// maxStateBytes bounds the size of a restored snapshot
+const maxStateBytes = 64 << 20
+
+// Snapshotter is a service whose whole in-memory state can be dumped and
+// reloaded. Restore replaces the current state and rebuilds any indexes
+// derived from it; a failed Restore leaves the current state untouched.
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// StateHandler serves GET to dump s as JSON and PUT to restore it from the
+// request body. It does no authorization of its own, wrap it in
+// middleware.AdminFromEnv.
+func StateHandler(s Snapshotter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			data, err := s.Snapshot()
+			if err != nil {
+				apierror.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+
+		case http.MethodPut:
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStateBytes))
+			if err != nil {
+				apierror.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if !json.Valid(data) {
+				apierror.Error(w, "state must be JSON", http.StatusBadRequest)
+				return
+			}
+			if err := s.Restore(data); err != nil {
+				apierror.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// Routes documents the state handler for a service whose snapshot has the
+// shape of state
+func Routes(state interface{}) []openapi.Route {
+	return []openapi.Route{
+		{
+			Summary: "Dump the whole service state", Response: state,
+			Responses: map[int]string{200: "The state snapshot", 403: "Missing or invalid admin token"},
+		},
+		{
+			Method: http.MethodPut, Summary: "Replace the whole service state", Request: state,
+			Responses: map[int]string{204: "State restored", 400: "Invalid snapshot", 403: "Missing or invalid admin token", 413: "Snapshot too large"},
+		},
+	}
+}
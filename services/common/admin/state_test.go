@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeState struct {
+	data     string
+	restored string
+	fail     bool
+}
+
+func (f *fakeState) Snapshot() ([]byte, error) { return []byte(f.data), nil }
+
+func (f *fakeState) Restore(data []byte) error {
+	if f.fail {
+		return errors.New("bad state")
+	}
+	f.restored = string(data)
+	return nil
+}
+
+func TestStateHandler_Dump(t *testing.T) {
+	handler := StateHandler(&fakeState{data: `{"users":{}}`})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, StatePath, nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"users":{}}` {
+		t.Errorf("Expected the snapshot, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON, got %q", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestStateHandler_Restore(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		fail   bool
+		status int
+	}{
+		{"valid", http.MethodPut, `{"users":{}}`, false, http.StatusNoContent},
+		{"not json", http.MethodPut, `users`, false, http.StatusBadRequest},
+		{"rejected", http.MethodPut, `{}`, true, http.StatusBadRequest},
+		{"wrong method", http.MethodPost, `{}`, false, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &fakeState{fail: tt.fail}
+
+			w := httptest.NewRecorder()
+			StateHandler(state).ServeHTTP(w, httptest.NewRequest(tt.method, StatePath, strings.NewReader(tt.body)))
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusNoContent && state.restored != tt.body {
+				t.Errorf("Expected %q to be restored, got %q", tt.body, state.restored)
+			}
+		})
+	}
+}
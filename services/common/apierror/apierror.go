@@ -0,0 +1,85 @@
+// Package apierror writes errors as a consistent JSON envelope:
+//
+//	{"error": {"code": "not_found", "message": "question not found"}}
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// APIError is an error returned to a client. Status is the HTTP status
+// and isn't part of the body; Code is a stable, machine-readable name for
+// the kind of failure.
+type APIError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// envelope is the body written for an APIError
+type envelope struct {
+	Error APIError `json:"error"`
+}
+
+// New returns an APIError with the code for status
+func New(status int, message string) APIError {
+	return APIError{Status: status, Code: CodeFor(status), Message: message}
+}
+
+// WithDetails returns a copy of e carrying extra structured context, e.g.
+// which field failed validation
+func (e APIError) WithDetails(details interface{}) APIError {
+	e.Details = details
+	return e
+}
+
+// Error implements error
+func (e APIError) Error() string {
+	return e.Message
+}
+
+// CodeFor returns the error code for an HTTP status, e.g. "not_found" for
+// 404, derived from the status text for statuses without a dedicated code
+func CodeFor(status int) string {
+	switch status {
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusInternalServerError:
+		return "internal"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	text = strings.ToLower(strings.ReplaceAll(text, "-", ""))
+	return strings.Join(strings.Fields(text), "_")
+}
+
+// Write sends e as a JSON error envelope with e.Status
+func Write(w http.ResponseWriter, e APIError) {
+	if e.Status == 0 {
+		e.Status = http.StatusInternalServerError
+	}
+	if e.Code == "" {
+		e.Code = CodeFor(e.Status)
+	}
+
+	// Drop headers set for the success response, as http.Error does
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(envelope{Error: e})
+}
+
+// Error is a drop-in replacement for http.Error that writes the JSON
+// envelope instead of plain text
+func Error(w http.ResponseWriter, message string, status int) {
+	Write(w, New(status, message))
+}
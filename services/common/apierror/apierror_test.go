@@ -0,0 +1,74 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCodeFor(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusBadRequest, "bad_request"},
+		{http.StatusNotFound, "not_found"},
+		{http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.StatusRequestEntityTooLarge, "payload_too_large"},
+		{http.StatusUnprocessableEntity, "unprocessable_entity"},
+		{http.StatusTooManyRequests, "too_many_requests"},
+		{http.StatusInternalServerError, "internal"},
+		{http.StatusServiceUnavailable, "unavailable"},
+		{799, "error"},
+	}
+
+	for _, tt := range tests {
+		if got := CodeFor(tt.status); got != tt.want {
+			t.Errorf("CodeFor(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Length", "12")
+
+	Write(w, New(http.StatusUnprocessableEntity, "title is too long").WithDetails(map[string]string{"field": "title"}))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %s", ct)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Error("Expected a stale Content-Length to be dropped")
+	}
+
+	var body struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Message string            `json:"message"`
+			Details map[string]string `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Error.Code != "unprocessable_entity" || body.Error.Message != "title is too long" || body.Error.Details["field"] != "title" {
+		t.Errorf("Unexpected error body: %+v", body.Error)
+	}
+}
+
+func TestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != `{"error":{"code":"method_not_allowed","message":"Method not allowed"}}`+"\n" {
+		t.Errorf("Unexpected body %s", got)
+	}
+}
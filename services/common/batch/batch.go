@@ -0,0 +1,152 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"common/apierror"
+	"common/middleware"
+)
+
+const (
+	// Path is where services mount the batch handler
+	Path = "/batch"
+	// DefaultMaxRequests bounds the sub-requests in one batch
+	DefaultMaxRequests = 20
+
+	maxBodyBytes = 1 << 20
+)
+
+// Request is one sub-request. Path may include a query string; Method
+// defaults to GET.
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is the result of one sub-request. JSON bodies are embedded as
+// is, anything else is encoded as a JSON string.
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Handler runs a JSON array of sub-requests against target, in order and
+// in-process, and responds with the array of their responses. Each
+// sub-request carries the caller's headers, so authentication applies to
+// it exactly as it would to a direct call. A failing sub-request doesn't
+// stop the ones after it.
+func Handler(target http.Handler, maxRequests int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var requests []Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&requests); err != nil {
+			apierror.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(requests) == 0 {
+			apierror.Error(w, "batch is empty", http.StatusBadRequest)
+			return
+		}
+		if len(requests) > maxRequests {
+			apierror.Error(w, fmt.Sprintf("batch has %d requests, the limit is %d", len(requests), maxRequests), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		responses := make([]Response, 0, len(requests))
+		for _, sub := range requests {
+			responses = append(responses, serve(target, r, sub))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses)
+	})
+}
+
+// serve runs one sub-request and captures its response
+func serve(target http.Handler, parent *http.Request, sub Request) Response {
+	method := strings.ToUpper(sub.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	if !strings.HasPrefix(sub.Path, "/") {
+		return errorResponse(http.StatusBadRequest, "path must start with /")
+	}
+	if p, _, _ := strings.Cut(sub.Path, "?"); p == Path {
+		return errorResponse(http.StatusBadRequest, "batches can't be nested")
+	}
+
+	req, err := http.NewRequestWithContext(parent.Context(), method, sub.Path, bytes.NewReader(sub.Body))
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	req.Header = parent.Header.Clone()
+	req.Header.Del("Content-Length")
+	req.Header.Del("Accept-Encoding")
+	// The key identifies the whole batch, not each sub-request
+	req.Header.Del(middleware.IdempotencyKeyHeader)
+	if len(sub.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.RemoteAddr = parent.RemoteAddr
+	req.Host = parent.Host
+
+	rec := &recorder{header: make(http.Header)}
+	target.ServeHTTP(rec, req)
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return Response{Status: status, Body: encodeBody(rec.body.Bytes())}
+}
+
+func errorResponse(status int, message string) Response {
+	return Response{Status: status, Body: encodeBody([]byte(message))}
+}
+
+// encodeBody embeds JSON bodies and quotes anything else
+func encodeBody(body []byte) json.RawMessage {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	if json.Valid(trimmed) {
+		return trimmed
+	}
+	quoted, _ := json.Marshal(string(trimmed))
+	return quoted
+}
+
+// recorder captures a sub-response in memory
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(p)
+}
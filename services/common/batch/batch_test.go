@@ -0,0 +1,84 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"method":%q,"q":%q,"auth":%q}`, r.Method, r.URL.Query().Get("q"), r.Header.Get("Authorization"))
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	mux.Handle(Path, Handler(mux, 3))
+	return mux
+}
+
+func runBatch(t *testing.T, mux http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_RunsSubRequests(t *testing.T) {
+	w := runBatch(t, newTestMux(), `[
+		{"path": "/echo?q=1"},
+		{"method": "post", "path": "/echo", "body": {"a": 1}},
+		{"path": "/text"}
+	]`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var responses []Response
+	if err := json.NewDecoder(w.Body).Decode(&responses); err != nil {
+		t.Fatalf("Expected a JSON array, got %v", err)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(responses))
+	}
+
+	if responses[0].Status != http.StatusOK || string(responses[0].Body) != `{"method":"GET","q":"1","auth":"Bearer token"}` {
+		t.Errorf("Unexpected first response %d %s", responses[0].Status, responses[0].Body)
+	}
+	if !bytes.Contains(responses[1].Body, []byte(`"method":"POST"`)) {
+		t.Errorf("Expected method to be uppercased, got %s", responses[1].Body)
+	}
+	if responses[2].Status != http.StatusNotFound || string(responses[2].Body) != `"not found"` {
+		t.Errorf("Expected a quoted text body, got %d %s", responses[2].Status, responses[2].Body)
+	}
+}
+
+func TestHandler_Limits(t *testing.T) {
+	mux := newTestMux()
+
+	w := runBatch(t, mux, `[{"path":"/echo"},{"path":"/echo"},{"path":"/echo"},{"path":"/echo"}]`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 over the limit, got %d", w.Code)
+	}
+
+	w = runBatch(t, mux, `[]`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty batch, got %d", w.Code)
+	}
+
+	w = runBatch(t, mux, `[{"path":"/batch"},{"path":"echo"}]`)
+	var responses []Response
+	json.NewDecoder(w.Body).Decode(&responses)
+	if len(responses) != 2 || responses[0].Status != http.StatusBadRequest || responses[1].Status != http.StatusBadRequest {
+		t.Errorf("Expected nested and relative paths to be rejected, got %+v", responses)
+	}
+}
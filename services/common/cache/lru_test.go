@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestLRU(maxEntries int) (*TTLCache[string, int], *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := NewLRUCache[string, int](time.Minute, maxEntries)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestLRU(3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Set("d", 4)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a, the least recently used key, to be evicted")
+	}
+	for _, key := range []string{"b", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Expected %s to still be cached", key)
+		}
+	}
+
+	m := c.Metrics()
+	if m.Evictions != 1 || m.Size != 3 {
+		t.Errorf("Expected 1 eviction and size 3, got %+v", m)
+	}
+}
+
+func TestLRUCache_GetPromotesRecency(t *testing.T) {
+	c, _ := newTestLRU(3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	c.Get("a") // b is now the least recently used
+	c.Set("d", 4)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted after a was read")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a to survive, got %d, %v", v, ok)
+	}
+}
+
+func TestLRUCache_SetExistingKeyPromotesWithoutEvicting(t *testing.T) {
+	c, _ := newTestLRU(2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("a", 10)
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted after a was overwritten")
+	}
+	if v, _ := c.Get("a"); v != 10 {
+		t.Errorf("Expected the updated value 10, got %d", v)
+	}
+	if m := c.Metrics(); m.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", m.Evictions)
+	}
+}
+
+func TestLRUCache_TTLDeleteAndPurge(t *testing.T) {
+	c, clock := newTestLRU(3)
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+	c.Set("c", 3)
+
+	c.Delete("c")
+	clock.now = clock.now.Add(time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to have expired")
+	}
+	if removed := c.Purge(); removed != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", removed)
+	}
+
+	// Room for two more before b, the only survivor, is evicted
+	c.Set("d", 4)
+	c.Set("e", 5)
+	if _, ok := c.Get("b"); !ok {
+		t.Error("Expected b to still be cached")
+	}
+	c.Set("f", 6)
+	if _, ok := c.Get("d"); ok {
+		t.Error("Expected d to be evicted")
+	}
+
+	m := c.Metrics()
+	if m.Evictions != 1 || m.Size != 3 {
+		t.Errorf("Expected expiry and deletes not to count as evictions, got %+v", m)
+	}
+}
+
+func TestLRUCache_ZeroCapacityIsUnbounded(t *testing.T) {
+	c := NewLRUCache[int, int](time.Minute, 0)
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	if c.Len() != 100 || c.Metrics().Evictions != 0 {
+		t.Errorf("Expected no evictions, got %+v", c.Metrics())
+	}
+}
+
+// BenchmarkLRUCache_MixedGetSet reads three times for every write over a key
+// space twice the capacity, so about half the reads miss and writes evict
+func BenchmarkLRUCache_MixedGetSet(b *testing.B) {
+	const capacity = 1024
+	c := NewLRUCache[string, int](time.Minute, capacity)
+
+	keys := make([]string, 2*capacity)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	var seq int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt64(&seq, 1)) * 7919
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				c.Set(key, i)
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
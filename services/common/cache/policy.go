@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// EvictionPolicy decides which key a full cache evicts. The cache tells it
+// about every key that enters, is used or leaves, and asks it for a victim
+// when a new key arrives at capacity. The cache calls it with its own lock
+// held, so implementations need no locking, but each cache needs its own
+// policy instance.
+type EvictionPolicy[K comparable] interface {
+	// RecordInsert is called when key is added to the cache
+	RecordInsert(key K)
+	// RecordAccess is called when key is read or overwritten
+	RecordAccess(key K)
+	// Remove is called when key leaves the cache for any reason
+	Remove(key K)
+	// SelectVictim returns the key to evict, or false if it tracks none
+	SelectVictim() (K, bool)
+}
+
+// Eviction names a built-in eviction policy
+type Eviction string
+
+// Built-in eviction policies
+const (
+	// EvictLRU evicts the least recently used key. It suits workloads
+	// whose hot set drifts, such as short links shared in bursts.
+	EvictLRU Eviction = "lru"
+	// EvictLFU evicts the least frequently used key, the least recently
+	// used of those on ties. It suits stable hot sets, such as popular DNS
+	// names, that a scan of one-off keys shouldn't flush.
+	EvictLFU Eviction = "lfu"
+	// EvictFIFO evicts the oldest key regardless of use. It is the
+	// cheapest: reads never reorder anything.
+	EvictFIFO Eviction = "fifo"
+)
+
+// NewEvictionPolicy returns a new instance of the named policy. An empty
+// name means EvictLRU.
+func NewEvictionPolicy[K comparable](name Eviction) (EvictionPolicy[K], error) {
+	switch name {
+	case EvictLRU, "":
+		return NewLRUPolicy[K](), nil
+	case EvictLFU:
+		return NewLFUPolicy[K](), nil
+	case EvictFIFO:
+		return NewFIFOPolicy[K](), nil
+	}
+	return nil, fmt.Errorf("unknown eviction policy %q", name)
+}
+
+// LRUPolicy evicts the least recently used key
+type LRUPolicy[K comparable] struct {
+	order    *list.List // front is the most recently used
+	elements map[K]*list.Element
+}
+
+// NewLRUPolicy creates an empty LRU policy
+func NewLRUPolicy[K comparable]() *LRUPolicy[K] {
+	return &LRUPolicy[K]{order: list.New(), elements: make(map[K]*list.Element)}
+}
+
+// RecordInsert makes key the most recently used
+func (p *LRUPolicy[K]) RecordInsert(key K) {
+	p.elements[key] = p.order.PushFront(key)
+}
+
+// RecordAccess makes key the most recently used
+func (p *LRUPolicy[K]) RecordAccess(key K) {
+	if e, exists := p.elements[key]; exists {
+		p.order.MoveToFront(e)
+	}
+}
+
+// Remove forgets key
+func (p *LRUPolicy[K]) Remove(key K) {
+	if e, exists := p.elements[key]; exists {
+		p.order.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// SelectVictim returns the least recently used key
+func (p *LRUPolicy[K]) SelectVictim() (K, bool) {
+	return back[K](p.order)
+}
+
+// FIFOPolicy evicts the key that was inserted first; accesses don't count
+type FIFOPolicy[K comparable] struct {
+	order    *list.List // front is the newest
+	elements map[K]*list.Element
+}
+
+// NewFIFOPolicy creates an empty FIFO policy
+func NewFIFOPolicy[K comparable]() *FIFOPolicy[K] {
+	return &FIFOPolicy[K]{order: list.New(), elements: make(map[K]*list.Element)}
+}
+
+// RecordInsert queues key as the newest
+func (p *FIFOPolicy[K]) RecordInsert(key K) {
+	p.elements[key] = p.order.PushFront(key)
+}
+
+// RecordAccess does nothing: FIFO order only depends on insertion
+func (p *FIFOPolicy[K]) RecordAccess(key K) {}
+
+// Remove forgets key
+func (p *FIFOPolicy[K]) Remove(key K) {
+	if e, exists := p.elements[key]; exists {
+		p.order.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// SelectVictim returns the oldest key
+func (p *FIFOPolicy[K]) SelectVictim() (K, bool) {
+	return back[K](p.order)
+}
+
+// LFUPolicy evicts the least frequently used key, breaking ties by least
+// recent use. Keys are grouped into one list per use count so every
+// operation is O(1).
+type LFUPolicy[K comparable] struct {
+	counts   map[K]int
+	elements map[K]*list.Element
+	buckets  map[int]*list.List // use count -> keys, front is the most recent
+	minCount int
+}
+
+// NewLFUPolicy creates an empty LFU policy
+func NewLFUPolicy[K comparable]() *LFUPolicy[K] {
+	return &LFUPolicy[K]{
+		counts:   make(map[K]int),
+		elements: make(map[K]*list.Element),
+		buckets:  make(map[int]*list.List),
+	}
+}
+
+// RecordInsert tracks key with a use count of 1
+func (p *LFUPolicy[K]) RecordInsert(key K) {
+	p.Remove(key)
+	p.push(key, 1)
+	p.minCount = 1
+}
+
+// RecordAccess adds one to key's use count
+func (p *LFUPolicy[K]) RecordAccess(key K) {
+	count, exists := p.counts[key]
+	if !exists {
+		return
+	}
+	p.unlink(key, count)
+	if count == p.minCount && p.buckets[count] == nil {
+		p.minCount++
+	}
+	p.push(key, count+1)
+}
+
+// Remove forgets key. The minimum count is recomputed lazily by
+// SelectVictim.
+func (p *LFUPolicy[K]) Remove(key K) {
+	if count, exists := p.counts[key]; exists {
+		p.unlink(key, count)
+		delete(p.counts, key)
+	}
+}
+
+// SelectVictim returns the least recently used of the least used keys
+func (p *LFUPolicy[K]) SelectVictim() (K, bool) {
+	if len(p.counts) == 0 {
+		var zero K
+		return zero, false
+	}
+	// Removals can leave minCount pointing at an emptied bucket
+	for p.buckets[p.minCount] == nil {
+		p.minCount++
+	}
+	return back[K](p.buckets[p.minCount])
+}
+
+// push adds key to the front of count's bucket
+func (p *LFUPolicy[K]) push(key K, count int) {
+	bucket := p.buckets[count]
+	if bucket == nil {
+		bucket = list.New()
+		p.buckets[count] = bucket
+	}
+	p.counts[key] = count
+	p.elements[key] = bucket.PushFront(key)
+}
+
+// unlink takes key out of count's bucket, dropping the bucket if it empties
+func (p *LFUPolicy[K]) unlink(key K, count int) {
+	bucket := p.buckets[count]
+	bucket.Remove(p.elements[key])
+	delete(p.elements, key)
+	if bucket.Len() == 0 {
+		delete(p.buckets, count)
+	}
+}
+
+// back returns the key at the back of l
+func back[K comparable](l *list.List) (K, bool) {
+	if e := l.Back(); e != nil {
+		return e.Value.(K), true
+	}
+	var zero K
+	return zero, false
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestEvicting(t testing.TB, name Eviction, maxEntries int) *TTLCache[string, int] {
+	t.Helper()
+	policy, err := NewEvictionPolicy[string](name)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return NewEvictingCache[string, int](time.Minute, maxEntries, policy)
+}
+
+// cached returns which of keys are still in c, without counting accesses
+func cached(c *TTLCache[string, int], keys ...string) map[string]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	present := make(map[string]bool)
+	for _, key := range keys {
+		_, present[key] = c.entries[key]
+	}
+	return present
+}
+
+func TestEvictionPolicies_CraftedPattern(t *testing.T) {
+	// a and b are read twice and c once, with a read last and b's last
+	// read before c's. Each policy evicts a different key when d arrives.
+	tests := []struct {
+		policy  Eviction
+		evicted string
+	}{
+		{EvictLRU, "b"},  // a and c were read after b
+		{EvictLFU, "c"},  // c was read least
+		{EvictFIFO, "a"}, // a was set first; reads don't matter
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			c := newTestEvicting(t, tt.policy, 3)
+			c.Set("a", 1)
+			c.Set("b", 2)
+			c.Set("c", 3)
+			c.Get("a")
+			c.Get("b")
+			c.Get("b")
+			c.Get("c")
+			c.Get("a")
+
+			c.Set("d", 4)
+
+			present := cached(c, "a", "b", "c", "d")
+			for key, ok := range present {
+				if want := key != tt.evicted; ok != want {
+					t.Errorf("Expected %s cached=%v, got %v (cache holds %v)", key, want, ok, present)
+				}
+			}
+			if m := c.Metrics(); m.Evictions != 1 || m.Size != 3 {
+				t.Errorf("Expected 1 eviction and size 3, got %+v", m)
+			}
+		})
+	}
+}
+
+func TestLFUPolicy_NewKeysCanStay(t *testing.T) {
+	c := newTestEvicting(t, EvictLFU, 2)
+	c.Set("hot", 1)
+	for i := 0; i < 5; i++ {
+		c.Get("hot")
+	}
+
+	// A stream of one-off keys replaces itself and never pushes out the
+	// hot key
+	for _, key := range []string{"x", "y", "z"} {
+		c.Set(key, 0)
+		if present := cached(c, "hot", key); !present["hot"] || !present[key] {
+			t.Errorf("Expected hot and %s cached, got %v", key, present)
+		}
+	}
+}
+
+func TestLFUPolicy_DeleteThenEvict(t *testing.T) {
+	c := newTestEvicting(t, EvictLFU, 2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("b")
+	c.Delete("a") // leaves no key with one use
+
+	c.Set("c", 3)
+	c.Set("d", 4) // c has fewer uses than b
+
+	if present := cached(c, "b", "c", "d"); !present["b"] || present["c"] || !present["d"] {
+		t.Errorf("Expected c evicted, got %v", present)
+	}
+}
+
+func TestNewEvictionPolicy_Unknown(t *testing.T) {
+	if _, err := NewEvictionPolicy[string]("random"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+	if policy, err := NewEvictionPolicy[string](""); err != nil {
+		t.Errorf("Expected the default policy, got %v", err)
+	} else if _, ok := policy.(*LRUPolicy[string]); !ok {
+		t.Errorf("Expected LRU by default, got %T", policy)
+	}
+}
+
+// BenchmarkEvictionPolicies_Zipf replays the same Zipf-distributed key
+// stream against each policy with room for 1% of the keys, reporting the
+// hit rate. A read that misses sets the key, as a read-through cache
+// would.
+func BenchmarkEvictionPolicies_Zipf(b *testing.B) {
+	const (
+		keySpace   = 10000
+		maxEntries = keySpace / 100
+	)
+	for _, policy := range []Eviction{EvictLRU, EvictLFU, EvictFIFO} {
+		b.Run(string(policy), func(b *testing.B) {
+			c := NewEvictingCache[uint64, int](time.Hour, maxEntries, must(NewEvictionPolicy[uint64](policy)))
+			zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, keySpace-1)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := zipf.Uint64()
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, i)
+				}
+			}
+
+			m := c.Metrics()
+			b.ReportMetric(float64(m.Hits)/float64(m.Hits+m.Misses), "hit-rate")
+		})
+	}
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
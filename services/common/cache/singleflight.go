@@ -0,0 +1,74 @@
+package cache
+
+import "sync"
+
+// call is a load in flight or just finished
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Group coalesces concurrent loads of the same key so that when a cached
+// value expires under load only one caller recomputes it and the rest wait
+// for and share its result. The zero value is ready to use.
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Do runs load for key unless a load for key is already running, in which
+// case it waits for that one and returns its result. shared reports
+// whether the result came from another caller's load.
+func (g *Group[K, V]) Do(key K, load func() (V, error)) (value V, err error, shared bool) {
+	c, started := g.start(key)
+	if !started {
+		<-c.done
+		return c.value, c.err, true
+	}
+
+	g.run(key, c, load)
+	return c.value, c.err, false
+}
+
+// Refresh runs load for key in the background unless a load for key is
+// already running, and reports whether it started one. Callers of Do for
+// key wait for the refresh and share its result.
+func (g *Group[K, V]) Refresh(key K, load func() (V, error)) bool {
+	c, started := g.start(key)
+	if started {
+		go g.run(key, c, load)
+	}
+	return started
+}
+
+// start returns the call in flight for key, or registers a new one and
+// reports that the caller must run it
+func (g *Group[K, V]) start(key K) (*call[V], bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if c, exists := g.calls[key]; exists {
+		return c, false
+	}
+
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+// run calls load and publishes its result to c's waiters
+func (g *Group[K, V]) run(key K, c *call[V], load func() (V, error)) {
+	// Release the waiters even if load panics
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.value, c.err = load()
+}
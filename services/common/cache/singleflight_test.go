@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_CoalescesConcurrentLoads(t *testing.T) {
+	var g Group[string, int]
+	var loads, shared int64
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, wasShared := g.Do("key", func() (int, error) {
+				atomic.AddInt64(&loads, 1)
+				<-release
+				return 42, nil
+			})
+			if v != 42 || err != nil {
+				t.Errorf("Expected 42, got %d, %v", v, err)
+			}
+			if wasShared {
+				atomic.AddInt64(&shared, 1)
+			}
+		}()
+	}
+
+	// Let the callers pile up behind the first load
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 || shared != 19 {
+		t.Errorf("Expected 1 load shared by 19 callers, got %d loads and %d shared", loads, shared)
+	}
+}
+
+func TestGroup_LoadsAgainAfterFinishing(t *testing.T) {
+	var g Group[string, int]
+	boom := errors.New("boom")
+
+	if _, err, _ := g.Do("key", func() (int, error) { return 0, boom }); err != boom {
+		t.Errorf("Expected the load error, got %v", err)
+	}
+	if v, err, shared := g.Do("key", func() (int, error) { return 7, nil }); v != 7 || err != nil || shared {
+		t.Errorf("Expected a fresh load, got %d, %v, shared %v", v, err, shared)
+	}
+}
+
+func TestGroup_PanicReleasesWaiters(t *testing.T) {
+	var g Group[string, int]
+
+	func() {
+		defer func() { recover() }()
+		g.Do("key", func() (int, error) { panic("boom") })
+	}()
+
+	if v, _, _ := g.Do("key", func() (int, error) { return 1, nil }); v != 1 {
+		t.Errorf("Expected the key usable after a panic, got %d", v)
+	}
+}
+
+func TestGroup_RefreshRunsOnceInBackground(t *testing.T) {
+	var g Group[string, int]
+	var loads int64
+	release := make(chan struct{})
+	load := func() (int, error) {
+		atomic.AddInt64(&loads, 1)
+		<-release
+		return 7, nil
+	}
+
+	if !g.Refresh("key", load) {
+		t.Fatal("Expected the first Refresh to start a load")
+	}
+	if g.Refresh("key", load) {
+		t.Error("Expected a second Refresh to join the one in flight")
+	}
+
+	close(release)
+	// The load is forgotten once it finishes, so a later Refresh starts again
+	deadline := time.Now().Add(time.Second)
+	for !g.Refresh("key", func() (int, error) { return 0, nil }) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background load to finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&loads); n != 1 {
+		t.Errorf("Expected one load, got %d", n)
+	}
+}
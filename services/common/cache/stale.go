@@ -0,0 +1,37 @@
+package cache
+
+import "time"
+
+// Freshness is how a cached value's age compares to its soft and hard TTLs
+type Freshness int
+
+const (
+	// Fresh values are younger than the soft TTL and are served as is
+	Fresh Freshness = iota
+	// Stale values are past the soft TTL but not the hard one. They are
+	// served immediately while a refresh runs in the background.
+	Stale
+	// Expired values are past the hard TTL and must be reloaded before
+	// anything is served
+	Expired
+)
+
+// SoftTTL configures stale-while-revalidate caching: a value is fresh for
+// Soft after it is stored, stale until Hard and expired after that. A Soft
+// of zero, or one not below Hard, disables the stale phase.
+type SoftTTL struct {
+	Soft time.Duration
+	Hard time.Duration
+}
+
+// Freshness classifies a value stored age ago
+func (t SoftTTL) Freshness(age time.Duration) Freshness {
+	switch {
+	case age >= t.Hard:
+		return Expired
+	case t.Soft > 0 && age >= t.Soft:
+		return Stale
+	default:
+		return Fresh
+	}
+}
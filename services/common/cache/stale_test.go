@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftTTL_Freshness(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  SoftTTL
+		age  time.Duration
+		want Freshness
+	}{
+		{"before soft", SoftTTL{Soft: time.Second, Hard: time.Minute}, 999 * time.Millisecond, Fresh},
+		{"at soft", SoftTTL{Soft: time.Second, Hard: time.Minute}, time.Second, Stale},
+		{"before hard", SoftTTL{Soft: time.Second, Hard: time.Minute}, time.Minute - 1, Stale},
+		{"at hard", SoftTTL{Soft: time.Second, Hard: time.Minute}, time.Minute, Expired},
+		{"no soft", SoftTTL{Hard: time.Minute}, 59 * time.Second, Fresh},
+		{"soft past hard", SoftTTL{Soft: time.Hour, Hard: time.Minute}, 2 * time.Minute, Expired},
+	}
+
+	for _, tt := range tests {
+		if got := tt.ttl.Freshness(tt.age); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics reports cache effectiveness
+type Metrics struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+}
+
+// entry is a cached value
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache is a concurrency-safe map whose entries expire a fixed time
+// after they are set. Expired entries are treated as absent by Get and are
+// removed lazily or by Purge.
+//
+// A cache created by NewLRUCache or NewEvictingCache also holds at most
+// maxEntries entries: setting a new key at capacity first evicts the key
+// its EvictionPolicy selects.
+type TTLCache[K comparable, V any] struct {
+	mu      sync.RWMutex
+	entries map[K]*entry[K, V]
+	ttl     time.Duration
+	now     func() time.Time
+
+	// maxEntries is zero when there is no capacity limit, and policy is
+	// then nil
+	maxEntries int
+	policy     EvictionPolicy[K]
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// NewTTLCache creates a cache whose entries live for ttl
+func NewTTLCache[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
+	return &TTLCache[K, V]{
+		entries: make(map[K]*entry[K, V]),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// NewLRUCache creates a cache whose entries live for ttl and which evicts
+// the least recently used entry once it holds maxEntries. A maxEntries of
+// zero or less means no limit, like NewTTLCache.
+func NewLRUCache[K comparable, V any](ttl time.Duration, maxEntries int) *TTLCache[K, V] {
+	return NewEvictingCache[K, V](ttl, maxEntries, NewLRUPolicy[K]())
+}
+
+// NewEvictingCache creates a cache whose entries live for ttl and which
+// evicts the entry policy selects once it holds maxEntries. policy must not
+// be shared with another cache. A maxEntries of zero or less means no
+// limit, and policy is unused.
+func NewEvictingCache[K comparable, V any](ttl time.Duration, maxEntries int, policy EvictionPolicy[K]) *TTLCache[K, V] {
+	c := NewTTLCache[K, V](ttl)
+	if maxEntries > 0 {
+		c.maxEntries = maxEntries
+		c.policy = policy
+	}
+	return c
+}
+
+// Get returns the value for key if it is present and not expired. In a
+// capacity-limited cache a hit is also recorded as an access with the
+// eviction policy.
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	if c.maxEntries > 0 {
+		return c.getTracked(key)
+	}
+
+	c.mu.RLock()
+	e, exists := c.entries[key]
+	var value V
+	var expiresAt time.Time
+	if exists {
+		value, expiresAt = e.value, e.expiresAt
+	}
+	c.mu.RUnlock()
+
+	if !exists || !c.now().Before(expiresAt) {
+		atomic.AddInt64(&c.misses, 1)
+		var zero V
+		return zero, false
+	}
+
+	atomic.AddInt64(&c.hits, 1)
+	return value, true
+}
+
+// getTracked is Get for capacity-limited caches, which must update the
+// eviction policy and so take the write lock
+func (c *TTLCache[K, V]) getTracked(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, exists := c.entries[key]
+	if !exists || !c.now().Before(e.expiresAt) {
+		atomic.AddInt64(&c.misses, 1)
+		var zero V
+		return zero, false
+	}
+
+	c.policy.RecordAccess(key)
+	atomic.AddInt64(&c.hits, 1)
+	return e.value, true
+}
+
+// Set stores value under key for the cache's TTL
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores value under key for ttl instead of the cache default
+func (c *TTLCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if e, exists := c.entries[key]; exists {
+		e.value = value
+		e.expiresAt = expiresAt
+		if c.maxEntries > 0 {
+			c.policy.RecordAccess(key)
+		}
+		return
+	}
+
+	// Evict before inserting, so the policy never picks the new key
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		if victim, ok := c.policy.SelectVictim(); ok {
+			c.removeLocked(c.entries[victim])
+			atomic.AddInt64(&c.evictions, 1)
+		}
+	}
+
+	c.entries[key] = &entry[K, V]{key: key, value: value, expiresAt: expiresAt}
+	if c.maxEntries > 0 {
+		c.policy.RecordInsert(key)
+	}
+}
+
+// Delete removes key from the cache
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, exists := c.entries[key]; exists {
+		c.removeLocked(e)
+	}
+}
+
+// Purge removes every expired entry and returns how many were removed.
+// Expired entries don't count as evictions.
+func (c *TTLCache[K, V]) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	removed := 0
+	for _, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			c.removeLocked(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// StartJanitor purges expired entries every interval until stop is closed
+func (c *TTLCache[K, V]) StartJanitor(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Purge()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Len returns the number of stored entries, including expired ones not yet
+// purged
+func (c *TTLCache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Metrics returns hit, miss and eviction counts and the current size
+func (c *TTLCache[K, V]) Metrics() Metrics {
+	return Metrics{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+		Size:      c.Len(),
+	}
+}
+
+// removeLocked deletes e from the map and from the eviction policy
+func (c *TTLCache[K, V]) removeLocked(e *entry[K, V]) {
+	delete(c.entries, e.key)
+	if c.maxEntries > 0 {
+		c.policy.Remove(e.key)
+	}
+}
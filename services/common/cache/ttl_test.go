@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests move time forward without sleeping
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func newTestCache(ttl time.Duration) (*TTLCache[string, int], *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := NewTTLCache[string, int](ttl)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestTTLCache_GetSet(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected miss on empty cache")
+	}
+
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected hit with 1, got %d, %v", v, ok)
+	}
+
+	m := c.Metrics()
+	if m.Hits != 1 || m.Misses != 1 || m.Size != 1 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestTTLCache_Expiry(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+
+	clock.now = clock.now.Add(time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to have expired")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("Expected b to outlive the default TTL")
+	}
+
+	if removed := c.Purge(); removed != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", removed)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected 1 entry left, got %d", c.Len())
+	}
+}
+
+func TestTTLCache_Delete(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	c.Set("a", 1)
+	c.Delete("a")
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+}
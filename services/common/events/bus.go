@@ -0,0 +1,106 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBufferSize is the per-subscriber channel buffer used by NewEventBus
+// when no size is given
+const DefaultBufferSize = 64
+
+// Event is a domain event delivered to subscribers
+type Event struct {
+	Topic     string
+	Payload   any
+	Timestamp time.Time
+}
+
+// EventBus is an in-process publish/subscribe bus. Every subscriber gets its
+// own buffered channel; Publish never blocks; an event is dropped for a
+// subscriber whose buffer is full.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]chan Event // topic -> subscriber channels
+	bufferSize  int
+	dropped     int64
+}
+
+// NewEventBus creates a new event bus
+func NewEventBus(bufferSize int) *EventBus {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	return &EventBus{
+		subscribers: make(map[string][]chan Event),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish delivers an event to every subscriber of the topic
+func (b *EventBus) Publish(topic string, payload any) {
+	event := Event{
+		Topic:     topic,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}
+
+	// Hold the read lock while sending so Unsubscribe can't close a
+	// channel underneath us
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ch := range b.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+			atomic.AddInt64(&b.dropped, 1)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published to the topic
+func (b *EventBus) Subscribe(topic string) <-chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, b.bufferSize)
+	b.subscribers[topic] = append(b.subscribers[topic], ch)
+	return ch
+}
+
+// Unsubscribe removes a subscription and closes its channel
+func (b *EventBus) Unsubscribe(topic string, sub <-chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscribers[topic]
+	for i, ch := range subs {
+		if ch == sub {
+			close(ch)
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+
+	if len(subs) == 0 {
+		delete(b.subscribers, topic)
+	} else {
+		b.subscribers[topic] = subs
+	}
+}
+
+// SubscriberCount returns the number of subscribers for a topic
+func (b *EventBus) SubscriberCount(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers[topic])
+}
+
+// Dropped returns the number of events dropped because a subscriber's buffer
+// was full
+func (b *EventBus) Dropped() int64 {
+	return atomic.LoadInt64(&b.dropped)
+}
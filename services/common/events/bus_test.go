@@ -0,0 +1,101 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBus_FanOut(t *testing.T) {
+	bus := NewEventBus(4)
+	first := bus.Subscribe("post.created")
+	second := bus.Subscribe("post.created")
+	other := bus.Subscribe("post.deleted")
+
+	bus.Publish("post.created", "post_1")
+
+	for i, ch := range []<-chan Event{first, second} {
+		select {
+		case event := <-ch:
+			if event.Topic != "post.created" || event.Payload != "post_1" {
+				t.Errorf("Subscriber %d: unexpected event %+v", i, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Subscriber %d did not receive event", i)
+		}
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("Expected no event on other topic, got %+v", event)
+	default:
+	}
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	bus := NewEventBus(4)
+	sub := bus.Subscribe("post.created")
+	keep := bus.Subscribe("post.created")
+
+	bus.Unsubscribe("post.created", sub)
+
+	if _, open := <-sub; open {
+		t.Error("Expected unsubscribed channel to be closed")
+	}
+	if count := bus.SubscriberCount("post.created"); count != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", count)
+	}
+
+	bus.Publish("post.created", "post_1")
+	if len(keep) != 1 {
+		t.Errorf("Expected remaining subscriber to receive event, got %d", len(keep))
+	}
+
+	bus.Unsubscribe("post.created", keep)
+	if _, exists := bus.subscribers["post.created"]; exists {
+		t.Error("Expected topic to be removed after last unsubscribe")
+	}
+
+	// Unsubscribing twice is a no-op
+	bus.Unsubscribe("post.created", keep)
+}
+
+func TestEventBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewEventBus(2)
+	slow := bus.Subscribe("post.created")
+	fast := bus.Subscribe("post.created")
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for range fast {
+			count++
+		}
+		received <- count
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			bus.Publish("post.created", i)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	if len(slow) != 2 {
+		t.Errorf("Expected slow subscriber buffer to be full, got %d", len(slow))
+	}
+	if bus.Dropped() < 98 {
+		t.Errorf("Expected at least 98 dropped events, got %d", bus.Dropped())
+	}
+
+	bus.Unsubscribe("post.created", fast)
+	if count := <-received; count == 0 {
+		t.Error("Expected fast subscriber to receive events")
+	}
+}
@@ -0,0 +1,182 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+)
+
+// ErrLockTimeout is returned by LockCheck when the lock can't be acquired
+var ErrLockTimeout = errors.New("timed out acquiring lock")
+
+// CheckFunc reports the health of a single subsystem
+type CheckFunc func() error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// CheckResult is the outcome of one subsystem check
+type CheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the body served by the readiness endpoint
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs registered subsystem checks for readiness probes. A failing
+// critical check makes the service unready; a failing non-critical check
+// only marks it degraded.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []check
+}
+
+// NewChecker creates a new checker with no checks
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Register adds a named subsystem check
+func (c *Checker) Register(name string, critical bool, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Run executes every check and returns the aggregated report
+func (c *Checker) Run() Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+
+	for _, chk := range checks {
+		result := CheckResult{Status: StatusUp, Critical: chk.critical}
+		if err := chk.fn(); err != nil {
+			result.Status = StatusDown
+			result.Error = err.Error()
+
+			if chk.critical {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}
+		report.Checks[chk.name] = result
+	}
+
+	return report
+}
+
+// ReadyHandler serves the check report, with a 503 when any critical check
+// fails
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Run()
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == StatusDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// LiveHandler reports that the process is running. It runs no checks so a
+// failing dependency doesn't get the container restarted.
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": StatusUp})
+}
+
+// Mount registers /healthz/live and /healthz/ready on the mux
+func (c *Checker) Mount(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz/live", LiveHandler)
+	mux.HandleFunc("/healthz/ready", c.ReadyHandler)
+}
+
+// RLocker is the read side of a sync.RWMutex
+type RLocker interface {
+	RLock()
+	RUnlock()
+}
+
+// LockCheck returns a check that fails when the read lock can't be taken
+// within the timeout, e.g. because a writer is stuck. Only one probe waits
+// on the lock at a time.
+func LockCheck(l RLocker, timeout time.Duration) CheckFunc {
+	var waiting int32
+
+	return func() error {
+		if !atomic.CompareAndSwapInt32(&waiting, 0, 1) {
+			return ErrLockTimeout
+		}
+
+		acquired := make(chan struct{})
+		go func() {
+			l.RLock()
+			l.RUnlock()
+			atomic.StoreInt32(&waiting, 0)
+			close(acquired)
+		}()
+
+		select {
+		case <-acquired:
+			return nil
+		case <-time.After(timeout):
+			return ErrLockTimeout
+		}
+	}
+}
+
+// Heartbeat records when a background loop last ran
+type Heartbeat struct {
+	last int64 // unix nanoseconds
+}
+
+// Beat records a run of the loop
+func (h *Heartbeat) Beat() {
+	atomic.StoreInt64(&h.last, time.Now().UnixNano())
+}
+
+// Last returns when the loop last ran, or the zero time if it never has
+func (h *Heartbeat) Last() time.Time {
+	last := atomic.LoadInt64(&h.last)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// Check returns a check failing when the loop hasn't run within maxAge
+func (h *Heartbeat) Check(maxAge time.Duration) CheckFunc {
+	return func() error {
+		last := h.Last()
+		if last.IsZero() {
+			return errors.New("not started")
+		}
+		if time.Since(last) > maxAge {
+			return errors.New("last run " + time.Since(last).Round(time.Second).String() + " ago")
+		}
+		return nil
+	}
+}
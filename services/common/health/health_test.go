@@ -0,0 +1,110 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadyHandler_CriticalFailure(t *testing.T) {
+	checker := NewChecker()
+	checker.Register("store", true, func() error { return nil })
+	checker.Register("database", true, func() error { return errors.New("connection refused") })
+
+	w := httptest.NewRecorder()
+	checker.ReadyHandler(w, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Expected JSON body, got %v", err)
+	}
+	if report.Status != StatusDown {
+		t.Errorf("Expected status %q, got %q", StatusDown, report.Status)
+	}
+	if report.Checks["database"].Status != StatusDown || report.Checks["database"].Error != "connection refused" {
+		t.Errorf("Expected failing database check, got %+v", report.Checks["database"])
+	}
+	if report.Checks["store"].Status != StatusUp {
+		t.Errorf("Expected store to be up, got %+v", report.Checks["store"])
+	}
+}
+
+func TestReadyHandler_NonCriticalFailure(t *testing.T) {
+	checker := NewChecker()
+	checker.Register("store", true, func() error { return nil })
+	checker.Register("indexer", false, func() error { return errors.New("not running") })
+
+	w := httptest.NewRecorder()
+	checker.ReadyHandler(w, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"status":"degraded"`) {
+		t.Errorf("Expected degraded status, got %s", w.Body.String())
+	}
+}
+
+func TestLiveHandler_IgnoresChecks(t *testing.T) {
+	checker := NewChecker()
+	checker.Register("database", true, func() error { return errors.New("down") })
+
+	mux := http.NewServeMux()
+	checker.Mount(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestLockCheck(t *testing.T) {
+	var mu sync.RWMutex
+	check := LockCheck(&mu, 20*time.Millisecond)
+
+	if err := check(); err != nil {
+		t.Fatalf("Expected free lock to pass, got %v", err)
+	}
+
+	mu.Lock()
+	if err := check(); err != ErrLockTimeout {
+		t.Errorf("Expected ErrLockTimeout while write-locked, got %v", err)
+	}
+	mu.Unlock()
+
+	// The stuck probe finishes once the lock is released
+	time.Sleep(10 * time.Millisecond)
+	if err := check(); err != nil {
+		t.Errorf("Expected lock check to recover, got %v", err)
+	}
+}
+
+func TestHeartbeat_Check(t *testing.T) {
+	var hb Heartbeat
+	check := hb.Check(time.Minute)
+
+	if err := check(); err == nil {
+		t.Error("Expected error before first beat")
+	}
+
+	hb.Beat()
+	if err := check(); err != nil {
+		t.Errorf("Expected fresh heartbeat to pass, got %v", err)
+	}
+
+	hb.last = time.Now().Add(-time.Hour).UnixNano()
+	if err := check(); err == nil {
+		t.Error("Expected stale heartbeat to fail")
+	}
+}
@@ -0,0 +1,85 @@
+// Package index provides a secondary index from a key to the IDs filed
+// under it, such as a user's posts or a tag's questions
+package index
+
+import "sync"
+
+// Index maps keys to IDs in the order they were added. An ID is listed at
+// most once per key. It is safe for concurrent use.
+type Index[K comparable] struct {
+	mu   sync.RWMutex
+	ids  map[K][]string
+	seen map[K]map[string]struct{}
+}
+
+// New creates an empty index
+func New[K comparable]() *Index[K] {
+	return &Index[K]{
+		ids:  make(map[K][]string),
+		seen: make(map[K]map[string]struct{}),
+	}
+}
+
+// Add files id under key. Adding an ID that is already there is a no-op.
+func (x *Index[K]) Add(key K, id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.seen[key] == nil {
+		x.seen[key] = make(map[string]struct{})
+	}
+	if _, exists := x.seen[key][id]; exists {
+		return
+	}
+	x.seen[key][id] = struct{}{}
+	x.ids[key] = append(x.ids[key], id)
+}
+
+// Remove takes id out of key and reports whether it was there. A key left
+// with no IDs is dropped.
+func (x *Index[K]) Remove(key K, id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if _, exists := x.seen[key][id]; !exists {
+		return false
+	}
+
+	delete(x.seen[key], id)
+	if len(x.seen[key]) == 0 {
+		delete(x.seen, key)
+		delete(x.ids, key)
+		return true
+	}
+
+	ids := x.ids[key]
+	for i, existing := range ids {
+		if existing == id {
+			x.ids[key] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Get returns a copy of the IDs under key in the order they were added
+func (x *Index[K]) Get(key K) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return append([]string{}, x.ids[key]...)
+}
+
+// Len returns how many IDs are under key
+func (x *Index[K]) Len(key K) int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.ids[key])
+}
+
+// Has reports whether id is under key
+func (x *Index[K]) Has(key K, id string) bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	_, exists := x.seen[key][id]
+	return exists
+}
@@ -0,0 +1,73 @@
+package index
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestIndex_AddRemoveGet(t *testing.T) {
+	x := New[string]()
+	x.Add("go", "q_1")
+	x.Add("go", "q_2")
+	x.Add("go", "q_1") // duplicate
+	x.Add("rust", "q_2")
+
+	if got := x.Get("go"); !reflect.DeepEqual(got, []string{"q_1", "q_2"}) {
+		t.Errorf("Expected [q_1 q_2], got %v", got)
+	}
+	if got := x.Get("missing"); len(got) != 0 {
+		t.Errorf("Expected nothing for a missing key, got %v", got)
+	}
+
+	if !x.Remove("go", "q_1") {
+		t.Error("Expected q_1 to be removed")
+	}
+	if x.Remove("go", "q_1") {
+		t.Error("Expected a second remove to report false")
+	}
+	if got := x.Get("go"); !reflect.DeepEqual(got, []string{"q_2"}) {
+		t.Errorf("Expected [q_2], got %v", got)
+	}
+	if !x.Has("rust", "q_2") || x.Has("go", "q_1") {
+		t.Error("Expected Has to follow adds and removes")
+	}
+
+	x.Remove("go", "q_2")
+	if x.Len("go") != 0 || len(x.ids) != 1 {
+		t.Errorf("Expected the emptied key to be dropped, got %v", x.ids)
+	}
+}
+
+func TestIndex_GetReturnsCopy(t *testing.T) {
+	x := New[int]()
+	x.Add(1, "a")
+	x.Add(1, "b")
+
+	got := x.Get(1)
+	got[0] = "changed"
+
+	if x.Get(1)[0] != "a" {
+		t.Error("Expected Get to return a copy")
+	}
+}
+
+func TestIndex_Concurrent(t *testing.T) {
+	x := New[string]()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := string(rune('A' + i%26))
+			x.Add("k", id)
+			x.Get("k")
+			x.Remove("k", id)
+		}(i)
+	}
+	wg.Wait()
+
+	if x.Len("k") != 0 {
+		t.Errorf("Expected every ID removed, got %v", x.Get("k"))
+	}
+}
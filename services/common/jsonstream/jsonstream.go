@@ -0,0 +1,104 @@
+// Package jsonstream writes large JSON arrays to a response one element at
+// a time, so the encoded array never has to be held in memory
+package jsonstream
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// DefaultFlushEvery is how many elements are written between flushes
+const DefaultFlushEvery = 100
+
+// ArrayWriter writes the elements of a JSON array as they are produced.
+// Nothing is written until the first element or Close, so a handler can
+// still send an error response before then.
+type ArrayWriter struct {
+	w          io.Writer
+	flusher    http.Flusher // nil when w can't flush
+	flushEvery int
+
+	count int
+	err   error
+}
+
+// NewArrayWriter creates an ArrayWriter on w, flushing every flushEvery
+// elements if w is an http.Flusher. A flushEvery of zero or less uses
+// DefaultFlushEvery.
+func NewArrayWriter(w io.Writer, flushEvery int) *ArrayWriter {
+	if flushEvery <= 0 {
+		flushEvery = DefaultFlushEvery
+	}
+	flusher, _ := w.(http.Flusher)
+	return &ArrayWriter{w: w, flusher: flusher, flushEvery: flushEvery}
+}
+
+// Write encodes v as the next element. After the first error every call
+// returns it without writing.
+func (a *ArrayWriter) Write(v interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		a.err = err
+		return err
+	}
+
+	sep := []byte{','}
+	if a.count == 0 {
+		sep = []byte{'['}
+	}
+	if _, a.err = a.w.Write(sep); a.err != nil {
+		return a.err
+	}
+	if _, a.err = a.w.Write(data); a.err != nil {
+		return a.err
+	}
+
+	a.count++
+	if a.flusher != nil && a.count%a.flushEvery == 0 {
+		a.flusher.Flush()
+	}
+	return nil
+}
+
+// Close ends the array, writing [] if it had no elements. The output ends
+// with a newline like json.Encoder's.
+func (a *ArrayWriter) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+
+	end := "]\n"
+	if a.count == 0 {
+		end = "[]\n"
+	}
+	if _, a.err = io.WriteString(a.w, end); a.err != nil {
+		return a.err
+	}
+	if a.flusher != nil {
+		a.flusher.Flush()
+	}
+	return nil
+}
+
+// Count returns how many elements have been written
+func (a *ArrayWriter) Count() int {
+	return a.count
+}
+
+// WriteArray streams items to w as a JSON array with a JSON content type
+func WriteArray[T any](w http.ResponseWriter, items []T) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	array := NewArrayWriter(w, DefaultFlushEvery)
+	for _, item := range items {
+		if err := array.Write(item); err != nil {
+			return err
+		}
+	}
+	return array.Close()
+}
@@ -0,0 +1,85 @@
+package jsonstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type item struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags,omitempty"`
+	HTML string   `json:"html"`
+}
+
+func TestWriteArray_MatchesBufferedEncoding(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 5000} {
+		items := make([]*item, n)
+		for i := range items {
+			items[i] = &item{ID: strconv.Itoa(i), HTML: "<a href=\"x\">&</a>"}
+			if i%2 == 0 {
+				items[i].Tags = []string{"even"}
+			}
+		}
+
+		var buffered bytes.Buffer
+		json.NewEncoder(&buffered).Encode(items)
+
+		w := httptest.NewRecorder()
+		if err := WriteArray(w, items); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if !json.Valid(w.Body.Bytes()) {
+			t.Fatalf("%d items: expected valid JSON", n)
+		}
+		if w.Body.String() != buffered.String() {
+			t.Errorf("%d items: streamed output differs from json.Encoder", n)
+		}
+		if n > DefaultFlushEvery && !w.Flushed {
+			t.Errorf("%d items: expected the response to be flushed", n)
+		}
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// failingWriter fails every write after the first limit bytes
+type failingWriter struct {
+	limit int
+}
+
+var errWrite = errors.New("write failed")
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > f.limit {
+		return 0, errWrite
+	}
+	f.limit -= len(p)
+	return len(p), nil
+}
+
+func TestArrayWriter_StopsAfterError(t *testing.T) {
+	a := NewArrayWriter(&failingWriter{limit: 5}, 0)
+
+	if err := a.Write("ab"); err != nil {
+		t.Fatalf("Expected the first element to fit, got %v", err)
+	}
+	if err := a.Write("cd"); err != errWrite {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+	if err := a.Close(); err != errWrite {
+		t.Errorf("Expected Close to report the write error, got %v", err)
+	}
+	if a.Count() != 1 {
+		t.Errorf("Expected 1 element written, got %d", a.Count())
+	}
+
+	if err := NewArrayWriter(&bytes.Buffer{}, 0).Write(func() {}); err == nil {
+		t.Error("Expected an error for a value JSON can't encode")
+	}
+}
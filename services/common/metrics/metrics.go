@@ -0,0 +1,196 @@
+// Package metrics is a minimal counter and gauge registry that renders in
+// the Prometheus text exposition format, so services can expose /metrics
+// without a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is the Prometheus text exposition format served by Handler
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var validName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Counter is a value that only goes up. It is safe for concurrent use.
+type Counter struct {
+	value uint64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// Gauge is a value that can go up and down. It is safe for concurrent use.
+type Gauge struct {
+	bits uint64 // math.Float64bits of the value
+}
+
+// Set replaces the gauge's value
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds delta, which may be negative, to the gauge
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, updated) {
+			return
+		}
+	}
+}
+
+// Value returns the gauge's current value
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// metric is one registered counter or gauge
+type metric struct {
+	name  string
+	help  string
+	kind  string // "counter" or "gauge"
+	value func() string
+}
+
+// Registry holds named metrics and renders them for scraping
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Default is the registry the package-level constructors and Handler use
+var Default = NewRegistry()
+
+// NewCounter registers a counter called name. It panics if the name is
+// invalid or already registered, like a duplicate route would.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(metric{name: name, help: help, kind: "counter", value: func() string {
+		return strconv.FormatUint(c.Value(), 10)
+	}})
+	return c
+}
+
+// NewGauge registers a gauge called name. It panics like NewCounter.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(metric{name: name, help: help, kind: "gauge", value: func() string {
+		return formatFloat(g.Value())
+	}})
+	return g
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at scrape
+// time, for values the service already tracks. It panics like NewCounter.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(metric{name: name, help: help, kind: "gauge", value: func() string {
+		return formatFloat(fn())
+	}})
+}
+
+func (r *Registry) register(m metric) {
+	if !validName.MatchString(m.name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", m.name))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.metrics[m.name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", m.name))
+	}
+	r.metrics[m.name] = m
+}
+
+// WriteTo renders every metric in the Prometheus text format, sorted by
+// name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	metrics := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.RUnlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+
+	var total int64
+	for _, m := range metrics {
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.name, helpEscaper.Replace(m.help), m.name, m.kind, m.name, m.value())
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Handler serves the registry's metrics for a Prometheus scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// NewCounter registers a counter in the Default registry
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+// NewGauge registers a gauge in the Default registry
+func NewGauge(name, help string) *Gauge {
+	return Default.NewGauge(name, help)
+}
+
+// NewGaugeFunc registers a computed gauge in the Default registry
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// helpEscaper escapes backslashes and newlines, as the format requires in
+// HELP lines
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCounter_ConcurrentIncrements(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests served")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.Value(); got != 50000 {
+		t.Errorf("Expected 50000, got %d", got)
+	}
+}
+
+func TestGauge_ConcurrentAdds(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("in_flight", "Requests in flight")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				g.Add(1)
+				g.Add(-0.5)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := g.Value(); got != 2500 {
+		t.Errorf("Expected 2500, got %v", got)
+	}
+	g.Set(-1.5)
+	if got := g.Value(); got != -1.5 {
+		t.Errorf("Expected -1.5, got %v", got)
+	}
+}
+
+func TestRegistry_RendersExpositionText(t *testing.T) {
+	r := NewRegistry()
+	posts := r.NewCounter("posts_created_total", "Posts created")
+	r.NewGauge("queue_depth", "Items waiting\nto be processed").Set(2.5)
+	r.NewGaugeFunc("users", `Registered users, see C:\docs`, func() float64 { return 7 })
+	posts.Add(3)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	want := strings.Join([]string{
+		"# HELP posts_created_total Posts created",
+		"# TYPE posts_created_total counter",
+		"posts_created_total 3",
+		`# HELP queue_depth Items waiting\nto be processed`,
+		"# TYPE queue_depth gauge",
+		"queue_depth 2.5",
+		`# HELP users Registered users, see C:\\docs`,
+		"# TYPE users gauge",
+		"users 7",
+	}, "\n") + "\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected %q, got %q", ContentType, ct)
+	}
+}
+
+func TestRegistry_RejectsBadRegistrations(t *testing.T) {
+	expectPanic := func(name string, register func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected %s to panic", name)
+			}
+		}()
+		register()
+	}
+
+	r := NewRegistry()
+	r.NewCounter("dup_total", "")
+	expectPanic("a duplicate name", func() { r.NewGauge("dup_total", "") })
+	expectPanic("an invalid name", func() { r.NewCounter("bad-name", "") })
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+
+	"common/apierror"
+)
+
+// AdminTokenHeader carries the shared secret for admin endpoints
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminToken returns middleware that only lets through requests whose
+// X-Admin-Token header matches token. Others get a 403.
+func AdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get(AdminTokenHeader)
+			if given == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				apierror.Error(w, "admin token required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AdminFromEnv returns AdminToken configured with the token in the given
+// environment variable. Unlike AuthFromEnv, admin endpoints are closed when
+// the variable is unset: they expose and replace the whole service state.
+func AdminFromEnv(envVar string) func(http.Handler) http.Handler {
+	token := os.Getenv(envVar)
+	if token == "" {
+		log.Printf("%s not set, admin endpoints are disabled", envVar)
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				apierror.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+			})
+		}
+	}
+	return AdminToken(token)
+}
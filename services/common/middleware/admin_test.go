@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminToken(t *testing.T) {
+	handler := AdminToken("s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"missing", "", http.StatusForbidden},
+		{"wrong", "guess", http.StatusForbidden},
+		{"valid", "s3cret", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/state", nil)
+			if tt.token != "" {
+				req.Header.Set(AdminTokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminFromEnv_DisabledWhenUnset(t *testing.T) {
+	t.Setenv("TEST_ADMIN_TOKEN", "")
+	handler := AdminFromEnv("TEST_ADMIN_TOKEN")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the handler not to run")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/state", nil)
+	req.Header.Set(AdminTokenHeader, "")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
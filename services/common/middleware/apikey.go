@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"common/apierror"
+)
+
+// APIKeyHeader carries the API key on requests authenticated by APIKeyAuth
+const APIKeyHeader = "X-API-Key"
+
+var (
+	// ErrMissingAPIKey is returned when a request has no API key
+	ErrMissingAPIKey = errors.New("missing api key")
+	// ErrInvalidAPIKey is returned for unknown or revoked keys
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrQuotaExceeded is returned once a key has used its daily quota
+	ErrQuotaExceeded = errors.New("daily api key quota exceeded")
+)
+
+// apiKey is an issued key's owner and its usage for the current day
+type apiKey struct {
+	userID string
+	day    string // UTC date the usage count belongs to
+	used   int64
+}
+
+// APIKeyStore issues API keys and counts each key's requests against a
+// daily quota that resets at midnight UTC. Only hashes of the keys are
+// kept.
+type APIKeyStore struct {
+	mu         sync.Mutex
+	keys       map[string]*apiKey // hashed key -> key
+	dailyQuota int64
+	now        func() time.Time
+}
+
+// NewAPIKeyStore creates a store allowing each key dailyQuota requests a
+// day. A quota of zero or less means unlimited.
+func NewAPIKeyStore(dailyQuota int64) *APIKeyStore {
+	return &APIKeyStore{
+		keys:       make(map[string]*apiKey),
+		dailyQuota: dailyQuota,
+		now:        time.Now,
+	}
+}
+
+// IssueKey creates a new key for userID. The key is only returned here;
+// the store can't recover it later.
+func (s *APIKeyStore) IssueKey(userID string) (string, error) {
+	if userID == "" {
+		return "", errors.New("user id is required")
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	key := "ak_" + hex.EncodeToString(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[hashAPIKey(key)] = &apiKey{userID: userID}
+
+	return key, nil
+}
+
+// RevokeKey invalidates key. Requests using it get a 401 afterwards.
+func (s *APIKeyStore) RevokeKey(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashed := hashAPIKey(key)
+	if _, exists := s.keys[hashed]; !exists {
+		return ErrInvalidAPIKey
+	}
+	delete(s.keys, hashed)
+	return nil
+}
+
+// Use counts one request against key and returns its owner and how many
+// requests it has left today, or -1 when the quota is unlimited
+func (s *APIKeyStore) Use(key string) (userID string, remaining int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, exists := s.keys[hashAPIKey(key)]
+	if !exists {
+		return "", 0, ErrInvalidAPIKey
+	}
+
+	if today := s.now().UTC().Format(time.DateOnly); k.day != today {
+		k.day = today
+		k.used = 0
+	}
+
+	if s.dailyQuota <= 0 {
+		k.used++
+		return k.userID, -1, nil
+	}
+	if k.used >= s.dailyQuota {
+		return k.userID, 0, ErrQuotaExceeded
+	}
+	k.used++
+	return k.userID, s.dailyQuota - k.used, nil
+}
+
+// untilReset returns the time left before quotas reset
+func (s *APIKeyStore) untilReset() time.Duration {
+	now := s.now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyAuth returns middleware that requires a valid X-API-Key header and
+// stores the key owner's user ID in the request context. Missing, unknown
+// and revoked keys get a 401; keys over their daily quota get a 429 with a
+// Retry-After of the time until the quota resets.
+func APIKeyAuth(store *APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				apierror.Error(w, ErrMissingAPIKey.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			userID, remaining, err := store.Use(key)
+			switch {
+			case errors.Is(err, ErrQuotaExceeded):
+				w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(store.dailyQuota, 10))
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("Retry-After", strconv.Itoa(int(store.untilReset().Seconds())+1))
+				apierror.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			case err != nil:
+				apierror.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if remaining >= 0 {
+				w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(store.dailyQuota, 10))
+				w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithUserID(r.Context(), userID)))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func keyedRequest(handler http.Handler, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func apiKeyHandler(store *APIKeyStore) http.Handler {
+	return APIKeyAuth(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		w.Write([]byte(userID))
+	}))
+}
+
+func TestAPIKeyAuth_IssueAndAuthenticate(t *testing.T) {
+	store := NewAPIKeyStore(10)
+	key, err := store.IssueKey("user1")
+	if err != nil || !strings.HasPrefix(key, "ak_") {
+		t.Fatalf("Expected a key, got %q, %v", key, err)
+	}
+	if other, _ := store.IssueKey("user1"); other == key {
+		t.Error("Expected every issued key to be unique")
+	}
+	if _, err := store.IssueKey(""); err == nil {
+		t.Error("Expected an error issuing a key without a user")
+	}
+
+	handler := apiKeyHandler(store)
+	w := keyedRequest(handler, key)
+	if w.Code != http.StatusOK || w.Body.String() != "user1" {
+		t.Fatalf("Expected user1 authenticated, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-RateLimit-Remaining") != "9" {
+		t.Errorf("Expected 9 requests remaining, got %q", w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	for _, bad := range []string{"", "ak_unknown"} {
+		if w := keyedRequest(handler, bad); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for key %q, got %d", bad, w.Code)
+		}
+	}
+}
+
+func TestAPIKeyAuth_DailyQuota(t *testing.T) {
+	store := NewAPIKeyStore(2)
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	key, _ := store.IssueKey("user1")
+	handler := apiKeyHandler(store)
+
+	for i := 0; i < 2; i++ {
+		if w := keyedRequest(handler, key); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i+1, w.Code)
+		}
+	}
+
+	w := keyedRequest(handler, key)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 over quota, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "3601" {
+		t.Errorf("Expected Retry-After until midnight, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// Quotas reset the next day
+	now = now.Add(2 * time.Hour)
+	if w := keyedRequest(handler, key); w.Code != http.StatusOK {
+		t.Errorf("Expected the quota reset the next day, got %d", w.Code)
+	}
+
+	// Each key has its own quota
+	other, _ := store.IssueKey("user1")
+	if w := keyedRequest(handler, other); w.Code != http.StatusOK {
+		t.Errorf("Expected a fresh key to have its own quota, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyAuth_Revocation(t *testing.T) {
+	store := NewAPIKeyStore(0)
+	key, _ := store.IssueKey("user1")
+	handler := apiKeyHandler(store)
+
+	w := keyedRequest(handler, key)
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("Expected an unlimited key without rate limit headers, got %d %v", w.Code, w.Header())
+	}
+
+	if err := store.RevokeKey(key); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := store.RevokeKey(key); err != ErrInvalidAPIKey {
+		t.Errorf("Expected ErrInvalidAPIKey revoking twice, got %v", err)
+	}
+	if w := keyedRequest(handler, key); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 after revocation, got %d", w.Code)
+	}
+}
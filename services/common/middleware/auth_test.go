@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+func authedRequest(t *testing.T, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := JWTAuth(testSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		w.Write([]byte(userID))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestJWTAuth_ValidToken(t *testing.T) {
+	token, err := SignToken("user1", time.Hour, testSecret)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	w := authedRequest(t, token)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Body.String() != "user1" {
+		t.Errorf("Expected user1 in context, got %q", w.Body.String())
+	}
+}
+
+func TestJWTAuth_ExpiredToken(t *testing.T) {
+	token, _ := SignToken("user1", -time.Minute, testSecret)
+
+	w := authedRequest(t, token)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), ErrExpiredToken.Error()) {
+		t.Errorf("Expected expired error, got %q", w.Body.String())
+	}
+}
+
+func TestJWTAuth_TamperedSignature(t *testing.T) {
+	token, _ := SignToken("user1", time.Hour, testSecret)
+	other, _ := SignToken("admin", time.Hour, testSecret)
+
+	// Swap in another token's claims while keeping the original signature
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+
+	if w := authedRequest(t, forged); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for forged claims, got %d", w.Code)
+	}
+
+	wrongKey, _ := SignToken("user1", time.Hour, []byte("other-secret"))
+	if w := authedRequest(t, wrongKey); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for wrong key, got %d", w.Code)
+	}
+}
+
+func TestJWTAuth_MissingOrMalformed(t *testing.T) {
+	for _, token := range []string{"", "not-a-jwt", "a.b.c"} {
+		if w := authedRequest(t, token); w.Code != http.StatusUnauthorized {
+			t.Errorf("Token %q: expected status 401, got %d", token, w.Code)
+		}
+	}
+}
+
+func TestAuthenticatedUserID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if got := AuthenticatedUserID(req, "body-user"); got != "body-user" {
+		t.Errorf("Expected fallback, got %q", got)
+	}
+
+	req = req.WithContext(ContextWithUserID(req.Context(), "token-user"))
+	if got := AuthenticatedUserID(req, "body-user"); got != "token-user" {
+		t.Errorf("Expected token user, got %q", got)
+	}
+}
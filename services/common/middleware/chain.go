@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+)
+
+// Middleware wraps a handler. Every middleware in this package returns one.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in mws so they see a request in the order given: the first
+// middleware is outermost and runs first, the last runs just before h.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Standard returns the middleware every service wraps its whole mux in, in
+// the order they run:
+//
+//  1. RequestID, so everything after it can read the request's ID
+//  2. Logging, which logs that ID and times the rest of the chain
+//
+// Per-route middleware such as auth, body limits and idempotency runs
+// inside these, after the mux has picked the route.
+func Standard(logger *log.Logger) []Middleware {
+	return []Middleware{
+		RequestID(),
+		Logging(logger),
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recording returns middleware that appends name to seq on the way in and
+// "/"+name on the way out
+func recording(seq *[]string, name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*seq = append(*seq, name)
+			next.ServeHTTP(w, r)
+			*seq = append(*seq, "/"+name)
+		})
+	}
+}
+
+func TestChain_RunsInOrder(t *testing.T) {
+	var seq []string
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seq = append(seq, "handler")
+	}), recording(&seq, "a"), recording(&seq, "b"), recording(&seq, "c"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"a", "b", "c", "handler", "/c", "/b", "/a"}
+	if !reflect.DeepEqual(seq, want) {
+		t.Errorf("Expected %v, got %v", want, seq)
+	}
+}
+
+func TestChain_NoMiddleware(t *testing.T) {
+	handler := Chain(jsonHandler(`{}`))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.String() != `{}` {
+		t.Errorf("Expected the bare handler, got %q", w.Body)
+	}
+}
+
+func TestStandard_RequestIDBeforeLogging(t *testing.T) {
+	var logs bytes.Buffer
+	var seen string
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = RequestIDFromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}), Standard(log.New(&logs, "", 0))...)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/brew", nil))
+
+	id := w.Header().Get(RequestIDHeader)
+	if id == "" || id != seen {
+		t.Fatalf("Expected the handler to see the response's request ID %q, got %q", id, seen)
+	}
+	if line := logs.String(); !strings.HasPrefix(line, "POST /brew 418 ") || !strings.Contains(line, "id="+id) {
+		t.Errorf("Expected the log line to carry the status and request ID, got %q", line)
+	}
+}
+
+func TestRequestID_KeepsClientID(t *testing.T) {
+	handler := RequestID()(jsonHandler(`{}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "trace-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); got != "trace-123" {
+		t.Errorf("Expected the client's ID to be kept, got %q", got)
+	}
+
+	req.Header.Set(RequestIDHeader, strings.Repeat("x", maxRequestIDLength+1))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); len(got) > maxRequestIDLength {
+		t.Errorf("Expected an oversized ID to be replaced, got %q", got)
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultGzipMinBytes is the smallest response worth compressing
+const DefaultGzipMinBytes = 1024
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// Gzip returns middleware that compresses responses of at least minBytes
+// when the client accepts gzip. The first minBytes are buffered to make the
+// decision, so smaller responses go out unchanged. Responses that already
+// carry a Content-Encoding are never compressed again.
+func Gzip(minBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipWriter{ResponseWriter: w, minBytes: minBytes}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip without q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// gzipWriter holds back the start of the response until it knows whether
+// the body is large enough to compress
+type gzipWriter struct {
+	http.ResponseWriter
+	minBytes int
+	status   int
+	buf      []byte
+	decided  bool
+	gz       *gzip.Writer
+}
+
+func (gw *gzipWriter) WriteHeader(status int) {
+	if gw.status == 0 {
+		gw.status = status
+	}
+}
+
+func (gw *gzipWriter) Write(p []byte) (int, error) {
+	if gw.decided {
+		return gw.write(p)
+	}
+
+	gw.buf = append(gw.buf, p...)
+	if len(gw.buf) >= gw.minBytes {
+		if err := gw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what has been written so far. A flush before the threshold is
+// reached commits to an uncompressed response.
+func (gw *gzipWriter) Flush() {
+	if !gw.decided {
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// decide writes the headers, compressing if asked and allowed, then
+// releases the buffered bytes
+func (gw *gzipWriter) decide(compress bool) error {
+	gw.decided = true
+
+	status := gw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := gw.Header()
+	if compress && header.Get("Content-Encoding") == "" &&
+		status != http.StatusNoContent && status != http.StatusNotModified {
+		if header.Get("Content-Type") == "" {
+			// Sniff before compressing, net/http would sniff the gzip bytes
+			header.Set("Content-Type", http.DetectContentType(gw.buf))
+		}
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+
+		gw.gz = gzipWriters.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+
+	gw.ResponseWriter.WriteHeader(status)
+
+	buf := gw.buf
+	gw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := gw.write(buf)
+	return err
+}
+
+func (gw *gzipWriter) write(p []byte) (int, error) {
+	if gw.gz != nil {
+		return gw.gz.Write(p)
+	}
+	return gw.ResponseWriter.Write(p)
+}
+
+// close sends a response that never reached the threshold and finishes the
+// gzip stream
+func (gw *gzipWriter) close() {
+	if !gw.decided {
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+		gw.gz.Reset(nil)
+		gzipWriters.Put(gw.gz)
+		gw.gz = nil
+	}
+}
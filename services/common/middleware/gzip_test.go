@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+}
+
+func gzipRequest(acceptEncoding string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	return req
+}
+
+func TestGzip_CompressesLargeResponse(t *testing.T) {
+	body := "[" + strings.Repeat(`{"id":"item"},`, 500) + `{"id":"last"}]`
+	handler := Gzip(DefaultGzipMinBytes)(jsonHandler(body))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, gzipRequest("br, gzip"))
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected Content-Type to be kept, got %q", w.Header().Get("Content-Type"))
+	}
+	if w.Body.Len() >= len(body) {
+		t.Errorf("Expected a smaller body, got %d bytes for %d", w.Body.Len(), len(body))
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip stream, got %v", err)
+	}
+	decoded, _ := io.ReadAll(reader)
+	if string(decoded) != body {
+		t.Error("Expected the decompressed body to match the original")
+	}
+}
+
+func TestGzip_LeavesResponseUncompressed(t *testing.T) {
+	large := strings.Repeat("a", 4096)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+	}{
+		{"not requested", "", large},
+		{"refused", "gzip;q=0", large},
+		{"below threshold", "gzip", "small"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Gzip(DefaultGzipMinBytes)(jsonHandler(tt.body))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, gzipRequest(tt.acceptEncoding))
+
+			if w.Header().Get("Content-Encoding") != "" {
+				t.Errorf("Expected no Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+			}
+			if w.Body.String() != tt.body {
+				t.Error("Expected the body to pass through unchanged")
+			}
+		})
+	}
+}
+
+func TestGzip_DoesNotDoubleCompress(t *testing.T) {
+	body := strings.Repeat("a", 4096)
+	handler := Gzip(DefaultGzipMinBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte(body))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, gzipRequest("gzip, br"))
+
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != body {
+		t.Error("Expected an already encoded response to pass through")
+	}
+}
+
+func TestGzip_StatusAndFlush(t *testing.T) {
+	chunk := strings.Repeat("b", 2048)
+	handler := Gzip(DefaultGzipMinBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(chunk))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(chunk))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, gzipRequest("gzip"))
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+	if !w.Flushed {
+		t.Error("Expected Flush to reach the underlying writer")
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip stream, got %v", err)
+	}
+	decoded, _ := io.ReadAll(reader)
+	if string(decoded) != chunk+chunk {
+		t.Error("Expected both chunks after decompression")
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"common/apierror"
+	"common/cache"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header clients set to make a POST
+	// safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on responses served from the store
+	IdempotentReplayHeader = "Idempotent-Replayed"
+	// DefaultIdempotencyTTL is how long a stored response can be replayed
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKeyLength = 255
+)
+
+// storedResponse is a captured response and the body that produced it
+type storedResponse struct {
+	status      int
+	header      http.Header
+	body        []byte
+	fingerprint string
+}
+
+// IdempotencyStore holds captured responses by key and tracks requests that
+// are still running so a concurrent retry can't slip through
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	responses *cache.TTLCache[string, *storedResponse]
+	inFlight  map[string]bool
+}
+
+// NewIdempotencyStore creates a store whose responses are replayed for ttl
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		responses: cache.NewTTLCache[string, *storedResponse](ttl),
+		inFlight:  make(map[string]bool),
+	}
+}
+
+// StartJanitor purges expired responses every interval
+func (s *IdempotencyStore) StartJanitor(interval time.Duration) {
+	s.responses.StartJanitor(interval, nil)
+}
+
+// Metrics reports hits and misses on the stored responses
+func (s *IdempotencyStore) Metrics() cache.Metrics {
+	return s.responses.Metrics()
+}
+
+// Idempotency returns middleware that honours the Idempotency-Key header.
+// The first response for a key is stored and replayed verbatim for retries
+// with the same key instead of running the handler again. Keys are scoped
+// to the method, path and authenticated user. Reusing a key with a
+// different body gets a 422, and a retry that arrives while the first
+// request is still running gets a 409. 5xx responses are not stored so
+// they can be retried. Requests without the header pass straight through.
+func Idempotency(store *IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				apierror.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = io.ReadAll(r.Body); err != nil {
+					apierror.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			sum := sha256.Sum256(body)
+			fingerprint := hex.EncodeToString(sum[:])
+
+			userID, _ := UserIDFromContext(r.Context())
+			scope := r.Method + " " + r.URL.Path + " " + userID + " " + key
+
+			store.mu.Lock()
+			if store.inFlight[scope] {
+				store.mu.Unlock()
+				apierror.Error(w, "a request with this Idempotency-Key is in progress", http.StatusConflict)
+				return
+			}
+			if stored, ok := store.responses.Get(scope); ok {
+				store.mu.Unlock()
+				if stored.fingerprint != fingerprint {
+					apierror.Error(w, "Idempotency-Key was used with a different request body", http.StatusUnprocessableEntity)
+					return
+				}
+				replay(w, stored)
+				return
+			}
+			store.inFlight[scope] = true
+			store.mu.Unlock()
+
+			capture := &captureWriter{ResponseWriter: w}
+			defer func() {
+				store.mu.Lock()
+				defer store.mu.Unlock()
+				delete(store.inFlight, scope)
+				if capture.status != 0 && capture.status < http.StatusInternalServerError {
+					store.responses.Set(scope, &storedResponse{
+						status:      capture.status,
+						header:      capture.header,
+						body:        capture.body.Bytes(),
+						fingerprint: fingerprint,
+					})
+				}
+			}()
+
+			next.ServeHTTP(capture, r)
+			if capture.status == 0 {
+				capture.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, stored *storedResponse) {
+	for name, values := range stored.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(stored.status)
+	w.Write(stored.body)
+}
+
+// captureWriter passes the response through while keeping a copy of it
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+	cw.header = cw.ResponseWriter.Header().Clone()
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.body.Write(p)
+	return cw.ResponseWriter.Write(p)
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler creates a "resource" per call and echoes its number
+func countingHandler(calls *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":"resource_%d"}`, n)
+	})
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	var calls int64
+	handler := Idempotency(NewIdempotencyStore(time.Minute))(countingHandler(&calls))
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, idempotentRequest("key-1", `{"a":1}`))
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, idempotentRequest("key-1", `{"a":1}`))
+
+	if calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("Expected identical responses, got %d %q and %d %q", first.Code, first.Body.String(), second.Code, second.Body.String())
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Error("Expected stored headers to be replayed")
+	}
+	if second.Header().Get(IdempotentReplayHeader) != "true" || first.Header().Get(IdempotentReplayHeader) != "" {
+		t.Error("Expected only the replay to be marked")
+	}
+}
+
+func TestIdempotency_KeyReusedWithDifferentBody(t *testing.T) {
+	var calls int64
+	handler := Idempotency(NewIdempotencyStore(time.Minute))(countingHandler(&calls))
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{"a":1}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest("key-1", `{"a":2}`))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_WithoutKeyOrAcrossUsers(t *testing.T) {
+	var calls int64
+	handler := Idempotency(NewIdempotencyStore(time.Minute))(countingHandler(&calls))
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", `{}`))
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", `{}`))
+	if calls != 2 {
+		t.Errorf("Expected requests without a key to always run, ran %d times", calls)
+	}
+
+	for _, user := range []string{"alice", "bob"} {
+		req := idempotentRequest("shared", `{}`)
+		req = req.WithContext(ContextWithUserID(req.Context(), user))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 4 {
+		t.Errorf("Expected keys to be scoped per user, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_ServerErrorsNotStored(t *testing.T) {
+	var calls int64
+	handler := Idempotency(NewIdempotencyStore(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{}`))
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{}`))
+
+	if calls != 2 {
+		t.Errorf("Expected a failed request to be retryable, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_InFlightConflict(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := Idempotency(NewIdempotencyStore(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{}`))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest("key-1", `{}`))
+	close(release)
+	<-done
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while the first request runs, got %d", w.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"common/apierror"
+)
+
+const (
+	// DefaultMaxBodyBytes is the request body cap used by the services
+	DefaultMaxBodyBytes = 1 << 20
+	// DefaultRequestTimeout bounds reading the body and running the handler
+	DefaultRequestTimeout = 10 * time.Second
+)
+
+// LimitRequest returns middleware that caps the request body at maxBytes and
+// bounds the whole request by timeout. The body is read up front: oversized
+// bodies get a 413 and bodies that don't arrive in time get a 408. If the
+// handler itself runs past the deadline the client gets a 504 and any later
+// writes by the handler are discarded. Handlers see the deadline on
+// r.Context().
+func LimitRequest(maxBytes int64, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				apierror.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			body, err := readBody(ctx, w, r.Body, maxBytes)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				switch {
+				case errors.As(err, &tooLarge):
+					apierror.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				case errors.Is(err, context.DeadlineExceeded):
+					apierror.Error(w, "timed out reading request body", http.StatusRequestTimeout)
+				default:
+					apierror.Error(w, err.Error(), http.StatusBadRequest)
+				}
+				return
+			}
+
+			r = r.WithContext(ctx)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+
+			serveWithTimeout(ctx, w, r, next)
+		})
+	}
+}
+
+// readBody reads at most maxBytes of the body, giving up when ctx is done
+func readBody(ctx context.Context, w http.ResponseWriter, body io.ReadCloser, maxBytes int64) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		data, err := io.ReadAll(http.MaxBytesReader(w, body, maxBytes))
+		done <- result{data: data, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// serveWithTimeout runs the handler, answering 504 if it doesn't finish
+// before ctx is done
+func serveWithTimeout(ctx context.Context, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	tw := &timeoutWriter{header: make(http.Header)}
+
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		next.ServeHTTP(tw, r)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		for key, values := range tw.header {
+			w.Header()[key] = values
+		}
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+		w.WriteHeader(tw.status)
+		w.Write(tw.body.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+		apierror.Error(w, "request timed out", http.StatusGatewayTimeout)
+	}
+}
+
+// timeoutWriter buffers a handler's response until it completes
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+}
+
+func TestLimitRequest_PassesBody(t *testing.T) {
+	handler := LimitRequest(16, time.Second)(http.HandlerFunc(echoHandler))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+	if w.Body.String() != "hello" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected handler response to pass through, got %q", w.Body.String())
+	}
+}
+
+func TestLimitRequest_Oversized(t *testing.T) {
+	handler := LimitRequest(4, time.Second)(http.HandlerFunc(echoHandler))
+
+	// Declared length over the limit
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too large")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+
+	// Unknown length, as with chunked encoding
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("too large")))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for chunked body, got %d", w.Code)
+	}
+}
+
+func TestLimitRequest_SlowBody(t *testing.T) {
+	handler := LimitRequest(1024, 50*time.Millisecond)(http.HandlerFunc(echoHandler))
+
+	body, writer := io.Pipe()
+	defer writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestTimeout {
+		t.Errorf("Expected status 408, got %d", w.Code)
+	}
+}
+
+func TestLimitRequest_SlowHandler(t *testing.T) {
+	handler := LimitRequest(1024, 50*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("too late"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "too late") {
+		t.Error("Expected late handler output to be discarded")
+	}
+}
@@ -0,0 +1,96 @@
+// Package moderation checks user-submitted text before services store it
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxLength is the longest text, in characters, the default filter
+// accepts
+const DefaultMaxLength = 10000
+
+// ErrRejected is wrapped by Check's error when a filter blocks content.
+// Handlers map it to 422 Unprocessable Entity.
+var ErrRejected = errors.New("content rejected")
+
+// ContentFilter decides whether text may be stored. When it may not, reason
+// explains why and is shown to the caller.
+type ContentFilter interface {
+	Check(text string) (allowed bool, reason string)
+}
+
+// FilterFunc adapts a plain function to ContentFilter
+type FilterFunc func(text string) (bool, string)
+
+// Check calls f(text)
+func (f FilterFunc) Check(text string) (bool, string) {
+	return f(text)
+}
+
+// WordFilter blocks text containing any banned word, matched as a whole
+// word and ignoring case, and text longer than MaxLength characters. A zero
+// MaxLength disables the length check.
+type WordFilter struct {
+	MaxLength int
+	banned    map[string]bool
+}
+
+// NewWordFilter returns a WordFilter with the given length limit and
+// banned words
+func NewWordFilter(maxLength int, bannedWords ...string) *WordFilter {
+	banned := make(map[string]bool, len(bannedWords))
+	for _, word := range bannedWords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			banned[word] = true
+		}
+	}
+	return &WordFilter{MaxLength: maxLength, banned: banned}
+}
+
+// Check implements ContentFilter
+func (f *WordFilter) Check(text string) (bool, string) {
+	if f.MaxLength > 0 {
+		if n := utf8.RuneCountInString(text); n > f.MaxLength {
+			return false, fmt.Sprintf("content is %d characters, the limit is %d", n, f.MaxLength)
+		}
+	}
+
+	if len(f.banned) == 0 {
+		return true, ""
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if f.banned[word] {
+			return false, fmt.Sprintf("content contains the banned word %q", word)
+		}
+	}
+	return true, ""
+}
+
+// FromEnv returns a WordFilter with DefaultMaxLength and the comma-separated
+// banned words in the given environment variable
+func FromEnv(envVar string) *WordFilter {
+	return NewWordFilter(DefaultMaxLength, strings.Split(os.Getenv(envVar), ",")...)
+}
+
+// Check runs filter over each text and returns an error wrapping ErrRejected
+// with the filter's reason for the first one it blocks. A nil filter allows
+// everything.
+func Check(filter ContentFilter, texts ...string) error {
+	if filter == nil {
+		return nil
+	}
+	for _, text := range texts {
+		if allowed, reason := filter.Check(text); !allowed {
+			return fmt.Errorf("%w: %s", ErrRejected, reason)
+		}
+	}
+	return nil
+}
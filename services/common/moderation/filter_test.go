@@ -0,0 +1,76 @@
+package moderation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWordFilter_Check(t *testing.T) {
+	filter := NewWordFilter(20, "spam", " Scam ")
+
+	tests := []struct {
+		name    string
+		text    string
+		allowed bool
+	}{
+		{"clean", "hello world", true},
+		{"banned word", "buy spam now", false},
+		{"case insensitive", "a SCAM!", false},
+		{"whole words only", "spammer", true},
+		{"at the limit", strings.Repeat("a", 20), true},
+		{"too long", strings.Repeat("a", 21), false},
+		{"counts characters not bytes", strings.Repeat("é", 20), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := filter.Check(tt.text)
+			if allowed != tt.allowed {
+				t.Fatalf("Expected allowed=%v, got %v (%s)", tt.allowed, allowed, reason)
+			}
+			if !allowed && reason == "" {
+				t.Error("Expected a reason for rejected content")
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TEST_BANNED_WORDS", "foo, bar,,")
+	filter := FromEnv("TEST_BANNED_WORDS")
+
+	if allowed, _ := filter.Check("so bar"); allowed {
+		t.Error("Expected bar to be banned")
+	}
+	if allowed, _ := filter.Check("plain text"); !allowed {
+		t.Error("Expected plain text to be allowed")
+	}
+	if filter.MaxLength != DefaultMaxLength {
+		t.Errorf("Expected MaxLength %d, got %d", DefaultMaxLength, filter.MaxLength)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var seen []string
+	filter := FilterFunc(func(text string) (bool, string) {
+		seen = append(seen, text)
+		return text != "bad", "no bad things"
+	})
+
+	if err := Check(filter, "good", "fine"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	err := Check(filter, "good", "bad", "never checked")
+	if !errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "no bad things") {
+		t.Errorf("Expected ErrRejected with the reason, got %v", err)
+	}
+	if len(seen) != 4 {
+		t.Errorf("Expected checking to stop at the first rejection, saw %v", seen)
+	}
+
+	if err := Check(nil, "anything"); err != nil {
+		t.Errorf("Expected a nil filter to allow everything, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.0.3"
+
+// SpecPath is where Mount serves the document
+const SpecPath = "/openapi.json"
+
+// Param describes a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Route documents one method on a path. Request and Response are zero
+// values of the types the handler decodes and encodes; their schemas are
+// derived from the json struct tags.
+type Route struct {
+	Method    string
+	Path      string // defaults to the mux pattern; {name} segments are path parameters
+	Summary   string
+	Query     []Param
+	Request   interface{}
+	Response  interface{}
+	Responses map[int]string // status -> description; defaults to 200 OK
+}
+
+// Registry registers handlers on a mux and records their documentation so
+// the served spec always matches the routes that exist
+type Registry struct {
+	mu       sync.RWMutex
+	mux      *http.ServeMux
+	title    string
+	version  string
+	patterns []string
+	routes   []Route
+}
+
+// NewRegistry creates a registry that registers handlers on mux
+func NewRegistry(mux *http.ServeMux, title, version string) *Registry {
+	return &Registry{
+		mux:     mux,
+		title:   title,
+		version: version,
+	}
+}
+
+// Handle registers handler for pattern and documents each route it serves
+func (reg *Registry) Handle(pattern string, handler http.Handler, routes ...Route) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.mux.Handle(pattern, handler)
+	reg.patterns = append(reg.patterns, pattern)
+
+	for _, route := range routes {
+		if route.Path == "" {
+			route.Path = pattern
+		}
+		if route.Method == "" {
+			route.Method = http.MethodGet
+		}
+		reg.routes = append(reg.routes, route)
+	}
+}
+
+// HandleFunc is Handle for a plain handler function
+func (reg *Registry) HandleFunc(pattern string, handler http.HandlerFunc, routes ...Route) {
+	reg.Handle(pattern, handler, routes...)
+}
+
+// Mount serves the document at SpecPath
+func (reg *Registry) Mount() {
+	reg.HandleFunc(SpecPath, reg.ServeHTTP, Route{
+		Method:   http.MethodGet,
+		Summary:  "OpenAPI document for this service",
+		Response: map[string]interface{}{},
+	})
+}
+
+// Patterns returns every mux pattern registered through the registry
+func (reg *Registry) Patterns() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return append([]string(nil), reg.patterns...)
+}
+
+// Routes returns every documented route
+func (reg *Registry) Routes() []Route {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return append([]Route(nil), reg.routes...)
+}
+
+// ServeHTTP serves the OpenAPI document as JSON
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reg.Document())
+}
+
+// Document builds the OpenAPI document
+func (reg *Registry) Document() map[string]interface{} {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	paths := make(map[string]map[string]interface{})
+	for _, route := range reg.routes {
+		if paths[route.Path] == nil {
+			paths[route.Path] = make(map[string]interface{})
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = operation(route)
+	}
+
+	return map[string]interface{}{
+		"openapi": Version,
+		"info": map[string]string{
+			"title":   reg.title,
+			"version": reg.version,
+		},
+		"paths": paths,
+	}
+}
+
+func operation(route Route) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary": route.Summary,
+	}
+
+	var params []map[string]interface{}
+	for _, name := range pathParams(route.Path) {
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": "string"},
+		})
+	}
+	if len(route.Query) > 0 {
+		for _, param := range route.Query {
+			params = append(params, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"required":    param.Required,
+				"schema":      map[string]string{"type": "string"},
+			})
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(route.Request),
+		}
+	}
+
+	statuses := route.Responses
+	if len(statuses) == 0 {
+		statuses = map[int]string{http.StatusOK: "OK"}
+	}
+
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	responses := make(map[string]interface{}, len(codes))
+	bodyAttached := false
+	for _, code := range codes {
+		response := map[string]interface{}{"description": statuses[code]}
+		// The response body schema belongs to the first success status
+		if route.Response != nil && !bodyAttached && code >= 200 && code < 300 {
+			response["content"] = jsonContent(route.Response)
+			bodyAttached = true
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+func jsonContent(v interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": Schema(reflect.TypeOf(v)),
+		},
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Schema returns a JSON schema for a Go type, following json struct tags
+func Schema(t reflect.Type) map[string]interface{} {
+	return schema(t, make(map[reflect.Type]bool))
+}
+
+func schema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == rawMessageType {
+		// Arbitrary embedded JSON
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			if tag := field.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if parts := strings.Split(tag, ","); parts[0] != "" {
+					name = parts[0]
+				}
+			}
+			properties[name] = schema(field.Type, seen)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// pathParams returns the names of the {name} segments in a path template
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, segment[1:len(segment)-1])
+		}
+	}
+	return names
+}
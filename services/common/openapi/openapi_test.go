@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type createRequest struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags,omitempty"`
+	Count int      `json:"count"`
+}
+
+type item struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Children  []*item   `json:"children"`
+	secret    string
+	Ignored   string `json:"-"`
+}
+
+func noop(w http.ResponseWriter, r *http.Request) {}
+
+func newTestRegistry() (*http.ServeMux, *Registry) {
+	mux := http.NewServeMux()
+	reg := NewRegistry(mux, "Test Service", "1.0.0")
+	reg.HandleFunc("/items/create", noop, Route{
+		Method:    http.MethodPost,
+		Summary:   "Create an item",
+		Request:   createRequest{},
+		Response:  item{},
+		Responses: map[int]string{http.StatusOK: "Created", http.StatusBadRequest: "Invalid body"},
+	})
+	reg.HandleFunc("/items/get", noop, Route{
+		Summary:  "Get an item",
+		Query:    []Param{{Name: "id", Required: true}},
+		Response: item{},
+	})
+	reg.Mount()
+	return mux, reg
+}
+
+// document mirrors the parts of the OpenAPI 3 schema the registry emits
+type document struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths map[string]map[string]struct {
+		Summary    string `json:"summary"`
+		Parameters []struct {
+			Name string `json:"name"`
+			In   string `json:"in"`
+		} `json:"parameters"`
+		RequestBody *struct {
+			Content map[string]struct {
+				Schema map[string]interface{} `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+		Responses map[string]struct {
+			Description string `json:"description"`
+		} `json:"responses"`
+	} `json:"paths"`
+}
+
+func TestRegistry_ServesValidDocument(t *testing.T) {
+	mux, reg := newTestRegistry()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, SpecPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc document
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != Version || doc.Info.Title != "Test Service" || doc.Info.Version != "1.0.0" {
+		t.Errorf("Unexpected header fields: %+v", doc)
+	}
+
+	for _, pattern := range reg.Patterns() {
+		ops, exists := doc.Paths[pattern]
+		if !exists || len(ops) == 0 {
+			t.Errorf("Expected %s to be documented", pattern)
+			continue
+		}
+		for method, op := range ops {
+			if len(op.Responses) == 0 {
+				t.Errorf("%s %s: expected at least one response", method, pattern)
+			}
+		}
+	}
+
+	create := doc.Paths["/items/create"]["post"]
+	if create.RequestBody == nil {
+		t.Fatal("Expected request body on create")
+	}
+	if _, ok := create.Responses["400"]; !ok {
+		t.Error("Expected 400 response on create")
+	}
+	if get := doc.Paths["/items/get"]["get"]; len(get.Parameters) != 1 || get.Parameters[0].In != "query" {
+		t.Errorf("Expected query parameter on get, got %+v", get.Parameters)
+	}
+}
+
+func TestSchema_FollowsJSONTags(t *testing.T) {
+	s := Schema(reflect.TypeOf(item{}))
+
+	properties := s["properties"].(map[string]interface{})
+	if _, ok := properties["created_at"]; !ok {
+		t.Error("Expected json tag name to be used")
+	}
+	if _, ok := properties["secret"]; ok {
+		t.Error("Expected unexported field to be skipped")
+	}
+	if _, ok := properties["Ignored"]; ok {
+		t.Error("Expected json:\"-\" field to be skipped")
+	}
+
+	createdAt := properties["created_at"].(map[string]interface{})
+	if createdAt["format"] != "date-time" {
+		t.Errorf("Expected time.Time to be a date-time string, got %v", createdAt)
+	}
+
+	// Self-referencing types terminate
+	children := properties["children"].(map[string]interface{})
+	if children["type"] != "array" {
+		t.Errorf("Expected children to be an array, got %v", children)
+	}
+}
+
+func TestOperation_PathParameters(t *testing.T) {
+	op := operation(Route{
+		Method: http.MethodGet,
+		Path:   "/{short_url}",
+		Query:  []Param{{Name: "pw"}},
+	})
+
+	params := op["parameters"].([]map[string]interface{})
+	if len(params) != 2 {
+		t.Fatalf("Expected 2 parameters, got %d", len(params))
+	}
+	if params[0]["name"] != "short_url" || params[0]["in"] != "path" || params[0]["required"] != true {
+		t.Errorf("Expected a required short_url path parameter, got %v", params[0])
+	}
+	if params[1]["in"] != "query" {
+		t.Errorf("Expected pw to stay a query parameter, got %v", params[1])
+	}
+}
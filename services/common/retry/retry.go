@@ -0,0 +1,78 @@
+// Package retry runs an operation again with exponential backoff when it
+// fails, so a single transient error isn't treated as a real failure
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Policy says how many times to try an operation and how long to wait
+// between tries. The wait before try n+1 is BaseDelay * 2^(n-1), capped at
+// MaxDelay and spread by Jitter.
+type Policy struct {
+	Attempts  int           // total tries including the first; below 1 means 1
+	BaseDelay time.Duration // wait after the first failure
+	MaxDelay  time.Duration // longest wait; zero means no cap
+	Jitter    float64       // fraction, 0 to 1, each wait is randomly shifted by
+}
+
+// DefaultPolicy tries three times, waiting about 100ms and then 200ms
+func DefaultPolicy() Policy {
+	return Policy{
+		Attempts:  3,
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  2 * time.Second,
+		Jitter:    0.2,
+	}
+}
+
+// Once tries an operation a single time
+func Once() Policy {
+	return Policy{Attempts: 1}
+}
+
+// Delay returns how long to wait after the given failed try, counting from 1
+func (p Policy) Delay(attempt int) time.Duration {
+	if attempt < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	return delay
+}
+
+// Do calls fn until it succeeds, the policy runs out of tries or ctx is
+// done, and returns the last error
+func (p Policy) Do(ctx context.Context, fn func() error) error {
+	attempts := max(p.Attempts, 1)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(p.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+func TestDo_SucceedsAfterFailures(t *testing.T) {
+	p := Policy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errFlaky
+		}
+		return nil
+	})
+
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third try, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDo_GivesUp(t *testing.T) {
+	p := Policy{Attempts: 2, BaseDelay: time.Millisecond}
+
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return errFlaky
+	})
+
+	if err != errFlaky || calls != 2 {
+		t.Errorf("Expected the last error after 2 calls, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	Policy{}.Do(context.Background(), func() error {
+		calls++
+		return errFlaky
+	})
+	if calls != 1 {
+		t.Errorf("Expected a zero policy to try once, got %d", calls)
+	}
+}
+
+func TestDo_StopsWhenContextDone(t *testing.T) {
+	p := Policy{Attempts: 5, BaseDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		return errFlaky
+	})
+
+	if err != errFlaky || calls != 1 {
+		t.Errorf("Expected to stop waiting when the context ended, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d): expected %v, got %v", i+1, w, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Delay(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("Expected jittered delay within 50%%, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package validate checks request fields and reports every failure at
+// once, so a client can fix all of them in one round trip
+package validate
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"common/apierror"
+)
+
+// FieldError is one failed check
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is every failed check in a request. It renders as
+// "title: required; ttl_seconds: must be >= 0".
+type Errors []FieldError
+
+// Error implements error
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// APIError returns a 400 listing the field errors in its details
+func (e Errors) APIError() apierror.APIError {
+	return apierror.New(http.StatusBadRequest, e.Error()).WithDetails(map[string]Errors{"fields": e})
+}
+
+// Rule checks a string field and returns a message when it fails. Apart
+// from Required, rules accept the empty string so they can be used on
+// optional fields.
+type Rule func(value string) string
+
+// IntRule checks an integer field and returns a message when it fails
+type IntRule func(value int) string
+
+// Validator collects field errors
+type Validator struct {
+	errs Errors
+}
+
+// String checks value against rules, recording the first that fails
+func (v *Validator) String(field, value string, rules ...Rule) {
+	for _, rule := range rules {
+		if msg := rule(value); msg != "" {
+			v.errs = append(v.errs, FieldError{Field: field, Message: msg})
+			return
+		}
+	}
+}
+
+// Int checks value against rules, recording the first that fails
+func (v *Validator) Int(field string, value int, rules ...IntRule) {
+	for _, rule := range rules {
+		if msg := rule(value); msg != "" {
+			v.errs = append(v.errs, FieldError{Field: field, Message: msg})
+			return
+		}
+	}
+}
+
+// Err returns the collected Errors, or nil if every check passed
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Required rejects empty and whitespace-only values
+func Required(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "required"
+	}
+	return ""
+}
+
+// MaxLen rejects values longer than n characters
+func MaxLen(n int) Rule {
+	return func(value string) string {
+		if utf8.RuneCountInString(value) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
+
+// URL rejects values that aren't absolute http or https URLs
+func URL(value string) string {
+	if value == "" {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be an http or https URL"
+	}
+	return ""
+}
+
+// OneOf rejects values outside options
+func OneOf(options ...string) Rule {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	}
+}
+
+// Min rejects values below n
+func Min(n int) IntRule {
+	return func(value int) string {
+		if value < n {
+			return fmt.Sprintf("must be >= %d", n)
+		}
+		return ""
+	}
+}
+
+// Max rejects values above n
+func Max(n int) IntRule {
+	return func(value int) string {
+		if value > n {
+			return fmt.Sprintf("must be <= %d", n)
+		}
+		return ""
+	}
+}
+
+// OneOfInt rejects values outside options. Zero passes, since it means
+// unset for optional fields.
+func OneOfInt(options ...int) IntRule {
+	return func(value int) string {
+		if value == 0 {
+			return ""
+		}
+		names := make([]string, len(options))
+		for i, option := range options {
+			if value == option {
+				return ""
+			}
+			names[i] = strconv.Itoa(option)
+		}
+		return "must be one of " + strings.Join(names, ", ")
+	}
+}
+
+// Write sends err as a field error response when it is an Errors, and as a
+// plain 400 otherwise
+func Write(w http.ResponseWriter, err error) {
+	if errs, ok := err.(Errors); ok {
+		apierror.Write(w, errs.APIError())
+		return
+	}
+	apierror.Error(w, err.Error(), http.StatusBadRequest)
+}
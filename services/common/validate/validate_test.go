@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidator_CollectsEveryField(t *testing.T) {
+	var v Validator
+	v.String("title", "", Required, MaxLen(5))
+	v.String("body", "far too long", MaxLen(5))
+	v.String("link", "ftp://example.com", URL)
+	v.String("kind", "D", OneOf("A", "B"))
+	v.Int("ttl_seconds", -1, Min(0))
+	v.Int("depth", 11, Min(0), Max(10))
+	v.String("optional_link", "", URL, MaxLen(5))
+	v.Int("redirect_type", 307, OneOfInt(301, 302))
+	v.Int("unset_redirect_type", 0, OneOfInt(301, 302))
+
+	err := v.Err()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("Expected Errors, got %T", err)
+	}
+
+	want := Errors{
+		{"title", "required"},
+		{"body", "must be at most 5 characters"},
+		{"link", "must be an http or https URL"},
+		{"kind", "must be one of A, B"},
+		{"ttl_seconds", "must be >= 0"},
+		{"depth", "must be <= 10"},
+		{"redirect_type", "must be one of 301, 302"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %v", len(want), errs)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], errs[i])
+		}
+	}
+}
+
+func TestValidator_Valid(t *testing.T) {
+	var v Validator
+	v.String("title", "Hi", Required, MaxLen(5))
+	v.String("link", "https://example.com/a", Required, URL)
+	v.Int("ttl_seconds", 0, Min(0))
+
+	if err := v.Err(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	errs := Errors{{"title", "required"}, {"ttl_seconds", "must be >= 0"}}
+
+	w := httptest.NewRecorder()
+	Write(w, errs)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Details struct {
+				Fields Errors `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if body.Error.Message != "title: required; ttl_seconds: must be >= 0" {
+		t.Errorf("Unexpected message %q", body.Error.Message)
+	}
+	if len(body.Error.Details.Fields) != 2 {
+		t.Errorf("Expected both field errors in details, got %+v", body.Error.Details)
+	}
+}
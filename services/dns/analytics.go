@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"common/apierror"
+)
+
+const (
+	// defaultQueryLogSize is how many recent queries the query log keeps
+	defaultQueryLogSize = 1000
+
+	// maxTrackedDomains bounds the per-domain counters. Queries for domains
+	// seen after it is reached still count towards the totals.
+	maxTrackedDomains = 10000
+
+	// defaultTopDomains and defaultQueryLogLimit size /analytics when its
+	// top and limit params are absent
+	defaultTopDomains    = 10
+	defaultQueryLogLimit = 100
+)
+
+// QueryLogEntry is one Resolve call
+type QueryLogEntry struct {
+	Domain   string    `json:"domain"`
+	CacheHit bool      `json:"cache_hit"`
+	Found    bool      `json:"found"`
+	Time     time.Time `json:"time"`
+}
+
+// DomainStats counts the queries for one domain
+type DomainStats struct {
+	Domain  string `json:"domain"`
+	Queries int64  `json:"queries"`
+	Hits    int64  `json:"hits"`
+	Misses  int64  `json:"misses"`
+}
+
+// queryAnalytics tallies resolved queries per domain and keeps a ring
+// buffer of the most recent ones
+type queryAnalytics struct {
+	mu     sync.Mutex
+	counts map[string]*DomainStats
+	totals DomainStats
+
+	log  []QueryLogEntry
+	next int // slot the next query is written to
+	full bool
+}
+
+func newQueryAnalytics(logSize int) *queryAnalytics {
+	if logSize <= 0 {
+		logSize = defaultQueryLogSize
+	}
+	return &queryAnalytics{
+		counts: make(map[string]*DomainStats),
+		log:    make([]QueryLogEntry, logSize),
+	}
+}
+
+// record tallies a query and adds it to the log, evicting the oldest entry
+// once the log is full
+func (a *queryAnalytics) record(entry QueryLogEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats, tracked := a.counts[entry.Domain]
+	if !tracked && len(a.counts) < maxTrackedDomains {
+		stats = &DomainStats{Domain: entry.Domain}
+		a.counts[entry.Domain] = stats
+	}
+	for _, s := range []*DomainStats{stats, &a.totals} {
+		if s == nil {
+			continue
+		}
+		s.Queries++
+		if entry.CacheHit {
+			s.Hits++
+		} else {
+			s.Misses++
+		}
+	}
+
+	a.log[a.next] = entry
+	a.next = (a.next + 1) % len(a.log)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// top returns the n most queried domains, most queried first, ties broken
+// by domain
+func (a *queryAnalytics) top(n int) []DomainStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	all := make([]DomainStats, 0, len(a.counts))
+	for _, stats := range a.counts {
+		all = append(all, *stats)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Queries != all[j].Queries {
+			return all[i].Queries > all[j].Queries
+		}
+		return all[i].Domain < all[j].Domain
+	})
+
+	if n >= 0 && n < len(all) {
+		all = all[:n]
+	}
+	return all
+}
+
+// recent returns up to limit logged queries, newest first. A limit of 0 or
+// less returns the whole log.
+func (a *queryAnalytics) recent(limit int) []QueryLogEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := a.next
+	if a.full {
+		n = len(a.log)
+	}
+	if limit > 0 && limit < n {
+		n = limit
+	}
+
+	out := make([]QueryLogEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, a.log[(a.next-i+len(a.log))%len(a.log)])
+	}
+	return out
+}
+
+// summary returns the query, hit and miss counts across every domain
+func (a *queryAnalytics) summary() DomainStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.totals
+}
+
+// recordQuery adds a Resolve call to the service's analytics
+func (s *DNSService) recordQuery(domain string, record *DNSRecord, cacheHit bool) {
+	s.analytics.record(QueryLogEntry{
+		Domain:   domain,
+		CacheHit: cacheHit,
+		Found:    record != nil,
+		Time:     time.Now(),
+	})
+}
+
+// GetTopDomains returns the n most queried domains, most queried first
+func (s *DNSService) GetTopDomains(n int) []DomainStats {
+	return s.analytics.top(n)
+}
+
+// QueryLog returns up to limit of the most recent queries, newest first
+func (s *DNSService) QueryLog(limit int) []QueryLogEntry {
+	return s.analytics.recent(limit)
+}
+
+// AnalyticsReport is the body of /analytics
+type AnalyticsReport struct {
+	Queries    int64           `json:"queries"`
+	Hits       int64           `json:"hits"`
+	Misses     int64           `json:"misses"`
+	HitRate    float64         `json:"hit_rate"`
+	TopDomains []DomainStats   `json:"top_domains"`
+	Log        []QueryLogEntry `json:"log"`
+}
+
+func analyticsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	top, limit := defaultTopDomains, defaultQueryLogLimit
+	if v := query.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			apierror.Error(w, "top must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apierror.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	totals := service.analytics.summary()
+	report := AnalyticsReport{
+		Queries:    totals.Queries,
+		Hits:       totals.Hits,
+		Misses:     totals.Misses,
+		TopDomains: service.GetTopDomains(top),
+		Log:        service.QueryLog(limit),
+	}
+	if totals.Queries > 0 {
+		report.HitRate = float64(totals.Hits) / float64(totals.Queries)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
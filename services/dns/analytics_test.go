@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestAnalytics_TopDomains(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("a.com", "10.0.0.1", "A", 300)
+	service.AddRecord("b.com", "10.0.0.2", "A", 300)
+	service.AddRecord("c.com", "10.0.0.3", "A", 300)
+
+	for domain, n := range map[string]int{"a.com": 2, "b.com": 5, "c.com": 3, "missing.com": 1} {
+		for i := 0; i < n; i++ {
+			service.Resolve(domain)
+		}
+	}
+
+	var got []string
+	for _, stats := range service.GetTopDomains(3) {
+		got = append(got, fmt.Sprintf("%s=%d", stats.Domain, stats.Queries))
+	}
+	want := []string{"b.com=5", "c.com=3", "a.com=2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTopDomains(3) = %v, want %v", got, want)
+	}
+	if all := service.GetTopDomains(10); len(all) != 4 {
+		t.Errorf("GetTopDomains(10) returned %d domains, want 4", len(all))
+	}
+}
+
+func TestAnalytics_HitsAndMisses(t *testing.T) {
+	service := NewDNSService()
+
+	// AddRecordToSet doesn't populate the cache, so the first resolve misses
+	service.AddRecordToSet("example.com", "10.0.0.1", "A", 300)
+	for i := 0; i < 4; i++ {
+		service.Resolve("example.com")
+	}
+	service.Resolve("missing.com")
+
+	stats := service.GetTopDomains(1)[0]
+	if stats.Domain != "example.com" || stats.Queries != 4 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("example.com stats = %+v, want 4 queries, 3 hits, 1 miss", stats)
+	}
+
+	totals := service.analytics.summary()
+	if totals.Queries != 5 || totals.Hits != 3 || totals.Misses != 2 {
+		t.Errorf("totals = %+v, want 5 queries, 3 hits, 2 misses", totals)
+	}
+
+	log := service.QueryLog(2)
+	if len(log) != 2 || log[0].Domain != "missing.com" || log[0].Found || !log[1].CacheHit {
+		t.Errorf("QueryLog(2) = %+v, want missing.com then a hit for example.com", log)
+	}
+}
+
+func TestAnalytics_LogIsBounded(t *testing.T) {
+	service := NewDNSService()
+	service.analytics = newQueryAnalytics(3)
+
+	for i := 0; i < 5; i++ {
+		service.Resolve(fmt.Sprintf("host%d.com", i))
+	}
+
+	var got []string
+	for _, entry := range service.QueryLog(0) {
+		got = append(got, entry.Domain)
+	}
+	want := []string{"host4.com", "host3.com", "host2.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryLog(0) = %v, want %v", got, want)
+	}
+}
+
+func TestAnalytics_ConcurrentResolves(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 300)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				service.Resolve("example.com")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := service.GetTopDomains(1)[0]; stats.Queries != 1000 || stats.Hits+stats.Misses != 1000 {
+		t.Errorf("stats = %+v, want 1000 queries", stats)
+	}
+}
+
+func TestAnalyticsHandler(t *testing.T) {
+	service = NewDNSService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	service.AddRecord("example.com", "10.0.0.1", "A", 300)
+	service.Resolve("example.com")
+	service.Resolve("missing.com")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analytics?top=1&limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var report AnalyticsReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.Queries != 2 || report.Hits != 1 || report.HitRate != 0.5 {
+		t.Errorf("report totals = %+v", report)
+	}
+	if len(report.TopDomains) != 1 || len(report.Log) != 1 || report.Log[0].Domain != "missing.com" {
+		t.Errorf("report = %+v, want one top domain and the latest query", report)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analytics?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for limit=0, got %d", w.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"common/apierror"
+	"common/validate"
+)
+
+var (
+	// ErrRecordExists is returned by AddRecordIfAbsent when the domain
+	// already has records
+	ErrRecordExists = errors.New("domain already has a record")
+
+	// ErrRecordConflict is returned by UpdateRecordCAS when the domain's
+	// current record isn't the expected one
+	ErrRecordConflict = errors.New("current record does not match the expected record")
+)
+
+// AddRecordIfAbsent adds a DNS record only if the domain has no global
+// records yet, so concurrent writers can't clobber each other
+func (s *DNSService) AddRecordIfAbsent(domain, ipAddress, recordType string, ttl int) (*DNSRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records[domain]) > 0 {
+		return nil, ErrRecordExists
+	}
+	return s.setRecordLocked(domain, ipAddress, recordType, ttl), nil
+}
+
+// UpdateRecordCAS replaces the domain's record with updated only if its
+// current record matches expected on IP address, type and TTL. A nil
+// expected means the domain must have no record. A domain with a record
+// set matches on the first record in the set, and the whole set is
+// replaced.
+func (s *DNSService) UpdateRecordCAS(domain string, expected, updated *DNSRecord) (*DNSRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current *DNSRecord
+	if records := s.records[domain]; len(records) > 0 {
+		current = records[0]
+	}
+	if !sameRecord(current, expected) {
+		return nil, ErrRecordConflict
+	}
+	return s.setRecordLocked(domain, updated.IPAddress, updated.Type, updated.TTL), nil
+}
+
+// sameRecord reports whether two records hold the same answer, ignoring
+// when they were created
+func sameRecord(a, b *DNSRecord) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.IPAddress == b.IPAddress && a.Type == b.Type && a.TTL == b.TTL
+}
+
+// setRecordLocked makes a new record the domain's only global record and
+// caches it. Must be called with s.mu held.
+func (s *DNSService) setRecordLocked(domain, ipAddress, recordType string, ttl int) *DNSRecord {
+	now := time.Now()
+	record := &DNSRecord{
+		Domain:    domain,
+		IPAddress: ipAddress,
+		Type:      recordType,
+		TTL:       ttl,
+		CreatedAt: now,
+	}
+
+	s.records[domain] = []*DNSRecord{record}
+	s.cache[domain] = &cacheEntry{
+		record:    record,
+		storedAt:  now,
+		expiresAt: now.Add(time.Duration(ttl) * time.Second),
+	}
+	return record
+}
+
+// recordValue is the part of a record compared and written by /update-cas
+type recordValue struct {
+	IPAddress string `json:"ip_address"`
+	Type      string `json:"type"`
+	TTL       int    `json:"ttl"`
+}
+
+func (rv recordValue) validate(v *validate.Validator, prefix string) {
+	v.String(prefix+".ip_address", rv.IPAddress, validate.Required)
+	v.String(prefix+".type", rv.Type, validate.OneOf("A", "AAAA", "CNAME", "MX", "NS", "TXT"))
+	v.Int(prefix+".ttl", rv.TTL, validate.Min(0))
+}
+
+func (rv *recordValue) record() *DNSRecord {
+	if rv == nil {
+		return nil
+	}
+	return &DNSRecord{IPAddress: rv.IPAddress, Type: rv.Type, TTL: rv.TTL}
+}
+
+// updateCASRequest is the body of /update-cas. Leaving out Expected
+// requires the domain to have no record.
+type updateCASRequest struct {
+	Domain   string       `json:"domain"`
+	Expected *recordValue `json:"expected,omitempty"`
+	New      recordValue  `json:"new"`
+}
+
+func (req updateCASRequest) validate() error {
+	var v validate.Validator
+	v.String("domain", req.Domain, validate.Required)
+	if req.Expected != nil {
+		req.Expected.validate(&v, "expected")
+	}
+	req.New.validate(&v, "new")
+	return v.Err()
+}
+
+func addRecordIfAbsentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req addRecordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+	if req.Region != "" {
+		apierror.Error(w, "region is not supported for conditional adds", http.StatusBadRequest)
+		return
+	}
+
+	record, err := service.AddRecordIfAbsent(req.Domain, req.IPAddress, req.Type, req.TTL)
+	if errors.Is(err, ErrRecordExists) {
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+func updateRecordCASHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req updateCASRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	record, err := service.UpdateRecordCAS(req.Domain, req.Expected.record(), req.New.record())
+	if errors.Is(err, ErrRecordConflict) {
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
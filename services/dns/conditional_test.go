@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddRecordIfAbsent(t *testing.T) {
+	service := NewDNSService()
+
+	record, err := service.AddRecordIfAbsent("example.com", "10.0.0.1", "A", 300)
+	if err != nil || record.IPAddress != "10.0.0.1" {
+		t.Fatalf("Expected the record to be added, got %+v, %v", record, err)
+	}
+
+	if _, err := service.AddRecordIfAbsent("example.com", "10.0.0.2", "A", 300); !errors.Is(err, ErrRecordExists) {
+		t.Errorf("Expected ErrRecordExists, got %v", err)
+	}
+	if resolved, _ := service.Resolve("example.com"); resolved.IPAddress != "10.0.0.1" {
+		t.Errorf("Expected the original record to be kept, got %s", resolved.IPAddress)
+	}
+}
+
+func TestUpdateRecordCAS(t *testing.T) {
+	service := NewDNSService()
+	original, _ := service.AddRecord("example.com", "10.0.0.1", "A", 300)
+
+	stale := &DNSRecord{IPAddress: "10.0.0.9", Type: "A", TTL: 300}
+	updated := &DNSRecord{IPAddress: "10.0.0.2", Type: "A", TTL: 60}
+
+	if _, err := service.UpdateRecordCAS("example.com", stale, updated); !errors.Is(err, ErrRecordConflict) {
+		t.Errorf("Expected ErrRecordConflict for a stale expected record, got %v", err)
+	}
+	if resolved, _ := service.Resolve("example.com"); resolved.IPAddress != "10.0.0.1" {
+		t.Errorf("Expected a failed CAS to leave the record alone, got %s", resolved.IPAddress)
+	}
+
+	// A copy of the current record matches even though CreatedAt differs
+	expected := &DNSRecord{IPAddress: original.IPAddress, Type: original.Type, TTL: original.TTL}
+	if _, err := service.UpdateRecordCAS("example.com", expected, updated); err != nil {
+		t.Fatalf("Expected the CAS to succeed, got %v", err)
+	}
+	if resolved, _ := service.Resolve("example.com"); resolved.IPAddress != "10.0.0.2" || resolved.TTL != 60 {
+		t.Errorf("Expected the updated record, got %+v", resolved)
+	}
+
+	// Replaying the same CAS now fails, since the record has moved on
+	if _, err := service.UpdateRecordCAS("example.com", expected, updated); !errors.Is(err, ErrRecordConflict) {
+		t.Errorf("Expected a replayed CAS to conflict, got %v", err)
+	}
+
+	// A nil expected record only matches a domain without records
+	if _, err := service.UpdateRecordCAS("new.com", nil, updated); err != nil {
+		t.Errorf("Expected a CAS from absent to succeed, got %v", err)
+	}
+	if _, err := service.UpdateRecordCAS("new.com", nil, updated); !errors.Is(err, ErrRecordConflict) {
+		t.Errorf("Expected a CAS from absent to conflict once the domain exists, got %v", err)
+	}
+}
+
+func TestConditionalHandlers(t *testing.T) {
+	service = NewDNSService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	post := func(path, body string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+		return w.Code
+	}
+
+	add := `{"domain":"example.com","ip_address":"10.0.0.1","type":"A","ttl":300}`
+	if code := post("/add-if-absent", add); code != http.StatusOK {
+		t.Errorf("Expected 200 for the first add, got %d", code)
+	}
+	if code := post("/add-if-absent", add); code != http.StatusConflict {
+		t.Errorf("Expected 409 for the second add, got %d", code)
+	}
+
+	stale := `{"domain":"example.com","expected":{"ip_address":"10.0.0.9","type":"A","ttl":300},"new":{"ip_address":"10.0.0.2","type":"A","ttl":60}}`
+	if code := post("/update-cas", stale); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a stale CAS, got %d", code)
+	}
+	current := `{"domain":"example.com","expected":{"ip_address":"10.0.0.1","type":"A","ttl":300},"new":{"ip_address":"10.0.0.2","type":"A","ttl":60}}`
+	if code := post("/update-cas", current); code != http.StatusOK {
+		t.Errorf("Expected 200 for a matching CAS, got %d", code)
+	}
+	if code := post("/update-cas", `{"domain":"example.com","new":{"type":"A"}}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a CAS without a new IP, got %d", code)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// Health check defaults
+const (
+	// DefaultHealthCheckInterval is how often record IPs are checked when
+	// DNS_HEALTH_CHECK_INTERVAL is unset
+	DefaultHealthCheckInterval = 10 * time.Second
+	// healthCheckTimeout bounds a single check
+	healthCheckTimeout = 2 * time.Second
+)
+
+// TCPHealthChecker reports an IP healthy when a TCP connection to port on
+// it opens within timeout
+func TCPHealthChecker(port string, timeout time.Duration) HealthChecker {
+	return func(ipAddress string) bool {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ipAddress, port), timeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+}
+
+// healthCheckFromEnv builds the checker named by DNS_HEALTH_CHECK_PORT,
+// the TCP port every record IP is probed on, and the interval from
+// DNS_HEALTH_CHECK_INTERVAL. It returns a nil checker, leaving every IP
+// healthy, when DNS_HEALTH_CHECK_PORT is unset.
+func healthCheckFromEnv() (HealthChecker, time.Duration) {
+	port := os.Getenv("DNS_HEALTH_CHECK_PORT")
+	if port == "" {
+		return nil, 0
+	}
+
+	interval := DefaultHealthCheckInterval
+	if value := os.Getenv("DNS_HEALTH_CHECK_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid DNS_HEALTH_CHECK_INTERVAL %q, using %s", value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+	return TCPHealthChecker(port, healthCheckTimeout), interval
+}
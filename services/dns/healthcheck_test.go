@@ -0,0 +1,45 @@
+//go:build unit
+// +build unit
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTCPHealthChecker(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	if !TCPHealthChecker(port, time.Second)(host) {
+		t.Error("Expected a listening port to be healthy")
+	}
+
+	srv.Close()
+	if TCPHealthChecker(port, time.Second)(host) {
+		t.Error("Expected a closed port to be unhealthy")
+	}
+}
+
+func TestHealthCheckFromEnv(t *testing.T) {
+	t.Setenv("DNS_HEALTH_CHECK_PORT", "")
+	if checker, _ := healthCheckFromEnv(); checker != nil {
+		t.Error("Expected health checks off without a port")
+	}
+
+	t.Setenv("DNS_HEALTH_CHECK_PORT", "80")
+	t.Setenv("DNS_HEALTH_CHECK_INTERVAL", "3s")
+	if checker, interval := healthCheckFromEnv(); checker == nil || interval != 3*time.Second {
+		t.Errorf("Expected a checker every 3s, got %v", interval)
+	}
+
+	t.Setenv("DNS_HEALTH_CHECK_INTERVAL", "soon")
+	if _, interval := healthCheckFromEnv(); interval != DefaultHealthCheckInterval {
+		t.Errorf("Expected the default interval for a bad value, got %v", interval)
+	}
+}
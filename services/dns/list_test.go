@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestListRecordsHandler_StreamsLargeZone(t *testing.T) {
+	service = NewDNSService()
+	for i := 0; i < 2000; i++ {
+		service.AddRecord(fmt.Sprintf("host%d.example.com", i), fmt.Sprintf("10.0.%d.%d", i/256, i%256), "A", 60)
+	}
+
+	w := httptest.NewRecorder()
+	listRecordsHandler(w, httptest.NewRequest(http.MethodGet, "/list", nil))
+
+	if !json.Valid(w.Body.Bytes()) {
+		t.Fatal("Expected the streamed list to be valid JSON")
+	}
+
+	var streamed []DNSRecord
+	json.Unmarshal(w.Body.Bytes(), &streamed)
+
+	// ListRecords has no fixed order, so compare the buffered encoding by domain
+	buffered, _ := json.Marshal(service.ListRecords())
+	var want []DNSRecord
+	json.Unmarshal(buffered, &want)
+
+	byDomain := func(records []DNSRecord) {
+		sort.Slice(records, func(i, j int) bool { return records[i].Domain < records[j].Domain })
+	}
+	byDomain(streamed)
+	byDomain(want)
+	if len(streamed) != 2000 || !reflect.DeepEqual(streamed, want) {
+		t.Errorf("Expected the streamed list to match the buffered one, got %d records", len(streamed))
+	}
+}
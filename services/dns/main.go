@@ -2,39 +2,100 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/cache"
+	"common/health"
+	"common/jsonstream"
+	"common/middleware"
+	"common/openapi"
+	"common/validate"
 )
 
+// DefaultNegativeTTL is how long Resolve remembers that a domain has no
+// records
+const DefaultNegativeTTL = 5 * time.Second
+
 // DNSRecord represents a DNS record
 type DNSRecord struct {
 	Domain    string    `json:"domain"`
 	IPAddress string    `json:"ip_address"`
 	Type      string    `json:"type"` // A, AAAA, CNAME, MX, etc.
 	TTL       int       `json:"ttl"`
+	Region    string    `json:"region,omitempty"` // empty for global records
 	CreatedAt time.Time `json:"created_at"`
+	Degraded  bool      `json:"degraded,omitempty"`  // set when every IP for the domain is unhealthy
+	Forwarded bool      `json:"forwarded,omitempty"` // set on answers from the upstream resolver
+}
+
+// HealthChecker reports whether the host behind an IP address is healthy
+type HealthChecker func(ipAddress string) bool
+
+// ipHealth tracks the health of a single IP address
+type ipHealth struct {
+	healthy     bool
+	lastFailure time.Time
 }
 
 // DNSService manages DNS records
 type DNSService struct {
-	mu      sync.RWMutex
-	records map[string]*DNSRecord // domain -> record
-	cache   map[string]*cacheEntry
+	mu       sync.RWMutex
+	records  map[string][]*DNSRecord          // domain -> record set
+	regional map[string]map[string]*DNSRecord // domain -> region -> record
+	cache    map[string]*cacheEntry
+
+	// resolving coalesces concurrent cache misses for a domain so only
+	// one of them runs lookup
+	resolving cache.Group[string, *DNSRecord]
+	lookup    func(domain string) (*DNSRecord, error)
+
+	health        map[string]*ipHealth // IP -> health status
+	healthChecker HealthChecker
+
+	analytics *queryAnalytics
+
+	// negativeTTL is how long a "not found" answer is cached; 0 disables
+	// negative caching
+	negativeTTL time.Duration
+
+	// refreshAhead is the fraction of an answer's TTL after which Resolve
+	// serves it stale and refreshes it in the background; 0 disables
+	refreshAhead float64
+
+	// upstream answers domains with no local records; nil disables
+	// forwarding
+	upstream Upstream
 }
 
+// cacheEntry is a cached answer. A nil record caches that the domain was
+// not found.
 type cacheEntry struct {
 	record    *DNSRecord
+	storedAt  time.Time
 	expiresAt time.Time
 }
 
 // NewDNSService creates a new DNS service
 func NewDNSService() *DNSService {
-	return &DNSService{
-		records: make(map[string]*DNSRecord),
-		cache:   make(map[string]*cacheEntry),
+	s := &DNSService{
+		records:     make(map[string][]*DNSRecord),
+		regional:    make(map[string]map[string]*DNSRecord),
+		cache:       make(map[string]*cacheEntry),
+		health:      make(map[string]*ipHealth),
+		analytics:   newQueryAnalytics(defaultQueryLogSize),
+		negativeTTL: DefaultNegativeTTL,
 	}
+	s.lookup = s.lookupOrForward
+	return s
 }
 
 // AddRecord adds a DNS record
@@ -42,6 +103,15 @@ func (s *DNSService) AddRecord(domain, ipAddress, recordType string, ttl int) (*
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.setRecordLocked(domain, ipAddress, recordType, ttl), nil
+}
+
+// AddRecordToSet adds an additional IP address for a domain so that Resolve
+// can fail over between them. An existing record for the same IP is replaced.
+func (s *DNSService) AddRecordToSet(domain, ipAddress, recordType string, ttl int) (*DNSRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	record := &DNSRecord{
 		Domain:    domain,
 		IPAddress: ipAddress,
@@ -50,40 +120,295 @@ func (s *DNSService) AddRecord(domain, ipAddress, recordType string, ttl int) (*
 		CreatedAt: time.Now(),
 	}
 
-	s.records[domain] = record
-	s.cache[domain] = &cacheEntry{
-		record:    record,
-		expiresAt: time.Now().Add(time.Duration(ttl) * time.Second),
+	records := make([]*DNSRecord, 0, len(s.records[domain])+1)
+	for _, existing := range s.records[domain] {
+		if existing.IPAddress != ipAddress {
+			records = append(records, existing)
+		}
 	}
+	s.records[domain] = append(records, record)
+
+	// The cached answer may no longer be the preferred one
+	delete(s.cache, domain)
 
 	return record, nil
 }
 
-// Resolve resolves a domain to an IP address
+// AddRegionalRecord adds a DNS record that is only served to clients in the
+// given region. An empty region adds a global record, same as AddRecord.
+func (s *DNSService) AddRegionalRecord(domain, ipAddress, recordType string, ttl int, region string) (*DNSRecord, error) {
+	if region == "" {
+		return s.AddRecord(domain, ipAddress, recordType, ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := &DNSRecord{
+		Domain:    domain,
+		IPAddress: ipAddress,
+		Type:      recordType,
+		TTL:       ttl,
+		Region:    region,
+		CreatedAt: time.Now(),
+	}
+
+	if s.regional[domain] == nil {
+		s.regional[domain] = make(map[string]*DNSRecord)
+	}
+	s.regional[domain][region] = record
+
+	return record, nil
+}
+
+// ResolveForRegion resolves a domain preferring the record for the client's
+// region, falling back to the global record when no regional record matches
+// or the regional IP is unhealthy. An unhealthy regional record is only
+// served, marked as degraded, when there is no global answer.
+func (s *DNSService) ResolveForRegion(domain, region string) (*DNSRecord, error) {
+	if region == "" {
+		return s.Resolve(domain)
+	}
+
+	s.mu.RLock()
+	record, exists := s.regional[domain][region]
+	healthy := exists && s.isHealthy(record.IPAddress)
+	s.mu.RUnlock()
+
+	if healthy {
+		// Regional answers never come from the cache
+		s.recordQuery(domain, record, false)
+		return record, nil
+	}
+
+	global, err := s.Resolve(domain)
+	if !exists || global != nil || err != nil {
+		return global, err
+	}
+	degraded := *record
+	degraded.Degraded = true
+	return &degraded, nil
+}
+
+// Resolve resolves a domain to an IP address, skipping unhealthy IPs when
+// the domain has more than one record. Domains with no local records are
+// forwarded upstream when an Upstream is set. Concurrent misses for the same
+// domain share one lookup. Each call is recorded in the query analytics.
 func (s *DNSService) Resolve(domain string) (*DNSRecord, error) {
+	// Check cache first
+	if record, freshness := s.cached(domain); freshness != cache.Expired {
+		if freshness == cache.Stale {
+			s.resolving.Refresh(domain, func() (*DNSRecord, error) { return s.lookup(domain) })
+		}
+		s.recordQuery(domain, record, true)
+		return record, nil
+	}
+
+	cacheHit := false
+	record, err, _ := s.resolving.Do(domain, func() (*DNSRecord, error) {
+		// A lookup that finished while this caller was missing may already
+		// have refreshed the cache
+		if record, freshness := s.cached(domain); freshness != cache.Expired {
+			cacheHit = true
+			return record, nil
+		}
+		return s.lookup(domain)
+	})
+	s.recordQuery(domain, record, cacheHit)
+	return record, err
+}
+
+// cached returns the cached answer for domain and how fresh it is. An
+// answer whose IP has gone down counts as expired.
+func (s *DNSService) cached(domain string) (*DNSRecord, cache.Freshness) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Check cache first
-	if entry, exists := s.cache[domain]; exists {
-		if time.Now().Before(entry.expiresAt) {
-			return entry.record, nil
+	entry, exists := s.cache[domain]
+	if !exists {
+		return nil, cache.Expired
+	}
+	ttl := entry.expiresAt.Sub(entry.storedAt)
+	soft := cache.SoftTTL{Soft: time.Duration(float64(ttl) * s.refreshAhead), Hard: ttl}
+	freshness := soft.Freshness(time.Since(entry.storedAt))
+	if freshness == cache.Expired {
+		return nil, cache.Expired
+	}
+	if entry.record != nil && !s.isHealthy(entry.record.IPAddress) {
+		return nil, cache.Expired
+	}
+	return entry.record, freshness
+}
+
+// SetRefreshAhead makes Resolve refresh cached answers in the background
+// once fraction of their TTL has passed, serving the old answer meanwhile,
+// so hot domains never block on a lookup. Answers still expire at their
+// full TTL. A fraction of 0, or 1 or more, turns refreshing off.
+func (s *DNSService) SetRefreshAhead(fraction float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fraction < 0 || fraction >= 1 {
+		fraction = 0
+	}
+	s.refreshAhead = fraction
+}
+
+// SetNegativeTTL sets how long Resolve caches that a domain was not found.
+// A ttl of 0 or less turns negative caching off.
+func (s *DNSService) SetNegativeTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl < 0 {
+		ttl = 0
+	}
+	s.negativeTTL = ttl
+	for domain, entry := range s.cache {
+		if entry.record == nil {
+			delete(s.cache, domain)
 		}
-		// Cache expired
-		delete(s.cache, domain)
 	}
+}
+
+// lookupRecords answers a cache miss from the stored records and caches
+// the answer
+func (s *DNSService) lookupRecords(domain string) (*DNSRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Cache expired or cached IP went down
+	delete(s.cache, domain)
 
 	// Check records
-	if record, exists := s.records[domain]; exists {
-		// Update cache
-		s.cache[domain] = &cacheEntry{
-			record:    record,
-			expiresAt: time.Now().Add(time.Duration(record.TTL) * time.Second),
+	records, exists := s.records[domain]
+	if !exists || len(records) == 0 {
+		// Remember the miss briefly; adding a record replaces or drops it
+		if s.negativeTTL > 0 {
+			now := time.Now()
+			s.cache[domain] = &cacheEntry{storedAt: now, expiresAt: now.Add(s.negativeTTL)}
 		}
+		return nil, nil
+	}
+
+	record, degraded := s.selectRecord(records)
+	if degraded {
+		// Don't cache a last-resort answer so recovery is picked up immediately
 		return record, nil
 	}
 
-	return nil, nil
+	// Update cache
+	now := time.Now()
+	s.cache[domain] = &cacheEntry{
+		record:    record,
+		storedAt:  now,
+		expiresAt: now.Add(time.Duration(record.TTL) * time.Second),
+	}
+	return record, nil
+}
+
+// selectRecord returns the first healthy record. If every record is
+// unhealthy it returns a copy of the least-recently-failed one marked as
+// degraded. Must be called with s.mu held.
+func (s *DNSService) selectRecord(records []*DNSRecord) (*DNSRecord, bool) {
+	var fallback *DNSRecord
+	var fallbackFailure time.Time
+
+	for _, record := range records {
+		status, tracked := s.health[record.IPAddress]
+		if !tracked || status.healthy {
+			return record, false
+		}
+		if fallback == nil || status.lastFailure.Before(fallbackFailure) {
+			fallback = record
+			fallbackFailure = status.lastFailure
+		}
+	}
+
+	degraded := *fallback
+	degraded.Degraded = true
+	return &degraded, true
+}
+
+// isHealthy reports whether an IP is healthy. IPs that have never been
+// checked are assumed healthy. Must be called with s.mu held.
+func (s *DNSService) isHealthy(ipAddress string) bool {
+	status, tracked := s.health[ipAddress]
+	return !tracked || status.healthy
+}
+
+// SetHealth marks an IP address as healthy or unhealthy
+func (s *DNSService) SetHealth(ipAddress string, healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setHealthLocked(ipAddress, healthy)
+}
+
+func (s *DNSService) setHealthLocked(ipAddress string, healthy bool) {
+	status, exists := s.health[ipAddress]
+	if !exists {
+		status = &ipHealth{}
+		s.health[ipAddress] = status
+	}
+
+	status.healthy = healthy
+	if !healthy {
+		status.lastFailure = time.Now()
+	}
+}
+
+// RegisterHealthChecker sets the checker used by CheckHealth
+func (s *DNSService) RegisterHealthChecker(checker HealthChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthChecker = checker
+}
+
+// CheckHealth runs the registered health checker against every known IP
+func (s *DNSService) CheckHealth() {
+	s.mu.RLock()
+	checker := s.healthChecker
+	ips := make(map[string]bool)
+	for _, records := range s.records {
+		for _, record := range records {
+			ips[record.IPAddress] = true
+		}
+	}
+	for _, byRegion := range s.regional {
+		for _, record := range byRegion {
+			ips[record.IPAddress] = true
+		}
+	}
+	s.mu.RUnlock()
+
+	if checker == nil {
+		return
+	}
+
+	// Run checks without holding the lock, they may do network I/O
+	results := make(map[string]bool, len(ips))
+	for ip := range ips {
+		results[ip] = checker(ip)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ip, healthy := range results {
+		s.setHealthLocked(ip, healthy)
+		if !healthy {
+			log.Printf("IP %s is down", ip)
+		}
+	}
+}
+
+// StartHealthCheck starts the health check routine
+func (s *DNSService) StartHealthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			s.CheckHealth()
+		}
+	}()
 }
 
 // DeleteRecord deletes a DNS record
@@ -92,6 +417,7 @@ func (s *DNSService) DeleteRecord(domain string) error {
 	defer s.mu.Unlock()
 
 	delete(s.records, domain)
+	delete(s.regional, domain)
 	delete(s.cache, domain)
 	return nil
 }
@@ -102,35 +428,148 @@ func (s *DNSService) ListRecords() []*DNSRecord {
 	defer s.mu.RUnlock()
 
 	records := make([]*DNSRecord, 0, len(s.records))
-	for _, record := range s.records {
-		records = append(records, record)
+	for _, set := range s.records {
+		records = append(records, set...)
+	}
+	for _, byRegion := range s.regional {
+		for _, record := range byRegion {
+			records = append(records, record)
+		}
 	}
 	return records
 }
 
+// ipHealthState is the serialized form of an IP's health
+type ipHealthState struct {
+	IPAddress   string    `json:"ip_address"`
+	Healthy     bool      `json:"healthy"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+}
+
+// dnsState is the serialized form of the whole service. Records holds both
+// global record sets, in set order, and regional records, told apart by
+// Region. The resolve cache is not stored and starts empty after Restore.
+type dnsState struct {
+	Records []*DNSRecord    `json:"records"`
+	Health  []ipHealthState `json:"health"`
+}
+
+// Snapshot serializes every record and tracked IP health to JSON
+func (s *DNSService) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := dnsState{
+		Records: make([]*DNSRecord, 0, len(s.records)),
+		Health:  make([]ipHealthState, 0, len(s.health)),
+	}
+
+	for _, domain := range sortedKeys(s.records) {
+		state.Records = append(state.Records, s.records[domain]...)
+	}
+	for _, domain := range sortedKeys(s.regional) {
+		byRegion := s.regional[domain]
+		for _, region := range sortedKeys(byRegion) {
+			state.Records = append(state.Records, byRegion[region])
+		}
+	}
+	for _, ip := range sortedKeys(s.health) {
+		status := s.health[ip]
+		state.Health = append(state.Health, ipHealthState{IPAddress: ip, Healthy: status.healthy, LastFailure: status.lastFailure})
+	}
+
+	return json.Marshal(state)
+}
+
+// Restore replaces the service's records and health with a snapshot and
+// clears the resolve cache
+func (s *DNSService) Restore(data []byte) error {
+	var state dnsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	records := make(map[string][]*DNSRecord)
+	regional := make(map[string]map[string]*DNSRecord)
+	for _, record := range state.Records {
+		if record == nil || record.Domain == "" {
+			return fmt.Errorf("record without a domain")
+		}
+		if record.Region == "" {
+			records[record.Domain] = append(records[record.Domain], record)
+			continue
+		}
+		if regional[record.Domain] == nil {
+			regional[record.Domain] = make(map[string]*DNSRecord)
+		}
+		regional[record.Domain][record.Region] = record
+	}
+
+	healthByIP := make(map[string]*ipHealth, len(state.Health))
+	for _, status := range state.Health {
+		healthByIP[status.IPAddress] = &ipHealth{healthy: status.Healthy, lastFailure: status.LastFailure}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = records
+	s.regional = regional
+	s.health = healthByIP
+	s.cache = make(map[string]*cacheEntry)
+
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 var service *DNSService
 
+// addRecordRequest is the body of /add
+type addRecordRequest struct {
+	Domain    string `json:"domain"`
+	IPAddress string `json:"ip_address"`
+	Type      string `json:"type"`
+	TTL       int    `json:"ttl"`
+	Region    string `json:"region,omitempty"`
+}
+
+func (req addRecordRequest) validate() error {
+	var v validate.Validator
+	v.String("domain", req.Domain, validate.Required)
+	v.String("ip_address", req.IPAddress, validate.Required)
+	v.String("type", req.Type, validate.OneOf("A", "AAAA", "CNAME", "MX", "NS", "TXT"))
+	v.Int("ttl", req.TTL, validate.Min(0))
+	return v.Err()
+}
+
 func addRecordHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Domain     string `json:"domain"`
-		IPAddress  string `json:"ip_address"`
-		Type       string `json:"type"`
-		TTL        int    `json:"ttl"`
-	}
+	var req addRecordRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	record, err := service.AddRecord(req.Domain, req.IPAddress, req.Type, req.TTL)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	record, err := service.AddRegionalRecord(req.Domain, req.IPAddress, req.Type, req.TTL, req.Region)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -141,18 +580,23 @@ func addRecordHandler(w http.ResponseWriter, r *http.Request) {
 func resolveHandler(w http.ResponseWriter, r *http.Request) {
 	domain := r.URL.Query().Get("domain")
 	if domain == "" {
-		http.Error(w, "domain parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "domain parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	record, err := service.Resolve(domain)
+	// An optional region query param selects a region-aware answer
+	record, err := service.ResolveForRegion(domain, r.URL.Query().Get("region"))
+	if errors.Is(err, ErrUpstream) {
+		apierror.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	if record == nil {
-		http.Error(w, "domain not found", http.StatusNotFound)
+		apierror.Error(w, "domain not found", http.StatusNotFound)
 		return
 	}
 
@@ -162,18 +606,18 @@ func resolveHandler(w http.ResponseWriter, r *http.Request) {
 
 func deleteRecordHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	domain := r.URL.Query().Get("domain")
 	if domain == "" {
-		http.Error(w, "domain parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "domain parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	if err := service.DeleteRecord(domain); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -181,9 +625,8 @@ func deleteRecordHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listRecordsHandler(w http.ResponseWriter, r *http.Request) {
-	records := service.ListRecords()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(records)
+	// Stream the records so a large zone isn't encoded in one buffer
+	jsonstream.WriteArray(w, service.ListRecords())
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -191,17 +634,94 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "DNS Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(&service.mu, time.Second))
+
+	// State dumps need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	domainQuery := []openapi.Param{{Name: "domain", Required: true}}
+
+	api.HandleFunc("/add", addRecordHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Add a DNS record",
+		Request: addRecordRequest{}, Response: DNSRecord{},
+		Responses: map[int]string{200: "Record added", 400: "Invalid request"},
+	})
+	api.HandleFunc("/add-if-absent", addRecordIfAbsentHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Add a DNS record unless the domain already has one",
+		Request: addRecordRequest{}, Response: DNSRecord{},
+		Responses: map[int]string{200: "Record added", 400: "Invalid request", 409: "Domain already has a record"},
+	})
+	api.HandleFunc("/update-cas", updateRecordCASHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Replace a domain's record if it still matches the expected one",
+		Request: updateCASRequest{}, Response: DNSRecord{},
+		Responses: map[int]string{200: "Record updated", 400: "Invalid request", 409: "Current record does not match"},
+	})
+	api.HandleFunc("/resolve", resolveHandler, openapi.Route{
+		Summary: "Resolve a domain",
+		Query: []openapi.Param{
+			{Name: "domain", Required: true},
+			{Name: "region", Description: "Prefer the record for this region"},
+		},
+		Response:  DNSRecord{},
+		Responses: map[int]string{200: "The resolved record", 400: "Missing domain", 404: "Domain not found", 502: "Forwarding to the upstream resolver failed"},
+	})
+	api.HandleFunc("/delete", deleteRecordHandler, openapi.Route{
+		Method: http.MethodDelete, Summary: "Delete the records for a domain", Query: domainQuery,
+		Responses: map[int]string{200: "Records deleted", 400: "Missing domain"},
+	})
+	api.HandleFunc("/list", listRecordsHandler, openapi.Route{
+		Summary: "List all records", Response: []DNSRecord{},
+		Responses: map[int]string{200: "All records"},
+	})
+	api.HandleFunc("/analytics", analyticsHandler, openapi.Route{
+		Summary: "Show the most queried domains, cache hit rate and recent queries",
+		Query: []openapi.Param{
+			{Name: "top", Description: "How many of the most queried domains to return"},
+			{Name: "limit", Description: "How many recent queries to return"},
+		},
+		Response:  AnalyticsReport{},
+		Responses: map[int]string{200: "Query analytics", 400: "Invalid top or limit"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(dnsState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	service = NewDNSService()
-
-	http.HandleFunc("/add", addRecordHandler)
-	http.HandleFunc("/resolve", resolveHandler)
-	http.HandleFunc("/delete", deleteRecordHandler)
-	http.HandleFunc("/list", listRecordsHandler)
-	http.HandleFunc("/health", healthHandler)
+	service.SetUpstream(upstreamFromEnv())
+	if checker, interval := healthCheckFromEnv(); checker != nil {
+		service.RegisterHealthChecker(checker)
+		service.CheckHealth()
+		service.StartHealthCheck(interval)
+		log.Printf("Checking record health every %s", interval)
+	}
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8085"
 	log.Printf("DNS service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
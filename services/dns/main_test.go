@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -320,3 +321,216 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("Expected status 'healthy', got %s", resp["status"])
 	}
 }
+
+func TestResolveForRegion(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 300)
+	service.AddRegionalRecord("example.com", "10.1.0.1", "A", 300, "us-east")
+	service.AddRegionalRecord("example.com", "10.2.0.1", "A", 300, "eu-west")
+
+	tests := []struct {
+		region string
+		wantIP string
+	}{
+		{"us-east", "10.1.0.1"},
+		{"eu-west", "10.2.0.1"},
+		{"ap-south", "10.0.0.1"},
+		{"", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		record, err := service.ResolveForRegion("example.com", tt.region)
+		if err != nil {
+			t.Fatalf("Expected no error for region %q, got %v", tt.region, err)
+		}
+		if record == nil {
+			t.Fatalf("Expected record for region %q", tt.region)
+		}
+		if record.IPAddress != tt.wantIP {
+			t.Errorf("Region %q: expected IP %s, got %s", tt.region, tt.wantIP, record.IPAddress)
+		}
+	}
+}
+
+func TestResolveForRegion_NoGlobalFallback(t *testing.T) {
+	service := NewDNSService()
+	service.AddRegionalRecord("example.com", "10.1.0.1", "A", 300, "us-east")
+
+	record, _ := service.ResolveForRegion("example.com", "eu-west")
+	if record != nil {
+		t.Errorf("Expected nil record without a global fallback, got %s", record.IPAddress)
+	}
+}
+
+func TestResolveForRegion_UnhealthyRegionalRecord(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 300)
+	service.AddRegionalRecord("example.com", "10.1.0.1", "A", 300, "us-east")
+	service.AddRegionalRecord("only-regional.com", "10.1.0.2", "A", 300, "us-east")
+
+	service.RegisterHealthChecker(func(ip string) bool { return ip != "10.1.0.1" && ip != "10.1.0.2" })
+	service.CheckHealth()
+
+	record, err := service.ResolveForRegion("example.com", "us-east")
+	if err != nil || record == nil {
+		t.Fatalf("Expected a record, got %v, %v", record, err)
+	}
+	if record.IPAddress != "10.0.0.1" || record.Degraded {
+		t.Errorf("Expected the healthy global IP 10.0.0.1, got %+v", record)
+	}
+
+	// With nothing global to fall back to, the regional IP is a last resort
+	record, _ = service.ResolveForRegion("only-regional.com", "us-east")
+	if record == nil || record.IPAddress != "10.1.0.2" || !record.Degraded {
+		t.Errorf("Expected a degraded 10.1.0.2, got %+v", record)
+	}
+
+	// The regional record is preferred again once it recovers
+	service.SetHealth("10.1.0.1", true)
+	record, _ = service.ResolveForRegion("example.com", "us-east")
+	if record == nil || record.IPAddress != "10.1.0.1" || record.Degraded {
+		t.Errorf("Expected the recovered regional IP 10.1.0.1, got %+v", record)
+	}
+}
+
+func TestResolveHandler_Region(t *testing.T) {
+	service = NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 300)
+	service.AddRegionalRecord("example.com", "10.2.0.1", "A", 300, "eu-west")
+
+	req := httptest.NewRequest(http.MethodGet, "/resolve?domain=example.com&region=eu-west", nil)
+	w := httptest.NewRecorder()
+
+	resolveHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var record DNSRecord
+	json.NewDecoder(w.Body).Decode(&record)
+	if record.IPAddress != "10.2.0.1" {
+		t.Errorf("Expected IP 10.2.0.1, got %s", record.IPAddress)
+	}
+	if record.Region != "eu-west" {
+		t.Errorf("Expected region eu-west, got %s", record.Region)
+	}
+}
+
+func TestResolve_SkipsUnhealthyIP(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecordToSet("example.com", "10.0.0.1", "A", 300)
+	service.AddRecordToSet("example.com", "10.0.0.2", "A", 300)
+
+	service.SetHealth("10.0.0.1", false)
+
+	for i := 0; i < 10; i++ {
+		record, _ := service.Resolve("example.com")
+		if record == nil {
+			t.Fatal("Expected record to be found")
+		}
+		if record.IPAddress == "10.0.0.1" {
+			t.Fatal("Expected unhealthy IP to be skipped")
+		}
+		if record.Degraded {
+			t.Error("Expected healthy answer not to be degraded")
+		}
+	}
+
+	// Recover the first IP and take the second one down
+	service.SetHealth("10.0.0.1", true)
+	service.SetHealth("10.0.0.2", false)
+
+	record, _ := service.Resolve("example.com")
+	if record.IPAddress != "10.0.0.1" {
+		t.Errorf("Expected recovered IP 10.0.0.1, got %s", record.IPAddress)
+	}
+}
+
+func TestResolve_AllUnhealthyReturnsLeastRecentlyFailed(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecordToSet("example.com", "10.0.0.1", "A", 300)
+	service.AddRecordToSet("example.com", "10.0.0.2", "A", 300)
+
+	service.SetHealth("10.0.0.2", false)
+	time.Sleep(5 * time.Millisecond)
+	service.SetHealth("10.0.0.1", false)
+
+	record, _ := service.Resolve("example.com")
+	if record == nil {
+		t.Fatal("Expected a last-resort record")
+	}
+	if record.IPAddress != "10.0.0.2" {
+		t.Errorf("Expected least recently failed IP 10.0.0.2, got %s", record.IPAddress)
+	}
+	if !record.Degraded {
+		t.Error("Expected degraded flag to be set")
+	}
+}
+
+func TestCheckHealth_UsesRegisteredChecker(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecordToSet("example.com", "10.0.0.1", "A", 300)
+	service.AddRecordToSet("example.com", "10.0.0.2", "A", 300)
+
+	down := map[string]bool{"10.0.0.1": true}
+	service.RegisterHealthChecker(func(ip string) bool {
+		return !down[ip]
+	})
+	service.CheckHealth()
+
+	record, _ := service.Resolve("example.com")
+	if record.IPAddress != "10.0.0.2" {
+		t.Errorf("Expected healthy IP 10.0.0.2, got %s", record.IPAddress)
+	}
+
+	// Backend recovers
+	delete(down, "10.0.0.1")
+	service.CheckHealth()
+
+	service.SetHealth("10.0.0.2", false)
+	record, _ = service.Resolve("example.com")
+	if record.IPAddress != "10.0.0.1" {
+		t.Errorf("Expected recovered IP 10.0.0.1, got %s", record.IPAddress)
+	}
+}
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewDNSService()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
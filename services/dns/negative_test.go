@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolve_NegativeCache(t *testing.T) {
+	service := NewDNSService()
+
+	var lookups int64
+	service.lookup = func(domain string) (*DNSRecord, error) {
+		atomic.AddInt64(&lookups, 1)
+		return service.lookupRecords(domain)
+	}
+
+	if record, err := service.Resolve("missing.com"); record != nil || err != nil {
+		t.Fatalf("Expected no record, got %+v, %v", record, err)
+	}
+	if record, err := service.Resolve("missing.com"); record != nil || err != nil {
+		t.Fatalf("Expected no record, got %+v, %v", record, err)
+	}
+	if lookups != 1 {
+		t.Errorf("Expected the second miss to come from the negative cache, got %d lookups", lookups)
+	}
+	if log := service.QueryLog(1); !log[0].CacheHit || log[0].Found {
+		t.Errorf("Expected the second miss to be logged as a cached not-found, got %+v", log[0])
+	}
+
+	// Adding the domain replaces the negative entry straight away
+	service.AddRecord("missing.com", "10.0.0.1", "A", 300)
+	record, err := service.Resolve("missing.com")
+	if err != nil || record == nil || record.IPAddress != "10.0.0.1" {
+		t.Errorf("Expected 10.0.0.1 after adding the record, got %+v, %v", record, err)
+	}
+
+	// Records added to a set drop the negative entry too
+	service.Resolve("set.com")
+	service.AddRecordToSet("set.com", "10.0.0.2", "A", 300)
+	if record, _ := service.Resolve("set.com"); record == nil || record.IPAddress != "10.0.0.2" {
+		t.Errorf("Expected 10.0.0.2 after adding to the set, got %+v", record)
+	}
+}
+
+func TestResolve_NegativeCacheExpires(t *testing.T) {
+	service := NewDNSService()
+	service.SetNegativeTTL(20 * time.Millisecond)
+
+	var lookups int64
+	service.lookup = func(domain string) (*DNSRecord, error) {
+		atomic.AddInt64(&lookups, 1)
+		return service.lookupRecords(domain)
+	}
+
+	service.Resolve("missing.com")
+	time.Sleep(30 * time.Millisecond)
+	service.Resolve("missing.com")
+	if lookups != 2 {
+		t.Errorf("Expected the negative entry to expire, got %d lookups", lookups)
+	}
+
+	// A TTL of 0 turns negative caching off
+	service.SetNegativeTTL(0)
+	service.Resolve("missing.com")
+	service.Resolve("missing.com")
+	if lookups != 4 {
+		t.Errorf("Expected every miss to look up with negative caching off, got %d lookups", lookups)
+	}
+}
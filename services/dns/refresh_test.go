@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"common/cache"
+)
+
+func TestResolve_RefreshAheadServesStale(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 1)
+	service.SetRefreshAhead(0.05) // stale after 50ms
+
+	var lookups int64
+	release := make(chan struct{})
+	service.lookup = func(domain string) (*DNSRecord, error) {
+		atomic.AddInt64(&lookups, 1)
+		<-release
+		return service.lookupRecords(domain)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, freshness := service.cached("example.com"); freshness != cache.Stale {
+		t.Fatalf("Expected the answer to be stale, got %d", freshness)
+	}
+
+	// Every caller gets the stale answer straight away while one refresh
+	// waits on release
+	for i := 0; i < 5; i++ {
+		done := make(chan *DNSRecord)
+		go func() {
+			record, _ := service.Resolve("example.com")
+			done <- record
+		}()
+		select {
+		case record := <-done:
+			if record == nil || record.IPAddress != "10.0.0.1" {
+				t.Fatalf("Expected the stale answer, got %+v", record)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected Resolve not to block on the refresh")
+		}
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, freshness := service.cached("example.com"); freshness == cache.Fresh {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background refresh to store a fresh answer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&lookups); n != 1 {
+		t.Errorf("Expected exactly one background refresh, got %d", n)
+	}
+	if log := service.QueryLog(1); !log[0].CacheHit {
+		t.Errorf("Expected stale answers to count as cache hits, got %+v", log[0])
+	}
+}
+
+func TestResolve_RefreshAheadOffByDefault(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 1)
+
+	if _, freshness := service.cached("example.com"); freshness != cache.Fresh {
+		t.Errorf("Expected a fresh answer, got %d", freshness)
+	}
+
+	service.SetRefreshAhead(1.5)
+	if service.refreshAhead != 0 {
+		t.Errorf("Expected an out of range fraction to turn refreshing off, got %v", service.refreshAhead)
+	}
+}
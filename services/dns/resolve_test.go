@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolve_CoalescesConcurrentMisses(t *testing.T) {
+	service := NewDNSService()
+	service.AddRecord("example.com", "10.0.0.1", "A", 300)
+	if _, err := service.Resolve("example.com"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Expire the cached answer and count lookups from here on
+	service.mu.Lock()
+	service.cache["example.com"].expiresAt = time.Now().Add(-time.Second)
+	service.mu.Unlock()
+
+	var lookups int64
+	release := make(chan struct{})
+	service.lookup = func(domain string) (*DNSRecord, error) {
+		atomic.AddInt64(&lookups, 1)
+		<-release
+		return service.lookupRecords(domain)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record, err := service.Resolve("example.com")
+			if err != nil || record == nil || record.IPAddress != "10.0.0.1" {
+				t.Errorf("Expected 10.0.0.1, got %+v, %v", record, err)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if lookups != 1 {
+		t.Errorf("Expected one lookup after expiry, got %d", lookups)
+	}
+
+	// The refreshed answer is cached again
+	if _, err := service.Resolve("example.com"); err != nil || lookups != 1 {
+		t.Errorf("Expected a cache hit, got %d lookups, %v", lookups, err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Forwarding defaults
+const (
+	// DefaultUpstreamTTL is how long forwarded answers are cached when the
+	// upstream doesn't report a TTL
+	DefaultUpstreamTTL = 60 * time.Second
+	// upstreamTimeout bounds a single forwarded query
+	upstreamTimeout = 2 * time.Second
+)
+
+// ErrUpstream is returned when a forwarded query fails. The returned error
+// wraps it with the cause.
+var ErrUpstream = errors.New("upstream lookup failed")
+
+// Upstream answers queries for domains with no local records. It returns
+// nil, nil when the domain does not exist upstream. The returned record's
+// TTL sets how long the answer is cached.
+type Upstream func(ctx context.Context, domain string) (*DNSRecord, error)
+
+// NetUpstream forwards queries to resolver. net.Resolver doesn't expose
+// record TTLs, so answers are cached for ttl.
+func NetUpstream(resolver *net.Resolver, ttl time.Duration) Upstream {
+	return func(ctx context.Context, domain string) (*DNSRecord, error) {
+		addrs, err := resolver.LookupIPAddr(ctx, domain)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, nil
+		}
+
+		recordType := "A"
+		if addrs[0].IP.To4() == nil {
+			recordType = "AAAA"
+		}
+		return &DNSRecord{
+			Domain:    domain,
+			IPAddress: addrs[0].IP.String(),
+			Type:      recordType,
+			TTL:       int(ttl / time.Second),
+			CreatedAt: time.Now(),
+		}, nil
+	}
+}
+
+// upstreamFromEnv builds the upstream named by DNS_UPSTREAM: "system" for
+// the host's resolver, or a host:port to query directly. It returns nil,
+// leaving forwarding off, when DNS_UPSTREAM is unset.
+func upstreamFromEnv() Upstream {
+	server := os.Getenv("DNS_UPSTREAM")
+	switch server {
+	case "":
+		return nil
+	case "system":
+		return NetUpstream(net.DefaultResolver, DefaultUpstreamTTL)
+	}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	return NetUpstream(resolver, DefaultUpstreamTTL)
+}
+
+// SetUpstream makes Resolve forward domains with no local records to
+// upstream and cache the answers, so the service acts as a caching
+// forwarder. Local records always take precedence. A nil upstream turns
+// forwarding off.
+func (s *DNSService) SetUpstream(upstream Upstream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upstream = upstream
+}
+
+// lookupOrForward answers a cache miss from the stored records, and from
+// the upstream when the domain has none and forwarding is on
+func (s *DNSService) lookupOrForward(domain string) (*DNSRecord, error) {
+	s.mu.RLock()
+	upstream := s.upstream
+	local := len(s.records[domain]) > 0
+	s.mu.RUnlock()
+
+	if upstream == nil || local {
+		return s.lookupRecords(domain)
+	}
+	return s.forward(domain, upstream)
+}
+
+// forward queries upstream for domain and caches the answer for its TTL,
+// or caches the miss like a local one. Failures aren't cached, so the next
+// query tries again.
+func (s *DNSService) forward(domain string, upstream Upstream) (*DNSRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	record, err := upstream(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s: %v", ErrUpstream, domain, err)
+	}
+
+	s.mu.Lock()
+	if len(s.records[domain]) > 0 {
+		// A local record was added while the query was out; it wins
+		s.mu.Unlock()
+		return s.lookupRecords(domain)
+	}
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if record == nil {
+		delete(s.cache, domain)
+		if s.negativeTTL > 0 {
+			s.cache[domain] = &cacheEntry{storedAt: now, expiresAt: now.Add(s.negativeTTL)}
+		}
+		return nil, nil
+	}
+
+	record.Forwarded = true
+	s.cache[domain] = &cacheEntry{
+		record:    record,
+		storedAt:  now,
+		expiresAt: now.Add(time.Duration(record.TTL) * time.Second),
+	}
+	return record, nil
+}
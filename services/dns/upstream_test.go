@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingUpstream answers every domain with ip and counts its queries
+func countingUpstream(ip string, queries *int64) Upstream {
+	return func(ctx context.Context, domain string) (*DNSRecord, error) {
+		atomic.AddInt64(queries, 1)
+		return &DNSRecord{Domain: domain, IPAddress: ip, Type: "A", TTL: 300, CreatedAt: time.Now()}, nil
+	}
+}
+
+func TestResolve_ForwardsLocalMiss(t *testing.T) {
+	service := NewDNSService()
+	var queries int64
+	service.SetUpstream(countingUpstream("93.184.216.34", &queries))
+
+	record, err := service.Resolve("example.org")
+	if err != nil || record == nil {
+		t.Fatalf("Expected a forwarded answer, got %+v, %v", record, err)
+	}
+	if record.IPAddress != "93.184.216.34" || !record.Forwarded {
+		t.Errorf("Expected the upstream's answer marked forwarded, got %+v", record)
+	}
+
+	again, err := service.Resolve("example.org")
+	if err != nil || again == nil || again.IPAddress != record.IPAddress {
+		t.Fatalf("Expected the cached answer, got %+v, %v", again, err)
+	}
+	if queries != 1 {
+		t.Errorf("Expected the second query to be served from cache, got %d upstream queries", queries)
+	}
+	if log := service.QueryLog(1); !log[0].CacheHit {
+		t.Errorf("Expected the second query to be logged as a cache hit, got %+v", log[0])
+	}
+
+	service.mu.RLock()
+	entry := service.cache["example.org"]
+	service.mu.RUnlock()
+	if ttl := entry.expiresAt.Sub(entry.storedAt); ttl != 300*time.Second {
+		t.Errorf("Expected the answer cached for the upstream TTL, got %v", ttl)
+	}
+}
+
+func TestResolve_LocalRecordsWinOverUpstream(t *testing.T) {
+	service := NewDNSService()
+	var queries int64
+	service.SetUpstream(countingUpstream("93.184.216.34", &queries))
+	service.AddRecord("example.org", "10.0.0.1", "A", 300)
+
+	record, _ := service.Resolve("example.org")
+	if record == nil || record.IPAddress != "10.0.0.1" || record.Forwarded {
+		t.Errorf("Expected the local record, got %+v", record)
+	}
+
+	// A local record added after forwarding replaces the cached answer
+	service.Resolve("other.org")
+	service.AddRecord("other.org", "10.0.0.2", "A", 300)
+	record, _ = service.Resolve("other.org")
+	if record == nil || record.IPAddress != "10.0.0.2" {
+		t.Errorf("Expected the new local record, got %+v", record)
+	}
+	if queries != 1 {
+		t.Errorf("Expected only other.org to be forwarded, got %d upstream queries", queries)
+	}
+}
+
+func TestResolve_UpstreamNotFoundAndFailure(t *testing.T) {
+	service := NewDNSService()
+	var queries int64
+	service.SetUpstream(func(ctx context.Context, domain string) (*DNSRecord, error) {
+		atomic.AddInt64(&queries, 1)
+		if domain == "broken.org" {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	})
+
+	if record, err := service.Resolve("missing.org"); record != nil || err != nil {
+		t.Fatalf("Expected not found, got %+v, %v", record, err)
+	}
+	service.Resolve("missing.org")
+	if queries != 1 {
+		t.Errorf("Expected the upstream miss to be negatively cached, got %d queries", queries)
+	}
+
+	if _, err := service.Resolve("broken.org"); !errors.Is(err, ErrUpstream) {
+		t.Fatalf("Expected ErrUpstream, got %v", err)
+	}
+	service.Resolve("broken.org")
+	if queries != 3 {
+		t.Errorf("Expected failures not to be cached, got %d queries", queries)
+	}
+
+	service.SetUpstream(nil)
+	if record, err := service.Resolve("elsewhere.org"); record != nil || err != nil {
+		t.Errorf("Expected no forwarding once the upstream is removed, got %+v, %v", record, err)
+	}
+}
+
+func TestResolveHandler_UpstreamFailure(t *testing.T) {
+	service = NewDNSService()
+	service.SetUpstream(func(ctx context.Context, domain string) (*DNSRecord, error) {
+		return nil, errors.New("timeout")
+	})
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resolve?domain=example.org", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", w.Code)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/validate"
+)
+
+func TestAddRecordHandler_ReportsEveryFieldError(t *testing.T) {
+	service = NewDNSService()
+
+	w := httptest.NewRecorder()
+	addRecordHandler(w, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"type":"SRV","ttl":-1}`)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var resp struct {
+		Error struct {
+			Details struct {
+				Fields validate.Errors `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	want := validate.Errors{
+		{Field: "domain", Message: "required"},
+		{Field: "ip_address", Message: "required"},
+		{Field: "type", Message: "must be one of A, AAAA, CNAME, MX, NS, TXT"},
+		{Field: "ttl", Message: "must be >= 0"},
+	}
+	if !reflect.DeepEqual(resp.Error.Details.Fields, want) {
+		t.Errorf("Expected %v, got %v", want, resp.Error.Details.Fields)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"common/apierror"
+	"common/middleware"
+	"common/validate"
+)
+
+// MaxBatchEdits is the most edits one ApplyBatch call may carry
+const MaxBatchEdits = 100
+
+var (
+	// ErrEmptyBatch is returned for a batch with no edits
+	ErrEmptyBatch = errors.New("batch has no edits")
+	// ErrBatchTooLarge is returned for a batch over MaxBatchEdits
+	ErrBatchTooLarge = fmt.Errorf("batch has more than %d edits", MaxBatchEdits)
+	// ErrDocumentNotFound is returned for an edit to a missing document
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrNotEditor is returned when the user is not one of a document's
+	// editors
+	ErrNotEditor = errors.New("user is not an editor of the document")
+	// ErrUnknownOperation is returned for an operation other than insert,
+	// delete or replace
+	ErrUnknownOperation = errors.New("operation must be insert, delete or replace")
+	// ErrPositionOutOfRange is returned for an insert or delete position
+	// outside the document
+	ErrPositionOutOfRange = errors.New("position is outside the document")
+	// ErrVersionConflict is returned when a document is not at the version
+	// an edit expects
+	ErrVersionConflict = errors.New("document version has changed")
+)
+
+// BatchEdit is one edit in an ApplyBatch call
+type BatchEdit struct {
+	DocumentID string `json:"document_id"`
+	UserID     string `json:"user_id"`
+	Operation  string `json:"operation"` // insert, delete, replace
+	Position   int    `json:"position"`
+	Content    string `json:"content"`
+	// Version, if non-zero, is the document version the edit was made
+	// against. Earlier edits to the same document in the batch count, so
+	// a second edit expects one version later than the first.
+	Version int `json:"version,omitempty"`
+}
+
+// BatchEditError reports which edit stopped a batch
+type BatchEditError struct {
+	Index int // of the failing edit in the batch
+	Err   error
+}
+
+func (e *BatchEditError) Error() string {
+	return fmt.Sprintf("edit %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchEditError) Unwrap() error {
+	return e.Err
+}
+
+// draft is a document's state part way through validating a batch
+type draft struct {
+	content string
+	version int
+}
+
+// ApplyBatch applies edits, possibly to several documents, as one
+// transaction. Every edit is checked in order against the documents as
+// the earlier edits leave them: the user must be an editor, the operation
+// known, the position inside the content and the version, if given,
+// current. If any check fails nothing is changed and the error is a
+// *BatchEditError naming the edit. Otherwise every edit is logged and
+// applied and the logged edits are returned in batch order.
+func (s *GoogleDocsService) ApplyBatch(edits []BatchEdit) ([]*Edit, error) {
+	if len(edits) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(edits) > MaxBatchEdits {
+		return nil, ErrBatchTooLarge
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Validate against drafts so a failure leaves the documents untouched
+	drafts := make(map[string]*draft)
+	for i, edit := range edits {
+		doc, exists := s.documents[edit.DocumentID]
+		if !exists {
+			return nil, &BatchEditError{Index: i, Err: ErrDocumentNotFound}
+		}
+		d := drafts[doc.ID]
+		if d == nil {
+			d = &draft{content: doc.Content, version: doc.Version}
+			drafts[doc.ID] = d
+		}
+
+		if !slices.Contains(doc.Editors, edit.UserID) {
+			return nil, &BatchEditError{Index: i, Err: ErrNotEditor}
+		}
+		if edit.Version != 0 && edit.Version != d.version {
+			return nil, &BatchEditError{Index: i, Err: fmt.Errorf("%w: expected %d, at %d", ErrVersionConflict, edit.Version, d.version)}
+		}
+		if err := checkEdit(d.content, edit); err != nil {
+			return nil, &BatchEditError{Index: i, Err: err}
+		}
+
+		d.content = applyEdit(d.content, &Edit{Operation: edit.Operation, Position: edit.Position, Content: edit.Content})
+		d.version++
+	}
+
+	// Every edit is valid; log and apply them exactly as validated
+	now := time.Now()
+	applied := make([]*Edit, 0, len(edits))
+	for _, be := range edits {
+		s.editIndex++
+		edit := &Edit{
+			ID:         generateID("edit", s.editIndex),
+			DocumentID: be.DocumentID,
+			UserID:     be.UserID,
+			Operation:  be.Operation,
+			Position:   be.Position,
+			Content:    be.Content,
+			Timestamp:  now,
+		}
+		s.edits[edit.DocumentID] = append(s.edits[edit.DocumentID], edit)
+		applied = append(applied, edit)
+	}
+	for docID, d := range drafts {
+		doc := s.documents[docID]
+		doc.Content = d.content
+		doc.Version = d.version
+		doc.UpdatedAt = now
+	}
+
+	return applied, nil
+}
+
+// checkEdit rejects an edit that applyEdit would ignore on content
+func checkEdit(content string, edit BatchEdit) error {
+	switch edit.Operation {
+	case "insert":
+		if edit.Position < 0 || edit.Position > len(content) {
+			return ErrPositionOutOfRange
+		}
+	case "delete":
+		if edit.Position < 0 || edit.Position >= len(content) {
+			return ErrPositionOutOfRange
+		}
+	case "replace":
+	default:
+		return ErrUnknownOperation
+	}
+	return nil
+}
+
+// batchEditRequest is the body of /document/batch-edit. Every edit is made
+// as the authenticated user, or user_id without authentication.
+type batchEditRequest struct {
+	UserID string      `json:"user_id"`
+	Edits  []BatchEdit `json:"edits"`
+}
+
+func (req batchEditRequest) validate() error {
+	var v validate.Validator
+	v.String("user_id", req.UserID, validate.Required)
+	v.Int("edits", len(req.Edits), validate.Min(1), validate.Max(MaxBatchEdits))
+	for i, edit := range req.Edits {
+		v.String(fmt.Sprintf("edits[%d].document_id", i), edit.DocumentID, validate.Required)
+		v.String(fmt.Sprintf("edits[%d].operation", i), edit.Operation, validate.OneOf("insert", "delete", "replace"))
+	}
+	return v.Err()
+}
+
+func batchEditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req batchEditRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+	for i := range req.Edits {
+		req.Edits[i].UserID = req.UserID
+	}
+
+	edits, err := service.ApplyBatch(req.Edits)
+	switch {
+	case errors.Is(err, ErrDocumentNotFound):
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotEditor):
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrVersionConflict):
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// batchFixture creates two documents owned by alice with some content
+func batchFixture(t *testing.T, s *GoogleDocsService) (first, second *Document) {
+	t.Helper()
+	first, _ = s.CreateDocument("First", "alice")
+	second, _ = s.CreateDocument("Second", "alice")
+	s.EditDocument(first.ID, "alice", "insert", "Hello World", 0)
+	s.EditDocument(second.ID, "alice", "insert", "Goodbye World", 0)
+	return first, second
+}
+
+func TestApplyBatch_AppliesEveryEdit(t *testing.T) {
+	s := NewGoogleDocsService()
+	first, second := batchFixture(t, s)
+
+	edits, err := s.ApplyBatch([]BatchEdit{
+		{DocumentID: first.ID, UserID: "alice", Operation: "delete", Position: 6, Content: "World", Version: 2},
+		{DocumentID: first.ID, UserID: "alice", Operation: "insert", Position: 6, Content: "Docs", Version: 3},
+		{DocumentID: second.ID, UserID: "alice", Operation: "replace", Content: "Goodbye Docs"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(edits) != 3 || edits[0].DocumentID != first.ID || edits[2].DocumentID != second.ID {
+		t.Errorf("Expected the 3 logged edits in batch order, got %v", edits)
+	}
+
+	if first.Content != "Hello Docs" || first.Version != 4 {
+		t.Errorf("Expected 'Hello Docs' at version 4, got %q at %d", first.Content, first.Version)
+	}
+	if second.Content != "Goodbye Docs" || second.Version != 3 {
+		t.Errorf("Expected 'Goodbye Docs' at version 3, got %q at %d", second.Content, second.Version)
+	}
+	// The log stays the source of truth
+	if got := s.ReplayTo(first.ID, 100); got != first.Content {
+		t.Errorf("Expected replay to match content %q, got %q", first.Content, got)
+	}
+}
+
+func TestApplyBatch_OneInvalidEditChangesNothing(t *testing.T) {
+	tests := []struct {
+		name string
+		edit BatchEdit // the second edit of the batch; the first is valid
+		want error
+	}{
+		{"not an editor", BatchEdit{UserID: "mallory", Operation: "replace", Content: "pwned"}, ErrNotEditor},
+		{"out of bounds", BatchEdit{UserID: "alice", Operation: "insert", Position: 100, Content: "!"}, ErrPositionOutOfRange},
+		{"stale version", BatchEdit{UserID: "alice", Operation: "replace", Content: "x", Version: 1}, ErrVersionConflict},
+		{"unknown operation", BatchEdit{UserID: "alice", Operation: "append", Content: "x"}, ErrUnknownOperation},
+		{"missing document", BatchEdit{DocumentID: "doc_missing", UserID: "alice", Operation: "replace"}, ErrDocumentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewGoogleDocsService()
+			first, second := batchFixture(t, s)
+			if tt.edit.DocumentID == "" {
+				tt.edit.DocumentID = second.ID
+			}
+
+			_, err := s.ApplyBatch([]BatchEdit{
+				{DocumentID: first.ID, UserID: "alice", Operation: "replace", Content: "Changed"},
+				tt.edit,
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+			var batchErr *BatchEditError
+			if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+				t.Errorf("Expected the error to name edit 1, got %v", err)
+			}
+
+			for _, doc := range []struct {
+				doc     *Document
+				content string
+			}{{first, "Hello World"}, {second, "Goodbye World"}} {
+				if doc.doc.Content != doc.content || doc.doc.Version != 2 {
+					t.Errorf("Expected %s unchanged, got %q at version %d", doc.doc.ID, doc.doc.Content, doc.doc.Version)
+				}
+				if history, _ := s.GetEditHistory(doc.doc.ID); len(history) != 1 {
+					t.Errorf("Expected nothing logged for %s, got %d edits", doc.doc.ID, len(history))
+				}
+			}
+			if s.editIndex != 2 {
+				t.Errorf("Expected no edit IDs used, got edit index %d", s.editIndex)
+			}
+		})
+	}
+}
+
+func TestApplyBatch_Limits(t *testing.T) {
+	s := NewGoogleDocsService()
+	if _, err := s.ApplyBatch(nil); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("Expected ErrEmptyBatch, got %v", err)
+	}
+	if _, err := s.ApplyBatch(make([]BatchEdit, MaxBatchEdits+1)); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("Expected ErrBatchTooLarge, got %v", err)
+	}
+}
+
+func TestBatchEditHandler(t *testing.T) {
+	service = NewGoogleDocsService()
+	first, second := batchFixture(t, service)
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/document/batch-edit", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"user_id": "bob", "edits": [{"document_id": "` + first.ID + `", "operation": "replace", "content": "x"}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a non-editor, got %d", w.Code)
+	}
+	w = post(`{"user_id": "alice", "edits": [{"document_id": "` + first.ID + `", "operation": "replace", "content": "x", "version": 1}]}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a stale version, got %d", w.Code)
+	}
+	w = post(`{"user_id": "alice", "edits": []}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty batch, got %d", w.Code)
+	}
+
+	w = post(`{"user_id": "alice", "edits": [
+		{"document_id": "` + first.ID + `", "operation": "replace", "content": "One"},
+		{"document_id": "` + second.ID + `", "operation": "replace", "content": "Two"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body)
+	}
+	var edits []*Edit
+	json.NewDecoder(w.Body).Decode(&edits)
+	if len(edits) != 2 || first.Content != "One" || second.Content != "Two" {
+		t.Errorf("Expected both edits applied, got %v, %q and %q", edits, first.Content, second.Content)
+	}
+}
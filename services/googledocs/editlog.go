@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"common/apierror"
+)
+
+// applyEdit returns content with one edit applied. It is the only place
+// edits change content, so a document's cached Content and a replay of its
+// edit log always agree. Positions outside the content leave it unchanged.
+func applyEdit(content string, edit *Edit) string {
+	switch edit.Operation {
+	case "insert":
+		if edit.Position >= 0 && edit.Position <= len(content) {
+			return content[:edit.Position] + edit.Content + content[edit.Position:]
+		}
+	case "delete":
+		if edit.Position >= 0 && edit.Position < len(content) {
+			endPos := edit.Position + len(edit.Content)
+			if endPos > len(content) {
+				endPos = len(content)
+			}
+			return content[:edit.Position] + content[endPos:]
+		}
+	case "replace":
+		return edit.Content
+	}
+	return content
+}
+
+// replayEdits applies edits in order to an empty document
+func replayEdits(edits []*Edit) string {
+	content := ""
+	for _, edit := range edits {
+		content = applyEdit(content, edit)
+	}
+	return content
+}
+
+// ReplayTo reconstructs a document's content as it was right after the
+// edit at editIndex in its history. An editIndex below 0 gives the empty
+// document it started as, and one past the last edit gives the current
+// content. Unknown documents replay to "".
+func (s *GoogleDocsService) ReplayTo(docID string, editIndex int) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	edits := s.edits[docID]
+	if editIndex >= len(edits) {
+		editIndex = len(edits) - 1
+	}
+	if editIndex < -1 {
+		editIndex = -1
+	}
+	return replayEdits(edits[:editIndex+1])
+}
+
+// replayResponse is the body returned by /document/replay
+type replayResponse struct {
+	DocumentID string `json:"document_id"`
+	EditIndex  int    `json:"edit_index"`
+	Content    string `json:"content"`
+}
+
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	docID := query.Get("doc_id")
+	if docID == "" {
+		apierror.Error(w, "doc_id parameter is required", http.StatusBadRequest)
+		return
+	}
+	editIndex, err := strconv.Atoi(query.Get("edit_index"))
+	if err != nil {
+		apierror.Error(w, "edit_index must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if doc, _ := service.GetDocument(docID); doc == nil {
+		apierror.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replayResponse{
+		DocumentID: docID,
+		EditIndex:  editIndex,
+		Content:    service.ReplayTo(docID, editIndex),
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReplayTo_MatchesContentAfterEachEdit(t *testing.T) {
+	service := NewGoogleDocsService()
+	doc, _ := service.CreateDocument("Doc", "owner")
+
+	steps := []struct {
+		operation, content string
+		position           int
+	}{
+		{"insert", "Hello", 0},
+		{"insert", " World", 5},
+		{"delete", "Wor", 6},
+		{"insert", "!", 100}, // out of range, ignored
+		{"replace", "Fresh start", 0},
+		{"insert", ", again", 11},
+		{"delete", "start, again and more", 6},
+	}
+
+	var seen []string
+	for _, step := range steps {
+		service.EditDocument(doc.ID, "owner", step.operation, step.content, step.position)
+		current, _ := service.GetDocument(doc.ID)
+		seen = append(seen, current.Content)
+	}
+
+	for i, want := range seen {
+		if got := service.ReplayTo(doc.ID, i); got != want {
+			t.Errorf("ReplayTo(%d) = %q, want %q", i, got, want)
+		}
+	}
+
+	final, _ := service.GetDocument(doc.ID)
+	if got := service.ReplayTo(doc.ID, len(steps)-1); got != final.Content || final.Content != "Fresh " {
+		t.Errorf("Expected the final replay to equal the stored content %q, got %q", final.Content, got)
+	}
+	for _, index := range []int{-1, -2, math.MinInt} {
+		if got := service.ReplayTo(doc.ID, index); got != "" {
+			t.Errorf("ReplayTo(%d): expected the initial state to be empty, got %q", index, got)
+		}
+	}
+	if got := service.ReplayTo(doc.ID, 1000); got != final.Content {
+		t.Errorf("Expected an index past the end to give the current content, got %q", got)
+	}
+	if got := service.ReplayTo("doc_missing", 0); got != "" {
+		t.Errorf("Expected an unknown document to replay to empty, got %q", got)
+	}
+}
+
+func TestEditDocument_NegativePositionIgnored(t *testing.T) {
+	service := NewGoogleDocsService()
+	doc, _ := service.CreateDocument("Doc", "owner")
+	service.EditDocument(doc.ID, "owner", "insert", "Hello", 0)
+
+	service.EditDocument(doc.ID, "owner", "insert", "x", -1)
+	service.EditDocument(doc.ID, "owner", "delete", "x", -1)
+
+	if doc.Content != "Hello" || service.ReplayTo(doc.ID, 2) != "Hello" {
+		t.Errorf("Expected negative positions to leave the content alone, got %q", doc.Content)
+	}
+}
+
+func TestRestore_ReplaysContentFromEdits(t *testing.T) {
+	service := NewGoogleDocsService()
+	doc, _ := service.CreateDocument("Doc", "owner")
+	service.EditDocument(doc.ID, "owner", "insert", "Hello", 0)
+
+	// Corrupt the cached content so it disagrees with the log
+	doc.Content = "diverged"
+	data, _ := service.Snapshot()
+
+	restored := NewGoogleDocsService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got, _ := restored.GetDocument(doc.ID); got.Content != "Hello" {
+		t.Errorf("Expected the content to be rebuilt from the edit log, got %q", got.Content)
+	}
+}
+
+func TestReplayHandler(t *testing.T) {
+	service = NewGoogleDocsService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	doc, _ := service.CreateDocument("Doc", "owner")
+	service.EditDocument(doc.ID, "owner", "insert", "Hello", 0)
+	service.EditDocument(doc.ID, "owner", "insert", " World", 5)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/document/replay?doc_id="+doc.ID+"&edit_index=0", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp replayResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Content != "Hello" {
+		t.Errorf("Expected %q, got %q", "Hello", resp.Content)
+	}
+
+	for _, target := range []string{"/document/replay?doc_id=" + doc.ID, "/document/replay?edit_index=0"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/document/replay?doc_id="+doc.ID+"&edit_index=-5", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a negative edit_index, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/document/replay?doc_id=doc_missing&edit_index=0", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown document, got %d", w.Code)
+	}
+}
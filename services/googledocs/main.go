@@ -2,13 +2,28 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/health"
+	"common/middleware"
+	"common/openapi"
+	"common/validate"
 )
 
-// Document represents a collaborative document
+// maxTitleLength caps document titles
+const maxTitleLength = 200
+
+// Document represents a collaborative document. Its edit log is the
+// source of truth: Content is a cache of replaying every edit in order.
 type Document struct {
 	ID        string    `json:"id"`
 	Title     string    `json:"title"`
@@ -93,7 +108,7 @@ func (s *GoogleDocsService) EditDocument(docID, userID, operation, content strin
 
 	doc, exists := s.documents[docID]
 	if !exists {
-		return nil, nil
+		return nil, ErrDocumentNotFound
 	}
 
 	s.editIndex++
@@ -109,29 +124,13 @@ func (s *GoogleDocsService) EditDocument(docID, userID, operation, content strin
 		Timestamp:  time.Now(),
 	}
 
-	// Apply edit to document
-	switch operation {
-	case "insert":
-		if position <= len(doc.Content) {
-			doc.Content = doc.Content[:position] + content + doc.Content[position:]
-		}
-	case "delete":
-		if position < len(doc.Content) {
-			endPos := position + len(content)
-			if endPos > len(doc.Content) {
-				endPos = len(doc.Content)
-			}
-			doc.Content = doc.Content[:position] + doc.Content[endPos:]
-		}
-	case "replace":
-		doc.Content = content
-	}
+	// Log the edit, then apply it to the cached content
+	s.edits[docID] = append(s.edits[docID], edit)
+	doc.Content = applyEdit(doc.Content, edit)
 
 	doc.UpdatedAt = time.Now()
 	doc.Version++
 
-	s.edits[docID] = append(s.edits[docID], edit)
-
 	return edit, nil
 }
 
@@ -163,37 +162,139 @@ func (s *GoogleDocsService) GetEditHistory(docID string) ([]*Edit, error) {
 
 	edits, exists := s.edits[docID]
 	if !exists {
-		return []*Edit{}, nil
+		return nil, ErrDocumentNotFound
 	}
 
 	return edits, nil
 }
 
+// docsState is the serialized form of the whole service: documents ordered
+// by creation and their edit logs, grouped by document in edit order
+type docsState struct {
+	Documents []*Document `json:"documents"`
+	Edits     []*Edit     `json:"edits"`
+	DocIndex  int64       `json:"doc_index"`
+	EditIndex int64       `json:"edit_index"`
+}
+
+// Snapshot serializes every document, edit and the ID counters to JSON
+func (s *GoogleDocsService) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := docsState{
+		Documents: make([]*Document, 0, len(s.documents)),
+		Edits:     make([]*Edit, 0),
+		DocIndex:  s.docIndex,
+		EditIndex: s.editIndex,
+	}
+
+	for _, doc := range s.documents {
+		state.Documents = append(state.Documents, doc)
+	}
+	sort.Slice(state.Documents, func(i, j int) bool {
+		a, b := state.Documents[i], state.Documents[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	for _, doc := range state.Documents {
+		state.Edits = append(state.Edits, s.edits[doc.ID]...)
+	}
+
+	return json.Marshal(state)
+}
+
+// Restore replaces the service's state with a snapshot and rebuilds each
+// document's edit log. Content is rebuilt by replaying the log, so a
+// snapshot whose content disagrees with its edits takes the edits' word.
+// Snapshots with edits to unknown documents are rejected and leave the
+// current state in place.
+func (s *GoogleDocsService) Restore(data []byte) error {
+	var state docsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	documents := make(map[string]*Document, len(state.Documents))
+	edits := make(map[string][]*Edit, len(state.Documents))
+	for _, doc := range state.Documents {
+		if doc == nil || doc.ID == "" {
+			return fmt.Errorf("document without an id")
+		}
+		if _, exists := documents[doc.ID]; exists {
+			return fmt.Errorf("duplicate document %s", doc.ID)
+		}
+		documents[doc.ID] = doc
+		edits[doc.ID] = []*Edit{}
+	}
+
+	for _, edit := range state.Edits {
+		if edit == nil {
+			return fmt.Errorf("empty edit")
+		}
+		if _, exists := documents[edit.DocumentID]; !exists {
+			return fmt.Errorf("edit %s belongs to unknown document %s", edit.ID, edit.DocumentID)
+		}
+		edits[edit.DocumentID] = append(edits[edit.DocumentID], edit)
+	}
+	for docID, doc := range documents {
+		doc.Content = replayEdits(edits[docID])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents = documents
+	s.edits = edits
+	s.docIndex = state.DocIndex
+	s.editIndex = state.EditIndex
+
+	return nil
+}
+
 func generateID(prefix string, index int64) string {
 	return prefix + "_" + string(rune(index+'0'))
 }
 
 var service *GoogleDocsService
 
+// createDocumentRequest is the body of /document/create
+type createDocumentRequest struct {
+	Title   string `json:"title"`
+	OwnerID string `json:"owner_id"`
+}
+
+func (req createDocumentRequest) validate() error {
+	var v validate.Validator
+	v.String("title", req.Title, validate.Required, validate.MaxLen(maxTitleLength))
+	v.String("owner_id", req.OwnerID, validate.Required)
+	return v.Err()
+}
+
 func createDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Title   string `json:"title"`
-		OwnerID string `json:"owner_id"`
-	}
+	var req createDocumentRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.OwnerID = middleware.AuthenticatedUserID(r, req.OwnerID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
 		return
 	}
 
 	doc, err := service.CreateDocument(req.Title, req.OwnerID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -204,18 +305,18 @@ func createDocumentHandler(w http.ResponseWriter, r *http.Request) {
 func getDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	docID := r.URL.Query().Get("doc_id")
 	if docID == "" {
-		http.Error(w, "doc_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "doc_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	doc, err := service.GetDocument(docID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	if doc == nil {
-		http.Error(w, "document not found", http.StatusNotFound)
+		apierror.Error(w, "document not found", http.StatusNotFound)
 		return
 	}
 
@@ -223,28 +324,35 @@ func getDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(doc)
 }
 
+// editDocumentRequest is the body of /document/edit
+type editDocumentRequest struct {
+	DocumentID string `json:"document_id"`
+	UserID     string `json:"user_id"`
+	Operation  string `json:"operation"`
+	Content    string `json:"content"`
+	Position   int    `json:"position"`
+}
+
 func editDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		DocumentID string `json:"document_id"`
-		UserID     string `json:"user_id"`
-		Operation  string `json:"operation"`
-		Content    string `json:"content"`
-		Position   int    `json:"position"`
-	}
+	var req editDocumentRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	edit, err := service.EditDocument(req.DocumentID, req.UserID, req.Operation, req.Content, req.Position)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	edit, err := service.EditDocument(req.DocumentID, middleware.AuthenticatedUserID(r, req.UserID), req.Operation, req.Content, req.Position)
+	switch {
+	case errors.Is(err, ErrDocumentNotFound):
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -252,24 +360,27 @@ func editDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(edit)
 }
 
+// shareDocumentRequest is the body of /document/share
+type shareDocumentRequest struct {
+	DocumentID string `json:"document_id"`
+	UserID     string `json:"user_id"`
+}
+
 func shareDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		DocumentID string `json:"document_id"`
-		UserID     string `json:"user_id"`
-	}
+	var req shareDocumentRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if err := service.ShareDocument(req.DocumentID, req.UserID); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -279,13 +390,13 @@ func shareDocumentHandler(w http.ResponseWriter, r *http.Request) {
 func getEditHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	docID := r.URL.Query().Get("doc_id")
 	if docID == "" {
-		http.Error(w, "doc_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "doc_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	edits, err := service.GetEditHistory(docID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -298,18 +409,108 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Google Docs Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(&service.mu, time.Second))
+
+	// Mutating endpoints act as the user in the bearer token
+	auth := middleware.AuthFromEnv("JWT_SECRET")
+
+	// Cap request bodies and bound request time on create endpoints
+	guard := middleware.LimitRequest(middleware.DefaultMaxBodyBytes, middleware.DefaultRequestTimeout)
+
+	// Retried creates with the same Idempotency-Key replay the first response
+	idempotency := middleware.NewIdempotencyStore(middleware.DefaultIdempotencyTTL)
+	idempotency.StartJanitor(time.Minute)
+	idempotent := middleware.Idempotency(idempotency)
+
+	// State dumps need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	docQuery := []openapi.Param{{Name: "doc_id", Required: true}}
+
+	api.Handle("/document/create", auth(guard(idempotent(http.HandlerFunc(createDocumentHandler)))), openapi.Route{
+		Method: http.MethodPost, Summary: "Create a document",
+		Request: createDocumentRequest{}, Response: Document{},
+		Responses: map[int]string{
+			200: "Document created",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			409: "Same Idempotency-Key still in progress",
+			413: "Body too large",
+			422: "Idempotency-Key reused with a different body",
+		},
+	})
+	api.HandleFunc("/document/get", getDocumentHandler, openapi.Route{
+		Summary: "Get a document", Query: docQuery, Response: Document{},
+		Responses: map[int]string{200: "The document", 400: "Missing doc_id", 404: "Document not found"},
+	})
+	api.Handle("/document/edit", auth(http.HandlerFunc(editDocumentHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Apply an edit to a document",
+		Request: editDocumentRequest{}, Response: Edit{},
+		Responses: map[int]string{200: "Edit applied", 400: "Invalid request", 401: "Missing or invalid token", 404: "Document not found"},
+	})
+	api.Handle("/document/batch-edit", auth(guard(http.HandlerFunc(batchEditHandler))), openapi.Route{
+		Method: http.MethodPost, Summary: "Apply edits across documents, all or none",
+		Request: batchEditRequest{}, Response: []Edit{},
+		Responses: map[int]string{
+			200: "Every edit applied",
+			400: "Invalid request, operation or position; nothing applied",
+			401: "Missing or invalid token",
+			403: "Not an editor of a document; nothing applied",
+			404: "Document not found; nothing applied",
+			409: "Document version changed; nothing applied",
+			413: "Body too large",
+		},
+	})
+	api.Handle("/document/share", auth(http.HandlerFunc(shareDocumentHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Share a document with an editor", Request: shareDocumentRequest{},
+		Responses: map[int]string{200: "Document shared", 400: "Invalid request", 401: "Missing or invalid token"},
+	})
+	api.HandleFunc("/document/history", getEditHistoryHandler, openapi.Route{
+		Summary: "List the edits made to a document", Query: docQuery, Response: []Edit{},
+		Responses: map[int]string{200: "The edit history", 400: "Missing doc_id", 404: "Document not found"},
+	})
+	api.HandleFunc("/document/replay", replayHandler, openapi.Route{
+		Summary: "Rebuild a document's content as of an edit in its history",
+		Query: []openapi.Param{
+			{Name: "doc_id", Required: true},
+			{Name: "edit_index", Required: true, Description: "Index of the last edit to apply; -1 for the empty document"},
+		},
+		Response:  replayResponse{},
+		Responses: map[int]string{200: "The content after that edit", 400: "Missing doc_id or invalid edit_index", 404: "Document not found"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(docsState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	service = NewGoogleDocsService()
-
-	http.HandleFunc("/document/create", createDocumentHandler)
-	http.HandleFunc("/document/get", getDocumentHandler)
-	http.HandleFunc("/document/edit", editDocumentHandler)
-	http.HandleFunc("/document/share", shareDocumentHandler)
-	http.HandleFunc("/document/history", getEditHistoryHandler)
-	http.HandleFunc("/health", healthHandler)
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8087"
 	log.Printf("Google Docs service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
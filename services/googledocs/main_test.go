@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -198,8 +199,8 @@ func TestEditDocument_NotFound(t *testing.T) {
 	service := NewGoogleDocsService()
 	
 	edit, err := service.EditDocument("nonexistent", "user1", "insert", "Hello", 0)
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
+	if err != ErrDocumentNotFound {
+		t.Fatalf("Expected ErrDocumentNotFound, got %v", err)
 	}
 	if edit != nil {
 		t.Errorf("Expected nil edit, got %v", edit)
@@ -269,11 +270,11 @@ func TestGetEditHistory_NotFound(t *testing.T) {
 	service := NewGoogleDocsService()
 	
 	edits, err := service.GetEditHistory("nonexistent")
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
+	if err != ErrDocumentNotFound {
+		t.Fatalf("Expected ErrDocumentNotFound, got %v", err)
 	}
-	if len(edits) != 0 {
-		t.Errorf("Expected 0 edits, got %d", len(edits))
+	if edits != nil {
+		t.Errorf("Expected nil edits, got %v", edits)
 	}
 }
 
@@ -404,6 +405,25 @@ func TestEditDocumentHandler(t *testing.T) {
 	}
 }
 
+func TestEditDocumentHandler_NotFound(t *testing.T) {
+	service = NewGoogleDocsService()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"document_id": "nonexistent",
+		"user_id":     "user1",
+		"operation":   "insert",
+		"content":     "Hello",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/document/edit", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	editDocumentHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
 func TestEditDocumentHandler_InvalidMethod(t *testing.T) {
 	service = NewGoogleDocsService()
 	
@@ -497,6 +517,19 @@ func TestGetEditHistoryHandler(t *testing.T) {
 	}
 }
 
+func TestGetEditHistoryHandler_NotFound(t *testing.T) {
+	service = NewGoogleDocsService()
+
+	req := httptest.NewRequest(http.MethodGet, "/document/history?doc_id=nonexistent", nil)
+	w := httptest.NewRecorder()
+
+	getEditHistoryHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
 func TestGetEditHistoryHandler_MissingDocID(t *testing.T) {
 	service = NewGoogleDocsService()
 	
@@ -526,3 +559,43 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("Expected status 'healthy', got %s", resp["status"])
 	}
 }
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewGoogleDocsService()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"common/cache"
 )
 
 // CacheConfig holds configuration for all cache layers
@@ -12,8 +14,10 @@ type CacheConfig struct {
 	HealthCacheTTL     time.Duration
 	HealthCacheEnabled bool
 
-	// Stats cache settings
+	// Stats cache settings. Past StatsCacheSoftTTL, cached stats are served
+	// while they are recomputed in the background; zero turns that off.
 	StatsCacheTTL     time.Duration
+	StatsCacheSoftTTL time.Duration
 	StatsCacheEnabled bool
 
 	// Routing cache settings
@@ -27,6 +31,7 @@ func DefaultCacheConfig() CacheConfig {
 		HealthCacheTTL:      5 * time.Second,
 		HealthCacheEnabled:  true,
 		StatsCacheTTL:       1 * time.Second,
+		StatsCacheSoftTTL:   500 * time.Millisecond,
 		StatsCacheEnabled:   true,
 		RoutingCacheTTL:     2 * time.Second,
 		RoutingCacheEnabled: true,
@@ -164,8 +169,14 @@ type StatsCache struct {
 	snapshot   []map[string]interface{}
 	lastUpdate time.Time
 	ttl        time.Duration
+	softTTL    time.Duration // 0 means stats are never served stale
 	enabled    bool
 	dirty      bool
+	generation uint64 // bumped by Invalidate so an older computation isn't stored
+
+	// flight lets one caller recompute expired stats while the rest wait,
+	// and runs the background recompute of stale stats
+	flight cache.Group[struct{}, []map[string]interface{}]
 
 	// Metrics
 	hitCount  int64
@@ -181,23 +192,94 @@ func NewStatsCache(ttl time.Duration, enabled bool) *StatsCache {
 	}
 }
 
-// Get retrieves cached stats
+// SetSoftTTL makes GetOrCompute serve stats older than softTTL while it
+// recomputes them in the background, blocking only once they are past the
+// cache's TTL or invalidated. Zero turns stale serving off.
+func (sc *StatsCache) SetSoftTTL(softTTL time.Duration) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.softTTL = softTTL
+}
+
+// Get retrieves cached stats, stale or not
 func (sc *StatsCache) Get() ([]map[string]interface{}, bool) {
+	stats, freshness := sc.get()
+	return stats, freshness != cache.Expired
+}
+
+// get returns the stored stats and how fresh they are, counting a hit
+// unless they are expired
+func (sc *StatsCache) get() ([]map[string]interface{}, cache.Freshness) {
 	if !sc.enabled {
-		return nil, false
+		return nil, cache.Expired
 	}
 
+	stats, freshness := sc.peek()
+	if freshness == cache.Expired {
+		atomic.AddInt64(&sc.missCount, 1)
+		return nil, cache.Expired
+	}
+
+	atomic.AddInt64(&sc.hitCount, 1)
+	return stats, freshness
+}
+
+// peek returns the stored stats and how fresh they are, without counting a
+// hit or miss. Dirty stats are expired.
+func (sc *StatsCache) peek() ([]map[string]interface{}, cache.Freshness) {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
 
-	// Check if cache is dirty or expired
-	if sc.dirty || time.Since(sc.lastUpdate) > sc.ttl {
-		atomic.AddInt64(&sc.missCount, 1)
-		return nil, false
+	if sc.dirty {
+		return nil, cache.Expired
+	}
+	ttl := cache.SoftTTL{Soft: sc.softTTL, Hard: sc.ttl}
+	freshness := ttl.Freshness(time.Since(sc.lastUpdate))
+	if freshness == cache.Expired {
+		return nil, cache.Expired
 	}
+	return sc.snapshot, freshness
+}
 
-	atomic.AddInt64(&sc.hitCount, 1)
-	return sc.snapshot, true
+// GetOrCompute returns the cached stats, or calls compute to refresh them.
+// Callers that miss at the same time share a single compute call instead
+// of each recomputing. Stale stats are returned at once and recomputed in
+// the background, once however many callers see them.
+func (sc *StatsCache) GetOrCompute(compute func() []map[string]interface{}) []map[string]interface{} {
+	stats, freshness := sc.get()
+	switch freshness {
+	case cache.Fresh:
+		return stats
+	case cache.Stale:
+		sc.flight.Refresh(struct{}{}, func() ([]map[string]interface{}, error) {
+			return sc.recompute(compute), nil
+		})
+		return stats
+	}
+
+	stats, _, _ = sc.flight.Do(struct{}{}, func() ([]map[string]interface{}, error) {
+		// A computation that finished while this caller was missing may
+		// already have refreshed the cache
+		if sc.enabled {
+			if stats, freshness := sc.peek(); freshness != cache.Expired {
+				return stats, nil
+			}
+		}
+		return sc.recompute(compute), nil
+	})
+	return stats
+}
+
+// recompute calls compute and stores its result, unless the cache was
+// invalidated meanwhile
+func (sc *StatsCache) recompute(compute func() []map[string]interface{}) []map[string]interface{} {
+	sc.mu.RLock()
+	generation := sc.generation
+	sc.mu.RUnlock()
+
+	stats := compute()
+	sc.setIfGeneration(stats, generation)
+	return stats
 }
 
 // Set stores stats in cache
@@ -214,11 +296,30 @@ func (sc *StatsCache) Set(stats []map[string]interface{}) {
 	sc.dirty = false
 }
 
+// setIfGeneration stores stats unless the cache was invalidated after they
+// started being computed
+func (sc *StatsCache) setIfGeneration(stats []map[string]interface{}, generation uint64) {
+	if !sc.enabled {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.generation != generation {
+		return
+	}
+	sc.snapshot = stats
+	sc.lastUpdate = time.Now()
+	sc.dirty = false
+}
+
 // Invalidate marks cache as dirty
 func (sc *StatsCache) Invalidate() {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 	sc.dirty = true
+	sc.generation++
 }
 
 // GetMetrics returns cache metrics
@@ -361,12 +462,14 @@ type CacheManager struct {
 
 // NewCacheManager creates a new cache manager
 func NewCacheManager(config CacheConfig) *CacheManager {
-	return &CacheManager{
+	cm := &CacheManager{
 		healthCache:  NewHealthCache(config.HealthCacheTTL, config.HealthCacheEnabled),
 		statsCache:   NewStatsCache(config.StatsCacheTTL, config.StatsCacheEnabled),
 		routingCache: NewRoutingCache(config.RoutingCacheTTL, config.RoutingCacheEnabled),
 		config:       config,
 	}
+	cm.statsCache.SetSoftTTL(config.StatsCacheSoftTTL)
+	return cm
 }
 
 // Health returns the health cache
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -144,6 +148,103 @@ func TestStatsCacheDisabled(t *testing.T) {
 	}
 }
 
+// TestStatsCacheGetOrComputeCoalesces tests that callers missing an expired
+// cache at the same time share one computation
+func TestStatsCacheGetOrComputeCoalesces(t *testing.T) {
+	cache := NewStatsCache(50*time.Millisecond, true)
+	cache.Set([]map[string]interface{}{{"url": "stale"}})
+	time.Sleep(60 * time.Millisecond)
+
+	var computes int64
+	release := make(chan struct{})
+	compute := func() []map[string]interface{} {
+		atomic.AddInt64(&computes, 1)
+		<-release
+		return []map[string]interface{}{{"url": "fresh"}}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if stats := cache.GetOrCompute(compute); stats[0]["url"] != "fresh" {
+				t.Errorf("Expected fresh stats, got %v", stats)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if computes != 1 {
+		t.Errorf("Expected stats computed once, got %d", computes)
+	}
+}
+
+// TestStatsCacheSoftTTLServesStale tests that stats just past the soft TTL
+// are returned immediately while one background recompute runs
+func TestStatsCacheSoftTTLServesStale(t *testing.T) {
+	cache := NewStatsCache(time.Minute, true)
+	cache.SetSoftTTL(20 * time.Millisecond)
+	cache.Set([]map[string]interface{}{{"url": "stale"}})
+	time.Sleep(30 * time.Millisecond)
+
+	var computes int64
+	release := make(chan struct{})
+	compute := func() []map[string]interface{} {
+		atomic.AddInt64(&computes, 1)
+		<-release
+		return []map[string]interface{}{{"url": "fresh"}}
+	}
+
+	// compute blocks until release, so any caller waiting on it would hang
+	for i := 0; i < 10; i++ {
+		if stats := cache.GetOrCompute(compute); stats[0]["url"] != "stale" {
+			t.Fatalf("Expected the stale stats, got %v", stats)
+		}
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if stats, _ := cache.Get(); stats != nil && stats[0]["url"] == "fresh" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background recompute to store fresh stats")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&computes); n != 1 {
+		t.Errorf("Expected one background recompute, got %d", n)
+	}
+
+	// Invalidated stats are never served stale
+	cache.Invalidate()
+	if stats := cache.GetOrCompute(func() []map[string]interface{} {
+		return []map[string]interface{}{{"url": "recomputed"}}
+	}); stats[0]["url"] != "recomputed" {
+		t.Errorf("Expected invalidated stats to be recomputed, got %v", stats)
+	}
+}
+
+// TestStatsCacheInvalidateDuringCompute tests that stats computed before an
+// invalidation aren't cached
+func TestStatsCacheInvalidateDuringCompute(t *testing.T) {
+	cache := NewStatsCache(time.Minute, true)
+
+	cache.GetOrCompute(func() []map[string]interface{} {
+		cache.Invalidate()
+		return []map[string]interface{}{{"url": "outdated"}}
+	})
+
+	if _, found := cache.Get(); found {
+		t.Error("Expected stats computed across an invalidation not to be cached")
+	}
+}
+
 // TestRoutingCache tests the routing cache functionality
 func TestRoutingCache(t *testing.T) {
 	cache := NewRoutingCache(100*time.Millisecond, true)
@@ -196,6 +297,45 @@ func TestRoutingCache(t *testing.T) {
 	}
 }
 
+// TestWarmCaches checks that warming fills the routing and stats caches
+// from an immediate health check, without waiting for a tick
+func TestWarmCaches(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	lb := NewLoadBalancer()
+	lb.AddBackend(up.URL)
+	lb.AddBackend(down.URL)
+
+	if _, found := lb.cacheManager.Routing().Get(); found {
+		t.Fatal("Expected a cold routing cache before warming")
+	}
+
+	lb.WarmCaches()
+
+	cached, found := lb.cacheManager.Routing().Get()
+	if !found {
+		t.Fatal("Expected the routing cache to be populated after warming")
+	}
+	if len(cached) != 1 || cached[0].URL.String() != up.URL {
+		t.Errorf("Expected only the healthy backend cached, got %d backends", len(cached))
+	}
+
+	stats, found := lb.cacheManager.Stats().Get()
+	if !found || len(stats) != 2 {
+		t.Fatalf("Expected cached stats for both backends, got %v (found %v)", stats, found)
+	}
+	for _, backend := range stats {
+		if alive := backend["alive"]; alive != (backend["url"] == up.URL) {
+			t.Errorf("Expected cached stats to reflect the health check, got %v", backend)
+		}
+	}
+}
+
 // TestRoutingCacheEmptyBackends tests routing cache with empty backends
 func TestRoutingCacheEmptyBackends(t *testing.T) {
 	cache := NewRoutingCache(1*time.Second, true)
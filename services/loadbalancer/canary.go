@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"common/apierror"
+)
+
+// canaryConfig sends a share of the default pool's traffic to a canary pool
+type canaryConfig struct {
+	backend string
+	pool    *ServerPool
+	percent float64
+}
+
+// CanaryStatus describes the current canary split
+type CanaryStatus struct {
+	Backend string  `json:"backend,omitempty"`
+	Percent float64 `json:"percent"`
+}
+
+// SetCanary routes percent (0 to 100) of the requests that would go to the
+// default pool to the backend at urlStr instead. Path routes are not
+// affected. Calling it again for the same backend only changes the split,
+// so the canary keeps its health and latency history as the percentage is
+// raised. An empty urlStr or a zero percent turns the canary off.
+func (lb *LoadBalancer) SetCanary(urlStr string, percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("canary percent must be between 0 and 100, got %v", percent)
+	}
+
+	lb.canaryMu.Lock()
+	defer lb.canaryMu.Unlock()
+
+	if urlStr == "" || percent == 0 {
+		lb.canary = nil
+		return nil
+	}
+
+	if lb.canary != nil && lb.canary.backend == urlStr {
+		lb.canary = &canaryConfig{backend: urlStr, pool: lb.canary.pool, percent: percent}
+		return nil
+	}
+
+	pool, err := lb.NewPool(urlStr)
+	if err != nil {
+		return err
+	}
+	if pool.GetBackends()[0].URL.Host == "" {
+		return fmt.Errorf("canary backend %q has no host", urlStr)
+	}
+	lb.canary = &canaryConfig{backend: urlStr, pool: pool, percent: percent}
+	return nil
+}
+
+// Canary returns the current canary split
+func (lb *LoadBalancer) Canary() CanaryStatus {
+	lb.canaryMu.RLock()
+	defer lb.canaryMu.RUnlock()
+
+	if lb.canary == nil {
+		return CanaryStatus{}
+	}
+	return CanaryStatus{Backend: lb.canary.backend, Percent: lb.canary.percent}
+}
+
+func (lb *LoadBalancer) canaryConfig() *canaryConfig {
+	lb.canaryMu.RLock()
+	defer lb.canaryMu.RUnlock()
+	return lb.canary
+}
+
+// canaryPeer returns the canary backend if this request is sampled for the
+// canary and the canary is healthy, otherwise nil
+func (lb *LoadBalancer) canaryPeer(pool *ServerPool) *Backend {
+	if pool != lb.serverPool {
+		return nil
+	}
+
+	canary := lb.canaryConfig()
+	if canary == nil || rand.Float64()*100 >= canary.percent {
+		return nil
+	}
+	return lb.nextPeer(canary.pool)
+}
+
+// setCanaryRequest is the body of /canary
+type setCanaryRequest struct {
+	Backend string  `json:"backend"`
+	Percent float64 `json:"percent"`
+}
+
+func canaryHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req setCanaryRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := lb.SetCanary(req.Backend, req.Percent); err != nil {
+			apierror.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb.Canary())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"common/middleware"
+)
+
+func TestCanary_SplitsTraffic(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	lb = NewLoadBalancer()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	stableURL, stableHits := countingBackend(t, http.StatusOK)
+	canaryURL, canaryHits := countingBackend(t, http.StatusOK)
+	lb.AddBackend(stableURL)
+
+	setCanary := func(percent float64) {
+		body, _ := json.Marshal(setCanaryRequest{Backend: canaryURL, Percent: percent})
+		req := httptest.NewRequest(http.MethodPost, "/canary", bytes.NewReader(body))
+		req.Header.Set(middleware.AdminTokenHeader, "s3cret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 setting a %v%% canary, got %d: %s", percent, w.Code, w.Body)
+		}
+	}
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			w := httptest.NewRecorder()
+			lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", w.Code)
+			}
+		}
+	}
+
+	const requests = 2000
+	setCanary(20)
+	send(requests)
+
+	share := float64(atomic.LoadInt64(canaryHits)) / requests
+	if math.Abs(share-0.2) > 0.05 {
+		t.Errorf("Expected about 20%% of requests on the canary, got %.1f%%", share*100)
+	}
+	if got := atomic.LoadInt64(stableHits) + atomic.LoadInt64(canaryHits); got != requests {
+		t.Errorf("Expected %d requests in total, got %d", requests, got)
+	}
+
+	atomic.StoreInt64(canaryHits, 0)
+	setCanary(0)
+	send(requests)
+	if got := atomic.LoadInt64(canaryHits); got != 0 {
+		t.Errorf("Expected no canary traffic at 0%%, got %d requests", got)
+	}
+	if status := lb.Canary(); status.Backend != "" || status.Percent != 0 {
+		t.Errorf("Expected the canary to be off, got %+v", status)
+	}
+}
+
+func TestCanaryHandler_ChangesNeedAdminToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	lb = NewLoadBalancer()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	body, _ := json.Marshal(setCanaryRequest{Backend: "http://localhost:9001", Percent: 100})
+	for _, token := range []string{"", "wrong"} {
+		req := httptest.NewRequest(http.MethodPost, "/canary", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Token %q: expected 403, got %d", token, w.Code)
+		}
+	}
+	if status := lb.Canary(); status.Backend != "" {
+		t.Errorf("Expected the canary to stay off, got %+v", status)
+	}
+
+	// Reading the split needs no token
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/canary", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 reading the canary, got %d", w.Code)
+	}
+}
+
+func TestCanary_KeepsPoolWhenPercentChanges(t *testing.T) {
+	lb = NewLoadBalancer()
+	if err := lb.SetCanary("http://localhost:9001", 10); err != nil {
+		t.Fatal(err)
+	}
+	pool := lb.canaryConfig().pool
+
+	if err := lb.SetCanary("http://localhost:9001", 50); err != nil {
+		t.Fatal(err)
+	}
+	if lb.canaryConfig().pool != pool {
+		t.Error("Expected raising the percentage to keep the canary pool")
+	}
+	if got := lb.Canary().Percent; got != 50 {
+		t.Errorf("Expected 50%%, got %v", got)
+	}
+}
+
+func TestCanary_FallsBackWhenCanaryDown(t *testing.T) {
+	lb = NewLoadBalancer()
+	stableURL, stableHits := countingBackend(t, http.StatusOK)
+	lb.AddBackend(stableURL)
+	lb.SetCanary("http://localhost:9001", 100)
+	lb.canaryConfig().pool.GetBackends()[0].SetAlive(false)
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || atomic.LoadInt64(stableHits) != 1 {
+		t.Errorf("Expected the stable pool to serve while the canary is down, got %d", w.Code)
+	}
+}
+
+func TestCanary_RejectsInvalidPercent(t *testing.T) {
+	lb = NewLoadBalancer()
+	for _, percent := range []float64{-1, 101} {
+		if err := lb.SetCanary("http://localhost:9001", percent); err == nil {
+			t.Errorf("Expected an error for %v%%", percent)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// defaultFailureLogSize is how many failed requests the dead-letter log keeps
+const defaultFailureLogSize = 100
+
+// Failure is one failed proxied request
+type Failure struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Backend string    `json:"backend"`
+	Status  int       `json:"status,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// FailureLog is a dead-letter log of the most recent failed proxied
+// requests. Once full, each new failure overwrites the oldest.
+type FailureLog struct {
+	mu      sync.Mutex
+	entries []Failure
+	next    int // slot the next failure is written to
+	full    bool
+}
+
+// NewFailureLog creates a log that keeps the last size failures
+func NewFailureLog(size int) *FailureLog {
+	if size <= 0 {
+		size = defaultFailureLogSize
+	}
+	return &FailureLog{entries: make([]Failure, size)}
+}
+
+// Add records a failure, evicting the oldest once the log is full
+func (l *FailureLog) Add(f Failure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = f
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Entries returns the recorded failures, newest first
+func (l *FailureLog) Entries() []Failure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+
+	out := make([]Failure, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return out
+}
+
+// recordFailure adds a failed request to the load balancer's dead-letter log
+func (lb *LoadBalancer) recordFailure(r *http.Request, backend *url.URL, status int, err error) {
+	f := Failure{
+		Time:    lb.now(),
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Backend: backend.String(),
+		Status:  status,
+	}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	lb.failures.Add(f)
+}
+
+// proxyErrorHandler answers 502 when a backend can't be reached, like the
+// reverse proxy's default handler, and logs the failure. Cancelled requests,
+// such as the losing hedged attempt, aren't the backend's fault and are not
+// logged.
+func (lb *LoadBalancer) proxyErrorHandler(backend *url.URL) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("proxy error from %s: %v", backend, err)
+		if !errors.Is(err, context.Canceled) {
+			lb.recordFailure(r, backend, http.StatusBadGateway, err)
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+// failedResponseRecorder logs backend responses with a 5xx status
+func (lb *LoadBalancer) failedResponseRecorder(backend *url.URL) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		if resp.StatusCode >= http.StatusInternalServerError {
+			lb.recordFailure(resp.Request, backend, resp.StatusCode, fmt.Errorf("backend returned %s", resp.Status))
+		}
+		return nil
+	}
+}
+
+func failuresHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb.failures.Entries())
+}
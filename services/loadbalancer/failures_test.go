@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailureLog_KeepsNewestEntries(t *testing.T) {
+	log := NewFailureLog(3)
+	for i := 0; i < 5; i++ {
+		log.Add(Failure{Path: fmt.Sprintf("/%d", i)})
+	}
+
+	entries := log.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	for i, want := range []string{"/4", "/3", "/2"} {
+		if entries[i].Path != want {
+			t.Errorf("entry %d: expected %s, got %s", i, want, entries[i].Path)
+		}
+	}
+}
+
+func TestFailures_RecordsProxyErrorsAndServerErrors(t *testing.T) {
+	lb = NewLoadBalancer()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// A server that's closed refuses connections
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	lb.AddBackend(failing.URL)
+	lb.AddBackend(down.URL)
+
+	for _, path := range []string{"/a", "/b"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code < http.StatusInternalServerError {
+			t.Fatalf("GET %s: expected a 5xx, got %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failures", nil))
+	var failures []Failure
+	if err := json.NewDecoder(w.Body).Decode(&failures); err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %+v", failures)
+	}
+
+	byBackend := map[string]Failure{}
+	for _, f := range failures {
+		byBackend[f.Backend] = f
+	}
+
+	served := byBackend[failing.URL]
+	if served.Status != http.StatusInternalServerError || served.Method != http.MethodGet || !strings.Contains(served.Error, "500") {
+		t.Errorf("Expected a 500 from %s, got %+v", failing.URL, served)
+	}
+
+	refused := byBackend[down.URL]
+	if refused.Status != http.StatusBadGateway || refused.Error == "" {
+		t.Errorf("Expected a 502 with the dial error from %s, got %+v", down.URL, refused)
+	}
+	if served.Path == refused.Path {
+		t.Errorf("Expected each failure to keep its own path, got %+v", failures)
+	}
+}
+
+func TestFailures_RecordsHedgeExhaustion(t *testing.T) {
+	lb = NewLoadBalancer()
+	lb.SetHedging(HedgeConfig{Enabled: true, Delay: time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer backend.Close()
+		lb.AddBackend(backend.URL)
+	}
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", w.Code)
+	}
+
+	exhausted := 0
+	for _, f := range lb.failures.Entries() {
+		if f.Error == errHedgeExhausted.Error() {
+			exhausted++
+		}
+	}
+	if exhausted != 1 {
+		t.Errorf("Expected one hedge exhaustion entry, got %+v", lb.failures.Entries())
+	}
+}
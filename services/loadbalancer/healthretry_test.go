@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"common/retry"
+)
+
+// flakyBackend answers 503 to its first failures requests and 200 after
+func flakyBackend(t *testing.T, failures int64) *httptest.Server {
+	t.Helper()
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHealthCheckWithRetry(t *testing.T) {
+	policy := retry.Policy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		failures  int64
+		wantAlive bool
+	}{
+		{"one failure then success", 1, true},
+		{"fails every try", 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLoadBalancer()
+			lb.AddBackend(flakyBackend(t, tt.failures).URL)
+			healthCache := NewHealthCache(time.Minute, true)
+
+			lb.serverPool.HealthCheckWithRetry(nil, healthCache, policy)
+
+			backend := lb.serverPool.GetBackends()[0]
+			if backend.IsAlive() != tt.wantAlive {
+				t.Errorf("Expected alive %v, got %v", tt.wantAlive, backend.IsAlive())
+			}
+			if alive, found := healthCache.Get(backend.URL.String()); !found || alive != tt.wantAlive {
+				t.Errorf("Expected the final result cached, got %v, %v", alive, found)
+			}
+		})
+	}
+}
+
+func TestHealthCheckWithCache_SingleProbe(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddBackend(flakyBackend(t, 1).URL)
+
+	lb.serverPool.HealthCheckWithCache(nil, nil)
+
+	if lb.serverPool.GetBackends()[0].IsAlive() {
+		t.Error("Expected a single failed probe to mark the backend down without retries")
+	}
+}
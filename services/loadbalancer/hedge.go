@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"common/apierror"
+)
+
+// defaultMaxHedgeBodyBytes caps how much of a request body is buffered so it
+// can be replayed to a second backend
+const defaultMaxHedgeBodyBytes = 1 << 20
+
+// HedgeConfig configures hedged requests. Hedging sends a duplicate request
+// to a second backend when the first hasn't answered within Delay, which
+// cuts tail latency at the cost of extra backend load.
+type HedgeConfig struct {
+	Enabled      bool
+	Delay        time.Duration
+	MaxBodyBytes int64
+}
+
+// responseBuffer captures a proxied response so only the winning attempt is
+// written to the client
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rb *responseBuffer) Header() http.Header         { return rb.header }
+func (rb *responseBuffer) Write(p []byte) (int, error) { return rb.body.Write(p) }
+func (rb *responseBuffer) WriteHeader(status int)      { rb.status = status }
+
+// copyTo writes the captured response to w
+func (rb *responseBuffer) copyTo(w http.ResponseWriter) {
+	for key, values := range rb.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rb.status)
+	w.Write(rb.body.Bytes())
+}
+
+// errHedgeExhausted is logged when every hedged attempt failed
+var errHedgeExhausted = errors.New("all hedged attempts failed")
+
+type hedgeResult struct {
+	peer     *Backend
+	response *responseBuffer
+	latency  time.Duration
+}
+
+// SetHedging enables or disables hedged requests
+func (lb *LoadBalancer) SetHedging(config HedgeConfig) {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultMaxHedgeBodyBytes
+	}
+
+	lb.hedgeMu.Lock()
+	defer lb.hedgeMu.Unlock()
+	lb.hedge = config
+}
+
+func (lb *LoadBalancer) hedgeConfig() HedgeConfig {
+	lb.hedgeMu.RLock()
+	defer lb.hedgeMu.RUnlock()
+	return lb.hedge
+}
+
+// serveHedged proxies to the primary peer and, if it hasn't answered within
+// the hedge delay, to a second peer as well. The first successful response
+// wins and the other attempt is cancelled.
+func (lb *LoadBalancer) serveHedged(w http.ResponseWriter, r *http.Request, pool *ServerPool, primary *Backend, config HedgeConfig) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, config.MaxBodyBytes+1))
+		r.Body.Close()
+		if err != nil {
+			apierror.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > config.MaxBodyBytes {
+			apierror.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+	attempt := func(peer *Backend) {
+		req := r.Clone(ctx)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+
+		start := time.Now()
+		response := newResponseBuffer()
+		peer.ReverseProxy.ServeHTTP(response, req)
+		results <- hedgeResult{peer: peer, response: response, latency: time.Since(start)}
+	}
+
+	go attempt(primary)
+	pending := 1
+
+	timer := time.NewTimer(config.Delay)
+	defer timer.Stop()
+
+	var result hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if second := lb.nextPeerExcluding(pool, primary); second != nil {
+				go attempt(second)
+				pending++
+			}
+			continue
+		case result = <-results:
+			pending--
+		}
+
+		// A failed attempt only wins if nothing else is still in flight
+		if result.response.status < http.StatusInternalServerError || pending == 0 {
+			break
+		}
+	}
+
+	// Cancel the losing attempt, if any. Only the winner's latency is
+	// observed; the loser's is cut short by the cancellation.
+	cancel()
+	result.peer.ObserveLatency(result.latency)
+
+	lb.recordOutcome(result.peer, result.response.status)
+	if result.response.status >= http.StatusInternalServerError {
+		lb.recordFailure(r, result.peer.URL, result.response.status, errHedgeExhausted)
+	}
+	result.response.copyTo(w)
+}
+
+// nextPeerExcluding returns the next healthy peer in pool other than exclude
+func (lb *LoadBalancer) nextPeerExcluding(pool *ServerPool, exclude *Backend) *Backend {
+	for range pool.GetBackends() {
+		peer := lb.nextPeer(pool)
+		if peer == nil {
+			return nil
+		}
+		if peer != exclude {
+			return peer
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBackend(name string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Drain the body first so the server notices when the client cancels
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(name + ":" + string(body)))
+	}))
+}
+
+// hedgeTestLB builds a load balancer whose next pick is the first backend
+func hedgeTestLB(t *testing.T, urls ...string) *LoadBalancer {
+	t.Helper()
+
+	lb := NewLoadBalancer()
+	for _, u := range urls {
+		if err := lb.AddBackend(u); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+	lb.serverPool.current = uint64(len(urls) - 1)
+	return lb
+}
+
+func TestServeHTTP_HedgedRequestUsesFastBackend(t *testing.T) {
+	slow := newTestBackend("slow", 2*time.Second)
+	defer slow.Close()
+	fast := newTestBackend("fast", 0)
+	defer fast.Close()
+
+	lb := hedgeTestLB(t, slow.URL, fast.URL)
+	lb.SetHedging(HedgeConfig{Enabled: true, Delay: 50 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	lb.ServeHTTP(w, req)
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Body.String() != "fast:payload" {
+		t.Errorf("Expected fast backend response with replayed body, got %q", w.Body.String())
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected hedged response well before the slow backend, took %v", elapsed)
+	}
+}
+
+func TestServeHTTP_HedgeNotSentWhenPrimaryIsFast(t *testing.T) {
+	fast := newTestBackend("fast", 0)
+	defer fast.Close()
+	other := newTestBackend("other", 0)
+	defer other.Close()
+
+	lb := hedgeTestLB(t, fast.URL, other.URL)
+	lb.SetHedging(HedgeConfig{Enabled: true, Delay: time.Second})
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Body.String() != "fast:" {
+		t.Errorf("Expected primary response, got %q", w.Body.String())
+	}
+	if count := lb.serverPool.GetBackends()[1].SuccessCount; count != 0 {
+		t.Errorf("Expected no hedge request, got %d on second backend", count)
+	}
+}
+
+func TestServeHTTP_HedgeBodyTooLarge(t *testing.T) {
+	fast := newTestBackend("fast", 0)
+	defer fast.Close()
+
+	lb := hedgeTestLB(t, fast.URL)
+	lb.SetHedging(HedgeConfig{Enabled: true, Delay: time.Second, MaxBodyBytes: 4})
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too large")))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+}
@@ -1,16 +1,34 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/health"
+	"common/middleware"
+	"common/openapi"
+	"common/retry"
 )
 
+// ErrNoHealthyBackends is reported when no backend is alive
+var ErrNoHealthyBackends = errors.New("no healthy backends")
+
+// defaultRetryAfter is the Retry-After sent when no backend is available
+const defaultRetryAfter = 30 * time.Second
+
 // Backend represents a backend server
 type Backend struct {
 	URL          *url.URL
@@ -19,6 +37,12 @@ type Backend struct {
 	ReverseProxy *httputil.ReverseProxy
 	FailCount    int64
 	SuccessCount int64
+
+	ewma    uint64 // float64 bits of the response time EWMA in nanoseconds; 0 before the first sample
+	outlier outlierState
+
+	weight int64          // routing weight, read atomically; 0 means DefaultBackendWeight
+	tuned  tuningBaseline // guarded by the load balancer's tuningMu
 }
 
 // SetAlive sets the alive status of the backend
@@ -41,6 +65,9 @@ type ServerPool struct {
 	backends []*Backend
 	current  uint64
 	mu       sync.RWMutex
+
+	wrrMu     sync.Mutex
+	wrrScores map[*Backend]int64 // smooth weighted round-robin running scores
 }
 
 // AddBackend adds a backend to the server pool
@@ -50,6 +77,24 @@ func (s *ServerPool) AddBackend(backend *Backend) {
 	s.mu.Unlock()
 }
 
+// RemoveBackend removes the backend serving u and reports whether it was
+// in the pool
+func (s *ServerPool) RemoveBackend(u string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, b := range s.backends {
+		if b.URL.String() == u {
+			s.backends = append(s.backends[:i:i], s.backends[i+1:]...)
+			s.wrrMu.Lock()
+			delete(s.wrrScores, b)
+			s.wrrMu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
 // NextIndex atomically increases the counter and returns an index
 func (s *ServerPool) NextIndex() int {
 	return int(atomic.AddUint64(&s.current, uint64(1)) % uint64(len(s.backends)))
@@ -62,12 +107,25 @@ func (s *ServerPool) GetNextPeer() *Backend {
 
 // GetNextPeerWithCache returns next active peer using routing cache
 func (s *ServerPool) GetNextPeerWithCache(routingCache *RoutingCache) *Backend {
+	return s.roundRobin(s.activeBackends(routingCache))
+}
+
+// roundRobin returns the next of backends in turn, or nil if there are none
+func (s *ServerPool) roundRobin(backends []*Backend) *Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+	next := int(atomic.AddUint64(&s.current, 1) % uint64(len(backends)))
+	return backends[next]
+}
+
+// activeBackends returns the live backends, from the routing cache when it
+// has them
+func (s *ServerPool) activeBackends(routingCache *RoutingCache) []*Backend {
 	// Try cache first
 	if routingCache != nil {
 		if cached, found := routingCache.Get(); found && len(cached) > 0 {
-			// Use cached active backends for faster selection
-			next := int(atomic.AddUint64(&s.current, 1) % uint64(len(cached)))
-			return cached[next]
+			return cached
 		}
 	}
 
@@ -75,10 +133,6 @@ func (s *ServerPool) GetNextPeerWithCache(routingCache *RoutingCache) *Backend {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if len(s.backends) == 0 {
-		return nil
-	}
-
 	// Collect active backends
 	var activeBackends []*Backend
 	for _, b := range s.backends {
@@ -87,18 +141,12 @@ func (s *ServerPool) GetNextPeerWithCache(routingCache *RoutingCache) *Backend {
 		}
 	}
 
-	if len(activeBackends) == 0 {
-		return nil
-	}
-
 	// Update cache
-	if routingCache != nil {
+	if routingCache != nil && len(activeBackends) > 0 {
 		routingCache.Set(activeBackends)
 	}
 
-	// Select from active backends
-	next := int(atomic.AddUint64(&s.current, 1) % uint64(len(activeBackends)))
-	return activeBackends[next]
+	return activeBackends
 }
 
 // HealthCheck pings the backends and updates the status
@@ -108,13 +156,19 @@ func (s *ServerPool) HealthCheck() {
 
 // HealthCheckWithCache pings backends using connection pool and cache
 func (s *ServerPool) HealthCheckWithCache(pool *ConnectionPool, healthCache *HealthCache) {
+	s.HealthCheckWithRetry(pool, healthCache, retry.Once())
+}
+
+// HealthCheckWithRetry pings backends like HealthCheckWithCache, but only
+// marks a backend down once every try allowed by policy has failed
+func (s *ServerPool) HealthCheckWithRetry(pool *ConnectionPool, healthCache *HealthCache, policy retry.Policy) {
 	s.mu.RLock()
 	backends := make([]*Backend, len(s.backends))
 	copy(backends, s.backends)
 	s.mu.RUnlock()
 
 	for _, b := range backends {
-		alive := isBackendAliveWithPool(b.URL, pool, healthCache)
+		alive := isBackendAliveWithRetry(b.URL, pool, healthCache, policy)
 		b.SetAlive(alive)
 		if alive {
 			log.Printf("Backend %s is alive", b.URL)
@@ -138,6 +192,12 @@ func isBackendAlive(u *url.URL) bool {
 
 // isBackendAliveWithPool checks if a backend is alive using connection pool and cache
 func isBackendAliveWithPool(u *url.URL, pool *ConnectionPool, healthCache *HealthCache) bool {
+	return isBackendAliveWithRetry(u, pool, healthCache, retry.Once())
+}
+
+// isBackendAliveWithRetry checks if a backend is alive, probing again with
+// backoff after a failure. Only the final answer is cached.
+func isBackendAliveWithRetry(u *url.URL, pool *ConnectionPool, healthCache *HealthCache, policy retry.Policy) bool {
 	urlStr := u.String()
 
 	// Check cache first
@@ -148,7 +208,6 @@ func isBackendAliveWithPool(u *url.URL, pool *ConnectionPool, healthCache *Healt
 	}
 
 	// Perform health check
-	start := time.Now()
 	timeout := 2 * time.Second
 
 	var client *http.Client
@@ -158,13 +217,21 @@ func isBackendAliveWithPool(u *url.URL, pool *ConnectionPool, healthCache *Healt
 		client = &http.Client{Timeout: timeout}
 	}
 
-	resp, err := client.Get(urlStr + "/health")
-	latency := time.Since(start)
-
-	alive := err == nil && resp != nil && resp.StatusCode == http.StatusOK
-	if resp != nil {
+	var latency time.Duration
+	err := policy.Do(context.Background(), func() error {
+		start := time.Now()
+		resp, err := client.Get(urlStr + "/health")
+		latency = time.Since(start)
+		if err != nil {
+			return err
+		}
 		resp.Body.Close()
-	}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned %d", resp.StatusCode)
+		}
+		return nil
+	})
+	alive := err == nil
 
 	// Store in cache
 	if healthCache != nil {
@@ -179,6 +246,56 @@ type LoadBalancer struct {
 	serverPool     *ServerPool
 	cacheManager   *CacheManager
 	connectionPool *ConnectionPool
+
+	healthInterval time.Duration
+	healthBeat     health.Heartbeat
+
+	fallbackMu sync.RWMutex
+	fallback   http.Handler
+	retryAfter time.Duration
+
+	hedgeMu sync.RWMutex
+	hedge   HedgeConfig
+
+	strategyMu sync.RWMutex
+	strategy   Strategy
+
+	healthRetryMu sync.RWMutex
+	healthRetry   retry.Policy
+
+	transportMu sync.RWMutex
+	transport   http.RoundTripper // reaches backends; nil uses http.DefaultTransport
+
+	routesMu sync.RWMutex
+	routes   []pathRoute // longest prefix first; serverPool serves the rest
+
+	shadowMu    sync.RWMutex
+	shadow      *shadowConfig // nil when mirroring is off
+	shadowSlots chan struct{} // bounds mirrored requests in flight
+	shadowStats ShadowStats
+
+	canaryMu sync.RWMutex
+	canary   *canaryConfig // nil when no canary is set
+
+	outlierMu sync.RWMutex
+	outlier   OutlierConfig
+
+	tuningMu sync.Mutex
+	tuning   WeightTuningConfig
+
+	responsesMu sync.RWMutex
+	responses   *ResponseCache // nil when response caching is off
+
+	// latency counts every proxied request; the scaling hint derives the
+	// request rate from its count
+	latency     *LatencyTracker
+	scalingMu   sync.Mutex
+	scaling     ScalingConfig
+	rateSamples []rateSample
+	now         func() time.Time
+
+	// failures keeps the most recent failed proxied requests
+	failures *FailureLog
 }
 
 // NewLoadBalancer creates a new load balancer
@@ -198,6 +315,15 @@ func NewLoadBalancer() *LoadBalancer {
 		},
 		cacheManager:   NewCacheManager(cacheConfig),
 		connectionPool: NewConnectionPool(poolConfig),
+		retryAfter:     defaultRetryAfter,
+		strategy:       StrategyRoundRobin,
+		healthRetry:    retry.Once(),
+		shadowSlots:    make(chan struct{}, maxShadowInFlight),
+		latency:        NewLatencyTracker(0),
+		scaling:        DefaultScalingConfig(),
+		tuning:         DefaultWeightTuningConfig(),
+		now:            time.Now,
+		failures:       NewFailureLog(defaultFailureLogSize),
 	}
 }
 
@@ -208,11 +334,10 @@ func (lb *LoadBalancer) AddBackend(urlStr string) error {
 		return err
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(u)
 	backend := &Backend{
 		URL:          u,
 		Alive:        true,
-		ReverseProxy: proxy,
+		ReverseProxy: lb.newProxy(u),
 	}
 
 	lb.serverPool.AddBackend(backend)
@@ -224,38 +349,192 @@ func (lb *LoadBalancer) AddBackend(urlStr string) error {
 	return nil
 }
 
-// ServeHTTP handles incoming requests
+// RemoveBackend removes a backend from the default pool and reports whether
+// it was there
+func (lb *LoadBalancer) RemoveBackend(urlStr string) bool {
+	if !lb.serverPool.RemoveBackend(urlStr) {
+		return false
+	}
+
+	lb.cacheManager.Routing().Invalidate()
+	lb.cacheManager.Stats().Invalidate()
+	return true
+}
+
+// HasHealthyBackend reports whether any pool, including routed ones, has a
+// live backend
+func (lb *LoadBalancer) HasHealthyBackend() bool {
+	for _, pool := range lb.pools() {
+		for _, b := range pool.GetBackends() {
+			if b.IsAlive() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeHTTP handles incoming requests. Cacheable GETs are answered from
+// the response cache when it is on.
 func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	peer := lb.serverPool.GetNextPeerWithCache(lb.cacheManager.Routing())
+	start := time.Now()
+	defer func() { lb.latency.Record(time.Since(start)) }()
+
+	if responses := lb.responseCache(); responses != nil && cacheableRequest(r) {
+		lb.serveCached(w, r, responses)
+		return
+	}
+	lb.proxy(w, r)
+}
+
+// proxy sends r to a backend picked for its path, or to the fallback when
+// none is healthy
+func (lb *LoadBalancer) proxy(w http.ResponseWriter, r *http.Request) {
+	pool := lb.poolFor(r.URL.Path)
+	peer := lb.canaryPeer(pool)
+	if peer == nil {
+		peer = lb.nextPeer(pool)
+	}
 	if peer != nil {
-		peer.ReverseProxy.ServeHTTP(w, r)
-		atomic.AddInt64(&peer.SuccessCount, 1)
+		lb.mirror(r)
+
+		if hedge := lb.hedgeConfig(); hedge.Enabled {
+			lb.serveHedged(w, r, pool, peer, hedge)
+			return
+		}
+
+		proxyStart := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		peer.ReverseProxy.ServeHTTP(sw, r)
+		peer.ObserveLatency(time.Since(proxyStart))
+		lb.recordOutcome(peer, sw.status)
+		return
+	}
+
+	lb.serveFallback(w, r)
+}
+
+// SetFallback registers a handler that serves requests while no backend is
+// healthy, e.g. a maintenance page or a stale cached response. The handler
+// is responsible for its own status code. retryAfter sets the Retry-After
+// header; zero keeps the default.
+func (lb *LoadBalancer) SetFallback(handler http.Handler, retryAfter time.Duration) {
+	lb.fallbackMu.Lock()
+	defer lb.fallbackMu.Unlock()
+
+	lb.fallback = handler
+	if retryAfter > 0 {
+		lb.retryAfter = retryAfter
+	}
+}
+
+// serveFallback responds when there is no healthy backend
+func (lb *LoadBalancer) serveFallback(w http.ResponseWriter, r *http.Request) {
+	lb.fallbackMu.RLock()
+	fallback := lb.fallback
+	retryAfter := lb.retryAfter
+	lb.fallbackMu.RUnlock()
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+
+	if fallback != nil {
+		fallback.ServeHTTP(w, r)
 		return
 	}
 
-	http.Error(w, "Service not available", http.StatusServiceUnavailable)
+	apierror.Error(w, "Service not available", http.StatusServiceUnavailable)
+}
+
+// MaintenanceHandler returns a fallback that answers with a 503 JSON body
+// carrying the given message
+func MaintenanceHandler(message string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "maintenance",
+			"message": message,
+		})
+	})
+}
+
+// SetHealthRetry sets how health checks retry a failing backend before
+// marking it down. The default tries once.
+func (lb *LoadBalancer) SetHealthRetry(policy retry.Policy) {
+	lb.healthRetryMu.Lock()
+	defer lb.healthRetryMu.Unlock()
+	lb.healthRetry = policy
+}
+
+func (lb *LoadBalancer) healthRetryPolicy() retry.Policy {
+	lb.healthRetryMu.RLock()
+	defer lb.healthRetryMu.RUnlock()
+	return lb.healthRetry
 }
 
 // StartHealthCheck starts the health check routine
 func (lb *LoadBalancer) StartHealthCheck(interval time.Duration) {
+	lb.healthInterval = interval
+	lb.healthBeat.Beat()
+
 	ticker := time.NewTicker(interval)
 	go func() {
 		for range ticker.C {
-			lb.serverPool.HealthCheckWithCache(lb.connectionPool, lb.cacheManager.Health())
-			// Invalidate routing cache after health check
-			lb.cacheManager.Routing().Invalidate()
+			lb.checkHealth()
+			// Sample the request rate so the scaling window stays covered
+			lb.RequestRate()
+			lb.healthBeat.Beat()
 		}
 	}()
 }
 
-// GetStats returns statistics about the backends
-func (lb *LoadBalancer) GetStats() []map[string]interface{} {
-	// Try cache first
-	if cached, found := lb.cacheManager.Stats().Get(); found {
-		return cached
+// checkHealth runs one health check round over every pool and invalidates
+// the routing cache, which may now list backends that went down
+func (lb *LoadBalancer) checkHealth() {
+	policy := lb.healthRetryPolicy()
+	for _, pool := range lb.pools() {
+		pool.HealthCheckWithRetry(lb.connectionPool, lb.cacheManager.Health(), policy)
 	}
+	lb.cacheManager.Routing().Invalidate()
+}
+
+// WarmCaches runs a health check round right away and fills the routing
+// and stats caches from its results, so the first requests after startup
+// don't take the slow path. Call it once backends are registered and
+// before serving traffic.
+func (lb *LoadBalancer) WarmCaches() {
+	lb.checkHealth()
+	lb.serverPool.activeBackends(lb.cacheManager.Routing())
+
+	lb.cacheManager.Stats().Invalidate()
+	lb.GetStats()
+}
+
+// HealthChecks returns the readiness checks for the load balancer: at least
+// one backend must be alive, and the health check loop should be running
+func (lb *LoadBalancer) HealthChecks() *health.Checker {
+	checker := health.NewChecker()
+	checker.Register("backends", true, func() error {
+		if !lb.HasHealthyBackend() {
+			return ErrNoHealthyBackends
+		}
+		return nil
+	})
+	checker.Register("health_checker", false, func() error {
+		// Allow a couple of missed ticks before reporting the loop stalled
+		return lb.healthBeat.Check(3 * lb.healthInterval)()
+	})
+	return checker
+}
+
+// GetStats returns statistics about the backends. When the cached stats
+// have expired, concurrent callers share one recomputation.
+func (lb *LoadBalancer) GetStats() []map[string]interface{} {
+	return lb.cacheManager.Stats().GetOrCompute(lb.computeStats)
+}
 
-	// Compute stats
+// computeStats builds the per-backend stats from scratch
+func (lb *LoadBalancer) computeStats() []map[string]interface{} {
 	backends := lb.serverPool.GetBackends()
 	stats := make([]map[string]interface{}, len(backends))
 
@@ -265,34 +544,113 @@ func (lb *LoadBalancer) GetStats() []map[string]interface{} {
 			"alive":         b.IsAlive(),
 			"success_count": atomic.LoadInt64(&b.SuccessCount),
 			"fail_count":    atomic.LoadInt64(&b.FailCount),
+			"ewma_ms":       float64(b.LatencyEWMA()) / float64(time.Millisecond),
+			"weight":        b.Weight(),
+			"ejected":       false,
+			"ejections":     b.ejectionCount(),
+		}
+		if until := b.ejectedUntil(lb.now()); !until.IsZero() {
+			stats[i]["ejected"] = true
+			stats[i]["ejected_until"] = until
 		}
 	}
 
-	// Cache the result
-	lb.cacheManager.Stats().Set(stats)
-
 	return stats
 }
 
+// backendState is the serialized form of a backend
+type backendState struct {
+	URL          string `json:"url"`
+	Alive        bool   `json:"alive"`
+	FailCount    int64  `json:"fail_count"`
+	SuccessCount int64  `json:"success_count"`
+}
+
+// lbState is the serialized form of the load balancer's backend pool, in
+// round-robin order
+type lbState struct {
+	Backends []backendState `json:"backends"`
+}
+
+// Snapshot serializes the backend pool with each backend's health and
+// counters to JSON
+func (lb *LoadBalancer) Snapshot() ([]byte, error) {
+	backends := lb.serverPool.GetBackends()
+	state := lbState{Backends: make([]backendState, 0, len(backends))}
+	for _, b := range backends {
+		state.Backends = append(state.Backends, backendState{
+			URL:          b.URL.String(),
+			Alive:        b.IsAlive(),
+			FailCount:    atomic.LoadInt64(&b.FailCount),
+			SuccessCount: atomic.LoadInt64(&b.SuccessCount),
+		})
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the backend pool with the one in a snapshot and
+// invalidates the routing and stats caches
+func (lb *LoadBalancer) Restore(data []byte) error {
+	var state lbState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	backends := make([]*Backend, 0, len(state.Backends))
+	for _, bs := range state.Backends {
+		u, err := url.Parse(bs.URL)
+		if err != nil {
+			return err
+		}
+		if u.Host == "" {
+			return fmt.Errorf("backend %q has no host", bs.URL)
+		}
+		backends = append(backends, &Backend{
+			URL:          u,
+			Alive:        bs.Alive,
+			ReverseProxy: lb.newProxy(u),
+			FailCount:    bs.FailCount,
+			SuccessCount: bs.SuccessCount,
+		})
+	}
+
+	lb.serverPool.mu.Lock()
+	lb.serverPool.backends = backends
+	atomic.StoreUint64(&lb.serverPool.current, 0)
+	lb.serverPool.mu.Unlock()
+
+	lb.serverPool.wrrMu.Lock()
+	lb.serverPool.wrrScores = nil
+	lb.serverPool.wrrMu.Unlock()
+
+	lb.cacheManager.Routing().Invalidate()
+	lb.cacheManager.Stats().Invalidate()
+
+	return nil
+}
+
 var lb *LoadBalancer
 
+// addBackendRequest is the body of /add-backend
+type addBackendRequest struct {
+	URL string `json:"url"`
+}
+
 func addBackendHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		URL string `json:"url"`
-	}
+	var req addBackendRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if err := lb.AddBackend(req.URL); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -317,28 +675,138 @@ func cacheMetricsHandler(w http.ResponseWriter, r *http.Request) {
 		"cache_metrics": lb.cacheManager.GetAllMetrics(),
 		"pool_metrics":  lb.connectionPool.GetMetrics(),
 	}
+	if responses := lb.responseCache(); responses != nil {
+		metrics["response_cache_metrics"] = responses.Metrics()
+	}
 
 	json.NewEncoder(w).Encode(metrics)
 }
 
+// guardWrites serves GETs with h directly and sends every other method
+// through guard, so an endpoint stays readable while changing it is not
+func guardWrites(guard func(http.Handler) http.Handler, h http.HandlerFunc) http.Handler {
+	guarded := guard(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			h(w, r)
+			return
+		}
+		guarded.ServeHTTP(w, r)
+	})
+}
+
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Load Balancer", "1.0.0")
+
+	checker := lb.HealthChecks()
+	proxyResponses := map[int]string{
+		200: "Response from the selected backend",
+		413: "Body too large to hedge",
+		503: "No healthy backend; see Retry-After",
+	}
+
+	// State dumps and changes to where live traffic goes need the admin
+	// token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	api.HandleFunc("/add-backend", addBackendHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Add a backend to the pool", Request: addBackendRequest{},
+		Responses: map[int]string{200: "Backend added", 400: "Invalid request"},
+	})
+	api.Handle("/add-route", adminOnly(http.HandlerFunc(addRouteHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Route a path prefix to its own backend pool", Request: addRouteRequest{},
+		Responses: map[int]string{200: "Route added", 400: "Invalid request", 403: "Missing or invalid admin token"},
+	})
+	api.HandleFunc("/stats", statsHandler, openapi.Route{
+		Summary: "Backend and routing stats", Responses: map[int]string{200: "The stats"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	// Kubernetes readiness probes point here so traffic only reaches a load
+	// balancer that has somewhere to send it
+	api.HandleFunc("/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe: ready once a health check confirms a live backend", Response: health.Report{},
+		Responses: map[int]string{200: "At least one backend is alive", 503: "No live backend"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.HandleFunc("/cache-metrics", cacheMetricsHandler, openapi.Route{
+		Summary: "Cache and connection pool metrics", Responses: map[int]string{200: "The metrics"},
+	})
+	api.Handle("/canary", guardWrites(adminOnly, canaryHandler),
+		openapi.Route{Summary: "The current canary split", Response: CanaryStatus{}, Responses: map[int]string{200: "The canary split"}},
+		openapi.Route{
+			Method: http.MethodPost, Summary: "Send a percentage of default-pool traffic to a canary backend",
+			Request: setCanaryRequest{}, Response: CanaryStatus{},
+			Responses: map[int]string{200: "Canary updated", 400: "Invalid request", 403: "Missing or invalid admin token"},
+		},
+	)
+	api.HandleFunc("/failures", failuresHandler, openapi.Route{
+		Summary: "The most recent failed proxied requests, newest first", Response: []Failure{},
+		Responses: map[int]string{200: "The failed requests"},
+	})
+	api.HandleFunc("/scaling-hint", scalingHintHandler, openapi.Route{
+		Summary: "Request rate and suggested backend count for autoscalers", Response: ScalingHint{},
+		Responses: map[int]string{200: "The scaling hint"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(lb)), admin.Routes(lbState{})...)
+	api.HandleFunc("/", lb.ServeHTTP,
+		openapi.Route{Summary: "Proxy a request to a backend", Responses: proxyResponses},
+		openapi.Route{Method: http.MethodPost, Summary: "Proxy a request to a backend", Responses: proxyResponses},
+	)
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	lb = NewLoadBalancer()
+	lb.SetOutlierDetection(DefaultOutlierConfig())
+	lb.SetHealthRetry(retry.DefaultPolicy())
+	if strategy := os.Getenv("LB_STRATEGY"); strategy != "" {
+		if err := lb.SetStrategy(Strategy(strategy)); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Start health check every 10 seconds
 	lb.StartHealthCheck(10 * time.Second)
 
-	http.HandleFunc("/add-backend", addBackendHandler)
-	http.HandleFunc("/stats", statsHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/cache-metrics", cacheMetricsHandler)
-	http.HandleFunc("/", lb.ServeHTTP)
+	lb.SetWeightTuning(WeightTuningConfigFromEnv())
+	lb.StartWeightTuning()
+
+	if shadowURL := os.Getenv("LB_SHADOW_URL"); shadowURL != "" {
+		rate, _ := strconv.ParseFloat(os.Getenv("LB_SHADOW_RATE"), 64)
+		if err := lb.SetShadowBackend(shadowURL, rate); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("LB_RESPONSE_CACHE_TTL")); err == nil {
+		responseCache := DefaultResponseCacheConfig()
+		responseCache.TTL = ttl
+		lb.SetResponseCache(responseCache)
+	}
+
+	config := ServerConfigFromEnv()
+	if config.BackendHTTP2 {
+		lb.SetBackendTransport(HTTP2Transport(nil))
+	}
+
+	registerRoutes(http.DefaultServeMux)
+	lb.WarmCaches()
 
-	port := ":8082"
-	log.Printf("Load balancer starting on %s", port)
 	log.Printf("Caching enabled - Health: %v, Stats: %v, Routing: %v",
 		lb.cacheManager.config.HealthCacheEnabled,
 		lb.cacheManager.config.StatsCacheEnabled,
 		lb.cacheManager.config.RoutingCacheEnabled)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(ListenAndServe(config, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
+
+	"common/health"
 )
 
 func TestNewLoadBalancer(t *testing.T) {
@@ -226,3 +229,110 @@ func TestStartHealthCheck(t *testing.T) {
 	}
 }
 
+
+func TestHealthChecks_NoHealthyBackends(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddBackend("http://localhost:8080")
+	lb.serverPool.GetBackends()[0].SetAlive(false)
+	lb.StartHealthCheck(time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz/ready", nil)
+	w := httptest.NewRecorder()
+
+	lb.HealthChecks().ReadyHandler(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+	var report health.Report
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Checks["backends"].Error != ErrNoHealthyBackends.Error() {
+		t.Errorf("Expected failing backends check, got %+v", report.Checks)
+	}
+	if report.Checks["health_checker"].Status != health.StatusUp {
+		t.Errorf("Expected health checker to be up, got %+v", report.Checks["health_checker"])
+	}
+
+	lb.serverPool.GetBackends()[0].SetAlive(true)
+	w = httptest.NewRecorder()
+	lb.HealthChecks().ReadyHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with a live backend, got %d", w.Code)
+	}
+}
+
+func TestServeHTTP_NoBackends_RetryAfter(t *testing.T) {
+	lb := NewLoadBalancer()
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected default Retry-After of 30, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestServeHTTP_Fallback(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddBackend("http://localhost:8080")
+	lb.serverPool.GetBackends()[0].SetAlive(false)
+	lb.SetFallback(MaintenanceHandler("back soon"), 2*time.Minute)
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "120" {
+		t.Errorf("Expected Retry-After of 120, got %q", w.Header().Get("Retry-After"))
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected JSON fallback body, got %v", err)
+	}
+	if body["status"] != "maintenance" || body["message"] != "back soon" {
+		t.Errorf("Expected maintenance body, got %v", body)
+	}
+}
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	lb = NewLoadBalancer()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
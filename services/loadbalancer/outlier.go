@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OutlierConfig configures outlier detection: a backend whose error rate
+// over Window reaches ErrorRateThreshold is taken out of rotation for an
+// ejection period, even while it still passes health checks. Each repeat
+// ejection lasts BaseEjection longer than the one before, up to
+// MaxEjection.
+type OutlierConfig struct {
+	Enabled            bool
+	Window             time.Duration
+	MinRequests        int     // requests in the window before the rate counts
+	ErrorRateThreshold float64 // fraction of 5xx responses, 0 to 1
+	BaseEjection       time.Duration
+	MaxEjection        time.Duration
+}
+
+// DefaultOutlierConfig ejects a backend for 30s when half of at least 5
+// requests in 30s fail, up to 5 minutes for repeat offenders
+func DefaultOutlierConfig() OutlierConfig {
+	return OutlierConfig{
+		Enabled:            true,
+		Window:             30 * time.Second,
+		MinRequests:        5,
+		ErrorRateThreshold: 0.5,
+		BaseEjection:       30 * time.Second,
+		MaxEjection:        5 * time.Minute,
+	}
+}
+
+// outcome is one proxied response
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// outlierState tracks a backend's recent outcomes and ejections
+type outlierState struct {
+	mu           sync.Mutex
+	outcomes     []outcome // oldest first, within the window
+	ejectedUntil time.Time
+	ejections    int
+}
+
+// SetOutlierDetection replaces the outlier detection settings
+func (lb *LoadBalancer) SetOutlierDetection(config OutlierConfig) {
+	lb.outlierMu.Lock()
+	defer lb.outlierMu.Unlock()
+	lb.outlier = config
+}
+
+func (lb *LoadBalancer) outlierConfig() OutlierConfig {
+	lb.outlierMu.RLock()
+	defer lb.outlierMu.RUnlock()
+	return lb.outlier
+}
+
+// recordOutcome notes a response from b and ejects b if its error rate
+// has crossed the threshold
+func (lb *LoadBalancer) recordOutcome(b *Backend, status int) {
+	failed := status >= http.StatusInternalServerError
+	if failed {
+		atomic.AddInt64(&b.FailCount, 1)
+	} else {
+		atomic.AddInt64(&b.SuccessCount, 1)
+	}
+
+	config := lb.outlierConfig()
+	if !config.Enabled {
+		return
+	}
+
+	now := lb.now()
+	state := &b.outlier
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.outcomes = append(state.outcomes, outcome{at: now, failed: failed})
+	cutoff := now.Add(-config.Window)
+	expired := 0
+	for expired < len(state.outcomes) && !state.outcomes[expired].at.After(cutoff) {
+		expired++
+	}
+	state.outcomes = state.outcomes[expired:]
+
+	if len(state.outcomes) < config.MinRequests || now.Before(state.ejectedUntil) {
+		return
+	}
+	failures := 0
+	for _, o := range state.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+	if float64(failures)/float64(len(state.outcomes)) < config.ErrorRateThreshold {
+		return
+	}
+
+	state.ejections++
+	state.ejectedUntil = now.Add(min(config.BaseEjection*time.Duration(state.ejections), config.MaxEjection))
+	// Judge the backend afresh once it's re-admitted
+	state.outcomes = nil
+	lb.cacheManager.Stats().Invalidate()
+}
+
+// ejectedUntil returns when b's current ejection ends, or the zero time if
+// it isn't ejected
+func (b *Backend) ejectedUntil(now time.Time) time.Time {
+	b.outlier.mu.Lock()
+	defer b.outlier.mu.Unlock()
+	if now.Before(b.outlier.ejectedUntil) {
+		return b.outlier.ejectedUntil
+	}
+	return time.Time{}
+}
+
+// ejectionCount returns how many times b has been ejected
+func (b *Backend) ejectionCount() int {
+	b.outlier.mu.Lock()
+	defer b.outlier.mu.Unlock()
+	return b.outlier.ejections
+}
+
+// eligible drops ejected backends. If every backend is ejected, all of
+// them stay in rotation rather than failing every request.
+func (lb *LoadBalancer) eligible(backends []*Backend) []*Backend {
+	if !lb.outlierConfig().Enabled {
+		return backends
+	}
+
+	now := lb.now()
+	var admitted []*Backend
+	for _, b := range backends {
+		if b.ejectedUntil(now).IsZero() {
+			admitted = append(admitted, b)
+		}
+	}
+	if len(admitted) == 0 {
+		return backends
+	}
+	return admitted
+}
+
+// statusWriter records the status code written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so the
+// reverse proxy can still flush streamed responses
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
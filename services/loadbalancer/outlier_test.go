@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBackend starts a backend that answers with status and counts hits
+func countingBackend(t *testing.T, status int) (string, *int64) {
+	t.Helper()
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &hits
+}
+
+func TestOutlierDetection_EjectsAndReadmits(t *testing.T) {
+	goodURL, goodHits := countingBackend(t, http.StatusOK)
+	badURL, badHits := countingBackend(t, http.StatusInternalServerError)
+
+	lb := NewLoadBalancer()
+	lb.AddBackend(goodURL)
+	lb.AddBackend(badURL)
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lb.now = func() time.Time { return clock }
+	lb.SetOutlierDetection(OutlierConfig{
+		Enabled:            true,
+		Window:             10 * time.Second,
+		MinRequests:        4,
+		ErrorRateThreshold: 0.5,
+		BaseEjection:       30 * time.Second,
+		MaxEjection:        45 * time.Second,
+	})
+
+	serve := func(n int) {
+		for i := 0; i < n; i++ {
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+	}
+	badStats := func() map[string]interface{} {
+		lb.cacheManager.Stats().Invalidate()
+		return lb.GetStats()[1]
+	}
+
+	// Round robin sends every other request to the bad backend until it
+	// has failed MinRequests times
+	serve(8)
+	if hits := atomic.LoadInt64(badHits); hits != 4 {
+		t.Fatalf("Expected 4 requests to reach the bad backend before ejection, got %d", hits)
+	}
+	if stats := badStats(); stats["ejected"] != true || stats["ejections"] != 1 {
+		t.Fatalf("Expected the bad backend to be ejected once, got %v", stats)
+	}
+
+	serve(10)
+	if hits := atomic.LoadInt64(badHits); hits != 4 {
+		t.Errorf("Expected no traffic to the ejected backend, got %d more requests", hits-4)
+	}
+	if hits := atomic.LoadInt64(goodHits); hits != 14 {
+		t.Errorf("Expected the healthy backend to take the traffic, got %d", hits)
+	}
+
+	// After the cooldown the backend is back in rotation
+	clock = clock.Add(31 * time.Second)
+	if stats := badStats(); stats["ejected"] != false {
+		t.Fatalf("Expected the backend to be re-admitted, got %v", stats)
+	}
+	serve(8)
+	if hits := atomic.LoadInt64(badHits); hits != 8 {
+		t.Errorf("Expected the re-admitted backend to get traffic again, got %d total", hits)
+	}
+
+	// It failed again, so the second ejection lasts longer
+	until, ok := badStats()["ejected_until"].(time.Time)
+	if !ok || until.Sub(clock) != 45*time.Second {
+		t.Errorf("Expected a second ejection capped at 45s, got %v", until.Sub(clock))
+	}
+}
+
+func TestOutlierDetection_NeverEjectsEveryBackend(t *testing.T) {
+	badURL, badHits := countingBackend(t, http.StatusBadGateway)
+
+	lb := NewLoadBalancer()
+	lb.AddBackend(badURL)
+	config := DefaultOutlierConfig()
+	config.MinRequests = 2
+	lb.SetOutlierDetection(config)
+
+	for i := 0; i < 5; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if hits := atomic.LoadInt64(badHits); hits != 5 {
+		t.Errorf("Expected the only backend to keep serving while ejected, got %d requests", hits)
+	}
+	if fails := atomic.LoadInt64(&lb.serverPool.GetBackends()[0].FailCount); fails != 5 {
+		t.Errorf("Expected 5 failures counted, got %d", fails)
+	}
+}
@@ -15,6 +15,33 @@ type PooledConnection struct {
 	useCount int64
 	created  time.Time
 	mu       sync.RWMutex
+
+	// transport holds the client's keep-alive connections, closed when
+	// the entry is evicted
+	transport *http.Transport
+	// stale is set when a request through the client fails below HTTP,
+	// as when the backend restarted under its keep-alive connections;
+	// the next Get replaces the client
+	stale atomic.Bool
+}
+
+// staleCheckingTransport marks its pooled connection stale and drops the
+// transport's idle connections when a request fails at the connection
+// level, so the next request dials afresh instead of reusing a socket the
+// backend may have closed
+type staleCheckingTransport struct {
+	conn *PooledConnection
+}
+
+// RoundTrip sends req on the pooled transport
+func (t staleCheckingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.conn.transport.RoundTrip(req)
+	// A cancelled or timed out request says nothing about the connection
+	if err != nil && req.Context().Err() == nil {
+		t.conn.stale.Store(true)
+		t.conn.transport.CloseIdleConnections()
+	}
+	return resp, err
 }
 
 // ConnectionPool manages HTTP client connections for health checks and backend communication
@@ -30,6 +57,7 @@ type ConnectionPool struct {
 	missCount     int64
 	evictionCount int64
 	createCount   int64
+	staleCount    int64 // evictions of connections that failed
 }
 
 // PoolConfig holds connection pool configuration
@@ -109,23 +137,29 @@ func (p *ConnectionPool) Get(u *url.URL, timeout time.Duration) *http.Client {
 		return client
 	}
 
+	// Replace an expired or stale entry, closing its idle connections
+	if exists {
+		p.evictLocked(key, conn)
+	}
+
 	// Create new pooled connection
-	client := &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
+	conn = &PooledConnection{
+		lastUsed: time.Now(),
+		created:  time.Now(),
+		useCount: 1,
+		transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
 			MaxIdleConns:        p.maxIdle,
 			MaxIdleConnsPerHost: p.maxIdle,
 			IdleConnTimeout:     p.idleTimeout,
 			DisableKeepAlives:   false,
 		},
 	}
-
-	conn = &PooledConnection{
-		client:   client,
-		lastUsed: time.Now(),
-		created:  time.Now(),
-		useCount: 1,
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: staleCheckingTransport{conn: conn},
 	}
+	conn.client = client
 
 	p.connections[key] = conn
 	atomic.AddInt64(&p.createCount, 1)
@@ -134,8 +168,13 @@ func (p *ConnectionPool) Get(u *url.URL, timeout time.Duration) *http.Client {
 	return client
 }
 
-// isExpired checks if a connection has expired based on lifetime or idle time
+// isExpired checks if a connection has expired based on lifetime or idle
+// time, or has gone stale
 func (p *ConnectionPool) isExpired(conn *PooledConnection) bool {
+	if conn.stale.Load() {
+		return true
+	}
+
 	conn.mu.RLock()
 	defer conn.mu.RUnlock()
 
@@ -169,26 +208,34 @@ func (p *ConnectionPool) cleanup() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	now := time.Now()
 	for key, conn := range p.connections {
-		conn.mu.RLock()
-		expired := now.Sub(conn.created) > p.maxLifetime ||
-			now.Sub(conn.lastUsed) > p.idleTimeout
-		conn.mu.RUnlock()
-
-		if expired {
-			delete(p.connections, key)
-			atomic.AddInt64(&p.evictionCount, 1)
+		if p.isExpired(conn) {
+			p.evictLocked(key, conn)
 		}
 	}
 }
 
+// evictLocked removes a connection from the pool and closes its idle
+// sockets. In-flight requests on it finish normally. Must be called with
+// p.mu held.
+func (p *ConnectionPool) evictLocked(key string, conn *PooledConnection) {
+	delete(p.connections, key)
+	conn.transport.CloseIdleConnections()
+	atomic.AddInt64(&p.evictionCount, 1)
+	if conn.stale.Load() {
+		atomic.AddInt64(&p.staleCount, 1)
+	}
+}
+
 // Close closes all connections in the pool
 func (p *ConnectionPool) Close() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	// Clear all connections
+	for _, conn := range p.connections {
+		conn.transport.CloseIdleConnections()
+	}
 	p.connections = make(map[string]*PooledConnection)
 }
 
@@ -214,6 +261,7 @@ func (p *ConnectionPool) GetMetrics() PoolMetrics {
 		HitRate:       hitRate,
 		EvictionCount: atomic.LoadInt64(&p.evictionCount),
 		CreateCount:   atomic.LoadInt64(&p.createCount),
+		StaleCount:    atomic.LoadInt64(&p.staleCount),
 	}
 }
 
@@ -225,6 +273,7 @@ type PoolMetrics struct {
 	HitRate       float64
 	EvictionCount int64
 	CreateCount   int64
+	StaleCount    int64 // evictions of connections whose last request failed
 }
 
 // Reset resets the pool metrics
@@ -233,4 +282,5 @@ func (p *ConnectionPool) Reset() {
 	atomic.StoreInt64(&p.missCount, 0)
 	atomic.StoreInt64(&p.evictionCount, 0)
 	atomic.StoreInt64(&p.createCount, 0)
+	atomic.StoreInt64(&p.staleCount, 0)
 }
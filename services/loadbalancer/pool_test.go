@@ -1,6 +1,10 @@
 package main
 
 import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -343,3 +347,84 @@ func BenchmarkConnectionPoolConcurrent(b *testing.B) {
 		}
 	})
 }
+
+// TestConnectionPoolBackendRestart tests that a client whose backend went
+// away is replaced once the backend is back
+func TestConnectionPoolBackendRestart(t *testing.T) {
+	pool := NewConnectionPool(PoolConfig{CleanupInterval: time.Hour})
+	defer pool.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	backend := httptest.NewServer(handler)
+	addr := backend.Listener.Addr().String()
+	u, _ := url.Parse(backend.URL)
+
+	get := func() error {
+		resp, err := pool.Get(u, time.Second).Get(u.String())
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := get(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Restart the backend on the same address
+	backend.Close()
+	if err := get(); err == nil {
+		t.Fatal("Expected an error while the backend is down")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Could not reopen %s: %v", addr, err)
+	}
+	restarted := httptest.NewUnstartedServer(handler)
+	restarted.Listener.Close()
+	restarted.Listener = listener
+	restarted.Start()
+	defer restarted.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("Expected requests after the restart to succeed, got %v", err)
+		}
+	}
+
+	metrics := pool.GetMetrics()
+	if metrics.StaleCount != 1 {
+		t.Errorf("Expected 1 stale eviction, got %d", metrics.StaleCount)
+	}
+	if metrics.CreateCount != 2 {
+		t.Errorf("Expected a fresh client after the restart, got %d creates", metrics.CreateCount)
+	}
+}
+
+// TestConnectionPoolTimeoutIsNotStale tests that a slow backend doesn't
+// cost the pool its client
+func TestConnectionPoolTimeoutIsNotStale(t *testing.T) {
+	pool := NewConnectionPool(PoolConfig{CleanupInterval: time.Hour})
+	defer pool.Close()
+
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer backend.Close()
+	defer close(release)
+	u, _ := url.Parse(backend.URL)
+
+	if _, err := pool.Get(u, 20*time.Millisecond).Get(backend.URL); err == nil {
+		t.Fatal("Expected the request to time out")
+	}
+	pool.Get(u, 20*time.Millisecond)
+
+	if metrics := pool.GetMetrics(); metrics.StaleCount != 0 || metrics.CreateCount != 1 {
+		t.Errorf("Expected the client to be kept, got %+v", metrics)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"math/rand"
 	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -355,16 +357,41 @@ func (mp *MemoryProfiler) StartPeriodicSnapshot(interval time.Duration) chan str
 	return stopCh
 }
 
-// LatencyTracker tracks latency percentiles
+// LatencySampling chooses which latencies a LatencyTracker keeps once it
+// has seen more than its size
+type LatencySampling int
+
+const (
+	// SampleRecent keeps the most recent latencies, so percentiles follow
+	// current traffic
+	SampleRecent LatencySampling = iota
+	// SampleReservoir keeps a uniform random sample of every latency ever
+	// recorded, so percentiles estimate the whole stream without bias
+	SampleReservoir
+)
+
+// LatencyTracker tracks latency percentiles over a bounded sample
 type LatencyTracker struct {
 	mu        sync.RWMutex
 	latencies []time.Duration
 	maxSize   int
 	count     int64
+	sampling  LatencySampling
+	rng       *rand.Rand // picks reservoir slots; guarded by mu
+
+	// sorted caches latencies in order until the next Record. It is never
+	// modified once built, so readers may keep it after unlocking.
+	sorted []time.Duration
 }
 
-// NewLatencyTracker creates a new latency tracker
+// NewLatencyTracker creates a tracker of the most recent maxSize latencies
 func NewLatencyTracker(maxSize int) *LatencyTracker {
+	return NewSampledLatencyTracker(maxSize, SampleRecent)
+}
+
+// NewSampledLatencyTracker creates a tracker that keeps maxSize latencies
+// chosen by sampling. A maxSize of 0 means 1000.
+func NewSampledLatencyTracker(maxSize int, sampling LatencySampling) *LatencyTracker {
 	if maxSize == 0 {
 		maxSize = 1000
 	}
@@ -372,6 +399,8 @@ func NewLatencyTracker(maxSize int) *LatencyTracker {
 	return &LatencyTracker{
 		latencies: make([]time.Duration, 0, maxSize),
 		maxSize:   maxSize,
+		sampling:  sampling,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
@@ -380,36 +409,56 @@ func (lt *LatencyTracker) Record(latency time.Duration) {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
-	atomic.AddInt64(&lt.count, 1)
+	seen := atomic.AddInt64(&lt.count, 1)
+	lt.sorted = nil
 
-	lt.latencies = append(lt.latencies, latency)
+	if len(lt.latencies) < lt.maxSize {
+		lt.latencies = append(lt.latencies, latency)
+		return
+	}
 
-	// Keep only recent measurements
-	if len(lt.latencies) > lt.maxSize {
-		lt.latencies = lt.latencies[1:]
+	switch lt.sampling {
+	case SampleReservoir:
+		// Algorithm R: the nth latency replaces a random slot with
+		// probability maxSize/n, keeping every latency equally likely
+		// to be in the sample
+		if slot := lt.rng.Int63n(seen); slot < int64(lt.maxSize) {
+			lt.latencies[slot] = latency
+		}
+	default:
+		// Keep only recent measurements
+		lt.latencies = append(lt.latencies[1:], latency)
 	}
 }
 
 // GetPercentile calculates the specified percentile (0-100)
 func (lt *LatencyTracker) GetPercentile(percentile float64) time.Duration {
-	lt.mu.RLock()
-	defer lt.mu.RUnlock()
+	return percentileOf(lt.sortedSample(), percentile)
+}
 
-	if len(lt.latencies) == 0 {
-		return 0
+// sortedSample returns the kept latencies in order, sorting them only if a
+// latency was recorded since the last call
+func (lt *LatencyTracker) sortedSample() []time.Duration {
+	lt.mu.RLock()
+	sorted := lt.sorted
+	lt.mu.RUnlock()
+	if sorted != nil {
+		return sorted
 	}
 
-	// Create a sorted copy
-	sorted := make([]time.Duration, len(lt.latencies))
-	copy(sorted, lt.latencies)
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if lt.sorted == nil {
+		lt.sorted = slices.Clone(lt.latencies)
+		slices.Sort(lt.sorted)
+	}
+	return lt.sorted
+}
 
-	// Simple bubble sort (fine for small datasets)
-	for i := 0; i < len(sorted); i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if sorted[i] > sorted[j] {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
+// percentileOf returns the percentile (0-100) of sorted latencies
+func percentileOf(sorted []time.Duration, percentile float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
 	}
 
 	index := int(float64(len(sorted)) * percentile / 100.0)
@@ -420,14 +469,21 @@ func (lt *LatencyTracker) GetPercentile(percentile float64) time.Duration {
 	return sorted[index]
 }
 
+// Count returns how many latencies have been recorded, including ones no
+// longer kept for percentiles
+func (lt *LatencyTracker) Count() int64 {
+	return atomic.LoadInt64(&lt.count)
+}
+
 // GetMetrics returns latency metrics
 func (lt *LatencyTracker) GetMetrics() LatencyMetrics {
+	sorted := lt.sortedSample()
 	return LatencyMetrics{
 		Count: atomic.LoadInt64(&lt.count),
-		P50:   lt.GetPercentile(50),
-		P90:   lt.GetPercentile(90),
-		P95:   lt.GetPercentile(95),
-		P99:   lt.GetPercentile(99),
+		P50:   percentileOf(sorted, 50),
+		P90:   percentileOf(sorted, 90),
+		P95:   percentileOf(sorted, 95),
+		P99:   percentileOf(sorted, 99),
 	}
 }
 
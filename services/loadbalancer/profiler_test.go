@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestLatencyTrackerReservoirP99 tests that a reservoir's P99 estimates the
+// true P99 of the whole stream
+func TestLatencyTrackerReservoirP99(t *testing.T) {
+	tracker := NewSampledLatencyTracker(1000, SampleReservoir)
+
+	// A shuffled uniform stream of 1..100000µs has a true P99 of 99000µs
+	rng := rand.New(rand.NewSource(1))
+	for _, i := range rng.Perm(100000) {
+		tracker.Record(time.Duration(i+1) * time.Microsecond)
+	}
+
+	want := 99000 * time.Microsecond
+	got := tracker.GetPercentile(99)
+	if tolerance := 2000 * time.Microsecond; got < want-tolerance || got > want+tolerance {
+		t.Errorf("Expected P99 within %v of %v, got %v", tolerance, want, got)
+	}
+	if metrics := tracker.GetMetrics(); metrics.Count != 100000 || metrics.P99 != got {
+		t.Errorf("Expected 100000 recorded and P99 %v, got %+v", got, metrics)
+	}
+}
+
+// TestLatencyTrackerSamplingBias tests that only the reservoir remembers
+// traffic that has scrolled out of the recent window
+func TestLatencyTrackerSamplingBias(t *testing.T) {
+	recent := NewLatencyTracker(100)
+	reservoir := NewSampledLatencyTracker(100, SampleReservoir)
+
+	// Half the stream was slow, then traffic got fast
+	for i := 0; i < 10000; i++ {
+		latency := time.Millisecond
+		if i < 5000 {
+			latency = time.Second
+		}
+		recent.Record(latency)
+		reservoir.Record(latency)
+	}
+
+	if p90 := recent.GetPercentile(90); p90 != time.Millisecond {
+		t.Errorf("Expected the recent window to see only fast requests, got P90 %v", p90)
+	}
+	if p90 := reservoir.GetPercentile(90); p90 != time.Second {
+		t.Errorf("Expected the reservoir to keep the slow half, got P90 %v", p90)
+	}
+}
+
+// TestLatencyTrackerCachesSortedSample tests that percentiles reuse one
+// sort until a new latency is recorded
+func TestLatencyTrackerCachesSortedSample(t *testing.T) {
+	tracker := NewLatencyTracker(10)
+	for _, ms := range []int{5, 1, 4, 2, 3} {
+		tracker.Record(time.Duration(ms) * time.Millisecond)
+	}
+
+	first := tracker.sortedSample()
+	tracker.GetMetrics()
+	if second := tracker.sortedSample(); &second[0] != &first[0] {
+		t.Error("Expected repeated reads to share the sorted sample")
+	}
+	if p50 := tracker.GetPercentile(50); p50 != 3*time.Millisecond {
+		t.Errorf("Expected P50 3ms, got %v", p50)
+	}
+
+	tracker.Record(10 * time.Millisecond)
+	if p99 := tracker.GetPercentile(99); p99 != 10*time.Millisecond {
+		t.Errorf("Expected a new latency to invalidate the cache, got P99 %v", p99)
+	}
+	if first[len(first)-1] != 5*time.Millisecond {
+		t.Error("Expected an earlier sorted sample not to change")
+	}
+}
+
+// BenchmarkLatencyTrackerGetMetrics benchmarks reading percentiles of a
+// full tracker with no new latencies between reads
+func BenchmarkLatencyTrackerGetMetrics(b *testing.B) {
+	tracker := NewSampledLatencyTracker(10000, SampleReservoir)
+	for i := 0; i < 100000; i++ {
+		tracker.Record(time.Duration(rand.Intn(1000)) * time.Millisecond)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracker.GetMetrics()
+	}
+}
+
+// BenchmarkLatencyTrackerRecordAndGetMetrics benchmarks the worst case,
+// where every read follows a write and has to sort
+func BenchmarkLatencyTrackerRecordAndGetMetrics(b *testing.B) {
+	tracker := NewSampledLatencyTracker(10000, SampleReservoir)
+	for i := 0; i < 100000; i++ {
+		tracker.Record(time.Duration(rand.Intn(1000)) * time.Millisecond)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracker.Record(time.Millisecond)
+		tracker.GetMetrics()
+	}
+}
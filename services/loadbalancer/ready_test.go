@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReady_TracksBackendHealth(t *testing.T) {
+	lb = NewLoadBalancer()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	ready := func() int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return w.Code
+	}
+
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 with no backends, got %d", code)
+	}
+
+	var healthy atomic.Bool
+	healthy.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	lb.AddBackend(backend.URL)
+	lb.serverPool.HealthCheck()
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("Expected 200 once a backend passes its health check, got %d", code)
+	}
+
+	healthy.Store(false)
+	lb.serverPool.HealthCheck()
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 once every backend fails, got %d", code)
+	}
+
+	healthy.Store(true)
+	lb.serverPool.HealthCheck()
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("Expected 200 after the backend recovers, got %d", code)
+	}
+
+	if !lb.RemoveBackend(backend.URL) {
+		t.Fatal("Expected the backend to be removed")
+	}
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after removing the only backend, got %d", code)
+	}
+	if lb.RemoveBackend(backend.URL) {
+		t.Error("Expected a second removal to report false")
+	}
+}
+
+func TestReady_CountsRoutedPools(t *testing.T) {
+	lb = NewLoadBalancer()
+	pool, err := lb.NewPool("http://localhost:9001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lb.AddRoute("/api", pool); err != nil {
+		t.Fatal(err)
+	}
+	if !lb.HasHealthyBackend() {
+		t.Error("Expected a live backend in a routed pool to count")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"common/cache"
+)
+
+// ResponseCacheConfig configures the GET response cache. Cached responses
+// are served without reaching a backend until TTL passes, and concurrent
+// misses for the same URL share one backend request.
+type ResponseCacheConfig struct {
+	TTL          time.Duration // how long a response is served from cache; 0 turns caching off
+	MaxEntries   int           // least recently used responses are evicted past this; 0 means no limit
+	MaxBodyBytes int64         // larger responses are proxied but not cached
+}
+
+// DefaultResponseCacheConfig caches up to 1000 responses of up to 1MB for
+// a second: long enough to absorb bursts of identical requests, short
+// enough that clients rarely notice
+func DefaultResponseCacheConfig() ResponseCacheConfig {
+	return ResponseCacheConfig{
+		TTL:          time.Second,
+		MaxEntries:   1000,
+		MaxBodyBytes: 1 << 20,
+	}
+}
+
+// CacheStatusHeader tells clients whether a GET was served from the
+// response cache (HIT) or proxied (MISS)
+const CacheStatusHeader = "X-Cache"
+
+// cachedResponse is a stored backend response
+type cachedResponse struct {
+	header   http.Header
+	status   int
+	body     []byte
+	storedAt time.Time
+}
+
+// ResponseCache stores GET responses keyed by method and URL, plus the
+// request headers named in the response's Vary header
+type ResponseCache struct {
+	config  ResponseCacheConfig
+	entries *cache.TTLCache[string, *cachedResponse]
+	// vary holds the header names each URL's response varies on, which
+	// are needed to build the entry key before the response is known
+	vary    *cache.TTLCache[string, []string]
+	loading cache.Group[string, *responseBuffer]
+}
+
+// NewResponseCache creates an empty response cache
+func NewResponseCache(config ResponseCacheConfig) *ResponseCache {
+	return &ResponseCache{
+		config:  config,
+		entries: cache.NewLRUCache[string, *cachedResponse](config.TTL, config.MaxEntries),
+		vary:    cache.NewLRUCache[string, []string](config.TTL, config.MaxEntries),
+	}
+}
+
+// Metrics returns the cache's hit, miss and eviction counts
+func (rc *ResponseCache) Metrics() cache.Metrics {
+	return rc.entries.Metrics()
+}
+
+// SetResponseCache turns on caching of GET responses with config, or turns
+// it off when config.TTL is zero. Zero limits keep their defaults. Any
+// previously cached responses are dropped.
+func (lb *LoadBalancer) SetResponseCache(config ResponseCacheConfig) {
+	var responses *ResponseCache
+	if config.TTL > 0 {
+		defaults := DefaultResponseCacheConfig()
+		if config.MaxEntries == 0 {
+			config.MaxEntries = defaults.MaxEntries
+		}
+		if config.MaxBodyBytes <= 0 {
+			config.MaxBodyBytes = defaults.MaxBodyBytes
+		}
+		responses = NewResponseCache(config)
+	}
+
+	lb.responsesMu.Lock()
+	defer lb.responsesMu.Unlock()
+	lb.responses = responses
+}
+
+func (lb *LoadBalancer) responseCache() *ResponseCache {
+	lb.responsesMu.RLock()
+	defer lb.responsesMu.RUnlock()
+	return lb.responses
+}
+
+// cacheableRequest reports whether r may be answered from, or stored in,
+// the shared response cache. Requests carrying credentials or cookies, or
+// asking for part of a resource, always go to a backend.
+func cacheableRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get("Authorization") == "" &&
+		r.Header.Get("Cookie") == "" &&
+		r.Header.Get("Range") == "" &&
+		!hasCacheDirective(r.Header, "no-store")
+}
+
+// hasCacheDirective reports whether h's Cache-Control includes directive
+func hasCacheDirective(h http.Header, directive string) bool {
+	for _, value := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if strings.EqualFold(name, directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveCached answers a cacheable GET from rc, or proxies it and stores
+// the response. A client's Cache-Control: no-cache skips the lookup but
+// still refreshes the cache.
+func (lb *LoadBalancer) serveCached(w http.ResponseWriter, r *http.Request, rc *ResponseCache) {
+	primary := r.Method + " " + r.Host + r.URL.RequestURI()
+	if !hasCacheDirective(r.Header, "no-cache") {
+		if cached, ok := rc.lookup(primary, r); ok {
+			cached.writeTo(w)
+			return
+		}
+	}
+
+	key := rc.variantKey(primary, r)
+	response, _, shared := rc.loading.Do(key, func() (*responseBuffer, error) {
+		response := newResponseBuffer()
+		// Callers waiting on this request share it, so one client going
+		// away mustn't cancel it for the rest
+		lb.proxy(response, r.WithContext(context.WithoutCancel(r.Context())))
+		rc.store(primary, r, response)
+		return response, nil
+	})
+	if shared && response.header.Get("Vary") != "" {
+		// The shared response may be a different variant than this
+		// caller asked for
+		w.Header().Set(CacheStatusHeader, "MISS")
+		lb.proxy(w, r)
+		return
+	}
+
+	w.Header().Set(CacheStatusHeader, "MISS")
+	response.copyTo(w)
+}
+
+// lookup returns the fresh cached response for r, if any
+func (rc *ResponseCache) lookup(primary string, r *http.Request) (*cachedResponse, bool) {
+	if _, known := rc.vary.Get(primary); !known {
+		return nil, false
+	}
+	return rc.entries.Get(rc.variantKey(primary, r))
+}
+
+// variantKey extends primary with r's values for the headers the URL's
+// response varies on
+func (rc *ResponseCache) variantKey(primary string, r *http.Request) string {
+	names, _ := rc.vary.Get(primary)
+	var key strings.Builder
+	key.WriteString(primary)
+	for _, name := range names {
+		key.WriteString("\n" + name + ":" + strings.Join(r.Header.Values(name), ","))
+	}
+	return key.String()
+}
+
+// store caches response unless the backend forbade it or it is not a
+// complete, public 200
+func (rc *ResponseCache) store(primary string, r *http.Request, response *responseBuffer) {
+	header := response.header
+	if response.status != http.StatusOK ||
+		hasCacheDirective(header, "no-store") ||
+		hasCacheDirective(header, "no-cache") ||
+		hasCacheDirective(header, "private") ||
+		header.Get("Set-Cookie") != "" ||
+		int64(response.body.Len()) > rc.config.MaxBodyBytes {
+		return
+	}
+
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	rc.vary.Set(primary, names)
+	rc.entries.Set(rc.variantKey(primary, r), &cachedResponse{
+		header:   header.Clone(),
+		status:   response.status,
+		body:     bytes.Clone(response.body.Bytes()),
+		storedAt: time.Now(),
+	})
+}
+
+// writeTo serves the cached response, with its age
+func (c *cachedResponse) writeTo(w http.ResponseWriter) {
+	for key, values := range c.header {
+		// Copy so nothing downstream can append into the cached slice
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	w.Header().Set(CacheStatusHeader, "HIT")
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(c.storedAt).Seconds())))
+	w.WriteHeader(c.status)
+	w.Write(c.body)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cacheTestBackend serves body, echoing Accept-Language, and counts requests. header is
+// applied to every response.
+func cacheTestBackend(t *testing.T, body string, header http.Header) (*httptest.Server, *int64) {
+	t.Helper()
+	var hits int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.Write([]byte(body + " " + r.Header.Get("Accept-Language")))
+	}))
+	t.Cleanup(backend.Close)
+	return backend, &hits
+}
+
+func cachingLB(t *testing.T, backendURL string) *LoadBalancer {
+	t.Helper()
+	lb := NewLoadBalancer()
+	lb.AddBackend(backendURL)
+	lb.SetResponseCache(ResponseCacheConfig{TTL: time.Minute})
+	return lb
+}
+
+func cachedGet(lb *LoadBalancer, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCache_ServesRepeatGETFromCache(t *testing.T) {
+	backend, hits := cacheTestBackend(t, "hello", nil)
+	lb := cachingLB(t, backend.URL)
+
+	first := cachedGet(lb, "/page?x=1", nil)
+	second := cachedGet(lb, "/page?x=1", nil)
+
+	if n := atomic.LoadInt64(hits); n != 1 {
+		t.Errorf("Expected the backend to be hit once, got %d", n)
+	}
+	if first.Header().Get(CacheStatusHeader) != "MISS" || second.Header().Get(CacheStatusHeader) != "HIT" {
+		t.Errorf("Expected MISS then HIT, got %q then %q",
+			first.Header().Get(CacheStatusHeader), second.Header().Get(CacheStatusHeader))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("Expected the cached body %q, got %q", first.Body, second.Body)
+	}
+
+	cachedGet(lb, "/page?x=2", nil)
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected a different query to miss, got %d backend hits", n)
+	}
+}
+
+func TestResponseCache_NeverCachesPOST(t *testing.T) {
+	backend, hits := cacheTestBackend(t, "created", nil)
+	lb := cachingLB(t, backend.URL)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{}`)))
+		if got := w.Header().Get(CacheStatusHeader); got != "" {
+			t.Errorf("Expected POSTs to bypass the cache, got %s %q", CacheStatusHeader, got)
+		}
+	}
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected every POST to reach the backend, got %d hits", n)
+	}
+
+	// Nor does a POST's response answer a later GET
+	cachedGet(lb, "/items", nil)
+	if n := atomic.LoadInt64(hits); n != 3 {
+		t.Errorf("Expected the GET to miss, got %d hits", n)
+	}
+}
+
+func TestResponseCache_RespectsNoStore(t *testing.T) {
+	backend, hits := cacheTestBackend(t, "secret", http.Header{"Cache-Control": {"no-store"}})
+	lb := cachingLB(t, backend.URL)
+	cachedGet(lb, "/private", nil)
+	cachedGet(lb, "/private", nil)
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected a no-store response not to be cached, got %d hits", n)
+	}
+
+	backend, hits = cacheTestBackend(t, "public", nil)
+	lb = cachingLB(t, backend.URL)
+	noStore := http.Header{"Cache-Control": {"no-store"}}
+	cachedGet(lb, "/public", noStore)
+	cachedGet(lb, "/public", noStore)
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected a client's no-store to bypass the cache, got %d hits", n)
+	}
+}
+
+func TestResponseCache_BypassesCookies(t *testing.T) {
+	backend, hits := cacheTestBackend(t, "dashboard", nil)
+	lb := cachingLB(t, backend.URL)
+
+	session := http.Header{"Cookie": {"session=alice"}}
+	for i := 0; i < 2; i++ {
+		if got := cachedGet(lb, "/dashboard", session).Header().Get(CacheStatusHeader); got != "" {
+			t.Errorf("Expected a request with cookies to bypass the cache, got %s %q", CacheStatusHeader, got)
+		}
+	}
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected every request with cookies to reach the backend, got %d hits", n)
+	}
+
+	// Nor does a cookie-bearing response answer an anonymous GET
+	if got := cachedGet(lb, "/dashboard", nil).Header().Get(CacheStatusHeader); got != "MISS" {
+		t.Errorf("Expected the anonymous GET to miss, got %q", got)
+	}
+}
+
+func TestResponseCache_Vary(t *testing.T) {
+	backend, hits := cacheTestBackend(t, "greeting", http.Header{"Vary": {"Accept-Language"}})
+	lb := cachingLB(t, backend.URL)
+
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	cachedGet(lb, "/hello", en)
+	if w := cachedGet(lb, "/hello", fr); w.Body.String() != "greeting fr" {
+		t.Errorf("Expected the fr variant, got %q", w.Body)
+	}
+	if w := cachedGet(lb, "/hello", en); w.Header().Get(CacheStatusHeader) != "HIT" || w.Body.String() != "greeting en" {
+		t.Errorf("Expected the cached en variant, got %q (%s)", w.Body, w.Header().Get(CacheStatusHeader))
+	}
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected one backend hit per variant, got %d", n)
+	}
+}
+
+func TestResponseCache_Expires(t *testing.T) {
+	backend, hits := cacheTestBackend(t, "hello", nil)
+	lb := NewLoadBalancer()
+	lb.AddBackend(backend.URL)
+	lb.SetResponseCache(ResponseCacheConfig{TTL: 20 * time.Millisecond})
+
+	cachedGet(lb, "/", nil)
+	time.Sleep(40 * time.Millisecond)
+	cachedGet(lb, "/", nil)
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("Expected the response to expire after the TTL, got %d hits", n)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"common/apierror"
+)
+
+// ErrInvalidPrefix is returned for a route prefix that isn't an absolute path
+var ErrInvalidPrefix = errors.New("path prefix must start with /")
+
+// pathRoute sends requests under prefix to pool
+type pathRoute struct {
+	prefix string
+	pool   *ServerPool
+}
+
+// matches reports whether path is prefix itself or below it. "/api" and
+// "/api/" both match "/api/users" but not "/apiary".
+func (r pathRoute) matches(path string) bool {
+	base := strings.TrimSuffix(r.prefix, "/")
+	return path == base || strings.HasPrefix(path, base+"/")
+}
+
+// NewPool builds a server pool for the given backend URLs. The pool is not
+// used until it's passed to AddRoute.
+func (lb *LoadBalancer) NewPool(urls ...string) (*ServerPool, error) {
+	pool := &ServerPool{backends: []*Backend{}}
+	for _, urlStr := range urls {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return nil, err
+		}
+		pool.AddBackend(&Backend{
+			URL:          u,
+			Alive:        true,
+			ReverseProxy: lb.newProxy(u),
+		})
+	}
+	return pool, nil
+}
+
+// AddRoute sends requests whose path falls under pathPrefix to pool,
+// replacing any pool already routed at that prefix. When several routes
+// match, the longest prefix wins; requests matching none go to the
+// default pool.
+func (lb *LoadBalancer) AddRoute(pathPrefix string, pool *ServerPool) error {
+	if !strings.HasPrefix(pathPrefix, "/") {
+		return ErrInvalidPrefix
+	}
+
+	lb.routesMu.Lock()
+	defer lb.routesMu.Unlock()
+
+	for i, route := range lb.routes {
+		if route.prefix == pathPrefix {
+			lb.routes[i].pool = pool
+			return nil
+		}
+	}
+
+	lb.routes = append(lb.routes, pathRoute{prefix: pathPrefix, pool: pool})
+	sort.SliceStable(lb.routes, func(i, j int) bool {
+		return len(lb.routes[i].prefix) > len(lb.routes[j].prefix)
+	})
+	return nil
+}
+
+// poolFor returns the pool serving path
+func (lb *LoadBalancer) poolFor(path string) *ServerPool {
+	lb.routesMu.RLock()
+	defer lb.routesMu.RUnlock()
+
+	for _, route := range lb.routes {
+		if route.matches(path) {
+			return route.pool
+		}
+	}
+	return lb.serverPool
+}
+
+// pools returns the default pool followed by every routed pool and the
+// canary pool, if any
+func (lb *LoadBalancer) pools() []*ServerPool {
+	lb.routesMu.RLock()
+	pools := []*ServerPool{lb.serverPool}
+	for _, route := range lb.routes {
+		pools = append(pools, route.pool)
+	}
+	lb.routesMu.RUnlock()
+
+	if canary := lb.canaryConfig(); canary != nil {
+		pools = append(pools, canary.pool)
+	}
+	return pools
+}
+
+// addRouteRequest is the body of /add-route
+type addRouteRequest struct {
+	PathPrefix string   `json:"path_prefix"`
+	Backends   []string `json:"backends"`
+}
+
+func addRouteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req addRouteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Backends) == 0 {
+		apierror.Error(w, "at least one backend is required", http.StatusBadRequest)
+		return
+	}
+
+	pool, err := lb.NewPool(req.Backends...)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := lb.AddRoute(req.PathPrefix, pool); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"common/middleware"
+)
+
+// namedBackend starts a backend that answers every request with its name
+func namedBackend(t *testing.T, name string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// servedBy proxies a GET for path through lb and returns the backend name
+func servedBy(lb *LoadBalancer, path string) string {
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Body.String()
+}
+
+func TestAddRoute_LongestPrefix(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddBackend(namedBackend(t, "default"))
+
+	routes := map[string]string{
+		"/api":     "api",
+		"/api/v2/": "api-v2",
+		"/static/": "static",
+	}
+	for prefix, name := range routes {
+		pool, err := lb.NewPool(namedBackend(t, name))
+		if err != nil {
+			t.Fatalf("Failed to build pool: %v", err)
+		}
+		if err := lb.AddRoute(prefix, pool); err != nil {
+			t.Fatalf("Failed to add route %s: %v", prefix, err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api", "api"},
+		{"/api/users", "api"},
+		{"/api/v2/users", "api-v2"},
+		{"/api/v2", "api-v2"},
+		{"/static/logo.png", "static"},
+		{"/apiary", "default"},
+		{"/", "default"},
+		{"/other/page", "default"},
+	}
+
+	for _, tt := range tests {
+		if got := servedBy(lb, tt.path); got != tt.want {
+			t.Errorf("Expected %s to be served by %s, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestAddRoute_ReplacesPool(t *testing.T) {
+	lb := NewLoadBalancer()
+	first, _ := lb.NewPool(namedBackend(t, "first"))
+	second, _ := lb.NewPool(namedBackend(t, "second"))
+
+	lb.AddRoute("/api/", first)
+	lb.AddRoute("/api/", second)
+
+	if got := servedBy(lb, "/api/x"); got != "second" {
+		t.Errorf("Expected the newer pool to serve the route, got %q", got)
+	}
+	if len(lb.pools()) != 2 {
+		t.Errorf("Expected the default pool and one route, got %d pools", len(lb.pools()))
+	}
+	if err := lb.AddRoute("api", first); err != ErrInvalidPrefix {
+		t.Errorf("Expected ErrInvalidPrefix, got %v", err)
+	}
+}
+
+func TestAddRoute_NeedsAdminToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	lb = NewLoadBalancer()
+	lb.AddBackend(namedBackend(t, "default"))
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	body, _ := json.Marshal(addRouteRequest{PathPrefix: "/static/", Backends: []string{namedBackend(t, "static")}})
+	post := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/add-route", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if code := post(token); code != http.StatusForbidden {
+			t.Errorf("Token %q: expected 403, got %d", token, code)
+		}
+	}
+	if got := servedBy(lb, "/static/app.js"); got != "default" {
+		t.Errorf("Expected /static/ to stay on the default pool, got %q", got)
+	}
+
+	if code := post("s3cret"); code != http.StatusOK {
+		t.Fatalf("Expected 200 with the admin token, got %d", code)
+	}
+	if got := servedBy(lb, "/static/app.js"); got != "static" {
+		t.Errorf("Expected the new route to serve /static/app.js, got %q", got)
+	}
+}
+
+func TestAddRouteHandler(t *testing.T) {
+	lb = NewLoadBalancer()
+	lb.AddBackend(namedBackend(t, "default"))
+
+	tests := []struct {
+		name       string
+		body       addRouteRequest
+		wantStatus int
+	}{
+		{"valid", addRouteRequest{PathPrefix: "/static/", Backends: []string{namedBackend(t, "static")}}, http.StatusOK},
+		{"no backends", addRouteRequest{PathPrefix: "/img/"}, http.StatusBadRequest},
+		{"relative prefix", addRouteRequest{PathPrefix: "img", Backends: []string{"http://localhost:1"}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			addRouteHandler(w, httptest.NewRequest(http.MethodPost, "/add-route", bytes.NewReader(body)))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+
+	if got := servedBy(lb, "/static/app.js"); got != "static" {
+		t.Errorf("Expected the new route to serve /static/app.js, got %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy selects how the load balancer picks a backend for each request
+type Strategy string
+
+const (
+	// StrategyRoundRobin cycles through the healthy backends in turn
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyEWMA picks healthy backends at random, weighted by the inverse
+	// of their average response time, so slow backends get less traffic
+	// without being taken out of rotation
+	StrategyEWMA Strategy = "ewma"
+	// StrategyWeighted cycles through the healthy backends in proportion to
+	// their weights, which auto-tuning adjusts from observed errors and
+	// latency
+	StrategyWeighted Strategy = "weighted"
+)
+
+// ewmaAlpha is the weight of the newest response time in a backend's
+// moving average
+const ewmaAlpha = 0.2
+
+// ObserveLatency folds a response time into the backend's moving average
+func (b *Backend) ObserveLatency(latency time.Duration) {
+	sample := float64(latency)
+	for {
+		old := atomic.LoadUint64(&b.ewma)
+		next := sample
+		if old != 0 {
+			next = ewmaAlpha*sample + (1-ewmaAlpha)*math.Float64frombits(old)
+		}
+		// Keep zero reserved for "no samples yet"
+		next = math.Max(next, 1)
+		if atomic.CompareAndSwapUint64(&b.ewma, old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// LatencyEWMA returns the backend's moving average response time, or zero
+// before its first request
+func (b *Backend) LatencyEWMA() time.Duration {
+	return time.Duration(math.Float64frombits(atomic.LoadUint64(&b.ewma)))
+}
+
+// SetStrategy changes how backends are picked
+func (lb *LoadBalancer) SetStrategy(strategy Strategy) error {
+	switch strategy {
+	case StrategyRoundRobin, StrategyEWMA, StrategyWeighted:
+	default:
+		return fmt.Errorf("unknown strategy %q", strategy)
+	}
+
+	lb.strategyMu.Lock()
+	defer lb.strategyMu.Unlock()
+	lb.strategy = strategy
+	return nil
+}
+
+func (lb *LoadBalancer) routingStrategy() Strategy {
+	lb.strategyMu.RLock()
+	defer lb.strategyMu.RUnlock()
+	return lb.strategy
+}
+
+// nextPeer picks a live, non-ejected backend from pool using the current
+// strategy
+func (lb *LoadBalancer) nextPeer(pool *ServerPool) *Backend {
+	backends := lb.eligible(pool.activeBackends(lb.routingCacheFor(pool)))
+	switch lb.routingStrategy() {
+	case StrategyEWMA:
+		return pickByLatency(backends)
+	case StrategyWeighted:
+		return pool.weightedRoundRobin(backends)
+	}
+	return pool.roundRobin(backends)
+}
+
+// routingCacheFor returns the routing cache for pool. Only the default pool
+// is cached; path routes scan their pool on every request.
+func (lb *LoadBalancer) routingCacheFor(pool *ServerPool) *RoutingCache {
+	if pool != lb.serverPool {
+		return nil
+	}
+	return lb.cacheManager.Routing()
+}
+
+// pickByLatency chooses a backend with probability proportional to the
+// inverse of its latency EWMA. A backend with no samples yet is picked
+// first so every backend gets measured.
+func pickByLatency(backends []*Backend) *Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	weights := make([]float64, len(backends))
+	var total float64
+	for i, b := range backends {
+		ewma := b.LatencyEWMA()
+		if ewma == 0 {
+			return b
+		}
+		weights[i] = 1 / float64(ewma)
+		total += weights[i]
+	}
+
+	target := rand.Float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return backends[i]
+		}
+	}
+	return backends[len(backends)-1]
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackend_ObserveLatency(t *testing.T) {
+	b := &Backend{}
+	if got := b.LatencyEWMA(); got != 0 {
+		t.Fatalf("Expected no EWMA before the first sample, got %v", got)
+	}
+
+	b.ObserveLatency(100 * time.Millisecond)
+	if got := b.LatencyEWMA(); got != 100*time.Millisecond {
+		t.Errorf("Expected the first sample to seed the EWMA, got %v", got)
+	}
+
+	b.ObserveLatency(200 * time.Millisecond)
+	if got := b.LatencyEWMA(); got != 120*time.Millisecond {
+		t.Errorf("Expected 0.2*200ms + 0.8*100ms = 120ms, got %v", got)
+	}
+}
+
+func TestSetStrategy_Unknown(t *testing.T) {
+	lb := NewLoadBalancer()
+	if err := lb.SetStrategy("fastest"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+	if got := lb.routingStrategy(); got != StrategyRoundRobin {
+		t.Errorf("Expected round robin to remain, got %s", got)
+	}
+}
+
+func TestEWMAStrategy_PrefersFastBackend(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	lb := NewLoadBalancer()
+	lb.AddBackend(fast.URL)
+	lb.AddBackend(slow.URL)
+	if err := lb.SetStrategy(StrategyEWMA); err != nil {
+		t.Fatalf("Failed to set strategy: %v", err)
+	}
+
+	const requests = 200
+	for i := 0; i < requests; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	backends := lb.serverPool.GetBackends()
+	fastCount := atomic.LoadInt64(&backends[0].SuccessCount)
+	slowCount := atomic.LoadInt64(&backends[1].SuccessCount)
+	if fastCount+slowCount != requests {
+		t.Fatalf("Expected %d requests served, got %d", requests, fastCount+slowCount)
+	}
+	if slowCount == 0 {
+		t.Error("Expected the slow backend to be measured at least once")
+	}
+	if share := float64(fastCount) / requests; share < 0.8 {
+		t.Errorf("Expected the fast backend to get most traffic, got %.0f%% (fast EWMA %v, slow EWMA %v)",
+			share*100, backends[0].LatencyEWMA(), backends[1].LatencyEWMA())
+	}
+}
+
+func TestPickByLatency_KeepsSlowBackendsInRotation(t *testing.T) {
+	fast, slow := &Backend{}, &Backend{}
+	fast.ObserveLatency(time.Millisecond)
+	slow.ObserveLatency(3 * time.Millisecond)
+
+	picks := map[*Backend]int{}
+	for i := 0; i < 4000; i++ {
+		picks[pickByLatency([]*Backend{fast, slow})]++
+	}
+
+	// Weights 1/1ms and 1/3ms: the slow backend should get about a quarter
+	if share := float64(picks[slow]) / 4000; share < 0.2 || share > 0.3 {
+		t.Errorf("Expected the slow backend to get about 25%% of picks, got %.1f%%", share*100)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// ScalingConfig configures the backend count suggested to autoscalers.
+// Utilization is the request rate divided by what the healthy backends can
+// take at TargetRPS each. Between ScaleDownAt and ScaleUpAt the current
+// count is kept, so the hint doesn't flap around a single threshold.
+type ScalingConfig struct {
+	TargetRPS   float64       // requests per second one backend should serve
+	ScaleUpAt   float64       // suggest more backends above this utilization
+	ScaleDownAt float64       // suggest fewer backends below this utilization
+	MinBackends int           // never suggest fewer than this
+	MaxBackends int           // never suggest more than this; zero means no limit
+	Window      time.Duration // how far back the request rate is measured
+}
+
+// DefaultScalingConfig returns the scaling settings used by NewLoadBalancer
+func DefaultScalingConfig() ScalingConfig {
+	return ScalingConfig{
+		TargetRPS:   100,
+		ScaleUpAt:   0.8,
+		ScaleDownAt: 0.3,
+		MinBackends: 1,
+		Window:      time.Minute,
+	}
+}
+
+// ScalingHint is the load balancer's view of its load and how many backends
+// it would like
+type ScalingHint struct {
+	RequestRate       float64 `json:"request_rate"`
+	Utilization       float64 `json:"utilization"`
+	HealthyBackends   int     `json:"healthy_backends"`
+	TotalBackends     int     `json:"total_backends"`
+	SuggestedBackends int     `json:"suggested_backends"`
+	TargetRPS         float64 `json:"target_rps"`
+}
+
+// rateSample is the request count seen at a point in time
+type rateSample struct {
+	at    time.Time
+	count int64
+}
+
+// SetScaling replaces the scaling settings. Zero fields keep their defaults.
+func (lb *LoadBalancer) SetScaling(config ScalingConfig) {
+	defaults := DefaultScalingConfig()
+	if config.TargetRPS <= 0 {
+		config.TargetRPS = defaults.TargetRPS
+	}
+	if config.ScaleUpAt <= 0 {
+		config.ScaleUpAt = defaults.ScaleUpAt
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+
+	lb.scalingMu.Lock()
+	defer lb.scalingMu.Unlock()
+	lb.scaling = config
+}
+
+// RequestRate returns the requests per second served over the scaling
+// window. Each call records a sample, as does every health check tick; the
+// first call has nothing to compare against and returns zero.
+func (lb *LoadBalancer) RequestRate() float64 {
+	lb.scalingMu.Lock()
+	defer lb.scalingMu.Unlock()
+	return lb.requestRateLocked()
+}
+
+func (lb *LoadBalancer) requestRateLocked() float64 {
+	now := lb.now()
+	count := lb.latency.Count()
+	lb.rateSamples = append(lb.rateSamples, rateSample{at: now, count: count})
+
+	// Drop samples older than the window, keeping the last one before it
+	// so the rate still covers the whole window
+	cutoff := now.Add(-lb.scaling.Window)
+	for len(lb.rateSamples) > 2 && !lb.rateSamples[1].at.After(cutoff) {
+		lb.rateSamples = lb.rateSamples[1:]
+	}
+
+	oldest := lb.rateSamples[0]
+	elapsed := now.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count-oldest.count) / elapsed
+}
+
+// ScalingHint measures the current request rate and suggests a backend
+// count for it
+func (lb *LoadBalancer) ScalingHint() ScalingHint {
+	backends := lb.serverPool.GetBackends()
+	healthy := 0
+	for _, b := range backends {
+		if b.IsAlive() {
+			healthy++
+		}
+	}
+
+	lb.scalingMu.Lock()
+	config := lb.scaling
+	rate := lb.requestRateLocked()
+	lb.scalingMu.Unlock()
+
+	hint := ScalingHint{
+		RequestRate:     rate,
+		HealthyBackends: healthy,
+		TotalBackends:   len(backends),
+		TargetRPS:       config.TargetRPS,
+	}
+	if healthy > 0 {
+		hint.Utilization = rate / (float64(healthy) * config.TargetRPS)
+	}
+	hint.SuggestedBackends = suggestBackends(rate, healthy, hint.Utilization, config)
+	return hint
+}
+
+// EstimateRequiredBackends suggests how many backends the current request
+// rate needs
+func (lb *LoadBalancer) EstimateRequiredBackends() int {
+	return lb.ScalingHint().SuggestedBackends
+}
+
+// suggestBackends sizes the pool so utilization lands at ScaleUpAt when it
+// has left the [ScaleDownAt, ScaleUpAt] band, and keeps it otherwise
+func suggestBackends(rate float64, healthy int, utilization float64, config ScalingConfig) int {
+	suggested := healthy
+	if healthy == 0 || utilization > config.ScaleUpAt || utilization < config.ScaleDownAt {
+		suggested = int(math.Ceil(rate / (config.TargetRPS * config.ScaleUpAt)))
+	}
+
+	suggested = max(suggested, config.MinBackends)
+	if config.MaxBackends > 0 {
+		suggested = min(suggested, config.MaxBackends)
+	}
+	return suggested
+}
+
+func scalingHintHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb.ScalingHint())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// scalingTestLB builds a load balancer with n live backends and a manual
+// clock, seeded with a first rate sample
+func scalingTestLB(t *testing.T, n int, config ScalingConfig) (*LoadBalancer, *time.Time) {
+	t.Helper()
+
+	lb := NewLoadBalancer()
+	for i := 0; i < n; i++ {
+		if err := lb.AddBackend("http://backend" + string(rune('a'+i)) + ":8080"); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lb.now = func() time.Time { return clock }
+	lb.SetScaling(config)
+	lb.RequestRate()
+	return lb, &clock
+}
+
+// serveRate records rps requests per second for d
+func serveRate(lb *LoadBalancer, clock *time.Time, rps int, d time.Duration) {
+	for i := 0; i < rps*int(d/time.Second); i++ {
+		lb.latency.Record(time.Millisecond)
+	}
+	*clock = clock.Add(d)
+}
+
+func TestEstimateRequiredBackends(t *testing.T) {
+	config := ScalingConfig{
+		TargetRPS:   100,
+		ScaleUpAt:   0.8,
+		ScaleDownAt: 0.3,
+		MinBackends: 1,
+		MaxBackends: 10,
+		Window:      time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		healthy int
+		rps     int
+		want    int
+	}{
+		{"idle keeps the minimum", 1, 0, 1},
+		{"within band holds", 2, 100, 2},                 // utilization 0.5
+		{"at scale-up threshold holds", 2, 160, 2},       // utilization exactly 0.8
+		{"above scale-up adds backends", 2, 200, 3},      // 200 / 80 per backend
+		{"heavy load scales up further", 3, 600, 8},      // 600 / 80
+		{"capped at max", 2, 2000, 10},                   // would be 25
+		{"below scale-down removes backends", 4, 100, 2}, // utilization 0.25
+		{"at scale-down threshold holds", 4, 120, 4},     // utilization exactly 0.3
+		{"no healthy backends sizes from rate", 0, 100, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb, clock := scalingTestLB(t, max(tt.healthy, 1), config)
+			if tt.healthy == 0 {
+				lb.serverPool.GetBackends()[0].SetAlive(false)
+			}
+
+			serveRate(lb, clock, tt.rps, 10*time.Second)
+
+			if got := lb.EstimateRequiredBackends(); got != tt.want {
+				t.Errorf("Expected %d backends at %d rps, got %d", tt.want, tt.rps, got)
+			}
+		})
+	}
+}
+
+func TestRequestRate_Window(t *testing.T) {
+	lb, clock := scalingTestLB(t, 1, ScalingConfig{Window: 30 * time.Second})
+
+	// A burst, then quiet for longer than the window
+	serveRate(lb, clock, 500, 10*time.Second)
+	if rate := lb.RequestRate(); rate != 500 {
+		t.Errorf("Expected 500 rps during the burst, got %v", rate)
+	}
+
+	for i := 0; i < 4; i++ {
+		serveRate(lb, clock, 10, 10*time.Second)
+		lb.RequestRate()
+	}
+	if rate := lb.RequestRate(); rate != 10 {
+		t.Errorf("Expected the burst to age out of the window, got %v rps", rate)
+	}
+}
+
+func TestScalingHintHandler(t *testing.T) {
+	var clock *time.Time
+	lb, clock = scalingTestLB(t, 2, ScalingConfig{TargetRPS: 50})
+	serveRate(lb, clock, 120, 10*time.Second)
+
+	req := httptest.NewRequest(http.MethodGet, "/scaling-hint", nil)
+	w := httptest.NewRecorder()
+	scalingHintHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var hint ScalingHint
+	if err := json.NewDecoder(w.Body).Decode(&hint); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if hint.RequestRate != 120 || hint.HealthyBackends != 2 || hint.TotalBackends != 2 {
+		t.Errorf("Unexpected load in hint: %+v", hint)
+	}
+	if hint.Utilization != 1.2 || hint.SuggestedBackends != 3 {
+		t.Errorf("Expected utilization 1.2 suggesting 3 backends, got %+v", hint)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// shadowTimeout bounds each mirrored request
+	shadowTimeout = 5 * time.Second
+	// maxShadowInFlight caps concurrent mirrored requests; once reached,
+	// further requests aren't mirrored until some finish
+	maxShadowInFlight = 100
+	// ShadowHeader marks mirrored requests so the shadow backend can tell
+	// them apart
+	ShadowHeader = "X-Shadow-Request"
+)
+
+// shadowConfig is a backend that receives a copy of a fraction of requests
+type shadowConfig struct {
+	target *url.URL
+	rate   float64
+}
+
+// ShadowStats counts mirrored requests
+type ShadowStats struct {
+	Mirrored int64 `json:"mirrored"`
+	Failed   int64 `json:"failed"`
+	Skipped  int64 `json:"skipped"` // body too large or too many in flight
+}
+
+// SetShadowBackend mirrors rate (0 to 1) of requests to the backend at
+// urlStr. Mirrored requests are sent in the background and their responses
+// discarded, so the shadow backend never affects clients. An empty urlStr
+// or a zero rate turns mirroring off.
+func (lb *LoadBalancer) SetShadowBackend(urlStr string, rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("shadow rate must be between 0 and 1, got %v", rate)
+	}
+
+	var shadow *shadowConfig
+	if urlStr != "" && rate > 0 {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return err
+		}
+		if u.Host == "" {
+			return fmt.Errorf("shadow backend %q has no host", urlStr)
+		}
+		shadow = &shadowConfig{target: u, rate: rate}
+	}
+
+	lb.shadowMu.Lock()
+	defer lb.shadowMu.Unlock()
+	lb.shadow = shadow
+	return nil
+}
+
+// ShadowStats returns the mirroring counters
+func (lb *LoadBalancer) ShadowStats() ShadowStats {
+	return ShadowStats{
+		Mirrored: atomic.LoadInt64(&lb.shadowStats.Mirrored),
+		Failed:   atomic.LoadInt64(&lb.shadowStats.Failed),
+		Skipped:  atomic.LoadInt64(&lb.shadowStats.Skipped),
+	}
+}
+
+// mirror sends a copy of r to the shadow backend if r is sampled. The body
+// is buffered and r.Body replaced so the primary request still sees it.
+func (lb *LoadBalancer) mirror(r *http.Request) {
+	lb.shadowMu.RLock()
+	shadow := lb.shadow
+	lb.shadowMu.RUnlock()
+
+	if shadow == nil || rand.Float64() >= shadow.rate {
+		return
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		buffered, err := io.ReadAll(io.LimitReader(r.Body, defaultMaxHedgeBodyBytes+1))
+		if err != nil || len(buffered) > defaultMaxHedgeBodyBytes {
+			// Hand the primary everything read so far plus the rest
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(buffered), r.Body), r.Body}
+			atomic.AddInt64(&lb.shadowStats.Skipped, 1)
+			return
+		}
+		r.Body.Close()
+		body = buffered
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	select {
+	case lb.shadowSlots <- struct{}{}:
+	default:
+		atomic.AddInt64(&lb.shadowStats.Skipped, 1)
+		return
+	}
+
+	target := *shadow.target
+	target.Path = singleJoiningSlash(target.Path, r.URL.Path)
+	target.RawQuery = r.URL.RawQuery
+	header := r.Header.Clone()
+	header.Set(ShadowHeader, "true")
+
+	go func() {
+		defer func() { <-lb.shadowSlots }()
+
+		// Detached from the client's context: the mirror finishes even
+		// after the primary response is sent
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, r.Method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			atomic.AddInt64(&lb.shadowStats.Failed, 1)
+			return
+		}
+		req.Header = header
+
+		resp, err := lb.RoundTrip(req)
+		if err != nil {
+			atomic.AddInt64(&lb.shadowStats.Failed, 1)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			atomic.AddInt64(&lb.shadowStats.Failed, 1)
+			return
+		}
+		atomic.AddInt64(&lb.shadowStats.Mirrored, 1)
+	}()
+}
+
+// readCloser reads from one source and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// singleJoiningSlash joins two URL paths with exactly one slash, as
+// httputil.ReverseProxy does
+func singleJoiningSlash(a, b string) string {
+	aslash := len(a) > 0 && a[len(a)-1] == '/'
+	bslash := len(b) > 0 && b[0] == '/'
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
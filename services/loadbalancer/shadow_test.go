@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitForShadow polls until check passes or a second has gone by
+func waitForShadow(t *testing.T, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the shadow request")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShadow_MirrorsRequest(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte("primary:"), body...))
+	}))
+	defer primary.Close()
+
+	mirrored := make(chan *http.Request, 1)
+	mirroredBody := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrored <- r
+		mirroredBody <- string(body)
+		w.Write([]byte("shadow response is discarded"))
+	}))
+	defer shadow.Close()
+
+	lb := NewLoadBalancer()
+	lb.AddBackend(primary.URL)
+	if err := lb.SetShadowBackend(shadow.URL, 1); err != nil {
+		t.Fatalf("Failed to set shadow backend: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders?id=7", bytes.NewBufferString("payload")))
+
+	if got := w.Body.String(); got != "primary:payload" {
+		t.Errorf("Expected the primary response with the full body, got %q", got)
+	}
+
+	select {
+	case r := <-mirrored:
+		if r.Method != http.MethodPost || r.URL.Path != "/orders" || r.URL.RawQuery != "id=7" {
+			t.Errorf("Unexpected mirrored request %s %s", r.Method, r.URL)
+		}
+		if r.Header.Get(ShadowHeader) != "true" {
+			t.Error("Expected the mirrored request to be marked")
+		}
+		if body := <-mirroredBody; body != "payload" {
+			t.Errorf("Expected the mirrored body, got %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shadow backend never received the request")
+	}
+
+	waitForShadow(t, func() bool { return lb.ShadowStats().Mirrored == 1 })
+}
+
+func TestShadow_FailuresDontReachClient(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer primary.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "shadow broke", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// Nothing listens here once the server is closed
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	for _, shadowURL := range []string{failing.URL, unreachable.URL} {
+		lb := NewLoadBalancer()
+		lb.AddBackend(primary.URL)
+		lb.SetShadowBackend(shadowURL, 1)
+
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Errorf("Expected the client to get the primary response, got %d %q", w.Code, w.Body.String())
+		}
+		waitForShadow(t, func() bool { return lb.ShadowStats().Failed == 1 })
+	}
+}
+
+func TestShadow_Sampling(t *testing.T) {
+	lb := NewLoadBalancer()
+	if err := lb.SetShadowBackend("http://shadow:8080", 1.5); err == nil {
+		t.Error("Expected an error for a rate above 1")
+	}
+
+	lb.SetShadowBackend("http://shadow:8080", 0)
+	for i := 0; i < 100; i++ {
+		lb.mirror(httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if stats := lb.ShadowStats(); stats != (ShadowStats{}) {
+		t.Errorf("Expected a zero rate to mirror nothing, got %+v", stats)
+	}
+}
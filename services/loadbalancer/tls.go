@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultAddr is where the load balancer listens unless configured otherwise
+const defaultAddr = ":8082"
+
+// ServerConfig controls how the load balancer accepts client connections.
+// Plain HTTP is the default; setting CertFile and KeyFile terminates TLS,
+// with HTTP/2 negotiated over ALPN.
+type ServerConfig struct {
+	Addr     string
+	CertFile string
+	KeyFile  string
+
+	// RedirectAddr, when set with TLS, runs a plain HTTP listener that
+	// redirects every request to the HTTPS address
+	RedirectAddr string
+
+	// BackendHTTP2 upgrades connections to HTTPS backends to HTTP/2
+	BackendHTTP2 bool
+}
+
+// TLSEnabled reports whether the config terminates TLS
+func (c ServerConfig) TLSEnabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// Validate reports settings that can't work together
+func (c ServerConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("TLS needs both a certificate and a key file")
+	}
+	if c.RedirectAddr != "" && !c.TLSEnabled() {
+		return errors.New("the HTTPS redirect listener needs TLS to be enabled")
+	}
+	return nil
+}
+
+// ServerConfigFromEnv reads the config from LB_ADDR, LB_TLS_CERT,
+// LB_TLS_KEY, LB_REDIRECT_ADDR and LB_BACKEND_HTTP2
+func ServerConfigFromEnv() ServerConfig {
+	config := ServerConfig{
+		Addr:         os.Getenv("LB_ADDR"),
+		CertFile:     os.Getenv("LB_TLS_CERT"),
+		KeyFile:      os.Getenv("LB_TLS_KEY"),
+		RedirectAddr: os.Getenv("LB_REDIRECT_ADDR"),
+	}
+	if config.Addr == "" {
+		config.Addr = defaultAddr
+	}
+	config.BackendHTTP2, _ = strconv.ParseBool(os.Getenv("LB_BACKEND_HTTP2"))
+	return config
+}
+
+// newServer builds the client-facing server for config
+func newServer(config ServerConfig, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              config.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if config.TLSEnabled() {
+		srv.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"h2", "http/1.1"},
+		}
+	}
+	return srv
+}
+
+// ListenAndServe serves handler as configured and blocks until the main
+// listener fails
+func ListenAndServe(config ServerConfig, handler http.Handler) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	srv := newServer(config, handler)
+	if !config.TLSEnabled() {
+		log.Printf("Load balancer starting on %s", config.Addr)
+		return srv.ListenAndServe()
+	}
+
+	if config.RedirectAddr != "" {
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", config.RedirectAddr)
+			redirect := &http.Server{
+				Addr:              config.RedirectAddr,
+				Handler:           RedirectToHTTPS(config.Addr),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			log.Printf("HTTPS redirect listener stopped: %v", redirect.ListenAndServe())
+		}()
+	}
+
+	log.Printf("Load balancer starting on %s with TLS", config.Addr)
+	return srv.ListenAndServeTLS(config.CertFile, config.KeyFile)
+}
+
+// RedirectToHTTPS returns a handler that permanently redirects requests to
+// the same host and path over HTTPS on httpsAddr's port
+func RedirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+}
+
+// HTTP2Transport returns a backend transport that negotiates HTTP/2 with
+// HTTPS backends. tlsConfig may be nil to use the system roots.
+func HTTP2Transport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	return transport
+}
+
+// SetBackendTransport sets the transport used to reach backends, for
+// current and future backends. nil restores the default transport.
+func (lb *LoadBalancer) SetBackendTransport(transport http.RoundTripper) {
+	lb.transportMu.Lock()
+	defer lb.transportMu.Unlock()
+	lb.transport = transport
+}
+
+// RoundTrip sends a proxied request through the configured backend
+// transport, so it can be changed without rebuilding each backend's proxy
+func (lb *LoadBalancer) RoundTrip(r *http.Request) (*http.Response, error) {
+	lb.transportMu.RLock()
+	transport := lb.transport
+	lb.transportMu.RUnlock()
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(r)
+}
+
+// newProxy builds the reverse proxy for a backend. Failed requests are
+// recorded in the dead-letter log.
+func (lb *LoadBalancer) newProxy(u *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.Transport = lb
+	proxy.ErrorHandler = lb.proxyErrorHandler(u)
+	proxy.ModifyResponse = lb.failedResponseRecorder(u)
+	return proxy
+}
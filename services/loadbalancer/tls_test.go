@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir and returns their paths with a pool that trusts the certificate
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "loadbalancer test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	cert, _ := x509.ParseCertificate(der)
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+	return certFile, keyFile, roots
+}
+
+func TestTLSTermination_ProxiesOverHTTP2(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Proto", r.Proto)
+		io.WriteString(w, "hello from backend")
+	}))
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	backendRoots := x509.NewCertPool()
+	backendRoots.AddCert(backend.Certificate())
+
+	lb := NewLoadBalancer()
+	lb.SetBackendTransport(HTTP2Transport(&tls.Config{RootCAs: backendRoots}))
+	if err := lb.AddBackend(backend.URL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+
+	certFile, keyFile, roots := writeTestCert(t, t.TempDir())
+	config := ServerConfig{Addr: "127.0.0.1:0", CertFile: certFile, KeyFile: keyFile}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected a valid config, got %v", err)
+	}
+
+	ln, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := newServer(config, lb)
+	go srv.ServeTLS(ln, certFile, keyFile)
+	defer srv.Close()
+
+	client := &http.Client{Transport: HTTP2Transport(&tls.Config{RootCAs: roots})}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("Request over TLS failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello from backend" {
+		t.Fatalf("Expected the backend's response, got %d %q", resp.StatusCode, body)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2 to the client, got %s", resp.Proto)
+	}
+	if proto := resp.Header.Get("X-Backend-Proto"); proto != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2 to the backend, got %s", proto)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		want      string
+	}{
+		{"custom port", ":8443", "https://example.com:8443/path?q=1"},
+		{"default port", ":443", "https://example.com/path?q=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(RedirectToHTTPS(tt.httpsAddr))
+			defer srv.Close()
+
+			client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/path?q=1", nil)
+			req.Host = "example.com:8080"
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusMovedPermanently {
+				t.Errorf("Expected status 301, got %d", resp.StatusCode)
+			}
+			if location := resp.Header.Get("Location"); location != tt.want {
+				t.Errorf("Expected Location %s, got %s", tt.want, location)
+			}
+		})
+	}
+}
+
+func TestServerConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ServerConfig
+		wantErr bool
+	}{
+		{"plain HTTP", ServerConfig{Addr: ":8082"}, false},
+		{"TLS", ServerConfig{CertFile: "c.pem", KeyFile: "k.pem"}, false},
+		{"TLS with redirect", ServerConfig{CertFile: "c.pem", KeyFile: "k.pem", RedirectAddr: ":80"}, false},
+		{"cert without key", ServerConfig{CertFile: "c.pem"}, true},
+		{"redirect without TLS", ServerConfig{RedirectAddr: ":80"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// WeightTuningConfig configures backend weight auto-tuning. Every Interval
+// each backend's weight is moved toward a target derived from its success
+// rate over the interval and its latency EWMA relative to the fastest
+// backend in its pool, so traffic drifts toward healthy, fast backends.
+// Weights only steer traffic under StrategyWeighted.
+type WeightTuningConfig struct {
+	Enabled   bool
+	Interval  time.Duration
+	MinWeight int64 // weight of a backend that fails every request
+	MaxWeight int64 // weight of a fully healthy, fastest backend
+	// Gain is how far each round moves a weight toward its target, 0 to 1.
+	// Lower values react more slowly but ride out short blips.
+	Gain float64
+}
+
+// DefaultWeightTuningConfig returns the settings used when tuning is
+// enabled without overrides: weights between 1 and DefaultBackendWeight,
+// retuned every 10s, halfway toward their target each time
+func DefaultWeightTuningConfig() WeightTuningConfig {
+	return WeightTuningConfig{
+		Interval:  10 * time.Second,
+		MinWeight: 1,
+		MaxWeight: DefaultBackendWeight,
+		Gain:      0.5,
+	}
+}
+
+// WeightTuningConfigFromEnv returns DefaultWeightTuningConfig overridden by
+// LB_AUTOTUNE, LB_AUTOTUNE_INTERVAL, LB_AUTOTUNE_MIN_WEIGHT and
+// LB_AUTOTUNE_MAX_WEIGHT when they are set
+func WeightTuningConfigFromEnv() WeightTuningConfig {
+	config := DefaultWeightTuningConfig()
+	config.Enabled, _ = strconv.ParseBool(os.Getenv("LB_AUTOTUNE"))
+	if interval, err := time.ParseDuration(os.Getenv("LB_AUTOTUNE_INTERVAL")); err == nil {
+		config.Interval = interval
+	}
+	if n, err := strconv.ParseInt(os.Getenv("LB_AUTOTUNE_MIN_WEIGHT"), 10, 64); err == nil {
+		config.MinWeight = n
+	}
+	if n, err := strconv.ParseInt(os.Getenv("LB_AUTOTUNE_MAX_WEIGHT"), 10, 64); err == nil {
+		config.MaxWeight = n
+	}
+	return config
+}
+
+// DefaultBackendWeight is the weight of a backend that hasn't been tuned
+const DefaultBackendWeight = 100
+
+// tuningBaseline is a backend's request counts at the last tuning round
+type tuningBaseline struct {
+	success, fail int64
+}
+
+// Weight returns the backend's routing weight
+func (b *Backend) Weight() int64 {
+	if w := atomic.LoadInt64(&b.weight); w > 0 {
+		return w
+	}
+	return DefaultBackendWeight
+}
+
+// SetWeight changes the backend's routing weight. Weights below 1 are
+// raised to 1 so every live backend still gets some traffic.
+func (b *Backend) SetWeight(weight int64) {
+	atomic.StoreInt64(&b.weight, max(weight, 1))
+}
+
+// SetWeightTuning replaces the auto-tuning settings. Zero fields keep
+// their defaults, and the bounds are swapped if given the wrong way round.
+func (lb *LoadBalancer) SetWeightTuning(config WeightTuningConfig) {
+	defaults := DefaultWeightTuningConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.MinWeight <= 0 {
+		config.MinWeight = defaults.MinWeight
+	}
+	if config.MaxWeight <= 0 {
+		config.MaxWeight = defaults.MaxWeight
+	}
+	if config.MinWeight > config.MaxWeight {
+		config.MinWeight, config.MaxWeight = config.MaxWeight, config.MinWeight
+	}
+	if config.Gain <= 0 || config.Gain > 1 {
+		config.Gain = defaults.Gain
+	}
+
+	lb.tuningMu.Lock()
+	defer lb.tuningMu.Unlock()
+	lb.tuning = config
+}
+
+func (lb *LoadBalancer) weightTuning() WeightTuningConfig {
+	lb.tuningMu.Lock()
+	defer lb.tuningMu.Unlock()
+	return lb.tuning
+}
+
+// StartWeightTuning retunes backend weights every configured interval
+// while tuning is enabled. It is a no-op when tuning is disabled.
+func (lb *LoadBalancer) StartWeightTuning() {
+	config := lb.weightTuning()
+	if !config.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(config.Interval)
+	go func() {
+		for range ticker.C {
+			lb.TuneWeights()
+		}
+	}()
+}
+
+// TuneWeights runs one tuning round over every pool, moving each backend's
+// weight toward its target. It returns without changes when tuning is
+// disabled.
+func (lb *LoadBalancer) TuneWeights() {
+	lb.tuningMu.Lock()
+	defer lb.tuningMu.Unlock()
+
+	config := lb.tuning
+	if !config.Enabled {
+		return
+	}
+	for _, pool := range lb.pools() {
+		lb.tunePoolLocked(pool.GetBackends(), config)
+	}
+	lb.cacheManager.Stats().Invalidate()
+}
+
+// tunePoolLocked retunes backends, judging latency against the fastest of
+// them. Callers must hold lb.tuningMu.
+func (lb *LoadBalancer) tunePoolLocked(backends []*Backend, config WeightTuningConfig) {
+	var fastest time.Duration
+	for _, b := range backends {
+		if ewma := b.LatencyEWMA(); ewma > 0 && (fastest == 0 || ewma < fastest) {
+			fastest = ewma
+		}
+	}
+
+	for _, b := range backends {
+		success := atomic.LoadInt64(&b.SuccessCount)
+		fail := atomic.LoadInt64(&b.FailCount)
+		recentSuccess := success - b.tuned.success
+		recentFail := fail - b.tuned.fail
+		b.tuned = tuningBaseline{success: success, fail: fail}
+
+		// A backend that served nothing this round keeps full credit for
+		// health; its latency EWMA still counts
+		successRate := 1.0
+		if total := recentSuccess + recentFail; total > 0 {
+			successRate = float64(recentSuccess) / float64(total)
+		}
+		speed := 1.0
+		if ewma := b.LatencyEWMA(); ewma > 0 && fastest > 0 {
+			speed = float64(fastest) / float64(ewma)
+		}
+
+		span := float64(config.MaxWeight - config.MinWeight)
+		target := float64(config.MinWeight) + span*successRate*speed
+		current := float64(min(max(b.Weight(), config.MinWeight), config.MaxWeight))
+		next := int64(math.Round(current + (target-current)*config.Gain))
+		b.SetWeight(min(max(next, config.MinWeight), config.MaxWeight))
+	}
+}
+
+// weightedRoundRobin picks among backends with smooth weighted round-robin:
+// each pick adds every backend's weight to its running score, takes the
+// highest and subtracts the total from it. Over any run of picks each
+// backend is chosen in proportion to its weight, evenly interleaved.
+func (s *ServerPool) weightedRoundRobin(backends []*Backend) *Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	s.wrrMu.Lock()
+	defer s.wrrMu.Unlock()
+	if s.wrrScores == nil {
+		s.wrrScores = make(map[*Backend]int64)
+	}
+
+	var best *Backend
+	var total int64
+	for _, b := range backends {
+		weight := b.Weight()
+		s.wrrScores[b] += weight
+		total += weight
+		if best == nil || s.wrrScores[b] > s.wrrScores[best] {
+			best = b
+		}
+	}
+	s.wrrScores[best] -= total
+	return best
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// serveRound records n responses from b at latency, failing every
+// failEvery-th one (never when failEvery is zero)
+func serveRound(lb *LoadBalancer, b *Backend, n, failEvery int, latency time.Duration) {
+	for i := 1; i <= n; i++ {
+		b.ObserveLatency(latency)
+		status := http.StatusOK
+		if failEvery > 0 && i%failEvery == 0 {
+			status = http.StatusBadGateway
+		}
+		lb.recordOutcome(b, status)
+	}
+}
+
+func TestTuneWeights_PenalizesSlowErroringBackend(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddBackend("http://fast:8080")
+	lb.AddBackend("http://slow:8080")
+	lb.SetWeightTuning(WeightTuningConfig{Enabled: true, MinWeight: 1, MaxWeight: 100})
+	backends := lb.serverPool.GetBackends()
+	fast, slow := backends[0], backends[1]
+
+	previous := slow.Weight()
+	for round := 1; round <= 3; round++ {
+		serveRound(lb, fast, 10, 0, 10*time.Millisecond)
+		serveRound(lb, slow, 10, 2, 50*time.Millisecond)
+		lb.TuneWeights()
+
+		if got := slow.Weight(); got >= previous {
+			t.Errorf("Round %d: expected the slow backend's weight to drop below %d, got %d", round, previous, got)
+		}
+		previous = slow.Weight()
+		if got := fast.Weight(); got != 100 {
+			t.Errorf("Round %d: expected the fast backend to keep the max weight, got %d", round, got)
+		}
+	}
+	if previous < 1 {
+		t.Errorf("Expected the weight to stay within bounds, got %d", previous)
+	}
+
+	lb.cacheManager.Stats().Invalidate()
+	for _, stat := range lb.GetStats() {
+		if stat["url"] == "http://slow:8080" && stat["weight"] != previous {
+			t.Errorf("Expected stats to report weight %d, got %v", previous, stat["weight"])
+		}
+	}
+}
+
+func TestTuneWeights_Disabled(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddBackend("http://slow:8080")
+	b := lb.serverPool.GetBackends()[0]
+	serveRound(lb, b, 10, 1, 50*time.Millisecond)
+
+	lb.TuneWeights()
+	if got := b.Weight(); got != DefaultBackendWeight {
+		t.Errorf("Expected weights untouched while tuning is off, got %d", got)
+	}
+}
+
+func TestWeightedRoundRobin_FollowsWeights(t *testing.T) {
+	pool := &ServerPool{}
+	heavy := &Backend{}
+	light := &Backend{}
+	heavy.SetWeight(3)
+	light.SetWeight(1)
+
+	counts := map[*Backend]int{}
+	for i := 0; i < 40; i++ {
+		counts[pool.weightedRoundRobin([]*Backend{heavy, light})]++
+	}
+	if counts[heavy] != 30 || counts[light] != 10 {
+		t.Errorf("Expected a 30/10 split, got %d/%d", counts[heavy], counts[light])
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"common/apierror"
+	"common/middleware"
+	"common/moderation"
+)
+
+// DefaultMaxAttachmentBytes is the largest attachment accepted by default
+const DefaultMaxAttachmentBytes = 5 << 20
+
+// attachmentFormOverhead is room for the multipart headers and text fields
+// sent alongside an attachment
+const attachmentFormOverhead = 64 << 10
+
+var (
+	// ErrAttachmentTooLarge is returned for attachments over the size limit
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrAttachmentTypeNotAllowed is returned for content types outside the
+	// allow-list
+	ErrAttachmentTypeNotAllowed = errors.New("attachment content type not allowed")
+	// ErrAttachmentNotFound is returned when an attachment does not exist
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// Attachment is a file sent with a message. Messages carry only this
+// metadata; the payload is fetched separately with GetAttachment.
+type Attachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+
+	data []byte
+}
+
+// AttachmentPolicy limits what can be attached to a message
+type AttachmentPolicy struct {
+	MaxBytes     int64
+	ContentTypes []string // allowed media types, without parameters
+}
+
+// DefaultAttachmentPolicy allows common images, PDFs and plain text up to
+// DefaultMaxAttachmentBytes
+func DefaultAttachmentPolicy() AttachmentPolicy {
+	return AttachmentPolicy{
+		MaxBytes:     DefaultMaxAttachmentBytes,
+		ContentTypes: []string{"image/png", "image/jpeg", "image/gif", "application/pdf", "text/plain"},
+	}
+}
+
+// check validates an attachment against the policy and returns its media
+// type without parameters, lowercased
+func (p AttachmentPolicy) check(contentType string, size int64) (string, error) {
+	if size > p.MaxBytes {
+		return "", fmt.Errorf("%w: %d bytes, the limit is %d", ErrAttachmentTooLarge, size, p.MaxBytes)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrAttachmentTypeNotAllowed, contentType)
+	}
+	if !contains(p.ContentTypes, mediaType) {
+		return "", fmt.Errorf("%w: %s, expected one of %s", ErrAttachmentTypeNotAllowed, mediaType, strings.Join(p.ContentTypes, ", "))
+	}
+	return mediaType, nil
+}
+
+// SetAttachmentPolicy replaces the size and content type limits for new
+// attachments
+func (s *MessagingService) SetAttachmentPolicy(policy AttachmentPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attachmentPolicy = policy
+}
+
+// SendMessageWithAttachment sends a message carrying one file. The file is
+// checked against the attachment policy before anything is stored.
+func (s *MessagingService) SendMessageWithAttachment(fromUserID, toUserID, content, filename, contentType string, data []byte) (*Message, error) {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename == "." || filename == "/" {
+		return nil, fmt.Errorf("attachment filename is required")
+	}
+	if err := s.checkContent(content); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mediaType, err := s.attachmentPolicy.check(contentType, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	chatID, err := s.findOrCreateChat(fromUserID, toUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.attachmentIndex++
+	attachment := &Attachment{
+		ID:          generateID("att", s.attachmentIndex),
+		Filename:    filename,
+		ContentType: mediaType,
+		Size:        int64(len(data)),
+		data:        append([]byte(nil), data...),
+	}
+
+	s.messageIndex++
+	message := &Message{
+		ID:          generateID("msg", s.messageIndex),
+		FromUserID:  fromUserID,
+		ToUserID:    toUserID,
+		Content:     content,
+		Timestamp:   time.Now(),
+		Status:      StatusSent,
+		ChatID:      chatID,
+		Attachments: []*Attachment{attachment},
+	}
+
+	s.attachments[attachment.ID] = attachment
+	s.messages[message.ID] = message
+	s.chats[chatID].Messages = append(s.chats[chatID].Messages, message.ID)
+
+	return message, nil
+}
+
+// GetAttachment returns an attachment's metadata and payload
+func (s *MessagingService) GetAttachment(attachmentID string) (*Attachment, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attachment, exists := s.attachments[attachmentID]
+	if !exists {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	return attachment, attachment.data, nil
+}
+
+// sendAttachmentHandler handles a multipart/form-data POST with
+// from_user_id, to_user_id and content fields and the file in "file"
+func sendAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The body is already bounded by the request guard; keep the file in
+	// memory rather than spilling to disk
+	if err := r.ParseMultipartForm(DefaultMaxAttachmentBytes + attachmentFormOverhead); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		apierror.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+
+	message, err := service.SendMessageWithAttachment(
+		middleware.AuthenticatedUserID(r, r.FormValue("from_user_id")),
+		r.FormValue("to_user_id"),
+		r.FormValue("content"),
+		header.Filename,
+		contentType,
+		data,
+	)
+	switch {
+	case errors.Is(err, ErrAttachmentTooLarge):
+		apierror.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, ErrAttachmentTypeNotAllowed):
+		apierror.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, moderation.ErrRejected):
+		apierror.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// downloadAttachmentHandler serves GET /attachment/{id}
+func downloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID := strings.TrimPrefix(r.URL.Path, "/attachment/")
+	if attachmentID == "" {
+		apierror.Error(w, "attachment id is required", http.StatusBadRequest)
+		return
+	}
+
+	attachment, data, err := service.GetAttachment(attachmentID)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestSendMessageWithAttachment_Allowed(t *testing.T) {
+	service := NewMessagingService()
+
+	message, err := service.SendMessageWithAttachment("user1", "user2", "see attached", "notes.txt", "text/plain; charset=utf-8", []byte("hello"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(message.Attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(message.Attachments))
+	}
+
+	attachment := message.Attachments[0]
+	if attachment.Filename != "notes.txt" || attachment.ContentType != "text/plain" || attachment.Size != 5 {
+		t.Errorf("Unexpected attachment metadata: %+v", attachment)
+	}
+
+	_, data, err := service.GetAttachment(attachment.ID)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected payload hello, got %q (%v)", data, err)
+	}
+}
+
+func TestSendMessageWithAttachment_Rejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		size        int
+		wantErr     error
+	}{
+		{"oversized", "image/png", 2048, ErrAttachmentTooLarge},
+		{"disallowed type", "application/x-msdownload", 16, ErrAttachmentTypeNotAllowed},
+		{"malformed type", "not a type", 16, ErrAttachmentTypeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewMessagingService()
+			service.SetAttachmentPolicy(AttachmentPolicy{MaxBytes: 1024, ContentTypes: []string{"image/png"}})
+
+			_, err := service.SendMessageWithAttachment("user1", "user2", "", "file.bin", tt.contentType, make([]byte, tt.size))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if len(service.messages) != 0 || len(service.attachments) != 0 || len(service.chats) != 0 {
+				t.Error("Expected a rejected attachment to store nothing")
+			}
+		})
+	}
+}
+
+func TestSendMessageWithAttachment_StripsDirectories(t *testing.T) {
+	service := NewMessagingService()
+
+	message, err := service.SendMessageWithAttachment("user1", "user2", "", `..\..\evil/photo.png`, "image/png", []byte{1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := message.Attachments[0].Filename; got != "photo.png" {
+		t.Errorf("Expected photo.png, got %s", got)
+	}
+}
+
+// attachmentForm builds a multipart body for /send-attachment
+func attachmentForm(t *testing.T, contentType string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("from_user_id", "user1")
+	form.WriteField("to_user_id", "user2")
+	form.WriteField("content", "here you go")
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="report.pdf"`)
+	header.Set("Content-Type", contentType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create part: %v", err)
+	}
+	part.Write(data)
+	form.Close()
+
+	return &body, form.FormDataContentType()
+}
+
+func TestSendAttachmentHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantStatus  int
+	}{
+		{"allowed", "application/pdf", http.StatusOK},
+		{"disallowed", "application/zip", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service = NewMessagingService()
+			body, formType := attachmentForm(t, tt.contentType, []byte("%PDF-1.4"))
+
+			req := httptest.NewRequest(http.MethodPost, "/send-attachment", body)
+			req.Header.Set("Content-Type", formType)
+			w := httptest.NewRecorder()
+
+			sendAttachmentHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(w.Body.String(), tt.contentType) {
+					t.Errorf("Expected the error to name the rejected type, got %q", w.Body.String())
+				}
+				return
+			}
+
+			var message Message
+			json.NewDecoder(w.Body).Decode(&message)
+			if len(message.Attachments) != 1 || message.Attachments[0].Filename != "report.pdf" {
+				t.Fatalf("Expected report.pdf attachment, got %+v", message.Attachments)
+			}
+
+			// Listings carry metadata only
+			messages, _ := service.GetMessages(message.ChatID)
+			listing, _ := json.Marshal(messages)
+			if strings.Contains(string(listing), "PDF-1.4") {
+				t.Errorf("Expected message listing to omit the payload: %s", listing)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/attachment/"+message.Attachments[0].ID, nil)
+			w = httptest.NewRecorder()
+			downloadAttachmentHandler(w, req)
+
+			got, _ := io.ReadAll(w.Body)
+			if w.Code != http.StatusOK || string(got) != "%PDF-1.4" {
+				t.Errorf("Expected the file back, got %d %q", w.Code, got)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+				t.Errorf("Expected application/pdf, got %s", ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=report.pdf" {
+				t.Errorf("Unexpected Content-Disposition %q", cd)
+			}
+		})
+	}
+}
+
+func TestDownloadAttachmentHandler_NotFound(t *testing.T) {
+	service = NewMessagingService()
+
+	req := httptest.NewRequest(http.MethodGet, "/attachment/att_9", nil)
+	w := httptest.NewRecorder()
+
+	downloadAttachmentHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestSnapshotRestore_KeepsAttachments(t *testing.T) {
+	original := NewMessagingService()
+	message, _ := original.SendMessageWithAttachment("user1", "user2", "", "a.png", "image/png", []byte{0x89, 'P', 'N', 'G'})
+
+	data, err := original.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	restored := NewMessagingService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	attachment, payload, err := restored.GetAttachment(message.Attachments[0].ID)
+	if err != nil || !bytes.Equal(payload, []byte{0x89, 'P', 'N', 'G'}) {
+		t.Fatalf("Expected payload to survive restore, got %v (%v)", payload, err)
+	}
+	if restored.messages[message.ID].Attachments[0] != attachment {
+		t.Error("Expected the restored message to share the restored attachment")
+	}
+}
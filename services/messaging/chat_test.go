@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendMessage_OneChatPerPair(t *testing.T) {
+	service := NewMessagingService()
+
+	first, _ := service.SendMessage("alice", "bob", "hi")
+	for i := 0; i < 5; i++ {
+		for _, send := range []func() (*Message, error){
+			func() (*Message, error) { return service.SendMessage("alice", "bob", "again") },
+			func() (*Message, error) { return service.SendMessage("bob", "alice", "reply") },
+			func() (*Message, error) {
+				return service.SendMessageWithClientID("bob", "alice", "retry-safe", fmt.Sprintf("c%d", i))
+			},
+			func() (*Message, error) {
+				return service.SendMessageWithAttachment("alice", "bob", "file", "a.txt", "text/plain", []byte("x"))
+			},
+		} {
+			message, err := send()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if message.ChatID != first.ChatID {
+				t.Fatalf("Expected every message to use chat %s, got %s", first.ChatID, message.ChatID)
+			}
+		}
+	}
+
+	for _, userID := range []string{"alice", "bob"} {
+		if chats, _ := service.GetUserChats(userID); len(chats) != 1 {
+			t.Errorf("Expected %s to have one chat, got %d", userID, len(chats))
+		}
+	}
+}
+
+func TestSendMessage_ManyChatsHaveDistinctIDs(t *testing.T) {
+	service := NewMessagingService()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		message, _ := service.SendMessage("alice", fmt.Sprintf("user%d", i), "hi")
+		if seen[message.ChatID] {
+			t.Fatalf("Expected a new chat for user%d, got existing %s", i, message.ChatID)
+		}
+		seen[message.ChatID] = true
+	}
+	if chats, _ := service.GetUserChats("alice"); len(chats) != 100 {
+		t.Errorf("Expected 100 chats, got %d", len(chats))
+	}
+}
+
+func TestSendMessage_ToSelf(t *testing.T) {
+	service := NewMessagingService()
+
+	if _, err := service.SendMessage("alice", "alice", "note to self"); !errors.Is(err, ErrSelfMessage) {
+		t.Errorf("Expected ErrSelfMessage, got %v", err)
+	}
+	if _, err := service.SendMessageWithAttachment("alice", "alice", "", "a.txt", "text/plain", []byte("x")); !errors.Is(err, ErrSelfMessage) {
+		t.Errorf("Expected ErrSelfMessage for an attachment, got %v", err)
+	}
+	if chats, _ := service.GetUserChats("alice"); len(chats) != 0 {
+		t.Errorf("Expected no chat to be created, got %d", len(chats))
+	}
+}
+
+func TestSendMessageHandler_ToSelf(t *testing.T) {
+	service = NewMessagingService()
+
+	w := httptest.NewRecorder()
+	sendMessageHandler(w, httptest.NewRequest(http.MethodPost, "/send",
+		strings.NewReader(`{"from_user_id":"alice","to_user_id":"alice","content":"hi"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRestore_KeepsOneChatPerPair(t *testing.T) {
+	original := NewMessagingService()
+	first, _ := original.SendMessage("alice", "bob", "hi")
+	data, _ := original.Snapshot()
+
+	restored := NewMessagingService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	message, _ := restored.SendMessage("bob", "alice", "back again")
+	if message.ChatID != first.ChatID {
+		t.Errorf("Expected the restored chat %s to be reused, got %s", first.ChatID, message.ChatID)
+	}
+}
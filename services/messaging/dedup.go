@@ -0,0 +1,127 @@
+package main
+
+import "time"
+
+// DefaultDedupTTL is how long a client message ID is remembered
+const DefaultDedupTTL = 10 * time.Minute
+
+// maxDedupEntries bounds the dedup window; the oldest keys are forgotten
+// first once it is full
+const maxDedupEntries = 10000
+
+// dedupKey identifies a client's message: client message IDs only have to
+// be unique per sender
+type dedupKey struct {
+	fromUserID  string
+	clientMsgID string
+}
+
+// dedupEntry remembers the message a client message ID produced
+type dedupEntry struct {
+	key       dedupKey
+	messageID string
+	expires   time.Time
+}
+
+// dedupWindow maps recent client message IDs to the messages they created.
+// Entries are kept in insertion order, which is also expiry order since
+// they all share one TTL.
+type dedupWindow struct {
+	ttl     time.Duration
+	entries map[dedupKey]dedupEntry
+	order   []dedupEntry
+}
+
+func newDedupWindow(ttl time.Duration) *dedupWindow {
+	return &dedupWindow{ttl: ttl, entries: make(map[dedupKey]dedupEntry)}
+}
+
+// get returns the message ID remembered for key, if it hasn't expired
+func (d *dedupWindow) get(key dedupKey, now time.Time) (string, bool) {
+	d.prune(now)
+	entry, exists := d.entries[key]
+	return entry.messageID, exists
+}
+
+// put remembers that key produced messageID
+func (d *dedupWindow) put(key dedupKey, messageID string, now time.Time) {
+	entry := dedupEntry{key: key, messageID: messageID, expires: now.Add(d.ttl)}
+	d.entries[key] = entry
+	d.order = append(d.order, entry)
+	d.prune(now)
+}
+
+// prune drops expired entries and the oldest ones past maxDedupEntries
+func (d *dedupWindow) prune(now time.Time) {
+	drop := 0
+	for drop < len(d.order) {
+		entry := d.order[drop]
+		if now.Before(entry.expires) && len(d.order)-drop <= maxDedupEntries {
+			break
+		}
+		// A key sent again after expiring has a newer entry; keep that one
+		if current := d.entries[entry.key]; current == entry {
+			delete(d.entries, entry.key)
+		}
+		drop++
+	}
+	// append reallocates once the slice runs out of room, releasing the
+	// dropped prefix
+	d.order = d.order[drop:]
+}
+
+// SetDedupTTL changes how long client message IDs are remembered. IDs
+// already seen are forgotten.
+func (s *MessagingService) SetDedupTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup = newDedupWindow(ttl)
+}
+
+// SendMessageWithClientID sends a message like SendMessage, deduplicating
+// client retries: if fromUserID already sent clientMsgID within the dedup
+// TTL, the original message is returned and nothing new is stored. An empty
+// clientMsgID disables deduplication.
+func (s *MessagingService) SendMessageWithClientID(fromUserID, toUserID, content, clientMsgID string) (*Message, error) {
+	if err := s.checkContent(content); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	key := dedupKey{fromUserID: fromUserID, clientMsgID: clientMsgID}
+	if clientMsgID != "" {
+		if messageID, seen := s.dedup.get(key, now); seen {
+			if message, exists := s.messages[messageID]; exists {
+				return message, nil
+			}
+		}
+	}
+
+	chatID, err := s.findOrCreateChat(fromUserID, toUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.messageIndex++
+	message := &Message{
+		ID:          generateID("msg", s.messageIndex),
+		FromUserID:  fromUserID,
+		ToUserID:    toUserID,
+		Content:     content,
+		Timestamp:   now,
+		Status:      StatusSent,
+		ChatID:      chatID,
+		ClientMsgID: clientMsgID,
+	}
+
+	s.messages[message.ID] = message
+	s.chats[chatID].Messages = append(s.chats[chatID].Messages, message.ID)
+	if clientMsgID != "" {
+		s.dedup.put(key, message.ID, now)
+	}
+
+	return message, nil
+}
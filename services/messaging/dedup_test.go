@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendMessageWithClientID_SameIDStoresOnce(t *testing.T) {
+	service := NewMessagingService()
+
+	first, err := service.SendMessageWithClientID("alice", "bob", "hello", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry, err := service.SendMessageWithClientID("alice", "bob", "hello", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retry != first {
+		t.Errorf("Expected the retry to return the original message, got %+v and %+v", first, retry)
+	}
+	messages, _ := service.GetMessages(first.ChatID)
+	if len(messages) != 1 {
+		t.Errorf("Expected one stored message, got %d", len(messages))
+	}
+}
+
+func TestSendMessageWithClientID_DifferentIDsStoreTwo(t *testing.T) {
+	service := NewMessagingService()
+
+	first, _ := service.SendMessageWithClientID("alice", "bob", "hello", "c1")
+	second, _ := service.SendMessageWithClientID("alice", "bob", "hello", "c2")
+	if first.ID == second.ID {
+		t.Fatalf("Expected two messages, got %s twice", first.ID)
+	}
+
+	// Client IDs are per sender
+	reply, _ := service.SendMessageWithClientID("bob", "alice", "hi", "c1")
+	if reply.ID == first.ID {
+		t.Errorf("Expected another sender's c1 to be a new message")
+	}
+
+	// No client ID, no dedup
+	service.SendMessageWithClientID("alice", "bob", "again", "")
+	service.SendMessageWithClientID("alice", "bob", "again", "")
+
+	if messages, _ := service.GetMessages(first.ChatID); len(messages) != 5 {
+		t.Errorf("Expected five stored messages, got %d", len(messages))
+	}
+}
+
+func TestSendMessageWithClientID_ExpiresAfterTTL(t *testing.T) {
+	service := NewMessagingService()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	service.SetDedupTTL(time.Minute)
+
+	first, _ := service.SendMessageWithClientID("alice", "bob", "hello", "c1")
+
+	now = now.Add(59 * time.Second)
+	if retry, _ := service.SendMessageWithClientID("alice", "bob", "hello", "c1"); retry != first {
+		t.Errorf("Expected a retry inside the TTL to be deduplicated")
+	}
+
+	now = now.Add(time.Second)
+	if late, _ := service.SendMessageWithClientID("alice", "bob", "hello", "c1"); late == first {
+		t.Errorf("Expected a send after the TTL to create a new message")
+	}
+}
+
+func TestDedupWindow_Bounded(t *testing.T) {
+	now := time.Now()
+	d := newDedupWindow(time.Hour)
+	for i := 0; i < maxDedupEntries+10; i++ {
+		d.put(dedupKey{fromUserID: "alice", clientMsgID: string(rune('a' + i))}, "msg", now)
+	}
+
+	if len(d.entries) != maxDedupEntries || len(d.order) != maxDedupEntries {
+		t.Errorf("Expected %d entries, got %d in the map and %d in order", maxDedupEntries, len(d.entries), len(d.order))
+	}
+	if _, seen := d.get(dedupKey{fromUserID: "alice", clientMsgID: "a"}, now); seen {
+		t.Errorf("Expected the oldest key to be forgotten")
+	}
+}
+
+func TestSendMessageHandler_ClientMsgID(t *testing.T) {
+	service = NewMessagingService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	send := func() *Message {
+		body := []byte(`{"from_user_id":"alice","to_user_id":"bob","content":"hello","client_msg_id":"c1"}`)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/send", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var message Message
+		json.NewDecoder(w.Body).Decode(&message)
+		return &message
+	}
+
+	first, retry := send(), send()
+	if first.ID != retry.ID || retry.ClientMsgID != "c1" {
+		t.Errorf("Expected the retry to return %s with its client ID, got %+v", first.ID, retry)
+	}
+}
@@ -1,22 +1,68 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/health"
+	"common/middleware"
+	"common/moderation"
+	"common/openapi"
+	"common/validate"
+)
+
+var (
+	// ErrChatNotFound is returned when a chat does not exist
+	ErrChatNotFound = errors.New("chat not found")
+	// ErrUnsupportedFormat is returned for an unknown export format
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+	// ErrMessageNotFound is returned when a message does not exist
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrInvalidTransition is returned when a status change would move a
+	// message backward
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrSelfMessage is returned when a user sends a message to themselves
+	ErrSelfMessage = errors.New("cannot send a message to yourself")
+)
+
+// MessageStatus is the delivery state of a message
+type MessageStatus string
+
+const (
+	StatusSent      MessageStatus = "sent"
+	StatusDelivered MessageStatus = "delivered"
+	StatusRead      MessageStatus = "read"
 )
 
+// statusOrder ranks statuses so transitions can only move forward
+var statusOrder = map[MessageStatus]int{
+	StatusSent:      0,
+	StatusDelivered: 1,
+	StatusRead:      2,
+}
+
 // Message represents a message in the system
 type Message struct {
-	ID          string    `json:"id"`
-	FromUserID  string    `json:"from_user_id"`
-	ToUserID    string    `json:"to_user_id"`
-	Content     string    `json:"content"`
-	Timestamp   time.Time `json:"timestamp"`
-	Read        bool      `json:"read"`
-	ChatID      string    `json:"chat_id"`
+	ID         string        `json:"id"`
+	FromUserID string        `json:"from_user_id"`
+	ToUserID   string        `json:"to_user_id"`
+	Content    string        `json:"content"`
+	Timestamp  time.Time     `json:"timestamp"`
+	Status     MessageStatus `json:"status"`
+	ChatID     string        `json:"chat_id"`
+
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	ClientMsgID string        `json:"client_msg_id,omitempty"` // the sender's dedup key, if any
 }
 
 // Chat represents a conversation between users
@@ -31,72 +77,97 @@ type MessagingService struct {
 	mu           sync.RWMutex
 	messages     map[string]*Message
 	chats        map[string]*Chat
-	userChats    map[string][]string // userID -> []chatID
+	userChats    map[string][]string       // userID -> []chatID
+	chatsByPair  map[chatPair]string       // members -> chatID, one chat per pair
+	readCursors  map[string]map[string]int // chatID -> userID -> messages read, from the start of the chat
 	messageIndex int64
 	chatIndex    int64
+
+	attachments      map[string]*Attachment
+	attachmentIndex  int64
+	attachmentPolicy AttachmentPolicy
+
+	filter moderation.ContentFilter // checks message text before it is stored
+
+	dedup *dedupWindow // recent client message IDs; not persisted
+	now   func() time.Time
 }
 
 // NewMessagingService creates a new messaging service
 func NewMessagingService() *MessagingService {
 	return &MessagingService{
-		messages:  make(map[string]*Message),
-		chats:     make(map[string]*Chat),
-		userChats: make(map[string][]string),
+		messages:    make(map[string]*Message),
+		chats:       make(map[string]*Chat),
+		userChats:   make(map[string][]string),
+		chatsByPair: make(map[chatPair]string),
+		readCursors: make(map[string]map[string]int),
+
+		attachments:      make(map[string]*Attachment),
+		attachmentPolicy: DefaultAttachmentPolicy(),
+
+		filter: moderation.NewWordFilter(moderation.DefaultMaxLength),
+
+		dedup: newDedupWindow(DefaultDedupTTL),
+		now:   time.Now,
 	}
 }
 
-// SendMessage sends a message
-func (s *MessagingService) SendMessage(fromUserID, toUserID, content string) (*Message, error) {
+// SetContentFilter replaces the filter new messages are checked against
+func (s *MessagingService) SetContentFilter(filter moderation.ContentFilter) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.filter = filter
+}
 
-	// Find or create chat
-	chatID := s.findOrCreateChat(fromUserID, toUserID)
-
-	s.messageIndex++
-	messageID := generateID("msg", s.messageIndex)
-
-	message := &Message{
-		ID:         messageID,
-		FromUserID: fromUserID,
-		ToUserID:   toUserID,
-		Content:    content,
-		Timestamp:  time.Now(),
-		Read:       false,
-		ChatID:     chatID,
-	}
+// checkContent runs the content filter outside s.mu, so a slow or
+// re-entrant filter doesn't hold up the service
+func (s *MessagingService) checkContent(texts ...string) error {
+	s.mu.RLock()
+	filter := s.filter
+	s.mu.RUnlock()
+	return moderation.Check(filter, texts...)
+}
 
-	s.messages[messageID] = message
-	s.chats[chatID].Messages = append(s.chats[chatID].Messages, messageID)
+// SendMessage sends a message
+func (s *MessagingService) SendMessage(fromUserID, toUserID, content string) (*Message, error) {
+	return s.SendMessageWithClientID(fromUserID, toUserID, content, "")
+}
 
-	return message, nil
+// chatPair identifies the chat between two users regardless of who wrote
+// first: a is always the smaller user ID
+type chatPair struct {
+	a, b string
 }
 
-// findOrCreateChat finds or creates a chat between two users
-func (s *MessagingService) findOrCreateChat(user1ID, user2ID string) string {
-	// Check if chat already exists
-	for _, chatID := range s.userChats[user1ID] {
-		chat := s.chats[chatID]
-		if contains(chat.UserIDs, user2ID) {
-			return chatID
-		}
+// newChatPair returns the pair for two users in either order
+func newChatPair(user1ID, user2ID string) chatPair {
+	if user2ID < user1ID {
+		user1ID, user2ID = user2ID, user1ID
 	}
+	return chatPair{a: user1ID, b: user2ID}
+}
 
-	// Create new chat
-	s.chatIndex++
-	chatID := generateID("chat", s.chatIndex)
-
-	chat := &Chat{
-		ID:       chatID,
-		UserIDs:  []string{user1ID, user2ID},
-		Messages: []string{},
+// findOrCreateChat returns the chat between two users, creating it on their
+// first message. Must be called with s.mu write-held.
+func (s *MessagingService) findOrCreateChat(user1ID, user2ID string) (string, error) {
+	if user1ID == user2ID {
+		return "", ErrSelfMessage
 	}
 
-	s.chats[chatID] = chat
-	s.userChats[user1ID] = append(s.userChats[user1ID], chatID)
-	s.userChats[user2ID] = append(s.userChats[user2ID], chatID)
+	chatID, _ := findOrCreate(s.chatsByPair, newChatPair(user1ID, user2ID), func() string {
+		s.chatIndex++
+		chat := &Chat{
+			ID:       generateID("chat", s.chatIndex),
+			UserIDs:  []string{user1ID, user2ID},
+			Messages: []string{},
+		}
 
-	return chatID
+		s.chats[chat.ID] = chat
+		s.userChats[user1ID] = append(s.userChats[user1ID], chat.ID)
+		s.userChats[user2ID] = append(s.userChats[user2ID], chat.ID)
+		return chat.ID
+	})
+	return chatID, nil
 }
 
 // GetMessages retrieves messages for a chat
@@ -139,17 +210,255 @@ func (s *MessagingService) GetUserChats(userID string) ([]*Chat, error) {
 	return chats, nil
 }
 
-// MarkAsRead marks a message as read
+// UpdateStatus moves a message to the given status. Statuses only move
+// forward (sent -> delivered -> read); setting the current status again is a
+// no-op.
+func (s *MessagingService) UpdateStatus(messageID string, status MessageStatus) error {
+	next, valid := statusOrder[status]
+	if !valid {
+		return ErrInvalidTransition
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message, exists := s.messages[messageID]
+	if !exists {
+		return ErrMessageNotFound
+	}
+
+	if next < statusOrder[message.Status] {
+		return ErrInvalidTransition
+	}
+
+	message.Status = status
+	return nil
+}
+
+// MarkDelivered marks a message as delivered to the recipient
+func (s *MessagingService) MarkDelivered(messageID string) error {
+	return s.UpdateStatus(messageID, StatusDelivered)
+}
+
+// MarkAsRead marks a message as read. Read is the terminal status. The
+// recipient's read cursor moves up to the message, so everything before it
+// counts as read too.
 func (s *MessagingService) MarkAsRead(messageID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	message, exists := s.messages[messageID]
 	if !exists {
-		return nil
+		return ErrMessageNotFound
+	}
+
+	chat := s.chats[message.ChatID]
+	for i, id := range chat.Messages {
+		if id == messageID {
+			s.advanceReadCursorLocked(chat, message.ToUserID, i+1)
+			break
+		}
+	}
+	message.Status = StatusRead
+	return nil
+}
+
+// ExportChat produces a transcript of every message in a chat in
+// chronological order. Supported formats are "json" and "text".
+func (s *MessagingService) ExportChat(chatID string, format string) ([]byte, error) {
+	if format != "json" && format != "text" {
+		return nil, ErrUnsupportedFormat
+	}
+
+	s.mu.RLock()
+	chat, exists := s.chats[chatID]
+	if !exists {
+		s.mu.RUnlock()
+		return nil, ErrChatNotFound
+	}
+
+	messages := make([]Message, 0, len(chat.Messages))
+	for _, msgID := range chat.Messages {
+		if msg, exists := s.messages[msgID]; exists {
+			messages = append(messages, *msg)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+
+	if format == "json" {
+		return json.MarshalIndent(map[string]interface{}{
+			"chat_id":  chatID,
+			"messages": messages,
+		}, "", "  ")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Chat %s\n", chatID)
+	for _, msg := range messages {
+		fmt.Fprintf(&buf, "[%s] %s -> %s (%s): %s\n",
+			msg.Timestamp.Format(time.RFC3339), msg.FromUserID, msg.ToUserID, msg.Status, msg.Content)
+	}
+	return buf.Bytes(), nil
+}
+
+// messagingState is the serialized form of the whole service. The
+// user -> chats index is not stored: it is rebuilt from the chats' members.
+type messagingState struct {
+	Chats        []*Chat    `json:"chats"`
+	Messages     []*Message `json:"messages"`
+	MessageIndex int64      `json:"message_index"`
+	ChatIndex    int64      `json:"chat_index"`
+
+	ReadCursors map[string]map[string]int `json:"read_cursors,omitempty"` // chatID -> userID -> messages read
+
+	Attachments     []attachmentState `json:"attachments,omitempty"`
+	AttachmentIndex int64             `json:"attachment_index"`
+}
+
+// attachmentState is an attachment with its payload, which Attachment
+// keeps out of message listings
+type attachmentState struct {
+	Attachment
+	Data []byte `json:"data"`
+}
+
+// Snapshot serializes every chat, message and the ID counters to JSON
+func (s *MessagingService) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := messagingState{
+		Chats:        make([]*Chat, 0, len(s.chats)),
+		Messages:     make([]*Message, 0, len(s.messages)),
+		MessageIndex: s.messageIndex,
+		ChatIndex:    s.chatIndex,
+		ReadCursors:  s.readCursors,
+	}
+
+	for _, chat := range s.chats {
+		state.Chats = append(state.Chats, chat)
+	}
+	sort.Slice(state.Chats, func(i, j int) bool { return state.Chats[i].ID < state.Chats[j].ID })
+
+	for _, chat := range state.Chats {
+		for _, messageID := range chat.Messages {
+			if message, exists := s.messages[messageID]; exists {
+				state.Messages = append(state.Messages, message)
+				for _, attachment := range message.Attachments {
+					state.Attachments = append(state.Attachments, attachmentState{Attachment: *attachment, Data: attachment.data})
+				}
+			}
+		}
+	}
+	state.AttachmentIndex = s.attachmentIndex
+
+	return json.Marshal(state)
+}
+
+// Restore replaces the service's state with a snapshot and rebuilds the
+// user -> chats index. Snapshots whose chats and messages don't refer to
+// each other consistently are rejected and leave the current state in place.
+func (s *MessagingService) Restore(data []byte) error {
+	var state messagingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	attachments := make(map[string]*Attachment, len(state.Attachments))
+	for _, as := range state.Attachments {
+		if as.ID == "" {
+			return fmt.Errorf("attachment without an id")
+		}
+		attachment := as.Attachment
+		attachment.data = as.Data
+		attachment.Size = int64(len(as.Data))
+		attachments[attachment.ID] = &attachment
 	}
 
-	message.Read = true
+	messages := make(map[string]*Message, len(state.Messages))
+	for _, message := range state.Messages {
+		if message == nil || message.ID == "" {
+			return fmt.Errorf("message without an id")
+		}
+		if _, exists := messages[message.ID]; exists {
+			return fmt.Errorf("duplicate message %s", message.ID)
+		}
+		// Point at the attachments that carry the payload
+		for i, attachment := range message.Attachments {
+			if attachment == nil || attachments[attachment.ID] == nil {
+				return fmt.Errorf("message %s has an attachment missing from the snapshot", message.ID)
+			}
+			message.Attachments[i] = attachments[attachment.ID]
+		}
+		messages[message.ID] = message
+	}
+
+	chats := make(map[string]*Chat, len(state.Chats))
+	userChats := make(map[string][]string)
+	chatsByPair := make(map[chatPair]string, len(state.Chats))
+	for _, chat := range state.Chats {
+		if chat == nil || chat.ID == "" {
+			return fmt.Errorf("chat without an id")
+		}
+		if _, exists := chats[chat.ID]; exists {
+			return fmt.Errorf("duplicate chat %s", chat.ID)
+		}
+		for _, messageID := range chat.Messages {
+			if message, exists := messages[messageID]; !exists || message.ChatID != chat.ID {
+				return fmt.Errorf("chat %s lists message %s that isn't in it", chat.ID, messageID)
+			}
+		}
+		if chat.Messages == nil {
+			chat.Messages = []string{}
+		}
+		chats[chat.ID] = chat
+		for _, userID := range chat.UserIDs {
+			userChats[userID] = append(userChats[userID], chat.ID)
+		}
+		// Older snapshots may hold several chats for a pair; new messages
+		// go to the first
+		if len(chat.UserIDs) == 2 {
+			findOrCreate(chatsByPair, newChatPair(chat.UserIDs[0], chat.UserIDs[1]), func() string { return chat.ID })
+		}
+	}
+	for _, message := range messages {
+		if _, exists := chats[message.ChatID]; !exists {
+			return fmt.Errorf("message %s belongs to unknown chat %s", message.ID, message.ChatID)
+		}
+	}
+
+	readCursors := make(map[string]map[string]int, len(state.ReadCursors))
+	for chatID, cursors := range state.ReadCursors {
+		chat, exists := chats[chatID]
+		if !exists {
+			return fmt.Errorf("read cursor for unknown chat %s", chatID)
+		}
+		readCursors[chatID] = make(map[string]int, len(cursors))
+		for userID, read := range cursors {
+			if !contains(chat.UserIDs, userID) || read < 0 || read > len(chat.Messages) {
+				return fmt.Errorf("invalid read cursor for %s in chat %s", userID, chatID)
+			}
+			readCursors[chatID][userID] = read
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats = chats
+	s.messages = messages
+	s.userChats = userChats
+	s.chatsByPair = chatsByPair
+	s.readCursors = readCursors
+	s.messageIndex = state.MessageIndex
+	s.chatIndex = state.ChatIndex
+	s.attachments = attachments
+	s.attachmentIndex = state.AttachmentIndex
+	s.dedup = newDedupWindow(s.dedup.ttl)
+
 	return nil
 }
 
@@ -167,28 +476,65 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
+// findOrCreate returns m[key], storing create() under key first if it is
+// missing, and reports whether it was created. It is race safe as long as
+// the caller holds the lock guarding m for writing for the whole call, so
+// check and insert can't interleave with another caller's.
+func findOrCreate[K comparable, V any](m map[K]V, key K, create func() V) (V, bool) {
+	if value, exists := m[key]; exists {
+		return value, false
+	}
+	value := create()
+	m[key] = value
+	return value, true
+}
+
 var service *MessagingService
 
+// sendMessageRequest is the body of /send
+type sendMessageRequest struct {
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+	Content    string `json:"content"`
+
+	// ClientMsgID lets a client retry a send without duplicating the message
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+}
+
+func (req sendMessageRequest) validate() error {
+	var v validate.Validator
+	v.String("from_user_id", req.FromUserID, validate.Required)
+	v.String("to_user_id", req.ToUserID, validate.Required)
+	v.String("content", req.Content, validate.Required)
+	return v.Err()
+}
+
 func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		FromUserID string `json:"from_user_id"`
-		ToUserID   string `json:"to_user_id"`
-		Content    string `json:"content"`
-	}
+	var req sendMessageRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	message, err := service.SendMessage(req.FromUserID, req.ToUserID, req.Content)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	req.FromUserID = middleware.AuthenticatedUserID(r, req.FromUserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	message, err := service.SendMessageWithClientID(req.FromUserID, req.ToUserID, req.Content, req.ClientMsgID)
+	switch {
+	case errors.Is(err, moderation.ErrRejected):
+		apierror.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -199,13 +545,13 @@ func sendMessageHandler(w http.ResponseWriter, r *http.Request) {
 func getMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	chatID := r.URL.Query().Get("chat_id")
 	if chatID == "" {
-		http.Error(w, "chat_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "chat_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	messages, err := service.GetMessages(chatID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -216,13 +562,13 @@ func getMessagesHandler(w http.ResponseWriter, r *http.Request) {
 func getUserChatsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
-		http.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	chats, err := service.GetUserChats(userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -230,27 +576,101 @@ func getUserChatsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(chats)
 }
 
+// messageStatusRequest is the body of /mark-read and /mark-delivered
+type messageStatusRequest struct {
+	MessageID string `json:"message_id"`
+}
+
 func markAsReadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		MessageID string `json:"message_id"`
-	}
+	var req messageStatusRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if err := service.MarkAsRead(req.MessageID); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeStatusError(w, err)
+		return
+	}
+
+	writeStatus(w, req.MessageID, StatusRead)
+}
+
+func markDeliveredHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	var req messageStatusRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.MarkDelivered(req.MessageID); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	writeStatus(w, req.MessageID, StatusDelivered)
+}
+
+func writeStatus(w http.ResponseWriter, messageID string, status MessageStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message_id": messageID,
+		"status":     status,
+	})
+}
+
+func writeStatusError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidTransition) {
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	apierror.Error(w, err.Error(), http.StatusNotFound)
+}
+
+func exportChatHandler(w http.ResponseWriter, r *http.Request) {
+	chatID := r.URL.Query().Get("chat_id")
+	if chatID == "" {
+		apierror.Error(w, "chat_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	transcript, err := service.ExportChat(chatID, format)
+	if errors.Is(err, ErrChatNotFound) {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ext := "json"
+	contentType := "application/json"
+	if format == "text" {
+		ext = "txt"
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", chatID+"."+ext))
+	w.Write(transcript)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -258,17 +678,144 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Messaging Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(&service.mu, time.Second))
+
+	// Mutating endpoints act as the user in the bearer token
+	auth := middleware.AuthFromEnv("JWT_SECRET")
+
+	// Cap request bodies and bound request time on create endpoints
+	guard := middleware.LimitRequest(middleware.DefaultMaxBodyBytes, middleware.DefaultRequestTimeout)
+
+	// Attachments get a larger body limit than JSON endpoints
+	attachmentGuard := middleware.LimitRequest(DefaultMaxAttachmentBytes+attachmentFormOverhead, middleware.DefaultRequestTimeout)
+
+	// Retried creates with the same Idempotency-Key replay the first response
+	idempotency := middleware.NewIdempotencyStore(middleware.DefaultIdempotencyTTL)
+	idempotency.StartJanitor(time.Minute)
+	idempotent := middleware.Idempotency(idempotency)
+
+	// State dumps need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	chatQuery := []openapi.Param{{Name: "chat_id", Required: true}}
+	statusResponses := map[int]string{
+		200: "Status updated",
+		400: "Invalid request",
+		401: "Missing or invalid token",
+		404: "Message not found",
+		409: "Status cannot move backwards",
+	}
+
+	api.Handle("/send", auth(guard(idempotent(http.HandlerFunc(sendMessageHandler)))), openapi.Route{
+		Method: http.MethodPost, Summary: "Send a direct message",
+		Request: sendMessageRequest{}, Response: Message{},
+		Responses: map[int]string{
+			200: "Message sent",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			409: "Same Idempotency-Key still in progress",
+			413: "Body too large",
+			422: "Content rejected by the filter, or Idempotency-Key reused with a different body",
+		},
+	})
+	api.Handle("/send-attachment", auth(attachmentGuard(http.HandlerFunc(sendAttachmentHandler))), openapi.Route{
+		Method: http.MethodPost, Summary: "Send a message with a file (multipart/form-data)",
+		Response: Message{},
+		Responses: map[int]string{
+			200: "Message sent",
+			400: "Invalid form",
+			401: "Missing or invalid token",
+			413: "Attachment too large",
+			415: "Attachment content type not allowed",
+			422: "Content rejected by the filter",
+		},
+	})
+	api.HandleFunc("/attachment/", downloadAttachmentHandler, openapi.Route{
+		Path: "/attachment/{id}", Summary: "Download an attachment",
+		Responses: map[int]string{200: "The file", 400: "Missing id", 404: "Attachment not found"},
+	})
+	api.HandleFunc("/messages", getMessagesHandler, openapi.Route{
+		Summary: "List the messages in a chat", Query: chatQuery, Response: []Message{},
+		Responses: map[int]string{200: "The messages", 400: "Missing chat_id", 404: "Chat not found"},
+	})
+	api.HandleFunc("/chats", getUserChatsHandler, openapi.Route{
+		Summary: "List a user's chats", Query: []openapi.Param{{Name: "user_id", Required: true}}, Response: []Chat{},
+		Responses: map[int]string{200: "The chats", 400: "Missing user_id", 404: "User not found"},
+	})
+	api.Handle("/mark-read", auth(http.HandlerFunc(markAsReadHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Mark a message as read",
+		Request: messageStatusRequest{}, Responses: statusResponses,
+	})
+	api.Handle("/mark-delivered", auth(http.HandlerFunc(markDeliveredHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Mark a message as delivered",
+		Request: messageStatusRequest{}, Responses: statusResponses,
+	})
+	api.Handle("/read-cursor", auth(http.HandlerFunc(readCursorHandler)),
+		openapi.Route{
+			Summary:  "Get a user's read cursor and unread count in a chat",
+			Query:    []openapi.Param{{Name: "chat_id", Required: true}, {Name: "user_id", Required: true}},
+			Response: ReadCursor{},
+			Responses: map[int]string{
+				200: "The read cursor",
+				400: "Missing chat_id or user_id",
+				403: "User is not in the chat",
+				404: "Chat not found",
+			},
+		},
+		openapi.Route{
+			Method: http.MethodPost, Summary: "Mark a chat read up to a message, on every device",
+			Request: readCursorRequest{}, Response: ReadCursor{},
+			Responses: map[int]string{
+				200: "The updated read cursor",
+				400: "Invalid request",
+				401: "Missing or invalid token",
+				403: "User is not in the chat",
+				404: "Chat or message not found",
+			},
+		},
+	)
+	api.HandleFunc("/chat/export", exportChatHandler, openapi.Route{
+		Summary: "Export a chat transcript",
+		Query: []openapi.Param{
+			{Name: "chat_id", Required: true},
+			{Name: "format", Description: "json (default) or text"},
+		},
+		Responses: map[int]string{200: "The transcript as an attachment", 400: "Unsupported format", 404: "Chat not found"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(messagingState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	service = NewMessagingService()
-
-	http.HandleFunc("/send", sendMessageHandler)
-	http.HandleFunc("/messages", getMessagesHandler)
-	http.HandleFunc("/chats", getUserChatsHandler)
-	http.HandleFunc("/mark-read", markAsReadHandler)
-	http.HandleFunc("/health", healthHandler)
+	service.SetContentFilter(moderation.FromEnv("BANNED_WORDS"))
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8084"
 	log.Printf("Messaging service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
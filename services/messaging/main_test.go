@@ -8,7 +8,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"common/middleware"
 )
 
 func TestNewMessagingService(t *testing.T) {
@@ -42,8 +46,8 @@ func TestSendMessage(t *testing.T) {
 	if msg.ToUserID != "user2" {
 		t.Errorf("Expected to_user_id 'user2', got %s", msg.ToUserID)
 	}
-	if msg.Read {
-		t.Error("Expected message to not be read")
+	if msg.Status != StatusSent {
+		t.Errorf("Expected status %q, got %q", StatusSent, msg.Status)
 	}
 }
 
@@ -130,7 +134,7 @@ func TestMarkAsRead(t *testing.T) {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 	
-	if !service.messages[msg.ID].Read {
+	if service.messages[msg.ID].Status != StatusRead {
 		t.Error("Expected message to be marked as read")
 	}
 }
@@ -139,8 +143,8 @@ func TestMarkAsRead_NotFound(t *testing.T) {
 	service := NewMessagingService()
 	
 	err := service.MarkAsRead("nonexistent")
-	if err != nil {
-		t.Errorf("Expected no error for non-existent message, got %v", err)
+	if err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
 	}
 }
 
@@ -305,6 +309,20 @@ func TestMarkAsReadHandler(t *testing.T) {
 	}
 }
 
+func TestMarkAsReadHandler_NotFound(t *testing.T) {
+	service = NewMessagingService()
+
+	body, _ := json.Marshal(map[string]interface{}{"message_id": "nonexistent"})
+	req := httptest.NewRequest(http.MethodPost, "/mark-read", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	markAsReadHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
 func TestMarkAsReadHandler_InvalidMethod(t *testing.T) {
 	service = NewMessagingService()
 	
@@ -347,3 +365,271 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("Expected status 'healthy', got %s", resp["status"])
 	}
 }
+
+func TestExportChat_JSON(t *testing.T) {
+	service := NewMessagingService()
+	first, _ := service.SendMessage("user1", "user2", "Hello")
+	service.SendMessage("user2", "user1", "Hi there")
+	service.SendMessage("user1", "user2", "How are you?")
+	service.MarkAsRead(first.ID)
+
+	data, err := service.ExportChat(first.ChatID, "json")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var transcript struct {
+		ChatID   string     `json:"chat_id"`
+		Messages []*Message `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+
+	expected := []string{"Hello", "Hi there", "How are you?"}
+	if len(transcript.Messages) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(transcript.Messages))
+	}
+	for i, msg := range transcript.Messages {
+		if msg.Content != expected[i] {
+			t.Errorf("Message %d: expected %q, got %q", i, expected[i], msg.Content)
+		}
+		if i > 0 && msg.Timestamp.Before(transcript.Messages[i-1].Timestamp) {
+			t.Errorf("Message %d is out of chronological order", i)
+		}
+	}
+	if transcript.Messages[0].Status != StatusRead {
+		t.Error("Expected status to be exported")
+	}
+}
+
+func TestExportChat_Text(t *testing.T) {
+	service := NewMessagingService()
+	first, _ := service.SendMessage("user1", "user2", "Hello")
+	service.SendMessage("user2", "user1", "Hi there")
+
+	data, err := service.ExportChat(first.ChatID, "text")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	transcript := string(data)
+	helloIdx := strings.Index(transcript, "user1 -> user2 (sent): Hello")
+	replyIdx := strings.Index(transcript, "user2 -> user1 (sent): Hi there")
+	if helloIdx < 0 || replyIdx < 0 {
+		t.Fatalf("Expected both messages in transcript, got:\n%s", transcript)
+	}
+	if helloIdx > replyIdx {
+		t.Error("Expected messages in chronological order")
+	}
+}
+
+func TestExportChatHandler_Errors(t *testing.T) {
+	service = NewMessagingService()
+	msg, _ := service.SendMessage("user1", "user2", "Hello")
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"unknown chat", "?chat_id=missing", http.StatusNotFound},
+		{"unsupported format", "?chat_id=" + msg.ChatID + "&format=pdf", http.StatusBadRequest},
+		{"missing chat id", "", http.StatusBadRequest},
+		{"default format", "?chat_id=" + msg.ChatID, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/chat/export"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			exportChatHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestMarkDelivered(t *testing.T) {
+	service := NewMessagingService()
+	msg, _ := service.SendMessage("user1", "user2", "Hello")
+
+	if err := service.MarkDelivered(msg.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if service.messages[msg.ID].Status != StatusDelivered {
+		t.Errorf("Expected status %q, got %q", StatusDelivered, service.messages[msg.ID].Status)
+	}
+
+	if err := service.MarkAsRead(msg.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if service.messages[msg.ID].Status != StatusRead {
+		t.Errorf("Expected status %q, got %q", StatusRead, service.messages[msg.ID].Status)
+	}
+}
+
+func TestMarkDelivered_NotFound(t *testing.T) {
+	service := NewMessagingService()
+
+	if err := service.MarkDelivered("nonexistent"); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestUpdateStatus_RejectsBackwardTransitions(t *testing.T) {
+	service := NewMessagingService()
+	msg, _ := service.SendMessage("user1", "user2", "Hello")
+	service.MarkAsRead(msg.ID)
+
+	for _, status := range []MessageStatus{StatusSent, StatusDelivered} {
+		if err := service.UpdateStatus(msg.ID, status); err != ErrInvalidTransition {
+			t.Errorf("read -> %s: expected ErrInvalidTransition, got %v", status, err)
+		}
+	}
+	if service.messages[msg.ID].Status != StatusRead {
+		t.Errorf("Expected status to stay %q, got %q", StatusRead, service.messages[msg.ID].Status)
+	}
+
+	delivered, _ := service.SendMessage("user1", "user2", "Again")
+	service.MarkDelivered(delivered.ID)
+	if err := service.UpdateStatus(delivered.ID, StatusSent); err != ErrInvalidTransition {
+		t.Errorf("delivered -> sent: expected ErrInvalidTransition, got %v", err)
+	}
+	if err := service.UpdateStatus(delivered.ID, "bogus"); err != ErrInvalidTransition {
+		t.Errorf("Expected unknown status to be rejected, got %v", err)
+	}
+}
+
+func TestMarkDeliveredHandler_AfterRead(t *testing.T) {
+	service = NewMessagingService()
+	msg, _ := service.SendMessage("user1", "user2", "Hello")
+	service.MarkAsRead(msg.ID)
+
+	body, _ := json.Marshal(map[string]string{"message_id": msg.ID})
+	req := httptest.NewRequest(http.MethodPost, "/mark-delivered", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	markDeliveredHandler(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestMarkDeliveredHandler(t *testing.T) {
+	service = NewMessagingService()
+	msg, _ := service.SendMessage("user1", "user2", "Hello")
+
+	body, _ := json.Marshal(map[string]string{"message_id": msg.ID})
+	req := httptest.NewRequest(http.MethodPost, "/mark-delivered", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	markDeliveredHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var resp map[string]string
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["status"] != string(StatusDelivered) {
+		t.Errorf("Expected status %q in response, got %q", StatusDelivered, resp["status"])
+	}
+}
+
+func TestSendMessageHandler_OversizedBody(t *testing.T) {
+	service = NewMessagingService()
+	handler := middleware.LimitRequest(64, time.Second)(http.HandlerFunc(sendMessageHandler))
+
+	body, _ := json.Marshal(map[string]string{
+		"from_user_id": "user1",
+		"to_user_id":   "user2",
+		"content":      strings.Repeat("x", 128),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/send", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+	if len(service.messages) != 0 {
+		t.Errorf("Expected no message to be stored, got %d", len(service.messages))
+	}
+}
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewMessagingService()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
+
+func TestSendMessage_IdempotencyKey(t *testing.T) {
+	service = NewMessagingService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"from_user_id": "user1", "to_user_id": "user2", "content": "Hello"})
+		req := httptest.NewRequest(http.MethodPost, "/send", bytes.NewReader(body))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send()
+	second := send()
+
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 twice, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("Expected identical responses, got %q and %q", first.Body.String(), second.Body.String())
+	}
+
+	var message Message
+	json.NewDecoder(first.Body).Decode(&message)
+	messages, _ := service.GetMessages(message.ChatID)
+	if len(messages) != 1 {
+		t.Errorf("Expected 1 message, got %d", len(messages))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"common/moderation"
+)
+
+func TestSendMessage_ContentFilter(t *testing.T) {
+	service := NewMessagingService()
+	service.SetContentFilter(moderation.NewWordFilter(100, "spam"))
+
+	if _, err := service.SendMessage("user1", "user2", "hello there"); err != nil {
+		t.Fatalf("Expected clean message to be sent, got %v", err)
+	}
+
+	_, err := service.SendMessage("user1", "user2", "buy SPAM today")
+	if !errors.Is(err, moderation.ErrRejected) {
+		t.Fatalf("Expected ErrRejected, got %v", err)
+	}
+	if len(service.messages) != 1 {
+		t.Errorf("Expected the rejected message not to be stored, have %d messages", len(service.messages))
+	}
+}
+
+func TestSendMessage_FilterRunsBeforeStore(t *testing.T) {
+	service := NewMessagingService()
+
+	var storedAtCheck []int
+	service.SetContentFilter(moderation.FilterFunc(func(text string) (bool, string) {
+		storedAtCheck = append(storedAtCheck, len(service.messages))
+		return false, "closed for maintenance"
+	}))
+
+	if _, err := service.SendMessage("user1", "user2", "hi"); err == nil {
+		t.Fatal("Expected the filter to reject the message")
+	}
+	if _, err := service.SendMessageWithAttachment("user1", "user2", "hi", "a.txt", "text/plain", []byte("x")); err == nil {
+		t.Fatal("Expected the filter to reject the attachment message")
+	}
+
+	if len(storedAtCheck) != 2 || storedAtCheck[0] != 0 || storedAtCheck[1] != 0 {
+		t.Errorf("Expected the filter to run once per send with nothing stored, got %v", storedAtCheck)
+	}
+	if len(service.messages) != 0 || len(service.chats) != 0 || len(service.attachments) != 0 {
+		t.Error("Expected nothing to be stored")
+	}
+}
+
+func TestSendMessageHandler_Rejected(t *testing.T) {
+	service = NewMessagingService()
+	service.SetContentFilter(moderation.NewWordFilter(100, "spam"))
+
+	body, _ := json.Marshal(sendMessageRequest{FromUserID: "user1", ToUserID: "user2", Content: "spam"})
+	req := httptest.NewRequest(http.MethodPost, "/send", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	sendMessageHandler(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("banned word")) {
+		t.Errorf("Expected the reason in the response, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"common/apierror"
+	"common/middleware"
+	"common/validate"
+)
+
+// ErrNotChatMember is returned when a user isn't part of a chat
+var ErrNotChatMember = errors.New("user is not a member of the chat")
+
+// ReadCursor is the last message a user has read in a chat, shared by all
+// of the user's devices
+type ReadCursor struct {
+	ChatID    string `json:"chat_id"`
+	UserID    string `json:"user_id"`
+	MessageID string `json:"message_id,omitempty"` // empty until the user reads something
+	Unread    int    `json:"unread"`
+}
+
+// SetReadCursor records that userID has read chatID up to and including
+// upToMessageID, and marks every earlier message sent to the user as read.
+// Cursors only move forward, so a device syncing a stale cursor doesn't
+// bring back messages another device already read.
+func (s *MessagingService) SetReadCursor(chatID, userID, upToMessageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, err := s.memberChatLocked(chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	for i, messageID := range chat.Messages {
+		if messageID == upToMessageID {
+			s.advanceReadCursorLocked(chat, userID, i+1)
+			return nil
+		}
+	}
+	return ErrMessageNotFound
+}
+
+// GetReadCursor returns the last message userID has read in chatID, or ""
+// if they haven't read any
+func (s *MessagingService) GetReadCursor(chatID, userID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, err := s.memberChatLocked(chatID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	read := s.readCursors[chatID][userID]
+	if read == 0 {
+		return "", nil
+	}
+	return chat.Messages[read-1], nil
+}
+
+// GetUnreadCount returns how many messages sent to userID in chatID come
+// after the user's read cursor
+func (s *MessagingService) GetUnreadCount(chatID, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, err := s.memberChatLocked(chatID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	unread := 0
+	for _, messageID := range chat.Messages[s.readCursors[chatID][userID]:] {
+		if message, exists := s.messages[messageID]; exists && message.FromUserID != userID {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// memberChatLocked returns chatID if userID is one of its members
+func (s *MessagingService) memberChatLocked(chatID, userID string) (*Chat, error) {
+	chat, exists := s.chats[chatID]
+	if !exists {
+		return nil, ErrChatNotFound
+	}
+	if !contains(chat.UserIDs, userID) {
+		return nil, ErrNotChatMember
+	}
+	return chat, nil
+}
+
+// advanceReadCursorLocked moves userID's cursor in chat to cover its first
+// read messages, marking the newly covered messages sent to the user as read.
+// A cursor already at or past read is left alone.
+func (s *MessagingService) advanceReadCursorLocked(chat *Chat, userID string, read int) {
+	cursors := s.readCursors[chat.ID]
+	if cursors == nil {
+		cursors = make(map[string]int)
+		s.readCursors[chat.ID] = cursors
+	}
+
+	current := cursors[userID]
+	if read <= current {
+		return
+	}
+
+	for _, messageID := range chat.Messages[current:read] {
+		if message, exists := s.messages[messageID]; exists && message.ToUserID == userID {
+			message.Status = StatusRead
+		}
+	}
+	cursors[userID] = read
+}
+
+// readCursorRequest is the body of POST /read-cursor
+type readCursorRequest struct {
+	ChatID    string `json:"chat_id"`
+	UserID    string `json:"user_id"`
+	MessageID string `json:"message_id"`
+}
+
+func (req readCursorRequest) validate() error {
+	var v validate.Validator
+	v.String("chat_id", req.ChatID, validate.Required)
+	v.String("user_id", req.UserID, validate.Required)
+	v.String("message_id", req.MessageID, validate.Required)
+	return v.Err()
+}
+
+func readCursorHandler(w http.ResponseWriter, r *http.Request) {
+	var chatID, userID string
+
+	switch r.Method {
+	case http.MethodGet:
+		chatID = r.URL.Query().Get("chat_id")
+		userID = middleware.AuthenticatedUserID(r, r.URL.Query().Get("user_id"))
+		if chatID == "" || userID == "" {
+			apierror.Error(w, "chat_id and user_id parameters are required", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		var req readCursorRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+		if err := req.validate(); err != nil {
+			validate.Write(w, err)
+			return
+		}
+
+		if err := service.SetReadCursor(req.ChatID, req.UserID, req.MessageID); err != nil {
+			writeReadCursorError(w, err)
+			return
+		}
+		chatID, userID = req.ChatID, req.UserID
+	default:
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	messageID, err := service.GetReadCursor(chatID, userID)
+	if err != nil {
+		writeReadCursorError(w, err)
+		return
+	}
+	unread, err := service.GetUnreadCount(chatID, userID)
+	if err != nil {
+		writeReadCursorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReadCursor{ChatID: chatID, UserID: userID, MessageID: messageID, Unread: unread})
+}
+
+func writeReadCursorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotChatMember) {
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	apierror.Error(w, err.Error(), http.StatusNotFound)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sendAll sends each content from alice to bob and returns the messages
+func sendAll(t *testing.T, service *MessagingService, contents ...string) []*Message {
+	t.Helper()
+	messages := make([]*Message, 0, len(contents))
+	for _, content := range contents {
+		message, err := service.SendMessage("alice", "bob", content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestSetReadCursor_MarksEarlierMessagesRead(t *testing.T) {
+	service := NewMessagingService()
+	messages := sendAll(t, service, "one", "two", "three", "four")
+	chatID := messages[0].ChatID
+
+	if unread, _ := service.GetUnreadCount(chatID, "bob"); unread != 4 {
+		t.Fatalf("Expected 4 unread before any cursor, got %d", unread)
+	}
+
+	if err := service.SetReadCursor(chatID, "bob", messages[2].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cursor, err := service.GetReadCursor(chatID, "bob")
+	if err != nil || cursor != messages[2].ID {
+		t.Fatalf("Expected cursor %s, got %q (%v)", messages[2].ID, cursor, err)
+	}
+	for i, message := range messages {
+		want := StatusSent
+		if i <= 2 {
+			want = StatusRead
+		}
+		if message.Status != want {
+			t.Errorf("message %d: expected %s, got %s", i, want, message.Status)
+		}
+	}
+	if unread, _ := service.GetUnreadCount(chatID, "bob"); unread != 1 {
+		t.Errorf("Expected 1 unread after the cursor, got %d", unread)
+	}
+
+	// The sender's own messages never count as unread
+	if unread, _ := service.GetUnreadCount(chatID, "alice"); unread != 0 {
+		t.Errorf("Expected no unread messages for the sender, got %d", unread)
+	}
+}
+
+func TestSetReadCursor_OnlyMovesForward(t *testing.T) {
+	service := NewMessagingService()
+	messages := sendAll(t, service, "one", "two", "three")
+	chatID := messages[0].ChatID
+
+	service.SetReadCursor(chatID, "bob", messages[2].ID)
+	if err := service.SetReadCursor(chatID, "bob", messages[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if cursor, _ := service.GetReadCursor(chatID, "bob"); cursor != messages[2].ID {
+		t.Errorf("Expected a stale cursor to be ignored, got %s", cursor)
+	}
+	if unread, _ := service.GetUnreadCount(chatID, "bob"); unread != 0 {
+		t.Errorf("Expected 0 unread, got %d", unread)
+	}
+}
+
+func TestSetReadCursor_Errors(t *testing.T) {
+	service := NewMessagingService()
+	messages := sendAll(t, service, "one")
+	chatID := messages[0].ChatID
+
+	tests := []struct {
+		chatID, userID, messageID string
+		want                      error
+	}{
+		{"missing", "bob", messages[0].ID, ErrChatNotFound},
+		{chatID, "mallory", messages[0].ID, ErrNotChatMember},
+		{chatID, "bob", "msg_missing", ErrMessageNotFound},
+	}
+	for _, tt := range tests {
+		if err := service.SetReadCursor(tt.chatID, tt.userID, tt.messageID); !errors.Is(err, tt.want) {
+			t.Errorf("SetReadCursor(%s, %s, %s): expected %v, got %v", tt.chatID, tt.userID, tt.messageID, tt.want, err)
+		}
+	}
+}
+
+func TestMarkAsRead_AdvancesReadCursor(t *testing.T) {
+	service := NewMessagingService()
+	messages := sendAll(t, service, "one", "two", "three")
+	chatID := messages[0].ChatID
+
+	if err := service.MarkAsRead(messages[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if cursor, _ := service.GetReadCursor(chatID, "bob"); cursor != messages[1].ID {
+		t.Errorf("Expected cursor %s, got %s", messages[1].ID, cursor)
+	}
+	if unread, _ := service.GetUnreadCount(chatID, "bob"); unread != 1 {
+		t.Errorf("Expected 1 unread, got %d", unread)
+	}
+}
+
+func TestReadCursor_SurvivesSnapshot(t *testing.T) {
+	service := NewMessagingService()
+	messages := sendAll(t, service, "one", "two")
+	chatID := messages[0].ChatID
+	service.SetReadCursor(chatID, "bob", messages[0].ID)
+
+	data, err := service.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewMessagingService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if cursor, _ := restored.GetReadCursor(chatID, "bob"); cursor != messages[0].ID {
+		t.Errorf("Expected cursor %s after restore, got %s", messages[0].ID, cursor)
+	}
+}
+
+func TestReadCursorHandler(t *testing.T) {
+	service = NewMessagingService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+	messages := sendAll(t, service, "one", "two", "three")
+	chatID := messages[0].ChatID
+
+	body, _ := json.Marshal(readCursorRequest{ChatID: chatID, UserID: "bob", MessageID: messages[1].ID})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/read-cursor", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/read-cursor?chat_id="+chatID+"&user_id=bob", nil))
+	var cursor ReadCursor
+	json.NewDecoder(w.Body).Decode(&cursor)
+	if cursor.MessageID != messages[1].ID || cursor.Unread != 1 {
+		t.Errorf("Expected cursor at %s with 1 unread, got %+v", messages[1].ID, cursor)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/read-cursor?chat_id="+chatID+"&user_id=mallory", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-member, got %d", w.Code)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newWideFeedFixture has user "reader" follow n users with a post each
+func newWideFeedFixture(n int) *NewsfeedService {
+	service := NewNewsfeedService()
+	service.CreateUser("reader", "reader")
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("author%d", i)
+		service.CreateUser(id, id)
+		service.Follow("reader", id)
+		service.CreatePost(id, "hello from "+id)
+	}
+	return service
+}
+
+func TestGetNewsfeed_Cancelled(t *testing.T) {
+	service := newWideFeedFixture(500)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := service.GetNewsfeed(ctx, "reader", 50); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected a cancelled feed to return promptly, took %v", elapsed)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := service.GetRankedNewsfeed(ctx, "reader", 50, SortTop); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if feed, err := service.GetNewsfeed(context.Background(), "reader", 50); err != nil || len(feed) != 50 {
+		t.Errorf("Expected 50 posts with a live context, got %d (%v)", len(feed), err)
+	}
+}
+
+func TestGetNewsfeedHandler_Cancelled(t *testing.T) {
+	service = newWideFeedFixture(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/feed?user_id=reader", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	getNewsfeedHandler(w, req)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, got %d", w.Code)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrEngagementBacklog is returned when the engagement queue stays full for
+// longer than the enqueue timeout
+var ErrEngagementBacklog = errors.New("engagement queue is full")
+
+// EngagementConfig sizes the worker pool that applies likes, comments and
+// shares in the background
+type EngagementConfig struct {
+	Workers        int           // goroutines applying updates
+	QueueSize      int           // updates buffered before callers wait
+	BatchSize      int           // updates applied per write lock
+	EnqueueTimeout time.Duration // how long a caller waits for room in a full queue
+}
+
+// DefaultEngagementConfig returns a small pool suited to a single instance
+func DefaultEngagementConfig() EngagementConfig {
+	return EngagementConfig{
+		Workers:        4,
+		QueueSize:      1024,
+		BatchSize:      64,
+		EnqueueTimeout: 100 * time.Millisecond,
+	}
+}
+
+// engagementKind is the counter an engagement increments
+type engagementKind int
+
+const (
+	engageLike engagementKind = iota
+	engageComment
+	engageShare
+)
+
+// engagement is one queued counter increment
+type engagement struct {
+	postID  string
+	actorID string // who engaged, for the author's notification; may be empty
+	kind    engagementKind
+}
+
+// engagementQueue feeds increments to the workers. mu is held for reading
+// while sending so drain can't close the channel under a sender.
+type engagementQueue struct {
+	mu      sync.RWMutex
+	closed  bool
+	ch      chan engagement
+	workers sync.WaitGroup
+	config  EngagementConfig
+}
+
+// StartEngagementWorkers makes LikePost, CommentPost and SharePost queue
+// their increments for a pool of workers instead of taking the write lock
+// for each one. Workers apply queued increments in batches, so counts read
+// back are eventually consistent. Call DrainEngagement before shutting down
+// so queued increments aren't lost.
+func (s *NewsfeedService) StartEngagementWorkers(config EngagementConfig) {
+	defaults := DefaultEngagementConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+
+	q := &engagementQueue{ch: make(chan engagement, config.QueueSize), config: config}
+	for i := 0; i < config.Workers; i++ {
+		q.workers.Add(1)
+		go s.applyEngagements(q)
+	}
+
+	if old := s.engagement.Swap(q); old != nil {
+		old.drain()
+	}
+}
+
+// DrainEngagement stops queueing increments, waits for the workers to apply
+// everything already queued and returns the service to synchronous updates
+func (s *NewsfeedService) DrainEngagement() {
+	if q := s.engagement.Swap(nil); q != nil {
+		q.drain()
+	}
+}
+
+// drain closes the queue and waits for the workers to finish it
+func (q *engagementQueue) drain() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	q.mu.Unlock()
+	q.workers.Wait()
+}
+
+// enqueue queues e, waiting up to the enqueue timeout for room. It reports
+// false if the queue has been drained, in which case the caller applies the
+// increment itself.
+func (q *engagementQueue) enqueue(e engagement) (bool, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return false, nil
+	}
+
+	select {
+	case q.ch <- e:
+		return true, nil
+	default:
+	}
+
+	timer := time.NewTimer(q.config.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case q.ch <- e:
+		return true, nil
+	case <-timer.C:
+		return true, ErrEngagementBacklog
+	}
+}
+
+// applyEngagements is a worker: it takes whatever is queued, up to the batch
+// size, and applies it under one write lock
+func (s *NewsfeedService) applyEngagements(q *engagementQueue) {
+	defer q.workers.Done()
+
+	batch := make([]engagement, 0, q.config.BatchSize)
+	for e := range q.ch {
+		batch = append(batch[:0], e)
+	fill:
+		for len(batch) < q.config.BatchSize {
+			select {
+			case e, ok := <-q.ch:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+
+		s.mu.Lock()
+		for _, e := range batch {
+			// A post purged since it was queued has nothing to count
+			if post, exists := s.posts[e.postID]; exists {
+				incrementEngagement(post, e.kind)
+				s.notifyEngagementLocked(post, e)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// engage increments a live post's counter and notifies its author, through
+// the worker pool when it is running. An empty actorID engages anonymously.
+func (s *NewsfeedService) engage(postID, actorID string, kind engagementKind) error {
+	e := engagement{postID: postID, actorID: actorID, kind: kind}
+
+	if q := s.engagement.Load(); q != nil {
+		s.mu.RLock()
+		err := s.checkEngagementLocked(e)
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		if queued, err := q.enqueue(e); queued {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkEngagementLocked(e); err != nil {
+		return err
+	}
+
+	post := s.posts[postID]
+	incrementEngagement(post, kind)
+	s.notifyEngagementLocked(post, e)
+	return nil
+}
+
+// checkEngagementLocked checks that the post is live and the actor, if
+// any, exists. Must be called with s.mu held.
+func (s *NewsfeedService) checkEngagementLocked(e engagement) error {
+	if _, exists := s.livePost(e.postID); !exists {
+		return ErrPostNotFound
+	}
+	if _, exists := s.users[e.actorID]; e.actorID != "" && !exists {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func incrementEngagement(post *Post, kind engagementKind) {
+	switch kind {
+	case engageLike:
+		post.Likes++
+	case engageComment:
+		post.Comments++
+	case engageShare:
+		post.Shares++
+	}
+}
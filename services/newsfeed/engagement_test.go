@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEngagementWorkers_NoLostUpdates(t *testing.T) {
+	s := NewNewsfeedService()
+	s.CreateUser("author", "author")
+	post, _ := s.CreatePost("author", "hello")
+
+	s.StartEngagementWorkers(EngagementConfig{Workers: 4, QueueSize: 16, BatchSize: 8, EnqueueTimeout: time.Second})
+
+	const goroutines, perGoroutine = 20, 250
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				if err := s.LikePost(post.ID); err != nil {
+					t.Error(err)
+					return
+				}
+				s.CommentPost(post.ID)
+				s.SharePost(post.ID)
+			}
+		}()
+	}
+
+	// Reads while workers are applying updates see some consistent count
+	for i := 0; i < 10; i++ {
+		if got, _ := s.GetPost(post.ID); got == nil {
+			t.Fatal("Expected the post to stay readable")
+		}
+	}
+
+	wg.Wait()
+	s.DrainEngagement()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	const want = goroutines * perGoroutine
+	if post.Likes != want || post.Comments != want || post.Shares != want {
+		t.Errorf("Expected %d of each, got likes=%d comments=%d shares=%d", want, post.Likes, post.Comments, post.Shares)
+	}
+}
+
+func TestEngagementWorkers_Backpressure(t *testing.T) {
+	s := NewNewsfeedService()
+	s.CreateUser("author", "author")
+	post, _ := s.CreatePost("author", "hello")
+
+	// A queue with no workers fills up after one like
+	s.engagement.Store(&engagementQueue{
+		ch:     make(chan engagement, 1),
+		config: EngagementConfig{EnqueueTimeout: time.Millisecond},
+	})
+
+	if err := s.LikePost(post.ID); err != nil {
+		t.Fatalf("Expected the first like to be queued, got %v", err)
+	}
+	backlog := s.LikePost(post.ID)
+
+	if !errors.Is(backlog, ErrEngagementBacklog) {
+		t.Errorf("Expected ErrEngagementBacklog once the queue filled, got %v", backlog)
+	}
+	s.DrainEngagement()
+}
+
+func TestEngagementWorkers_DrainReturnsToSyncUpdates(t *testing.T) {
+	s := NewNewsfeedService()
+	s.CreateUser("author", "author")
+	post, _ := s.CreatePost("author", "hello")
+
+	s.StartEngagementWorkers(DefaultEngagementConfig())
+	if err := s.LikePost("post_missing"); err != ErrPostNotFound {
+		t.Errorf("Expected ErrPostNotFound for a missing post, got %v", err)
+	}
+	s.DrainEngagement()
+
+	s.LikePost(post.ID)
+	if post.Likes != 1 {
+		t.Errorf("Expected the like to apply immediately after draining, got %d", post.Likes)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerErrors_StatusCodes(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		target     string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"follow unknown user", followHandler, "/user/follow", `{"follower_id":"user1","followee_id":"ghost"}`, http.StatusNotFound, "not_found"},
+		{"unfollow without following", unfollowHandler, "/user/unfollow", `{"follower_id":"user1","followee_id":"user1"}`, http.StatusBadRequest, "bad_request"},
+		{"block unknown user", blockHandler, "/user/block", `{"user_id":"user1","blocked_id":"ghost"}`, http.StatusNotFound, "not_found"},
+		{"post as unknown user", createPostHandler, "/post/create", `{"user_id":"ghost","content":"hi"}`, http.StatusNotFound, "not_found"},
+		{"malformed body", createPostHandler, "/post/create", `{`, http.StatusBadRequest, "bad_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			var body struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("Expected a JSON error body: %v", err)
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Message == "" {
+				t.Errorf("Expected code %s with a message, got %+v", tt.wantCode, body.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeletePost_RemovesFromEveryIndex(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("author", "author")
+	service.CreateUser("reader", "reader")
+	service.Follow("reader", "author")
+	kept, _ := service.CreatePost("author", "Kept")
+	deleted, _ := service.CreatePost("author", "Deleted")
+
+	if err := service.DeletePost(deleted.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if service.userPosts.Has("author", deleted.ID) {
+		t.Errorf("Expected the post out of the author index, got %v", service.userPosts.Get("author"))
+	}
+	if !service.userPosts.Has("author", kept.ID) {
+		t.Error("Expected the other post to stay indexed")
+	}
+	feed, _ := service.GetNewsfeed(context.Background(), "reader", 0)
+	if len(feed) != 1 || feed[0].ID != kept.ID {
+		t.Errorf("Expected only the kept post in the feed, got %v", feed)
+	}
+
+	// Restoring puts it back, and snapshots keep soft-deleted posts
+	if _, err := service.RestorePost(deleted.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !service.userPosts.Has("author", deleted.ID) {
+		t.Error("Expected the restored post back in the author index")
+	}
+
+	service.DeletePost(deleted.ID)
+	data, _ := service.Snapshot()
+	restored := NewNewsfeedService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, exists := restored.posts[deleted.ID]; !exists {
+		t.Error("Expected the soft-deleted post in the snapshot")
+	}
+	if got := restored.userPosts.Get("author"); len(got) != 1 || got[0] != kept.ID {
+		t.Errorf("Expected only the live post indexed after restore, got %v", got)
+	}
+	if _, err := restored.RestorePost(deleted.ID); err != nil || restored.userPosts.Len("author") != 2 {
+		t.Errorf("Expected the post restorable after a snapshot round trip, got %v", err)
+	}
+}
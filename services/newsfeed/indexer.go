@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"common/events"
+)
+
+// PostIndexer builds a word -> post ID index from post.created events
+type PostIndexer struct {
+	mu    sync.RWMutex
+	index map[string]map[string]bool
+}
+
+// NewPostIndexer creates a new post indexer
+func NewPostIndexer() *PostIndexer {
+	return &PostIndexer{
+		index: make(map[string]map[string]bool),
+	}
+}
+
+// Run indexes posts from the channel until it is closed
+func (ix *PostIndexer) Run(ch <-chan events.Event) {
+	for event := range ch {
+		post, ok := event.Payload.(*Post)
+		if !ok {
+			continue
+		}
+		ix.Add(post)
+	}
+}
+
+// Add indexes a single post
+func (ix *PostIndexer) Add(post *Post) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	for _, word := range strings.Fields(strings.ToLower(post.Content)) {
+		if ix.index[word] == nil {
+			ix.index[word] = make(map[string]bool)
+		}
+		ix.index[word][post.ID] = true
+	}
+}
+
+// Search returns the IDs of posts containing the word
+func (ix *PostIndexer) Search(word string) []string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	postIDs := make([]string, 0, len(ix.index[strings.ToLower(word)]))
+	for postID := range ix.index[strings.ToLower(word)] {
+		postIDs = append(postIDs, postID)
+	}
+	sort.Strings(postIDs)
+	return postIDs
+}
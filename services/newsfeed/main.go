@@ -1,13 +1,109 @@
 package main
 
 import (
+	"container/heap"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/events"
+	"common/health"
+	"common/index"
+	"common/metrics"
+	"common/middleware"
+	"common/moderation"
+	"common/openapi"
+	"common/validate"
+)
+
+// TopicPostCreated is published with a *Post whenever a post is created
+const TopicPostCreated = "post.created"
+
+// DefaultDeleteGrace is how long a soft-deleted post can be restored before
+// the reaper purges it
+const DefaultDeleteGrace = 24 * time.Hour
+
+// maxUsernameLength caps usernames at creation
+const maxUsernameLength = 50
+
+// maxReactionLength caps a reaction in bytes; enough for an emoji with
+// modifiers and joiners
+const maxReactionLength = 32
+
+// postsCreated is exposed at /metrics
+var postsCreated = metrics.NewCounter("newsfeed_posts_created_total", "Posts created, including scheduled ones")
+
+var (
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrPostNotFound is returned when a post does not exist or is hidden
+	ErrPostNotFound = errors.New("post not found")
+	// ErrPostNotDeleted is returned when restoring a post that is live
+	ErrPostNotDeleted = errors.New("post is not deleted")
+	// ErrRestoreWindowExpired is returned when the grace window has passed
+	ErrRestoreWindowExpired = errors.New("restore window expired")
+	// ErrPublishTimeInPast is returned when scheduling a post for a time
+	// that has already passed
+	ErrPublishTimeInPast = errors.New("publish time must be in the future")
+	// ErrPostNotScheduled is returned when cancelling a post that is not
+	// waiting to be published
+	ErrPostNotScheduled = errors.New("post is not scheduled")
+	// ErrEmptyReaction is returned when reacting without an emoji
+	ErrEmptyReaction = errors.New("reaction is required")
+	// ErrNotAuthor is returned when someone other than a post's author
+	// tries to change it
+	ErrNotAuthor = errors.New("only the author can change this post")
+)
+
+// errorStatus returns 404 for errors about missing users or posts and
+// fallback for anything else
+func errorStatus(err error, fallback int) int {
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrPostNotFound) {
+		return http.StatusNotFound
+	}
+	return fallback
+}
+
+// ErrUnknownSort is returned for a feed sort mode with no ranker
+var ErrUnknownSort = errors.New("unknown sort mode")
+
+// Feed sort modes accepted by GetRankedNewsfeed
+const (
+	SortRecent = "recent"
+	SortTop    = "top"
+	SortHybrid = "hybrid"
+)
+
+// DefaultHybridHalfLife is how long it takes a post's hybrid score to halve
+const DefaultHybridHalfLife = 6 * time.Hour
+
+// Page sizes for follower and following lists
+const (
+	defaultFollowPageLimit = 50
+	maxFollowPageLimit     = 500
+)
+
+// Page sizes for the discover feed
+const (
+	defaultDiscoverLimit = 20
+	maxDiscoverLimit     = 100
 )
 
 // Post represents a social media post
@@ -19,6 +115,11 @@ type Post struct {
 	Likes     int64     `json:"likes"`
 	Comments  int64     `json:"comments"`
 	Shares    int64     `json:"shares"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
+	Scheduled bool      `json:"scheduled,omitempty"` // waiting for PublishAt
+	PublishAt time.Time `json:"publish_at,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"` // Content was cut to the length limit
 }
 
 // User represents a user in the system
@@ -27,6 +128,7 @@ type User struct {
 	Username  string   `json:"username"`
 	Following []string `json:"following"`
 	Followers []string `json:"followers"`
+	Blocked   []string `json:"blocked,omitempty"` // users whose posts are hidden from discovery
 }
 
 // NewsfeedService manages posts and user relationships
@@ -34,20 +136,112 @@ type NewsfeedService struct {
 	mu        sync.RWMutex
 	posts     map[string]*Post
 	users     map[string]*User
-	userPosts map[string][]string // userID -> []postID
+	userPosts WriteStore                   // userID -> live postIDs; every write goes here
+	readPosts ReadStore                    // where feeds and timelines read userPosts from
+	reactions map[string]map[string]string // postID -> userID -> emoji
 	postIndex int64
+	events    *events.EventBus
+
+	deleteGrace time.Duration // how long soft-deleted posts can be restored
+	rankers     map[string]Ranker
+	now         func() time.Time
+
+	rngMu sync.Mutex // rand.Rand is not safe for concurrent use
+	rng   *rand.Rand // draws the discover feed sample
+
+	filter     moderation.ContentFilter // checks post content before it is stored
+	postLimits PostLimits
+
+	notifications     map[string][]*Notification // recipient userID -> notifications, oldest first
+	notificationIndex int64
+
+	engagement atomic.Pointer[engagementQueue] // queues likes, comments and shares; nil applies them inline
 }
 
-// NewNewsfeedService creates a new newsfeed service
+// NewNewsfeedService creates a new newsfeed service that reads and writes
+// one in-memory store
 func NewNewsfeedService() *NewsfeedService {
+	store := index.New[string]()
+	return NewNewsfeedServiceWithStores(store, store)
+}
+
+// NewNewsfeedServiceWithStores creates a newsfeed service that writes post
+// lists to write and serves feeds and timelines from read, which may lag
+// behind it
+func NewNewsfeedServiceWithStores(read ReadStore, write WriteStore) *NewsfeedService {
 	return &NewsfeedService{
 		posts:     make(map[string]*Post),
 		users:     make(map[string]*User),
-		userPosts: make(map[string][]string),
+		userPosts: write,
+		readPosts: read,
+		reactions: make(map[string]map[string]string),
 		postIndex: 0,
+		events:    events.NewEventBus(events.DefaultBufferSize),
+
+		deleteGrace: DefaultDeleteGrace,
+		rankers: map[string]Ranker{
+			SortRecent: RankRecent,
+			SortTop:    RankTop,
+			SortHybrid: HybridRanker(DefaultHybridHalfLife),
+		},
+		now:    time.Now,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		filter: moderation.NewWordFilter(moderation.DefaultMaxLength),
+
+		postLimits: DefaultPostLimits(),
+
+		notifications: make(map[string][]*Notification),
 	}
 }
 
+// SetDeleteGrace sets how long soft-deleted posts stay restorable
+func (s *NewsfeedService) SetDeleteGrace(grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteGrace = grace
+}
+
+// SetContentFilter replaces the filter new posts are checked against
+func (s *NewsfeedService) SetContentFilter(filter moderation.ContentFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = filter
+}
+
+// checkContent runs the content filter outside s.mu, so a slow or
+// re-entrant filter doesn't hold up the service
+func (s *NewsfeedService) checkContent(texts ...string) error {
+	s.mu.RLock()
+	filter := s.filter
+	s.mu.RUnlock()
+	return moderation.Check(filter, texts...)
+}
+
+// SetRanker registers ranker under a sort mode, replacing any existing one
+func (s *NewsfeedService) SetRanker(mode string, ranker Ranker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rankers[mode] = ranker
+}
+
+// SetHybridHalfLife sets the recency decay of the hybrid sort mode
+func (s *NewsfeedService) SetHybridHalfLife(halfLife time.Duration) {
+	s.SetRanker(SortHybrid, HybridRanker(halfLife))
+}
+
+// SetRandSource replaces the source the discover feed samples from, so
+// tests can fix the seed
+func (s *NewsfeedService) SetRandSource(src rand.Source) {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	s.rng = rand.New(src)
+}
+
+// Events returns the bus the service publishes domain events on
+func (s *NewsfeedService) Events() *events.EventBus {
+	return s.events
+}
+
 // CreateUser creates a new user
 func (s *NewsfeedService) CreateUser(userID, username string) (*User, error) {
 	s.mu.Lock()
@@ -65,7 +259,6 @@ func (s *NewsfeedService) CreateUser(userID, username string) (*User, error) {
 	}
 
 	s.users[userID] = user
-	s.userPosts[userID] = []string{}
 
 	return user, nil
 }
@@ -77,7 +270,7 @@ func (s *NewsfeedService) GetUser(userID string) (*User, error) {
 
 	user, exists := s.users[userID]
 	if !exists {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	return user, nil
@@ -87,15 +280,21 @@ func (s *NewsfeedService) GetUser(userID string) (*User, error) {
 func (s *NewsfeedService) Follow(followerID, followeeID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.followLocked(followerID, followeeID)
+}
 
+// followLocked validates the pair before touching either slice so
+// Following and Followers always change together. Must be called with
+// s.mu held.
+func (s *NewsfeedService) followLocked(followerID, followeeID string) error {
 	follower, exists := s.users[followerID]
 	if !exists {
-		return fmt.Errorf("follower not found")
+		return fmt.Errorf("follower %w", ErrUserNotFound)
 	}
 
 	followee, exists := s.users[followeeID]
 	if !exists {
-		return fmt.Errorf("followee not found")
+		return fmt.Errorf("followee %w", ErrUserNotFound)
 	}
 
 	// Check if already following
@@ -115,15 +314,20 @@ func (s *NewsfeedService) Follow(followerID, followeeID string) error {
 func (s *NewsfeedService) Unfollow(followerID, followeeID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.unfollowLocked(followerID, followeeID)
+}
 
+// unfollowLocked removes the pair from both slices. Must be called with
+// s.mu held.
+func (s *NewsfeedService) unfollowLocked(followerID, followeeID string) error {
 	follower, exists := s.users[followerID]
 	if !exists {
-		return fmt.Errorf("follower not found")
+		return fmt.Errorf("follower %w", ErrUserNotFound)
 	}
 
 	followee, exists := s.users[followeeID]
 	if !exists {
-		return fmt.Errorf("followee not found")
+		return fmt.Errorf("followee %w", ErrUserNotFound)
 	}
 
 	// Remove from following list
@@ -155,13 +359,159 @@ func (s *NewsfeedService) Unfollow(followerID, followeeID string) error {
 	return nil
 }
 
+// Block hides blockedID's posts from userID's discover feed
+func (s *NewsfeedService) Block(userID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if _, exists := s.users[blockedID]; !exists {
+		return fmt.Errorf("blocked %w", ErrUserNotFound)
+	}
+	if userID == blockedID {
+		return fmt.Errorf("cannot block yourself")
+	}
+
+	for _, id := range user.Blocked {
+		if id == blockedID {
+			return fmt.Errorf("already blocked")
+		}
+	}
+
+	user.Blocked = append(user.Blocked, blockedID)
+	return nil
+}
+
+// Unblock lets blockedID's posts back into userID's discover feed
+func (s *NewsfeedService) Unblock(userID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	newBlocked := []string{}
+	found := false
+	for _, id := range user.Blocked {
+		if id != blockedID {
+			newBlocked = append(newBlocked, id)
+		} else {
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("not blocked")
+	}
+
+	user.Blocked = newBlocked
+	return nil
+}
+
+// FollowMany makes followerID follow each of followeeIDs. Every id is
+// applied on its own, so an unknown, duplicate or self id lands in failed
+// without aborting the rest. The whole batch runs under one lock.
+func (s *NewsfeedService) FollowMany(followerID string, followeeIDs []string) (succeeded, failed []string) {
+	return s.applyMany(followerID, followeeIDs, s.followLocked)
+}
+
+// UnfollowMany makes followerID unfollow each of followeeIDs, reporting
+// per-id results like FollowMany
+func (s *NewsfeedService) UnfollowMany(followerID string, followeeIDs []string) (succeeded, failed []string) {
+	return s.applyMany(followerID, followeeIDs, s.unfollowLocked)
+}
+
+func (s *NewsfeedService) applyMany(followerID string, followeeIDs []string, apply func(followerID, followeeID string) error) (succeeded, failed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	succeeded = []string{}
+	failed = []string{}
+	for _, followeeID := range followeeIDs {
+		if followeeID == "" || followeeID == followerID {
+			failed = append(failed, followeeID)
+			continue
+		}
+		if err := apply(followerID, followeeID); err != nil {
+			failed = append(failed, followeeID)
+			continue
+		}
+		succeeded = append(succeeded, followeeID)
+	}
+
+	return succeeded, failed
+}
+
+// FollowPage is one page of a follower or following list
+type FollowPage struct {
+	UserIDs []string `json:"user_ids"`
+	Total   int      `json:"total"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+}
+
+// GetFollowers returns a page of the users following userID
+func (s *NewsfeedService) GetFollowers(userID string, offset, limit int) (*FollowPage, error) {
+	return s.followPage(userID, offset, limit, func(user *User) []string { return user.Followers })
+}
+
+// GetFollowing returns a page of the users userID follows
+func (s *NewsfeedService) GetFollowing(userID string, offset, limit int) (*FollowPage, error) {
+	return s.followPage(userID, offset, limit, func(user *User) []string { return user.Following })
+}
+
+// followPage copies a window of the list so callers never share the
+// user's backing array. A non-positive limit uses defaultFollowPageLimit
+// and limits are capped at maxFollowPageLimit.
+func (s *NewsfeedService) followPage(userID string, offset, limit int, list func(*User) []string) (*FollowPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = defaultFollowPageLimit
+	}
+	if limit > maxFollowPageLimit {
+		limit = maxFollowPageLimit
+	}
+
+	ids := list(user)
+	page := &FollowPage{UserIDs: []string{}, Total: len(ids), Offset: offset, Limit: limit}
+	if offset < len(ids) {
+		end := offset + limit
+		if end > len(ids) {
+			end = len(ids)
+		}
+		page.UserIDs = append(page.UserIDs, ids[offset:end]...)
+	}
+
+	return page, nil
+}
+
 // CreatePost creates a new post
 func (s *NewsfeedService) CreatePost(userID, content string) (*Post, error) {
+	content, truncated, err := s.prepareContent(content)
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.users[userID]; !exists {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	s.postIndex++
@@ -175,67 +525,226 @@ func (s *NewsfeedService) CreatePost(userID, content string) (*Post, error) {
 		Likes:     0,
 		Comments:  0,
 		Shares:    0,
+		Truncated: truncated,
+	}
+
+	s.posts[postID] = post
+	s.userPosts.Add(userID, postID)
+	s.publishCreatedLocked(post)
+	postsCreated.Inc()
+
+	return post, nil
+}
+
+// publishCreatedLocked announces a post that just became visible and
+// notifies the author's followers. Must be called with s.mu held.
+func (s *NewsfeedService) publishCreatedLocked(post *Post) {
+	// Publish a copy so subscribers don't race with later counter updates
+	published := *post
+	s.events.Publish(TopicPostCreated, &published)
+	s.notifyFollowersLocked(post)
+}
+
+// SchedulePost stores a post that stays out of feeds and lookups until
+// publishAt, when the scheduler started by StartScheduler publishes it
+func (s *NewsfeedService) SchedulePost(userID, content string, publishAt time.Time) (*Post, error) {
+	content, truncated, err := s.prepareContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[userID]; !exists {
+		return nil, ErrUserNotFound
+	}
+	if !publishAt.After(s.now()) {
+		return nil, ErrPublishTimeInPast
+	}
+
+	s.postIndex++
+	postID := fmt.Sprintf("post_%d", s.postIndex)
+
+	post := &Post{
+		ID:        postID,
+		UserID:    userID,
+		Content:   content,
+		Timestamp: publishAt,
+		Scheduled: true,
+		PublishAt: publishAt,
+		Truncated: truncated,
 	}
 
 	s.posts[postID] = post
-	s.userPosts[userID] = append(s.userPosts[userID], postID)
+	s.userPosts.Add(userID, postID)
+	postsCreated.Inc()
 
 	return post, nil
 }
 
+// CancelScheduledPost removes userID's post that has not been published
+// yet
+func (s *NewsfeedService) CancelScheduledPost(postID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, exists := s.posts[postID]
+	if !exists || post.Deleted {
+		return ErrPostNotFound
+	}
+	if post.UserID != userID {
+		return ErrNotAuthor
+	}
+	if !post.Scheduled {
+		return ErrPostNotScheduled
+	}
+
+	s.purgePostLocked(post)
+	delete(s.posts, postID)
+
+	return nil
+}
+
+// PublishDuePosts publishes every scheduled post whose publish time has
+// arrived and returns how many were published
+func (s *NewsfeedService) PublishDuePosts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	published := 0
+	for _, post := range s.posts {
+		if post.Scheduled && !post.PublishAt.After(now) {
+			post.Scheduled = false
+			s.publishCreatedLocked(post)
+			published++
+		}
+	}
+
+	return published
+}
+
+// StartScheduler publishes due scheduled posts every interval. A post goes
+// live up to one interval after its publish time.
+func (s *NewsfeedService) StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if published := s.PublishDuePosts(); published > 0 {
+				log.Printf("Published %d scheduled posts", published)
+			}
+		}
+	}()
+}
+
 // GetPost retrieves a post by ID
 func (s *NewsfeedService) GetPost(postID string) (*Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	post, exists := s.posts[postID]
+	post, exists := s.livePost(postID)
 	if !exists {
-		return nil, fmt.Errorf("post not found")
+		return nil, ErrPostNotFound
 	}
 
 	return post, nil
 }
 
+// livePost looks up a post that is published and not soft-deleted. Must be
+// called with s.mu held.
+func (s *NewsfeedService) livePost(postID string) (*Post, bool) {
+	post, exists := s.posts[postID]
+	if !exists || post.Deleted || post.Scheduled {
+		return nil, false
+	}
+	return post, true
+}
+
 // LikePost increments the like count for a post
 func (s *NewsfeedService) LikePost(postID string) error {
+	return s.LikePostBy(postID, "")
+}
+
+// LikePostBy increments the like count for a post and notifies its author
+// that userID liked it. An empty userID likes anonymously.
+func (s *NewsfeedService) LikePostBy(postID, userID string) error {
+	return s.engage(postID, userID, engageLike)
+}
+
+// AddReaction sets userID's reaction to a post. Each user has at most one
+// reaction per post, so reacting again replaces the earlier one.
+func (s *NewsfeedService) AddReaction(postID, userID, emoji string) error {
+	if emoji == "" {
+		return ErrEmptyReaction
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	post, exists := s.posts[postID]
-	if !exists {
-		return fmt.Errorf("post not found")
+	if _, exists := s.livePost(postID); !exists {
+		return ErrPostNotFound
+	}
+	if _, exists := s.users[userID]; !exists {
+		return ErrUserNotFound
 	}
 
-	post.Likes++
+	reactions := s.reactions[postID]
+	if reactions == nil {
+		reactions = make(map[string]string)
+		s.reactions[postID] = reactions
+	}
+	reactions[userID] = emoji
 	return nil
 }
 
-// CommentPost increments the comment count for a post
-func (s *NewsfeedService) CommentPost(postID string) error {
+// RemoveReaction clears userID's reaction to a post. Removing a reaction
+// that isn't there is a no-op.
+func (s *NewsfeedService) RemoveReaction(postID, userID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	post, exists := s.posts[postID]
-	if !exists {
-		return fmt.Errorf("post not found")
+	if _, exists := s.livePost(postID); !exists {
+		return ErrPostNotFound
 	}
 
-	post.Comments++
+	delete(s.reactions[postID], userID)
+	if len(s.reactions[postID]) == 0 {
+		delete(s.reactions, postID)
+	}
 	return nil
 }
 
-// SharePost increments the share count for a post
-func (s *NewsfeedService) SharePost(postID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetReactions counts a post's reactions by emoji
+func (s *NewsfeedService) GetReactions(postID string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	post, exists := s.posts[postID]
-	if !exists {
-		return fmt.Errorf("post not found")
+	if _, exists := s.livePost(postID); !exists {
+		return nil, ErrPostNotFound
 	}
 
-	post.Shares++
-	return nil
+	counts := make(map[string]int)
+	for _, emoji := range s.reactions[postID] {
+		counts[emoji]++
+	}
+	return counts, nil
+}
+
+// CommentPost increments the comment count for a post
+func (s *NewsfeedService) CommentPost(postID string) error {
+	return s.CommentPostBy(postID, "")
+}
+
+// CommentPostBy increments the comment count for a post and notifies its
+// author that userID commented. An empty userID comments anonymously.
+func (s *NewsfeedService) CommentPostBy(postID, userID string) error {
+	return s.engage(postID, userID, engageComment)
+}
+
+// SharePost increments the share count for a post
+func (s *NewsfeedService) SharePost(postID string) error {
+	return s.engage(postID, "", engageShare)
 }
 
 // GetUserPosts retrieves all posts by a user
@@ -243,14 +752,14 @@ func (s *NewsfeedService) GetUserPosts(userID string) ([]*Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	postIDs, exists := s.userPosts[userID]
-	if !exists {
-		return nil, fmt.Errorf("user not found")
+	if _, exists := s.users[userID]; !exists {
+		return nil, ErrUserNotFound
 	}
+	postIDs := s.readPosts.Get(userID)
 
 	posts := make([]*Post, 0, len(postIDs))
 	for _, postID := range postIDs {
-		if post, exists := s.posts[postID]; exists {
+		if post, exists := s.livePost(postID); exists {
 			posts = append(posts, post)
 		}
 	}
@@ -264,31 +773,48 @@ func (s *NewsfeedService) GetUserPosts(userID string) ([]*Post, error) {
 }
 
 // GetNewsfeed retrieves the newsfeed for a user (posts from followed users)
-func (s *NewsfeedService) GetNewsfeed(userID string, limit int) ([]*Post, error) {
+// newest first
+func (s *NewsfeedService) GetNewsfeed(ctx context.Context, userID string, limit int) ([]*Post, error) {
+	return s.GetRankedNewsfeed(ctx, userID, limit, SortRecent)
+}
+
+// GetRankedNewsfeed retrieves the newsfeed for a user ordered by the ranker
+// registered for mode. It stops with ctx's error if ctx is done before the
+// feed is built.
+func (s *NewsfeedService) GetRankedNewsfeed(ctx context.Context, userID string, limit int, mode string) ([]*Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	ranker, exists := s.rankers[mode]
+	if !exists {
+		return nil, ErrUnknownSort
+	}
+
 	user, exists := s.users[userID]
 	if !exists {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	// Collect posts from followed users
 	posts := []*Post{}
 	for _, followedID := range user.Following {
-		if postIDs, exists := s.userPosts[followedID]; exists {
-			for _, postID := range postIDs {
-				if post, exists := s.posts[postID]; exists {
-					posts = append(posts, post)
-				}
+		// Users can follow many accounts; give up between them once the
+		// caller has
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, postID := range s.readPosts.Get(followedID) {
+			if post, exists := s.livePost(postID); exists {
+				posts = append(posts, post)
 			}
 		}
 	}
 
-	// Sort by timestamp descending
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Timestamp.After(posts[j].Timestamp)
-	})
+	ranker(posts, s.now())
 
 	// Apply limit
 	if limit > 0 && len(posts) > limit {
@@ -298,57 +824,458 @@ func (s *NewsfeedService) GetNewsfeed(userID string, limit int) ([]*Post, error)
 	return posts, nil
 }
 
-// DeletePost deletes a post
-func (s *NewsfeedService) DeletePost(postID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Ranker orders a feed in place, best first. now is when the feed is built.
+type Ranker func(posts []*Post, now time.Time)
 
-	post, exists := s.posts[postID]
-	if !exists {
-		return fmt.Errorf("post not found")
-	}
+// EngagementScore weighs shares over comments over likes
+func EngagementScore(post *Post) float64 {
+	return float64(post.Likes) + 2*float64(post.Comments) + 3*float64(post.Shares)
+}
 
-	// Remove from posts map
-	delete(s.posts, postID)
+// RankRecent orders posts newest first
+func RankRecent(posts []*Post, now time.Time) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Timestamp.After(posts[j].Timestamp)
+	})
+}
 
-	// Remove from user posts
-	userID := post.UserID
-	if postIDs, exists := s.userPosts[userID]; exists {
-		newPostIDs := []string{}
-		for _, id := range postIDs {
-			if id != postID {
-				newPostIDs = append(newPostIDs, id)
+// RankTop orders posts by engagement score, newest first on ties
+func RankTop(posts []*Post, now time.Time) {
+	rankByScore(posts, EngagementScore)
+}
+
+// HybridRanker returns a ranker that discounts engagement by age: a post's
+// score is (1 + engagement) halved every halfLife, so fresh posts with no
+// engagement still surface and old popular posts fade out
+func HybridRanker(halfLife time.Duration) Ranker {
+	return func(posts []*Post, now time.Time) {
+		rankByScore(posts, func(post *Post) float64 {
+			age := now.Sub(post.Timestamp)
+			if age < 0 {
+				age = 0
 			}
-		}
-		s.userPosts[userID] = newPostIDs
+			decay := math.Exp2(-float64(age) / float64(halfLife))
+			return (1 + EngagementScore(post)) * decay
+		})
 	}
-
-	return nil
 }
 
-// HTTP Handlers
+// rankByScore sorts posts by descending score, computing each score once
+func rankByScore(posts []*Post, score func(*Post) float64) {
+	scores := make(map[*Post]float64, len(posts))
+	for _, post := range posts {
+		scores[post] = score(post)
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		if scores[posts[i]] != scores[posts[j]] {
+			return scores[posts[i]] > scores[posts[j]]
+		}
+		return posts[i].Timestamp.After(posts[j].Timestamp)
+	})
+}
 
-var service *NewsfeedService
+// GetDiscoverFeed samples up to limit posts from users other than userID
+// and the users they blocked. Posts are drawn without replacement with
+// probability proportional to 1 + EngagementScore, so popular posts are
+// favored but every post has a chance. Posts come back in draw order.
+func (s *NewsfeedService) GetDiscoverFeed(userID string, limit int) ([]*Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-func createUserHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, ErrUserNotFound
 	}
 
-	var req struct {
-		UserID   string `json:"user_id"`
-		Username string `json:"username"`
+	excluded := map[string]bool{userID: true}
+	for _, id := range user.Blocked {
+		excluded[id] = true
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	candidates := []*Post{}
+	for authorID := range s.users {
+		if excluded[authorID] {
+			continue
+		}
+		for _, postID := range s.readPosts.Get(authorID) {
+			if post, exists := s.livePost(postID); exists {
+				candidates = append(candidates, post)
+			}
+		}
+	}
+	// Map order is random; fix it so a seeded source gives the same feed
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	return weightedSample(candidates, limit, s.rng, func(post *Post) float64 {
+		return 1 + EngagementScore(post)
+	}), nil
+}
+
+// weightedSample draws up to k posts without replacement, each with
+// probability proportional to its weight, in one pass using weighted
+// reservoir sampling (Efraimidis-Spirakis A-Res). Every post gets the key
+// u^(1/w) for a uniform u, and the k largest keys win; keys are compared
+// as ln(u)/w, which orders the same and doesn't underflow.
+func weightedSample(posts []*Post, k int, rng *rand.Rand, weight func(*Post) float64) []*Post {
+	if k <= 0 {
+		return []*Post{}
+	}
+
+	reservoir := &keyedPosts{}
+	for _, post := range posts {
+		key := math.Log(1-rng.Float64()) / weight(post)
+		if reservoir.Len() < k {
+			heap.Push(reservoir, keyedPost{post: post, key: key})
+		} else if key > (*reservoir)[0].key {
+			(*reservoir)[0] = keyedPost{post: post, key: key}
+			heap.Fix(reservoir, 0)
+		}
+	}
+
+	// Popping the min-heap yields the smallest key first, so fill from
+	// the back to list the winners in draw order
+	sampled := make([]*Post, reservoir.Len())
+	for i := len(sampled) - 1; i >= 0; i-- {
+		sampled[i] = heap.Pop(reservoir).(keyedPost).post
+	}
+	return sampled
+}
+
+// keyedPost is a post in the sampling reservoir
+type keyedPost struct {
+	post *Post
+	key  float64
+}
+
+// keyedPosts is a min-heap on key, so the weakest sample is at the root
+type keyedPosts []keyedPost
+
+func (h keyedPosts) Len() int            { return len(h) }
+func (h keyedPosts) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h keyedPosts) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keyedPosts) Push(x interface{}) { *h = append(*h, x.(keyedPost)) }
+func (h *keyedPosts) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// DeletePost soft-deletes a post. It disappears from feeds and lookups but
+// can be restored with RestorePost until the grace window passes.
+func (s *NewsfeedService) DeletePost(postID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deletePostLocked(postID)
+}
+
+// DeletePostBy soft-deletes a post like DeletePost on behalf of userID,
+// who must be its author
+func (s *NewsfeedService) DeletePostBy(postID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if post, exists := s.livePost(postID); exists && post.UserID != userID {
+		return ErrNotAuthor
+	}
+	return s.deletePostLocked(postID)
+}
+
+// deletePostLocked soft-deletes a live post. Must be called with s.mu held.
+func (s *NewsfeedService) deletePostLocked(postID string) error {
+	post, exists := s.livePost(postID)
+	if !exists {
+		return ErrPostNotFound
+	}
+
+	post.Deleted = true
+	post.DeletedAt = time.Now()
+	s.purgePostLocked(post)
+
+	return nil
+}
+
+// RestorePost undoes a soft delete made within the grace window
+func (s *NewsfeedService) RestorePost(postID string) (*Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.restorePostLocked(postID)
+}
+
+// RestorePostBy restores a post like RestorePost on behalf of userID, who
+// must be its author
+func (s *NewsfeedService) RestorePostBy(postID, userID string) (*Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if post, exists := s.posts[postID]; exists && post.UserID != userID {
+		return nil, ErrNotAuthor
+	}
+	return s.restorePostLocked(postID)
+}
+
+// restorePostLocked undoes a soft delete. Must be called with s.mu held.
+func (s *NewsfeedService) restorePostLocked(postID string) (*Post, error) {
+	post, exists := s.posts[postID]
+	if !exists {
+		return nil, ErrPostNotFound
+	}
+	if !post.Deleted {
+		return nil, ErrPostNotDeleted
+	}
+	if time.Since(post.DeletedAt) > s.deleteGrace {
+		return nil, ErrRestoreWindowExpired
+	}
+
+	post.Deleted = false
+	post.DeletedAt = time.Time{}
+	s.userPosts.Add(post.UserID, post.ID)
+
+	return post, nil
+}
+
+// ReapDeletedPosts permanently removes posts whose grace window has passed
+// and returns how many were purged
+func (s *NewsfeedService) ReapDeletedPosts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for postID, post := range s.posts {
+		if post.Deleted && time.Since(post.DeletedAt) > s.deleteGrace {
+			s.purgePostLocked(post)
+			delete(s.posts, postID)
+			delete(s.reactions, postID)
+			purged++
+		}
+	}
+
+	return purged
+}
+
+// StartReaper purges expired soft-deleted posts every interval
+func (s *NewsfeedService) StartReaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if purged := s.ReapDeletedPosts(); purged > 0 {
+				log.Printf("Purged %d deleted posts", purged)
+			}
+		}
+	}()
+}
+
+// purgePostLocked removes a post from every index it appears in. Must be
+// called with s.mu held.
+func (s *NewsfeedService) purgePostLocked(post *Post) {
+	s.userPosts.Remove(post.UserID, post.ID)
+}
+
+// postSeq returns the sequence number in a post ID, or 0 if it has none
+func postSeq(postID string) int64 {
+	seq, _ := strconv.ParseInt(strings.TrimPrefix(postID, "post_"), 10, 64)
+	return seq
+}
+
+// newsfeedState is the serialized form of the whole service. userPosts is
+// not stored: it is rebuilt from the live Posts, which are grouped by
+// author in posting order.
+type newsfeedState struct {
+	Users     []*User `json:"users"`
+	Posts     []*Post `json:"posts"`
+	PostIndex int64   `json:"post_index"`
+
+	Reactions map[string]map[string]string `json:"reactions,omitempty"` // postID -> userID -> emoji
+
+	Notifications     map[string][]*Notification `json:"notifications,omitempty"` // recipient userID -> notifications
+	NotificationIndex int64                      `json:"notification_index,omitempty"`
+}
+
+// Snapshot serializes every user, post (including soft-deleted ones) and
+// the post ID counter to JSON
+func (s *NewsfeedService) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := newsfeedState{
+		Users:     make([]*User, 0, len(s.users)),
+		Posts:     make([]*Post, 0, len(s.posts)),
+		PostIndex: s.postIndex,
+		Reactions: s.reactions,
+
+		Notifications:     s.notifications,
+		NotificationIndex: s.notificationIndex,
+	}
+
+	userIDs := make([]string, 0, len(s.users))
+	for userID := range s.users {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	// Soft-deleted posts are out of the index, so collect every author's
+	// posts and order them by their sequence number
+	postsByUser := make(map[string][]*Post, len(s.users))
+	for _, post := range s.posts {
+		postsByUser[post.UserID] = append(postsByUser[post.UserID], post)
+	}
+
+	for _, userID := range userIDs {
+		state.Users = append(state.Users, s.users[userID])
+		posts := postsByUser[userID]
+		sort.Slice(posts, func(i, j int) bool {
+			return postSeq(posts[i].ID) < postSeq(posts[j].ID)
+		})
+		state.Posts = append(state.Posts, posts...)
+	}
+
+	return json.Marshal(state)
+}
+
+// Restore replaces the service's state with a snapshot and rebuilds the
+// per-user post lists. Snapshots referring to unknown users are rejected
+// and leave the current state in place.
+func (s *NewsfeedService) Restore(data []byte) error {
+	var state newsfeedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	users := make(map[string]*User, len(state.Users))
+	var listed []*Post // live posts in posting order, to rebuild userPosts
+	for _, user := range state.Users {
+		if user == nil || user.ID == "" {
+			return fmt.Errorf("user without an id")
+		}
+		if _, exists := users[user.ID]; exists {
+			return fmt.Errorf("duplicate user %s", user.ID)
+		}
+		if user.Following == nil {
+			user.Following = []string{}
+		}
+		if user.Followers == nil {
+			user.Followers = []string{}
+		}
+		users[user.ID] = user
+	}
+	for _, user := range users {
+		refs := append(append(append([]string{}, user.Following...), user.Followers...), user.Blocked...)
+		for _, id := range refs {
+			if _, exists := users[id]; !exists {
+				return fmt.Errorf("user %s refers to unknown user %s", user.ID, id)
+			}
+		}
+	}
+
+	posts := make(map[string]*Post, len(state.Posts))
+	for _, post := range state.Posts {
+		if post == nil || post.ID == "" {
+			return fmt.Errorf("post without an id")
+		}
+		if _, exists := posts[post.ID]; exists {
+			return fmt.Errorf("duplicate post %s", post.ID)
+		}
+		if _, exists := users[post.UserID]; !exists {
+			return fmt.Errorf("post %s belongs to unknown user %s", post.ID, post.UserID)
+		}
+		posts[post.ID] = post
+		if !post.Deleted {
+			listed = append(listed, post)
+		}
+	}
+
+	reactions := make(map[string]map[string]string, len(state.Reactions))
+	for postID, byUser := range state.Reactions {
+		if _, exists := posts[postID]; !exists {
+			return fmt.Errorf("reactions to unknown post %s", postID)
+		}
+		reactions[postID] = make(map[string]string, len(byUser))
+		for userID, emoji := range byUser {
+			if _, exists := users[userID]; !exists || emoji == "" {
+				return fmt.Errorf("invalid reaction by %s to post %s", userID, postID)
+			}
+			reactions[postID][userID] = emoji
+		}
+	}
+
+	notifications := make(map[string][]*Notification, len(state.Notifications))
+	for userID, list := range state.Notifications {
+		if _, exists := users[userID]; !exists {
+			return fmt.Errorf("notifications for unknown user %s", userID)
+		}
+		for _, n := range list {
+			if n == nil || n.ID == "" {
+				return fmt.Errorf("notification without an id for user %s", userID)
+			}
+		}
+		notifications[userID] = list
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Rebuild the post lists through the write store, so a replica
+	// follows along
+	for userID := range s.users {
+		for _, postID := range s.userPosts.Get(userID) {
+			s.userPosts.Remove(userID, postID)
+		}
+	}
+	for _, post := range listed {
+		s.userPosts.Add(post.UserID, post.ID)
+	}
+	s.users = users
+	s.posts = posts
+	s.reactions = reactions
+	s.postIndex = state.PostIndex
+	s.notifications = notifications
+	s.notificationIndex = state.NotificationIndex
+
+	return nil
+}
+
+// HTTP Handlers
+
+var service *NewsfeedService
+
+// createUserRequest is the body of /user/create
+type createUserRequest struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+func (req createUserRequest) validate() error {
+	var v validate.Validator
+	v.String("user_id", req.UserID, validate.Required)
+	v.String("username", req.Username, validate.Required, validate.MaxLen(maxUsernameLength))
+	return v.Err()
+}
+
+func createUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req createUserRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
 		return
 	}
 
 	user, err := service.CreateUser(req.UserID, req.Username)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -359,13 +1286,13 @@ func createUserHandler(w http.ResponseWriter, r *http.Request) {
 func getUserHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
-		http.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	user, err := service.GetUser(userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -373,24 +1300,27 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// followRequest is the body of /user/follow and /user/unfollow
+type followRequest struct {
+	FollowerID string `json:"follower_id"`
+	FolloweeID string `json:"followee_id"`
+}
+
 func followHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		FollowerID string `json:"follower_id"`
-		FolloweeID string `json:"followee_id"`
-	}
+	var req followRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := service.Follow(req.FollowerID, req.FolloweeID); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := service.Follow(middleware.AuthenticatedUserID(r, req.FollowerID), req.FolloweeID); err != nil {
+		apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
@@ -399,47 +1329,190 @@ func followHandler(w http.ResponseWriter, r *http.Request) {
 
 func unfollowHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req followRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.Unfollow(middleware.AuthenticatedUserID(r, req.FollowerID), req.FolloweeID); err != nil {
+		apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
-	var req struct {
-		FollowerID string `json:"follower_id"`
-		FolloweeID string `json:"followee_id"`
+	w.WriteHeader(http.StatusOK)
+}
+
+// blockRequest is the body of /user/block and /user/unblock
+type blockRequest struct {
+	UserID    string `json:"user_id"`
+	BlockedID string `json:"blocked_id"`
+}
+
+func blockHandler(w http.ResponseWriter, r *http.Request) {
+	handleBlock(w, r, service.Block)
+}
+
+func unblockHandler(w http.ResponseWriter, r *http.Request) {
+	handleBlock(w, r, service.Unblock)
+}
+
+func handleBlock(w http.ResponseWriter, r *http.Request, apply func(string, string) error) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	var req blockRequest
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := service.Unfollow(req.FollowerID, req.FolloweeID); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := apply(middleware.AuthenticatedUserID(r, req.UserID), req.BlockedID); err != nil {
+		apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func createPostHandler(w http.ResponseWriter, r *http.Request) {
+// followBatchRequest is the body of /user/follow-batch and /user/unfollow-batch
+type followBatchRequest struct {
+	FollowerID  string   `json:"follower_id"`
+	FolloweeIDs []string `json:"followee_ids"`
+}
+
+// followBatchResponse reports which followee ids were applied
+type followBatchResponse struct {
+	Succeeded []string `json:"succeeded"`
+	Failed    []string `json:"failed"`
+}
+
+func followBatchHandler(w http.ResponseWriter, r *http.Request) {
+	handleFollowBatch(w, r, service.FollowMany)
+}
+
+func unfollowBatchHandler(w http.ResponseWriter, r *http.Request) {
+	handleFollowBatch(w, r, service.UnfollowMany)
+}
+
+func handleFollowBatch(w http.ResponseWriter, r *http.Request, apply func(string, []string) ([]string, []string)) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		UserID  string `json:"user_id"`
-		Content string `json:"content"`
+	var req followBatchRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	if len(req.FolloweeIDs) == 0 {
+		apierror.Error(w, "followee_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	succeeded, failed := apply(middleware.AuthenticatedUserID(r, req.FollowerID), req.FolloweeIDs)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(followBatchResponse{Succeeded: succeeded, Failed: failed})
+}
+
+func getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	handleFollowPage(w, r, service.GetFollowers)
+}
+
+func getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	handleFollowPage(w, r, service.GetFollowing)
+}
+
+func handleFollowPage(w http.ResponseWriter, r *http.Request, get func(string, int, int) (*FollowPage, error)) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	offset, err := queryInt(r, "offset")
+	if err != nil || offset < 0 {
+		apierror.Error(w, "invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(r, "limit")
+	if err != nil || limit < 0 {
+		apierror.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	page, err := get(userID, offset, limit)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// queryInt parses an optional integer query parameter, returning 0 when it
+// is absent
+func queryInt(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// createPostRequest is the body of /post/create
+type createPostRequest struct {
+	UserID  string `json:"user_id"`
+	Content string `json:"content"`
+}
+
+func (req createPostRequest) validate() error {
+	var v validate.Validator
+	v.String("user_id", req.UserID, validate.Required)
+	v.String("content", req.Content, validate.Required)
+	return v.Err()
+}
+
+func createPostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req createPostRequest
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
 		return
 	}
 
 	post, err := service.CreatePost(req.UserID, req.Content)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	switch {
+	case errors.Is(err, moderation.ErrRejected):
+		apierror.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
@@ -447,40 +1520,230 @@ func createPostHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(post)
 }
 
-func likePostHandler(w http.ResponseWriter, r *http.Request) {
+// schedulePostRequest is the body of /post/schedule
+type schedulePostRequest struct {
+	UserID    string    `json:"user_id"`
+	Content   string    `json:"content"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+func schedulePostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req schedulePostRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	post, err := service.SchedulePost(middleware.AuthenticatedUserID(r, req.UserID), req.Content, req.PublishAt)
+	switch {
+	case errors.Is(err, moderation.ErrRejected):
+		apierror.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// cancelScheduledRequest is the body of /post/cancel-scheduled
+type cancelScheduledRequest struct {
+	PostID string `json:"post_id"`
+	UserID string `json:"user_id,omitempty"` // the author; the token's subject when authenticated
+}
+
+func cancelScheduledHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req cancelScheduledRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := service.CancelScheduledPost(req.PostID, middleware.AuthenticatedUserID(r, req.UserID))
+	switch {
+	case errors.Is(err, ErrNotAuthor):
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrPostNotScheduled):
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	var req struct {
-		PostID string `json:"post_id"`
+	w.WriteHeader(http.StatusOK)
+}
+
+// likePostRequest is the body of /post/like
+type likePostRequest struct {
+	PostID string `json:"post_id"`
+	UserID string `json:"user_id,omitempty"` // who liked it; the token's subject when authenticated
+}
+
+func likePostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	var req likePostRequest
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := service.LikePost(req.PostID); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	err := service.LikePostBy(req.PostID, middleware.AuthenticatedUserID(r, req.UserID))
+	switch {
+	case errors.Is(err, ErrEngagementBacklog):
+		apierror.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// reactRequest is the body of POST /post/react
+type reactRequest struct {
+	PostID string `json:"post_id"`
+	UserID string `json:"user_id"`
+	Emoji  string `json:"emoji"`
+}
+
+func (req reactRequest) validate() error {
+	var v validate.Validator
+	v.String("post_id", req.PostID, validate.Required)
+	v.String("user_id", req.UserID, validate.Required)
+	v.String("emoji", req.Emoji, validate.Required, validate.MaxLen(maxReactionLength))
+	return v.Err()
+}
+
+// reactHandler sets a reaction on POST and removes it on DELETE
+func reactHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req reactRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+		if err := req.validate(); err != nil {
+			validate.Write(w, err)
+			return
+		}
+
+		if err := service.AddReaction(req.PostID, req.UserID, req.Emoji); err != nil {
+			apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+	case http.MethodDelete:
+		postID := r.URL.Query().Get("post_id")
+		userID := middleware.AuthenticatedUserID(r, r.URL.Query().Get("user_id"))
+		if postID == "" || userID == "" {
+			apierror.Error(w, "post_id and user_id parameters are required", http.StatusBadRequest)
+			return
+		}
+
+		if err := service.RemoveReaction(postID, userID); err != nil {
+			apierror.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+	default:
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	postID := r.URL.Query().Get("post_id")
+	if postID == "" {
+		apierror.Error(w, "post_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	reactions, err := service.GetReactions(postID)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
+
 func getNewsfeedHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
-		http.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
+	mode := r.URL.Query().Get("sort")
+	if mode == "" {
+		mode = SortRecent
+	}
+
 	limit := 50 // default limit
-	posts, err := service.GetNewsfeed(userID, limit)
+	posts, err := service.GetRankedNewsfeed(r.Context(), userID, limit, mode)
+	if errors.Is(err, ErrUnknownSort) {
+		apierror.Error(w, fmt.Sprintf("sort must be %s, %s or %s", SortRecent, SortTop, SortHybrid), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		apierror.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+func getDiscoverFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(r, "limit")
+	if err != nil || limit < 0 || limit > maxDiscoverLimit {
+		apierror.Error(w, fmt.Sprintf("limit must be between 0 and %d", maxDiscoverLimit), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = defaultDiscoverLimit
+	}
+
+	posts, err := service.GetDiscoverFeed(userID, limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -491,13 +1754,13 @@ func getNewsfeedHandler(w http.ResponseWriter, r *http.Request) {
 func getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
-		http.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	posts, err := service.GetUserPosts(userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -505,26 +1768,327 @@ func getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(posts)
 }
 
+func deletePostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID := r.URL.Query().Get("post_id")
+	if postID == "" {
+		apierror.Error(w, "post_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.AuthenticatedUserID(r, r.URL.Query().Get("user_id"))
+	err := service.DeletePostBy(postID, userID)
+	switch {
+	case errors.Is(err, ErrNotAuthor):
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// restorePostRequest is the body of /post/restore
+type restorePostRequest struct {
+	PostID string `json:"post_id"`
+	UserID string `json:"user_id,omitempty"` // the author; the token's subject when authenticated
+}
+
+func restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req restorePostRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	post, err := service.RestorePostBy(req.PostID, middleware.AuthenticatedUserID(r, req.UserID))
+	switch {
+	case errors.Is(err, ErrNotAuthor):
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrPostNotDeleted):
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrRestoreWindowExpired):
+		apierror.Error(w, err.Error(), http.StatusGone)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Newsfeed Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(&service.mu, time.Second))
+	checker.Register("post_indexer", false, func() error {
+		if service.Events().SubscriberCount(TopicPostCreated) == 0 {
+			return errors.New("no post.created subscriber")
+		}
+		return nil
+	})
+
+	// Mutating endpoints act as the user in the bearer token
+	auth := middleware.AuthFromEnv("JWT_SECRET")
+
+	// Cap request bodies and bound request time on create endpoints
+	guard := middleware.LimitRequest(middleware.DefaultMaxBodyBytes, middleware.DefaultRequestTimeout)
+
+	// Retried creates with the same Idempotency-Key replay the first response
+	idempotency := middleware.NewIdempotencyStore(middleware.DefaultIdempotencyTTL)
+	idempotency.StartJanitor(time.Minute)
+	idempotent := middleware.Idempotency(idempotency)
+
+	// Compress large list responses for clients that accept gzip
+	compress := middleware.Gzip(middleware.DefaultGzipMinBytes)
+
+	// State dumps need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	userQuery := []openapi.Param{{Name: "user_id", Required: true}}
+	pageQuery := []openapi.Param{
+		{Name: "user_id", Required: true},
+		{Name: "offset", Description: "Number of ids to skip"},
+		{Name: "limit", Description: "Page size, default 50, max 500"},
+	}
+
+	api.Handle("/user/create", auth(http.HandlerFunc(createUserHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Create a user",
+		Request: createUserRequest{}, Response: User{},
+		Responses: map[int]string{200: "User created", 400: "Invalid fields or user exists", 401: "Missing or invalid token"},
+	})
+	api.HandleFunc("/user/get", getUserHandler, openapi.Route{
+		Summary: "Get a user", Query: userQuery, Response: User{},
+		Responses: map[int]string{200: "The user", 400: "Missing user_id", 404: "User not found"},
+	})
+	api.Handle("/user/follow", auth(http.HandlerFunc(followHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Follow a user", Request: followRequest{},
+		Responses: map[int]string{200: "Followed", 400: "Invalid request", 401: "Missing or invalid token", 404: "User not found"},
+	})
+	api.Handle("/user/unfollow", auth(http.HandlerFunc(unfollowHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Unfollow a user", Request: followRequest{},
+		Responses: map[int]string{200: "Unfollowed", 400: "Invalid request", 401: "Missing or invalid token", 404: "User not found"},
+	})
+	api.Handle("/user/block", auth(http.HandlerFunc(blockHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Hide a user's posts from discovery", Request: blockRequest{},
+		Responses: map[int]string{200: "Blocked", 400: "Invalid request", 401: "Missing or invalid token", 404: "User not found"},
+	})
+	api.Handle("/user/unblock", auth(http.HandlerFunc(unblockHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Show a blocked user's posts in discovery again", Request: blockRequest{},
+		Responses: map[int]string{200: "Unblocked", 400: "Invalid request", 401: "Missing or invalid token", 404: "User not found"},
+	})
+	api.Handle("/user/follow-batch", auth(http.HandlerFunc(followBatchHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Follow several users",
+		Request: followBatchRequest{}, Response: followBatchResponse{},
+		Responses: map[int]string{200: "Per-id results", 400: "Invalid request", 401: "Missing or invalid token"},
+	})
+	api.Handle("/user/unfollow-batch", auth(http.HandlerFunc(unfollowBatchHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Unfollow several users",
+		Request: followBatchRequest{}, Response: followBatchResponse{},
+		Responses: map[int]string{200: "Per-id results", 400: "Invalid request", 401: "Missing or invalid token"},
+	})
+	api.HandleFunc("/user/followers", getFollowersHandler, openapi.Route{
+		Summary: "List a user's followers", Query: pageQuery, Response: FollowPage{},
+		Responses: map[int]string{200: "A page of follower ids", 400: "Missing user_id or invalid paging", 404: "User not found"},
+	})
+	api.HandleFunc("/user/following", getFollowingHandler, openapi.Route{
+		Summary: "List the users a user follows", Query: pageQuery, Response: FollowPage{},
+		Responses: map[int]string{200: "A page of followee ids", 400: "Missing user_id or invalid paging", 404: "User not found"},
+	})
+	api.Handle("/post/create", auth(guard(idempotent(http.HandlerFunc(createPostHandler)))), openapi.Route{
+		Method: http.MethodPost, Summary: "Create a post",
+		Request: createPostRequest{}, Response: Post{},
+		Responses: map[int]string{
+			200: "Post created",
+			400: "Invalid request, blank content or content over the length limit",
+			401: "Missing or invalid token",
+			404: "User not found",
+			409: "Same Idempotency-Key still in progress",
+			413: "Body too large",
+			422: "Content rejected by the filter, or Idempotency-Key reused with a different body",
+		},
+	})
+	api.Handle("/post/schedule", auth(guard(idempotent(http.HandlerFunc(schedulePostHandler)))), openapi.Route{
+		Method: http.MethodPost, Summary: "Schedule a post to publish later",
+		Request: schedulePostRequest{}, Response: Post{},
+		Responses: map[int]string{
+			200: "Post scheduled",
+			400: "Invalid request, blank or overlong content, or publish_at not in the future",
+			401: "Missing or invalid token",
+			404: "User not found",
+			409: "Same Idempotency-Key still in progress",
+			413: "Body too large",
+			422: "Content rejected by the filter, or Idempotency-Key reused with a different body",
+		},
+	})
+	api.Handle("/post/cancel-scheduled", auth(http.HandlerFunc(cancelScheduledHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Cancel a post before it is published", Request: cancelScheduledRequest{},
+		Responses: map[int]string{200: "Cancelled", 400: "Invalid request", 401: "Missing or invalid token", 403: "Not the post's author", 404: "Post not found", 409: "Post is already published"},
+	})
+	api.Handle("/post/like", auth(http.HandlerFunc(likePostHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Like a post", Request: likePostRequest{},
+		Responses: map[int]string{200: "Liked", 400: "Invalid request", 401: "Missing or invalid token", 404: "Post not found", 503: "Too many likes queued"},
+	})
+	api.Handle("/post/react", auth(http.HandlerFunc(reactHandler)),
+		openapi.Route{
+			Method: http.MethodPost, Summary: "React to a post, replacing the user's earlier reaction", Request: reactRequest{},
+			Responses: map[int]string{200: "Reaction set", 400: "Invalid request", 401: "Missing or invalid token", 404: "Post or user not found"},
+		},
+		openapi.Route{
+			Method: http.MethodDelete, Summary: "Remove the user's reaction to a post",
+			Query:     []openapi.Param{{Name: "post_id", Required: true}, {Name: "user_id", Required: true}},
+			Responses: map[int]string{200: "Reaction removed", 400: "Missing post_id or user_id", 401: "Missing or invalid token", 404: "Post not found"},
+		},
+	)
+	api.Handle("/notifications", auth(http.HandlerFunc(notificationsHandler)),
+		openapi.Route{
+			Summary: "List a user's notifications, newest first",
+			Query: []openapi.Param{
+				{Name: "user_id", Required: true},
+				{Name: "unread", Description: "true to list only unread notifications"},
+			},
+			Response:  []Notification{},
+			Responses: map[int]string{200: "The notifications", 400: "Missing user_id", 401: "Missing or invalid token", 404: "User not found"},
+		},
+		openapi.Route{
+			Method: http.MethodPost, Summary: "Mark a notification as read", Request: markNotificationReadRequest{},
+			Responses: map[int]string{200: "Marked read", 400: "Invalid request", 401: "Missing or invalid token", 404: "User or notification not found"},
+		},
+	)
+	api.HandleFunc("/post/reactions", getReactionsHandler, openapi.Route{
+		Summary: "Count a post's reactions by emoji", Query: []openapi.Param{{Name: "post_id", Required: true}},
+		Response:  map[string]int{},
+		Responses: map[int]string{200: "Reaction counts", 400: "Missing post_id", 404: "Post not found"},
+	})
+	api.Handle("/post/delete", auth(http.HandlerFunc(deletePostHandler)), openapi.Route{
+		Method: http.MethodDelete, Summary: "Soft-delete a post",
+		Query:     []openapi.Param{{Name: "post_id", Required: true}, {Name: "user_id", Description: "The author; the token's subject when authenticated"}},
+		Responses: map[int]string{200: "Post deleted", 400: "Missing post_id", 401: "Missing or invalid token", 403: "Not the post's author", 404: "Post not found"},
+	})
+	api.Handle("/post/restore", auth(http.HandlerFunc(restorePostHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Restore a soft-deleted post",
+		Request: restorePostRequest{}, Response: Post{},
+		Responses: map[int]string{
+			200: "Post restored",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			403: "Not the post's author",
+			404: "Post not found",
+			409: "Post is not deleted",
+			410: "Restore window expired",
+		},
+	})
+	api.Handle("/newsfeed", compress(http.HandlerFunc(getNewsfeedHandler)), openapi.Route{
+		Summary: "Get a user's newsfeed",
+		Query: []openapi.Param{
+			{Name: "user_id", Required: true},
+			{Name: "sort", Description: "recent (default), top or hybrid"},
+		},
+		Response:  []Post{},
+		Responses: map[int]string{200: "Posts from followed users", 400: "Missing user_id or unknown sort", 404: "User not found"},
+	})
+	api.Handle("/discover", compress(http.HandlerFunc(getDiscoverFeedHandler)), openapi.Route{
+		Summary: "Sample posts from other users, weighted by engagement",
+		Query: []openapi.Param{
+			{Name: "user_id", Required: true},
+			{Name: "limit", Description: "Number of posts, default 20, max 100"},
+		},
+		Response:  []Post{},
+		Responses: map[int]string{200: "Sampled posts in draw order", 400: "Missing user_id or invalid limit", 404: "User not found"},
+	})
+	api.Handle("/posts", compress(http.HandlerFunc(getUserPostsHandler)), openapi.Route{
+		Summary: "List a user's posts", Query: userQuery, Response: []Post{},
+		Responses: map[int]string{200: "The user's posts", 400: "Missing user_id", 404: "User not found"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(newsfeedState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Handle("/metrics", metrics.Handler(), openapi.Route{
+		Summary: "Counters in the Prometheus text format", Responses: map[int]string{200: "The metrics"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
-	service = NewNewsfeedService()
-
-	http.HandleFunc("/user/create", createUserHandler)
-	http.HandleFunc("/user/get", getUserHandler)
-	http.HandleFunc("/user/follow", followHandler)
-	http.HandleFunc("/user/unfollow", unfollowHandler)
-	http.HandleFunc("/post/create", createPostHandler)
-	http.HandleFunc("/post/like", likePostHandler)
-	http.HandleFunc("/newsfeed", getNewsfeedHandler)
-	http.HandleFunc("/posts", getUserPostsHandler)
-	http.HandleFunc("/health", healthHandler)
+	service = NewNewsfeedServiceWithStores(storesFromEnv())
+	service.SetContentFilter(moderation.FromEnv("BANNED_WORDS"))
+	service.SetPostLimits(postLimitsFromEnv())
+
+	indexer := NewPostIndexer()
+	go indexer.Run(service.Events().Subscribe(TopicPostCreated))
+
+	// Purge soft-deleted posts once their restore window has passed
+	service.StartReaper(time.Minute)
+
+	// Publish scheduled posts as their time arrives
+	service.StartScheduler(time.Second)
+
+	// Apply likes, comments and shares in batches off the request path
+	service.StartEngagementWorkers(DefaultEngagementConfig())
+
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8081"
-	log.Printf("Newsfeed service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
-}
+	server := &http.Server{
+		Addr:    port,
+		Handler: middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...),
+	}
+	go func() {
+		log.Printf("Newsfeed service starting on %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
+	// Stop taking requests, then apply the queued engagement before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		log.Printf("Shutdown: %v", err)
+	}
+	service.DrainEngagement()
+}
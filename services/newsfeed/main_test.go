@@ -5,10 +5,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"common/middleware"
 )
 
 func TestNewNewsfeedService(t *testing.T) {
@@ -125,6 +134,104 @@ func TestUnfollow_NotFollowing(t *testing.T) {
 	}
 }
 
+func TestFollowMany_PartialFailure(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+	service.CreateUser("user3", "testuser3")
+	service.Follow("user1", "user3")
+
+	succeeded, failed := service.FollowMany("user1", []string{"user2", "missing", "user1", "user3", "user2"})
+
+	if len(succeeded) != 1 || succeeded[0] != "user2" {
+		t.Errorf("Expected only user2 to succeed, got %v", succeeded)
+	}
+	if len(failed) != 4 {
+		t.Errorf("Expected 4 failures, got %v", failed)
+	}
+
+	user1, _ := service.GetUser("user1")
+	if len(user1.Following) != 2 {
+		t.Errorf("Expected user1 to follow 2 users, got %v", user1.Following)
+	}
+	user2, _ := service.GetUser("user2")
+	if len(user2.Followers) != 1 || user2.Followers[0] != "user1" {
+		t.Errorf("Expected user2 to have user1 as follower, got %v", user2.Followers)
+	}
+}
+
+func TestUnfollowMany(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+	service.CreateUser("user3", "testuser3")
+	service.FollowMany("user1", []string{"user2", "user3"})
+
+	succeeded, failed := service.UnfollowMany("user1", []string{"user2", "missing"})
+	if len(succeeded) != 1 || len(failed) != 1 {
+		t.Errorf("Expected 1 success and 1 failure, got %v and %v", succeeded, failed)
+	}
+
+	user1, _ := service.GetUser("user1")
+	if len(user1.Following) != 1 || user1.Following[0] != "user3" {
+		t.Errorf("Expected user1 to only follow user3, got %v", user1.Following)
+	}
+	user2, _ := service.GetUser("user2")
+	if len(user2.Followers) != 0 {
+		t.Errorf("Expected user2 to have no followers, got %v", user2.Followers)
+	}
+}
+
+func TestGetFollowers_Pagination(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("star", "star")
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("fan%d", i)
+		service.CreateUser(id, id)
+		service.Follow(id, "star")
+	}
+
+	page, err := service.GetFollowers("star", 2, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if page.Total != 5 {
+		t.Errorf("Expected total 5, got %d", page.Total)
+	}
+	if len(page.UserIDs) != 2 || page.UserIDs[0] != "fan2" || page.UserIDs[1] != "fan3" {
+		t.Errorf("Expected [fan2 fan3], got %v", page.UserIDs)
+	}
+
+	page, _ = service.GetFollowers("star", 10, 2)
+	if len(page.UserIDs) != 0 {
+		t.Errorf("Expected an empty page past the end, got %v", page.UserIDs)
+	}
+
+	page, _ = service.GetFollowing("fan0", 0, 0)
+	if page.Limit != defaultFollowPageLimit || len(page.UserIDs) != 1 {
+		t.Errorf("Expected default limit and 1 followee, got %+v", page)
+	}
+
+	if _, err := service.GetFollowers("missing", 0, 10); err == nil {
+		t.Error("Expected error for unknown user")
+	}
+}
+
+func TestGetFollowers_CopiesPage(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+	service.Follow("user1", "user2")
+
+	page, _ := service.GetFollowers("user2", 0, 10)
+	page.UserIDs[0] = "changed"
+
+	user2, _ := service.GetUser("user2")
+	if user2.Followers[0] != "user1" {
+		t.Error("Expected the page not to share the followers slice")
+	}
+}
+
 func TestCreatePost(t *testing.T) {
 	service := NewNewsfeedService()
 	service.CreateUser("user1", "testuser")
@@ -221,7 +328,7 @@ func TestGetNewsfeed(t *testing.T) {
 	service.Follow("user1", "user2")
 	service.CreatePost("user2", "Post from user2")
 
-	feed, err := service.GetNewsfeed("user1", 50)
+	feed, err := service.GetNewsfeed(context.Background(), "user1", 50)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -231,6 +338,134 @@ func TestGetNewsfeed(t *testing.T) {
 	}
 }
 
+// newRankingFixture builds a feed for user1 with posts of known age and
+// engagement, relative to a fixed clock
+func newRankingFixture(t *testing.T) *NewsfeedService {
+	t.Helper()
+
+	service := NewNewsfeedService()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	service.CreateUser("user1", "reader")
+	service.CreateUser("user2", "writer")
+	service.Follow("user1", "user2")
+
+	fixture := []struct {
+		content                 string
+		age                     time.Duration
+		likes, comments, shares int64
+	}{
+		{"fresh", time.Hour, 0, 0, 0},              // engagement 0
+		{"old-popular", 48 * time.Hour, 100, 0, 0}, // engagement 100
+		{"mid", 6 * time.Hour, 5, 1, 0},            // engagement 7
+		{"viral", 3 * time.Hour, 0, 0, 2},          // engagement 6
+	}
+	for _, f := range fixture {
+		post, err := service.CreatePost("user2", f.content)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		post.Timestamp = now.Add(-f.age)
+		post.Likes, post.Comments, post.Shares = f.likes, f.comments, f.shares
+	}
+	return service
+}
+
+func feedContents(posts []*Post) []string {
+	contents := make([]string, len(posts))
+	for i, post := range posts {
+		contents[i] = post.Content
+	}
+	return contents
+}
+
+func TestGetRankedNewsfeed_Modes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		halfLife time.Duration
+		expected []string
+	}{
+		{SortRecent, 0, []string{"fresh", "viral", "mid", "old-popular"}},
+		{SortTop, 0, []string{"old-popular", "mid", "viral", "fresh"}},
+		// (1 + engagement) halved every 6h: viral 4.95, mid 4, fresh 0.89, old 0.39
+		{SortHybrid, DefaultHybridHalfLife, []string{"viral", "mid", "fresh", "old-popular"}},
+		// A two day half-life lets engagement dominate: old 50.5, mid 7.3, viral 6.7
+		{SortHybrid, 48 * time.Hour, []string{"old-popular", "mid", "viral", "fresh"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%s", tt.mode, tt.halfLife), func(t *testing.T) {
+			service := newRankingFixture(t)
+			if tt.halfLife > 0 {
+				service.SetHybridHalfLife(tt.halfLife)
+			}
+
+			feed, err := service.GetRankedNewsfeed(context.Background(), "user1", 50, tt.mode)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := feedContents(feed); strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetRankedNewsfeed_LimitAndCustomRanker(t *testing.T) {
+	service := newRankingFixture(t)
+
+	feed, _ := service.GetRankedNewsfeed(context.Background(), "user1", 2, SortTop)
+	if got := feedContents(feed); strings.Join(got, ",") != "old-popular,mid" {
+		t.Errorf("Expected the limit to apply after ranking, got %v", got)
+	}
+
+	service.SetRanker("alphabetical", func(posts []*Post, now time.Time) {
+		sort.Slice(posts, func(i, j int) bool { return posts[i].Content < posts[j].Content })
+	})
+	feed, _ = service.GetRankedNewsfeed(context.Background(), "user1", 50, "alphabetical")
+	if got := feedContents(feed); strings.Join(got, ",") != "fresh,mid,old-popular,viral" {
+		t.Errorf("Expected the plugged in ranker to be used, got %v", got)
+	}
+
+	if _, err := service.GetRankedNewsfeed(context.Background(), "user1", 50, "bogus"); err != ErrUnknownSort {
+		t.Errorf("Expected ErrUnknownSort, got %v", err)
+	}
+}
+
+func TestGetNewsfeedHandler_Sort(t *testing.T) {
+	service = newRankingFixture(t)
+
+	tests := []struct {
+		query    string
+		status   int
+		expected string
+	}{
+		{"user_id=user1", http.StatusOK, "fresh,viral,mid,old-popular"},
+		{"user_id=user1&sort=top", http.StatusOK, "old-popular,mid,viral,fresh"},
+		{"user_id=user1&sort=hybrid", http.StatusOK, "viral,mid,fresh,old-popular"},
+		{"user_id=user1&sort=bogus", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/newsfeed?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		getNewsfeedHandler(w, req)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s: expected status %d, got %d", tt.query, tt.status, w.Code)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+
+		var posts []*Post
+		json.NewDecoder(w.Body).Decode(&posts)
+		if got := strings.Join(feedContents(posts), ","); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.expected, got)
+		}
+	}
+}
+
 func TestDeletePost(t *testing.T) {
 	service := NewNewsfeedService()
 	service.CreateUser("user1", "testuser")
@@ -247,6 +482,84 @@ func TestDeletePost(t *testing.T) {
 	}
 }
 
+func TestDeletePost_HiddenFromNewsfeed(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+	service.Follow("user1", "user2")
+	post, _ := service.CreatePost("user2", "Hello")
+	service.CreatePost("user2", "World")
+
+	service.DeletePost(post.ID)
+
+	feed, _ := service.GetNewsfeed(context.Background(), "user1", 10)
+	if len(feed) != 1 || feed[0].ID == post.ID {
+		t.Errorf("Expected deleted post to be hidden from the feed, got %d posts", len(feed))
+	}
+	posts, _ := service.GetUserPosts("user2")
+	if len(posts) != 1 {
+		t.Errorf("Expected 1 visible post, got %d", len(posts))
+	}
+	if err := service.LikePost(post.ID); err == nil {
+		t.Error("Expected liking a deleted post to fail")
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+	service.Follow("user1", "user2")
+	post, _ := service.CreatePost("user2", "Hello")
+
+	if _, err := service.RestorePost(post.ID); err != ErrPostNotDeleted {
+		t.Errorf("Expected ErrPostNotDeleted, got %v", err)
+	}
+
+	service.DeletePost(post.ID)
+	restored, err := service.RestorePost(post.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if restored.Deleted || !restored.DeletedAt.IsZero() {
+		t.Error("Expected restored post to be live")
+	}
+
+	feed, _ := service.GetNewsfeed(context.Background(), "user1", 10)
+	if len(feed) != 1 {
+		t.Errorf("Expected restored post in the feed, got %d posts", len(feed))
+	}
+}
+
+func TestReapDeletedPosts(t *testing.T) {
+	service := NewNewsfeedService()
+	service.SetDeleteGrace(time.Hour)
+	service.CreateUser("user1", "testuser")
+	expired, _ := service.CreatePost("user1", "Old")
+	recent, _ := service.CreatePost("user1", "New")
+
+	service.DeletePost(expired.ID)
+	service.DeletePost(recent.ID)
+	service.posts[expired.ID].DeletedAt = time.Now().Add(-2 * time.Hour)
+
+	if _, err := service.RestorePost(expired.ID); err != ErrRestoreWindowExpired {
+		t.Errorf("Expected ErrRestoreWindowExpired, got %v", err)
+	}
+
+	if purged := service.ReapDeletedPosts(); purged != 1 {
+		t.Fatalf("Expected 1 purged post, got %d", purged)
+	}
+	if _, exists := service.posts[expired.ID]; exists {
+		t.Error("Expected expired post to be purged")
+	}
+	if service.userPosts.Has("user1", expired.ID) {
+		t.Errorf("Expected purged post removed from user posts, got %v", service.userPosts.Get("user1"))
+	}
+	if _, err := service.RestorePost(recent.ID); err != nil {
+		t.Errorf("Expected post within the window to be restorable, got %v", err)
+	}
+}
+
 func TestCreateUserHandler(t *testing.T) {
 	service = NewNewsfeedService()
 
@@ -301,6 +614,60 @@ func TestFollowHandler(t *testing.T) {
 	}
 }
 
+func TestFollowBatchHandler(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"follower_id":  "user1",
+		"followee_ids": []string{"user2", "missing"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/user/follow-batch", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	followBatchHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp followBatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Succeeded) != 1 || len(resp.Failed) != 1 || resp.Failed[0] != "missing" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+}
+
+func TestGetFollowersHandler(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "testuser1")
+	service.CreateUser("user2", "testuser2")
+	service.Follow("user1", "user2")
+
+	req := httptest.NewRequest(http.MethodGet, "/user/followers?user_id=user2&offset=0&limit=10", nil)
+	w := httptest.NewRecorder()
+	getFollowersHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var page FollowPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if page.Total != 1 || page.UserIDs[0] != "user1" {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/user/followers?user_id=user2&offset=-1", nil)
+	w = httptest.NewRecorder()
+	getFollowersHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for negative offset, got %d", w.Code)
+	}
+}
+
 func TestCreatePostHandler(t *testing.T) {
 	service = NewNewsfeedService()
 	service.CreateUser("user1", "testuser")
@@ -321,6 +688,76 @@ func TestCreatePostHandler(t *testing.T) {
 	}
 }
 
+func TestDeleteAndRestorePostHandlers(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "testuser")
+	post, _ := service.CreatePost("user1", "Hello")
+
+	req := httptest.NewRequest(http.MethodDelete, "/post/delete?post_id="+post.ID+"&user_id=user1", nil)
+	w := httptest.NewRecorder()
+	deletePostHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	body, _ := json.Marshal(map[string]string{"post_id": post.ID, "user_id": "user1"})
+	req = httptest.NewRequest(http.MethodPost, "/post/restore", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	restorePostHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/post/restore", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	restorePostHandler(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a live post, got %d", w.Code)
+	}
+}
+
+func TestDeleteAndRestorePostHandlers_OnlyAuthor(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+	service.CreateUser("user2", "bob")
+	post, _ := service.CreatePost("user1", "Hello")
+
+	secret := []byte("test-secret")
+	auth := middleware.JWTAuth(secret)
+	send := func(handler http.HandlerFunc, req *http.Request, userID string) int {
+		token, _ := middleware.SignToken(userID, time.Hour, secret)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		auth(handler).ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The token's subject counts, not a user_id claiming to be the author
+	deleteReq := func() *http.Request {
+		return httptest.NewRequest(http.MethodDelete, "/post/delete?post_id="+post.ID+"&user_id=user1", nil)
+	}
+	if code := send(deletePostHandler, deleteReq(), "user2"); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 deleting someone else's post, got %d", code)
+	}
+	if _, err := service.GetPost(post.ID); err != nil {
+		t.Fatalf("Expected the post to stay live, got %v", err)
+	}
+
+	if code := send(deletePostHandler, deleteReq(), "user1"); code != http.StatusOK {
+		t.Fatalf("Expected the author to delete the post, got %d", code)
+	}
+	body, _ := json.Marshal(map[string]string{"post_id": post.ID, "user_id": "user1"})
+	restoreReq := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/post/restore", bytes.NewReader(body))
+	}
+	if code := send(restorePostHandler, restoreReq(), "user2"); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 restoring someone else's post, got %d", code)
+	}
+	if code := send(restorePostHandler, restoreReq(), "user1"); code != http.StatusOK {
+		t.Errorf("Expected the author to restore the post, got %d", code)
+	}
+}
+
 func TestHealthHandler(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	w := httptest.NewRecorder()
@@ -332,3 +769,573 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+
+func TestCreatePost_PublishesEvent(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+	sub := service.Events().Subscribe(TopicPostCreated)
+
+	post, _ := service.CreatePost("user1", "Hello world")
+
+	select {
+	case event := <-sub:
+		published, ok := event.Payload.(*Post)
+		if !ok {
+			t.Fatalf("Expected *Post payload, got %T", event.Payload)
+		}
+		if published.ID != post.ID || published.Content != "Hello world" {
+			t.Errorf("Unexpected payload %+v", published)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected post.created event")
+	}
+}
+
+func TestPostIndexer_ConsumesEvents(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+
+	sub := service.Events().Subscribe(TopicPostCreated)
+	indexer := NewPostIndexer()
+	done := make(chan struct{})
+	go func() {
+		indexer.Run(sub)
+		close(done)
+	}()
+
+	first, _ := service.CreatePost("user1", "Go is fun")
+	second, _ := service.CreatePost("user1", "Kubernetes and go")
+	service.CreatePost("user1", "Something else")
+
+	service.Events().Unsubscribe(TopicPostCreated, sub)
+	<-done
+
+	results := indexer.Search("GO")
+	if len(results) != 2 || results[0] != first.ID || results[1] != second.ID {
+		t.Errorf("Expected [%s %s], got %v", first.ID, second.ID, results)
+	}
+	if results := indexer.Search("missing"); len(results) != 0 {
+		t.Errorf("Expected no results, got %v", results)
+	}
+}
+
+func TestCreatePostHandler_UsesAuthenticatedUser(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+	service.CreateUser("user2", "bob")
+
+	secret := []byte("test-secret")
+	handler := middleware.JWTAuth(secret)(http.HandlerFunc(createPostHandler))
+	token, _ := middleware.SignToken("user1", time.Hour, secret)
+
+	body, _ := json.Marshal(map[string]string{"user_id": "user2", "content": "Hello"})
+	req := httptest.NewRequest(http.MethodPost, "/post/create", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var post Post
+	json.NewDecoder(w.Body).Decode(&post)
+	if post.UserID != "user1" {
+		t.Errorf("Expected post by token user user1, got %s", post.UserID)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/post/create", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", w.Code)
+	}
+}
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewNewsfeedService()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
+
+func TestCreatePost_IdempotencyKey(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "testuser")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"user_id": "user1", "content": "Hello"})
+		req := httptest.NewRequest(http.MethodPost, "/post/create", bytes.NewReader(body))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send()
+	second := send()
+
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 twice, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("Expected identical responses, got %q and %q", first.Body.String(), second.Body.String())
+	}
+	posts, _ := service.GetUserPosts("user1")
+	if len(posts) != 1 {
+		t.Errorf("Expected 1 post, got %d", len(posts))
+	}
+}
+
+func TestBatch_CreateFollowAndRead(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user2", "testuser2")
+	service.CreatePost("user2", "Hello from user2")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	body := `[
+		{"method": "POST", "path": "/user/create", "body": {"user_id": "user1", "username": "testuser1"}},
+		{"method": "POST", "path": "/user/follow", "body": {"follower_id": "user1", "followee_id": "user2"}},
+		{"method": "GET", "path": "/newsfeed?user_id=user1"},
+		{"method": "GET", "path": "/user/get?user_id=missing"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var responses []struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&responses); err != nil {
+		t.Fatalf("Expected a JSON array, got %v", err)
+	}
+	if len(responses) != 4 {
+		t.Fatalf("Expected 4 responses, got %d", len(responses))
+	}
+
+	var user User
+	json.Unmarshal(responses[0].Body, &user)
+	if responses[0].Status != http.StatusOK || user.ID != "user1" {
+		t.Errorf("Expected user1 to be created, got %d %s", responses[0].Status, responses[0].Body)
+	}
+	if responses[1].Status != http.StatusOK {
+		t.Errorf("Expected follow to succeed, got %d %s", responses[1].Status, responses[1].Body)
+	}
+
+	var feed []Post
+	json.Unmarshal(responses[2].Body, &feed)
+	if responses[2].Status != http.StatusOK || len(feed) != 1 || feed[0].Content != "Hello from user2" {
+		t.Errorf("Expected the followed user's post in the feed, got %d %s", responses[2].Status, responses[2].Body)
+	}
+	if responses[3].Status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing user, got %d", responses[3].Status)
+	}
+}
+
+// sameJSON compares values by their encoding, since timestamps lose their
+// monotonic clock reading in a round trip
+func sameJSON(a, b interface{}) bool {
+	encodedA, _ := json.Marshal(a)
+	encodedB, _ := json.Marshal(b)
+	return bytes.Equal(encodedA, encodedB)
+}
+
+func TestSnapshotRestore_RoundTrip(t *testing.T) {
+	original := NewNewsfeedService()
+	original.CreateUser("alice", "Alice")
+	original.CreateUser("bob", "Bob")
+	original.CreateUser("carol", "Carol")
+	original.Follow("alice", "bob")
+	original.Follow("alice", "carol")
+	original.Follow("bob", "carol")
+
+	first, _ := original.CreatePost("bob", "first")
+	original.CreatePost("carol", "second")
+	third, _ := original.CreatePost("bob", "third")
+	original.LikePost(first.ID)
+	original.SharePost(first.ID)
+	original.DeletePost(third.ID)
+
+	data, err := original.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restored := NewNewsfeedService()
+	restored.CreateUser("stale", "Overwritten")
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := restored.GetUser("stale"); err == nil {
+		t.Error("Expected Restore to replace the existing state")
+	}
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		for _, mode := range []string{SortRecent, SortTop} {
+			want, _ := original.GetRankedNewsfeed(context.Background(), userID, 50, mode)
+			got, _ := restored.GetRankedNewsfeed(context.Background(), userID, 50, mode)
+			if !sameJSON(want, got) {
+				t.Errorf("%s %s feed: expected %v, got %v", userID, mode, feedContents(want), feedContents(got))
+			}
+		}
+
+		want, _ := original.GetUserPosts(userID)
+		got, _ := restored.GetUserPosts(userID)
+		if !sameJSON(want, got) {
+			t.Errorf("%s posts: expected %v, got %v", userID, feedContents(want), feedContents(got))
+		}
+		wantUser, _ := original.GetUser(userID)
+		gotUser, _ := restored.GetUser(userID)
+		if !sameJSON(wantUser, gotUser) {
+			t.Errorf("Expected user %+v, got %+v", wantUser, gotUser)
+		}
+	}
+
+	// The soft-deleted post is still restorable and new IDs don't collide
+	if _, err := restored.RestorePost(third.ID); err != nil {
+		t.Errorf("Expected the deleted post to be restorable, got %v", err)
+	}
+	post, _ := restored.CreatePost("alice", "fourth")
+	if post.ID != "post_4" {
+		t.Errorf("Expected the ID counter to carry over, got %s", post.ID)
+	}
+}
+
+func TestRestore_RejectsInconsistentState(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("alice", "Alice")
+
+	for _, data := range []string{
+		`{"users":[{"id":"bob","following":["ghost"]}]}`,
+		`{"users":[{"id":"bob"}],"posts":[{"id":"post_1","user_id":"ghost"}]}`,
+		`{"users":[{"id":"bob"},{"id":"bob"}]}`,
+		`not json`,
+	} {
+		if err := service.Restore([]byte(data)); err == nil {
+			t.Errorf("Expected %s to be rejected", data)
+		}
+	}
+
+	if _, err := service.GetUser("alice"); err != nil {
+		t.Error("Expected a failed restore to keep the current state")
+	}
+}
+
+func TestAdminStateHandler(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	service = NewNewsfeedService()
+	service.CreateUser("alice", "Alice")
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/state", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without the admin token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/state", nil)
+	req.Header.Set(middleware.AdminTokenHeader, "s3cret")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"alice"`) {
+		t.Fatalf("Expected the state dump, got %d %s", w.Code, w.Body.String())
+	}
+	dump := w.Body.String()
+
+	service.CreateUser("bob", "Bob")
+	req = httptest.NewRequest(http.MethodPut, "/admin/state", strings.NewReader(dump))
+	req.Header.Set(middleware.AdminTokenHeader, "s3cret")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := service.GetUser("bob"); err == nil {
+		t.Error("Expected bob to be gone after restoring the earlier dump")
+	}
+}
+
+// newDiscoverFixture has user1 discovering posts by user2 with engagement
+// 0 to 3, so sampling weights 1 to 4, plus posts by user1 and user3 that
+// must never be drawn once user3 is blocked
+func newDiscoverFixture(t *testing.T) *NewsfeedService {
+	t.Helper()
+
+	service := NewNewsfeedService()
+	service.SetRandSource(rand.NewSource(42))
+	service.CreateUser("user1", "reader")
+	service.CreateUser("user2", "writer")
+	service.CreateUser("user3", "spammer")
+
+	for likes := int64(0); likes < 4; likes++ {
+		post, _ := service.CreatePost("user2", fmt.Sprintf("w%d", likes+1))
+		post.Likes = likes
+	}
+	service.CreatePost("user1", "own")
+	spam, _ := service.CreatePost("user3", "spam")
+	spam.Shares = 1000
+
+	if err := service.Block("user1", "user3"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return service
+}
+
+func TestGetDiscoverFeed_TracksWeights(t *testing.T) {
+	service := newDiscoverFixture(t)
+
+	const runs = 20000
+	counts := make(map[string]int)
+	for i := 0; i < runs; i++ {
+		feed, err := service.GetDiscoverFeed("user1", 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(feed) != 1 {
+			t.Fatalf("Expected 1 post, got %d", len(feed))
+		}
+		counts[feed[0].Content]++
+	}
+
+	for content, weight := range map[string]float64{"w1": 1, "w2": 2, "w3": 3, "w4": 4} {
+		expected := weight / 10
+		got := float64(counts[content]) / runs
+		if math.Abs(got-expected) > 0.02 {
+			t.Errorf("Expected %s drawn about %.2f of the time, got %.3f", content, expected, got)
+		}
+	}
+	if counts["own"] != 0 || counts["spam"] != 0 {
+		t.Errorf("Expected own and blocked posts to be excluded, got %v", counts)
+	}
+}
+
+func TestGetDiscoverFeed_SampleWithoutReplacement(t *testing.T) {
+	service := newDiscoverFixture(t)
+
+	feed, _ := service.GetDiscoverFeed("user1", 10)
+	got := feedContents(feed)
+	sort.Strings(got)
+	if strings.Join(got, ",") != "w1,w2,w3,w4" {
+		t.Errorf("Expected every eligible post once, got %v", got)
+	}
+
+	// Unblocking lets user3's post back in
+	service.Unblock("user1", "user3")
+	feed, _ = service.GetDiscoverFeed("user1", 10)
+	if len(feed) != 5 {
+		t.Errorf("Expected 5 posts after unblocking, got %d", len(feed))
+	}
+}
+
+func TestGetDiscoverFeed_SeededIsDeterministic(t *testing.T) {
+	first, _ := newDiscoverFixture(t).GetDiscoverFeed("user1", 3)
+	second, _ := newDiscoverFixture(t).GetDiscoverFeed("user1", 3)
+
+	if strings.Join(feedContents(first), ",") != strings.Join(feedContents(second), ",") {
+		t.Errorf("Expected the same seed to give the same feed, got %v and %v", feedContents(first), feedContents(second))
+	}
+}
+
+func TestDiscoverHandler(t *testing.T) {
+	service = newDiscoverFixture(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantPosts  int
+	}{
+		{"default limit", "?user_id=user1", http.StatusOK, 4},
+		{"explicit limit", "?user_id=user1&limit=2", http.StatusOK, 2},
+		{"missing user", "", http.StatusBadRequest, 0},
+		{"bad limit", "?user_id=user1&limit=1000", http.StatusBadRequest, 0},
+		{"unknown user", "?user_id=nobody", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/discover"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			getDiscoverFeedHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var posts []*Post
+			json.NewDecoder(w.Body).Decode(&posts)
+			if len(posts) != tt.wantPosts {
+				t.Errorf("Expected %d posts, got %d", tt.wantPosts, len(posts))
+			}
+		})
+	}
+}
+
+func TestSchedulePost_PublishedByScheduler(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "reader")
+	service.CreateUser("user2", "writer")
+	service.Follow("user1", "user2")
+	created := service.Events().Subscribe(TopicPostCreated)
+
+	post, err := service.SchedulePost("user2", "later", time.Now().Add(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	feed, _ := service.GetNewsfeed(context.Background(), "user1", 10)
+	posts, _ := service.GetUserPosts("user2")
+	if len(feed) != 0 || len(posts) != 0 {
+		t.Fatalf("Expected scheduled post to be hidden, got feed %d and user posts %d", len(feed), len(posts))
+	}
+	if _, err := service.GetPost(post.ID); err == nil {
+		t.Error("Expected scheduled post to be hidden from lookups")
+	}
+
+	service.StartScheduler(10 * time.Millisecond)
+
+	select {
+	case event := <-created:
+		if event.Payload.(*Post).ID != post.ID {
+			t.Errorf("Expected post.created for %s, got %v", post.ID, event.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the scheduler to publish the post")
+	}
+
+	feed, _ = service.GetNewsfeed(context.Background(), "user1", 10)
+	if len(feed) != 1 || feed[0].ID != post.ID {
+		t.Errorf("Expected the post in the feed after publishing, got %v", feedContents(feed))
+	}
+	if !time.Now().After(post.PublishAt) {
+		t.Error("Expected the post to be published no earlier than publish_at")
+	}
+}
+
+func TestSchedulePost_Errors(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "writer")
+
+	if _, err := service.SchedulePost("user1", "too late", time.Now().Add(-time.Minute)); err != ErrPublishTimeInPast {
+		t.Errorf("Expected ErrPublishTimeInPast, got %v", err)
+	}
+	if _, err := service.SchedulePost("nobody", "x", time.Now().Add(time.Minute)); err == nil {
+		t.Error("Expected error for unknown user")
+	}
+}
+
+func TestCancelScheduledPost(t *testing.T) {
+	service := NewNewsfeedService()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	service.CreateUser("user1", "writer")
+	service.CreateUser("user2", "reader")
+
+	scheduled, _ := service.SchedulePost("user1", "never", now.Add(time.Hour))
+	live, _ := service.CreatePost("user1", "now")
+
+	if err := service.CancelScheduledPost(scheduled.ID, "user2"); err != ErrNotAuthor {
+		t.Errorf("Expected ErrNotAuthor for someone else's post, got %v", err)
+	}
+	if err := service.CancelScheduledPost(scheduled.ID, "user1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.CancelScheduledPost(live.ID, "user1"); err != ErrPostNotScheduled {
+		t.Errorf("Expected ErrPostNotScheduled, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if published := service.PublishDuePosts(); published != 0 {
+		t.Errorf("Expected a cancelled post not to publish, got %d", published)
+	}
+	if posts, _ := service.GetUserPosts("user1"); len(posts) != 1 {
+		t.Errorf("Expected only the live post, got %d", len(posts))
+	}
+}
+
+func TestScheduleHandlers(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "writer")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"user_id":    "user1",
+		"content":    "soon",
+		"publish_at": time.Now().Add(time.Hour),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/post/schedule", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	schedulePostHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var post Post
+	json.NewDecoder(w.Body).Decode(&post)
+	if !post.Scheduled {
+		t.Errorf("Expected a scheduled post, got %+v", post)
+	}
+
+	cancel := func(userID string) int {
+		body, _ := json.Marshal(map[string]string{"post_id": post.ID, "user_id": userID})
+		req := httptest.NewRequest(http.MethodPost, "/post/cancel-scheduled", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		cancelScheduledHandler(w, req)
+		return w.Code
+	}
+	if code := cancel("user2"); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for someone else's post, got %d", code)
+	}
+	if code := cancel("user1"); code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+	if code := cancel("user1"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 cancelling twice, got %d", code)
+	}
+}
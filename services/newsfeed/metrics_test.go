@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler_CountsPosts(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("author", "author")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	before := postsCreated.Value()
+	service.CreatePost("author", "hello")
+	service.SchedulePost("author", "later", time.Now().Add(time.Hour))
+	service.CreatePost("author", "   ") // rejected, not counted
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	want := fmt.Sprintf("newsfeed_posts_created_total %d\n", before+2)
+	if body := w.Body.String(); !strings.Contains(body, want) {
+		t.Errorf("Expected %q in\n%s", want, body)
+	}
+}
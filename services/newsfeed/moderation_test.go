@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"common/moderation"
+)
+
+func TestCreatePost_ContentFilter(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+	service.SetContentFilter(moderation.NewWordFilter(100, "spam"))
+
+	if _, err := service.CreatePost("user1", "Lovely weather"); err != nil {
+		t.Fatalf("Expected clean post to be created, got %v", err)
+	}
+	if _, err := service.CreatePost("user1", "Click for spam"); !errors.Is(err, moderation.ErrRejected) {
+		t.Errorf("Expected ErrRejected, got %v", err)
+	}
+	if _, err := service.SchedulePost("user1", "Later spam", time.Now().Add(time.Hour)); !errors.Is(err, moderation.ErrRejected) {
+		t.Errorf("Expected scheduled posts to be filtered too, got %v", err)
+	}
+	if len(service.posts) != 1 {
+		t.Errorf("Expected rejected posts not to be stored, have %d posts", len(service.posts))
+	}
+}
+
+func TestCreatePost_FilterRunsBeforeStore(t *testing.T) {
+	service := NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+
+	calls := 0
+	service.SetContentFilter(moderation.FilterFunc(func(text string) (bool, string) {
+		calls++
+		if len(service.posts) != 0 {
+			t.Error("Expected the filter to run before the post is stored")
+		}
+		return false, "no posting today"
+	}))
+
+	if _, err := service.CreatePost("user1", "hello"); err == nil {
+		t.Fatal("Expected the filter to reject the post")
+	}
+	if calls != 1 {
+		t.Errorf("Expected the filter to be consulted once, got %d", calls)
+	}
+	if service.postIndex != 0 {
+		t.Error("Expected a rejected post not to use up an id")
+	}
+}
+
+func TestCreatePostHandler_Rejected(t *testing.T) {
+	service = NewNewsfeedService()
+	service.CreateUser("user1", "alice")
+	service.SetContentFilter(moderation.NewWordFilter(100, "spam"))
+
+	body, _ := json.Marshal(createPostRequest{UserID: "user1", Content: "spam"})
+	req := httptest.NewRequest(http.MethodPost, "/post/create", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	createPostHandler(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("banned word")) {
+		t.Errorf("Expected the reason in the response, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"common/apierror"
+	"common/middleware"
+)
+
+// maxNotificationsPerUser bounds each user's notifications; the oldest are
+// dropped first
+const maxNotificationsPerUser = 500
+
+// Notification types
+const (
+	NotifyPost    = "post"    // someone you follow posted
+	NotifyLike    = "like"    // someone liked your post
+	NotifyComment = "comment" // someone commented on your post
+)
+
+// ErrNotificationNotFound is returned when a user has no notification with
+// the given ID
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// Notification tells a user about activity that concerns them
+type Notification struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	ActorID   string    `json:"actor_id,omitempty"` // empty for anonymous likes and comments
+	PostID    string    `json:"post_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Read      bool      `json:"read"`
+}
+
+// notifyLocked stores a notification for recipient. Users aren't notified
+// of their own actions or of actions by users they've blocked. Must be
+// called with s.mu held.
+func (s *NewsfeedService) notifyLocked(recipientID, kind, actorID, postID string) {
+	if recipientID == actorID {
+		return
+	}
+	recipient, exists := s.users[recipientID]
+	if !exists || (actorID != "" && slices.Contains(recipient.Blocked, actorID)) {
+		return
+	}
+
+	s.notificationIndex++
+	notifications := append(s.notifications[recipientID], &Notification{
+		ID:        fmt.Sprintf("notif_%d", s.notificationIndex),
+		Type:      kind,
+		ActorID:   actorID,
+		PostID:    postID,
+		Timestamp: s.now(),
+	})
+	if len(notifications) > maxNotificationsPerUser {
+		notifications = notifications[len(notifications)-maxNotificationsPerUser:]
+	}
+	s.notifications[recipientID] = notifications
+}
+
+// notifyFollowersLocked tells the author's followers about a newly visible
+// post. Must be called with s.mu held.
+func (s *NewsfeedService) notifyFollowersLocked(post *Post) {
+	author, exists := s.users[post.UserID]
+	if !exists {
+		return
+	}
+	for _, followerID := range author.Followers {
+		s.notifyLocked(followerID, NotifyPost, post.UserID, post.ID)
+	}
+}
+
+// notifyEngagementLocked tells a post's author about a like or comment.
+// Must be called with s.mu held.
+func (s *NewsfeedService) notifyEngagementLocked(post *Post, e engagement) {
+	switch e.kind {
+	case engageLike:
+		s.notifyLocked(post.UserID, NotifyLike, e.actorID, post.ID)
+	case engageComment:
+		s.notifyLocked(post.UserID, NotifyComment, e.actorID, post.ID)
+	}
+}
+
+// GetNotifications returns a user's notifications, newest first. With
+// unreadOnly it leaves out the ones already marked read.
+func (s *NewsfeedService) GetNotifications(userID string, unreadOnly bool) ([]*Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.users[userID]; !exists {
+		return nil, ErrUserNotFound
+	}
+
+	stored := s.notifications[userID]
+	notifications := make([]*Notification, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		if unreadOnly && stored[i].Read {
+			continue
+		}
+		// Copy so callers don't race with MarkNotificationRead
+		n := *stored[i]
+		notifications = append(notifications, &n)
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func (s *NewsfeedService) MarkNotificationRead(userID, notificationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[userID]; !exists {
+		return ErrUserNotFound
+	}
+	for _, n := range s.notifications[userID] {
+		if n.ID == notificationID {
+			n.Read = true
+			return nil
+		}
+	}
+	return ErrNotificationNotFound
+}
+
+// markNotificationReadRequest is the body of POST /notifications
+type markNotificationReadRequest struct {
+	UserID         string `json:"user_id"`
+	NotificationID string `json:"notification_id"`
+}
+
+func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		userID := middleware.AuthenticatedUserID(r, query.Get("user_id"))
+		if userID == "" {
+			apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
+			return
+		}
+		unreadOnly, _ := strconv.ParseBool(query.Get("unread"))
+
+		notifications, err := service.GetNotifications(userID, unreadOnly)
+		if err != nil {
+			apierror.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notifications)
+
+	case http.MethodPost:
+		var req markNotificationReadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		userID := middleware.AuthenticatedUserID(r, req.UserID)
+		if userID == "" || req.NotificationID == "" {
+			apierror.Error(w, "user_id and notification_id are required", http.StatusBadRequest)
+			return
+		}
+
+		if err := service.MarkNotificationRead(userID, req.NotificationID); err != nil {
+			apierror.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// notificationFixture returns a service where alice follows author
+func notificationFixture(t *testing.T) *NewsfeedService {
+	t.Helper()
+	s := NewNewsfeedService()
+	for _, id := range []string{"author", "alice", "bob"} {
+		if _, err := s.CreateUser(id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Follow("alice", "author"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNotifications_FollowerIsNotifiedOfPost(t *testing.T) {
+	s := notificationFixture(t)
+
+	post, err := s.CreatePost("author", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetNotifications("alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Type != NotifyPost || got[0].ActorID != "author" || got[0].PostID != post.ID {
+		t.Fatalf("Expected one post notification from author, got %+v", got)
+	}
+
+	if got, _ := s.GetNotifications("bob", false); len(got) != 0 {
+		t.Errorf("Expected no notifications for a non-follower, got %+v", got)
+	}
+	if got, _ := s.GetNotifications("author", false); len(got) != 0 {
+		t.Errorf("Expected the author not to be notified of their own post, got %+v", got)
+	}
+}
+
+func TestNotifications_OwnerIsNotifiedOfEngagement(t *testing.T) {
+	s := notificationFixture(t)
+	post, _ := s.CreatePost("author", "hello")
+
+	if err := s.LikePostBy(post.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CommentPostBy(post.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := s.GetNotifications("author", false)
+	if len(got) != 2 {
+		t.Fatalf("Expected two notifications, got %+v", got)
+	}
+	if got[0].Type != NotifyComment || got[0].ActorID != "alice" {
+		t.Errorf("Expected the newest notification to be alice's comment, got %+v", got[0])
+	}
+	if got[1].Type != NotifyLike || got[1].ActorID != "bob" || got[1].PostID != post.ID {
+		t.Errorf("Expected bob's like, got %+v", got[1])
+	}
+}
+
+func TestNotifications_NoSelfNotification(t *testing.T) {
+	s := notificationFixture(t)
+	post, _ := s.CreatePost("author", "hello")
+
+	s.LikePostBy(post.ID, "author")
+	s.CommentPostBy(post.ID, "author")
+	if got, _ := s.GetNotifications("author", false); len(got) != 0 {
+		t.Errorf("Expected no notifications, got %+v", got)
+	}
+
+	s.LikePost(post.ID)
+	if got, _ := s.GetNotifications("author", false); len(got) != 1 || got[0].ActorID != "" {
+		t.Errorf("Expected one anonymous like notification, got %+v", got)
+	}
+	if post.Likes != 2 {
+		t.Errorf("Expected the likes to be counted, got %d", post.Likes)
+	}
+}
+
+func TestNotifications_EngagementWorkersNotify(t *testing.T) {
+	s := notificationFixture(t)
+	post, _ := s.CreatePost("author", "hello")
+
+	s.StartEngagementWorkers(EngagementConfig{})
+	if err := s.LikePostBy(post.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	s.DrainEngagement()
+
+	if got, _ := s.GetNotifications("author", false); len(got) != 1 || got[0].ActorID != "bob" {
+		t.Errorf("Expected bob's like to notify once drained, got %+v", got)
+	}
+}
+
+func TestNotifications_UnknownActor(t *testing.T) {
+	s := notificationFixture(t)
+	post, _ := s.CreatePost("author", "hello")
+
+	if err := s.LikePostBy(post.ID, "ghost"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+	s := notificationFixture(t)
+	s.CreatePost("author", "first")
+	s.CreatePost("author", "second")
+
+	all, _ := s.GetNotifications("alice", true)
+	if len(all) != 2 {
+		t.Fatalf("Expected two unread notifications, got %+v", all)
+	}
+
+	if err := s.MarkNotificationRead("alice", all[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if unread, _ := s.GetNotifications("alice", true); len(unread) != 1 || unread[0].ID != all[1].ID {
+		t.Errorf("Expected only the older notification unread, got %+v", unread)
+	}
+	if got, _ := s.GetNotifications("alice", false); len(got) != 2 || !got[0].Read {
+		t.Errorf("Expected both notifications with the newest read, got %+v", got)
+	}
+
+	if err := s.MarkNotificationRead("alice", "notif_missing"); err != ErrNotificationNotFound {
+		t.Errorf("Expected ErrNotificationNotFound, got %v", err)
+	}
+	if _, err := s.GetNotifications("ghost", false); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestNotifications_SnapshotRoundTrip(t *testing.T) {
+	s := notificationFixture(t)
+	s.CreatePost("author", "hello")
+
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNewsfeedService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+
+	before, _ := s.GetNotifications("alice", false)
+	after, _ := restored.GetNotifications("alice", false)
+	if len(after) != 1 || after[0].ID != before[0].ID {
+		t.Fatalf("Expected the notification to be restored, got %+v", after)
+	}
+
+	restored.CreatePost("author", "again")
+	if got, _ := restored.GetNotifications("alice", false); got[0].ID == before[0].ID {
+		t.Errorf("Expected a fresh ID after restoring, got %s twice", got[0].ID)
+	}
+}
+
+func TestNotificationsHandler(t *testing.T) {
+	service = notificationFixture(t)
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	post, _ := service.CreatePost("author", "hello")
+	if w := do(http.MethodPost, "/post/like", `{"post_id":"`+post.ID+`","user_id":"bob"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 liking, got %d: %s", w.Code, w.Body)
+	}
+
+	var got []Notification
+	w := do(http.MethodGet, "/notifications?user_id=author&unread=true", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&got)
+	if len(got) != 1 || got[0].Type != NotifyLike || got[0].ActorID != "bob" {
+		t.Fatalf("Expected bob's like, got %+v", got)
+	}
+
+	if w := do(http.MethodPost, "/notifications", `{"user_id":"author","notification_id":"`+got[0].ID+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 marking read, got %d: %s", w.Code, w.Body)
+	}
+	got = nil
+	json.NewDecoder(do(http.MethodGet, "/notifications?user_id=author&unread=true", "").Body).Decode(&got)
+	if len(got) != 0 {
+		t.Errorf("Expected no unread notifications, got %+v", got)
+	}
+
+	if w := do(http.MethodGet, "/notifications", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without user_id, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/notifications?user_id=ghost", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/notifications", `{"user_id":"author","notification_id":"nope"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown notification, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/notifications", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxPostLength is the longest post, in characters, accepted by
+// default
+const DefaultMaxPostLength = 5000
+
+var (
+	// ErrEmptyPost is returned for post content that is empty or only
+	// whitespace
+	ErrEmptyPost = errors.New("post content is empty")
+	// ErrPostTooLong is returned for post content over the length limit
+	// when truncation is off. The returned error wraps it with the limit.
+	ErrPostTooLong = errors.New("post content is too long")
+)
+
+// PostLimits bounds the content of new posts
+type PostLimits struct {
+	// MaxLength is the most characters a post may have; 0 means no limit
+	MaxLength int
+	// Truncate stores the first MaxLength characters of a longer post,
+	// marked Truncated, instead of rejecting it
+	Truncate bool
+}
+
+// DefaultPostLimits rejects posts over DefaultMaxPostLength characters
+func DefaultPostLimits() PostLimits {
+	return PostLimits{MaxLength: DefaultMaxPostLength}
+}
+
+// postLimitsFromEnv returns DefaultPostLimits overridden by POST_MAX_LENGTH
+// and POST_TRUNCATE when they are set
+func postLimitsFromEnv() PostLimits {
+	limits := DefaultPostLimits()
+	if n, err := strconv.Atoi(os.Getenv("POST_MAX_LENGTH")); err == nil && n >= 0 {
+		limits.MaxLength = n
+	}
+	if truncate, err := strconv.ParseBool(os.Getenv("POST_TRUNCATE")); err == nil {
+		limits.Truncate = truncate
+	}
+	return limits
+}
+
+// SetPostLimits replaces the limits new posts are checked against
+func (s *NewsfeedService) SetPostLimits(limits PostLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.postLimits = limits
+}
+
+// prepareContent applies the post limits and content filter to content
+// and returns what to store, and whether it was truncated to fit. The
+// filter sees the stored version.
+func (s *NewsfeedService) prepareContent(content string) (string, bool, error) {
+	s.mu.RLock()
+	limits := s.postLimits
+	s.mu.RUnlock()
+
+	if strings.TrimSpace(content) == "" {
+		return "", false, ErrEmptyPost
+	}
+
+	truncated := false
+	if n := utf8.RuneCountInString(content); limits.MaxLength > 0 && n > limits.MaxLength {
+		if !limits.Truncate {
+			return "", false, fmt.Errorf("%w: %d characters, the limit is %d", ErrPostTooLong, n, limits.MaxLength)
+		}
+		content = truncateRunes(content, limits.MaxLength)
+		truncated = true
+	}
+
+	if err := s.checkContent(content); err != nil {
+		return "", false, err
+	}
+	return content, truncated, nil
+}
+
+// truncateRunes returns the first n characters of text
+func truncateRunes(text string, n int) string {
+	for i := range text {
+		if n == 0 {
+			return text[:i]
+		}
+		n--
+	}
+	return text
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postLimitsFixture(t *testing.T, limits PostLimits) *NewsfeedService {
+	t.Helper()
+	s := NewNewsfeedService()
+	s.SetPostLimits(limits)
+	if _, err := s.CreateUser("author", "author"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCreatePost_UnderLimit(t *testing.T) {
+	s := postLimitsFixture(t, PostLimits{MaxLength: 5})
+
+	post, err := s.CreatePost("author", "héllo")
+	if err != nil {
+		t.Fatalf("Expected five characters to be accepted, got %v", err)
+	}
+	if post.Content != "héllo" || post.Truncated {
+		t.Errorf("Expected the content stored as is, got %+v", post)
+	}
+}
+
+func TestCreatePost_OverLimitRejected(t *testing.T) {
+	s := postLimitsFixture(t, PostLimits{MaxLength: 5})
+
+	_, err := s.CreatePost("author", "héllo!")
+	if !errors.Is(err, ErrPostTooLong) {
+		t.Fatalf("Expected ErrPostTooLong, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "limit is 5") {
+		t.Errorf("Expected the error to name the limit, got %q", err)
+	}
+	if _, err := s.SchedulePost("author", "héllo!", time.Now().Add(time.Hour)); !errors.Is(err, ErrPostTooLong) {
+		t.Errorf("Expected scheduled posts to be limited too, got %v", err)
+	}
+}
+
+func TestCreatePost_TruncateMode(t *testing.T) {
+	s := postLimitsFixture(t, PostLimits{MaxLength: 5, Truncate: true})
+
+	post, err := s.CreatePost("author", "héllo wörld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Content != "héllo" || !post.Truncated {
+		t.Errorf("Expected the first five characters marked truncated, got %+v", post)
+	}
+
+	short, _ := s.CreatePost("author", "hi")
+	if short.Truncated {
+		t.Errorf("Expected a post within the limit not to be marked truncated")
+	}
+}
+
+func TestCreatePost_WhitespaceOnlyRejected(t *testing.T) {
+	s := postLimitsFixture(t, DefaultPostLimits())
+
+	for _, content := range []string{"", "   ", "\n\t  "} {
+		if _, err := s.CreatePost("author", content); !errors.Is(err, ErrEmptyPost) {
+			t.Errorf("Expected ErrEmptyPost for %q, got %v", content, err)
+		}
+	}
+}
+
+func TestCreatePostHandler_SurfacesLimit(t *testing.T) {
+	service = postLimitsFixture(t, PostLimits{MaxLength: 3})
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/post/create", strings.NewReader(`{"user_id":"author","content":"toolong"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if !strings.Contains(body.Error.Message, "limit is 3") {
+		t.Errorf("Expected the error to name the limit, got %q", body.Error.Message)
+	}
+}
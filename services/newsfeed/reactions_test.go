@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reactionFixture returns a service with a post by author and users alice
+// and bob
+func reactionFixture(t *testing.T) (*NewsfeedService, *Post) {
+	t.Helper()
+	s := NewNewsfeedService()
+	for _, id := range []string{"author", "alice", "bob"} {
+		if _, err := s.CreateUser(id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	post, err := s.CreatePost("author", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, post
+}
+
+func TestAddReaction_SwitchingMovesTheCount(t *testing.T) {
+	s, post := reactionFixture(t)
+
+	s.AddReaction(post.ID, "alice", "👍")
+	s.AddReaction(post.ID, "bob", "👍")
+	if got, _ := s.GetReactions(post.ID); !reflect.DeepEqual(got, map[string]int{"👍": 2}) {
+		t.Fatalf("Expected two thumbs up, got %v", got)
+	}
+
+	if err := s.AddReaction(post.ID, "alice", "❤️"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"👍": 1, "❤️": 1}
+	if got, _ := s.GetReactions(post.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after switching, got %v", want, got)
+	}
+	if post.Likes != 0 {
+		t.Errorf("Expected reactions to leave likes alone, got %d", post.Likes)
+	}
+}
+
+func TestRemoveReaction_NeverGoesNegative(t *testing.T) {
+	s, post := reactionFixture(t)
+
+	s.AddReaction(post.ID, "alice", "👍")
+	s.AddReaction(post.ID, "bob", "❤️")
+
+	if err := s.RemoveReaction(post.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetReactions(post.ID); !reflect.DeepEqual(got, map[string]int{"❤️": 1}) {
+		t.Errorf("Expected only bob's heart left, got %v", got)
+	}
+
+	// Removing again, or for a user who never reacted, changes nothing
+	s.RemoveReaction(post.ID, "alice")
+	s.RemoveReaction(post.ID, "author")
+	if got, _ := s.GetReactions(post.ID); !reflect.DeepEqual(got, map[string]int{"❤️": 1}) {
+		t.Errorf("Expected repeated removals to be no-ops, got %v", got)
+	}
+
+	s.RemoveReaction(post.ID, "bob")
+	if got, _ := s.GetReactions(post.ID); len(got) != 0 {
+		t.Errorf("Expected no reactions, got %v", got)
+	}
+}
+
+func TestAddReaction_Errors(t *testing.T) {
+	s, post := reactionFixture(t)
+
+	if err := s.AddReaction("post_missing", "alice", "👍"); err != ErrPostNotFound {
+		t.Errorf("Expected ErrPostNotFound, got %v", err)
+	}
+	if err := s.AddReaction(post.ID, "mallory", "👍"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if err := s.AddReaction(post.ID, "alice", ""); err != ErrEmptyReaction {
+		t.Errorf("Expected ErrEmptyReaction, got %v", err)
+	}
+}
+
+func TestReactions_SurviveSnapshot(t *testing.T) {
+	s, post := reactionFixture(t)
+	s.AddReaction(post.ID, "alice", "🎉")
+
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNewsfeedService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := restored.GetReactions(post.ID); !reflect.DeepEqual(got, map[string]int{"🎉": 1}) {
+		t.Errorf("Expected the reaction to be restored, got %v", got)
+	}
+}
+
+func TestReactHandlers(t *testing.T) {
+	var post *Post
+	service, post = reactionFixture(t)
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do(http.MethodPost, "/post/react", `{"post_id":"`+post.ID+`","user_id":"alice","emoji":"👍"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 reacting, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/post/react", `{"post_id":"`+post.ID+`","user_id":"alice","emoji":"❤️"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 switching, got %d: %s", w.Code, w.Body)
+	}
+
+	var counts map[string]int
+	json.NewDecoder(do(http.MethodGet, "/post/reactions?post_id="+post.ID, "").Body).Decode(&counts)
+	if !reflect.DeepEqual(counts, map[string]int{"❤️": 1}) {
+		t.Errorf("Expected one heart, got %v", counts)
+	}
+
+	if w := do(http.MethodDelete, "/post/react?post_id="+post.ID+"&user_id=alice", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 removing, got %d", w.Code)
+	}
+	counts = nil
+	json.NewDecoder(do(http.MethodGet, "/post/reactions?post_id="+post.ID, "").Body).Decode(&counts)
+	if len(counts) != 0 {
+		t.Errorf("Expected no reactions, got %v", counts)
+	}
+
+	if w := do(http.MethodPost, "/post/react", `{"post_id":"`+post.ID+`","user_id":"alice"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without an emoji, got %d", w.Code)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"common/index"
+)
+
+// ReadStore serves the per-author post lists feeds are built from. Feeds
+// and author timelines read from it, so it may be a replica that trails
+// the WriteStore.
+type ReadStore interface {
+	Get(userID string) []string // the author's live post IDs, oldest first
+	Has(userID, postID string) bool
+	Len(userID string) int
+}
+
+// WriteStore is the primary copy of the per-author post lists. Every
+// change is made here and is visible in its own reads immediately.
+// *index.Index[string] is both a ReadStore and a WriteStore.
+type WriteStore interface {
+	ReadStore
+	Add(userID, postID string)
+	Remove(userID, postID string) bool
+}
+
+// ReplicatedStore is a primary WriteStore that copies each write to an
+// in-memory replica lag after it was made, simulating asynchronous
+// replication. Writes reach the replica in the order they were made.
+type ReplicatedStore struct {
+	primary WriteStore
+	lag     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	replica *index.Index[string]
+	log     []replicatedWrite // not yet applied to the replica, oldest first
+}
+
+// replicatedWrite is a write waiting out the replication lag
+type replicatedWrite struct {
+	due   time.Time
+	apply func(replica *index.Index[string])
+}
+
+// NewReplicatedStore wraps primary with a replica that sees its writes lag
+// later
+func NewReplicatedStore(primary WriteStore, lag time.Duration) *ReplicatedStore {
+	return &ReplicatedStore{
+		primary: primary,
+		lag:     lag,
+		now:     time.Now,
+		replica: index.New[string](),
+	}
+}
+
+// Get reads the author's posts from the primary
+func (r *ReplicatedStore) Get(userID string) []string { return r.primary.Get(userID) }
+
+// Has reads from the primary
+func (r *ReplicatedStore) Has(userID, postID string) bool { return r.primary.Has(userID, postID) }
+
+// Len reads from the primary
+func (r *ReplicatedStore) Len(userID string) int { return r.primary.Len(userID) }
+
+// Add lists postID under userID on the primary now and on the replica
+// after the lag
+func (r *ReplicatedStore) Add(userID, postID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.primary.Add(userID, postID)
+	r.replicateLocked(func(replica *index.Index[string]) { replica.Add(userID, postID) })
+}
+
+// Remove unlists postID from userID on the primary now and on the replica
+// after the lag. It reports whether the primary listed it.
+func (r *ReplicatedStore) Remove(userID, postID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := r.primary.Remove(userID, postID)
+	r.replicateLocked(func(replica *index.Index[string]) { replica.Remove(userID, postID) })
+	return removed
+}
+
+// replicateLocked queues a write for the replica. Must be called with r.mu
+// held, so the log stays in the primary's write order.
+func (r *ReplicatedStore) replicateLocked(apply func(replica *index.Index[string])) {
+	r.log = append(r.log, replicatedWrite{due: r.now().Add(r.lag), apply: apply})
+}
+
+// Replica returns the lagging read side of the store
+func (r *ReplicatedStore) Replica() ReadStore {
+	return replicaStore{r}
+}
+
+// catchUp applies every queued write whose lag has passed
+func (r *ReplicatedStore) catchUp() *index.Index[string] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	applied := 0
+	for _, write := range r.log {
+		if write.due.After(now) {
+			break
+		}
+		write.apply(r.replica)
+		applied++
+	}
+	r.log = r.log[applied:]
+	return r.replica
+}
+
+// replicaStore reads a ReplicatedStore's replica, first applying the
+// writes that have had time to replicate
+type replicaStore struct {
+	r *ReplicatedStore
+}
+
+func (v replicaStore) Get(userID string) []string { return v.r.catchUp().Get(userID) }
+
+func (v replicaStore) Has(userID, postID string) bool { return v.r.catchUp().Has(userID, postID) }
+
+func (v replicaStore) Len(userID string) int { return v.r.catchUp().Len(userID) }
+
+// storesFromEnv returns the stores the service reads from and writes to.
+// When NEWSFEED_REPLICA_LAG is set to a duration, reads go to a replica
+// trailing the primary by that much; otherwise both are one index.
+func storesFromEnv() (ReadStore, WriteStore) {
+	primary := index.New[string]()
+	lag, err := time.ParseDuration(os.Getenv("NEWSFEED_REPLICA_LAG"))
+	if err != nil || lag < 0 {
+		return primary, primary
+	}
+	replicated := NewReplicatedStore(primary, lag)
+	return replicated.Replica(), replicated
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"common/index"
+)
+
+// replicatedFixture returns a service reading from a replica that trails
+// its primary by a minute, and a function that moves the store's clock
+func replicatedFixture(t *testing.T) (*NewsfeedService, *ReplicatedStore, func(time.Duration)) {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewReplicatedStore(index.New[string](), time.Minute)
+	store.now = func() time.Time { return now }
+
+	svc := NewNewsfeedServiceWithStores(store.Replica(), store)
+	svc.CreateUser("author", "author")
+	svc.CreateUser("reader", "reader")
+	svc.Follow("reader", "author")
+	return svc, store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestReplicatedStore_WriteReachesReplicaAfterLag(t *testing.T) {
+	svc, store, advance := replicatedFixture(t)
+
+	post, err := svc.CreatePost("author", "Hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !store.Has("author", post.ID) {
+		t.Error("Expected the post on the primary immediately")
+	}
+	if store.Replica().Has("author", post.ID) {
+		t.Error("Expected the post missing from the replica before the lag")
+	}
+	if posts, _ := svc.GetUserPosts("author"); len(posts) != 0 {
+		t.Errorf("Expected timeline reads from the replica to miss the post, got %v", posts)
+	}
+	if feed, _ := svc.GetNewsfeed(context.Background(), "reader", 0); len(feed) != 0 {
+		t.Errorf("Expected feed reads from the replica to miss the post, got %v", feed)
+	}
+
+	advance(59 * time.Second)
+	if store.Replica().Has("author", post.ID) {
+		t.Error("Expected the post missing from the replica just before the lag")
+	}
+
+	advance(time.Second)
+	if posts, _ := svc.GetUserPosts("author"); len(posts) != 1 || posts[0].ID != post.ID {
+		t.Errorf("Expected the post on the timeline after the lag, got %v", posts)
+	}
+	if feed, _ := svc.GetNewsfeed(context.Background(), "reader", 0); len(feed) != 1 || feed[0].ID != post.ID {
+		t.Errorf("Expected the post in the feed after the lag, got %v", feed)
+	}
+}
+
+func TestReplicatedStore_AppliesWritesInOrder(t *testing.T) {
+	svc, store, advance := replicatedFixture(t)
+
+	first, _ := svc.CreatePost("author", "First")
+	advance(30 * time.Second)
+	second, _ := svc.CreatePost("author", "Second")
+	svc.DeletePost(first.ID)
+	if store.Has("author", first.ID) {
+		t.Error("Expected the delete on the primary immediately")
+	}
+
+	advance(30 * time.Second)
+	if got := store.Replica().Get("author"); len(got) != 1 || got[0] != first.ID {
+		t.Errorf("Expected only the first post replicated, got %v", got)
+	}
+
+	advance(30 * time.Second)
+	if got := store.Replica().Get("author"); len(got) != 1 || got[0] != second.ID {
+		t.Errorf("Expected the delete and second post replicated, got %v", got)
+	}
+}
+
+func TestNewNewsfeedService_ReadsItsOwnWrites(t *testing.T) {
+	svc := NewNewsfeedService()
+	svc.CreateUser("author", "author")
+	post, _ := svc.CreatePost("author", "Hello")
+
+	if posts, _ := svc.GetUserPosts("author"); len(posts) != 1 || posts[0].ID != post.ID {
+		t.Errorf("Expected the post readable immediately, got %v", posts)
+	}
+}
+
+func TestRestore_RebuildsThroughWriteStore(t *testing.T) {
+	original := NewNewsfeedService()
+	original.CreateUser("author", "author")
+	post, _ := original.CreatePost("author", "Hello")
+	data, err := original.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	svc, store, advance := replicatedFixture(t)
+	svc.CreatePost("author", "Overwritten")
+	stale, _ := svc.CreatePost("author", "Stale")
+	if err := svc.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := store.Get("author"); len(got) != 1 || got[0] != post.ID {
+		t.Errorf("Expected the primary to hold only the restored post, got %v", got)
+	}
+
+	advance(time.Minute)
+	if store.Replica().Has("author", stale.ID) || !store.Replica().Has("author", post.ID) {
+		t.Errorf("Expected the replica to follow the restore, got %v", store.Replica().Get("author"))
+	}
+}
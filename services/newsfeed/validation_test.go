@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/validate"
+)
+
+func TestCreateHandlers_ReportEveryFieldError(t *testing.T) {
+	service = NewNewsfeedService()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    validate.Errors
+	}{
+		{"empty user", createUserHandler, `{}`, validate.Errors{
+			{Field: "user_id", Message: "required"},
+			{Field: "username", Message: "required"},
+		}},
+		{"long username", createUserHandler, `{"user_id":"u","username":"` + strings.Repeat("x", maxUsernameLength+1) + `"}`, validate.Errors{
+			{Field: "username", Message: "must be at most 50 characters"},
+		}},
+		{"empty post", createPostHandler, `{"content":""}`, validate.Errors{
+			{Field: "user_id", Message: "required"},
+			{Field: "content", Message: "required"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", w.Code)
+			}
+
+			var resp struct {
+				Error struct {
+					Details struct {
+						Fields validate.Errors `json:"fields"`
+					} `json:"details"`
+				} `json:"error"`
+			}
+			json.NewDecoder(w.Body).Decode(&resp)
+			if !reflect.DeepEqual(resp.Error.Details.Fields, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, resp.Error.Details.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrUnknownSort is returned for an answer sort mode GetAnswersSorted
+// doesn't know
+var ErrUnknownSort = errors.New("unknown sort mode")
+
+// Answer sort modes accepted by GetAnswersSorted. The empty mode keeps the
+// order answers were posted in.
+const (
+	AnswerSortVotes  = "votes"  // net score, highest first
+	AnswerSortNewest = "newest" // most recently posted first
+	AnswerSortOldest = "oldest" // first posted first
+)
+
+// answerLess orders answers for each sort mode. Sorting is stable, so
+// answers that tie keep their posting order.
+var answerLess = map[string]func(a, b *Answer) bool{
+	AnswerSortVotes: func(a, b *Answer) bool {
+		return a.Upvotes-a.Downvotes > b.Upvotes-b.Downvotes
+	},
+	AnswerSortNewest: func(a, b *Answer) bool { return a.CreatedAt.After(b.CreatedAt) },
+	AnswerSortOldest: func(a, b *Answer) bool { return a.CreatedAt.Before(b.CreatedAt) },
+}
+
+// GetAnswersSorted retrieves a question's answers ordered by mode. With
+// pinAccepted, the question's accepted answer comes first whatever the
+// mode. The stored posting order is left untouched.
+func (s *QuoraService) GetAnswersSorted(questionID, mode string, pinAccepted bool) ([]*Answer, error) {
+	less, known := answerLess[mode]
+	if mode != "" && !known {
+		return nil, ErrUnknownSort
+	}
+
+	s.mu.RLock()
+	question, exists := s.questions[questionID]
+	if !exists {
+		s.mu.RUnlock()
+		return nil, ErrQuestionNotFound
+	}
+	acceptedID := question.AcceptedAnswerID
+	// The snapshots are copies, so sorting them leaves the index alone
+	answers := s.answersLocked(questionID)
+	s.mu.RUnlock()
+
+	if less != nil {
+		sort.SliceStable(answers, func(i, j int) bool { return less(answers[i], answers[j]) })
+	}
+	if pinAccepted && acceptedID != "" {
+		sort.SliceStable(answers, func(i, j int) bool {
+			return answers[i].ID == acceptedID && answers[j].ID != acceptedID
+		})
+	}
+
+	return answers, nil
+}
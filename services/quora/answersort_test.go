@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// answerSortFixture returns a question with three answers: a1 posted first
+// with net score 1, a2 posted last with net score 3 and a3 posted in between
+// with net score -1
+func answerSortFixture(t *testing.T) (*QuoraService, *Question) {
+	t.Helper()
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Why?", "", nil)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixture := []struct {
+		posted   time.Duration
+		up, down int
+	}{
+		{0, 1, 0},
+		{2 * time.Hour, 3, 0},
+		{time.Hour, 0, 1},
+	}
+	for i, f := range fixture {
+		answer, err := service.CreateAnswer(question.ID, "expert", "Because.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.answers[answer.ID].CreatedAt = base.Add(f.posted)
+		for v := 0; v < f.up; v++ {
+			service.UpvoteAnswer(answer.ID)
+		}
+		for v := 0; v < f.down; v++ {
+			service.DownvoteAnswer(answer.ID)
+		}
+		if want := generateID("a", int64(i+1)); answer.ID != want {
+			t.Fatalf("Expected answer ID %s, got %s", want, answer.ID)
+		}
+	}
+	return service, question
+}
+
+func answerIDs(answers []*Answer) []string {
+	ids := make([]string, len(answers))
+	for i, answer := range answers {
+		ids[i] = answer.ID
+	}
+	return ids
+}
+
+func TestGetAnswersSorted_Modes(t *testing.T) {
+	service, question := answerSortFixture(t)
+	a1, a2, a3 := generateID("a", 1), generateID("a", 2), generateID("a", 3)
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"", []string{a1, a2, a3}},
+		{AnswerSortVotes, []string{a2, a1, a3}},
+		{AnswerSortNewest, []string{a2, a3, a1}},
+		{AnswerSortOldest, []string{a1, a3, a2}},
+	}
+
+	for _, tt := range tests {
+		answers, err := service.GetAnswersSorted(question.ID, tt.mode, false)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.mode, err)
+		}
+		if got := answerIDs(answers); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.mode, tt.want, got)
+		}
+	}
+
+	stored, _ := service.GetAnswers(question.ID)
+	if got := answerIDs(stored); !slices.Equal(got, []string{a1, a2, a3}) {
+		t.Errorf("Expected sorting to leave posting order alone, got %v", got)
+	}
+}
+
+func TestGetAnswersSorted_PinsAccepted(t *testing.T) {
+	service, question := answerSortFixture(t)
+	a1, a2, a3 := generateID("a", 1), generateID("a", 2), generateID("a", 3)
+
+	// Pinning is a no-op until an answer is accepted
+	answers, _ := service.GetAnswersSorted(question.ID, AnswerSortVotes, true)
+	if got := answerIDs(answers); !slices.Equal(got, []string{a2, a1, a3}) {
+		t.Errorf("Expected vote order with nothing accepted, got %v", got)
+	}
+
+	if err := service.AcceptAnswer(question.ID, a3, "asker"); err != nil {
+		t.Fatal(err)
+	}
+
+	answers, _ = service.GetAnswersSorted(question.ID, AnswerSortVotes, true)
+	if got := answerIDs(answers); !slices.Equal(got, []string{a3, a2, a1}) {
+		t.Errorf("Expected the accepted answer pinned above vote order, got %v", got)
+	}
+	answers, _ = service.GetAnswersSorted(question.ID, AnswerSortVotes, false)
+	if got := answerIDs(answers); !slices.Equal(got, []string{a2, a1, a3}) {
+		t.Errorf("Expected vote order without pinning, got %v", got)
+	}
+}
+
+func TestGetAnswersSorted_Errors(t *testing.T) {
+	service, question := answerSortFixture(t)
+
+	if _, err := service.GetAnswersSorted(question.ID, "random", false); err != ErrUnknownSort {
+		t.Errorf("Expected ErrUnknownSort, got %v", err)
+	}
+	if _, err := service.GetAnswersSorted("nonexistent", AnswerSortVotes, false); err != ErrQuestionNotFound {
+		t.Errorf("Expected ErrQuestionNotFound, got %v", err)
+	}
+}
+
+func TestGetAnswersHandler_Sort(t *testing.T) {
+	var question *Question
+	service, question = answerSortFixture(t)
+	service.AcceptAnswer(question.ID, generateID("a", 1), "asker")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/answer/list?question_id="+question.ID+"&sort=newest&accepted_first=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var answers []*Answer
+	json.NewDecoder(w.Body).Decode(&answers)
+	want := []string{generateID("a", 1), generateID("a", 2), generateID("a", 3)}
+	if got := answerIDs(answers); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/answer/list?question_id="+question.ID+"&sort=random", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown sort, got %d", w.Code)
+	}
+}
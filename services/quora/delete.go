@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"common/apierror"
+	"common/middleware"
+	"common/validate"
+)
+
+// ErrNotAuthor is returned when someone other than the author tries to
+// delete a question or answer
+var ErrNotAuthor = errors.New("only the author can delete this")
+
+// DeleteAnswer removes userID's answer from its question. If it was the
+// accepted answer the question is left with none. The votes and acceptance
+// bonus it earned are taken back from the author's reputation.
+func (s *QuoraService) DeleteAnswer(answerID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answer, exists := s.answers[answerID]
+	if !exists {
+		return ErrAnswerNotFound
+	}
+	if answer.UserID != userID {
+		return ErrNotAuthor
+	}
+
+	s.deleteAnswerLocked(answer)
+	return nil
+}
+
+// DeleteQuestion removes userID's question along with all of its answers,
+// whoever wrote them, and takes it out of its tags
+func (s *QuoraService) DeleteQuestion(questionID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	question, exists := s.questions[questionID]
+	if !exists {
+		return ErrQuestionNotFound
+	}
+	if question.UserID != userID {
+		return ErrNotAuthor
+	}
+
+	for _, aID := range s.answersByQ.Get(questionID) {
+		if answer, exists := s.answers[aID]; exists {
+			s.deleteAnswerLocked(answer)
+		}
+	}
+	for _, tag := range question.Tags {
+		s.questionsByTag.Remove(tag, questionID)
+	}
+	s.addReputation(question.UserID, -(atomic.LoadInt64(&question.Upvotes)*s.weights.QuestionUpvote +
+		atomic.LoadInt64(&question.Downvotes)*s.weights.QuestionDownvote))
+	s.forgetVotesLocked(questionID)
+	delete(s.questions, questionID)
+
+	return nil
+}
+
+// deleteAnswerLocked removes an answer, unaccepts it and reverses the
+// reputation it earned. Must be called with s.mu write-held.
+func (s *QuoraService) deleteAnswerLocked(answer *Answer) {
+	if question, exists := s.questions[answer.QuestionID]; exists && question.AcceptedAnswerID == answer.ID {
+		question.AcceptedAnswerID = ""
+		s.addReputation(answer.UserID, -s.weights.AcceptedAnswer)
+	}
+	s.addReputation(answer.UserID, -(atomic.LoadInt64(&answer.Upvotes)*s.weights.AnswerUpvote +
+		atomic.LoadInt64(&answer.Downvotes)*s.weights.AnswerDownvote))
+
+	s.forgetVotesLocked(answer.ID)
+	s.answersByQ.Remove(answer.QuestionID, answer.ID)
+	delete(s.answers, answer.ID)
+}
+
+// deleteQuestionRequest is the body of /question/delete
+type deleteQuestionRequest struct {
+	QuestionID string `json:"question_id"`
+	UserID     string `json:"user_id"`
+}
+
+func (req deleteQuestionRequest) validate() error {
+	var v validate.Validator
+	v.String("question_id", req.QuestionID, validate.Required)
+	v.String("user_id", req.UserID, validate.Required)
+	return v.Err()
+}
+
+// deleteAnswerRequest is the body of /answer/delete
+type deleteAnswerRequest struct {
+	AnswerID string `json:"answer_id"`
+	UserID   string `json:"user_id"`
+}
+
+func (req deleteAnswerRequest) validate() error {
+	var v validate.Validator
+	v.String("answer_id", req.AnswerID, validate.Required)
+	v.String("user_id", req.UserID, validate.Required)
+	return v.Err()
+}
+
+func deleteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req deleteQuestionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	writeDeleteResult(w, service.DeleteQuestion(req.QuestionID, req.UserID))
+}
+
+func deleteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req deleteAnswerRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	writeDeleteResult(w, service.DeleteAnswer(req.AnswerID, req.UserID))
+}
+
+// writeDeleteResult maps a delete error to its status code
+func writeDeleteResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotAuthor):
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
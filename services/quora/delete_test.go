@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeleteQuestion_Cascades(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Q", "", []string{"go", "rust"})
+	other, _ := service.CreateQuestion("asker", "Other", "", []string{"go"})
+	first, _ := service.CreateAnswer(question.ID, "alice", "A1")
+	second, _ := service.CreateAnswer(question.ID, "bob", "A2")
+	kept, _ := service.CreateAnswer(other.ID, "bob", "A3")
+
+	if err := service.DeleteQuestion(question.ID, "asker"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := service.GetQuestion(question.ID); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("Expected the question to be gone, got %v", err)
+	}
+	for _, id := range []string{first.ID, second.ID} {
+		if _, exists := service.answers[id]; exists {
+			t.Errorf("Expected answer %s to be deleted with its question", id)
+		}
+	}
+	if _, exists := service.answers[kept.ID]; !exists {
+		t.Error("Expected answers to other questions to survive")
+	}
+	if n := service.answersByQ.Len(question.ID); n != 0 {
+		t.Errorf("Expected no answers indexed under the question, got %d", n)
+	}
+	if service.questionsByTag.Has("rust", question.ID) || service.questionsByTag.Has("go", question.ID) {
+		t.Error("Expected the question to be removed from its tags")
+	}
+
+	found, _ := service.SearchByTag("go")
+	if len(found) != 1 || found[0].ID != other.ID {
+		t.Errorf("Expected SearchByTag to return only the surviving question, got %v", found)
+	}
+}
+
+func TestDeleteAnswer_ClearsAcceptance(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Q", "", nil)
+	answer, _ := service.CreateAnswer(question.ID, "alice", "A")
+	service.UpvoteAnswer(answer.ID)
+	service.AcceptAnswer(question.ID, answer.ID, "asker")
+
+	if err := service.DeleteAnswer(answer.ID, "alice"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got, _ := service.GetQuestion(question.ID)
+	if got.AcceptedAnswerID != "" {
+		t.Errorf("Expected no accepted answer, got %q", got.AcceptedAnswerID)
+	}
+	if answers, _ := service.GetAnswers(question.ID); len(answers) != 0 {
+		t.Errorf("Expected no answers, got %v", answers)
+	}
+	if rep, _ := service.GetReputation("alice"); rep != 0 {
+		t.Errorf("Expected the deleted answer's reputation to be taken back, got %d", rep)
+	}
+	if err := service.DeleteAnswer(answer.ID, "alice"); !errors.Is(err, ErrAnswerNotFound) {
+		t.Errorf("Expected ErrAnswerNotFound, got %v", err)
+	}
+}
+
+func TestDelete_OnlyAuthor(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Q", "", []string{"go"})
+	answer, _ := service.CreateAnswer(question.ID, "alice", "A")
+
+	if err := service.DeleteAnswer(answer.ID, "asker"); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Expected ErrNotAuthor for the asker deleting an answer, got %v", err)
+	}
+	if err := service.DeleteQuestion(question.ID, "alice"); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Expected ErrNotAuthor for an answerer deleting the question, got %v", err)
+	}
+	if found, _ := service.SearchByTag("go"); len(found) != 1 {
+		t.Errorf("Expected the question to survive, got %v", found)
+	}
+}
+
+func TestDeleteHandlers(t *testing.T) {
+	service = NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Q", "", nil)
+	answer, _ := service.CreateAnswer(question.ID, "alice", "A")
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	tests := []struct {
+		path string
+		body string
+		want int
+	}{
+		{"/answer/delete", `{"answer_id":"` + answer.ID + `","user_id":"asker"}`, http.StatusForbidden},
+		{"/answer/delete", `{"answer_id":"` + answer.ID + `","user_id":"alice"}`, http.StatusOK},
+		{"/answer/delete", `{"answer_id":"` + answer.ID + `","user_id":"alice"}`, http.StatusNotFound},
+		{"/question/delete", `{"question_id":"` + question.ID + `"}`, http.StatusBadRequest},
+		{"/question/delete", `{"question_id":"` + question.ID + `","user_id":"alice"}`, http.StatusForbidden},
+		{"/question/delete", `{"question_id":"` + question.ID + `","user_id":"asker"}`, http.StatusOK},
+		{"/question/delete", `{"question_id":"` + question.ID + `","user_id":"asker"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.path, tt.body, tt.want, w.Code)
+		}
+	}
+}
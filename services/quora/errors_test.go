@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// errorBody is the JSON envelope every handler error is written in
+type errorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func TestHandlerErrors_JSONEnvelope(t *testing.T) {
+	service = NewQuoraService()
+	question, _ := service.CreateQuestion("user1", "Title", "", nil)
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		method      string
+		target      string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{"unknown question", getQuestionHandler, http.MethodGet, "/question/get?question_id=q_9", "", http.StatusNotFound, "not_found", "question not found"},
+		{"answers to unknown question", getAnswersHandler, http.MethodGet, "/answer/list?question_id=q_9", "", http.StatusNotFound, "not_found", "question not found"},
+		{"answer to unknown question", createAnswerHandler, http.MethodPost, "/answer/create", `{"question_id":"q_9","user_id":"u","content":"hi"}`, http.StatusNotFound, "not_found", "question not found"},
+		{"upvote unknown question", upvoteQuestionHandler, http.MethodPost, "/question/upvote", `{"question_id":"q_9"}`, http.StatusNotFound, "not_found", "question not found"},
+		{"upvote unknown answer", upvoteAnswerHandler, http.MethodPost, "/answer/upvote", `{"id":"a_9"}`, http.StatusNotFound, "not_found", "answer not found"},
+		{"missing parameter", getQuestionHandler, http.MethodGet, "/question/get", "", http.StatusBadRequest, "bad_request", "question_id parameter is required"},
+		{"malformed body", createAnswerHandler, http.MethodPost, "/answer/create", `{`, http.StatusBadRequest, "bad_request", ""},
+		{"wrong method", createQuestionHandler, http.MethodGet, "/question/create", "", http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed"},
+		{"known question", getAnswersHandler, http.MethodGet, "/answer/list?question_id=" + question.ID, "", http.StatusOK, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				if strings.TrimSpace(w.Body.String()) == "null" {
+					t.Error("Expected a body, got null")
+				}
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected a JSON error, got Content-Type %s", ct)
+			}
+			var body errorBody
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode error body: %v", err)
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s", tt.wantCode, body.Error.Code)
+			}
+			if tt.wantMessage != "" && body.Error.Message != tt.wantMessage {
+				t.Errorf("Expected message %q, got %q", tt.wantMessage, body.Error.Message)
+			}
+		})
+	}
+}
@@ -2,13 +2,54 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/health"
+	"common/index"
+	"common/metrics"
+	"common/middleware"
+	"common/moderation"
+	"common/openapi"
+	"common/validate"
+)
+
+var (
+	// ErrQuestionNotFound is returned when a question does not exist
+	ErrQuestionNotFound = errors.New("question not found")
+	// ErrAnswerNotFound is returned when an answer does not exist or belongs
+	// to another question
+	ErrAnswerNotFound = errors.New("answer not found")
+)
+
+// Page sizes for the tag feed
+const (
+	defaultTagFeedLimit = 20
+	maxTagFeedLimit     = 100
+)
+
+// maxTitleLength caps question titles
+const maxTitleLength = 300
+
+// Counters exposed at /metrics
+var (
+	questionsCreated = metrics.NewCounter("quora_questions_created_total", "Questions asked")
+	answersCreated   = metrics.NewCounter("quora_answers_created_total", "Answers posted")
 )
 
-// Question represents a question on Quora
+// Question represents a question on Quora. Views, Upvotes and Downvotes
+// are updated with atomic operations under the read lock, so they must
+// only be read through atomic loads or a snapshot.
 type Question struct {
 	ID          string    `json:"id"`
 	UserID      string    `json:"user_id"`
@@ -19,9 +60,30 @@ type Question struct {
 	Views       int64     `json:"views"`
 	Upvotes     int64     `json:"upvotes"`
 	Downvotes   int64     `json:"downvotes"`
+
+	AcceptedAnswerID string `json:"accepted_answer_id,omitempty"`
+}
+
+// snapshot copies the question, loading its counters atomically so the copy
+// can be encoded while votes keep arriving
+func (q *Question) snapshot() *Question {
+	return &Question{
+		ID:          q.ID,
+		UserID:      q.UserID,
+		Title:       q.Title,
+		Description: q.Description,
+		Tags:        q.Tags,
+		CreatedAt:   q.CreatedAt,
+		Views:       atomic.LoadInt64(&q.Views),
+		Upvotes:     atomic.LoadInt64(&q.Upvotes),
+		Downvotes:   atomic.LoadInt64(&q.Downvotes),
+
+		AcceptedAnswerID: q.AcceptedAnswerID,
+	}
 }
 
-// Answer represents an answer to a question
+// Answer represents an answer to a question. Upvotes and Downvotes are
+// updated atomically, like the Question counters.
 type Answer struct {
 	ID         string    `json:"id"`
 	QuestionID string    `json:"question_id"`
@@ -32,6 +94,19 @@ type Answer struct {
 	Downvotes  int64     `json:"downvotes"`
 }
 
+// snapshot copies the answer, loading its counters atomically
+func (a *Answer) snapshot() *Answer {
+	return &Answer{
+		ID:         a.ID,
+		QuestionID: a.QuestionID,
+		UserID:     a.UserID,
+		Content:    a.Content,
+		CreatedAt:  a.CreatedAt,
+		Upvotes:    atomic.LoadInt64(&a.Upvotes),
+		Downvotes:  atomic.LoadInt64(&a.Downvotes),
+	}
+}
+
 // QuoraService manages questions and answers
 type QuoraService struct {
 	mu             sync.RWMutex
@@ -39,8 +114,21 @@ type QuoraService struct {
 	answers        map[string]*Answer
 	questionIndex  int64
 	answerIndex    int64
-	questionsByTag map[string][]string // tag -> []questionID
-	answersByQ     map[string][]string // questionID -> []answerID
+	questionsByTag *index.Index[string] // tag -> questionIDs
+	answersByQ     *index.Index[string] // questionID -> answerIDs
+
+	subscriptions map[string]map[string]bool // userID -> subscribed tags
+	tagAliases    map[string]string          // normalized alias -> canonical tag
+
+	// reputation holds a counter per author, created when they first post
+	// and updated atomically as votes arrive under the read lock
+	reputation map[string]*int64
+	weights    ReputationWeights
+
+	votesMu sync.Mutex                // guards votes, which change under the read lock
+	votes   map[string]map[string]int // targetID -> userID -> 1 or -1
+
+	filter moderation.ContentFilter // checks questions and answers before they are stored
 }
 
 // NewQuoraService creates a new Quora service
@@ -48,18 +136,51 @@ func NewQuoraService() *QuoraService {
 	return &QuoraService{
 		questions:      make(map[string]*Question),
 		answers:        make(map[string]*Answer),
-		questionsByTag: make(map[string][]string),
-		answersByQ:     make(map[string][]string),
+		questionsByTag: index.New[string](),
+		answersByQ:     index.New[string](),
+
+		subscriptions: make(map[string]map[string]bool),
+		tagAliases:    make(map[string]string),
+
+		reputation: make(map[string]*int64),
+		weights:    DefaultReputationWeights(),
+
+		votes: make(map[string]map[string]int),
+
+		filter: moderation.NewWordFilter(moderation.DefaultMaxLength),
 	}
 }
 
-// CreateQuestion creates a new question
+// SetContentFilter replaces the filter new questions and answers are
+// checked against
+func (s *QuoraService) SetContentFilter(filter moderation.ContentFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = filter
+}
+
+// checkContent runs the content filter outside s.mu, so a slow or
+// re-entrant filter doesn't hold up the service
+func (s *QuoraService) checkContent(texts ...string) error {
+	s.mu.RLock()
+	filter := s.filter
+	s.mu.RUnlock()
+	return moderation.Check(filter, texts...)
+}
+
+// CreateQuestion creates a new question. Tags are stored and indexed in
+// canonical form: normalized, resolved through the alias map and deduped.
 func (s *QuoraService) CreateQuestion(userID, title, description string, tags []string) (*Question, error) {
+	if err := s.checkContent(title, description); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.questionIndex++
 	qID := generateID("q", s.questionIndex)
+	tags = s.canonicalTagsLocked(tags)
 
 	question := &Question{
 		ID:          qID,
@@ -74,39 +195,44 @@ func (s *QuoraService) CreateQuestion(userID, title, description string, tags []
 	}
 
 	s.questions[qID] = question
-	s.answersByQ[qID] = []string{}
+	s.ensureReputationLocked(userID)
 
 	// Index by tags
 	for _, tag := range tags {
-		s.questionsByTag[tag] = append(s.questionsByTag[tag], qID)
+		s.questionsByTag.Add(tag, qID)
 	}
+	questionsCreated.Inc()
 
-	return question, nil
+	return question.snapshot(), nil
 }
 
 // GetQuestion retrieves a question
 func (s *QuoraService) GetQuestion(questionID string) (*Question, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	question, exists := s.questions[questionID]
 	if !exists {
-		return nil, nil
+		return nil, ErrQuestionNotFound
 	}
 
 	// Increment views
-	question.Views++
+	atomic.AddInt64(&question.Views, 1)
 
-	return question, nil
+	return question.snapshot(), nil
 }
 
 // CreateAnswer creates a new answer
 func (s *QuoraService) CreateAnswer(questionID, userID, content string) (*Answer, error) {
+	if err := s.checkContent(content); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.questions[questionID]; !exists {
-		return nil, nil
+		return nil, ErrQuestionNotFound
 	}
 
 	s.answerIndex++
@@ -123,9 +249,11 @@ func (s *QuoraService) CreateAnswer(questionID, userID, content string) (*Answer
 	}
 
 	s.answers[aID] = answer
-	s.answersByQ[questionID] = append(s.answersByQ[questionID], aID)
+	s.answersByQ.Add(questionID, aID)
+	s.ensureReputationLocked(userID)
+	answersCreated.Inc()
 
-	return answer, nil
+	return answer.snapshot(), nil
 }
 
 // GetAnswers retrieves all answers for a question
@@ -133,96 +261,367 @@ func (s *QuoraService) GetAnswers(questionID string) ([]*Answer, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	answerIDs, exists := s.answersByQ[questionID]
-	if !exists {
-		return []*Answer{}, nil
+	if _, exists := s.questions[questionID]; !exists {
+		return nil, ErrQuestionNotFound
 	}
+	return s.answersLocked(questionID), nil
+}
+
+// answersLocked snapshots a question's answers in posting order. Must be
+// called with s.mu held.
+func (s *QuoraService) answersLocked(questionID string) []*Answer {
+	answerIDs := s.answersByQ.Get(questionID)
 
 	answers := make([]*Answer, 0, len(answerIDs))
 	for _, aID := range answerIDs {
 		if answer, exists := s.answers[aID]; exists {
-			answers = append(answers, answer)
+			answers = append(answers, answer.snapshot())
 		}
 	}
-
-	return answers, nil
+	return answers
 }
 
-// UpvoteQuestion upvotes a question
+// UpvoteQuestion upvotes a question. Only the read lock is taken so votes
+// on different (or the same) questions don't serialize on the service mutex.
 func (s *QuoraService) UpvoteQuestion(questionID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	question, exists := s.questions[questionID]
 	if !exists {
-		return nil
+		return ErrQuestionNotFound
 	}
 
-	question.Upvotes++
+	atomic.AddInt64(&question.Upvotes, 1)
+	s.addReputation(question.UserID, s.weights.QuestionUpvote)
+	return nil
+}
+
+// DownvoteQuestion downvotes a question
+func (s *QuoraService) DownvoteQuestion(questionID string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	question, exists := s.questions[questionID]
+	if !exists {
+		return ErrQuestionNotFound
+	}
+
+	atomic.AddInt64(&question.Downvotes, 1)
+	s.addReputation(question.UserID, s.weights.QuestionDownvote)
 	return nil
 }
 
 // UpvoteAnswer upvotes an answer
 func (s *QuoraService) UpvoteAnswer(answerID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	answer, exists := s.answers[answerID]
 	if !exists {
-		return nil
+		return ErrAnswerNotFound
 	}
 
-	answer.Upvotes++
+	atomic.AddInt64(&answer.Upvotes, 1)
+	s.addReputation(answer.UserID, s.weights.AnswerUpvote)
 	return nil
 }
 
-// SearchByTag searches questions by tag
-func (s *QuoraService) SearchByTag(tag string) ([]*Question, error) {
+// DownvoteAnswer downvotes an answer
+func (s *QuoraService) DownvoteAnswer(answerID string) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	questionIDs, exists := s.questionsByTag[tag]
+	answer, exists := s.answers[answerID]
 	if !exists {
-		return []*Question{}, nil
+		return ErrAnswerNotFound
 	}
 
+	atomic.AddInt64(&answer.Downvotes, 1)
+	s.addReputation(answer.UserID, s.weights.AnswerDownvote)
+	return nil
+}
+
+// SearchByTag searches questions by tag. The tag is matched in canonical
+// form, so "Golang" finds questions tagged "go" when it is an alias.
+func (s *QuoraService) SearchByTag(tag string) ([]*Question, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	questionIDs := s.questionsByTag.Get(s.canonicalTagLocked(tag))
 	questions := make([]*Question, 0, len(questionIDs))
 	for _, qID := range questionIDs {
 		if question, exists := s.questions[qID]; exists {
-			questions = append(questions, question)
+			questions = append(questions, question.snapshot())
+		}
+	}
+
+	return questions, nil
+}
+
+// SubscribeTag adds tag to the tags whose questions appear in userID's tag
+// feed. Subscribing twice is a no-op.
+func (s *QuoraService) SubscribeTag(userID, tag string) error {
+	if userID == "" || normalizeTag(tag) == "" {
+		return fmt.Errorf("user_id and tag are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag = s.canonicalTagLocked(tag)
+
+	if s.subscriptions[userID] == nil {
+		s.subscriptions[userID] = make(map[string]bool)
+	}
+	s.subscriptions[userID][tag] = true
+
+	return nil
+}
+
+// UnsubscribeTag removes tag from userID's tag feed
+func (s *QuoraService) UnsubscribeTag(userID, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag = s.canonicalTagLocked(tag)
+
+	if !s.subscriptions[userID][tag] {
+		return fmt.Errorf("not subscribed to %s", tag)
+	}
+	delete(s.subscriptions[userID], tag)
+	if len(s.subscriptions[userID]) == 0 {
+		delete(s.subscriptions, userID)
+	}
+
+	return nil
+}
+
+// GetTagFeed returns up to limit questions carrying any of userID's
+// subscribed tags, newest first. A question with several subscribed tags
+// appears once. A limit of zero or less returns every match.
+func (s *QuoraService) GetTagFeed(userID string, limit int) ([]*Question, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	questions := []*Question{}
+	for tag := range s.subscriptions[userID] {
+		for _, qID := range s.questionsByTag.Get(tag) {
+			if seen[qID] {
+				continue
+			}
+			seen[qID] = true
+			if question, exists := s.questions[qID]; exists {
+				questions = append(questions, question.snapshot())
+			}
 		}
 	}
 
+	sort.Slice(questions, func(i, j int) bool {
+		a, b := questions[i], questions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	if limit > 0 && len(questions) > limit {
+		questions = questions[:limit]
+	}
+
 	return questions, nil
 }
 
+// quoraState is the serialized form of the whole service. The tag and
+// per-question answer indexes are not stored: they are rebuilt from
+// Questions, ordered by creation, and Answers, grouped by question in
+// posting order.
+type quoraState struct {
+	Questions     []*Question `json:"questions"`
+	Answers       []*Answer   `json:"answers"`
+	QuestionIndex int64       `json:"question_index"`
+	AnswerIndex   int64       `json:"answer_index"`
+
+	Subscriptions map[string][]string       `json:"subscriptions,omitempty"` // userID -> tags
+	Votes         map[string]map[string]int `json:"votes,omitempty"`         // targetID -> userID -> 1 or -1
+}
+
+// Snapshot serializes every question and answer and the ID counters to JSON
+func (s *QuoraService) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := quoraState{
+		Questions:     make([]*Question, 0, len(s.questions)),
+		Answers:       make([]*Answer, 0, len(s.answers)),
+		QuestionIndex: s.questionIndex,
+		AnswerIndex:   s.answerIndex,
+		Subscriptions: make(map[string][]string, len(s.subscriptions)),
+		Votes:         make(map[string]map[string]int),
+	}
+
+	s.votesMu.Lock()
+	for targetID, byUser := range s.votes {
+		state.Votes[targetID] = make(map[string]int, len(byUser))
+		for userID, direction := range byUser {
+			state.Votes[targetID][userID] = direction
+		}
+	}
+	s.votesMu.Unlock()
+
+	for userID, tags := range s.subscriptions {
+		for tag := range tags {
+			state.Subscriptions[userID] = append(state.Subscriptions[userID], tag)
+		}
+		sort.Strings(state.Subscriptions[userID])
+	}
+
+	for _, question := range s.questions {
+		state.Questions = append(state.Questions, question.snapshot())
+	}
+	sort.Slice(state.Questions, func(i, j int) bool {
+		a, b := state.Questions[i], state.Questions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	for _, question := range state.Questions {
+		for _, aID := range s.answersByQ.Get(question.ID) {
+			if answer, exists := s.answers[aID]; exists {
+				state.Answers = append(state.Answers, answer.snapshot())
+			}
+		}
+	}
+
+	return json.Marshal(state)
+}
+
+// Restore replaces the service's state with a snapshot and rebuilds the tag
+// and answer indexes, with tags in canonical form under the current aliases. Snapshots with answers to unknown questions are
+// rejected and leave the current state in place.
+func (s *QuoraService) Restore(data []byte) error {
+	var state quoraState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	questions := make(map[string]*Question, len(state.Questions))
+	answersByQ := index.New[string]()
+	for _, question := range state.Questions {
+		if question == nil || question.ID == "" {
+			return fmt.Errorf("question without an id")
+		}
+		if _, exists := questions[question.ID]; exists {
+			return fmt.Errorf("duplicate question %s", question.ID)
+		}
+		questions[question.ID] = question
+	}
+
+	answers := make(map[string]*Answer, len(state.Answers))
+	for _, answer := range state.Answers {
+		if answer == nil || answer.ID == "" {
+			return fmt.Errorf("answer without an id")
+		}
+		if _, exists := answers[answer.ID]; exists {
+			return fmt.Errorf("duplicate answer %s", answer.ID)
+		}
+		if _, exists := questions[answer.QuestionID]; !exists {
+			return fmt.Errorf("answer %s belongs to unknown question %s", answer.ID, answer.QuestionID)
+		}
+		answers[answer.ID] = answer
+		answersByQ.Add(answer.QuestionID, answer.ID)
+	}
+
+	subscriptions := make(map[string]map[string]bool, len(state.Subscriptions))
+	for userID, tags := range state.Subscriptions {
+		for _, tag := range tags {
+			if subscriptions[userID] == nil {
+				subscriptions[userID] = make(map[string]bool)
+			}
+			subscriptions[userID][tag] = true
+		}
+	}
+
+	votes := make(map[string]map[string]int, len(state.Votes))
+	for targetID, byUser := range state.Votes {
+		_, isQuestion := questions[targetID]
+		_, isAnswer := answers[targetID]
+		if !isQuestion && !isAnswer {
+			return fmt.Errorf("votes on unknown question or answer %s", targetID)
+		}
+		for userID, direction := range byUser {
+			if direction != 1 && direction != -1 {
+				return fmt.Errorf("vote by %s on %s has direction %d", userID, targetID, direction)
+			}
+		}
+		votes[targetID] = byUser
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.questions = questions
+	s.answers = answers
+	s.votes = votes
+	s.subscriptions = subscriptions
+	s.answersByQ = answersByQ
+	s.questionIndex = state.QuestionIndex
+	s.answerIndex = state.AnswerIndex
+	s.canonicalizeTagsLocked()
+	s.recomputeReputationLocked()
+
+	return nil
+}
+
 func generateID(prefix string, index int64) string {
 	return prefix + "_" + string(rune(index+'0'))
 }
 
 var service *QuoraService
 
+// createQuestionRequest is the body of /question/create
+type createQuestionRequest struct {
+	UserID      string   `json:"user_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+func (req createQuestionRequest) validate() error {
+	var v validate.Validator
+	v.String("user_id", req.UserID, validate.Required)
+	v.String("title", req.Title, validate.Required, validate.MaxLen(maxTitleLength))
+	return v.Err()
+}
+
 func createQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		UserID      string   `json:"user_id"`
-		Title       string   `json:"title"`
-		Description string   `json:"description"`
-		Tags        []string `json:"tags"`
-	}
+	var req createQuestionRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
 		return
 	}
 
 	question, err := service.CreateQuestion(req.UserID, req.Title, req.Description, req.Tags)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	switch {
+	case errors.Is(err, moderation.ErrRejected):
+		apierror.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -233,18 +632,13 @@ func createQuestionHandler(w http.ResponseWriter, r *http.Request) {
 func getQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	questionID := r.URL.Query().Get("question_id")
 	if questionID == "" {
-		http.Error(w, "question_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "question_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	question, err := service.GetQuestion(questionID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	}
-
-	if question == nil {
-		http.Error(w, "question not found", http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -252,26 +646,50 @@ func getQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(question)
 }
 
+// createAnswerRequest is the body of /answer/create
+type createAnswerRequest struct {
+	QuestionID string `json:"question_id"`
+	UserID     string `json:"user_id"`
+	Content    string `json:"content"`
+}
+
+func (req createAnswerRequest) validate() error {
+	var v validate.Validator
+	v.String("question_id", req.QuestionID, validate.Required)
+	v.String("user_id", req.UserID, validate.Required)
+	v.String("content", req.Content, validate.Required)
+	return v.Err()
+}
+
 func createAnswerHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		QuestionID string `json:"question_id"`
-		UserID     string `json:"user_id"`
-		Content    string `json:"content"`
-	}
+	var req createAnswerRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
 		return
 	}
 
 	answer, err := service.CreateAnswer(req.QuestionID, req.UserID, req.Content)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	switch {
+	case errors.Is(err, ErrQuestionNotFound):
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, moderation.ErrRejected):
+		apierror.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -282,13 +700,20 @@ func createAnswerHandler(w http.ResponseWriter, r *http.Request) {
 func getAnswersHandler(w http.ResponseWriter, r *http.Request) {
 	questionID := r.URL.Query().Get("question_id")
 	if questionID == "" {
-		http.Error(w, "question_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "question_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	answers, err := service.GetAnswers(questionID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	query := r.URL.Query()
+	pinAccepted, _ := strconv.ParseBool(query.Get("accepted_first"))
+
+	answers, err := service.GetAnswersSorted(questionID, query.Get("sort"), pinAccepted)
+	switch {
+	case errors.Is(err, ErrUnknownSort):
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -296,23 +721,65 @@ func getAnswersHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(answers)
 }
 
+// upvoteQuestionRequest is the body of /question/upvote
+type upvoteQuestionRequest struct {
+	QuestionID string `json:"question_id"`
+}
+
 func upvoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		QuestionID string `json:"question_id"`
-	}
+	var req upvoteQuestionRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if err := service.UpvoteQuestion(req.QuestionID); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// voteRequest is the body of /question/downvote, /answer/upvote and
+// /answer/downvote
+type voteRequest struct {
+	ID string `json:"id"`
+}
+
+func downvoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	handleVote(w, r, service.DownvoteQuestion)
+}
+
+func upvoteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	handleVote(w, r, service.UpvoteAnswer)
+}
+
+func downvoteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	handleVote(w, r, service.DownvoteAnswer)
+}
+
+func handleVote(w http.ResponseWriter, r *http.Request, vote func(id string) error) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req voteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := vote(req.ID); err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -322,13 +789,75 @@ func upvoteQuestionHandler(w http.ResponseWriter, r *http.Request) {
 func searchByTagHandler(w http.ResponseWriter, r *http.Request) {
 	tag := r.URL.Query().Get("tag")
 	if tag == "" {
-		http.Error(w, "tag parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "tag parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	questions, err := service.SearchByTag(tag)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
+
+// subscribeTagRequest is the body of /tag/subscribe and /tag/unsubscribe
+type subscribeTagRequest struct {
+	UserID string `json:"user_id"`
+	Tag    string `json:"tag"`
+}
+
+func subscribeTagHandler(w http.ResponseWriter, r *http.Request) {
+	handleSubscription(w, r, service.SubscribeTag)
+}
+
+func unsubscribeTagHandler(w http.ResponseWriter, r *http.Request) {
+	handleSubscription(w, r, service.UnsubscribeTag)
+}
+
+func handleSubscription(w http.ResponseWriter, r *http.Request, apply func(userID, tag string) error) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req subscribeTagRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := apply(middleware.AuthenticatedUserID(r, req.UserID), req.Tag); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func tagFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultTagFeedLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTagFeedLimit {
+			apierror.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxTagFeedLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	questions, err := service.GetTagFeed(userID, limit)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
@@ -341,19 +870,172 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Quora Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(&service.mu, time.Second))
+
+	// Mutating endpoints act as the user in the bearer token
+	auth := middleware.AuthFromEnv("JWT_SECRET")
+
+	// Cap request bodies and bound request time on create endpoints
+	guard := middleware.LimitRequest(middleware.DefaultMaxBodyBytes, middleware.DefaultRequestTimeout)
+
+	// Retried creates with the same Idempotency-Key replay the first response
+	idempotency := middleware.NewIdempotencyStore(middleware.DefaultIdempotencyTTL)
+	idempotency.StartJanitor(time.Minute)
+	idempotent := middleware.Idempotency(idempotency)
+
+	// State dumps and tag merges need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	questionQuery := []openapi.Param{{Name: "question_id", Required: true}}
+
+	api.Handle("/question/create", auth(guard(idempotent(http.HandlerFunc(createQuestionHandler)))), openapi.Route{
+		Method: http.MethodPost, Summary: "Ask a question",
+		Request: createQuestionRequest{}, Response: Question{},
+		Responses: map[int]string{
+			200: "Question created",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			409: "Same Idempotency-Key still in progress",
+			413: "Body too large",
+			422: "Content rejected by the filter, or Idempotency-Key reused with a different body",
+		},
+	})
+	api.HandleFunc("/question/get", getQuestionHandler, openapi.Route{
+		Summary: "Get a question", Query: questionQuery, Response: Question{},
+		Responses: map[int]string{200: "The question", 400: "Missing question_id", 404: "Question not found"},
+	})
+	api.Handle("/question/upvote", auth(http.HandlerFunc(upvoteQuestionHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Upvote a question", Request: upvoteQuestionRequest{},
+		Responses: map[int]string{200: "Upvoted", 400: "Invalid request", 401: "Missing or invalid token", 404: "Question not found"},
+	})
+	api.Handle("/question/downvote", auth(http.HandlerFunc(downvoteQuestionHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Downvote a question", Request: voteRequest{},
+		Responses: map[int]string{200: "Downvoted", 400: "Invalid request", 401: "Missing or invalid token", 404: "Question not found"},
+	})
+	api.Handle("/answer/create", auth(http.HandlerFunc(createAnswerHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Answer a question",
+		Request: createAnswerRequest{}, Response: Answer{},
+		Responses: map[int]string{200: "Answer created", 400: "Invalid request", 401: "Missing or invalid token", 404: "Question not found", 422: "Content rejected by the filter"},
+	})
+	api.HandleFunc("/answer/list", getAnswersHandler, openapi.Route{
+		Summary: "List answers to a question",
+		Query: append(questionQuery,
+			openapi.Param{Name: "sort", Description: "votes, newest or oldest; posting order when omitted"},
+			openapi.Param{Name: "accepted_first", Description: "true to list the accepted answer first"},
+		),
+		Response:  []Answer{},
+		Responses: map[int]string{200: "The answers", 400: "Missing question_id or unknown sort", 404: "Question not found"},
+	})
+	api.Handle("/answer/upvote", auth(http.HandlerFunc(upvoteAnswerHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Upvote an answer", Request: voteRequest{},
+		Responses: map[int]string{200: "Upvoted", 400: "Invalid request", 401: "Missing or invalid token", 404: "Answer not found"},
+	})
+	api.Handle("/answer/downvote", auth(http.HandlerFunc(downvoteAnswerHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Downvote an answer", Request: voteRequest{},
+		Responses: map[int]string{200: "Downvoted", 400: "Invalid request", 401: "Missing or invalid token", 404: "Answer not found"},
+	})
+	api.Handle("/vote/batch", auth(http.HandlerFunc(voteBatchHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Set, change or take back the caller's votes on questions and answers",
+		Request: voteBatchRequest{}, Response: []voteResult{},
+		Responses: map[int]string{200: "Outcome of each vote", 400: "Invalid request", 401: "Missing or invalid token"},
+	})
+	api.Handle("/answer/accept", auth(http.HandlerFunc(acceptAnswerHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Accept an answer to your question", Request: acceptAnswerRequest{},
+		Responses: map[int]string{
+			200: "Accepted",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			403: "Not the question's author",
+			404: "Question or answer not found",
+		},
+	})
+	api.Handle("/question/delete", auth(http.HandlerFunc(deleteQuestionHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Delete your question and all of its answers", Request: deleteQuestionRequest{},
+		Responses: map[int]string{
+			200: "Deleted",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			403: "Not the question's author",
+			404: "Question not found",
+		},
+	})
+	api.Handle("/answer/delete", auth(http.HandlerFunc(deleteAnswerHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Delete your answer", Request: deleteAnswerRequest{},
+		Responses: map[int]string{
+			200: "Deleted",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			403: "Not the answer's author",
+			404: "Answer not found",
+		},
+	})
+	api.HandleFunc("/user/reputation", getReputationHandler, openapi.Route{
+		Summary: "Get a user's reputation", Query: []openapi.Param{{Name: "user_id", Required: true}}, Response: reputationResponse{},
+		Responses: map[int]string{200: "The reputation", 400: "Missing user_id", 404: "User has never posted"},
+	})
+	api.HandleFunc("/search", searchByTagHandler, openapi.Route{
+		Summary: "Search questions by tag", Query: []openapi.Param{{Name: "tag", Required: true}}, Response: []Question{},
+		Responses: map[int]string{200: "Matching questions", 400: "Missing tag"},
+	})
+	api.Handle("/tag/subscribe", auth(http.HandlerFunc(subscribeTagHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Follow a tag", Request: subscribeTagRequest{},
+		Responses: map[int]string{200: "Subscribed", 400: "Invalid request", 401: "Missing or invalid token"},
+	})
+	api.Handle("/tag/unsubscribe", auth(http.HandlerFunc(unsubscribeTagHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Stop following a tag", Request: subscribeTagRequest{},
+		Responses: map[int]string{200: "Unsubscribed", 400: "Invalid request or not subscribed", 401: "Missing or invalid token"},
+	})
+	api.HandleFunc("/tag/feed", tagFeedHandler, openapi.Route{
+		Summary: "Recent questions from a user's followed tags",
+		Query: []openapi.Param{
+			{Name: "user_id", Required: true},
+			{Name: "limit", Description: "Number of questions, default 20, max 100"},
+		},
+		Response:  []Question{},
+		Responses: map[int]string{200: "Questions newest first", 400: "Missing user_id or invalid limit"},
+	})
+	api.Handle("/tags/merge", adminOnly(http.HandlerFunc(mergeTagsHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Move every question from one tag to another", Request: mergeTagsRequest{}, Response: mergeTagsResponse{},
+		Responses: map[int]string{200: "Tags merged", 400: "Invalid request", 403: "Admin token required"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(quoraState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Handle("/metrics", metrics.Handler(), openapi.Route{
+		Summary: "Counters in the Prometheus text format", Responses: map[int]string{200: "The metrics"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	service = NewQuoraService()
-
-	http.HandleFunc("/question/create", createQuestionHandler)
-	http.HandleFunc("/question/get", getQuestionHandler)
-	http.HandleFunc("/question/upvote", upvoteQuestionHandler)
-	http.HandleFunc("/answer/create", createAnswerHandler)
-	http.HandleFunc("/answer/list", getAnswersHandler)
-	http.HandleFunc("/search", searchByTagHandler)
-	http.HandleFunc("/health", healthHandler)
+	service.SetContentFilter(moderation.FromEnv("BANNED_WORDS"))
+	service.SetTagAliases(tagAliasesFromEnv())
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8088"
 	log.Printf("Quora service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
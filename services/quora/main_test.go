@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -76,8 +78,8 @@ func TestGetQuestion_NotFound(t *testing.T) {
 	service := NewQuoraService()
 	
 	q, err := service.GetQuestion("nonexistent")
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
+	if err != ErrQuestionNotFound {
+		t.Fatalf("Expected ErrQuestionNotFound, got %v", err)
 	}
 	if q != nil {
 		t.Errorf("Expected nil question, got %v", q)
@@ -107,8 +109,8 @@ func TestCreateAnswer_QuestionNotFound(t *testing.T) {
 	service := NewQuoraService()
 	
 	a, err := service.CreateAnswer("nonexistent", "user2", "Test Answer")
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
+	if err != ErrQuestionNotFound {
+		t.Fatalf("Expected ErrQuestionNotFound, got %v", err)
 	}
 	if a != nil {
 		t.Errorf("Expected nil answer, got %v", a)
@@ -134,8 +136,8 @@ func TestGetAnswers_NotFound(t *testing.T) {
 	service := NewQuoraService()
 	
 	answers, err := service.GetAnswers("nonexistent")
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
+	if err != ErrQuestionNotFound {
+		t.Fatalf("Expected ErrQuestionNotFound, got %v", err)
 	}
 	if len(answers) != 0 {
 		t.Errorf("Expected 0 answers, got %d", len(answers))
@@ -160,8 +162,8 @@ func TestUpvoteQuestion_NotFound(t *testing.T) {
 	service := NewQuoraService()
 	
 	err := service.UpvoteQuestion("nonexistent")
-	if err != nil {
-		t.Errorf("Expected no error for non-existent question, got %v", err)
+	if err != ErrQuestionNotFound {
+		t.Errorf("Expected ErrQuestionNotFound, got %v", err)
 	}
 }
 
@@ -184,8 +186,46 @@ func TestUpvoteAnswer_NotFound(t *testing.T) {
 	service := NewQuoraService()
 	
 	err := service.UpvoteAnswer("nonexistent")
-	if err != nil {
-		t.Errorf("Expected no error for non-existent answer, got %v", err)
+	if err != ErrAnswerNotFound {
+		t.Errorf("Expected ErrAnswerNotFound, got %v", err)
+	}
+}
+
+func TestUpvoteQuestion_Concurrent(t *testing.T) {
+	service := NewQuoraService()
+	q, _ := service.CreateQuestion("user1", "Test Question", "Description", []string{"go"})
+	a, _ := service.CreateAnswer(q.ID, "user2", "Test Answer")
+
+	const voters = 5000
+	var wg sync.WaitGroup
+	for i := 0; i < voters; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			service.UpvoteQuestion(q.ID)
+		}()
+		go func() {
+			defer wg.Done()
+			service.UpvoteAnswer(a.ID)
+		}()
+		// Reads run alongside the votes
+		go func() {
+			defer wg.Done()
+			service.GetQuestion(q.ID)
+		}()
+	}
+	wg.Wait()
+
+	got, _ := service.GetQuestion(q.ID)
+	if got.Upvotes != voters {
+		t.Errorf("Expected %d question upvotes, got %d", voters, got.Upvotes)
+	}
+	if got.Views != voters+1 {
+		t.Errorf("Expected %d views, got %d", voters+1, got.Views)
+	}
+	answers, _ := service.GetAnswers(q.ID)
+	if answers[0].Upvotes != voters {
+		t.Errorf("Expected %d answer upvotes, got %d", voters, answers[0].Upvotes)
 	}
 }
 
@@ -491,3 +531,168 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("Expected status 'healthy', got %s", resp["status"])
 	}
 }
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewQuoraService()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
+
+// upvoteWithMutex is the previous write-lock implementation, kept for the
+// benchmark comparison
+func upvoteWithMutex(s *QuoraService, questionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if question, exists := s.questions[questionID]; exists {
+		question.Upvotes++
+	}
+}
+
+func BenchmarkUpvoteQuestion_Mutex(b *testing.B) {
+	service := NewQuoraService()
+	q, _ := service.CreateQuestion("user1", "Test Question", "Description", nil)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			upvoteWithMutex(service, q.ID)
+		}
+	})
+}
+
+func BenchmarkUpvoteQuestion_Atomic(b *testing.B) {
+	service := NewQuoraService()
+	q, _ := service.CreateQuestion("user1", "Test Question", "Description", nil)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			service.UpvoteQuestion(q.ID)
+		}
+	})
+}
+
+func TestGetTagFeed_SubscribedTagsOnly(t *testing.T) {
+	service := NewQuoraService()
+	goQ, _ := service.CreateQuestion("author", "Go generics?", "", []string{"go"})
+	rustQ, _ := service.CreateQuestion("author", "Rust lifetimes?", "", []string{"rust"})
+	service.CreateQuestion("author", "Best pasta?", "", []string{"cooking"})
+	bothQ, _ := service.CreateQuestion("author", "Go or Rust?", "", []string{"go", "rust"})
+
+	service.SubscribeTag("reader", "go")
+	service.SubscribeTag("reader", "rust")
+	service.SubscribeTag("reader", "go") // no-op
+
+	feed, err := service.GetTagFeed("reader", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got := make([]string, len(feed))
+	for i, q := range feed {
+		got[i] = q.ID
+	}
+	want := []string{bothQ.ID, rustQ.ID, goQ.ID}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v newest first without duplicates, got %v", want, got)
+	}
+
+	if limited, _ := service.GetTagFeed("reader", 2); len(limited) != 2 || limited[0].ID != bothQ.ID {
+		t.Errorf("Expected the 2 newest questions, got %d", len(limited))
+	}
+	if other, _ := service.GetTagFeed("stranger", 10); len(other) != 0 {
+		t.Errorf("Expected an empty feed without subscriptions, got %d", len(other))
+	}
+}
+
+func TestUnsubscribeTag(t *testing.T) {
+	service := NewQuoraService()
+	service.CreateQuestion("author", "Go generics?", "", []string{"go"})
+	service.SubscribeTag("reader", "go")
+
+	if err := service.UnsubscribeTag("reader", "go"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.UnsubscribeTag("reader", "go"); err == nil {
+		t.Error("Expected error unsubscribing twice")
+	}
+	if feed, _ := service.GetTagFeed("reader", 10); len(feed) != 0 {
+		t.Errorf("Expected an empty feed after unsubscribing, got %d", len(feed))
+	}
+}
+
+func TestTagHandlers(t *testing.T) {
+	service = NewQuoraService()
+	service.CreateQuestion("author", "Go generics?", "", []string{"go"})
+	service.CreateQuestion("author", "Best pasta?", "", []string{"cooking"})
+
+	body, _ := json.Marshal(map[string]string{"user_id": "reader", "tag": "go"})
+	req := httptest.NewRequest(http.MethodPost, "/tag/subscribe", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	subscribeTagHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"feed", "?user_id=reader", http.StatusOK, 1},
+		{"missing user", "", http.StatusBadRequest, 0},
+		{"bad limit", "?user_id=reader&limit=0", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/tag/feed"+tt.query, nil)
+			w := httptest.NewRecorder()
+			tagFeedHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var questions []Question
+			json.NewDecoder(w.Body).Decode(&questions)
+			if len(questions) != tt.wantCount || questions[0].Title != "Go generics?" {
+				t.Errorf("Expected only the go question, got %+v", questions)
+			}
+		})
+	}
+}
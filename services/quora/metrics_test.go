@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler_CountsQuestionsAndAnswers(t *testing.T) {
+	service = NewQuoraService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	questions, answers := questionsCreated.Value(), answersCreated.Value()
+	question, _ := service.CreateQuestion("asker", "Why?", "", nil)
+	service.CreateAnswer(question.ID, "expert", "Because.")
+	service.CreateAnswer(question.ID, "expert", "Also because.")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE quora_questions_created_total counter",
+		fmt.Sprintf("quora_questions_created_total %d", questions+1),
+		fmt.Sprintf("quora_answers_created_total %d", answers+2),
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in\n%s", line, body)
+		}
+	}
+}
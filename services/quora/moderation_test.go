@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"common/moderation"
+)
+
+func TestCreateQuestionAndAnswer_ContentFilter(t *testing.T) {
+	service := NewQuoraService()
+	service.SetContentFilter(moderation.NewWordFilter(100, "spam"))
+
+	question, err := service.CreateQuestion("user1", "How do I cook rice?", "Plain white rice", nil)
+	if err != nil {
+		t.Fatalf("Expected clean question to be created, got %v", err)
+	}
+	if _, err := service.CreateAnswer(question.ID, "user2", "Boil it"); err != nil {
+		t.Fatalf("Expected clean answer to be created, got %v", err)
+	}
+
+	if _, err := service.CreateQuestion("user1", "Cheap pills", "spam spam", nil); !errors.Is(err, moderation.ErrRejected) {
+		t.Errorf("Expected a banned word in the description to be rejected, got %v", err)
+	}
+	if _, err := service.CreateAnswer(question.ID, "user2", "Spam!"); !errors.Is(err, moderation.ErrRejected) {
+		t.Errorf("Expected a banned word in the answer to be rejected, got %v", err)
+	}
+	if len(service.questions) != 1 || len(service.answers) != 1 {
+		t.Errorf("Expected rejected content not to be stored, have %d questions and %d answers", len(service.questions), len(service.answers))
+	}
+}
+
+func TestCreateQuestion_FilterRunsBeforeStore(t *testing.T) {
+	service := NewQuoraService()
+
+	var checked []string
+	service.SetContentFilter(moderation.FilterFunc(func(text string) (bool, string) {
+		if len(service.questions) != 0 {
+			t.Error("Expected the filter to run before the question is stored")
+		}
+		checked = append(checked, text)
+		return true, ""
+	}))
+
+	if _, err := service.CreateQuestion("user1", "Title", "Description", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checked) != 2 || checked[0] != "Title" || checked[1] != "Description" {
+		t.Errorf("Expected the title and description to be checked, got %v", checked)
+	}
+}
+
+func TestCreateQuestionHandler_Rejected(t *testing.T) {
+	service = NewQuoraService()
+	service.SetContentFilter(moderation.NewWordFilter(10))
+
+	body, _ := json.Marshal(createQuestionRequest{UserID: "user1", Title: "A title that is far too long"})
+	req := httptest.NewRequest(http.MethodPost, "/question/create", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	createQuestionHandler(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("the limit is 10")) {
+		t.Errorf("Expected the reason in the response, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"common/apierror"
+	"common/middleware"
+)
+
+var (
+	// ErrUserNotFound is returned for a user who has never asked or answered
+	ErrUserNotFound = errors.New("user not found")
+	// ErrNotQuestionAuthor is returned when someone other than the asker
+	// tries to accept an answer
+	ErrNotQuestionAuthor = errors.New("only the question's author can accept an answer")
+)
+
+// ReputationWeights sets how many points each vote or accepted answer is
+// worth to the author of the content. Downvote weights are normally
+// negative.
+type ReputationWeights struct {
+	QuestionUpvote   int64 `json:"question_upvote"`
+	QuestionDownvote int64 `json:"question_downvote"`
+	AnswerUpvote     int64 `json:"answer_upvote"`
+	AnswerDownvote   int64 `json:"answer_downvote"`
+	AcceptedAnswer   int64 `json:"accepted_answer"`
+}
+
+// DefaultReputationWeights returns the weights used by NewQuoraService
+func DefaultReputationWeights() ReputationWeights {
+	return ReputationWeights{
+		QuestionUpvote:   5,
+		QuestionDownvote: -2,
+		AnswerUpvote:     10,
+		AnswerDownvote:   -2,
+		AcceptedAnswer:   15,
+	}
+}
+
+// SetReputationWeights changes the weights and recomputes every user's
+// reputation from the current votes
+func (s *QuoraService) SetReputationWeights(weights ReputationWeights) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weights = weights
+	s.recomputeReputationLocked()
+}
+
+// GetReputation returns the user's reputation. It is kept up to date as
+// votes arrive, so reading it is O(1).
+func (s *QuoraService) GetReputation(userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points, exists := s.reputation[userID]
+	if !exists {
+		return 0, ErrUserNotFound
+	}
+	return int(atomic.LoadInt64(points)), nil
+}
+
+// AcceptAnswer marks answerID as the accepted answer to questionID, moving
+// the accepted-answer bonus from any previously accepted answer's author
+func (s *QuoraService) AcceptAnswer(questionID, answerID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	question, exists := s.questions[questionID]
+	if !exists {
+		return ErrQuestionNotFound
+	}
+	answer, exists := s.answers[answerID]
+	if !exists || answer.QuestionID != questionID {
+		return ErrAnswerNotFound
+	}
+	if question.UserID != userID {
+		return ErrNotQuestionAuthor
+	}
+	if question.AcceptedAnswerID == answerID {
+		return nil
+	}
+
+	if previous, exists := s.answers[question.AcceptedAnswerID]; exists {
+		s.addReputation(previous.UserID, -s.weights.AcceptedAnswer)
+	}
+	question.AcceptedAnswerID = answerID
+	s.addReputation(answer.UserID, s.weights.AcceptedAnswer)
+
+	return nil
+}
+
+// ensureReputationLocked gives userID a reputation counter so later votes
+// can update it under the read lock. Must be called with s.mu write-held.
+func (s *QuoraService) ensureReputationLocked(userID string) {
+	if _, exists := s.reputation[userID]; !exists {
+		s.reputation[userID] = new(int64)
+	}
+}
+
+// addReputation adjusts an author's reputation. Authors get their counter
+// when they first post, so this only needs s.mu read-held.
+func (s *QuoraService) addReputation(userID string, delta int64) {
+	if points, exists := s.reputation[userID]; exists {
+		atomic.AddInt64(points, delta)
+	}
+}
+
+// recomputeReputationLocked rebuilds every counter from the vote counts and
+// accepted answers. Must be called with s.mu write-held.
+func (s *QuoraService) recomputeReputationLocked() {
+	s.reputation = make(map[string]*int64)
+	for _, question := range s.questions {
+		s.ensureReputationLocked(question.UserID)
+		*s.reputation[question.UserID] += question.Upvotes*s.weights.QuestionUpvote + question.Downvotes*s.weights.QuestionDownvote
+	}
+	for _, answer := range s.answers {
+		s.ensureReputationLocked(answer.UserID)
+		*s.reputation[answer.UserID] += answer.Upvotes*s.weights.AnswerUpvote + answer.Downvotes*s.weights.AnswerDownvote
+	}
+	for _, question := range s.questions {
+		if answer, exists := s.answers[question.AcceptedAnswerID]; exists {
+			*s.reputation[answer.UserID] += s.weights.AcceptedAnswer
+		}
+	}
+}
+
+func getReputationHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		apierror.Error(w, "user_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	reputation, err := service.GetReputation(userID)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reputationResponse{UserID: userID, Reputation: reputation})
+}
+
+// reputationResponse is the body returned by /user/reputation
+type reputationResponse struct {
+	UserID     string `json:"user_id"`
+	Reputation int    `json:"reputation"`
+}
+
+// acceptAnswerRequest is the body of /answer/accept
+type acceptAnswerRequest struct {
+	QuestionID string `json:"question_id"`
+	AnswerID   string `json:"answer_id"`
+	UserID     string `json:"user_id"`
+}
+
+func acceptAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req acceptAnswerRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := service.AcceptAnswer(req.QuestionID, req.AnswerID, middleware.AuthenticatedUserID(r, req.UserID))
+	switch {
+	case errors.Is(err, ErrNotQuestionAuthor):
+		apierror.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func reputationOf(t *testing.T, service *QuoraService, userID string) int {
+	t.Helper()
+	reputation, err := service.GetReputation(userID)
+	if err != nil {
+		t.Fatalf("Expected no error for %s, got %v", userID, err)
+	}
+	return reputation
+}
+
+func TestReputation_Votes(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Why?", "", nil)
+	answer, _ := service.CreateAnswer(question.ID, "expert", "Because.")
+
+	steps := []struct {
+		name       string
+		vote       func()
+		wantAsker  int
+		wantExpert int
+	}{
+		{"new authors start at zero", func() {}, 0, 0},
+		{"answer upvote", func() { service.UpvoteAnswer(answer.ID) }, 0, 10},
+		{"second answer upvote", func() { service.UpvoteAnswer(answer.ID) }, 0, 20},
+		{"answer downvote", func() { service.DownvoteAnswer(answer.ID) }, 0, 18},
+		{"question upvote", func() { service.UpvoteQuestion(question.ID) }, 5, 18},
+		{"question downvote", func() { service.DownvoteQuestion(question.ID) }, 3, 18},
+	}
+
+	for _, step := range steps {
+		step.vote()
+		if got := reputationOf(t, service, "asker"); got != step.wantAsker {
+			t.Errorf("%s: expected asker at %d, got %d", step.name, step.wantAsker, got)
+		}
+		if got := reputationOf(t, service, "expert"); got != step.wantExpert {
+			t.Errorf("%s: expected expert at %d, got %d", step.name, step.wantExpert, got)
+		}
+	}
+}
+
+func TestReputation_AcceptedAnswerBonus(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Why?", "", nil)
+	first, _ := service.CreateAnswer(question.ID, "alice", "Because.")
+	second, _ := service.CreateAnswer(question.ID, "bob", "It depends.")
+
+	if err := service.AcceptAnswer(question.ID, first.ID, "alice"); err != ErrNotQuestionAuthor {
+		t.Fatalf("Expected ErrNotQuestionAuthor, got %v", err)
+	}
+	if err := service.AcceptAnswer(question.ID, first.ID, "asker"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := reputationOf(t, service, "alice"); got != 15 {
+		t.Errorf("Expected alice to get the bonus, got %d", got)
+	}
+
+	// Accepting again is a no-op; switching moves the bonus
+	service.AcceptAnswer(question.ID, first.ID, "asker")
+	service.AcceptAnswer(question.ID, second.ID, "asker")
+	if alice, bob := reputationOf(t, service, "alice"), reputationOf(t, service, "bob"); alice != 0 || bob != 15 {
+		t.Errorf("Expected the bonus to move to bob, got alice %d and bob %d", alice, bob)
+	}
+
+	other, _ := service.CreateQuestion("asker", "How?", "", nil)
+	if err := service.AcceptAnswer(other.ID, second.ID, "asker"); err != ErrAnswerNotFound {
+		t.Errorf("Expected ErrAnswerNotFound for an answer to another question, got %v", err)
+	}
+}
+
+func TestReputation_WeightsAndRestore(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Why?", "", nil)
+	answer, _ := service.CreateAnswer(question.ID, "expert", "Because.")
+	service.UpvoteAnswer(answer.ID)
+	service.DownvoteAnswer(answer.ID)
+	service.AcceptAnswer(question.ID, answer.ID, "asker")
+
+	service.SetReputationWeights(ReputationWeights{AnswerUpvote: 1, AnswerDownvote: -1, AcceptedAnswer: 100})
+	if got := reputationOf(t, service, "expert"); got != 100 {
+		t.Errorf("Expected reputation recomputed with the new weights, got %d", got)
+	}
+
+	data, _ := service.Snapshot()
+	restored := NewQuoraService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := reputationOf(t, restored, "expert"); got != 10-2+15 {
+		t.Errorf("Expected reputation rebuilt from the snapshot, got %d", got)
+	}
+
+	if _, err := restored.GetReputation("nobody"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestReputationHandlers(t *testing.T) {
+	service = NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Why?", "", nil)
+	answer, _ := service.CreateAnswer(question.ID, "expert", "Because.")
+
+	body, _ := json.Marshal(map[string]string{"id": answer.ID})
+	req := httptest.NewRequest(http.MethodPost, "/answer/upvote", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	upvoteAnswerHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/user/reputation?user_id=expert", nil)
+	w = httptest.NewRecorder()
+	getReputationHandler(w, req)
+
+	var got reputationResponse
+	json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || got.Reputation != 10 {
+		t.Errorf("Expected reputation 10, got %d %+v", w.Code, got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/user/reputation?user_id=nobody", nil)
+	w = httptest.NewRecorder()
+	getReputationHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"common/apierror"
+	"common/index"
+	"common/validate"
+)
+
+// normalizeTag lowercases tag and trims surrounding whitespace, so "Go",
+// " go " and "GO" are the same tag
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// canonicalTagLocked normalizes tag and resolves it through the alias map.
+// Callers must hold s.mu.
+func (s *QuoraService) canonicalTagLocked(tag string) string {
+	tag = normalizeTag(tag)
+	if canonical, ok := s.tagAliases[tag]; ok {
+		return canonical
+	}
+	return tag
+}
+
+// canonicalTagsLocked returns the canonical form of each tag, dropping
+// empty tags and duplicates and keeping the first occurrence's position.
+// Callers must hold s.mu.
+func (s *QuoraService) canonicalTagsLocked(tags []string) []string {
+	canonical := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = s.canonicalTagLocked(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		canonical = append(canonical, tag)
+	}
+	return canonical
+}
+
+// SetTagAliases replaces the alias map, e.g. {"golang": "go"}, and rewrites
+// the tags of existing questions and subscriptions to their new canonical
+// forms. Aliases and their targets are normalized; an alias that maps to
+// itself or to nothing is ignored.
+func (s *QuoraService) SetTagAliases(aliases map[string]string) {
+	normalized := make(map[string]string, len(aliases))
+	for alias, canonical := range aliases {
+		alias, canonical = normalizeTag(alias), normalizeTag(canonical)
+		if alias == "" || canonical == "" || alias == canonical {
+			continue
+		}
+		normalized[alias] = canonical
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tagAliases = normalized
+	s.canonicalizeTagsLocked()
+}
+
+// canonicalizeTagsLocked rewrites every question's tags and every
+// subscription to canonical form and rebuilds the tag index from them.
+// Callers must hold the write lock.
+func (s *QuoraService) canonicalizeTagsLocked() {
+	questionsByTag := index.New[string]()
+	for qID, question := range s.questions {
+		// Replace rather than edit the slice: snapshots share it
+		question.Tags = s.canonicalTagsLocked(question.Tags)
+		for _, tag := range question.Tags {
+			questionsByTag.Add(tag, qID)
+		}
+	}
+	s.questionsByTag = questionsByTag
+
+	for userID, tags := range s.subscriptions {
+		canonical := make(map[string]bool, len(tags))
+		for tag := range tags {
+			if tag = s.canonicalTagLocked(tag); tag != "" {
+				canonical[tag] = true
+			}
+		}
+		s.subscriptions[userID] = canonical
+	}
+}
+
+// MergeTags moves every question tagged from to to, as when a moderator
+// folds "js" into "javascript", and returns how many questions were
+// affected. Questions that already had both keep to once. Subscriptions
+// follow, and from becomes an alias of to so new questions land under to.
+func (s *QuoraService) MergeTags(from, to string) (int, error) {
+	from = normalizeTag(from)
+	if from == "" || normalizeTag(to) == "" {
+		return 0, fmt.Errorf("from and to are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	to = s.canonicalTagLocked(to)
+	if from == to {
+		return 0, fmt.Errorf("cannot merge %s into itself", from)
+	}
+
+	questionIDs := s.questionsByTag.Get(from)
+	for _, qID := range questionIDs {
+		question, exists := s.questions[qID]
+		if !exists {
+			continue
+		}
+		tags := make([]string, 0, len(question.Tags))
+		for _, tag := range question.Tags {
+			if tag == from {
+				tag = to
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		// Replace rather than edit the slice: snapshots share it
+		question.Tags = tags
+		s.questionsByTag.Remove(from, qID)
+		s.questionsByTag.Add(to, qID)
+	}
+
+	for _, tags := range s.subscriptions {
+		if tags[from] {
+			delete(tags, from)
+			tags[to] = true
+		}
+	}
+
+	aliases := make(map[string]string, len(s.tagAliases)+1)
+	for alias, canonical := range s.tagAliases {
+		if canonical == from {
+			canonical = to
+		}
+		aliases[alias] = canonical
+	}
+	aliases[from] = to
+	s.tagAliases = aliases
+
+	return len(questionIDs), nil
+}
+
+// tagAliasesFromEnv parses TAG_ALIASES, a comma-separated list of
+// alias=canonical pairs such as "golang=go,js=javascript". Malformed pairs
+// are skipped.
+func tagAliasesFromEnv() map[string]string {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("TAG_ALIASES"), ",") {
+		alias, canonical, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		aliases[alias] = canonical
+	}
+	return aliases
+}
+
+// mergeTagsRequest is the body of /tags/merge
+type mergeTagsRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (req mergeTagsRequest) validate() error {
+	var v validate.Validator
+	v.String("from", req.From, validate.Required)
+	v.String("to", req.To, validate.Required)
+	return v.Err()
+}
+
+// mergeTagsResponse reports how many questions a merge moved
+type mergeTagsResponse struct {
+	Merged int `json:"merged"`
+}
+
+func mergeTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req mergeTagsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	merged, err := service.MergeTags(req.From, req.To)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mergeTagsResponse{Merged: merged})
+}
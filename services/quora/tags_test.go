@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/middleware"
+)
+
+func TestCreateQuestion_NormalizesTags(t *testing.T) {
+	service := NewQuoraService()
+	question, err := service.CreateQuestion("user1", "Q", "", []string{" Go ", "GO", "Rust", "  "})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if want := []string{"go", "rust"}; !reflect.DeepEqual(question.Tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, question.Tags)
+	}
+	found, _ := service.SearchByTag("RUST ")
+	if len(found) != 1 || found[0].ID != question.ID {
+		t.Errorf("Expected a case-insensitive search to find the question, got %v", found)
+	}
+}
+
+func TestTagAliases_IndexCanonicalForm(t *testing.T) {
+	service := NewQuoraService()
+	service.SetTagAliases(map[string]string{"Golang": "go"})
+
+	question, _ := service.CreateQuestion("user1", "Q", "", []string{"Golang"})
+
+	if want := []string{"go"}; !reflect.DeepEqual(question.Tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, question.Tags)
+	}
+	found, _ := service.SearchByTag("go")
+	if len(found) != 1 || found[0].ID != question.ID {
+		t.Fatalf("Expected SearchByTag(go) to find the question, got %v", found)
+	}
+	if ids := service.questionsByTag.Get("go"); len(ids) != 1 || ids[0] != question.ID {
+		t.Errorf("Expected the index to hold the question under go, got %v", ids)
+	}
+	if ids := service.questionsByTag.Get("golang"); len(ids) != 0 {
+		t.Errorf("Expected nothing indexed under the alias, got %v", ids)
+	}
+	found, _ = service.SearchByTag("golang")
+	if len(found) != 1 {
+		t.Errorf("Expected searching by the alias to find the question, got %v", found)
+	}
+}
+
+func TestSetTagAliases_RewritesExisting(t *testing.T) {
+	service := NewQuoraService()
+	question, _ := service.CreateQuestion("user1", "Q", "", []string{"golang", "go"})
+	service.SubscribeTag("user2", "golang")
+
+	service.SetTagAliases(map[string]string{"golang": "go"})
+
+	got, _ := service.GetQuestion(question.ID)
+	if want := []string{"go"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("Expected tags to collapse to %v, got %v", want, got.Tags)
+	}
+	if ids := service.questionsByTag.Get("golang"); len(ids) != 0 {
+		t.Errorf("Expected the alias to be dropped from the index, got %v", ids)
+	}
+	feed, _ := service.GetTagFeed("user2", 0)
+	if len(feed) != 1 {
+		t.Errorf("Expected the subscription to follow the canonical tag, got %v", feed)
+	}
+	if err := service.UnsubscribeTag("user2", "GoLang"); err != nil {
+		t.Errorf("Expected unsubscribing by alias to work, got %v", err)
+	}
+}
+
+func TestRestore_CanonicalizesTags(t *testing.T) {
+	source := NewQuoraService()
+	source.CreateQuestion("user1", "Q", "", []string{"golang"})
+	data, err := source.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewQuoraService()
+	restored.SetTagAliases(map[string]string{"golang": "go"})
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := restored.SearchByTag("go"); len(found) != 1 {
+		t.Errorf("Expected the restored question under its canonical tag, got %v", found)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	service := NewQuoraService()
+	jsOnly, _ := service.CreateQuestion("user1", "Closures?", "", []string{"js", "web"})
+	both, _ := service.CreateQuestion("user1", "Promises?", "", []string{"javascript", "js"})
+	jsFull, _ := service.CreateQuestion("user1", "Hoisting?", "", []string{"javascript"})
+	service.SubscribeTag("user2", "js")
+
+	merged, err := service.MergeTags("JS", "javascript")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if merged != 2 {
+		t.Errorf("Expected 2 questions merged, got %d", merged)
+	}
+
+	if ids := service.questionsByTag.Get("js"); len(ids) != 0 {
+		t.Errorf("Expected the js index entry to be gone, got %v", ids)
+	}
+	want := []string{jsOnly.ID, both.ID, jsFull.ID}
+	for _, id := range want {
+		if !service.questionsByTag.Has("javascript", id) {
+			t.Errorf("Expected %s under javascript", id)
+		}
+	}
+	if n := service.questionsByTag.Len("javascript"); n != len(want) {
+		t.Errorf("Expected %d questions under javascript, got %d", len(want), n)
+	}
+
+	got, _ := service.GetQuestion(jsOnly.ID)
+	if want := []string{"javascript", "web"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, got.Tags)
+	}
+	got, _ = service.GetQuestion(both.ID)
+	if want := []string{"javascript"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("Expected no duplicate tag, got %v", got.Tags)
+	}
+
+	if feed, _ := service.GetTagFeed("user2", 0); len(feed) != 3 {
+		t.Errorf("Expected the subscription to follow the merge, got %d questions", len(feed))
+	}
+	later, _ := service.CreateQuestion("user1", "Modules?", "", []string{"js"})
+	if want := []string{"javascript"}; !reflect.DeepEqual(later.Tags, want) {
+		t.Errorf("Expected new questions to use the merged tag, got %v", later.Tags)
+	}
+
+	if _, err := service.MergeTags("javascript", "JavaScript"); err == nil {
+		t.Error("Expected an error merging a tag into itself")
+	}
+}
+
+func TestMergeTagsHandler_AdminOnly(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	service = NewQuoraService()
+	service.CreateQuestion("user1", "Q", "", []string{"js"})
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	body := `{"from":"js","to":"javascript"}`
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tags/merge", strings.NewReader(body)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 without the admin token, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tags/merge", strings.NewReader(body))
+	req.Header.Set(middleware.AdminTokenHeader, "s3cret")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp mergeTagsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Merged != 1 {
+		t.Errorf("Expected 1 question merged, got %d", resp.Merged)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/validate"
+)
+
+// fieldErrors decodes the field errors from a validation response
+func fieldErrors(t *testing.T, w *httptest.ResponseRecorder) validate.Errors {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Details struct {
+				Fields validate.Errors `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected a JSON error body, got %v", err)
+	}
+	return body.Error.Details.Fields
+}
+
+func TestCreateHandlers_ReportEveryFieldError(t *testing.T) {
+	service = NewQuoraService()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    validate.Errors
+	}{
+		{"empty question", createQuestionHandler, `{}`, validate.Errors{
+			{Field: "user_id", Message: "required"},
+			{Field: "title", Message: "required"},
+		}},
+		{"long title", createQuestionHandler, `{"user_id":"u","title":"` + strings.Repeat("x", maxTitleLength+1) + `"}`, validate.Errors{
+			{Field: "title", Message: "must be at most 300 characters"},
+		}},
+		{"empty answer", createAnswerHandler, `{"content":"  "}`, validate.Errors{
+			{Field: "question_id", Message: "required"},
+			{Field: "user_id", Message: "required"},
+			{Field: "content", Message: "required"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", w.Code)
+			}
+			if got := fieldErrors(t, w); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
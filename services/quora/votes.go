@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"common/apierror"
+	"common/middleware"
+	"common/validate"
+)
+
+// MaxVoteBatch is the most votes one /vote/batch request may carry
+const MaxVoteBatch = 100
+
+var (
+	// ErrInvalidVote is returned for a vote direction other than -1, 0 or 1
+	ErrInvalidVote = errors.New("vote direction must be -1, 0 or 1")
+	// ErrVoteTargetNotFound is returned for a vote on an ID that is neither
+	// a question nor an answer
+	ErrVoteTargetNotFound = errors.New("question or answer not found")
+)
+
+// voteTarget is the counters and reputation weights a vote on a question
+// or answer moves
+type voteTarget struct {
+	upvotes, downvotes   *int64
+	authorID             string
+	upWeight, downWeight int64
+}
+
+// voteTargetLocked finds the question or answer targetID names. Must be
+// called with s.mu held.
+func (s *QuoraService) voteTargetLocked(targetID string) (voteTarget, error) {
+	if question, exists := s.questions[targetID]; exists {
+		return voteTarget{
+			upvotes: &question.Upvotes, downvotes: &question.Downvotes,
+			authorID: question.UserID,
+			upWeight: s.weights.QuestionUpvote, downWeight: s.weights.QuestionDownvote,
+		}, nil
+	}
+	if answer, exists := s.answers[targetID]; exists {
+		return voteTarget{
+			upvotes: &answer.Upvotes, downvotes: &answer.Downvotes,
+			authorID: answer.UserID,
+			upWeight: s.weights.AnswerUpvote, downWeight: s.weights.AnswerDownvote,
+		}, nil
+	}
+	return voteTarget{}, ErrVoteTargetNotFound
+}
+
+// count adds sign times a vote in direction to the target's counters and
+// its author's reputation
+func (s *QuoraService) count(target voteTarget, direction int, sign int64) {
+	switch direction {
+	case 1:
+		atomic.AddInt64(target.upvotes, sign)
+		s.addReputation(target.authorID, sign*target.upWeight)
+	case -1:
+		atomic.AddInt64(target.downvotes, sign)
+		s.addReputation(target.authorID, sign*target.downWeight)
+	}
+}
+
+// Vote sets userID's vote on a question or answer to direction: 1 for up,
+// -1 for down and 0 to take the vote back. A user holds at most one vote
+// per target, so changing it moves the counts and the author's reputation
+// from the old direction to the new one and repeating it changes nothing.
+func (s *QuoraService) Vote(targetID, userID string, direction int) error {
+	if direction < -1 || direction > 1 {
+		return ErrInvalidVote
+	}
+
+	// Like the anonymous votes, only the read lock is taken; votesMu
+	// serializes the per-user state
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	target, err := s.voteTargetLocked(targetID)
+	if err != nil {
+		return err
+	}
+
+	s.votesMu.Lock()
+	defer s.votesMu.Unlock()
+
+	previous := s.votes[targetID][userID]
+	if previous == direction {
+		return nil
+	}
+	s.count(target, previous, -1)
+	s.count(target, direction, 1)
+
+	if direction == 0 {
+		delete(s.votes[targetID], userID)
+		if len(s.votes[targetID]) == 0 {
+			delete(s.votes, targetID)
+		}
+		return nil
+	}
+	if s.votes[targetID] == nil {
+		s.votes[targetID] = make(map[string]int)
+	}
+	s.votes[targetID][userID] = direction
+	return nil
+}
+
+// GetVote returns userID's vote on a target: 1, -1, or 0 if they haven't
+// voted
+func (s *QuoraService) GetVote(targetID, userID string) int {
+	s.votesMu.Lock()
+	defer s.votesMu.Unlock()
+	return s.votes[targetID][userID]
+}
+
+// forgetVotesLocked drops the per-user votes on a deleted target. Must be
+// called with s.mu write-held.
+func (s *QuoraService) forgetVotesLocked(targetID string) {
+	s.votesMu.Lock()
+	defer s.votesMu.Unlock()
+	delete(s.votes, targetID)
+}
+
+// voteRequestItem is one vote in a /vote/batch request
+type voteRequestItem struct {
+	TargetID  string `json:"target_id"`
+	Direction int    `json:"direction"` // 1 up, -1 down, 0 to take the vote back
+}
+
+// voteBatchRequest is the body of /vote/batch
+type voteBatchRequest struct {
+	UserID string            `json:"user_id"`
+	Votes  []voteRequestItem `json:"votes"`
+}
+
+func (req voteBatchRequest) validate() error {
+	var v validate.Validator
+	v.String("user_id", req.UserID, validate.Required)
+	v.Int("votes", len(req.Votes), validate.Min(1), validate.Max(MaxVoteBatch))
+	for i, vote := range req.Votes {
+		v.String(fmt.Sprintf("votes[%d].target_id", i), vote.TargetID, validate.Required)
+		v.Int(fmt.Sprintf("votes[%d].direction", i), vote.Direction, validate.OneOfInt(-1, 0, 1))
+	}
+	return v.Err()
+}
+
+// voteResult is the outcome of one vote in a /vote/batch response
+type voteResult struct {
+	TargetID string `json:"target_id"`
+	Status   int    `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// voteBatchHandler applies each vote in order and reports each outcome. A
+// vote on a missing target doesn't stop the ones after it.
+func voteBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req voteBatchRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = middleware.AuthenticatedUserID(r, req.UserID)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	results := make([]voteResult, 0, len(req.Votes))
+	for _, vote := range req.Votes {
+		result := voteResult{TargetID: vote.TargetID, Status: http.StatusOK}
+		if err := service.Vote(vote.TargetID, req.UserID, vote.Direction); err != nil {
+			result.Status = http.StatusNotFound
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
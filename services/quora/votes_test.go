@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// counts returns a question's upvotes, downvotes and its author's
+// reputation
+func counts(t *testing.T, s *QuoraService, questionID string) (up, down int64, reputation int) {
+	t.Helper()
+	question, err := s.GetQuestion(questionID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	reputation, _ = s.GetReputation(question.UserID)
+	return question.Upvotes, question.Downvotes, reputation
+}
+
+func TestVote_SwitchingMovesCountsByTwo(t *testing.T) {
+	s := NewQuoraService()
+	question, _ := s.CreateQuestion("asker", "Q", "", nil)
+	s.Vote(question.ID, "alice", 1)
+	s.Vote(question.ID, "bob", 1)
+
+	upBefore, downBefore, _ := counts(t, s, question.ID)
+	netBefore := upBefore - downBefore
+
+	if err := s.Vote(question.ID, "alice", -1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	up, down, reputation := counts(t, s, question.ID)
+	if up != 1 || down != 1 {
+		t.Errorf("Expected 1 up and 1 down, got %d and %d", up, down)
+	}
+	if net := up - down; net != netBefore-2 {
+		t.Errorf("Expected the net count to drop by 2 from %d, got %d", netBefore, net)
+	}
+	weights := DefaultReputationWeights()
+	if want := int(weights.QuestionUpvote + weights.QuestionDownvote); reputation != want {
+		t.Errorf("Expected reputation %d, got %d", want, reputation)
+	}
+	if got := s.GetVote(question.ID, "alice"); got != -1 {
+		t.Errorf("Expected alice's vote to be -1, got %d", got)
+	}
+}
+
+func TestVote_RemovingRestoresPriorTotals(t *testing.T) {
+	s := NewQuoraService()
+	question, _ := s.CreateQuestion("asker", "Q", "", nil)
+	answer, _ := s.CreateAnswer(question.ID, "answerer", "A")
+	s.Vote(answer.ID, "bob", -1)
+
+	before, _ := s.GetReputation("answerer")
+	s.Vote(answer.ID, "alice", 1)
+	s.Vote(answer.ID, "alice", -1)
+	if err := s.Vote(answer.ID, "alice", 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got := s.answers[answer.ID]
+	if got.Upvotes != 0 || got.Downvotes != 1 {
+		t.Errorf("Expected only bob's downvote left, got %d up and %d down", got.Upvotes, got.Downvotes)
+	}
+	if after, _ := s.GetReputation("answerer"); after != before {
+		t.Errorf("Expected reputation back at %d, got %d", before, after)
+	}
+	if vote := s.GetVote(answer.ID, "alice"); vote != 0 {
+		t.Errorf("Expected no vote from alice, got %d", vote)
+	}
+}
+
+func TestVote_RepeatingCountsOnce(t *testing.T) {
+	s := NewQuoraService()
+	question, _ := s.CreateQuestion("asker", "Q", "", nil)
+	for i := 0; i < 3; i++ {
+		s.Vote(question.ID, "alice", 1)
+	}
+	if up, _, _ := counts(t, s, question.ID); up != 1 {
+		t.Errorf("Expected 1 upvote, got %d", up)
+	}
+}
+
+func TestVote_Errors(t *testing.T) {
+	s := NewQuoraService()
+	question, _ := s.CreateQuestion("asker", "Q", "", nil)
+	if err := s.Vote(question.ID, "alice", 2); !errors.Is(err, ErrInvalidVote) {
+		t.Errorf("Expected ErrInvalidVote, got %v", err)
+	}
+	if err := s.Vote("q_missing", "alice", 1); !errors.Is(err, ErrVoteTargetNotFound) {
+		t.Errorf("Expected ErrVoteTargetNotFound, got %v", err)
+	}
+}
+
+func TestVote_SurvivesSnapshot(t *testing.T) {
+	s := NewQuoraService()
+	question, _ := s.CreateQuestion("asker", "Q", "", nil)
+	s.Vote(question.ID, "alice", 1)
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restored := NewQuoraService()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Changing the restored vote must take the old one back
+	restored.Vote(question.ID, "alice", -1)
+	if up, down, _ := counts(t, restored, question.ID); up != 0 || down != 1 {
+		t.Errorf("Expected 0 up and 1 down, got %d and %d", up, down)
+	}
+}
+
+func TestVoteBatchHandler(t *testing.T) {
+	service = NewQuoraService()
+	question, _ := service.CreateQuestion("asker", "Q", "", nil)
+	answer, _ := service.CreateAnswer(question.ID, "answerer", "A")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	body := `{"user_id": "alice", "votes": [
+		{"target_id": "` + question.ID + `", "direction": 1},
+		{"target_id": "missing", "direction": 1},
+		{"target_id": "` + answer.ID + `", "direction": -1}
+	]}`
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/vote/batch", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var results []voteResult
+	json.NewDecoder(w.Body).Decode(&results)
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %v", results)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusNotFound, http.StatusOK} {
+		if results[i].Status != want {
+			t.Errorf("Vote %d: expected status %d, got %+v", i, want, results[i])
+		}
+	}
+	if service.GetVote(question.ID, "alice") != 1 || service.GetVote(answer.ID, "alice") != -1 {
+		t.Error("Expected both valid votes applied")
+	}
+
+	for _, body := range []string{
+		`{"user_id": "alice", "votes": []}`,
+		`{"votes": [{"target_id": "x", "direction": 1}]}`,
+		`{"user_id": "alice", "votes": [{"target_id": "x", "direction": 3}]}`,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/vote/batch", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
@@ -1,3 +1,14 @@
 module tinyurl
 
 go 1.21.5
+
+require (
+	common v0.0.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+)
+
+replace common => ../common
+
+require common v0.0.0
+
+replace common => ../common
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// base62Alphabet is the unsalted alphabet of counter codes
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// HashStrategy turns a long URL into a candidate short code. Codes that are
+// already taken are retried with the next attempt, so a strategy must
+// return a different code for each attempt.
+type HashStrategy interface {
+	// Name identifies the strategy, as accepted by HashStrategyByName
+	Name() string
+	// Generate returns a candidate code for longURL. attempt is 0 for the
+	// first candidate and counts collisions after that.
+	Generate(longURL, salt string, attempt int) string
+}
+
+// MD5Strategy makes 8 hex character codes from an MD5 of the salted URL
+// and the current time. It is the default: compact, but with only 32 bits
+// of code space collisions become likely past tens of thousands of links.
+type MD5Strategy struct{}
+
+// Name returns "md5"
+func (MD5Strategy) Name() string { return "md5" }
+
+// Generate returns the first 8 hex characters of the hash
+func (MD5Strategy) Generate(longURL, salt string, attempt int) string {
+	hash := md5.Sum([]byte(salt + longURL + time.Now().String() + strconv.Itoa(attempt)))
+	return hex.EncodeToString(hash[:])[:8]
+}
+
+// SHA256Strategy makes 12 hex character codes from a SHA-256 of the salted
+// URL and the current time, trading length for 48 bits of code space
+type SHA256Strategy struct{}
+
+// Name returns "sha256"
+func (SHA256Strategy) Name() string { return "sha256" }
+
+// Generate returns the first 12 hex characters of the hash
+func (SHA256Strategy) Generate(longURL, salt string, attempt int) string {
+	hash := sha256.Sum256([]byte(salt + longURL + time.Now().String() + strconv.Itoa(attempt)))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// CounterStrategy numbers links in base62, giving the shortest possible
+// codes that never collide with each other. The salt shuffles the alphabet
+// so codes aren't trivially enumerable. The counter lives in memory: after
+// a restart it skips past restored codes one collision at a time.
+type CounterStrategy struct {
+	next uint64
+
+	mu        sync.Mutex
+	alphabets map[string]string // salt -> shuffled alphabet
+}
+
+// NewCounterStrategy creates a counter strategy starting at 1
+func NewCounterStrategy() *CounterStrategy {
+	return &CounterStrategy{alphabets: make(map[string]string)}
+}
+
+// Name returns "counter"
+func (c *CounterStrategy) Name() string { return "counter" }
+
+// Generate returns the next counter value in the salt's alphabet. longURL
+// and attempt are ignored; every call advances the counter.
+func (c *CounterStrategy) Generate(longURL, salt string, attempt int) string {
+	alphabet := c.alphabet(salt)
+	n := atomic.AddUint64(&c.next, 1)
+
+	var code []byte
+	for ; n > 0; n /= 62 {
+		code = append(code, alphabet[n%62])
+	}
+	slices.Reverse(code)
+	return string(code)
+}
+
+// alphabet returns base62Alphabet in an order determined by salt
+func (c *CounterStrategy) alphabet(salt string) string {
+	if salt == "" {
+		return base62Alphabet
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if alphabet, ok := c.alphabets[salt]; ok {
+		return alphabet
+	}
+
+	weights := make(map[byte]uint64, len(base62Alphabet))
+	for i := 0; i < len(base62Alphabet); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(salt))
+		h.Write([]byte{base62Alphabet[i]})
+		weights[base62Alphabet[i]] = h.Sum64()
+	}
+	shuffled := []byte(base62Alphabet)
+	sort.Slice(shuffled, func(i, j int) bool { return weights[shuffled[i]] < weights[shuffled[j]] })
+
+	c.alphabets[salt] = string(shuffled)
+	return c.alphabets[salt]
+}
+
+// HashStrategyByName returns the strategy called name: md5, sha256 or
+// counter
+func HashStrategyByName(name string) (HashStrategy, error) {
+	switch name {
+	case "md5":
+		return MD5Strategy{}, nil
+	case "sha256":
+		return SHA256Strategy{}, nil
+	case "counter":
+		return NewCounterStrategy(), nil
+	}
+	return nil, fmt.Errorf("unknown hash strategy %q", name)
+}
+
+// serviceOptionsFromEnv reads the code strategy from TINYURL_HASH (md5 when
+// unset), its salt from TINYURL_SALT and whether to track referrals from
+// TINYURL_REFERRALS
+func serviceOptionsFromEnv() (ServiceOptions, error) {
+	opts := ServiceOptions{Salt: os.Getenv("TINYURL_SALT")}
+	if track, err := strconv.ParseBool(os.Getenv("TINYURL_REFERRALS")); err == nil {
+		opts.TrackReferrals = track
+	}
+	if name := os.Getenv("TINYURL_HASH"); name != "" {
+		hash, err := HashStrategyByName(name)
+		if err != nil {
+			return ServiceOptions{}, err
+		}
+		opts.Hash = hash
+	}
+	return opts, nil
+}
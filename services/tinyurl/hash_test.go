@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestHashStrategies_CodeShape(t *testing.T) {
+	tests := []struct {
+		strategy HashStrategy
+		pattern  *regexp.Regexp
+	}{
+		{MD5Strategy{}, regexp.MustCompile(`^[0-9a-f]{8}$`)},
+		{SHA256Strategy{}, regexp.MustCompile(`^[0-9a-f]{12}$`)},
+		{NewCounterStrategy(), regexp.MustCompile(`^[0-9A-Za-z]{1,3}$`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.Name(), func(t *testing.T) {
+			service := NewTinyURLServiceWithOptions("http://test.com", ServiceOptions{Hash: tt.strategy, Salt: "pepper"})
+			seen := make(map[string]bool)
+			for i := 0; i < 1000; i++ {
+				mapping, err := service.CreateShortURL(fmt.Sprintf("https://example.com/%d", i), "", 0)
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if !tt.pattern.MatchString(mapping.ShortURL) {
+					t.Fatalf("Expected a code matching %s, got %q", tt.pattern, mapping.ShortURL)
+				}
+				if seen[mapping.ShortURL] {
+					t.Fatalf("Expected unique codes, got %q twice", mapping.ShortURL)
+				}
+				seen[mapping.ShortURL] = true
+			}
+		})
+	}
+}
+
+func TestHashStrategies_Dedup(t *testing.T) {
+	for _, name := range []string{"md5", "sha256", "counter"} {
+		for _, salt := range []string{"", "pepper"} {
+			strategy, _ := HashStrategyByName(name)
+			service := NewTinyURLServiceWithOptions("http://test.com", ServiceOptions{Hash: strategy, Salt: salt})
+
+			first, _ := service.CreateShortURL("https://example.com/page", "", 0)
+			service.CreateShortURL("https://example.com/other", "", 0)
+			second, _ := service.CreateShortURL("https://example.com/page", "", 0)
+			if first.ShortURL != second.ShortURL {
+				t.Errorf("%s/%q: expected the same URL to dedupe, got %q and %q", name, salt, first.ShortURL, second.ShortURL)
+			}
+		}
+	}
+}
+
+func TestCounterStrategy_SaltShufflesAlphabet(t *testing.T) {
+	plain, salted, resalted := NewCounterStrategy(), NewCounterStrategy(), NewCounterStrategy()
+
+	var plainCodes, saltedCodes []string
+	for i := 0; i < 100; i++ {
+		plainCodes = append(plainCodes, plain.Generate("", "", 0))
+		saltedCodes = append(saltedCodes, salted.Generate("", "pepper", 0))
+		if again := resalted.Generate("", "pepper", 0); again != saltedCodes[i] {
+			t.Fatalf("Expected a salt to give the same sequence, got %q and %q", saltedCodes[i], again)
+		}
+	}
+	if plainCodes[0] != "1" || plainCodes[60] != "z" || plainCodes[61] != "10" {
+		t.Errorf("Expected unsalted codes 1, ..., z, 10, got %v", plainCodes[:62])
+	}
+	if fmt.Sprint(plainCodes) == fmt.Sprint(saltedCodes) {
+		t.Error("Expected a salt to change the codes")
+	}
+}
+
+func TestCounterStrategy_SkipsTakenCodes(t *testing.T) {
+	service := NewTinyURLServiceWithOptions("http://test.com", ServiceOptions{Hash: NewCounterStrategy()})
+	service.CreateShortURL("https://example.com/alias", "1", 0)
+
+	mapping, err := service.CreateShortURL("https://example.com/generated", "", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mapping.ShortURL != "2" {
+		t.Errorf("Expected the counter to skip the taken code, got %q", mapping.ShortURL)
+	}
+}
+
+func TestHashStrategyByName_Unknown(t *testing.T) {
+	if _, err := HashStrategyByName("crc32"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"common/apierror"
+	"common/jsonstream"
+)
+
+// SortField is the mapping field a listing is ordered by
+type SortField string
+
+// Sort fields accepted by ListMappings
+const (
+	SortByCreatedAt   SortField = "created_at"
+	SortByAccessCount SortField = "access_count"
+)
+
+// SortOrder is the direction a listing is ordered in
+type SortOrder string
+
+// Sort orders accepted by ListMappings
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+var (
+	// ErrUnknownSortField is returned for a sort field other than
+	// created_at or access_count
+	ErrUnknownSortField = errors.New("unknown sort field")
+	// ErrUnknownSortOrder is returned for an order other than asc or desc
+	ErrUnknownSortOrder = errors.New("unknown sort order")
+)
+
+// TotalCountHeader carries the number of mappings across all pages of a
+// /list response
+const TotalCountHeader = "X-Total-Count"
+
+// ListOptions selects the order and window of a listing. The zero value
+// lists every mapping oldest first.
+type ListOptions struct {
+	SortBy SortField // created_at when empty
+	Order  SortOrder // asc when empty
+	Offset int
+	Limit  int // 0 means no limit
+}
+
+// MappingPage is one window of an ordered listing
+type MappingPage struct {
+	Mappings []*URLMapping `json:"mappings"`
+	Total    int           `json:"total"`
+	Offset   int           `json:"offset"`
+	Limit    int           `json:"limit"`
+}
+
+// listedMapping pairs a mapping with its access count read under the shard
+// lock, so sorting doesn't race with redirects
+type listedMapping struct {
+	mapping     *URLMapping
+	accessCount int64
+}
+
+// ListMappings returns a window of the mappings in a stable order. Ties on
+// the sort field are broken by short URL, so the same store always lists
+// the same way and consecutive pages neither repeat nor skip mappings.
+func (s *TinyURLService) ListMappings(opts ListOptions) (*MappingPage, error) {
+	if opts.SortBy == "" {
+		opts.SortBy = SortByCreatedAt
+	}
+	if opts.Order == "" {
+		opts.Order = SortAsc
+	}
+	if opts.Order != SortAsc && opts.Order != SortDesc {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSortOrder, opts.Order)
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	if opts.Limit < 0 {
+		opts.Limit = 0
+	}
+
+	var compare func(a, b listedMapping) int
+	switch opts.SortBy {
+	case SortByCreatedAt:
+		compare = func(a, b listedMapping) int { return a.mapping.CreatedAt.Compare(b.mapping.CreatedAt) }
+	case SortByAccessCount:
+		compare = func(a, b listedMapping) int { return cmp.Compare(a.accessCount, b.accessCount) }
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownSortField, opts.SortBy)
+	}
+
+	listed := make([]listedMapping, 0, s.store.len())
+	s.store.each(func(mapping *URLMapping) {
+		listed = append(listed, listedMapping{mapping: mapping, accessCount: mapping.AccessCount})
+	})
+
+	sort.Slice(listed, func(i, j int) bool {
+		c := compare(listed[i], listed[j])
+		if c == 0 {
+			return listed[i].mapping.ShortURL < listed[j].mapping.ShortURL
+		}
+		if opts.Order == SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	page := &MappingPage{Mappings: []*URLMapping{}, Total: len(listed), Offset: opts.Offset, Limit: opts.Limit}
+	if opts.Offset < len(listed) {
+		end := len(listed)
+		// Compared against what's left so a huge limit can't overflow
+		if opts.Limit > 0 && opts.Limit < end-opts.Offset {
+			end = opts.Offset + opts.Limit
+		}
+		for _, l := range listed[opts.Offset:end] {
+			page.Mappings = append(page.Mappings, l.mapping)
+		}
+	}
+	return page, nil
+}
+
+// listOptionsFromQuery reads sort, order, offset and limit from r's query
+func listOptionsFromQuery(r *http.Request) (ListOptions, error) {
+	query := r.URL.Query()
+	opts := ListOptions{
+		SortBy: SortField(query.Get("sort")),
+		Order:  SortOrder(query.Get("order")),
+	}
+	params := []struct {
+		name string
+		dst  *int
+	}{{"offset", &opts.Offset}, {"limit", &opts.Limit}}
+	for _, p := range params {
+		value := query.Get(p.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return ListOptions{}, fmt.Errorf("invalid %s", p.name)
+		}
+		*p.dst = n
+	}
+	return opts, nil
+}
+
+func listHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := service.ListMappings(opts)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The body stays a plain array for existing clients; the total for
+	// paging goes in a header
+	w.Header().Set(TotalCountHeader, strconv.Itoa(page.Total))
+	for i, mapping := range page.Mappings {
+		page.Mappings[i] = mapping.redacted()
+	}
+	// Stream the mappings so a large store isn't encoded in one buffer
+	jsonstream.WriteArray(w, page.Mappings)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// listFixture creates mappings with aliases a..f, created a minute apart in
+// that order, with access counts 3, 5, 1, 5, 0 and 2
+func listFixture(t *testing.T) *TinyURLService {
+	t.Helper()
+	service := NewShardedTinyURLService("http://test.com", 4)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, count := range []int64{3, 5, 1, 5, 0, 2} {
+		alias := string(rune('a' + i))
+		mapping, err := service.CreateShortURL(fmt.Sprintf("https://example.com/%s", alias), alias, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		mapping.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		mapping.AccessCount = count
+	}
+	return service
+}
+
+func shortURLs(mappings []*URLMapping) []string {
+	codes := make([]string, len(mappings))
+	for i, mapping := range mappings {
+		codes[i] = mapping.ShortURL
+	}
+	return codes
+}
+
+func TestListMappings_AccessCountDescIsStable(t *testing.T) {
+	service := listFixture(t)
+
+	// b and d tie on 5 and are ordered by short URL, every time
+	want := []string{"b", "d", "a", "f", "c", "e"}
+	for i := 0; i < 20; i++ {
+		page, err := service.ListMappings(ListOptions{SortBy: SortByAccessCount, Order: SortDesc})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := shortURLs(page.Mappings); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestListMappings_DefaultsToCreatedAtAsc(t *testing.T) {
+	service := listFixture(t)
+
+	page, _ := service.ListMappings(ListOptions{})
+	if want := []string{"a", "b", "c", "d", "e", "f"}; !reflect.DeepEqual(shortURLs(page.Mappings), want) {
+		t.Errorf("Expected %v, got %v", want, shortURLs(page.Mappings))
+	}
+
+	page, _ = service.ListMappings(ListOptions{Order: SortDesc})
+	if want := []string{"f", "e", "d", "c", "b", "a"}; !reflect.DeepEqual(shortURLs(page.Mappings), want) {
+		t.Errorf("Expected %v, got %v", want, shortURLs(page.Mappings))
+	}
+}
+
+func TestListMappings_Pagination(t *testing.T) {
+	service := listFixture(t)
+	opts := ListOptions{SortBy: SortByAccessCount, Order: SortDesc, Limit: 4}
+
+	windows := []struct {
+		offset int
+		want   []string
+	}{
+		{0, []string{"b", "d", "a", "f"}},
+		{4, []string{"c", "e"}},
+		{6, []string{}},
+		{10, []string{}},
+	}
+	for _, window := range windows {
+		opts.Offset = window.offset
+		page, err := service.ListMappings(opts)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := shortURLs(page.Mappings); !reflect.DeepEqual(got, window.want) {
+			t.Errorf("Offset %d: expected %v, got %v", window.offset, window.want, got)
+		}
+		if page.Total != 6 || page.Offset != window.offset || page.Limit != 4 {
+			t.Errorf("Offset %d: expected total 6, offset %d, limit 4, got %+v", window.offset, window.offset, page)
+		}
+	}
+}
+
+func TestListMappings_HugeLimit(t *testing.T) {
+	service = listFixture(t)
+
+	page, err := service.ListMappings(ListOptions{Offset: 1, Limit: math.MaxInt})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := []string{"b", "c", "d", "e", "f"}; !reflect.DeepEqual(shortURLs(page.Mappings), want) {
+		t.Errorf("Expected %v, got %v", want, shortURLs(page.Mappings))
+	}
+
+	w := httptest.NewRecorder()
+	listHandler(w, httptest.NewRequest(http.MethodGet, "/list?offset=1&limit=9223372036854775807", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestListMappings_UnknownSort(t *testing.T) {
+	service := listFixture(t)
+	if _, err := service.ListMappings(ListOptions{SortBy: "long_url"}); !errors.Is(err, ErrUnknownSortField) {
+		t.Errorf("Expected ErrUnknownSortField, got %v", err)
+	}
+	if _, err := service.ListMappings(ListOptions{Order: "sideways"}); !errors.Is(err, ErrUnknownSortOrder) {
+		t.Errorf("Expected ErrUnknownSortOrder, got %v", err)
+	}
+}
+
+func TestListHandler_QueryParams(t *testing.T) {
+	service = listFixture(t)
+
+	w := httptest.NewRecorder()
+	listHandler(w, httptest.NewRequest(http.MethodGet, "/list?sort=access_count&order=desc&offset=1&limit=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var mappings []*URLMapping
+	json.NewDecoder(w.Body).Decode(&mappings)
+	if want := []string{"d", "a"}; !reflect.DeepEqual(shortURLs(mappings), want) {
+		t.Errorf("Expected %v, got %v", want, shortURLs(mappings))
+	}
+	if got := w.Header().Get(TotalCountHeader); got != "6" {
+		t.Errorf("Expected %s 6, got %q", TotalCountHeader, got)
+	}
+
+	for _, query := range []string{"sort=nope", "order=up", "limit=-1", "offset=x"} {
+		w := httptest.NewRecorder()
+		listHandler(w, httptest.NewRequest(http.MethodGet, "/list?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
@@ -1,142 +1,331 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"container/heap"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/health"
+	"common/middleware"
+	"common/openapi"
+	"common/validate"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// topURLCount is the number of most-accessed short URLs reported by GetServiceStats
+const topURLCount = 10
+
+// QR code image size bounds in pixels
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
 )
 
+// maxAliasLength caps custom aliases
+const maxAliasLength = 64
+
 // URLMapping represents a URL shortening entry
 type URLMapping struct {
-	ShortURL    string    `json:"short_url"`
-	LongURL     string    `json:"long_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	AccessCount int64     `json:"access_count"`
-	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+	ShortURL     string    `json:"short_url"`
+	LongURL      string    `json:"long_url"`
+	CreatedAt    time.Time `json:"created_at"`
+	AccessCount  int64     `json:"access_count"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	RedirectType int       `json:"redirect_type"` // 301 or 302
+	Protected    bool      `json:"protected"`
+
+	// Salted SHA-256 of the link password; never serialized
+	passwordHash []byte
+	passwordSalt []byte
+
+	referrals *referralStats // created on first use under the shard lock; never serialized
+}
+
+// redacted returns m as it may be shown without the link password: a
+// protected mapping's long URL is withheld, as in PreviewURL
+func (m *URLMapping) redacted() *URLMapping {
+	if !m.Protected {
+		return m
+	}
+	copied := *m
+	copied.LongURL = ""
+	return &copied
+}
+
+// ErrPasswordRequired is returned when a protected short URL is accessed
+// without the correct password
+var ErrPasswordRequired = errors.New("password required or incorrect")
+
+// CreateOptions holds optional settings for a new short URL
+type CreateOptions struct {
+	CustomAlias  string
+	TTL          time.Duration
+	RedirectType int    // defaults to 301 Moved Permanently
+	Password     string // optional; required to follow the link
+}
+
+// ServiceOptions configures a new TinyURLService
+type ServiceOptions struct {
+	Shards int          // defaults to DefaultShardCount
+	Hash   HashStrategy // defaults to MD5Strategy
+	Salt   string       // mixed into every generated code
+
+	TrackReferrals bool // count referrers, browsers, devices and countries per redirect
 }
 
 // TinyURLService handles URL shortening operations
 type TinyURLService struct {
-	mu       sync.RWMutex
-	mappings map[string]*URLMapping
-	reverse  map[string]string // longURL -> shortURL for deduplication
-	baseURL  string
+	store   *shardedStore
+	baseURL string
+	hash    HashStrategy
+	salt    string
+
+	trackReferrals bool
+
+	totalRedirects int64
+}
+
+// ServiceStats holds aggregate statistics across all mappings
+type ServiceStats struct {
+	TotalMappings  int           `json:"total_mappings"`
+	TotalRedirects int64         `json:"total_redirects"`
+	TopURLs        []*URLMapping `json:"top_urls"`
+	ExpiredPending int           `json:"expired_pending"` // expired but not yet removed
 }
 
 // NewTinyURLService creates a new TinyURL service
 func NewTinyURLService(baseURL string) *TinyURLService {
+	return NewShardedTinyURLService(baseURL, DefaultShardCount)
+}
+
+// NewShardedTinyURLService creates a TinyURL service whose mappings are
+// spread across the given number of shards
+func NewShardedTinyURLService(baseURL string, shards int) *TinyURLService {
+	return NewTinyURLServiceWithOptions(baseURL, ServiceOptions{Shards: shards})
+}
+
+// NewTinyURLServiceWithOptions creates a TinyURL service that generates
+// codes with opts.Hash and opts.Salt
+func NewTinyURLServiceWithOptions(baseURL string, opts ServiceOptions) *TinyURLService {
+	if opts.Shards <= 0 {
+		opts.Shards = DefaultShardCount
+	}
+	if opts.Hash == nil {
+		opts.Hash = MD5Strategy{}
+	}
 	return &TinyURLService{
-		mappings: make(map[string]*URLMapping),
-		reverse:  make(map[string]string),
-		baseURL:  baseURL,
+		store:   newShardedStore(opts.Shards),
+		baseURL: baseURL,
+		hash:    opts.Hash,
+		salt:    opts.Salt,
+
+		trackReferrals: opts.TrackReferrals,
 	}
 }
 
-// GenerateShortURL generates a short URL from a long URL
+// GenerateShortURL generates a short URL from a long URL with the
+// service's hash strategy
 func (s *TinyURLService) GenerateShortURL(longURL string) string {
-	hash := md5.Sum([]byte(longURL + time.Now().String()))
-	return hex.EncodeToString(hash[:])[:8]
+	return s.hash.Generate(longURL, s.salt, 0)
 }
 
 // CreateShortURL creates a new short URL
 func (s *TinyURLService) CreateShortURL(longURL string, customAlias string, ttl time.Duration) (*URLMapping, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.CreateShortURLWithOptions(longURL, CreateOptions{
+		CustomAlias: customAlias,
+		TTL:         ttl,
+	})
+}
+
+// CreateShortURLWithOptions creates a new short URL with the given options
+func (s *TinyURLService) CreateShortURLWithOptions(longURL string, opts CreateOptions) (*URLMapping, error) {
+	customAlias := opts.CustomAlias
+	ttl := opts.TTL
+
+	redirectType := opts.RedirectType
+	if redirectType == 0 {
+		redirectType = http.StatusMovedPermanently
+	}
+	if redirectType != http.StatusMovedPermanently && redirectType != http.StatusFound {
+		return nil, fmt.Errorf("redirect type must be 301 or 302")
+	}
 
-	// Check if long URL already exists
-	if shortURL, exists := s.reverse[longURL]; exists {
-		return s.mappings[shortURL], nil
+	// Every insert holds the reverse index lock, so checking a code and
+	// then storing it can't race with another create
+	s.store.reverseMu.Lock()
+	defer s.store.reverseMu.Unlock()
+
+	// Check if long URL already exists. Protected links are never shared
+	// with other callers, so they skip deduplication.
+	if opts.Password == "" {
+		if shortURL, exists := s.store.reverse[longURL]; exists {
+			if mapping, exists := s.store.get(shortURL); exists {
+				return mapping, nil
+			}
+		}
 	}
 
 	var shortURL string
 	if customAlias != "" {
 		// Check if custom alias is available
-		if _, exists := s.mappings[customAlias]; exists {
+		if _, exists := s.store.get(customAlias); exists {
 			return nil, fmt.Errorf("custom alias already exists")
 		}
 		shortURL = customAlias
 	} else {
 		shortURL = s.GenerateShortURL(longURL)
 		// Handle collision
-		for {
-			if _, exists := s.mappings[shortURL]; !exists {
+		for attempt := 1; ; attempt++ {
+			if _, exists := s.store.get(shortURL); !exists {
 				break
 			}
-			shortURL = s.GenerateShortURL(longURL + time.Now().String())
+			shortURL = s.hash.Generate(longURL, s.salt, attempt)
 		}
 	}
 
 	mapping := &URLMapping{
-		ShortURL:    shortURL,
-		LongURL:     longURL,
-		CreatedAt:   time.Now(),
-		AccessCount: 0,
+		ShortURL:     shortURL,
+		LongURL:      longURL,
+		CreatedAt:    time.Now(),
+		AccessCount:  0,
+		RedirectType: redirectType,
 	}
 
 	if ttl > 0 {
 		mapping.ExpiresAt = time.Now().Add(ttl)
 	}
 
-	s.mappings[shortURL] = mapping
-	s.reverse[longURL] = shortURL
+	if opts.Password != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %v", err)
+		}
+		mapping.Protected = true
+		mapping.passwordSalt = salt
+		mapping.passwordHash = hashPassword(opts.Password, salt)
+	}
+
+	s.store.put(mapping)
 
 	return mapping, nil
 }
 
+// hashPassword returns the salted SHA-256 hash of a password
+func hashPassword(password string, salt []byte) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(password))
+	return h.Sum(nil)
+}
+
+// checkPassword verifies the password for a mapping
+func checkPassword(mapping *URLMapping, password string) error {
+	if !mapping.Protected {
+		return nil
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if subtle.ConstantTimeCompare(hashPassword(password, mapping.passwordSalt), mapping.passwordHash) != 1 {
+		return ErrPasswordRequired
+	}
+	return nil
+}
+
 // GetLongURL retrieves the long URL for a short URL
 func (s *TinyURLService) GetLongURL(shortURL string) (*URLMapping, error) {
-	s.mu.RLock()
-	mapping, exists := s.mappings[shortURL]
-	s.mu.RUnlock()
+	return s.GetLongURLWithPassword(shortURL, "")
+}
 
+// GetLongURLWithPassword retrieves the long URL for a short URL, verifying
+// the password if the link is protected
+func (s *TinyURLService) GetLongURLWithPassword(shortURL, password string) (*URLMapping, error) {
+	sh := s.store.shardFor(shortURL)
+	mapping, exists := sh.get(shortURL)
 	if !exists {
 		return nil, fmt.Errorf("short URL not found")
 	}
 
 	// Check expiration
 	if !mapping.ExpiresAt.IsZero() && time.Now().After(mapping.ExpiresAt) {
-		s.mu.Lock()
-		delete(s.mappings, shortURL)
-		delete(s.reverse, mapping.LongURL)
-		s.mu.Unlock()
+		s.store.remove(mapping)
 		return nil, fmt.Errorf("short URL expired")
 	}
 
-	// Increment access count
-	s.mu.Lock()
+	if err := checkPassword(mapping, password); err != nil {
+		return nil, err
+	}
+
+	// Increment access count under the shard lock only
+	sh.mu.Lock()
 	mapping.AccessCount++
-	s.mu.Unlock()
+	sh.mu.Unlock()
+	atomic.AddInt64(&s.totalRedirects, 1)
+
+	return mapping, nil
+}
+
+// PreviewURL returns the mapping for a short URL without counting an access.
+// Protected links require the password so the target isn't leaked.
+func (s *TinyURLService) PreviewURL(shortURL, password string) (*URLMapping, error) {
+	mapping, exists := s.store.get(shortURL)
+	if !exists {
+		return nil, fmt.Errorf("short URL not found")
+	}
+
+	if !mapping.ExpiresAt.IsZero() && time.Now().After(mapping.ExpiresAt) {
+		return nil, fmt.Errorf("short URL expired")
+	}
+
+	if err := checkPassword(mapping, password); err != nil {
+		return nil, err
+	}
 
 	return mapping, nil
 }
 
 // DeleteShortURL deletes a short URL
 func (s *TinyURLService) DeleteShortURL(shortURL string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.store.reverseMu.Lock()
+	defer s.store.reverseMu.Unlock()
 
-	mapping, exists := s.mappings[shortURL]
+	mapping, exists := s.store.get(shortURL)
 	if !exists {
 		return fmt.Errorf("short URL not found")
 	}
 
-	delete(s.mappings, shortURL)
-	delete(s.reverse, mapping.LongURL)
+	s.store.removeLocked(mapping)
 
 	return nil
 }
 
+// DeleteByPrefix deletes every short URL whose long URL starts with prefix,
+// such as every link to one host, and returns how many were deleted
+func (s *TinyURLService) DeleteByPrefix(prefix string) int {
+	return s.store.removeWhere(func(mapping *URLMapping) bool {
+		return strings.HasPrefix(mapping.LongURL, prefix)
+	})
+}
+
 // GetStats returns statistics for a short URL
 func (s *TinyURLService) GetStats(shortURL string) (*URLMapping, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	mapping, exists := s.mappings[shortURL]
+	mapping, exists := s.store.get(shortURL)
 	if !exists {
 		return nil, fmt.Errorf("short URL not found")
 	}
@@ -144,42 +333,179 @@ func (s *TinyURLService) GetStats(shortURL string) (*URLMapping, error) {
 	return mapping, nil
 }
 
-// ListAllMappings returns all URL mappings
+// ListAllMappings returns all URL mappings, gathered shard by shard
 func (s *TinyURLService) ListAllMappings() []*URLMapping {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	mappings := make([]*URLMapping, 0, len(s.mappings))
-	for _, mapping := range s.mappings {
+	mappings := make([]*URLMapping, 0, s.store.len())
+	s.store.each(func(mapping *URLMapping) {
 		mappings = append(mappings, mapping)
-	}
+	})
 
 	return mappings
 }
 
+// FullShortURL returns the complete short URL (base URL plus code)
+func (s *TinyURLService) FullShortURL(shortURL string) string {
+	return strings.TrimSuffix(s.baseURL, "/") + "/" + shortURL
+}
+
+// GetServiceStats returns aggregate statistics across all mappings
+func (s *TinyURLService) GetServiceStats() *ServiceStats {
+	stats := &ServiceStats{
+		TotalRedirects: atomic.LoadInt64(&s.totalRedirects),
+	}
+
+	// Keep the top N in a min-heap so we never sort every mapping
+	now := time.Now()
+	top := &accessCountHeap{}
+	s.store.each(func(mapping *URLMapping) {
+		stats.TotalMappings++
+		if !mapping.ExpiresAt.IsZero() && now.After(mapping.ExpiresAt) {
+			stats.ExpiredPending++
+		}
+
+		if top.Len() < topURLCount {
+			heap.Push(top, mapping)
+		} else if mapping.AccessCount > (*top)[0].AccessCount {
+			(*top)[0] = mapping
+			heap.Fix(top, 0)
+		}
+	})
+
+	stats.TopURLs = make([]*URLMapping, top.Len())
+	for i := len(stats.TopURLs) - 1; i >= 0; i-- {
+		stats.TopURLs[i] = heap.Pop(top).(*URLMapping)
+	}
+
+	return stats
+}
+
+// accessCountHeap is a min-heap of mappings ordered by access count
+type accessCountHeap []*URLMapping
+
+func (h accessCountHeap) Len() int           { return len(h) }
+func (h accessCountHeap) Less(i, j int) bool { return h[i].AccessCount < h[j].AccessCount }
+func (h accessCountHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *accessCountHeap) Push(x interface{}) {
+	*h = append(*h, x.(*URLMapping))
+}
+
+func (h *accessCountHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// mappingState is the serialized form of a mapping, including the password
+// hash that URLMapping keeps out of API responses
+type mappingState struct {
+	URLMapping
+	PasswordHash []byte `json:"password_hash,omitempty"`
+	PasswordSalt []byte `json:"password_salt,omitempty"`
+}
+
+// tinyURLState is the serialized form of the whole service. The long URL
+// index is not stored: it is rebuilt from the mappings.
+type tinyURLState struct {
+	Mappings       []mappingState `json:"mappings"`
+	TotalRedirects int64          `json:"total_redirects"`
+}
+
+// Snapshot serializes every mapping, password hashes included, and the
+// redirect counter to JSON. Shards are copied one at a time, so mappings
+// created during the snapshot may or may not be included.
+func (s *TinyURLService) Snapshot() ([]byte, error) {
+	state := tinyURLState{
+		Mappings:       make([]mappingState, 0, s.store.len()),
+		TotalRedirects: atomic.LoadInt64(&s.totalRedirects),
+	}
+	s.store.each(func(mapping *URLMapping) {
+		state.Mappings = append(state.Mappings, mappingState{
+			URLMapping:   *mapping,
+			PasswordHash: mapping.passwordHash,
+			PasswordSalt: mapping.passwordSalt,
+		})
+	})
+	sort.Slice(state.Mappings, func(i, j int) bool {
+		return state.Mappings[i].ShortURL < state.Mappings[j].ShortURL
+	})
+
+	return json.Marshal(state)
+}
+
+// Restore replaces every mapping with those in a snapshot, redistributing
+// them across the current shards and rebuilding the long URL index
+func (s *TinyURLService) Restore(data []byte) error {
+	var state tinyURLState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	mappings := make([]*URLMapping, 0, len(state.Mappings))
+	seen := make(map[string]bool, len(state.Mappings))
+	for _, ms := range state.Mappings {
+		if ms.ShortURL == "" {
+			return fmt.Errorf("mapping without a short url")
+		}
+		if seen[ms.ShortURL] {
+			return fmt.Errorf("duplicate short url %s", ms.ShortURL)
+		}
+		if ms.Protected && len(ms.PasswordHash) == 0 {
+			return fmt.Errorf("protected mapping %s has no password hash", ms.ShortURL)
+		}
+		seen[ms.ShortURL] = true
+
+		mapping := ms.URLMapping
+		mapping.passwordHash = ms.PasswordHash
+		mapping.passwordSalt = ms.PasswordSalt
+		mappings = append(mappings, &mapping)
+	}
+
+	s.store.replace(mappings)
+	atomic.StoreInt64(&s.totalRedirects, state.TotalRedirects)
+
+	return nil
+}
+
 // HTTP Handlers
 
 var service *TinyURLService
 
+// createRequest is the body of /create
+type createRequest struct {
+	LongURL      string `json:"long_url"`
+	CustomAlias  string `json:"custom_alias,omitempty"`
+	TTLSeconds   int    `json:"ttl_seconds,omitempty"`
+	RedirectType int    `json:"redirect_type,omitempty"`
+	Password     string `json:"password,omitempty"`
+}
+
+func (req createRequest) validate() error {
+	var v validate.Validator
+	v.String("long_url", req.LongURL, validate.Required, validate.URL)
+	v.String("custom_alias", req.CustomAlias, validate.MaxLen(maxAliasLength))
+	v.Int("ttl_seconds", req.TTLSeconds, validate.Min(0))
+	v.Int("redirect_type", req.RedirectType, validate.OneOfInt(http.StatusMovedPermanently, http.StatusFound))
+	return v.Err()
+}
+
 func createHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		LongURL      string `json:"long_url"`
-		CustomAlias  string `json:"custom_alias,omitempty"`
-		TTLSeconds   int    `json:"ttl_seconds,omitempty"`
-	}
+	var req createRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.LongURL == "" {
-		http.Error(w, "long_url is required", http.StatusBadRequest)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
 		return
 	}
 
@@ -188,9 +514,14 @@ func createHandler(w http.ResponseWriter, r *http.Request) {
 		ttl = time.Duration(req.TTLSeconds) * time.Second
 	}
 
-	mapping, err := service.CreateShortURL(req.LongURL, req.CustomAlias, ttl)
+	mapping, err := service.CreateShortURLWithOptions(req.LongURL, CreateOptions{
+		CustomAlias:  req.CustomAlias,
+		TTL:          ttl,
+		RedirectType: req.RedirectType,
+		Password:     req.Password,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -201,56 +532,143 @@ func createHandler(w http.ResponseWriter, r *http.Request) {
 func redirectHandler(w http.ResponseWriter, r *http.Request) {
 	shortURL := r.URL.Path[1:] // Remove leading slash
 
-	mapping, err := service.GetLongURL(shortURL)
+	mapping, err := service.GetLongURLWithPassword(shortURL, r.URL.Query().Get("pw"))
+	if errors.Is(err, ErrPasswordRequired) {
+		apierror.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	service.RecordReferral(mapping.ShortURL, visitFromRequest(r))
+	http.Redirect(w, r, mapping.LongURL, mapping.RedirectType)
+}
+
+func previewHandler(w http.ResponseWriter, r *http.Request) {
+	shortURL := r.URL.Query().Get("short_url")
+	if shortURL == "" {
+		apierror.Error(w, "short_url parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	mapping, err := service.PreviewURL(shortURL, r.URL.Query().Get("pw"))
+	if errors.Is(err, ErrPasswordRequired) {
+		apierror.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	http.Redirect(w, r, mapping.LongURL, http.StatusMovedPermanently)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"short_url":     mapping.ShortURL,
+		"long_url":      mapping.LongURL,
+		"redirect_type": mapping.RedirectType,
+	})
 }
 
 func statsHandler(w http.ResponseWriter, r *http.Request) {
 	shortURL := r.URL.Query().Get("short_url")
 	if shortURL == "" {
-		http.Error(w, "short_url parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "short_url parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	mapping, err := service.GetStats(shortURL)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(mapping)
+	json.NewEncoder(w).Encode(mapping.redacted())
 }
 
 func deleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	shortURL := r.URL.Query().Get("short_url")
 	if shortURL == "" {
-		http.Error(w, "short_url parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "short_url parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	if err := service.DeleteShortURL(shortURL); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func listHandler(w http.ResponseWriter, r *http.Request) {
-	mappings := service.ListAllMappings()
+func deleteByPrefixHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// An empty prefix would match every mapping
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		apierror.Error(w, "prefix parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deleteByPrefixResponse{Deleted: service.DeleteByPrefix(prefix)})
+}
+
+// deleteByPrefixResponse is the body returned by /delete-by-prefix
+type deleteByPrefixResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+func qrHandler(w http.ResponseWriter, r *http.Request) {
+	shortURL := r.URL.Query().Get("short_url")
+	if shortURL == "" {
+		apierror.Error(w, "short_url parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	size := defaultQRSize
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		parsed, err := strconv.Atoi(sizeStr)
+		if err != nil || parsed < minQRSize || parsed > maxQRSize {
+			apierror.Error(w, fmt.Sprintf("size must be an integer between %d and %d", minQRSize, maxQRSize), http.StatusBadRequest)
+			return
+		}
+		size = parsed
+	}
+
+	if _, err := service.GetStats(shortURL); err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	png, err := qrcode.Encode(service.FullShortURL(shortURL), qrcode.Medium, size)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := service.GetServiceStats()
+	for i, mapping := range stats.TopURLs {
+		stats.TopURLs[i] = mapping.redacted()
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(mappings)
+	json.NewEncoder(w).Encode(stats)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -258,18 +676,124 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
-func main() {
-	service = NewTinyURLService("http://localhost:8080")
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "TinyURL Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(service.store, time.Second))
+
+	// Creating and deleting short URLs requires a bearer token
+	auth := middleware.AuthFromEnv("JWT_SECRET")
+
+	// Retried creates with the same Idempotency-Key replay the first response
+	idempotency := middleware.NewIdempotencyStore(middleware.DefaultIdempotencyTTL)
+	idempotency.StartJanitor(time.Minute)
+	idempotent := middleware.Idempotency(idempotency)
+
+	// Compress large list responses for clients that accept gzip
+	compress := middleware.Gzip(middleware.DefaultGzipMinBytes)
+
+	// State dumps need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	shortURLQuery := []openapi.Param{{Name: "short_url", Required: true}}
+	passwordParam := openapi.Param{Name: "pw", Description: "Password for protected short URLs"}
+
+	api.Handle("/create", auth(idempotent(http.HandlerFunc(createHandler))), openapi.Route{
+		Method: http.MethodPost, Summary: "Create a short URL",
+		Request: createRequest{}, Response: URLMapping{},
+		Responses: map[int]string{
+			200: "Short URL created",
+			400: "Invalid request",
+			401: "Missing or invalid token",
+			409: "Same Idempotency-Key still in progress",
+			422: "Idempotency-Key reused with a different body",
+		},
+	})
+	api.HandleFunc("/stats", statsHandler, openapi.Route{
+		Summary: "Get the stats for a short URL", Query: shortURLQuery, Response: URLMapping{},
+		Responses: map[int]string{200: "The mapping and its stats", 400: "Missing short_url", 404: "Short URL not found"},
+	})
+	api.Handle("/delete", auth(http.HandlerFunc(deleteHandler)), openapi.Route{
+		Method: http.MethodDelete, Summary: "Delete a short URL", Query: shortURLQuery,
+		Responses: map[int]string{204: "Short URL deleted", 400: "Missing short_url", 401: "Missing or invalid token", 404: "Short URL not found"},
+	})
+	api.Handle("/delete-by-prefix", auth(http.HandlerFunc(deleteByPrefixHandler)), openapi.Route{
+		Method: http.MethodDelete, Summary: "Delete every short URL whose long URL starts with a prefix",
+		Query:     []openapi.Param{{Name: "prefix", Required: true, Description: "Long URL prefix, such as https://example.com/"}},
+		Response:  deleteByPrefixResponse{},
+		Responses: map[int]string{200: "Number of short URLs deleted", 400: "Missing prefix", 401: "Missing or invalid token"},
+	})
+	api.Handle("/list", compress(http.HandlerFunc(listHandler)), openapi.Route{
+		Summary: "List short URLs in a stable order, optionally one page at a time",
+		Query: []openapi.Param{
+			{Name: "sort", Description: "created_at (default) or access_count"},
+			{Name: "order", Description: "asc (default) or desc"},
+			{Name: "offset", Description: "Mappings to skip"},
+			{Name: "limit", Description: "Page size; all mappings when omitted"},
+		},
+		Response:  []URLMapping{},
+		Responses: map[int]string{200: "The page of mappings; X-Total-Count holds the total", 400: "Invalid sort, order, offset or limit"},
+	})
+	api.HandleFunc("/referrals", referralsHandler, openapi.Route{
+		Summary: "Top referrers, browsers, devices and countries of a short URL's redirects",
+		Query:   shortURLQuery, Response: ReferralReport{},
+		Responses: map[int]string{200: "The referral breakdown", 400: "Missing short_url", 404: "Short URL not found"},
+	})
+	api.HandleFunc("/metrics", metricsHandler, openapi.Route{
+		Summary: "Service-wide stats", Response: ServiceStats{},
+		Responses: map[int]string{200: "The stats"},
+	})
+	api.HandleFunc("/qr", qrHandler, openapi.Route{
+		Summary: "Render a QR code for a short URL",
+		Query: []openapi.Param{
+			{Name: "short_url", Required: true},
+			{Name: "size", Description: "Image size in pixels"},
+		},
+		Responses: map[int]string{200: "PNG image", 400: "Invalid short_url or size", 404: "Short URL not found"},
+	})
+	api.HandleFunc("/preview", previewHandler, openapi.Route{
+		Summary:   "Show where a short URL points without following it",
+		Query:     []openapi.Param{{Name: "short_url", Required: true}, passwordParam},
+		Responses: map[int]string{200: "The destination", 400: "Missing short_url", 401: "Password required", 404: "Short URL not found"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(tinyURLState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.HandleFunc("/", redirectHandler, openapi.Route{
+		Path: "/{short_url}", Summary: "Redirect to the long URL", Query: []openapi.Param{passwordParam},
+		Responses: map[int]string{301: "Permanent redirect", 302: "Temporary redirect", 401: "Password required", 404: "Short URL not found"},
+	})
+	api.Mount()
+
+	return api
+}
 
-	http.HandleFunc("/create", createHandler)
-	http.HandleFunc("/stats", statsHandler)
-	http.HandleFunc("/delete", deleteHandler)
-	http.HandleFunc("/list", listHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/", redirectHandler)
+func main() {
+	opts, err := serviceOptionsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	service = NewTinyURLServiceWithOptions("http://localhost:8080", opts)
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8080"
 	log.Printf("TinyURL service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
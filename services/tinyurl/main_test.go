@@ -6,8 +6,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"image/png"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,8 +23,8 @@ func TestNewTinyURLService(t *testing.T) {
 	if service.baseURL != "http://test.com" {
 		t.Errorf("Expected baseURL to be http://test.com, got %s", service.baseURL)
 	}
-	if len(service.mappings) != 0 {
-		t.Errorf("Expected empty mappings, got %d", len(service.mappings))
+	if len(service.ListAllMappings()) != 0 {
+		t.Errorf("Expected empty mappings, got %d", len(service.ListAllMappings()))
 	}
 }
 
@@ -466,3 +469,448 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 }
 
+func TestGetServiceStats_TopURLs(t *testing.T) {
+	service := NewTinyURLService("http://test.com")
+
+	// Create more mappings than topURLCount with distinct access counts
+	total := topURLCount + 5
+	for i := 0; i < total; i++ {
+		alias := fmt.Sprintf("url%d", i)
+		service.CreateShortURL(fmt.Sprintf("https://example.com/%d", i), alias, 0)
+		for j := 0; j < i; j++ {
+			service.GetLongURL(alias)
+		}
+	}
+
+	stats := service.GetServiceStats()
+
+	if stats.TotalMappings != total {
+		t.Errorf("Expected %d mappings, got %d", total, stats.TotalMappings)
+	}
+
+	expectedRedirects := int64(total * (total - 1) / 2)
+	if stats.TotalRedirects != expectedRedirects {
+		t.Errorf("Expected %d redirects, got %d", expectedRedirects, stats.TotalRedirects)
+	}
+
+	if len(stats.TopURLs) != topURLCount {
+		t.Fatalf("Expected %d top URLs, got %d", topURLCount, len(stats.TopURLs))
+	}
+
+	for i, mapping := range stats.TopURLs {
+		expected := fmt.Sprintf("url%d", total-1-i)
+		if mapping.ShortURL != expected {
+			t.Errorf("Position %d: expected %s, got %s", i, expected, mapping.ShortURL)
+		}
+	}
+}
+
+func TestGetServiceStats_ExpiredPending(t *testing.T) {
+	service := NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com/1", "live", 0)
+	service.CreateShortURL("https://example.com/2", "expiring", 10*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+
+	stats := service.GetServiceStats()
+	if stats.ExpiredPending != 1 {
+		t.Errorf("Expected 1 expired entry, got %d", stats.ExpiredPending)
+	}
+	if stats.TotalMappings != 2 {
+		t.Errorf("Expected 2 mappings, got %d", stats.TotalMappings)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com", "test123", 0)
+	service.GetLongURL("test123")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	metricsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats ServiceStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.TotalRedirects != 1 {
+		t.Errorf("Expected 1 redirect, got %d", stats.TotalRedirects)
+	}
+	if len(stats.TopURLs) != 1 || stats.TopURLs[0].ShortURL != "test123" {
+		t.Errorf("Expected test123 as top URL, got %+v", stats.TopURLs)
+	}
+}
+
+func TestQRHandler_Success(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com", "test123", 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/qr?short_url=test123&size=128", nil)
+	w := httptest.NewRecorder()
+
+	qrHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Expected Content-Type image/png, got %s", ct)
+	}
+	if w.Body.Len() == 0 {
+		t.Fatal("Expected non-empty body")
+	}
+
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("Expected decodable PNG, got %v", err)
+	}
+	if img.Bounds().Dx() != 128 {
+		t.Errorf("Expected image width 128, got %d", img.Bounds().Dx())
+	}
+}
+
+func TestQRHandler_NotFound(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+
+	req := httptest.NewRequest(http.MethodGet, "/qr?short_url=missing", nil)
+	w := httptest.NewRecorder()
+
+	qrHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestQRHandler_BadSize(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com", "test123", 0)
+
+	for _, size := range []string{"abc", "10", "5000"} {
+		req := httptest.NewRequest(http.MethodGet, "/qr?short_url=test123&size="+size, nil)
+		w := httptest.NewRecorder()
+
+		qrHandler(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Size %s: expected status 400, got %d", size, w.Code)
+		}
+	}
+}
+
+func TestCreateShortURLWithOptions_InvalidRedirectType(t *testing.T) {
+	service := NewTinyURLService("http://test.com")
+
+	_, err := service.CreateShortURLWithOptions("https://example.com", CreateOptions{RedirectType: 307})
+	if err == nil {
+		t.Error("Expected error for unsupported redirect type")
+	}
+}
+
+func TestRedirectHandler_TemporaryRedirect(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	longURL := "https://example.com/rotating"
+
+	created, err := service.CreateShortURLWithOptions(longURL, CreateOptions{
+		CustomAlias:  "temp",
+		RedirectType: http.StatusFound,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+created.ShortURL, nil)
+	w := httptest.NewRecorder()
+
+	redirectHandler(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Errorf("Expected status 302, got %d", w.Code)
+	}
+	if location := w.Header().Get("Location"); location != longURL {
+		t.Errorf("Expected location %s, got %s", longURL, location)
+	}
+}
+
+func TestPreviewHandler_DoesNotCountAccess(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com", "test123", 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/preview?short_url=test123", nil)
+	w := httptest.NewRecorder()
+
+	previewHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["long_url"] != "https://example.com" {
+		t.Errorf("Expected long_url https://example.com, got %v", resp["long_url"])
+	}
+	if w.Header().Get("Location") != "" {
+		t.Error("Expected preview not to redirect")
+	}
+
+	stats, _ := service.GetStats("test123")
+	if stats.AccessCount != 0 {
+		t.Errorf("Expected access count 0 after preview, got %d", stats.AccessCount)
+	}
+}
+
+func TestPreviewHandler_NotFound(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+
+	req := httptest.NewRequest(http.MethodGet, "/preview?short_url=missing", nil)
+	w := httptest.NewRecorder()
+
+	previewHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestRedirectHandler_PasswordProtected(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	longURL := "https://example.com/secret"
+
+	_, err := service.CreateShortURLWithOptions(longURL, CreateOptions{
+		CustomAlias: "secret",
+		Password:    "hunter2",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"correct password", "?pw=hunter2", http.StatusMovedPermanently},
+		{"wrong password", "?pw=wrong", http.StatusUnauthorized},
+		{"missing password", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/secret"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			redirectHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusMovedPermanently && w.Header().Get("Location") != longURL {
+				t.Errorf("Expected location %s, got %s", longURL, w.Header().Get("Location"))
+			}
+		})
+	}
+
+	stats, _ := service.GetStats("secret")
+	if stats.AccessCount != 1 {
+		t.Errorf("Expected only the authorized access to be counted, got %d", stats.AccessCount)
+	}
+}
+
+func TestCreateShortURL_PasswordStoredHashed(t *testing.T) {
+	service := NewTinyURLService("http://test.com")
+	mapping, _ := service.CreateShortURLWithOptions("https://example.com", CreateOptions{Password: "hunter2"})
+
+	if string(mapping.passwordHash) == "hunter2" || len(mapping.passwordHash) != 32 {
+		t.Error("Expected password to be stored as a SHA-256 hash")
+	}
+
+	// A protected link must not be handed out to unprotected creators
+	other, _ := service.CreateShortURL("https://example.com", "", 0)
+	if other.ShortURL == mapping.ShortURL {
+		t.Error("Expected protected link to be excluded from deduplication")
+	}
+}
+
+func TestListHandler_OmitsPasswordHash(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	service.CreateShortURLWithOptions("https://example.com", CreateOptions{
+		CustomAlias: "secret",
+		Password:    "hunter2",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	w := httptest.NewRecorder()
+
+	listHandler(w, req)
+
+	body := w.Body.String()
+	mapping, _ := service.GetStats("secret")
+	for _, leaked := range []string{"hunter2", "password", fmt.Sprintf("%x", mapping.passwordHash)} {
+		if strings.Contains(body, leaked) {
+			t.Errorf("Expected list output not to contain %q: %s", leaked, body)
+		}
+	}
+	if !strings.Contains(body, `"protected":true`) {
+		t.Errorf("Expected list output to flag the link as protected: %s", body)
+	}
+}
+
+func TestHandlers_RedactProtectedLongURL(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+	longURL := "https://example.com/private-target"
+	service.CreateShortURLWithOptions(longURL, CreateOptions{
+		CustomAlias: "secret",
+		Password:    "hunter2",
+	})
+	service.CreateShortURL("https://example.com/public-target", "open", 0)
+
+	handlers := []struct {
+		target  string
+		handler http.HandlerFunc
+	}{
+		{"/stats?short_url=secret", statsHandler},
+		{"/list", listHandler},
+		{"/metrics", metricsHandler},
+	}
+	for _, h := range handlers {
+		w := httptest.NewRecorder()
+		h.handler(w, httptest.NewRequest(http.MethodGet, h.target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", h.target, w.Code)
+		}
+		if body := w.Body.String(); strings.Contains(body, "private-target") {
+			t.Errorf("%s: expected the protected target to be withheld: %s", h.target, body)
+		}
+	}
+
+	// Unprotected targets are still listed, and the service keeps the real URL
+	w := httptest.NewRecorder()
+	listHandler(w, httptest.NewRequest(http.MethodGet, "/list", nil))
+	if !strings.Contains(w.Body.String(), "public-target") {
+		t.Errorf("Expected the unprotected target to be listed: %s", w.Body)
+	}
+	if mapping, _ := service.GetStats("secret"); mapping.LongURL != longURL {
+		t.Errorf("Expected the stored long URL to be kept, got %q", mapping.LongURL)
+	}
+}
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewTinyURLService("http://localhost:8080")
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
+
+func TestRegisterRoutes_DeleteDocumentsNoContent(t *testing.T) {
+	service = NewTinyURLService("http://localhost:8080")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]interface{} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+
+	responses := doc.Paths["/delete"]["delete"].Responses
+	if _, ok := responses["204"]; !ok {
+		t.Errorf("Expected /delete to document 204, got %v", responses)
+	}
+	if _, ok := responses["200"]; ok {
+		t.Error("Expected /delete not to document 200, the handler writes 204")
+	}
+}
+
+func TestSnapshotRestore_KeepsPasswordsAndDedup(t *testing.T) {
+	original := NewTinyURLService("http://test.com")
+	public, _ := original.CreateShortURL("https://example.com/public", "", 0)
+	original.CreateShortURLWithOptions("https://example.com/secret", CreateOptions{
+		CustomAlias: "secret",
+		Password:    "hunter2",
+	})
+	original.GetLongURL(public.ShortURL)
+
+	data, err := original.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restored := NewTinyURLService("http://test.com")
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := restored.GetLongURLWithPassword("secret", "wrong"); err != ErrPasswordRequired {
+		t.Errorf("Expected ErrPasswordRequired, got %v", err)
+	}
+	if _, err := restored.GetLongURLWithPassword("secret", "hunter2"); err != nil {
+		t.Errorf("Expected password to still work after restore, got %v", err)
+	}
+
+	again, _ := restored.CreateShortURL("https://example.com/public", "", 0)
+	if again.ShortURL != public.ShortURL {
+		t.Errorf("Expected restored reverse index to dedupe to %s, got %s", public.ShortURL, again.ShortURL)
+	}
+	if got := restored.GetServiceStats().TotalRedirects; got != 2 {
+		t.Errorf("Expected 2 redirects after restore, got %d", got)
+	}
+}
+
+func TestRestore_RejectsProtectedWithoutHash(t *testing.T) {
+	service := NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com", "keep", 0)
+
+	bad := `{"mappings":[{"short_url":"x","long_url":"https://example.com","protected":true}]}`
+	if err := service.Restore([]byte(bad)); err == nil {
+		t.Error("Expected error for protected mapping without a hash")
+	}
+	if _, err := service.GetLongURL("keep"); err != nil {
+		t.Errorf("Expected failed restore to leave state untouched, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeleteByPrefix(t *testing.T) {
+	service := NewTinyURLService("http://short.ly")
+	a1, _ := service.CreateShortURL("https://example.com/a", "", 0)
+	service.CreateShortURL("https://example.com/b", "", 0)
+	service.CreateShortURL("https://example.com/c/d", "", 0)
+	other, _ := service.CreateShortURL("https://example.org/a", "", 0)
+	near, _ := service.CreateShortURL("https://other.com/https://example.com/", "", 0)
+
+	if removed := service.DeleteByPrefix("https://example.com/"); removed != 3 {
+		t.Errorf("Expected 3 mappings removed, got %d", removed)
+	}
+
+	if _, err := service.GetStats(a1.ShortURL); err == nil {
+		t.Error("Expected example.com mappings to be gone")
+	}
+	for _, kept := range []*URLMapping{other, near} {
+		if _, err := service.GetStats(kept.ShortURL); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", kept.LongURL, err)
+		}
+	}
+	if n := len(service.ListAllMappings()); n != 2 {
+		t.Errorf("Expected 2 mappings left, got %d", n)
+	}
+
+	// The reverse index is cleared too, so the long URL gets a fresh code
+	if n := len(service.store.reverse); n != 2 {
+		t.Errorf("Expected 2 reverse entries left, got %d", n)
+	}
+	again, _ := service.CreateShortURL("https://example.com/a", "", 0)
+	if _, err := service.GetStats(again.ShortURL); err != nil {
+		t.Errorf("Expected the recreated mapping to resolve, got %v", err)
+	}
+
+	if removed := service.DeleteByPrefix("https://nothing.example/"); removed != 0 {
+		t.Errorf("Expected nothing removed, got %d", removed)
+	}
+}
+
+func TestDeleteByPrefixHandler(t *testing.T) {
+	service = NewTinyURLService("http://short.ly")
+	service.CreateShortURL("https://example.com/a", "", 0)
+	service.CreateShortURL("https://example.org/a", "", 0)
+
+	w := httptest.NewRecorder()
+	deleteByPrefixHandler(w, httptest.NewRequest(http.MethodDelete, "/delete-by-prefix?prefix=https://example.com/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp deleteByPrefixResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Deleted != 1 {
+		t.Errorf("Expected 1 deleted, got %d", resp.Deleted)
+	}
+
+	w = httptest.NewRecorder()
+	deleteByPrefixHandler(w, httptest.NewRequest(http.MethodDelete, "/delete-by-prefix", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a prefix, got %d", w.Code)
+	}
+}
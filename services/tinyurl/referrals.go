@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"common/apierror"
+)
+
+// referralTopN is how many distinct values each referral counter keeps
+// per short URL. Past that the least counted value is replaced, so memory
+// stays fixed however many referrers a link sees.
+const referralTopN = 10
+
+// GeoCountryHeader carries the visitor's two-letter country code, set by
+// the ingress from the client address. The service never sees or stores
+// the address itself.
+const GeoCountryHeader = "X-Geo-Country"
+
+// Buckets for visits that carry no usable value
+const (
+	directReferrer = "direct"  // no Referer header
+	unknownBucket  = "unknown" // a header that couldn't be bucketed
+)
+
+// Visit is the coarse, PII-free description of one redirect: the referring
+// host, browser family, device type and country
+type Visit struct {
+	Referrer string
+	Browser  string
+	Device   string
+	Country  string
+}
+
+// visitFromRequest buckets a redirect request. Only the Referer's host is
+// kept, never its path or query, and the User-Agent is reduced to a family.
+func visitFromRequest(r *http.Request) Visit {
+	browser, device := classifyUserAgent(r.UserAgent())
+	return Visit{
+		Referrer: referrerHost(r.Referer()),
+		Browser:  browser,
+		Device:   device,
+		Country:  countryBucket(r.Header.Get(GeoCountryHeader)),
+	}
+}
+
+// referrerHost returns the host of a Referer header, "direct" when there
+// is none
+func referrerHost(referer string) string {
+	if referer == "" {
+		return directReferrer
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return unknownBucket
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// countryBucket accepts only two-letter country codes
+func countryBucket(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return unknownBucket
+	}
+	return code
+}
+
+// classifyUserAgent reduces a User-Agent to a browser family and a device
+// type. Order matters: Edge and Opera also claim Chrome, and Chrome also
+// claims Safari.
+func classifyUserAgent(ua string) (browser, device string) {
+	if ua == "" {
+		return unknownBucket, unknownBucket
+	}
+	lower := strings.ToLower(ua)
+	if strings.Contains(lower, "bot") || strings.Contains(lower, "crawler") || strings.Contains(lower, "spider") {
+		return "bot", "bot"
+	}
+
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "edge"
+	case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+		browser = "opera"
+	case strings.Contains(ua, "Firefox/"), strings.Contains(ua, "FxiOS/"):
+		browser = "firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+		browser = "chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "safari"
+	default:
+		browser = "other"
+	}
+
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"),
+		strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile"):
+		device = "tablet"
+	case strings.Contains(ua, "Mobile"), strings.Contains(ua, "iPhone"):
+		device = "mobile"
+	default:
+		device = "desktop"
+	}
+	return browser, device
+}
+
+// topCounter counts occurrences of at most capacity distinct values using
+// the Space-Saving algorithm: a new value arriving when it is full replaces
+// the least counted one and inherits its count. The counts of values that
+// stayed in from the start are exact; newcomers may be overcounted by the
+// count they inherited.
+type topCounter struct {
+	capacity int
+	counts   map[string]int64
+}
+
+func newTopCounter(capacity int) *topCounter {
+	return &topCounter{capacity: capacity, counts: make(map[string]int64, capacity)}
+}
+
+// add counts one occurrence of value
+func (c *topCounter) add(value string) {
+	if _, exists := c.counts[value]; exists || len(c.counts) < c.capacity {
+		c.counts[value]++
+		return
+	}
+
+	minValue, minCount := "", int64(-1)
+	for v, count := range c.counts {
+		if minCount < 0 || count < minCount || (count == minCount && v < minValue) {
+			minValue, minCount = v, count
+		}
+	}
+	delete(c.counts, minValue)
+	c.counts[value] = minCount + 1
+}
+
+// top returns the counted values, most counted first and by name on ties
+func (c *topCounter) top() []ReferralCount {
+	counts := make([]ReferralCount, 0, len(c.counts))
+	for value, count := range c.counts {
+		counts = append(counts, ReferralCount{Name: value, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// referralStats holds a short URL's bounded referral counters
+type referralStats struct {
+	mu        sync.Mutex
+	referrers *topCounter
+	browsers  *topCounter
+	devices   *topCounter
+	countries *topCounter
+}
+
+func newReferralStats() *referralStats {
+	return &referralStats{
+		referrers: newTopCounter(referralTopN),
+		browsers:  newTopCounter(referralTopN),
+		devices:   newTopCounter(referralTopN),
+		countries: newTopCounter(referralTopN),
+	}
+}
+
+// ReferralCount is how many visits fell in one bucket
+type ReferralCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// ReferralReport breaks down a short URL's redirects by where they came
+// from, most common first. Each list holds at most referralTopN buckets.
+type ReferralReport struct {
+	ShortURL  string          `json:"short_url"`
+	Referrers []ReferralCount `json:"referrers"` // referring hosts; "direct" without a Referer
+	Browsers  []ReferralCount `json:"browsers"`
+	Devices   []ReferralCount `json:"devices"` // desktop, mobile, tablet or bot
+	Countries []ReferralCount `json:"countries"`
+}
+
+// RecordReferral counts a redirect of shortURL from visit. It does nothing
+// unless the service was created with ServiceOptions.TrackReferrals.
+func (s *TinyURLService) RecordReferral(shortURL string, visit Visit) error {
+	if !s.trackReferrals {
+		return nil
+	}
+	stats, err := s.referralStats(shortURL)
+	if err != nil {
+		return err
+	}
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.referrers.add(visit.Referrer)
+	stats.browsers.add(visit.Browser)
+	stats.devices.add(visit.Device)
+	stats.countries.add(visit.Country)
+	return nil
+}
+
+// GetReferrals returns the referral breakdown of shortURL. The lists are
+// empty when tracking is off or the link hasn't been followed.
+func (s *TinyURLService) GetReferrals(shortURL string) (*ReferralReport, error) {
+	stats, err := s.referralStats(shortURL)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return &ReferralReport{
+		ShortURL:  shortURL,
+		Referrers: stats.referrers.top(),
+		Browsers:  stats.browsers.top(),
+		Devices:   stats.devices.top(),
+		Countries: stats.countries.top(),
+	}, nil
+}
+
+// referralStats returns a mapping's counters, creating them on first use.
+// They live on the mapping so they go away when it is deleted.
+func (s *TinyURLService) referralStats(shortURL string) (*referralStats, error) {
+	sh := s.store.shardFor(shortURL)
+	mapping, exists := sh.get(shortURL)
+	if !exists {
+		return nil, fmt.Errorf("short URL not found")
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if mapping.referrals == nil {
+		mapping.referrals = newReferralStats()
+	}
+	return mapping.referrals, nil
+}
+
+func referralsHandler(w http.ResponseWriter, r *http.Request) {
+	shortURL := r.URL.Query().Get("short_url")
+	if shortURL == "" {
+		apierror.Error(w, "short_url parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	report, err := service.GetReferrals(shortURL)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const (
+	chromeDesktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	safariIPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	firefoxLinux  = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	edgeDesktop   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0"
+	googlebot     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestRedirect_AggregatesReferrals(t *testing.T) {
+	service = NewTinyURLServiceWithOptions("http://test.com", ServiceOptions{TrackReferrals: true})
+	mapping, _ := service.CreateShortURL("https://example.com/target", "promo", 0)
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	visits := []struct {
+		referer, userAgent, country string
+	}{
+		{"https://news.example.org/story?id=42&user=alice", chromeDesktop, "us"},
+		{"https://news.example.org/other", safariIPhone, "US"},
+		{"https://news.example.org/", firefoxLinux, "DE"},
+		{"https://social.example.net/feed", chromeDesktop, "GB"},
+		{"", edgeDesktop, ""},
+		{"", googlebot, "not-a-country"},
+	}
+	for _, visit := range visits {
+		req := httptest.NewRequest(http.MethodGet, "/"+mapping.ShortURL, nil)
+		if visit.referer != "" {
+			req.Header.Set("Referer", visit.referer)
+		}
+		req.Header.Set("User-Agent", visit.userAgent)
+		req.Header.Set(GeoCountryHeader, visit.country)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusMovedPermanently {
+			t.Fatalf("Expected status 301, got %d", w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/referrals?short_url=promo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var report ReferralReport
+	json.NewDecoder(w.Body).Decode(&report)
+
+	want := ReferralReport{
+		ShortURL:  "promo",
+		Referrers: []ReferralCount{{"news.example.org", 3}, {"direct", 2}, {"social.example.net", 1}},
+		Browsers:  []ReferralCount{{"chrome", 2}, {"bot", 1}, {"edge", 1}, {"firefox", 1}, {"safari", 1}},
+		Devices:   []ReferralCount{{"desktop", 4}, {"bot", 1}, {"mobile", 1}},
+		Countries: []ReferralCount{{"US", 2}, {"unknown", 2}, {"DE", 1}, {"GB", 1}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Expected %+v, got %+v", want, report)
+	}
+}
+
+func TestReferrals_OffByDefault(t *testing.T) {
+	service := NewTinyURLService("http://test.com")
+	service.CreateShortURL("https://example.com", "quiet", 0)
+	service.RecordReferral("quiet", Visit{Referrer: "direct", Browser: "chrome", Device: "desktop", Country: "US"})
+
+	report, err := service.GetReferrals("quiet")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Referrers)+len(report.Browsers)+len(report.Devices)+len(report.Countries) != 0 {
+		t.Errorf("Expected nothing recorded, got %+v", report)
+	}
+
+	if _, err := service.GetReferrals("missing"); err == nil {
+		t.Error("Expected an error for an unknown short URL")
+	}
+}
+
+func TestTopCounter_StaysBounded(t *testing.T) {
+	counter := newTopCounter(3)
+	// Space-Saving keeps any value seen more than total/capacity times
+	for i := 0; i < 60; i++ {
+		counter.add("popular")
+	}
+	counter.add("steady")
+	counter.add("steady")
+	for i := 0; i < 100; i++ {
+		counter.add(fmt.Sprintf("one-off-%d", i))
+		if len(counter.counts) > 3 {
+			t.Fatalf("Expected at most 3 values, got %d", len(counter.counts))
+		}
+	}
+
+	top := counter.top()
+	if top[0] != (ReferralCount{"popular", 60}) {
+		t.Errorf("Expected the popular value kept with its exact count, got %v", top)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultShardCount is the number of shards NewTinyURLService uses
+const DefaultShardCount = 16
+
+// virtualNodesPerShard smooths the key distribution across the ring
+const virtualNodesPerShard = 64
+
+// shard holds the mappings whose short codes hash to it
+type shard struct {
+	mu       sync.RWMutex
+	mappings map[string]*URLMapping
+}
+
+// ringPoint is one virtual node on the hash ring
+type ringPoint struct {
+	hash  uint32
+	shard int
+}
+
+// shardedStore partitions mappings across shards placed on a consistent
+// hash ring, so lookups for different codes take different locks. The
+// long URL -> short code index used for deduplication has its own lock,
+// taken only by writes and always before any shard lock.
+type shardedStore struct {
+	shards []*shard
+	ring   []ringPoint // sorted by hash
+
+	reverseMu sync.Mutex
+	reverse   map[string]string // longURL -> shortURL
+}
+
+// newShardedStore creates a store with count shards (at least one)
+func newShardedStore(count int) *shardedStore {
+	if count < 1 {
+		count = 1
+	}
+
+	st := &shardedStore{
+		shards:  make([]*shard, count),
+		ring:    make([]ringPoint, 0, count*virtualNodesPerShard),
+		reverse: make(map[string]string),
+	}
+	for i := range st.shards {
+		st.shards[i] = &shard{mappings: make(map[string]*URLMapping)}
+		for v := 0; v < virtualNodesPerShard; v++ {
+			st.ring = append(st.ring, ringPoint{
+				hash:  hashKey("shard-" + strconv.Itoa(i) + "-" + strconv.Itoa(v)),
+				shard: i,
+			})
+		}
+	}
+	sort.Slice(st.ring, func(i, j int) bool { return st.ring[i].hash < st.ring[j].hash })
+
+	return st
+}
+
+// hashKey is 32-bit FNV-1a, inlined to keep lookups allocation free
+func hashKey(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	hash := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+// shardIndex returns the shard owning the first ring point at or after the
+// code's hash
+func (st *shardedStore) shardIndex(shortURL string) int {
+	hash := hashKey(shortURL)
+	i := sort.Search(len(st.ring), func(i int) bool { return st.ring[i].hash >= hash })
+	if i == len(st.ring) {
+		i = 0
+	}
+	return st.ring[i].shard
+}
+
+func (st *shardedStore) shardFor(shortURL string) *shard {
+	return st.shards[st.shardIndex(shortURL)]
+}
+
+// get looks up a mapping under its shard's read lock
+func (st *shardedStore) get(shortURL string) (*URLMapping, bool) {
+	return st.shardFor(shortURL).get(shortURL)
+}
+
+func (sh *shard) get(shortURL string) (*URLMapping, bool) {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	mapping, exists := sh.mappings[shortURL]
+	return mapping, exists
+}
+
+// put stores a mapping. Must be called with st.reverseMu held.
+func (st *shardedStore) put(mapping *URLMapping) {
+	sh := st.shardFor(mapping.ShortURL)
+	sh.mu.Lock()
+	sh.mappings[mapping.ShortURL] = mapping
+	sh.mu.Unlock()
+
+	if !mapping.Protected {
+		st.reverse[mapping.LongURL] = mapping.ShortURL
+	}
+}
+
+// remove deletes a mapping and its reverse entry if they are still the
+// current ones for its code and long URL
+func (st *shardedStore) remove(mapping *URLMapping) {
+	st.reverseMu.Lock()
+	defer st.reverseMu.Unlock()
+	st.removeLocked(mapping)
+}
+
+// removeLocked is remove for callers holding st.reverseMu
+func (st *shardedStore) removeLocked(mapping *URLMapping) {
+	sh := st.shardFor(mapping.ShortURL)
+	sh.mu.Lock()
+	if sh.mappings[mapping.ShortURL] == mapping {
+		delete(sh.mappings, mapping.ShortURL)
+	}
+	sh.mu.Unlock()
+
+	if st.reverse[mapping.LongURL] == mapping.ShortURL {
+		delete(st.reverse, mapping.LongURL)
+	}
+}
+
+// removeWhere deletes every mapping match returns true for, along with
+// its reverse entry, and returns how many were deleted
+func (st *shardedStore) removeWhere(match func(*URLMapping) bool) int {
+	st.reverseMu.Lock()
+	defer st.reverseMu.Unlock()
+
+	removed := 0
+	for _, sh := range st.shards {
+		sh.mu.Lock()
+		for shortURL, mapping := range sh.mappings {
+			if !match(mapping) {
+				continue
+			}
+			delete(sh.mappings, shortURL)
+			if st.reverse[mapping.LongURL] == shortURL {
+				delete(st.reverse, mapping.LongURL)
+			}
+			removed++
+		}
+		sh.mu.Unlock()
+	}
+	return removed
+}
+
+// each calls fn for every mapping, one shard at a time under its read lock
+func (st *shardedStore) each(fn func(*URLMapping)) {
+	for _, sh := range st.shards {
+		sh.mu.RLock()
+		for _, mapping := range sh.mappings {
+			fn(mapping)
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+// len returns the number of stored mappings
+func (st *shardedStore) len() int {
+	total := 0
+	for _, sh := range st.shards {
+		sh.mu.RLock()
+		total += len(sh.mappings)
+		sh.mu.RUnlock()
+	}
+	return total
+}
+
+// replace swaps the store's contents for mappings, holding every lock so
+// readers see either the old or the new set
+func (st *shardedStore) replace(mappings []*URLMapping) {
+	st.reverseMu.Lock()
+	defer st.reverseMu.Unlock()
+
+	for _, sh := range st.shards {
+		sh.mu.Lock()
+		sh.mappings = make(map[string]*URLMapping)
+	}
+	st.reverse = make(map[string]string)
+
+	for _, mapping := range mappings {
+		st.shardFor(mapping.ShortURL).mappings[mapping.ShortURL] = mapping
+		if !mapping.Protected {
+			st.reverse[mapping.LongURL] = mapping.ShortURL
+		}
+	}
+
+	for i := len(st.shards) - 1; i >= 0; i-- {
+		st.shards[i].mu.Unlock()
+	}
+}
+
+// RLock read-locks every shard in order, so the store can be probed by a
+// health check like a single mutex
+func (st *shardedStore) RLock() {
+	for _, sh := range st.shards {
+		sh.mu.RLock()
+	}
+}
+
+// RUnlock releases the locks taken by RLock
+func (st *shardedStore) RUnlock() {
+	for i := len(st.shards) - 1; i >= 0; i-- {
+		st.shards[i].mu.RUnlock()
+	}
+}
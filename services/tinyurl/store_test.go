@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestShardedStore_CodesLandInTheirShard(t *testing.T) {
+	service := NewShardedTinyURLService("http://localhost:8080", 8)
+
+	codes := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		mapping, err := service.CreateShortURL(fmt.Sprintf("https://example.com/%d", i), "", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		codes = append(codes, mapping.ShortURL)
+	}
+
+	used := make(map[int]bool)
+	for _, code := range codes {
+		owner := service.store.shardIndex(code)
+		used[owner] = true
+
+		for i, sh := range service.store.shards {
+			_, stored := sh.mappings[code]
+			if stored != (i == owner) {
+				t.Fatalf("Code %s: stored in shard %d = %v, owner is %d", code, i, stored, owner)
+			}
+		}
+
+		if _, err := service.GetLongURL(code); err != nil {
+			t.Errorf("Expected %s to be retrievable, got %v", code, err)
+		}
+	}
+
+	if len(used) != len(service.store.shards) {
+		t.Errorf("Expected every shard to be used, got %d of %d", len(used), len(service.store.shards))
+	}
+	if got := len(service.ListAllMappings()); got != len(codes) {
+		t.Errorf("Expected %d mappings across shards, got %d", len(codes), got)
+	}
+}
+
+func TestShardedStore_DeleteAndDedupe(t *testing.T) {
+	service := NewShardedTinyURLService("http://localhost:8080", 4)
+
+	first, _ := service.CreateShortURL("https://example.com", "", 0)
+	again, _ := service.CreateShortURL("https://example.com", "", 0)
+	if first != again {
+		t.Error("Expected the same long URL to reuse its mapping")
+	}
+
+	if err := service.DeleteShortURL(first.ShortURL); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, exists := service.store.get(first.ShortURL); exists {
+		t.Error("Expected the mapping to be removed from its shard")
+	}
+	if _, exists := service.store.reverse["https://example.com"]; exists {
+		t.Error("Expected the reverse entry to be removed")
+	}
+}
+
+func TestShardedStore_ConcurrentCreates(t *testing.T) {
+	service := NewShardedTinyURLService("http://localhost:8080", 8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				mapping, err := service.CreateShortURL(fmt.Sprintf("https://example.com/%d/%d", i, j), "", 0)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				service.GetLongURL(mapping.ShortURL)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(service.ListAllMappings()); got != 1000 {
+		t.Errorf("Expected 1000 mappings, got %d", got)
+	}
+}
+
+func benchmarkLookups(b *testing.B, shards int) {
+	service := NewShardedTinyURLService("http://localhost:8080", shards)
+
+	codes := make([]string, 1024)
+	for i := range codes {
+		mapping, _ := service.CreateShortURL(fmt.Sprintf("https://example.com/%d", i), "", 0)
+		codes[i] = mapping.ShortURL
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			service.GetLongURL(codes[i%len(codes)])
+			i++
+		}
+	})
+}
+
+// BenchmarkGetLongURL_SingleMutex puts every mapping behind one lock, like
+// the store before sharding
+func BenchmarkGetLongURL_SingleMutex(b *testing.B) {
+	benchmarkLookups(b, 1)
+}
+
+func BenchmarkGetLongURL_Sharded(b *testing.B) {
+	benchmarkLookups(b, DefaultShardCount)
+}
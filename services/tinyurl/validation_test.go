@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"common/validate"
+)
+
+func TestCreateHandler_ReportsEveryFieldError(t *testing.T) {
+	service = NewTinyURLService("http://test.com")
+
+	body := `{"long_url":"not a url","custom_alias":"` + strings.Repeat("a", maxAliasLength+1) + `","ttl_seconds":-5,"redirect_type":307}`
+	w := httptest.NewRecorder()
+	createHandler(w, httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(body)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+			Details struct {
+				Fields validate.Errors `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	want := validate.Errors{
+		{Field: "long_url", Message: "must be an http or https URL"},
+		{Field: "custom_alias", Message: "must be at most 64 characters"},
+		{Field: "ttl_seconds", Message: "must be >= 0"},
+		{Field: "redirect_type", Message: "must be one of 301, 302"},
+	}
+	if !reflect.DeepEqual(resp.Error.Details.Fields, want) {
+		t.Errorf("Expected %v, got %v", want, resp.Error.Details.Fields)
+	}
+	if !strings.Contains(resp.Error.Message, "ttl_seconds: must be >= 0") {
+		t.Errorf("Expected the message to list the field errors, got %q", resp.Error.Message)
+	}
+
+	// A missing long_url is reported as required
+	w = httptest.NewRecorder()
+	createHandler(w, httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "long_url: required") {
+		t.Errorf("Expected long_url: required, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,12 +1,34 @@
 package main
 
 import (
+	"container/heap"
 	"encoding/json"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/health"
+	"common/middleware"
+	"common/openapi"
+	"common/validate"
+)
+
+// maxWordLength caps words added to the trie
+const maxWordLength = 100
+
+// Page sizes for suggestions
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 100
 )
 
 // TrieNode represents a node in the trie
@@ -91,6 +113,113 @@ func (t *Trie) Search(prefix string, limit int) []string {
 	return words
 }
 
+// SearchPage returns limit words with the given prefix after skipping the
+// offset highest scoring ones, and whether more words follow. Only the top
+// offset+limit+1 words are kept while walking the trie, and ties are broken
+// alphabetically so pages don't overlap.
+func (t *Trie) SearchPage(prefix string, offset, limit int) ([]string, bool) {
+	// One extra word tells us whether there's another page
+	top := &scoredWords{}
+	t.collectTop(prefix, top, pageWindow(offset, limit), nil)
+	return pageOf(top.ranked(), offset, limit)
+}
+
+// pageWindow returns how many top words a page needs: offset+limit plus one
+// to tell whether another page follows, capped rather than overflowing
+func pageWindow(offset, limit int) int {
+	if limit >= math.MaxInt-1-offset {
+		return math.MaxInt
+	}
+	return offset + limit + 1
+}
+
+// pageOf returns limit words from ranked after skipping offset, and whether
+// ranked has more words after them. Counts are compared against what's left
+// after offset so a huge offset or limit can't overflow.
+func pageOf(ranked []scoredWord, offset, limit int) ([]string, bool) {
+	words := []string{}
+	for i := offset; i < len(ranked) && i-offset < limit; i++ {
+		words = append(words, ranked[i].word)
+	}
+	return words, offset < len(ranked) && len(ranked)-offset > limit
+}
+
+// collectTop adds the words under prefix to top, keeping at most k, and
+// leaves out the words in skip, which holds them lowercased
+func (t *Trie) collectTop(prefix string, top *scoredWords, k int, skip map[string]bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	node := t.root
+	for _, ch := range strings.ToLower(prefix) {
+		if node.children[ch] == nil {
+			return
+		}
+		node = node.children[ch]
+	}
+	collectTop(node, top, k, skip)
+}
+
+// scoredWord is a word and its score
+type scoredWord struct {
+	word  string
+	score int
+}
+
+// ranksBelow reports whether a sorts after b: lower score, then later word
+func (a scoredWord) ranksBelow(b scoredWord) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	return a.word > b.word
+}
+
+// scoredWords is a min-heap with the lowest ranked word on top
+type scoredWords []scoredWord
+
+func (h scoredWords) Len() int            { return len(h) }
+func (h scoredWords) Less(i, j int) bool  { return h[i].ranksBelow(h[j]) }
+func (h scoredWords) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoredWords) Push(x interface{}) { *h = append(*h, x.(scoredWord)) }
+func (h *scoredWords) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// offer adds word to a heap holding at most k words, replacing the lowest
+// ranked word if word ranks above it
+func (h *scoredWords) offer(word scoredWord, k int) {
+	if h.Len() < k {
+		heap.Push(h, word)
+	} else if k > 0 && (*h)[0].ranksBelow(word) {
+		(*h)[0] = word
+		heap.Fix(h, 0)
+	}
+}
+
+// ranked empties the heap and returns its words, highest ranked first
+func (h *scoredWords) ranked() []scoredWord {
+	ranked := make([]scoredWord, h.Len())
+	for i := len(ranked) - 1; i >= 0; i-- {
+		ranked[i] = heap.Pop(h).(scoredWord)
+	}
+	return ranked
+}
+
+// collectTop keeps the k highest ranked words under node in top, leaving out
+// the words in skip, which holds them lowercased
+func collectTop(node *TrieNode, top *scoredWords, k int, skip map[string]bool) {
+	if node.isEnd && (len(skip) == 0 || !skip[strings.ToLower(node.word)]) {
+		top.offer(scoredWord{word: node.word, score: node.score}, k)
+	}
+
+	for _, child := range node.children {
+		collectTop(child, top, k, skip)
+	}
+}
+
 // collectWords collects all words from a node
 func (t *Trie) collectWords(node *TrieNode, results *[]struct {
 	word  string
@@ -143,14 +272,75 @@ func (t *Trie) deleteHelper(node *TrieNode, word string, index int) bool {
 
 // TypeaheadService manages the typeahead functionality
 type TypeaheadService struct {
-	trie *Trie
+	trie    *Trie
+	phrases *phraseIndex // finds phrases by any of their words
+
+	limitsMu     sync.RWMutex
+	defaultLimit int // page size when none is asked for
+	maxLimit     int // largest page size served
 }
 
 // NewTypeaheadService creates a new typeahead service
 func NewTypeaheadService() *TypeaheadService {
 	return &TypeaheadService{
-		trie: NewTrie(),
+		trie:         NewTrie(),
+		phrases:      newPhraseIndex(),
+		defaultLimit: DefaultSuggestLimit,
+		maxLimit:     MaxSuggestLimit,
+	}
+}
+
+// SetLimits sets the page size used when none is asked for and the largest
+// page size served. Values below 1 keep the current setting.
+func (s *TypeaheadService) SetLimits(defaultLimit, maxLimit int) {
+	s.limitsMu.Lock()
+	defer s.limitsMu.Unlock()
+
+	if maxLimit > 0 {
+		s.maxLimit = maxLimit
+	}
+	if defaultLimit > 0 {
+		s.defaultLimit = defaultLimit
 	}
+	if s.defaultLimit > s.maxLimit {
+		s.defaultLimit = s.maxLimit
+	}
+}
+
+// pageLimit returns limit clamped to the configured maximum, or the default
+// limit if limit is 0 or less
+func (s *TypeaheadService) pageLimit(limit int) int {
+	s.limitsMu.RLock()
+	defer s.limitsMu.RUnlock()
+
+	if limit <= 0 {
+		return s.defaultLimit
+	}
+	if limit > s.maxLimit {
+		return s.maxLimit
+	}
+	return limit
+}
+
+// SuggestionPage is one page of suggestions
+type SuggestionPage struct {
+	Suggestions []string `json:"suggestions"`
+	Offset      int      `json:"offset"`
+	Limit       int      `json:"limit"`
+	HasMore     bool     `json:"has_more"`
+}
+
+// SuggestPage returns a page of suggestions for a prefix. limit is clamped
+// to the configured maximum, and 0 means the default limit.
+func (s *TypeaheadService) SuggestPage(prefix string, offset, limit int) SuggestionPage {
+	if offset < 0 {
+		offset = 0
+	}
+	limit = s.pageLimit(limit)
+
+	// One extra word tells us whether there's another page
+	words, more := pageOf(s.ranked(prefix, pageWindow(offset, limit)), offset, limit)
+	return SuggestionPage{Suggestions: words, Offset: offset, Limit: limit, HasMore: more}
 }
 
 // AddWord adds a word to the typeahead
@@ -158,47 +348,159 @@ func (s *TypeaheadService) AddWord(word string, score int) {
 	s.trie.Insert(word, score)
 }
 
-// GetSuggestions returns suggestions for a prefix
+// GetSuggestions returns suggestions for a prefix, including phrases with
+// any word starting with the prefix. A limit of 0 or less returns them all.
 func (s *TypeaheadService) GetSuggestions(prefix string, limit int) []string {
-	return s.trie.Search(prefix, limit)
+	if limit <= 0 {
+		limit = math.MaxInt
+	}
+	words, _ := pageOf(s.ranked(prefix, limit), 0, limit)
+	return words
+}
+
+// ranked returns the k highest ranked words and phrases for a prefix. A
+// phrase matched through several of its words, or also stored whole in the
+// trie, appears once.
+func (s *TypeaheadService) ranked(prefix string, k int) []scoredWord {
+	top := &scoredWords{}
+	phrases := s.phrases.match(prefix)
+
+	skip := make(map[string]bool, len(phrases))
+	for _, phrase := range phrases {
+		skip[strings.ToLower(phrase.word)] = true
+		top.offer(phrase, k)
+	}
+	s.trie.collectTop(prefix, top, k, skip)
+
+	return top.ranked()
 }
 
-// DeleteWord deletes a word from the typeahead
+// DeleteWord deletes a word or phrase from the typeahead
 func (s *TypeaheadService) DeleteWord(word string) bool {
-	return s.trie.Delete(word)
+	removedPhrase := s.phrases.remove(word)
+	return s.trie.Delete(word) || removedPhrase
+}
+
+// wordState is one word and its score in a snapshot
+type wordState struct {
+	Word  string `json:"word"`
+	Score int    `json:"score"`
+}
+
+// typeaheadState is the serialized form of the service: its words and
+// phrases, sorted so snapshots of the same service are identical. Phrases
+// are also in Words, as they are stored whole in the trie.
+type typeaheadState struct {
+	Words   []wordState `json:"words"`
+	Phrases []wordState `json:"phrases,omitempty"`
+}
+
+// Snapshot serializes every word in the trie with its score to JSON
+func (s *TypeaheadService) Snapshot() ([]byte, error) {
+	s.trie.mu.RLock()
+	var results []struct {
+		word  string
+		score int
+	}
+	s.trie.collectWords(s.trie.root, &results)
+	s.trie.mu.RUnlock()
+
+	state := typeaheadState{Words: make([]wordState, 0, len(results))}
+	for _, r := range results {
+		state.Words = append(state.Words, wordState{Word: r.word, Score: r.score})
+	}
+	sort.Slice(state.Words, func(i, j int) bool {
+		return state.Words[i].Word < state.Words[j].Word
+	})
+	state.Phrases = s.phrases.all()
+
+	return json.Marshal(state)
+}
+
+// Restore replaces every word with those in a snapshot. The new trie is
+// built aside and swapped in, so suggestions never see a partial state.
+func (s *TypeaheadService) Restore(data []byte) error {
+	var state typeaheadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	staged := NewTrie()
+	for _, w := range state.Words {
+		if w.Word == "" {
+			return fmt.Errorf("empty word in snapshot")
+		}
+		staged.Insert(w.Word, w.Score)
+	}
+
+	phrases := newPhraseIndex()
+	for _, p := range state.Phrases {
+		if strings.TrimSpace(p.Word) == "" {
+			return fmt.Errorf("empty phrase in snapshot")
+		}
+		phrases.add(p.Word, p.Score)
+	}
+
+	s.trie.mu.Lock()
+	s.trie.root = staged.root
+	s.trie.mu.Unlock()
+	s.phrases.replace(phrases)
+
+	return nil
 }
 
 var service *TypeaheadService
 
+// addWordRequest is the body of /add
+type addWordRequest struct {
+	Word  string `json:"word"`
+	Score int    `json:"score"`
+
+	// Phrase also suggests a multi-word Word by prefixes of each of its words
+	Phrase bool `json:"phrase,omitempty"`
+}
+
+func (req addWordRequest) validate() error {
+	var v validate.Validator
+	v.String("word", req.Word, validate.Required, validate.MaxLen(maxWordLength))
+	v.Int("score", req.Score, validate.Min(0))
+	return v.Err()
+}
+
 func addWordHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Word  string `json:"word"`
-		Score int    `json:"score"`
-	}
+	var req addWordRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	service.AddWord(req.Word, req.Score)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	if req.Phrase {
+		service.AddPhrase(req.Word, req.Score)
+	} else {
+		service.AddWord(req.Word, req.Score)
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
 func suggestHandler(w http.ResponseWriter, r *http.Request) {
 	prefix := r.URL.Query().Get("prefix")
 	if prefix == "" {
-		http.Error(w, "prefix parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "prefix parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	limit := 10 // default limit
-	suggestions := service.GetSuggestions(prefix, limit)
+	suggestions := service.GetSuggestions(prefix, service.pageLimit(0))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -206,20 +508,50 @@ func suggestHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func suggestPaginatedHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	if prefix == "" {
+		apierror.Error(w, "prefix parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	offset, limit := 0, 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			apierror.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apierror.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(service.SuggestPage(prefix, offset, limit))
+}
+
 func deleteWordHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	word := r.URL.Query().Get("word")
 	if word == "" {
-		http.Error(w, "word parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "word parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	if !service.DeleteWord(word) {
-		http.Error(w, "word not found", http.StatusNotFound)
+		apierror.Error(w, "word not found", http.StatusNotFound)
 		return
 	}
 
@@ -231,6 +563,61 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Typeahead Service", "1.0.0")
+
+	// Readiness fails if the trie lock is stuck
+	checker := health.NewChecker()
+	checker.Register("trie", true, health.LockCheck(&service.trie.mu, time.Second))
+
+	// State dumps need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	api.HandleFunc("/add", addWordHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Add a word or phrase, or raise its score", Request: addWordRequest{},
+		Responses: map[int]string{200: "Word added", 400: "Invalid request"},
+	})
+	api.HandleFunc("/suggest", suggestHandler, openapi.Route{
+		Summary: "Suggest completions for a prefix", Query: []openapi.Param{{Name: "prefix", Required: true}},
+		Responses: map[int]string{200: "The highest scoring completions", 400: "Missing prefix"},
+	})
+	api.HandleFunc("/suggest/paginated", suggestPaginatedHandler, openapi.Route{
+		Summary: "Page through completions for a prefix, highest scoring first",
+		Query: []openapi.Param{
+			{Name: "prefix", Required: true},
+			{Name: "offset", Description: "Completions to skip, default 0"},
+			{Name: "limit", Description: fmt.Sprintf("Page size, default %d, max %d", DefaultSuggestLimit, MaxSuggestLimit)},
+		},
+		Response:  SuggestionPage{},
+		Responses: map[int]string{200: "A page of completions", 400: "Missing prefix or invalid paging"},
+	})
+	api.HandleFunc("/delete", deleteWordHandler, openapi.Route{
+		Method: http.MethodDelete, Summary: "Delete a word", Query: []openapi.Param{{Name: "word", Required: true}},
+		Responses: map[int]string{200: "Word deleted", 400: "Missing word", 404: "Word not found"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(typeaheadState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	service = NewTypeaheadService()
 
@@ -240,13 +627,9 @@ func main() {
 	service.AddWord("apply", 80)
 	service.AddWord("banana", 70)
 
-	http.HandleFunc("/add", addWordHandler)
-	http.HandleFunc("/suggest", suggestHandler)
-	http.HandleFunc("/delete", deleteWordHandler)
-	http.HandleFunc("/health", healthHandler)
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8083"
 	log.Printf("Typeahead service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// pagedService returns a service with word0..word24 scored 0..24
+func pagedService() *TypeaheadService {
+	s := NewTypeaheadService()
+	for i := 0; i < 25; i++ {
+		s.AddWord(fmt.Sprintf("word%02d", i), i)
+	}
+	return s
+}
+
+func TestSuggestPage_WalksEveryWordOnce(t *testing.T) {
+	s := pagedService()
+
+	var seen []string
+	offset := 0
+	for {
+		page := s.SuggestPage("word", offset, 10)
+		seen = append(seen, page.Suggestions...)
+		if !page.HasMore {
+			break
+		}
+		offset += len(page.Suggestions)
+	}
+
+	if len(seen) != 25 {
+		t.Fatalf("Expected 25 words across pages, got %d: %v", len(seen), seen)
+	}
+	for i, word := range seen {
+		if want := fmt.Sprintf("word%02d", 24-i); word != want {
+			t.Errorf("position %d: expected %s, got %s", i, want, word)
+		}
+	}
+}
+
+func TestSuggestPage_HasMore(t *testing.T) {
+	s := pagedService()
+
+	tests := []struct {
+		offset, limit int
+		count         int
+		more          bool
+	}{
+		{0, 10, 10, true},
+		{15, 10, 10, false}, // ends exactly on the last word
+		{20, 10, 5, false},
+		{24, 1, 1, false},
+		{23, 1, 1, true},
+	}
+	for _, tt := range tests {
+		page := s.SuggestPage("word", tt.offset, tt.limit)
+		if len(page.Suggestions) != tt.count || page.HasMore != tt.more {
+			t.Errorf("offset %d limit %d: expected %d words, more=%v; got %d, more=%v",
+				tt.offset, tt.limit, tt.count, tt.more, len(page.Suggestions), page.HasMore)
+		}
+	}
+}
+
+func TestSuggestPage_OffsetBeyondResults(t *testing.T) {
+	s := pagedService()
+
+	page := s.SuggestPage("word", 100, 10)
+	if len(page.Suggestions) != 0 || page.HasMore {
+		t.Errorf("Expected an empty last page, got %+v", page)
+	}
+	if page.Suggestions == nil {
+		t.Error("Expected an empty list rather than null")
+	}
+}
+
+func TestSuggestPage_HugeOffset(t *testing.T) {
+	s := pagedService()
+
+	for _, offset := range []int{math.MaxInt, math.MaxInt - 5} {
+		page := s.SuggestPage("word", offset, 10)
+		if len(page.Suggestions) != 0 || page.HasMore {
+			t.Errorf("offset %d: expected an empty last page, got %+v", offset, page)
+		}
+	}
+	if words, more := s.trie.SearchPage("word", math.MaxInt, 10); len(words) != 0 || more {
+		t.Errorf("Expected an empty last trie page, got %v, more=%v", words, more)
+	}
+
+	service = s
+	w := httptest.NewRecorder()
+	suggestPaginatedHandler(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/suggest/paginated?prefix=word&offset=%d", math.MaxInt), nil))
+	var page SuggestionPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if w.Code != http.StatusOK || page.HasMore {
+		t.Errorf("Expected 200 with no more pages, got %d: %+v", w.Code, page)
+	}
+}
+
+func TestSuggestPage_ClampsLimit(t *testing.T) {
+	s := pagedService()
+	s.SetLimits(5, 20)
+
+	if page := s.SuggestPage("word", 0, 1000); page.Limit != 20 || len(page.Suggestions) != 20 {
+		t.Errorf("Expected the limit clamped to 20, got limit %d with %d words", page.Limit, len(page.Suggestions))
+	}
+	if page := s.SuggestPage("word", 0, 0); page.Limit != 5 || len(page.Suggestions) != 5 {
+		t.Errorf("Expected the default limit of 5, got limit %d with %d words", page.Limit, len(page.Suggestions))
+	}
+}
+
+func TestSuggestPage_BreaksTiesAlphabetically(t *testing.T) {
+	s := NewTypeaheadService()
+	for _, word := range []string{"cd", "ca", "cc", "cb"} {
+		s.AddWord(word, 1)
+	}
+
+	first := s.SuggestPage("c", 0, 2)
+	second := s.SuggestPage("c", 2, 2)
+	if !reflect.DeepEqual(first.Suggestions, []string{"ca", "cb"}) || !reflect.DeepEqual(second.Suggestions, []string{"cc", "cd"}) {
+		t.Errorf("Expected alphabetical pages for equal scores, got %v and %v", first.Suggestions, second.Suggestions)
+	}
+}
+
+func TestSuggestPaginatedHandler(t *testing.T) {
+	service = pagedService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/suggest/paginated?prefix=word&offset=20&limit=500", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var page SuggestionPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if page.Offset != 20 || page.Limit != MaxSuggestLimit || len(page.Suggestions) != 5 || page.HasMore {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+
+	for _, query := range []string{"offset=-1", "limit=0", "limit=abc"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/suggest/paginated?prefix=word&"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
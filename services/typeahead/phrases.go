@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// phraseIndex maps each word of a phrase back to the phrase, so a phrase is
+// suggested when the prefix matches any of its words and not just the first.
+// Phrases are keyed lowercased, like the trie, so phrases differing only in
+// case are one phrase shown as it was last added.
+type phraseIndex struct {
+	mu      sync.RWMutex
+	words   *Trie                      // every word of every phrase, lowercased
+	phrases map[string]map[string]bool // word -> lowercased phrases
+	scores  map[string]scoredWord      // lowercased phrase -> phrase and score
+}
+
+func newPhraseIndex() *phraseIndex {
+	return &phraseIndex{
+		words:   NewTrie(),
+		phrases: make(map[string]map[string]bool),
+		scores:  make(map[string]scoredWord),
+	}
+}
+
+// AddPhrase adds a multi-word phrase. It is suggested for prefixes of the
+// whole phrase, like a word, and also for prefixes of each of its words, so
+// "lea" suggests "machine learning".
+func (s *TypeaheadService) AddPhrase(phrase string, score int) {
+	s.trie.Insert(phrase, score)
+	s.phrases.add(phrase, score)
+}
+
+// add indexes phrase under each of its words, replacing its score if it is
+// already indexed
+func (ix *phraseIndex) add(phrase string, score int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	key := strings.ToLower(phrase)
+	ix.scores[key] = scoredWord{word: phrase, score: score}
+	for _, word := range strings.Fields(key) {
+		if ix.phrases[word] == nil {
+			ix.phrases[word] = make(map[string]bool)
+			ix.words.Insert(word, 0)
+		}
+		ix.phrases[word][key] = true
+	}
+}
+
+// remove drops phrase from the index and reports whether it was there
+func (ix *phraseIndex) remove(phrase string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	key := strings.ToLower(phrase)
+	if _, exists := ix.scores[key]; !exists {
+		return false
+	}
+
+	delete(ix.scores, key)
+	for _, word := range strings.Fields(key) {
+		delete(ix.phrases[word], key)
+		if len(ix.phrases[word]) == 0 {
+			delete(ix.phrases, word)
+			ix.words.Delete(word)
+		}
+	}
+	return true
+}
+
+// match returns each phrase with a word starting with prefix, once
+func (ix *phraseIndex) match(prefix string) []scoredWord {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if len(ix.scores) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var matches []scoredWord
+	for _, word := range ix.words.Search(prefix, 0) {
+		for key := range ix.phrases[word] {
+			if !seen[key] {
+				seen[key] = true
+				matches = append(matches, ix.scores[key])
+			}
+		}
+	}
+	return matches
+}
+
+// all returns every phrase with its score, sorted by phrase
+func (ix *phraseIndex) all() []wordState {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	phrases := make([]wordState, 0, len(ix.scores))
+	for _, phrase := range ix.scores {
+		phrases = append(phrases, wordState{Word: phrase.word, Score: phrase.score})
+	}
+	sort.Slice(phrases, func(i, j int) bool { return phrases[i].Word < phrases[j].Word })
+	return phrases
+}
+
+// replace swaps in the contents of other
+func (ix *phraseIndex) replace(other *phraseIndex) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.words = other.words
+	ix.phrases = other.phrases
+	ix.scores = other.scores
+}
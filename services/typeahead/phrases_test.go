@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAddPhrase_MatchesAnyWord(t *testing.T) {
+	s := NewTypeaheadService()
+	s.AddPhrase("machine learning", 50)
+	s.AddWord("leap", 10)
+
+	got := s.GetSuggestions("lea", 10)
+	want := []string{"machine learning", "leap"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSuggestions(lea) = %v, want %v", got, want)
+	}
+
+	// The whole phrase still matches from its start
+	if got := s.GetSuggestions("machine l", 10); !reflect.DeepEqual(got, []string{"machine learning"}) {
+		t.Errorf("GetSuggestions(machine l) = %v", got)
+	}
+	if got := s.GetSuggestions("xyz", 10); len(got) != 0 {
+		t.Errorf("GetSuggestions(xyz) = %v, want none", got)
+	}
+}
+
+func TestAddPhrase_NoDuplicates(t *testing.T) {
+	s := NewTypeaheadService()
+	// Reachable through "data", "dashboards" and the whole phrase in the trie
+	s.AddPhrase("data dashboards data", 30)
+	s.AddPhrase("big data", 20)
+
+	got := s.GetSuggestions("da", 10)
+	want := []string{"data dashboards data", "big data"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSuggestions(da) = %v, want %v", got, want)
+	}
+
+	// Re-adding a phrase updates its score rather than adding it twice
+	s.AddPhrase("big data", 40)
+	want = []string{"big data", "data dashboards data"}
+	if got := s.GetSuggestions("da", 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("after rescoring, GetSuggestions(da) = %v, want %v", got, want)
+	}
+}
+
+func TestAddPhrase_LimitAndPages(t *testing.T) {
+	s := NewTypeaheadService()
+	s.AddPhrase("deep learning", 30)
+	s.AddPhrase("machine learning", 20)
+	s.AddWord("learn", 10)
+
+	if got := s.GetSuggestions("lea", 2); !reflect.DeepEqual(got, []string{"deep learning", "machine learning"}) {
+		t.Errorf("GetSuggestions(lea, 2) = %v", got)
+	}
+
+	first := s.SuggestPage("lea", 0, 2)
+	if !first.HasMore || !reflect.DeepEqual(first.Suggestions, []string{"deep learning", "machine learning"}) {
+		t.Errorf("first page = %+v", first)
+	}
+	second := s.SuggestPage("lea", 2, 2)
+	if second.HasMore || !reflect.DeepEqual(second.Suggestions, []string{"learn"}) {
+		t.Errorf("second page = %+v", second)
+	}
+}
+
+func TestDeleteWord_RemovesPhrase(t *testing.T) {
+	s := NewTypeaheadService()
+	s.AddPhrase("machine learning", 50)
+
+	if !s.DeleteWord("machine learning") {
+		t.Fatal("DeleteWord(machine learning) = false, want true")
+	}
+	if got := s.GetSuggestions("lea", 10); len(got) != 0 {
+		t.Errorf("GetSuggestions(lea) after delete = %v, want none", got)
+	}
+}
+
+func TestDeleteWord_RemovesPhraseInAnyCase(t *testing.T) {
+	s := NewTypeaheadService()
+	s.AddPhrase("Machine Learning", 50)
+
+	if !s.DeleteWord("machine learning") {
+		t.Fatal("DeleteWord(machine learning) = false, want true")
+	}
+	for _, prefix := range []string{"lea", "mach"} {
+		if got := s.GetSuggestions(prefix, 10); len(got) != 0 {
+			t.Errorf("GetSuggestions(%s) after delete = %v, want none", prefix, got)
+		}
+	}
+}
+
+func TestAddPhrase_CaseVariantsAreOnePhrase(t *testing.T) {
+	s := NewTypeaheadService()
+	s.AddPhrase("Machine Learning", 50)
+	s.AddPhrase("machine learning", 60)
+
+	// The phrase shows as it was last added, with its latest score
+	want := []string{"machine learning"}
+	for _, prefix := range []string{"lea", "mach", "MACH"} {
+		if got := s.GetSuggestions(prefix, 10); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetSuggestions(%s) = %v, want %v", prefix, got, want)
+		}
+	}
+	if phrases := s.phrases.all(); len(phrases) != 1 || phrases[0].Score != 60 {
+		t.Errorf("Expected one phrase scored 60, got %v", phrases)
+	}
+}
+
+func TestSnapshot_RestoresPhrases(t *testing.T) {
+	s := NewTypeaheadService()
+	s.AddPhrase("machine learning", 50)
+	s.AddWord("leap", 10)
+
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restored := NewTypeaheadService()
+	restored.AddPhrase("stale phrase", 99)
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	want := []string{"machine learning", "leap"}
+	if got := restored.GetSuggestions("lea", 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored GetSuggestions(lea) = %v, want %v", got, want)
+	}
+	if got := restored.GetSuggestions("phr", 10); len(got) != 0 {
+		t.Errorf("restore kept a stale phrase: %v", got)
+	}
+}
+
+func TestAddHandler_Phrase(t *testing.T) {
+	service = NewTypeaheadService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	body := []byte(`{"word":"machine learning","score":5,"phrase":true}`)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/add", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /add status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	if got := service.GetSuggestions("lea", 10); !reflect.DeepEqual(got, []string{"machine learning"}) {
+		t.Errorf("GetSuggestions(lea) = %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"common/middleware"
+)
+
+// waitForJob polls until a job has finished crawling
+func waitForJob(t *testing.T, service *WebCrawlerService, jobID string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		service.mu.RLock()
+		status := service.jobs[jobID].Status
+		service.mu.RUnlock()
+		if status == "completed" || status == "failed" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", jobID)
+}
+
+func TestClearJob_RemovesOnlyItsPages(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	a, _ := service.CreateCrawlJob(context.Background(), "https://a.com", 3)
+	waitForJob(t, service, a.ID)
+	b, _ := service.CreateCrawlJob(context.Background(), "https://b.com", 2)
+	waitForJob(t, service, b.ID)
+
+	removed, err := service.ClearJob(a.ID)
+	if err != nil || removed != 3 {
+		t.Fatalf("Expected 3 pages removed, got %d, %v", removed, err)
+	}
+
+	if page, _ := service.GetPage("https://a.com"); page != nil {
+		t.Error("Expected a.com to be removed")
+	}
+	if page, _ := service.GetPage("https://b.com"); page == nil {
+		t.Error("Expected b.com to be kept")
+	}
+	if len(service.ListPages()) != 2 {
+		t.Errorf("Expected b's 2 pages to remain, got %d", len(service.ListPages()))
+	}
+
+	// The search index and visited set forget the removed pages
+	if results, _ := service.SearchPages("a", 10); len(results) != 0 {
+		t.Errorf("Expected no search results for a.com's pages, got %d", len(results))
+	}
+	if service.isVisited("https://a.com") {
+		t.Error("Expected a.com to be crawlable again")
+	}
+
+	graph, _ := service.GetLinkGraph(a.ID)
+	job, _ := service.GetJob(a.ID)
+	if len(graph) != 0 || job.Pages != 0 {
+		t.Errorf("Expected an empty graph and page count, got %d and %d", len(graph), job.Pages)
+	}
+
+	// Clearing again removes nothing
+	if removed, _ := service.ClearJob(a.ID); removed != 0 {
+		t.Errorf("Expected nothing left to remove, got %d", removed)
+	}
+}
+
+func TestClearJob_Errors(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	if _, err := service.ClearJob("missing"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+
+	service.jobs["job_x"] = &CrawlJob{ID: "job_x", Status: "running"}
+	if _, err := service.ClearJob("job_x"); err != ErrJobRunning {
+		t.Errorf("Expected ErrJobRunning, got %v", err)
+	}
+}
+
+func TestClearJobHandler(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	service = NewWebCrawlerService()
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	job, _ := service.CreateCrawlJob(context.Background(), "https://a.com", 2)
+	waitForJob(t, service, job.ID)
+
+	clearJob := func(jobID, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/job/clear?job_id="+jobID, nil)
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if w := clearJob(job.ID, token); w.Code != http.StatusForbidden {
+			t.Errorf("Token %q: expected 403, got %d", token, w.Code)
+		}
+	}
+	if pages := service.ListPages(); len(pages) != 2 {
+		t.Fatalf("Expected the pages kept without the admin token, got %d", len(pages))
+	}
+
+	w := clearJob(job.ID, "s3cret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp clearJobResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Removed != 2 {
+		t.Errorf("Expected 2 pages removed, got %d", resp.Removed)
+	}
+
+	if w := clearJob("missing", "s3cret"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", w.Code)
+	}
+}
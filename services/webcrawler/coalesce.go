@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// jobKey identifies crawl jobs that would do the same work: the same
+// normalized start URL crawled to the same depth and page limit
+func jobKey(rawURL string, depth, maxPages int) string {
+	return normalizeURL(rawURL) + "#" + strconv.Itoa(depth) + "#" + strconv.Itoa(maxPages)
+}
+
+// normalizeURL lowercases the scheme and host and drops the fragment and a
+// trailing slash, so trivially different spellings of a URL share a key
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// SetReuseCompletedJobs sets whether CreateCrawlJob returns the last
+// completed job for the same URL and depth, serving its already crawled
+// pages, instead of starting a new crawl. In-flight jobs are always
+// shared.
+func (s *WebCrawlerService) SetReuseCompletedJobs(reuse bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reuseCompleted = reuse
+}
+
+// existingJobLocked returns the job a request for key should attach to
+// instead of starting a crawl, if any. Must be called with s.mu held.
+func (s *WebCrawlerService) existingJobLocked(key string) *CrawlJob {
+	job, exists := s.jobsByKey[key]
+	if !exists {
+		return nil
+	}
+	switch job.Status {
+	case "pending", "running":
+		return job
+	case "completed":
+		if s.reuseCompleted {
+			return job
+		}
+	}
+	return nil
+}
+
+// forgetJobLocked stops later requests from attaching to job. Must be
+// called with s.mu held.
+func (s *WebCrawlerService) forgetJobLocked(job *CrawlJob) {
+	key := jobKey(job.URL, job.Depth, job.MaxPages)
+	if s.jobsByKey[key] == job {
+		delete(s.jobsByKey, key)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// blockingFetcher counts fetches and holds each one until release closes
+// or the fetch's context ends
+type blockingFetcher struct {
+	fetches int64
+	release chan struct{}
+}
+
+func (f *blockingFetcher) Fetch(ctx context.Context, pageURL, etag, lastModified string) (*Page, error) {
+	atomic.AddInt64(&f.fetches, 1)
+	select {
+	case <-f.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return simulatedFetcher{}.Fetch(ctx, pageURL, etag, lastModified)
+}
+
+func TestCreateCrawlJob_CoalescesInFlightDuplicates(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+
+	// Spellings that normalize to the same URL share the job
+	urls := []string{"https://example.com", "https://EXAMPLE.com/", "https://example.com#top"}
+
+	jobs := make([]*CrawlJob, 30)
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jobs[i], _ = service.CreateCrawlJob(context.Background(), urls[i%len(urls)], 1)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, job := range jobs {
+		if job != jobs[0] {
+			t.Fatalf("Expected every duplicate to attach to job %s, got %s", jobs[0].ID, job.ID)
+		}
+	}
+
+	close(fetcher.release)
+	waitForJob(t, service, jobs[0].ID)
+
+	if n := atomic.LoadInt64(&fetcher.fetches); n != 1 {
+		t.Errorf("Expected a single crawl to fetch the page once, got %d fetches", n)
+	}
+	if len(service.jobs) != 1 {
+		t.Errorf("Expected 1 job, got %d", len(service.jobs))
+	}
+}
+
+func TestCreateCrawlJob_DifferentDepthIsNewJob(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+
+	shallow, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+	deep, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 2)
+	if shallow == deep {
+		t.Error("Expected a new job for a different depth")
+	}
+
+	close(fetcher.release)
+	waitForJob(t, service, shallow.ID)
+	waitForJob(t, service, deep.ID)
+}
+
+func TestCreateCrawlJob_AfterCompletion(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	first, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+	waitForJob(t, service, first.ID)
+
+	second, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+	if second == first {
+		t.Error("Expected a new crawl once the first job completed")
+	}
+	waitForJob(t, service, second.ID)
+
+	service.SetReuseCompletedJobs(true)
+	third, _ := service.CreateCrawlJob(context.Background(), "https://example.com/", 1)
+	if third != second {
+		t.Errorf("Expected the completed job %s to be reused, got %s", second.ID, third.ID)
+	}
+
+	// A cleared job has no pages left to serve
+	service.ClearJob(second.ID)
+	fourth, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+	if fourth == second {
+		t.Error("Expected a cleared job not to be reused")
+	}
+	waitForJob(t, service, fourth.ID)
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"HTTPS://Example.COM/Path/": "https://example.com/Path",
+		"https://example.com/#frag": "https://example.com",
+		"https://example.com/a?q=1": "https://example.com/a?q=1",
+	}
+	for in, want := range tests {
+		if got := normalizeURL(in); got != want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
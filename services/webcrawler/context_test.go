@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCrawl_CancelledMidFetch(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+	job := &CrawlJob{ID: "job-1", URL: "https://example.com", Depth: 5, MaxPages: DefaultMaxPages}
+	service.jobs[job.ID] = job
+	service.graphs[job.ID] = make(map[string][]string)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- service.crawl(ctx, job, []string{job.URL}) }()
+
+	// Cancel while the first fetch is blocked
+	for atomic.LoadInt64(&fetcher.fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected crawl to return promptly after cancellation")
+	}
+
+	got, _ := service.GetJob(job.ID)
+	if got.Status != "failed" || got.Note != context.Canceled.Error() {
+		t.Errorf("Expected a failed job noting the cancellation, got %q (%q)", got.Status, got.Note)
+	}
+}
+
+func TestCreateCrawlJob_Timeout(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+
+	job, _ := service.CreateCrawlJobWithOptions(context.Background(), "https://example.com", 3,
+		CrawlOptions{Timeout: 20 * time.Millisecond})
+	waitForJob(t, service, job.ID)
+
+	got, _ := service.GetJob(job.ID)
+	if got.Status != "failed" || !strings.Contains(got.Note, "deadline exceeded") {
+		t.Errorf("Expected the job to fail on its deadline, got %q (%q)", got.Status, got.Note)
+	}
+}
+
+func TestCreateCrawlJob_OutlivesRequest(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job, err := service.CreateCrawlJob(ctx, "https://example.com", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The request that started the crawl ends before the crawl does
+	cancel()
+	close(fetcher.release)
+	waitForJob(t, service, job.ID)
+
+	if got, _ := service.GetJob(job.ID); got.Status != "completed" || got.Pages == 0 {
+		t.Errorf("Expected the crawl to complete after its request ended, got %+v", got)
+	}
+
+	if _, err := service.CreateCrawlJob(ctx, "https://example.org", 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled request not to start a job, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const maxPageBytes = 5 << 20
+
+// htmlContentType is the only media type links are extracted from
+const htmlContentType = "text/html"
+
+var (
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	hrefPattern  = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#]+)`)
+)
+
+// Fetcher retrieves a single page. When etag or lastModified are non-empty
+// they are sent as conditional request headers; an unchanged page is
+// returned with StatusCode 304 and no content. Fetch gives up with ctx's
+// error once ctx is done.
+type Fetcher interface {
+	Fetch(ctx context.Context, pageURL, etag, lastModified string) (*Page, error)
+}
+
+// simulatedFetcher fabricates pages without any network access
+type simulatedFetcher struct{}
+
+// Fetch returns a synthetic page with two child links
+func (simulatedFetcher) Fetch(ctx context.Context, pageURL, etag, lastModified string) (*Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &Page{
+		URL:        pageURL,
+		Title:      "Page Title for " + pageURL,
+		Content:    "Content for " + pageURL,
+		Links:      []string{pageURL + "/link1", pageURL + "/link2"},
+		CrawledAt:  time.Now(),
+		StatusCode: 200,
+
+		ContentType: htmlContentType,
+	}
+
+	// Generate content hash
+	hash := md5.Sum([]byte(page.Content))
+	page.ContentHash = hex.EncodeToString(hash[:])
+
+	return page, nil
+}
+
+// HTTPFetcher fetches pages over HTTP
+type HTTPFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPFetcher creates a fetcher using the given client, or a client with
+// the default fetch timeout when nil
+func NewHTTPFetcher(client *http.Client) *HTTPFetcher {
+	if client == nil {
+		client = &http.Client{Timeout: fetchTimeout}
+	}
+	return &HTTPFetcher{client: client}
+}
+
+// Fetch performs a (conditional) GET and records the response's media
+// type. Only HTML is parsed for a title and links. Other text, such as
+// JSON or plain text, is stored as content without links; binary bodies
+// are only hashed, so they don't fill the search index with noise.
+func (f *HTTPFetcher) Fetch(ctx context.Context, pageURL, etag, lastModified string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page := &Page{
+		URL:          pageURL,
+		CrawledAt:    time.Now(),
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return page, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %d", pageURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	page.ContentType = detectContentType(resp.Header.Get("Content-Type"), body)
+	switch {
+	case page.ContentType == htmlContentType:
+		page.Content = string(body)
+		if match := titlePattern.FindSubmatch(body); match != nil {
+			page.Title = strings.TrimSpace(string(match[1]))
+		}
+		page.Links = extractLinks(pageURL, body)
+	case isTextContentType(page.ContentType):
+		page.Content = string(body)
+	}
+
+	hash := md5.Sum(body)
+	page.ContentHash = hex.EncodeToString(hash[:])
+
+	return page, nil
+}
+
+// detectContentType returns the media type of a response, without
+// parameters, from its Content-Type header, or sniffed from the body when
+// the header is missing or malformed
+func detectContentType(header string, body []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(header); err == nil {
+		return mediaType
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	return mediaType
+}
+
+// isTextContentType reports whether a media type is text worth storing and
+// indexing: any text/* type, and JSON, XML and JavaScript
+func isTextContentType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// extractLinks returns the absolute http(s) links found in href attributes
+func extractLinks(pageURL string, body []byte) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var links []string
+	for _, match := range hrefPattern.FindAllSubmatch(body, -1) {
+		ref, err := url.Parse(strings.TrimSpace(string(match[1])))
+		if err != nil {
+			continue
+		}
+
+		link := base.ResolveReference(ref)
+		if link.Scheme != "http" && link.Scheme != "https" {
+			continue
+		}
+
+		if s := link.String(); !seen[s] {
+			seen[s] = true
+			links = append(links, s)
+		}
+	}
+	return links
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPFetcher_ParsesPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Write([]byte(`<html><head><title> Home </title></head><body>
+<a href="/about">About</a>
+<a href='https://other.com/x#top'>Other</a>
+<a href="/about">Again</a>
+<a href="mailto:me@example.com">Mail</a>
+</body></html>`))
+	}))
+	defer server.Close()
+
+	page, err := NewHTTPFetcher(server.Client()).Fetch(context.Background(), server.URL+"/index.html", "", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if page.Title != "Home" {
+		t.Errorf("Expected title 'Home', got %q", page.Title)
+	}
+	if page.LastModified != "Wed, 21 Oct 2015 07:28:00 GMT" {
+		t.Errorf("Expected Last-Modified to be stored, got %q", page.LastModified)
+	}
+	if page.ContentHash == "" {
+		t.Error("Expected content hash")
+	}
+
+	expected := []string{server.URL + "/about", "https://other.com/x"}
+	if len(page.Links) != len(expected) {
+		t.Fatalf("Expected links %v, got %v", expected, page.Links)
+	}
+	for i := range expected {
+		if page.Links[i] != expected[i] {
+			t.Errorf("Expected links %v, got %v", expected, page.Links)
+		}
+	}
+}
+
+func TestHTTPFetcher_SendsConditionalHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"abc"` || r.Header.Get("If-Modified-Since") == "" {
+			t.Errorf("Expected conditional headers, got %v", r.Header)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	page, err := NewHTTPFetcher(server.Client()).Fetch(context.Background(), server.URL, `"abc"`, "Wed, 21 Oct 2015 07:28:00 GMT")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if page.StatusCode != http.StatusNotModified || page.Content != "" {
+		t.Errorf("Expected empty 304 page, got %+v", page)
+	}
+}
+
+func TestHTTPFetcher_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := NewHTTPFetcher(server.Client()).Fetch(context.Background(), server.URL, "", ""); err == nil {
+		t.Error("Expected error for 404")
+	}
+}
+
+// contentServer serves an HTML page at /, JSON at /api and a binary blob
+// at /blob. Every body mentions /next so only parsing can tell them apart.
+func contentServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><title>Home</title><a href="/next">Next</a></html>`))
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"href": "/next", "html": "<a href=\"/next\">"}`))
+	})
+	mux.HandleFunc("/blob", func(w http.ResponseWriter, r *http.Request) {
+		// No Content-Type: the type is sniffed from the body
+		w.Write([]byte("\x00\x01\x02href=\"/next\"\xff\xfe"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPFetcher_ContentTypes(t *testing.T) {
+	server := contentServer()
+	defer server.Close()
+	fetcher := NewHTTPFetcher(server.Client())
+
+	tests := []struct {
+		path        string
+		contentType string
+		links       int
+		hasContent  bool
+	}{
+		{"/", "text/html", 1, true},
+		{"/api", "application/json", 0, true},
+		{"/blob", "application/octet-stream", 0, false},
+	}
+	for _, tt := range tests {
+		page, err := fetcher.Fetch(context.Background(), server.URL+tt.path, "", "")
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.path, err)
+		}
+		if page.ContentType != tt.contentType {
+			t.Errorf("%s: expected content type %q, got %q", tt.path, tt.contentType, page.ContentType)
+		}
+		if len(page.Links) != tt.links {
+			t.Errorf("%s: expected %d links, got %v", tt.path, tt.links, page.Links)
+		}
+		if (page.Content != "") != tt.hasContent {
+			t.Errorf("%s: expected content stored %v, got %q", tt.path, tt.hasContent, page.Content)
+		}
+		if page.ContentHash == "" {
+			t.Errorf("%s: expected a content hash", tt.path)
+		}
+	}
+}
+
+func TestCrawl_StoresNonHTMLWithoutLinks(t *testing.T) {
+	server := contentServer()
+	defer server.Close()
+	service := NewWebCrawlerService()
+	service.SetFetcher(NewHTTPFetcher(server.Client()))
+
+	for _, path := range []string{"/api", "/blob"} {
+		job, _ := service.CreateCrawlJob(context.Background(), server.URL+path, 5)
+		waitForJob(t, service, job.ID)
+
+		got, _ := service.GetJob(job.ID)
+		if got.Pages != 1 {
+			t.Errorf("%s: expected only the page itself crawled, got %d pages", path, got.Pages)
+		}
+		page, _ := service.GetPage(server.URL + path)
+		if page == nil || page.Links != nil {
+			t.Errorf("%s: expected the page stored without links, got %+v", path, page)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+// DefaultFrontierCapacity is how many URLs a job created without a
+// capacity may have queued at once
+const DefaultFrontierCapacity = 10000
+
+// frontier is a crawl job's bounded FIFO of URLs waiting to be crawled.
+// Once it holds capacity URLs further links are dropped and counted rather
+// than queued, so a link-heavy site can't grow it without limit. A URL
+// already queued is not queued again.
+type frontier struct {
+	urls     []string
+	queued   map[string]bool
+	capacity int
+	dropped  int
+}
+
+// newFrontier creates an empty frontier holding at most capacity URLs
+func newFrontier(capacity int) *frontier {
+	return &frontier{queued: make(map[string]bool), capacity: capacity}
+}
+
+// push queues url unless it is already queued, dropping it if the
+// frontier is full. It reports whether url is queued afterwards.
+func (f *frontier) push(url string) bool {
+	if f.queued[url] {
+		return true
+	}
+	if len(f.urls) >= f.capacity {
+		f.dropped++
+		return false
+	}
+	f.urls = append(f.urls, url)
+	f.queued[url] = true
+	return true
+}
+
+// pop removes and returns the oldest queued URL
+func (f *frontier) pop() (string, bool) {
+	if len(f.urls) == 0 {
+		return "", false
+	}
+	url := f.urls[0]
+	// Clear the slot so the backing array doesn't pin popped URLs
+	f.urls[0] = ""
+	f.urls = f.urls[1:]
+	delete(f.queued, url)
+	return url, true
+}
+
+// len returns how many URLs are queued
+func (f *frontier) len() int {
+	return len(f.urls)
+}
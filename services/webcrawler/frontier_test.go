@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFrontier_NeverExceedsCapacity(t *testing.T) {
+	queue := newFrontier(5)
+
+	// A site far wider than the frontier: every crawled page offers ten
+	// new links
+	for page := 0; page < 50; page++ {
+		for i := 0; i < 10; i++ {
+			queue.push(fmt.Sprintf("https://example.com/%d/%d", page, i))
+			if n := queue.len(); n > 5 {
+				t.Fatalf("Expected at most 5 queued URLs, got %d", n)
+			}
+		}
+		queue.pop()
+	}
+
+	// The first page fills the frontier and drops 5; every later page
+	// finds one free slot and drops 9
+	if want := 5 + 49*9; queue.dropped != want {
+		t.Errorf("Expected %d dropped links, got %d", want, queue.dropped)
+	}
+}
+
+func TestFrontier_DedupsAndKeepsOrder(t *testing.T) {
+	queue := newFrontier(3)
+	for _, url := range []string{"a", "b", "a", "c"} {
+		queue.push(url)
+	}
+	if queue.dropped != 0 || queue.len() != 3 {
+		t.Fatalf("Expected 3 queued and none dropped, got %d and %d", queue.len(), queue.dropped)
+	}
+
+	for _, want := range []string{"a", "b", "c"} {
+		if got, _ := queue.pop(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+	if _, ok := queue.pop(); ok {
+		t.Error("Expected an empty frontier")
+	}
+
+	// A popped URL may be queued again; the visited set keeps crawl from
+	// offering it
+	if !queue.push("a") {
+		t.Error("Expected a popped URL to be queueable again")
+	}
+}
+
+func TestCrawl_ReportsDroppedLinks(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &fanOutFetcher{}
+	service.SetFetcher(fetcher)
+
+	job, err := service.CreateCrawlJobWithOptions(context.Background(), "https://example.com", 20, CrawlOptions{FrontierCapacity: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForJob(t, service, job.ID)
+
+	got, _ := service.GetJob(job.ID)
+	if got.Status != "completed" || got.Pages != 20 || got.FrontierCapacity != 5 {
+		t.Fatalf("Expected a completed 20 page job with capacity 5, got %+v", got)
+	}
+	// Each page links to ten new URLs: the first leaves 5 that don't fit,
+	// each later one 9
+	if want := 5 + 19*9; got.DroppedLinks != want {
+		t.Errorf("Expected %d dropped links, got %d", want, got.DroppedLinks)
+	}
+}
+
+func TestCrawl_DefaultFrontierCapacity(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 3)
+	waitForJob(t, service, job.ID)
+
+	got, _ := service.GetJob(job.ID)
+	if got.FrontierCapacity != DefaultFrontierCapacity || got.DroppedLinks != 0 {
+		t.Errorf("Expected the default capacity and nothing dropped, got %+v", got)
+	}
+}
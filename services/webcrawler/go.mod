@@ -1,3 +1,7 @@
 module webcrawler
 
 go 1.21.5
+
+require common v0.0.0
+
+replace common => ../common
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// fanOutFetcher serves an endless site where every page links to ten more
+type fanOutFetcher struct {
+	fetches int64
+}
+
+func (f *fanOutFetcher) Fetch(ctx context.Context, pageURL, etag, lastModified string) (*Page, error) {
+	atomic.AddInt64(&f.fetches, 1)
+	page, _ := simulatedFetcher{}.Fetch(ctx, pageURL, etag, lastModified)
+	page.Links = nil
+	for i := 0; i < 10; i++ {
+		page.Links = append(page.Links, pageURL+"/"+string(rune('a'+i)))
+	}
+	return page, nil
+}
+
+func TestCrawl_StopsAtMaxPages(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &fanOutFetcher{}
+	service.SetFetcher(fetcher)
+
+	job, err := service.CreateCrawlJobWithOptions(context.Background(), "https://example.com", MaxCrawlDepth, CrawlOptions{MaxPages: 25})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForJob(t, service, job.ID)
+
+	if n := len(service.ListPages()); n != 25 {
+		t.Errorf("Expected exactly 25 pages stored, got %d", n)
+	}
+	if n := atomic.LoadInt64(&fetcher.fetches); n != 25 {
+		t.Errorf("Expected no fetches past the limit, got %d", n)
+	}
+
+	got, _ := service.GetJob(job.ID)
+	if got.Pages != 25 || got.Status != "completed" || got.Note != limitReachedNote {
+		t.Errorf("Expected a completed job with 25 pages and a limit note, got %+v", got)
+	}
+}
+
+func TestCrawl_NoNoteUnderLimit(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 3)
+	waitForJob(t, service, job.ID)
+
+	got, _ := service.GetJob(job.ID)
+	if got.MaxPages != DefaultMaxPages || got.Note != "" || got.Pages != 3 {
+		t.Errorf("Expected 3 pages under the default limit and no note, got %+v", got)
+	}
+}
+
+func TestCreateCrawlJob_DepthLimit(t *testing.T) {
+	service = NewWebCrawlerService()
+
+	if _, err := service.CreateCrawlJob(context.Background(), "https://example.com", MaxCrawlDepth+1); err != ErrDepthTooLarge {
+		t.Errorf("Expected ErrDepthTooLarge, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	body := bytes.NewBufferString(`{"url":"https://example.com","depth":1000000}`)
+	createJobHandler(w, httptest.NewRequest(http.MethodPost, "/crawl", body))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a depth over the cap, got %d", w.Code)
+	}
+}
@@ -1,13 +1,61 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
+
+	"common/admin"
+	"common/apierror"
+	"common/batch"
+	"common/health"
+	"common/jsonstream"
+	"common/middleware"
+	"common/openapi"
+	"common/validate"
+)
+
+const (
+	// MaxCrawlDepth caps the depth of a URL-started crawl job
+	MaxCrawlDepth = 1000
+	// DefaultMaxPages is the page limit of jobs created without one
+	DefaultMaxPages = 10000
+	// DefaultCrawlTimeout bounds crawls and recrawls created without a
+	// timeout
+	DefaultCrawlTimeout = 10 * time.Minute
+
+	// limitReachedNote is set on jobs stopped by their page limit
+	limitReachedNote = "limit reached"
+
+	defaultSearchLimit = 10
+	fetchTimeout       = 10 * time.Second
+	maxSitemapBytes    = 10 << 20
+)
+
+var (
+	// ErrEmptyQuery is returned when a search query has no terms
+	ErrEmptyQuery = errors.New("query must contain at least one term")
+	// ErrJobNotFound is returned when a crawl job does not exist
+	ErrJobNotFound = errors.New("job not found")
+	// ErrEmptySitemap is returned when a sitemap lists no URLs
+	ErrEmptySitemap = errors.New("sitemap contains no URLs")
+	// ErrJobRunning is returned when a job can't be changed mid-crawl
+	ErrJobRunning = errors.New("job is still crawling")
+	// ErrDepthTooLarge is returned for crawl depths above MaxCrawlDepth
+	ErrDepthTooLarge = fmt.Errorf("depth must be at most %d", MaxCrawlDepth)
 )
 
 // Page represents a crawled web page
@@ -19,6 +67,13 @@ type Page struct {
 	CrawledAt   time.Time `json:"crawled_at"`
 	StatusCode  int       `json:"status_code"`
 	ContentHash string    `json:"content_hash"`
+
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// ContentType is the response's media type, such as text/html or
+	// application/json. Links are only extracted from HTML.
+	ContentType string `json:"content_type,omitempty"`
 }
 
 // CrawlJob represents a crawl job
@@ -29,6 +84,29 @@ type CrawlJob struct {
 	Status    string    `json:"status"` // pending, running, completed, failed
 	CreatedAt time.Time `json:"created_at"`
 	Pages     int       `json:"pages"`
+	Seeds     []string  `json:"seeds,omitempty"` // initial frontier when seeded from a sitemap
+	MaxPages  int       `json:"max_pages"`
+	Note      string    `json:"note,omitempty"` // why the crawl stopped early, if it did
+
+	FrontierCapacity int `json:"frontier_capacity"`       // most URLs queued at once
+	DroppedLinks     int `json:"dropped_links,omitempty"` // links not queued because the frontier was full
+}
+
+// CrawlOptions holds optional limits for a new crawl job
+type CrawlOptions struct {
+	MaxPages int           // pages to store before stopping; defaults to DefaultMaxPages
+	Timeout  time.Duration // how long the crawl may run; defaults to DefaultCrawlTimeout
+
+	// FrontierCapacity bounds the URLs queued at once; links found while
+	// it is full are dropped. Defaults to DefaultFrontierCapacity.
+	FrontierCapacity int
+}
+
+// sitemap is the subset of the sitemaps.org urlset schema we read
+type sitemap struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
 }
 
 // WebCrawlerService manages web crawling
@@ -38,6 +116,22 @@ type WebCrawlerService struct {
 	jobs     map[string]*CrawlJob
 	visited  map[string]bool
 	jobIndex int64
+	client   *http.Client
+	fetcher  Fetcher
+
+	graphs map[string]map[string][]string // job ID -> page URL -> outbound links
+
+	// jobsByKey holds the latest URL-started job for each jobKey, so
+	// identical requests attach to it rather than crawling again
+	jobsByKey      map[string]*CrawlJob
+	reuseCompleted bool
+
+	index     map[string]map[string]int // term -> URL -> occurrences
+	pageTerms map[string]map[string]int // URL -> term -> occurrences
+
+	// watchers receive progress events for running jobs; see WatchJob
+	watchMu  sync.Mutex
+	watchers map[string]map[chan ProgressEvent]struct{} // job ID -> subscribers
 }
 
 // NewWebCrawlerService creates a new web crawler service
@@ -46,13 +140,114 @@ func NewWebCrawlerService() *WebCrawlerService {
 		pages:   make(map[string]*Page),
 		jobs:    make(map[string]*CrawlJob),
 		visited: make(map[string]bool),
+		client:  &http.Client{Timeout: fetchTimeout},
+		fetcher: simulatedFetcher{},
+		graphs:  make(map[string]map[string][]string),
+
+		jobsByKey: make(map[string]*CrawlJob),
+
+		index:     make(map[string]map[string]int),
+		pageTerms: make(map[string]map[string]int),
+
+		watchers: make(map[string]map[chan ProgressEvent]struct{}),
 	}
 }
 
-// CreateCrawlJob creates a new crawl job
-func (s *WebCrawlerService) CreateCrawlJob(url string, depth int) (*CrawlJob, error) {
+// SetFetcher replaces the fetcher used to retrieve pages
+func (s *WebCrawlerService) SetFetcher(fetcher Fetcher) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.fetcher = fetcher
+}
+
+// CreateCrawlJob creates a new crawl job. A request for the same URL and
+// depth as a job still crawling returns that job instead of starting
+// another; see SetReuseCompletedJobs for finished jobs.
+func (s *WebCrawlerService) CreateCrawlJob(ctx context.Context, url string, depth int) (*CrawlJob, error) {
+	return s.CreateCrawlJobWithOptions(ctx, url, depth, CrawlOptions{})
+}
+
+// CreateCrawlJobWithOptions creates a new crawl job that stops once it has
+// stored opts.MaxPages pages or run for opts.Timeout. The depth may be at
+// most MaxCrawlDepth. The crawl outlives ctx, keeping only its values.
+func (s *WebCrawlerService) CreateCrawlJobWithOptions(ctx context.Context, url string, depth int, opts CrawlOptions) (*CrawlJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if depth > MaxCrawlDepth {
+		return nil, ErrDepthTooLarge
+	}
+	return s.startJob(ctx, url, depth, opts, nil), nil
+}
+
+// CreateCrawlJobFromSitemap fetches an XML sitemap and creates a crawl job
+// whose initial frontier is every <loc> it lists. The job's depth is the
+// number of listed URLs so each of them is crawled. ctx bounds the sitemap
+// download; the crawl itself outlives it.
+func (s *WebCrawlerService) CreateCrawlJobFromSitemap(ctx context.Context, sitemapURL string) (*CrawlJob, error) {
+	seeds, err := s.fetchSitemap(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startJob(ctx, sitemapURL, len(seeds), CrawlOptions{}, seeds), nil
+}
+
+// fetchSitemap downloads a sitemap and returns the URLs it lists
+func (s *WebCrawlerService) fetchSitemap(ctx context.Context, sitemapURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching sitemap: unexpected status %d", resp.StatusCode)
+	}
+
+	var parsed sitemap
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxSitemapBytes)).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("parsing sitemap: %w", err)
+	}
+
+	seeds := make([]string, 0, len(parsed.URLs))
+	for _, entry := range parsed.URLs {
+		if loc := strings.TrimSpace(entry.Loc); loc != "" {
+			seeds = append(seeds, loc)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, ErrEmptySitemap
+	}
+	return seeds, nil
+}
+
+// startJob registers a job and starts crawling it in the background. When
+// seeds is empty the job starts from its URL, and is shared with identical
+// requests while it runs. Zero options fall back to their defaults.
+func (s *WebCrawlerService) startJob(ctx context.Context, url string, depth int, opts CrawlOptions, seeds []string) *CrawlJob {
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	capacity := opts.FrontierCapacity
+	if capacity <= 0 {
+		capacity = DefaultFrontierCapacity
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := jobKey(url, depth, maxPages)
+	if len(seeds) == 0 {
+		if job := s.existingJobLocked(key); job != nil {
+			return job
+		}
+	}
 
 	s.jobIndex++
 	jobID := generateJobID(s.jobIndex)
@@ -64,74 +259,336 @@ func (s *WebCrawlerService) CreateCrawlJob(url string, depth int) (*CrawlJob, er
 		Status:    "pending",
 		CreatedAt: time.Now(),
 		Pages:     0,
+		Seeds:     seeds,
+		MaxPages:  maxPages,
+
+		FrontierCapacity: capacity,
 	}
 
 	s.jobs[jobID] = job
+	s.graphs[jobID] = make(map[string][]string)
+	if len(seeds) == 0 {
+		s.jobsByKey[key] = job
+	}
+
+	initial := seeds
+	if len(initial) == 0 {
+		initial = []string{url}
+	}
 
 	// Start crawling in background
-	go s.crawl(job)
+	go s.runJob(ctx, opts.Timeout, func(ctx context.Context) error {
+		return s.crawl(ctx, job, append([]string(nil), initial...))
+	})
 
-	return job, nil
+	return job
 }
 
-// crawl performs the actual crawling
-func (s *WebCrawlerService) crawl(job *CrawlJob) {
+// runJob runs a background crawl under its own deadline. The crawl keeps
+// ctx's values but not its cancellation, so it survives the request that
+// started it. A timeout of 0 or less means DefaultCrawlTimeout.
+func (s *WebCrawlerService) runJob(ctx context.Context, timeout time.Duration, run func(context.Context) error) {
+	if timeout <= 0 {
+		timeout = DefaultCrawlTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	run(ctx)
+}
+
+// crawl performs the actual crawling, publishing progress to the job's
+// watchers as it goes. URLs wait in a frontier bounded by the job's
+// FrontierCapacity; links that don't fit are counted in DroppedLinks. If
+// ctx ends first the job fails with ctx's error, which crawl returns.
+func (s *WebCrawlerService) crawl(ctx context.Context, job *CrawlJob, urls []string) error {
 	s.mu.Lock()
 	job.Status = "running"
+	capacity := job.FrontierCapacity
+	if capacity <= 0 {
+		capacity = DefaultFrontierCapacity
+	}
+	running := progressLocked(ProgressStatus, job)
 	s.mu.Unlock()
+	s.publishProgress(running)
+
+	queue := newFrontier(capacity)
+	for _, url := range urls {
+		queue.push(url)
+	}
 
 	// Simulate crawling
-	urls := []string{job.URL}
-	for i := 0; i < job.Depth && len(urls) > 0; i++ {
-		currentURL := urls[0]
-		urls = urls[1:]
+	stored, limited := 0, false
+	for i := 0; i < job.Depth && queue.len() > 0; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		// Stop before fetching anything past the page limit
+		if stored >= job.MaxPages {
+			limited = true
+			break
+		}
+
+		currentURL, _ := queue.pop()
 
 		if s.isVisited(currentURL) {
 			continue
 		}
 
-		page := s.crawlPage(currentURL)
+		page := s.crawlPage(ctx, currentURL)
 		if page != nil {
 			s.storePage(page)
-			urls = append(urls, page.Links...)
-			
+			stored++
+			// Only queue links if there is room for the pages they lead to
+			if stored < job.MaxPages {
+				for _, link := range page.Links {
+					if !s.isVisited(link) {
+						queue.push(link)
+					}
+				}
+			} else if len(page.Links) > 0 {
+				limited = true
+			}
+
 			s.mu.Lock()
 			job.Pages++
+			job.DroppedLinks = queue.dropped
+			s.graphs[job.ID][page.URL] = append([]string(nil), page.Links...)
+			crawled := progressLocked(ProgressPage, job)
 			s.mu.Unlock()
+
+			crawled.URL = page.URL
+			s.publishProgress(crawled)
 		}
 
 		s.markVisited(currentURL)
 	}
 
+	err := ctx.Err()
 	s.mu.Lock()
-	job.Status = "completed"
+	job.DroppedLinks = queue.dropped
+	if limited {
+		job.Note = limitReachedNote
+	}
+	finishJobLocked(job, err)
+	completed := progressLocked(ProgressCompleted, job)
 	s.mu.Unlock()
+	s.finishProgress(completed)
+	return err
 }
 
-// crawlPage crawls a single page (simulated)
-func (s *WebCrawlerService) crawlPage(url string) *Page {
-	// Simulate HTTP request
-	page := &Page{
-		URL:        url,
-		Title:      "Page Title for " + url,
-		Content:    "Content for " + url,
-		Links:      []string{url + "/link1", url + "/link2"},
-		CrawledAt:  time.Now(),
-		StatusCode: 200,
+// finishJobLocked marks job completed, or failed with err's message when err
+// is non-nil. Must be called with s.mu held.
+func finishJobLocked(job *CrawlJob, err error) {
+	if err != nil {
+		job.Status = "failed"
+		job.Note = err.Error()
+		return
 	}
+	job.Status = "completed"
+}
 
-	// Generate content hash
-	hash := md5.Sum([]byte(page.Content))
-	page.ContentHash = hex.EncodeToString(hash[:])
+// crawlPage fetches a single page, returning nil on failure
+func (s *WebCrawlerService) crawlPage(ctx context.Context, url string) *Page {
+	s.mu.RLock()
+	fetcher := s.fetcher
+	s.mu.RUnlock()
 
+	page, err := fetcher.Fetch(ctx, url, "", "")
+	if err != nil {
+		log.Printf("crawl %s: %v", url, err)
+		return nil
+	}
 	return page
 }
 
-// storePage stores a crawled page
+// RecrawlJob re-fetches every page crawled by a job in the background,
+// sending the stored ETag/Last-Modified validators. Pages the server reports
+// as unchanged (304) keep their content and only have CrawledAt updated. New
+// links are recorded in the job's graph but not followed. Like a crawl, the
+// recrawl outlives ctx and is bounded by DefaultCrawlTimeout.
+func (s *WebCrawlerService) RecrawlJob(ctx context.Context, jobID string) (*CrawlJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[jobID]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	if job.Status == "pending" || job.Status == "running" {
+		return job, nil
+	}
+
+	urls := make([]string, 0, len(s.graphs[jobID]))
+	for url := range s.graphs[jobID] {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	if job.Status == "failed" {
+		job.Note = "" // the reason the last run failed
+	}
+	job.Status = "pending"
+	go s.runJob(ctx, 0, func(ctx context.Context) error {
+		return s.recrawl(ctx, job, urls)
+	})
+
+	return job, nil
+}
+
+// recrawl performs a conditional re-fetch of the given pages, failing the
+// job with ctx's error if ctx ends first
+func (s *WebCrawlerService) recrawl(ctx context.Context, job *CrawlJob, urls []string) error {
+	s.mu.Lock()
+	job.Status = "running"
+	fetcher := s.fetcher
+	running := progressLocked(ProgressStatus, job)
+	s.mu.Unlock()
+	s.publishProgress(running)
+
+	for _, url := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+
+		s.mu.RLock()
+		stored := s.pages[url]
+		s.mu.RUnlock()
+
+		var etag, lastModified string
+		if stored != nil {
+			etag, lastModified = stored.ETag, stored.LastModified
+		}
+
+		page, err := fetcher.Fetch(ctx, url, etag, lastModified)
+		if err != nil {
+			log.Printf("recrawl %s: %v", url, err)
+			continue
+		}
+
+		if page.StatusCode == http.StatusNotModified {
+			if stored == nil {
+				continue
+			}
+			// Unchanged: keep the stored content, only record the visit.
+			// Replace rather than mutate so readers never see a torn page.
+			touched := *stored
+			touched.CrawledAt = page.CrawledAt
+			s.mu.Lock()
+			s.pages[url] = &touched
+			s.mu.Unlock()
+			continue
+		}
+
+		s.storePage(page)
+		s.mu.Lock()
+		s.graphs[job.ID][url] = append([]string(nil), page.Links...)
+		recrawled := progressLocked(ProgressPage, job)
+		s.mu.Unlock()
+
+		recrawled.URL = url
+		s.publishProgress(recrawled)
+	}
+
+	err := ctx.Err()
+	s.mu.Lock()
+	finishJobLocked(job, err)
+	completed := progressLocked(ProgressCompleted, job)
+	s.mu.Unlock()
+	s.finishProgress(completed)
+	return err
+}
+
+// storePage stores a crawled page and updates the search index
 func (s *WebCrawlerService) storePage(page *Page) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.pages[page.URL] = page
+	s.indexPageLocked(page)
+}
+
+// indexPageLocked replaces the index entries for a page. Must be called with
+// s.mu held.
+func (s *WebCrawlerService) indexPageLocked(page *Page) {
+	s.unindexPageLocked(page.URL)
+
+	terms := make(map[string]int)
+	for _, term := range tokenize(page.Title + " " + page.Content) {
+		terms[term]++
+	}
+
+	for term, count := range terms {
+		if s.index[term] == nil {
+			s.index[term] = make(map[string]int)
+		}
+		s.index[term][page.URL] = count
+	}
+	s.pageTerms[page.URL] = terms
+}
+
+// unindexPageLocked removes a page's entries from the search index. Must be
+// called with s.mu held.
+func (s *WebCrawlerService) unindexPageLocked(url string) {
+	for term := range s.pageTerms[url] {
+		delete(s.index[term], url)
+		if len(s.index[term]) == 0 {
+			delete(s.index, term)
+		}
+	}
+	delete(s.pageTerms, url)
+}
+
+// SearchPages returns crawled pages whose title or content contains any of
+// the query terms, ranked by how often the terms occur
+func (s *WebCrawlerService) SearchPages(query string, limit int) ([]*Page, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := make(map[string]int)
+	for _, term := range terms {
+		for url, count := range s.index[term] {
+			scores[url] += count
+		}
+	}
+
+	urls := make([]string, 0, len(scores))
+	for url := range scores {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if scores[urls[i]] != scores[urls[j]] {
+			return scores[urls[i]] > scores[urls[j]]
+		}
+		return urls[i] < urls[j]
+	})
+
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+
+	pages := make([]*Page, 0, len(urls))
+	for _, url := range urls {
+		pages = append(pages, s.pages[url])
+	}
+	return pages, nil
+}
+
+// tokenize lowercases text and splits it into alphanumeric terms
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
 }
 
 // isVisited checks if a URL has been visited
@@ -174,6 +631,96 @@ func (s *WebCrawlerService) GetPage(url string) (*Page, error) {
 	return page, nil
 }
 
+// GetLinkGraph returns the pages crawled by a job mapped to their outbound
+// links
+func (s *WebCrawlerService) GetLinkGraph(jobID string) (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	graph, exists := s.graphs[jobID]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+
+	result := make(map[string][]string, len(graph))
+	for url, links := range graph {
+		result[url] = append([]string(nil), links...)
+	}
+	return result, nil
+}
+
+// ClearJob removes every page a job crawled from the page store, search
+// index and visited set, so they can be crawled again, and empties the
+// job's link graph. Pages also in another job's graph are kept. It returns
+// how many pages were removed.
+func (s *WebCrawlerService) ClearJob(jobID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[jobID]
+	if !exists {
+		return 0, ErrJobNotFound
+	}
+	if job.Status == "pending" || job.Status == "running" {
+		return 0, ErrJobRunning
+	}
+
+	removed := 0
+	for url := range s.graphs[jobID] {
+		if s.crawledByOtherJobLocked(jobID, url) {
+			continue
+		}
+		if _, stored := s.pages[url]; stored {
+			s.unindexPageLocked(url)
+			delete(s.pages, url)
+			removed++
+		}
+		delete(s.visited, url)
+	}
+
+	s.graphs[jobID] = make(map[string][]string)
+	job.Pages = 0
+
+	// Its pages are gone, so it can't serve identical requests any more
+	s.forgetJobLocked(job)
+
+	return removed, nil
+}
+
+// crawledByOtherJobLocked reports whether a job other than jobID has url in
+// its link graph. Must be called with s.mu held.
+func (s *WebCrawlerService) crawledByOtherJobLocked(jobID, url string) bool {
+	for otherID, graph := range s.graphs {
+		if _, crawled := graph[url]; crawled && otherID != jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDOT renders a link graph in Graphviz DOT format
+func formatDOT(graph map[string][]string) []byte {
+	urls := make([]string, 0, len(graph))
+	for url := range graph {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	var buf bytes.Buffer
+	buf.WriteString("digraph crawl {\n")
+	for _, url := range urls {
+		if len(graph[url]) == 0 {
+			fmt.Fprintf(&buf, "\t%q;\n", url)
+			continue
+		}
+		for _, link := range graph[url] {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", url, link)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
 // ListPages lists all crawled pages
 func (s *WebCrawlerService) ListPages() []*Page {
 	s.mu.RLock()
@@ -187,31 +734,167 @@ func (s *WebCrawlerService) ListPages() []*Page {
 	return pages
 }
 
+// crawlerState is the serialized form of the whole service. The search
+// index is not stored: it is rebuilt from the pages.
+type crawlerState struct {
+	Pages    []*Page                        `json:"pages"`
+	Jobs     []*CrawlJob                    `json:"jobs"`
+	Visited  []string                       `json:"visited"`
+	Graphs   map[string]map[string][]string `json:"graphs"`
+	JobIndex int64                          `json:"job_index"`
+}
+
+// Snapshot serializes pages, jobs, visited URLs and link graphs to JSON
+func (s *WebCrawlerService) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := crawlerState{
+		Pages:    make([]*Page, 0, len(s.pages)),
+		Jobs:     make([]*CrawlJob, 0, len(s.jobs)),
+		Visited:  make([]string, 0, len(s.visited)),
+		Graphs:   s.graphs,
+		JobIndex: s.jobIndex,
+	}
+	for _, page := range s.pages {
+		state.Pages = append(state.Pages, page)
+	}
+	sort.Slice(state.Pages, func(i, j int) bool { return state.Pages[i].URL < state.Pages[j].URL })
+	for _, job := range s.jobs {
+		state.Jobs = append(state.Jobs, job)
+	}
+	sort.Slice(state.Jobs, func(i, j int) bool { return state.Jobs[i].ID < state.Jobs[j].ID })
+	for url := range s.visited {
+		state.Visited = append(state.Visited, url)
+	}
+	sort.Strings(state.Visited)
+
+	// Marshal under the lock: jobs and graphs are still being written by
+	// running crawls
+	return json.Marshal(state)
+}
+
+// Restore replaces all crawl state with a snapshot and rebuilds the search
+// index. It fails while a crawl is in progress, since that crawl would keep
+// writing to the replaced jobs. Jobs that were running when the snapshot
+// was taken are restored as failed, as nothing resumes them.
+func (s *WebCrawlerService) Restore(data []byte) error {
+	var state crawlerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	pages := make(map[string]*Page, len(state.Pages))
+	for _, page := range state.Pages {
+		if page == nil || page.URL == "" {
+			return fmt.Errorf("page without a url")
+		}
+		pages[page.URL] = page
+	}
+	jobs := make(map[string]*CrawlJob, len(state.Jobs))
+	graphs := make(map[string]map[string][]string, len(state.Jobs))
+	for _, job := range state.Jobs {
+		if job == nil || job.ID == "" {
+			return fmt.Errorf("job without an id")
+		}
+		if _, dup := jobs[job.ID]; dup {
+			return fmt.Errorf("duplicate job %s", job.ID)
+		}
+		if job.Status == "pending" || job.Status == "running" {
+			job.Status = "failed"
+		}
+		jobs[job.ID] = job
+		graphs[job.ID] = state.Graphs[job.ID]
+		if graphs[job.ID] == nil {
+			graphs[job.ID] = make(map[string][]string)
+		}
+	}
+	visited := make(map[string]bool, len(state.Visited))
+	for _, url := range state.Visited {
+		visited[url] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.Status == "pending" || job.Status == "running" {
+			return fmt.Errorf("job %s is still crawling", job.ID)
+		}
+	}
+
+	s.pages = pages
+	s.jobs = jobs
+	s.visited = visited
+	s.graphs = graphs
+	s.jobIndex = state.JobIndex
+	s.jobsByKey = make(map[string]*CrawlJob)
+	for _, job := range state.Jobs {
+		if len(job.Seeds) > 0 {
+			continue
+		}
+		key := jobKey(job.URL, job.Depth, job.MaxPages)
+		if latest := s.jobsByKey[key]; latest == nil || job.CreatedAt.After(latest.CreatedAt) {
+			s.jobsByKey[key] = job
+		}
+	}
+	s.index = make(map[string]map[string]int)
+	s.pageTerms = make(map[string]map[string]int)
+	for _, page := range pages {
+		s.indexPageLocked(page)
+	}
+
+	return nil
+}
+
 func generateJobID(index int64) string {
 	return "job_" + string(rune(index+'0'))
 }
 
 var service *WebCrawlerService
 
+// createJobRequest is the body of /crawl
+type createJobRequest struct {
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`
+	MaxPages int    `json:"max_pages,omitempty"`
+
+	FrontierCapacity int `json:"frontier_capacity,omitempty"`
+}
+
+func (req createJobRequest) validate() error {
+	var v validate.Validator
+	v.String("url", req.URL, validate.Required, validate.URL)
+	v.Int("depth", req.Depth, validate.Min(0), validate.Max(MaxCrawlDepth))
+	v.Int("max_pages", req.MaxPages, validate.Min(0))
+	v.Int("frontier_capacity", req.FrontierCapacity, validate.Min(0))
+	return v.Err()
+}
+
 func createJobHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		URL   string `json:"url"`
-		Depth int    `json:"depth"`
-	}
+	var req createJobRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	job, err := service.CreateCrawlJob(req.URL, req.Depth)
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	job, err := service.CreateCrawlJobWithOptions(r.Context(), req.URL, req.Depth, CrawlOptions{
+		MaxPages:         req.MaxPages,
+		FrontierCapacity: req.FrontierCapacity,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -222,18 +905,18 @@ func createJobHandler(w http.ResponseWriter, r *http.Request) {
 func getJobHandler(w http.ResponseWriter, r *http.Request) {
 	jobID := r.URL.Query().Get("job_id")
 	if jobID == "" {
-		http.Error(w, "job_id parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "job_id parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	job, err := service.GetJob(jobID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	if job == nil {
-		http.Error(w, "job not found", http.StatusNotFound)
+		apierror.Error(w, "job not found", http.StatusNotFound)
 		return
 	}
 
@@ -244,18 +927,18 @@ func getJobHandler(w http.ResponseWriter, r *http.Request) {
 func getPageHandler(w http.ResponseWriter, r *http.Request) {
 	url := r.URL.Query().Get("url")
 	if url == "" {
-		http.Error(w, "url parameter is required", http.StatusBadRequest)
+		apierror.Error(w, "url parameter is required", http.StatusBadRequest)
 		return
 	}
 
 	page, err := service.GetPage(url)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apierror.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	if page == nil {
-		http.Error(w, "page not found", http.StatusNotFound)
+		apierror.Error(w, "page not found", http.StatusNotFound)
 		return
 	}
 
@@ -264,7 +947,150 @@ func getPageHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listPagesHandler(w http.ResponseWriter, r *http.Request) {
-	pages := service.ListPages()
+	// Stream the pages so a large crawl isn't encoded in one buffer
+	jsonstream.WriteArray(w, service.ListPages())
+}
+
+func recrawlJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		apierror.Error(w, "job_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := service.RecrawlJob(r.Context(), jobID)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func clearJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		apierror.Error(w, "job_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := service.ClearJob(jobID)
+	if errors.Is(err, ErrJobNotFound) {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrJobRunning) {
+		apierror.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clearJobResponse{Removed: removed})
+}
+
+// clearJobResponse is the body returned by /job/clear
+type clearJobResponse struct {
+	Removed int `json:"removed"`
+}
+
+// createSitemapJobRequest is the body of /crawl/sitemap
+type createSitemapJobRequest struct {
+	SitemapURL string `json:"sitemap_url"`
+}
+
+func (req createSitemapJobRequest) validate() error {
+	var v validate.Validator
+	v.String("sitemap_url", req.SitemapURL, validate.Required, validate.URL)
+	return v.Err()
+}
+
+func createSitemapJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req createSitemapJobRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		validate.Write(w, err)
+		return
+	}
+
+	job, err := service.CreateCrawlJobFromSitemap(r.Context(), req.SitemapURL)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func graphHandler(w http.ResponseWriter, r *http.Request) {
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		apierror.Error(w, "job_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	graph, err := service.GetLinkGraph(jobID)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Write(formatDOT(graph))
+	default:
+		apierror.Error(w, "unsupported format", http.StatusBadRequest)
+	}
+}
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		apierror.Error(w, "q parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			apierror.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	pages, err := service.SearchPages(query, limit)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(pages)
 }
@@ -274,17 +1100,103 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// registerRoutes registers every endpoint on mux and returns the registry
+// that documents them
+func registerRoutes(mux *http.ServeMux) *openapi.Registry {
+	api := openapi.NewRegistry(mux, "Web Crawler Service", "1.0.0")
+
+	// Readiness fails if the in-memory store lock is stuck
+	checker := health.NewChecker()
+	checker.Register("store", true, health.LockCheck(&service.mu, time.Second))
+
+	// Compress large list responses for clients that accept gzip
+	compress := middleware.Gzip(middleware.DefaultGzipMinBytes)
+
+	// State dumps and bulk deletes need the admin token
+	adminOnly := middleware.AdminFromEnv("ADMIN_TOKEN")
+
+	jobQuery := []openapi.Param{{Name: "job_id", Required: true}}
+
+	api.HandleFunc("/crawl", createJobHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Start a crawl job",
+		Request: createJobRequest{}, Response: CrawlJob{},
+		Responses: map[int]string{200: "Job started", 400: "Invalid request"},
+	})
+	api.HandleFunc("/crawl/sitemap", createSitemapJobHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Start a crawl job seeded from a sitemap",
+		Request: createSitemapJobRequest{}, Response: CrawlJob{},
+		Responses: map[int]string{200: "Job started", 400: "Missing sitemap_url", 502: "Sitemap could not be fetched"},
+	})
+	api.HandleFunc("/recrawl", recrawlJobHandler, openapi.Route{
+		Method: http.MethodPost, Summary: "Recrawl the pages of a job", Query: jobQuery, Response: CrawlJob{},
+		Responses: map[int]string{200: "Recrawl started", 400: "Missing job_id", 404: "Job not found"},
+	})
+	api.HandleFunc("/job", getJobHandler, openapi.Route{
+		Summary: "Get a crawl job", Query: jobQuery, Response: CrawlJob{},
+		Responses: map[int]string{200: "The job", 400: "Missing job_id", 404: "Job not found"},
+	})
+	api.HandleFunc("/job/stream", jobStreamHandler, openapi.Route{
+		Summary: "Stream a job's progress as server-sent events until it completes", Query: jobQuery, Response: ProgressEvent{},
+		Responses: map[int]string{200: "text/event-stream of status, page and completed events", 400: "Missing job_id", 404: "Job not found"},
+	})
+	api.Handle("/job/clear", adminOnly(http.HandlerFunc(clearJobHandler)), openapi.Route{
+		Method: http.MethodPost, Summary: "Remove every page a job crawled", Query: jobQuery, Response: clearJobResponse{},
+		Responses: map[int]string{200: "Number of pages removed", 400: "Missing job_id", 403: "Missing or invalid admin token", 404: "Job not found", 409: "Job is still crawling"},
+	})
+	api.HandleFunc("/page", getPageHandler, openapi.Route{
+		Summary: "Get a crawled page", Query: []openapi.Param{{Name: "url", Required: true}}, Response: Page{},
+		Responses: map[int]string{200: "The page", 400: "Missing url", 404: "Page not found"},
+	})
+	api.Handle("/pages", compress(http.HandlerFunc(listPagesHandler)), openapi.Route{
+		Summary: "List all crawled pages", Response: []Page{},
+		Responses: map[int]string{200: "All pages"},
+	})
+	api.Handle("/search", compress(http.HandlerFunc(searchHandler)), openapi.Route{
+		Summary: "Search crawled pages",
+		Query: []openapi.Param{
+			{Name: "q", Required: true},
+			{Name: "limit", Description: "Maximum number of results"},
+		},
+		Response:  []Page{},
+		Responses: map[int]string{200: "Pages ranked by relevance", 400: "Missing q or invalid limit"},
+	})
+	api.HandleFunc("/graph", graphHandler, openapi.Route{
+		Summary: "Get the link graph of a job",
+		Query: []openapi.Param{
+			{Name: "job_id", Required: true},
+			{Name: "format", Description: "json (default) or dot"},
+		},
+		Response:  map[string][]string{},
+		Responses: map[int]string{200: "Adjacency list of page URLs", 400: "Missing job_id or unsupported format", 404: "Job not found"},
+	})
+	api.Handle(admin.StatePath, adminOnly(admin.StateHandler(service)), admin.Routes(crawlerState{})...)
+	api.Handle(batch.Path, batch.Handler(mux, batch.DefaultMaxRequests), openapi.Route{
+		Method: http.MethodPost, Summary: "Run several requests in one round trip",
+		Request: []batch.Request{}, Response: []batch.Response{},
+		Responses: map[int]string{200: "One response per sub-request", 400: "Invalid batch", 413: "Too many sub-requests"},
+	})
+	api.HandleFunc("/healthz/live", health.LiveHandler, openapi.Route{
+		Summary: "Liveness probe", Responses: map[int]string{200: "Process is running"},
+	})
+	api.HandleFunc("/healthz/ready", checker.ReadyHandler, openapi.Route{
+		Summary: "Readiness probe", Response: health.Report{},
+		Responses: map[int]string{200: "Ready", 503: "A critical check failed"},
+	})
+	api.HandleFunc("/health", healthHandler, openapi.Route{
+		Summary: "Health check", Responses: map[int]string{200: "Healthy"},
+	})
+	api.Mount()
+
+	return api
+}
+
 func main() {
 	service = NewWebCrawlerService()
-
-	http.HandleFunc("/crawl", createJobHandler)
-	http.HandleFunc("/job", getJobHandler)
-	http.HandleFunc("/page", getPageHandler)
-	http.HandleFunc("/pages", listPagesHandler)
-	http.HandleFunc("/health", healthHandler)
+	// Serve repeat crawls of a URL from the last completed job's pages
+	service.SetReuseCompletedJobs(os.Getenv("CRAWLER_REUSE_COMPLETED") == "true")
+	registerRoutes(http.DefaultServeMux)
 
 	port := ":8086"
 	log.Printf("Web crawler service starting on %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, middleware.Chain(http.DefaultServeMux, middleware.Standard(log.Default())...)))
 }
-
@@ -5,9 +5,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -21,7 +24,7 @@ func TestNewWebCrawlerService(t *testing.T) {
 
 func TestCreateCrawlJob(t *testing.T) {
 	service := NewWebCrawlerService()
-	job, err := service.CreateCrawlJob("https://example.com", 2)
+	job, err := service.CreateCrawlJob(context.Background(), "https://example.com", 2)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -35,7 +38,7 @@ func TestCreateCrawlJob(t *testing.T) {
 
 func TestGetJob(t *testing.T) {
 	service := NewWebCrawlerService()
-	job, _ := service.CreateCrawlJob("https://example.com", 2)
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 2)
 	
 	// Wait for job to start
 	time.Sleep(100 * time.Millisecond)
@@ -51,7 +54,7 @@ func TestGetJob(t *testing.T) {
 
 func TestGetPage(t *testing.T) {
 	service := NewWebCrawlerService()
-	service.CreateCrawlJob("https://example.com", 1)
+	service.CreateCrawlJob(context.Background(), "https://example.com", 1)
 	
 	// Wait for crawling to complete
 	time.Sleep(200 * time.Millisecond)
@@ -67,7 +70,7 @@ func TestGetPage(t *testing.T) {
 
 func TestListPages(t *testing.T) {
 	service := NewWebCrawlerService()
-	service.CreateCrawlJob("https://example.com", 1)
+	service.CreateCrawlJob(context.Background(), "https://example.com", 1)
 	
 	// Wait for crawling to complete
 	time.Sleep(200 * time.Millisecond)
@@ -108,3 +111,370 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+
+func TestSearchPages_RankedByFrequency(t *testing.T) {
+	service := NewWebCrawlerService()
+	service.storePage(&Page{URL: "https://a.com", Title: "Go tips", Content: "go go go concurrency"})
+	service.storePage(&Page{URL: "https://b.com", Title: "Kubernetes", Content: "deploying Go services"})
+	service.storePage(&Page{URL: "https://c.com", Title: "Gardening", Content: "tomatoes"})
+
+	pages, err := service.SearchPages("GO", 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(pages))
+	}
+	if pages[0].URL != "https://a.com" || pages[1].URL != "https://b.com" {
+		t.Errorf("Expected [a.com b.com], got [%s %s]", pages[0].URL, pages[1].URL)
+	}
+
+	pages, _ = service.SearchPages("go", 1)
+	if len(pages) != 1 {
+		t.Errorf("Expected limit to be applied, got %d results", len(pages))
+	}
+}
+
+func TestSearchPages_ReindexOnRestore(t *testing.T) {
+	service := NewWebCrawlerService()
+	service.storePage(&Page{URL: "https://a.com", Title: "Old", Content: "stale words"})
+	service.storePage(&Page{URL: "https://a.com", Title: "New", Content: "fresh words"})
+
+	if pages, _ := service.SearchPages("stale", 10); len(pages) != 0 {
+		t.Errorf("Expected stale terms to be removed, got %d results", len(pages))
+	}
+	if pages, _ := service.SearchPages("fresh", 10); len(pages) != 1 {
+		t.Errorf("Expected 1 result for fresh, got %d", len(pages))
+	}
+}
+
+func TestSearchPages_CrawledPages(t *testing.T) {
+	service := NewWebCrawlerService()
+	service.CreateCrawlJob(context.Background(), "https://example.com", 3)
+
+	// Wait for crawling to complete
+	time.Sleep(200 * time.Millisecond)
+
+	pages, err := service.SearchPages("link2", 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(pages) != 1 || pages[0].URL != "https://example.com/link2" {
+		t.Errorf("Expected only https://example.com/link2, got %v", pages)
+	}
+}
+
+func TestSearchPages_EmptyQuery(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	if _, err := service.SearchPages("  !! ", 10); err != ErrEmptyQuery {
+		t.Errorf("Expected ErrEmptyQuery, got %v", err)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	service = NewWebCrawlerService()
+	service.storePage(&Page{URL: "https://a.com", Title: "Go tips", Content: "go"})
+
+	req := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+	w := httptest.NewRecorder()
+
+	searchHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var pages []*Page
+	json.NewDecoder(w.Body).Decode(&pages)
+	if len(pages) != 1 || pages[0].URL != "https://a.com" {
+		t.Errorf("Unexpected results %v", pages)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/search", nil)
+	w = httptest.NewRecorder()
+	searchHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestGetLinkGraph(t *testing.T) {
+	service := NewWebCrawlerService()
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 3)
+	other, _ := service.CreateCrawlJob(context.Background(), "https://other.com", 1)
+
+	// Wait for crawling to complete
+	time.Sleep(200 * time.Millisecond)
+
+	graph, err := service.GetLinkGraph(job.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := map[string][]string{
+		"https://example.com":       {"https://example.com/link1", "https://example.com/link2"},
+		"https://example.com/link1": {"https://example.com/link1/link1", "https://example.com/link1/link2"},
+		"https://example.com/link2": {"https://example.com/link2/link1", "https://example.com/link2/link2"},
+	}
+	if len(graph) != len(expected) {
+		t.Fatalf("Expected %d nodes, got %d: %v", len(expected), len(graph), graph)
+	}
+	for url, links := range expected {
+		got := graph[url]
+		if len(got) != len(links) {
+			t.Errorf("%s: expected %v, got %v", url, links, got)
+			continue
+		}
+		for i := range links {
+			if got[i] != links[i] {
+				t.Errorf("%s: expected %v, got %v", url, links, got)
+			}
+		}
+	}
+
+	otherGraph, _ := service.GetLinkGraph(other.ID)
+	if len(otherGraph) != 1 {
+		t.Errorf("Expected 1 node in other job graph, got %v", otherGraph)
+	}
+	if _, mixed := otherGraph["https://example.com"]; mixed {
+		t.Error("Expected graphs of different jobs not to be mixed")
+	}
+}
+
+func TestGetLinkGraph_UnknownJob(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	if _, err := service.GetLinkGraph("missing"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestGraphHandler_DOT(t *testing.T) {
+	service = NewWebCrawlerService()
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+
+	// Wait for crawling to complete
+	time.Sleep(200 * time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/graph?format=dot&job_id="+job.ID, nil)
+	w := httptest.NewRecorder()
+
+	graphHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "digraph crawl {") {
+		t.Errorf("Expected DOT output, got %s", body)
+	}
+	if !strings.Contains(body, `"https://example.com" -> "https://example.com/link1";`) {
+		t.Errorf("Expected edge in DOT output, got %s", body)
+	}
+}
+
+func TestGraphHandler_NotFound(t *testing.T) {
+	service = NewWebCrawlerService()
+
+	req := httptest.NewRequest(http.MethodGet, "/graph?job_id=missing", nil)
+	w := httptest.NewRecorder()
+
+	graphHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCreateCrawlJobFromSitemap(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%[1]s/about</loc></url>
+  <url><loc>%[1]s/blog</loc></url>
+  <url><loc> %[1]s/contact </loc></url>
+</urlset>`, server.URL)
+	}))
+	defer server.Close()
+
+	service := NewWebCrawlerService()
+	job, err := service.CreateCrawlJobFromSitemap(context.Background(), server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(job.Seeds) != 3 {
+		t.Fatalf("Expected 3 seeds, got %v", job.Seeds)
+	}
+
+	// Wait for crawling to complete
+	time.Sleep(200 * time.Millisecond)
+
+	for _, path := range []string{"/about", "/blog", "/contact"} {
+		page, _ := service.GetPage(server.URL + path)
+		if page == nil {
+			t.Errorf("Expected %s to be crawled and stored", path)
+		}
+	}
+
+	got, _ := service.GetJob(job.ID)
+	if got.Pages != 3 {
+		t.Errorf("Expected 3 pages crawled, got %d", got.Pages)
+	}
+}
+
+func TestCreateCrawlJobFromSitemap_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty.xml":
+			w.Write([]byte(`<urlset></urlset>`))
+		case "/broken.xml":
+			w.Write([]byte(`<urlset><url>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service := NewWebCrawlerService()
+
+	if _, err := service.CreateCrawlJobFromSitemap(context.Background(), server.URL + "/empty.xml"); err != ErrEmptySitemap {
+		t.Errorf("Expected ErrEmptySitemap, got %v", err)
+	}
+	if _, err := service.CreateCrawlJobFromSitemap(context.Background(), server.URL + "/broken.xml"); err == nil {
+		t.Error("Expected error for malformed sitemap")
+	}
+	if _, err := service.CreateCrawlJobFromSitemap(context.Background(), server.URL + "/missing.xml"); err == nil {
+		t.Error("Expected error for missing sitemap")
+	}
+}
+
+func TestCreateSitemapJobHandler_MissingURL(t *testing.T) {
+	service = NewWebCrawlerService()
+
+	req := httptest.NewRequest(http.MethodPost, "/crawl/sitemap", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+
+	createSitemapJobHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRecrawlJob_NotModified(t *testing.T) {
+	fetches := 0
+	conditional := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, "<html><title>Version %d</title></html>", fetches)
+	}))
+	defer server.Close()
+
+	service := NewWebCrawlerService()
+	service.SetFetcher(NewHTTPFetcher(server.Client()))
+
+	job, _ := service.CreateCrawlJob(context.Background(), server.URL, 1)
+	time.Sleep(200 * time.Millisecond)
+
+	first, _ := service.GetPage(server.URL)
+	if first == nil || first.Title != "Version 1" || first.ETag != `"v1"` {
+		t.Fatalf("Expected initial page with ETag, got %+v", first)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := service.RecrawlJob(context.Background(), job.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	second, _ := service.GetPage(server.URL)
+	if conditional != 1 {
+		t.Errorf("Expected 1 conditional request, got %d", conditional)
+	}
+	if second.Title != "Version 1" || second.Content != first.Content {
+		t.Errorf("Expected content to be kept on 304, got %+v", second)
+	}
+	if !second.CrawledAt.After(first.CrawledAt) {
+		t.Error("Expected CrawledAt to advance on 304")
+	}
+}
+
+func TestRecrawlJob_Changed(t *testing.T) {
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+		fmt.Fprintf(w, "<html><title>Version %d</title></html>", version)
+	}))
+	defer server.Close()
+
+	service := NewWebCrawlerService()
+	service.SetFetcher(NewHTTPFetcher(server.Client()))
+
+	job, _ := service.CreateCrawlJob(context.Background(), server.URL, 1)
+	time.Sleep(200 * time.Millisecond)
+
+	version = 2
+	service.RecrawlJob(context.Background(), job.ID)
+	time.Sleep(200 * time.Millisecond)
+
+	page, _ := service.GetPage(server.URL)
+	if page.Title != "Version 2" {
+		t.Errorf("Expected updated page, got %q", page.Title)
+	}
+}
+
+func TestRecrawlJob_UnknownJob(t *testing.T) {
+	service := NewWebCrawlerService()
+
+	if _, err := service.RecrawlJob(context.Background(), "missing"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestRegisterRoutes_OpenAPI(t *testing.T) {
+	service = NewWebCrawlerService()
+	mux := http.NewServeMux()
+	api := registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	routes := api.Routes()
+	if len(routes) < len(api.Patterns()) {
+		t.Errorf("Expected every registered pattern to be documented, got %d routes for %d patterns", len(routes), len(api.Patterns()))
+	}
+	for _, route := range routes {
+		op, exists := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !exists {
+			t.Errorf("Expected %s %s to be documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s: expected responses", route.Method, route.Path)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"common/apierror"
+)
+
+// progressBufferSize is how many undelivered events a slow /job/stream
+// subscriber may fall behind by before progress events are dropped. The
+// stream always ends with the job's final state even if events were lost.
+const progressBufferSize = 64
+
+// Progress event types
+const (
+	ProgressStatus    = "status"    // the job's status changed
+	ProgressPage      = "page"      // a page was crawled
+	ProgressCompleted = "completed" // the job finished; the stream ends
+)
+
+// ProgressEvent is one update about a running crawl job
+type ProgressEvent struct {
+	Type   string `json:"type"`
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+	Pages  int    `json:"pages"`
+	URL    string `json:"url,omitempty"` // the page just crawled, for page events
+	Note   string `json:"note,omitempty"`
+}
+
+// progressLocked builds an event from job's current state. Callers must
+// hold s.mu.
+func progressLocked(eventType string, job *CrawlJob) ProgressEvent {
+	return ProgressEvent{
+		Type:   eventType,
+		JobID:  job.ID,
+		Status: job.Status,
+		Pages:  job.Pages,
+		Note:   job.Note,
+	}
+}
+
+// WatchJob subscribes to progress events for a job. The channel receives
+// status and page events while the job runs and is closed after its
+// completed event. Watching a job that isn't running yields just the
+// completed event. Call stop to unsubscribe early.
+func (s *WebCrawlerService) WatchJob(jobID string) (events <-chan ProgressEvent, stop func(), err error) {
+	// Hold s.mu so the job can't finish between the status check and the
+	// registration below; jobs finish under the write lock
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[jobID]
+	if !exists {
+		return nil, nil, ErrJobNotFound
+	}
+
+	ch := make(chan ProgressEvent, progressBufferSize)
+	if job.Status != "pending" && job.Status != "running" {
+		ch <- progressLocked(ProgressCompleted, job)
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.watchers[jobID] == nil {
+		s.watchers[jobID] = make(map[chan ProgressEvent]struct{})
+	}
+	s.watchers[jobID][ch] = struct{}{}
+
+	stop = func() {
+		s.watchMu.Lock()
+		defer s.watchMu.Unlock()
+		if _, watching := s.watchers[jobID][ch]; watching {
+			delete(s.watchers[jobID], ch)
+			close(ch)
+		}
+	}
+	return ch, stop, nil
+}
+
+// publishProgress sends event to the job's watchers without blocking; a
+// watcher that has fallen behind misses it
+func (s *WebCrawlerService) publishProgress(event ProgressEvent) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for ch := range s.watchers[event.JobID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// finishProgress sends the completed event and closes every watcher of the
+// job
+func (s *WebCrawlerService) finishProgress(event ProgressEvent) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for ch := range s.watchers[event.JobID] {
+		select {
+		case ch <- event:
+		default:
+		}
+		close(ch)
+	}
+	delete(s.watchers, event.JobID)
+}
+
+// jobStreamHandler streams a job's progress as server-sent events until it
+// completes or the client goes away. The first event is the job's current
+// state.
+func jobStreamHandler(w http.ResponseWriter, r *http.Request) {
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		apierror.Error(w, "job_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	events, stop, err := service.WatchJob(jobID)
+	if err != nil {
+		apierror.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher := http.NewResponseController(w)
+
+	if job, _ := service.GetJob(jobID); job != nil {
+		service.mu.RLock()
+		current := progressLocked(ProgressStatus, job)
+		service.mu.RUnlock()
+		writeProgress(w, current)
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				// The completed event was dropped for a slow reader; end
+				// with the job's final state anyway
+				if job, _ := service.GetJob(jobID); job != nil {
+					service.mu.RLock()
+					final := progressLocked(ProgressCompleted, job)
+					service.mu.RUnlock()
+					writeProgress(w, final)
+				}
+				return
+			}
+			writeProgress(w, event)
+			flusher.Flush()
+			if event.Type == ProgressCompleted {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeProgress writes event as one server-sent event named after its type
+func writeProgress(w http.ResponseWriter, event ProgressEvent) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectProgress reads events until the channel closes
+func collectProgress(t *testing.T, events <-chan ProgressEvent) []ProgressEvent {
+	t.Helper()
+	var got []ProgressEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return got
+			}
+			got = append(got, event)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the job to finish, got %+v", got)
+		}
+	}
+}
+
+func TestWatchJob_StreamsPagesThenCompletes(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 2)
+	events, stop, err := service.WatchJob(job.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer stop()
+	close(fetcher.release)
+
+	got := collectProgress(t, events)
+	if len(got) < 2 {
+		t.Fatalf("Expected page events and a completed event, got %+v", got)
+	}
+	pages := 0
+	for _, event := range got[:len(got)-1] {
+		if event.Type != ProgressPage {
+			continue
+		}
+		pages++
+		if event.Pages != pages || event.URL == "" {
+			t.Errorf("Expected page event %d with a URL, got %+v", pages, event)
+		}
+	}
+	if pages == 0 {
+		t.Errorf("Expected at least one page event, got %+v", got)
+	}
+	last := got[len(got)-1]
+	if last.Type != ProgressCompleted || last.Status != "completed" || last.Pages != pages {
+		t.Errorf("Expected a completed event with %d pages, got %+v", pages, last)
+	}
+}
+
+func TestWatchJob_FinishedJob(t *testing.T) {
+	service := NewWebCrawlerService()
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+	waitForJob(t, service, job.ID)
+
+	events, stop, err := service.WatchJob(job.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer stop()
+	got := collectProgress(t, events)
+	if len(got) != 1 || got[0].Type != ProgressCompleted {
+		t.Errorf("Expected just a completed event, got %+v", got)
+	}
+
+	if _, _, err := service.WatchJob("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestWatchJob_StopUnsubscribes(t *testing.T) {
+	service := NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+	events, stop, _ := service.WatchJob(job.ID)
+	stop()
+	stop()
+	if _, open := <-events; open {
+		t.Error("Expected stop to close the channel")
+	}
+
+	close(fetcher.release)
+	waitForJob(t, service, job.ID)
+}
+
+func TestJobStreamHandler(t *testing.T) {
+	service = NewWebCrawlerService()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	service.SetFetcher(fetcher)
+	job, _ := service.CreateCrawlJob(context.Background(), "https://example.com", 1)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/job/stream?job_id=" + job.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %q", ct)
+	}
+	close(fetcher.release)
+
+	var names []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) < 2 || names[0] != ProgressStatus || names[len(names)-1] != ProgressCompleted {
+		t.Errorf("Expected a status event first and a completed event last, got %v", names)
+	}
+
+	for path, want := range map[string]int{
+		"/job/stream":               http.StatusBadRequest,
+		"/job/stream?job_id=absent": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}